@@ -0,0 +1,124 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+	types2 "github.com/erigontech/erigon-lib/types"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/rpc"
+)
+
+// External is a client of an external signer implementing the account_* API
+// of clef, over HTTP, websocket or IPC.
+type External struct {
+	client *rpc.Client
+}
+
+// NewExternal connects to the external signer at the given URL, or IPC path.
+func NewExternal(ctx context.Context, endpoint string, logger log.Logger) (*External, error) {
+	var (
+		client *rpc.Client
+		err    error
+	)
+	if u, perr := url.Parse(endpoint); perr == nil && u.Scheme == "" {
+		client, err = rpc.DialIPC(ctx, endpoint, logger) // clef serves its API on an IPC socket by default
+	} else {
+		client, err = rpc.DialContext(ctx, endpoint, logger)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial external signer: %w", err)
+	}
+	return &External{client: client}, nil
+}
+
+func (e *External) Accounts(ctx context.Context) ([]libcommon.Address, error) {
+	var accounts []libcommon.Address
+	if err := e.client.CallContext(ctx, &accounts, "account_list"); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (e *External) SignText(ctx context.Context, account libcommon.Address, text []byte) ([]byte, error) {
+	var sig hexutility.Bytes
+	if err := e.client.CallContext(ctx, &sig, "account_signData", "text/plain", account, hexutility.Bytes(text)); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (e *External) SignTypedData(ctx context.Context, account libcommon.Address, data *TypedData) ([]byte, error) {
+	var sig hexutility.Bytes
+	if err := e.client.CallContext(ctx, &sig, "account_signTypedData", account, data); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// externalTxArgs are the arguments of account_signTransaction.
+type externalTxArgs struct {
+	From                 libcommon.Address  `json:"from"`
+	To                   *libcommon.Address `json:"to"`
+	Gas                  hexutil.Uint64     `json:"gas"`
+	GasPrice             *hexutil.Big       `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big       `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big       `json:"maxPriorityFeePerGas,omitempty"`
+	Value                *hexutil.Big       `json:"value"`
+	Nonce                hexutil.Uint64     `json:"nonce"`
+	Input                hexutility.Bytes   `json:"input"`
+	AccessList           *types2.AccessList `json:"accessList,omitempty"`
+	ChainID              *hexutil.Big       `json:"chainId"`
+}
+
+func (e *External) SignTx(ctx context.Context, account libcommon.Address, txn types.Transaction, chainID *uint256.Int) (types.Transaction, error) {
+	args := externalTxArgs{
+		From:    account,
+		To:      txn.GetTo(),
+		Gas:     hexutil.Uint64(txn.GetGas()),
+		Value:   (*hexutil.Big)(txn.GetValue().ToBig()),
+		Nonce:   hexutil.Uint64(txn.GetNonce()),
+		Input:   txn.GetData(),
+		ChainID: (*hexutil.Big)(chainID.ToBig()),
+	}
+	switch txn.Type() {
+	case types.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(txn.GetPrice().ToBig())
+	case types.AccessListTxType:
+		accessList := txn.GetAccessList()
+		args.GasPrice, args.AccessList = (*hexutil.Big)(txn.GetPrice().ToBig()), &accessList
+	case types.DynamicFeeTxType:
+		accessList := txn.GetAccessList()
+		args.MaxFeePerGas, args.MaxPriorityFeePerGas = (*hexutil.Big)(txn.GetFeeCap().ToBig()), (*hexutil.Big)(txn.GetTip().ToBig())
+		args.AccessList = &accessList
+	default:
+		return nil, fmt.Errorf("%w: %d", types.ErrTxTypeNotSupported, txn.Type())
+	}
+
+	var result struct {
+		Raw hexutility.Bytes `json:"raw"`
+	}
+	if err := e.client.CallContext(ctx, &result, "account_signTransaction", args); err != nil {
+		return nil, err
+	}
+	signed, err := types.DecodeTransaction(result.Raw)
+	if err != nil {
+		return nil, err
+	}
+	// the external signer must sign the transaction as it is
+	if signed.SigningHash(chainID.ToBig()) != txn.SigningHash(chainID.ToBig()) {
+		return nil, fmt.Errorf("external signer altered transaction %x", txn.SigningHash(chainID.ToBig()))
+	}
+	if sender, err := signed.Sender(*types.LatestSignerForChainID(chainID.ToBig())); err != nil || sender != account {
+		return nil, fmt.Errorf("external signer signed for %x instead of %x: %v", sender, account, err)
+	}
+	return signed, nil
+}
@@ -0,0 +1,110 @@
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/rpc"
+)
+
+// fakeClef is the account_* API of clef, signing with a keystore.
+type fakeClef struct {
+	ks     *Keystore
+	tamper bool // bump the nonce of the transactions it signs
+}
+
+func (c *fakeClef) List(ctx context.Context) ([]libcommon.Address, error) {
+	return c.ks.Accounts(ctx)
+}
+
+func (c *fakeClef) SignData(ctx context.Context, contentType string, account libcommon.Address, data hexutility.Bytes) (hexutility.Bytes, error) {
+	return c.ks.SignText(ctx, account, data)
+}
+
+func (c *fakeClef) SignTypedData(ctx context.Context, account libcommon.Address, data TypedData) (hexutility.Bytes, error) {
+	return c.ks.SignTypedData(ctx, account, &data)
+}
+
+func (c *fakeClef) SignTransaction(ctx context.Context, args externalTxArgs) (map[string]hexutility.Bytes, error) {
+	if c.tamper {
+		args.Nonce++
+	}
+	txn := types.NewTransaction(uint64(args.Nonce), *args.To, uint256.MustFromBig(args.Value.ToInt()), uint64(args.Gas), uint256.MustFromBig(args.GasPrice.ToInt()), args.Input)
+	signed, err := c.ks.SignTx(ctx, args.From, txn, uint256.MustFromBig(args.ChainID.ToInt()))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := types.MarshalTransactionsBinary(types.Transactions{signed})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]hexutility.Bytes{"raw": raw[0]}, nil
+}
+
+func TestExternal(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	ks := &Keystore{keys: map[libcommon.Address]*ecdsa.PrivateKey{account: key}, addresses: []libcommon.Address{account}}
+
+	logger := log.New()
+	server := rpc.NewServer(50, false, false, true, logger, 100)
+	clef := &fakeClef{ks: ks}
+	require.NoError(t, server.RegisterName("account", clef))
+	defer server.Stop()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	ctx := context.Background()
+	ext, err := NewExternal(ctx, httpServer.URL, logger)
+	require.NoError(t, err)
+
+	accounts, err := ext.Accounts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []libcommon.Address{account}, accounts)
+
+	sig, err := ext.SignText(ctx, account, []byte("hello"))
+	require.NoError(t, err)
+	expected, err := ks.SignText(ctx, account, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, expected, sig)
+
+	chainID := uint256.NewInt(1337)
+	txn := types.NewTransaction(3, libcommon.Address{1}, uint256.NewInt(1), 21_000, uint256.NewInt(1), nil)
+	signed, err := ext.SignTx(ctx, account, txn, chainID)
+	require.NoError(t, err)
+	sender, err := signed.Sender(*types.LatestSignerForChainID(big.NewInt(1337)))
+	require.NoError(t, err)
+	require.Equal(t, account, sender)
+	require.Equal(t, txn.SigningHash(chainID.ToBig()), signed.SigningHash(chainID.ToBig()))
+
+	// over IPC
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "clef.ipc"))
+	require.NoError(t, err)
+	defer listener.Close()
+	go server.ServeListener(listener) //nolint:errcheck
+	ipc, err := NewExternal(ctx, listener.Addr().String(), logger)
+	require.NoError(t, err)
+	accounts, err = ipc.Accounts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []libcommon.Address{account}, accounts)
+
+	// a signer signing another transaction is rejected
+	clef.tamper = true
+	_, err = ext.SignTx(ctx, account, txn, chainID)
+	require.ErrorContains(t, err, "altered")
+}
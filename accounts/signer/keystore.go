@@ -0,0 +1,231 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/holiman/uint256"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+)
+
+var ErrDecrypt = errors.New("could not decrypt key with given password")
+
+// Keystore is a local signer of the keys of a keystore directory, in the web3
+// secret storage format. The keys are decrypted once, at load.
+type Keystore struct {
+	keys      map[libcommon.Address]*ecdsa.PrivateKey
+	addresses []libcommon.Address
+}
+
+// NewKeystore decrypts the key files of dir, each with the first of the
+// passwords which decrypts it. A key file which none decrypts is an error.
+func NewKeystore(dir string, passwords []string) (*Keystore, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ks := &Keystore{keys: map[libcommon.Address]*ecdsa.PrivateKey{}}
+	for _, entry := range entries {
+		// skip the directories and the editor backups, as geth does
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || name == "README" {
+			continue
+		}
+		keyJSON, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		key, err := decryptKeyWithAny(keyJSON, passwords)
+		if err != nil {
+			return nil, fmt.Errorf("key file %s: %w", name, err)
+		}
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		if _, ok := ks.keys[addr]; !ok {
+			ks.addresses = append(ks.addresses, addr)
+		}
+		ks.keys[addr] = key
+	}
+	return ks, nil
+}
+
+func decryptKeyWithAny(keyJSON []byte, passwords []string) (*ecdsa.PrivateKey, error) {
+	err := ErrDecrypt
+	for _, password := range passwords {
+		var key *ecdsa.PrivateKey
+		if key, err = DecryptKey(keyJSON, password); err == nil {
+			return key, nil
+		}
+		if !errors.Is(err, ErrDecrypt) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (ks *Keystore) Accounts(ctx context.Context) ([]libcommon.Address, error) {
+	return ks.addresses, nil
+}
+
+func (ks *Keystore) SignText(ctx context.Context, account libcommon.Address, text []byte) ([]byte, error) {
+	return ks.signHash(account, TextHash(text))
+}
+
+func (ks *Keystore) SignTypedData(ctx context.Context, account libcommon.Address, data *TypedData) ([]byte, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return ks.signHash(account, hash[:])
+}
+
+func (ks *Keystore) SignTx(ctx context.Context, account libcommon.Address, txn types.Transaction, chainID *uint256.Int) (types.Transaction, error) {
+	key, ok := ks.keys[account]
+	if !ok {
+		return nil, fmt.Errorf("%w: %x", ErrUnknownAccount, account)
+	}
+	return types.SignTx(txn, *types.LatestSignerForChainID(chainID.ToBig()), key)
+}
+
+func (ks *Keystore) signHash(account libcommon.Address, hash []byte) ([]byte, error) {
+	key, ok := ks.keys[account]
+	if !ok {
+		return nil, fmt.Errorf("%w: %x", ErrUnknownAccount, account)
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+// encryptedKeyJSON is a key file of the version 3 of the web3 secret storage.
+type encryptedKeyJSON struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string          `json:"cipher"`
+	CipherText   string          `json:"ciphertext"`
+	CipherParams cipherParamJSON `json:"cipherparams"`
+	KDF          string          `json:"kdf"`
+	KDFParams    json.RawMessage `json:"kdfparams"`
+	MAC          string          `json:"mac"`
+}
+
+type cipherParamJSON struct {
+	IV string `json:"iv"`
+}
+
+type scryptParamsJSON struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+type pbkdf2ParamsJSON struct {
+	C     int    `json:"c"`
+	DKLen int    `json:"dklen"`
+	PRF   string `json:"prf"`
+	Salt  string `json:"salt"`
+}
+
+// DecryptKey decrypts a key file of the version 3 of the web3 secret storage,
+// encrypted with aes-128-ctr from a scrypt or a pbkdf2 derived key.
+func DecryptKey(keyJSON []byte, password string) (*ecdsa.PrivateKey, error) {
+	var k encryptedKeyJSON
+	if err := json.Unmarshal(keyJSON, &k); err != nil {
+		return nil, err
+	}
+	if k.Version != 3 {
+		return nil, fmt.Errorf("unsupported key version %d", k.Version)
+	}
+	if k.Crypto.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher %s", k.Crypto.Cipher)
+	}
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	var derivedKey []byte
+	switch k.Crypto.KDF {
+	case "scrypt":
+		var params scryptParamsJSON
+		if err := json.Unmarshal(k.Crypto.KDFParams, &params); err != nil {
+			return nil, err
+		}
+		salt, err := hex.DecodeString(params.Salt)
+		if err != nil {
+			return nil, err
+		}
+		if derivedKey, err = scrypt.Key([]byte(password), salt, params.N, params.R, params.P, params.DKLen); err != nil {
+			return nil, err
+		}
+	case "pbkdf2":
+		var params pbkdf2ParamsJSON
+		if err := json.Unmarshal(k.Crypto.KDFParams, &params); err != nil {
+			return nil, err
+		}
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", params.PRF)
+		}
+		salt, err := hex.DecodeString(params.Salt)
+		if err != nil {
+			return nil, err
+		}
+		derivedKey = pbkdf2.Key([]byte(password), salt, params.C, params.DKLen, sha256.New)
+	default:
+		return nil, fmt.Errorf("unsupported KDF %s", k.Crypto.KDF)
+	}
+	if len(derivedKey) < 32 {
+		return nil, fmt.Errorf("derived key too short: %d bytes", len(derivedKey))
+	}
+	if !bytes.Equal(crypto.Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrDecrypt
+	}
+
+	block, err := aes.NewCipher(derivedKey[:16])
+	if err != nil {
+		return nil, err
+	}
+	plainText := make([]byte, len(cipherText))
+	cipher.NewCTR(block, iv).XORKeyStream(plainText, cipherText)
+	key, err := crypto.ToECDSA(plainText)
+	if err != nil {
+		return nil, err
+	}
+	if k.Address != "" && !strings.EqualFold(strings.TrimPrefix(k.Address, "0x"), hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes())) {
+		return nil, fmt.Errorf("key address mismatch: %s", k.Address)
+	}
+	return key, nil
+}
@@ -0,0 +1,124 @@
+package signer
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+)
+
+const testKeyPassword = "testpassword"
+
+// encryptTestKey encrypts key in a version 3 key file, with cheap KDF
+// parameters.
+func encryptTestKey(t *testing.T, key *ecdsa.PrivateKey, password, kdf string) []byte {
+	salt, iv := make([]byte, 32), make([]byte, aes.BlockSize)
+	_, err := rand.Read(salt)
+	require.NoError(t, err)
+	_, err = rand.Read(iv)
+	require.NoError(t, err)
+
+	var derivedKey []byte
+	var params any
+	switch kdf {
+	case "scrypt":
+		derivedKey, err = scrypt.Key([]byte(password), salt, 1<<10, 8, 1, 32)
+		require.NoError(t, err)
+		params = scryptParamsJSON{N: 1 << 10, R: 8, P: 1, DKLen: 32, Salt: hex.EncodeToString(salt)}
+	case "pbkdf2":
+		derivedKey = pbkdf2.Key([]byte(password), salt, 1<<10, 32, sha256.New)
+		params = pbkdf2ParamsJSON{C: 1 << 10, DKLen: 32, PRF: "hmac-sha256", Salt: hex.EncodeToString(salt)}
+	}
+	block, err := aes.NewCipher(derivedKey[:16])
+	require.NoError(t, err)
+	plainText := crypto.FromECDSA(key)
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherText, plainText)
+	kdfParams, err := json.Marshal(params)
+	require.NoError(t, err)
+
+	keyJSON, err := json.Marshal(encryptedKeyJSON{
+		Address: hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()),
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParamJSON{IV: hex.EncodeToString(iv)},
+			KDF:          kdf,
+			KDFParams:    kdfParams,
+			MAC:          hex.EncodeToString(crypto.Keccak256(derivedKey[16:32], cipherText)),
+		},
+		Version: 3,
+	})
+	require.NoError(t, err)
+	return keyJSON
+}
+
+func TestDecryptKey(t *testing.T) {
+	expected, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	for _, kdf := range []string{"scrypt", "pbkdf2"} {
+		keyJSON := encryptTestKey(t, expected, testKeyPassword, kdf)
+		key, err := DecryptKey(keyJSON, testKeyPassword)
+		require.NoError(t, err)
+		require.Equal(t, expected.D, key.D)
+
+		_, err = DecryptKey(keyJSON, "wrong")
+		require.ErrorIs(t, err, ErrDecrypt)
+	}
+}
+
+func TestKeystore(t *testing.T) {
+	expected, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "key"), encryptTestKey(t, expected, testKeyPassword, "scrypt"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), []byte("ignored"), 0600))
+
+	_, err = NewKeystore(dir, []string{"wrong"})
+	require.ErrorIs(t, err, ErrDecrypt)
+	ks, err := NewKeystore(dir, []string{"wrong", testKeyPassword})
+	require.NoError(t, err)
+
+	account := crypto.PubkeyToAddress(expected.PublicKey)
+	accounts, err := ks.Accounts(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []libcommon.Address{account}, accounts)
+
+	sig, err := ks.SignText(context.Background(), account, []byte("hello"))
+	require.NoError(t, err)
+	require.Contains(t, []byte{27, 28}, sig[crypto.RecoveryIDOffset])
+	sig[crypto.RecoveryIDOffset] -= 27
+	pub, err := crypto.SigToPub(TextHash([]byte("hello")), sig)
+	require.NoError(t, err)
+	require.Equal(t, account, crypto.PubkeyToAddress(*pub))
+
+	_, err = ks.SignText(context.Background(), libcommon.Address{1}, []byte("hello"))
+	require.ErrorIs(t, err, ErrUnknownAccount)
+
+	chainID := uint256.NewInt(1337)
+	txn := types.NewTransaction(0, libcommon.Address{1}, uint256.NewInt(1), 21_000, uint256.NewInt(1), nil)
+	signed, err := ks.SignTx(context.Background(), account, txn, chainID)
+	require.NoError(t, err)
+	require.True(t, signed.Protected())
+	sender, err := signed.Sender(*types.LatestSignerForChainID(big.NewInt(1337)))
+	require.NoError(t, err)
+	require.Equal(t, account, sender)
+}
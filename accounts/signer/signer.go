@@ -0,0 +1,78 @@
+// Package signer signs messages and transactions on behalf of the accounts of
+// a local keystore, or of an external signer, for the eth_sign* and
+// eth_sendTransaction RPC methods.
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+)
+
+var ErrUnknownAccount = errors.New("unknown account")
+
+// Signer signs on behalf of the accounts it manages. The signatures are in the
+// [R || S || V] format, with V 27 or 28.
+type Signer interface {
+	// Accounts returns the accounts managed by the signer.
+	Accounts(ctx context.Context) ([]libcommon.Address, error)
+	// SignText signs the EIP-191 personal message hash of text.
+	SignText(ctx context.Context, account libcommon.Address, text []byte) ([]byte, error)
+	// SignTypedData signs the EIP-712 hash of data.
+	SignTypedData(ctx context.Context, account libcommon.Address, data *TypedData) ([]byte, error)
+	// SignTx signs the transaction for the given chain.
+	SignTx(ctx context.Context, account libcommon.Address, txn types.Transaction, chainID *uint256.Int) (types.Transaction, error)
+}
+
+// New returns the signer of the given configuration: a local keystore of the
+// keys of keystoreDir, decrypted by the passwords of passwordFile, or an
+// external signer at the given endpoint. It returns nil if neither is set.
+func New(ctx context.Context, keystoreDir, passwordFile, external string, logger log.Logger) (Signer, error) {
+	switch {
+	case keystoreDir != "" && external != "":
+		return nil, errors.New("the keystore and the external signer are exclusive")
+	case keystoreDir != "":
+		var passwords []string
+		if passwordFile != "" {
+			content, err := os.ReadFile(passwordFile)
+			if err != nil {
+				return nil, fmt.Errorf("read password file: %w", err)
+			}
+			passwords = strings.Split(strings.TrimRight(string(content), "\r\n"), "\n")
+			for i := range passwords {
+				passwords[i] = strings.TrimRight(passwords[i], "\r")
+			}
+		}
+		ks, err := NewKeystore(keystoreDir, passwords)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("[signer] Loaded keystore", "dir", keystoreDir, "accounts", len(ks.addresses))
+		return ks, nil
+	case external != "":
+		ext, err := NewExternal(ctx, external, logger)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("[signer] Using external signer", "endpoint", external)
+		return ext, nil
+	default:
+		return nil, nil
+	}
+}
+
+// TextHash returns the EIP-191 hash of a personal message:
+// keccak256("\x19Ethereum Signed Message:\n" + len(message) + message).
+func TextHash(message []byte) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))), message)
+}
@@ -0,0 +1,293 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/crypto"
+)
+
+// TypedData is the structured data of EIP-712, as passed to
+// eth_signTypedData_v4.
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]any              `json:"domain"`
+	Message     map[string]any              `json:"message"`
+}
+
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+const typedDataDomain = "EIP712Domain"
+
+var (
+	typedDataArray   = regexp.MustCompile(`^(.+)\[(\d*)\]$`)
+	typedDataInteger = regexp.MustCompile(`^(u?)int(\d*)$`)
+	typedDataBytes   = regexp.MustCompile(`^bytes(\d+)$`)
+)
+
+// UnmarshalJSON keeps the numbers of the domain and of the message exact.
+func (td *TypedData) UnmarshalJSON(input []byte) error {
+	type typedData TypedData
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	return decoder.Decode((*typedData)(td))
+}
+
+// Hash returns the EIP-712 hash of the typed data:
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)).
+func (td *TypedData) Hash() (libcommon.Hash, error) {
+	if _, ok := td.Types[typedDataDomain]; !ok {
+		return libcommon.Hash{}, errors.New("missing EIP712Domain type")
+	}
+	domainSeparator, err := td.HashStruct(typedDataDomain, td.Domain)
+	if err != nil {
+		return libcommon.Hash{}, fmt.Errorf("domain: %w", err)
+	}
+	data := append([]byte{0x19, 0x01}, domainSeparator[:]...)
+	if td.PrimaryType != typedDataDomain {
+		message, err := td.HashStruct(td.PrimaryType, td.Message)
+		if err != nil {
+			return libcommon.Hash{}, fmt.Errorf("message: %w", err)
+		}
+		data = append(data, message[:]...)
+	}
+	return crypto.Keccak256Hash(data), nil
+}
+
+// HashStruct returns keccak256(typeHash ‖ encodeData(data)) for the struct of
+// the given type.
+func (td *TypedData) HashStruct(primaryType string, data map[string]any) (libcommon.Hash, error) {
+	encoded, err := td.encodeData(primaryType, data, 0)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// EncodeType returns the encoding of the given struct type, followed by the
+// ones of the struct types it references, sorted by name.
+func (td *TypedData) EncodeType(primaryType string) string {
+	deps := td.dependencies(primaryType, nil)
+	slices.Sort(deps[1:])
+	var buf strings.Builder
+	for _, dep := range deps {
+		buf.WriteString(dep)
+		buf.WriteByte('(')
+		for i, field := range td.Types[dep] {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(field.Type)
+			buf.WriteByte(' ')
+			buf.WriteString(field.Name)
+		}
+		buf.WriteByte(')')
+	}
+	return buf.String()
+}
+
+func (td *TypedData) dependencies(typ string, found []string) []string {
+	typ = baseType(typ)
+	if slices.Contains(found, typ) {
+		return found
+	}
+	if _, ok := td.Types[typ]; !ok {
+		return found
+	}
+	found = append(found, typ)
+	for _, field := range td.Types[typ] {
+		found = td.dependencies(field.Type, found)
+	}
+	return found
+}
+
+func baseType(typ string) string {
+	for {
+		match := typedDataArray.FindStringSubmatch(typ)
+		if match == nil {
+			return typ
+		}
+		typ = match[1]
+	}
+}
+
+func (td *TypedData) encodeData(primaryType string, data map[string]any, depth int) ([]byte, error) {
+	fields, ok := td.Types[primaryType]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", primaryType)
+	}
+	if depth > 64 {
+		return nil, errors.New("too deeply nested data")
+	}
+	if len(data) > len(fields) {
+		return nil, fmt.Errorf("extra data in %s", primaryType)
+	}
+	typeHash := crypto.Keccak256([]byte(td.EncodeType(primaryType)))
+	buf := bytes.NewBuffer(typeHash)
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing value of %s.%s", primaryType, field.Name)
+		}
+		encoded, err := td.encodeValue(field.Type, value, depth)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", primaryType, field.Name, err)
+		}
+		buf.Write(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeValue returns the 32 bytes encoding of a value of the given type.
+func (td *TypedData) encodeValue(typ string, value any, depth int) ([]byte, error) {
+	if match := typedDataArray.FindStringSubmatch(typ); match != nil {
+		items, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an array, got %T", value)
+		}
+		if match[2] != "" {
+			if length, err := strconv.Atoi(match[2]); err != nil || length != len(items) {
+				return nil, fmt.Errorf("expected %s items, got %d", match[2], len(items))
+			}
+		}
+		var buf bytes.Buffer
+		for _, item := range items {
+			encoded, err := td.encodeValue(match[1], item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(encoded)
+		}
+		return crypto.Keccak256(buf.Bytes()), nil
+	}
+	if _, ok := td.Types[typ]; ok {
+		data, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %T", value)
+		}
+		encoded, err := td.encodeData(typ, data, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(encoded), nil
+	}
+
+	word := make([]byte, 32)
+	switch typ {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %T", value)
+		}
+		return crypto.Keccak256([]byte(s)), nil
+	case "bytes":
+		b, err := typedDataBytesValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a bool, got %T", value)
+		}
+		if b {
+			word[31] = 1
+		}
+		return word, nil
+	case "address":
+		s, ok := value.(string)
+		if !ok || !libcommon.IsHexAddress(s) {
+			return nil, fmt.Errorf("invalid address %v", value)
+		}
+		copy(word[12:], libcommon.HexToAddress(s).Bytes())
+		return word, nil
+	}
+	if match := typedDataBytes.FindStringSubmatch(typ); match != nil {
+		size, _ := strconv.Atoi(match[1])
+		b, err := typedDataBytesValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if size < 1 || size > 32 || len(b) > size {
+			return nil, fmt.Errorf("invalid %s value of %d bytes", typ, len(b))
+		}
+		copy(word, b)
+		return word, nil
+	}
+	if match := typedDataInteger.FindStringSubmatch(typ); match != nil {
+		bits := 256
+		if match[2] != "" {
+			bits, _ = strconv.Atoi(match[2])
+		}
+		if bits < 8 || bits > 256 || bits%8 != 0 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		n, err := typedDataIntegerValue(value)
+		if err != nil {
+			return nil, err
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		lower := new(big.Int)
+		if match[1] == "" {
+			limit.Rsh(limit, 1)
+			lower.Neg(limit)
+		}
+		if n.Cmp(lower) < 0 || n.Cmp(limit) >= 0 {
+			return nil, fmt.Errorf("%s out of range for %s", n, typ)
+		}
+		if n.Sign() < 0 {
+			// two's complement
+			n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return n.FillBytes(word), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+func typedDataBytesValue(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return hexutility.FromHex(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("expected hex bytes, got %T", value)
+	}
+}
+
+func typedDataIntegerValue(value any) (*big.Int, error) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		return big.NewInt(int64(v)), nil
+	default:
+		return nil, fmt.Errorf("expected an integer, got %T", value)
+	}
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,79 @@
+package signer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+)
+
+// the example of EIP-712
+const typedDataMail = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	var td TypedData
+	require.NoError(t, json.Unmarshal([]byte(typedDataMail), &td))
+
+	require.Equal(t, "Mail(Person from,Person to,string contents)Person(string name,address wallet)", td.EncodeType("Mail"))
+	domainSeparator, err := td.HashStruct("EIP712Domain", td.Domain)
+	require.NoError(t, err)
+	require.Equal(t, libcommon.HexToHash("0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"), domainSeparator)
+	message, err := td.HashStruct("Mail", td.Message)
+	require.NoError(t, err)
+	require.Equal(t, libcommon.HexToHash("0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"), message)
+	hash, err := td.Hash()
+	require.NoError(t, err)
+	require.Equal(t, libcommon.HexToHash("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"), hash)
+
+	td.Message["extra"] = "field"
+	_, err = td.Hash()
+	require.Error(t, err)
+	delete(td.Message, "extra")
+	delete(td.Message, "contents")
+	_, err = td.Hash()
+	require.Error(t, err)
+}
+
+func TestTypedDataIntegers(t *testing.T) {
+	td := TypedData{Types: map[string][]TypedDataField{"T": {{Name: "v", Type: "int8"}}}}
+	for _, v := range []any{json.Number("-128"), json.Number("127"), "0x7f", float64(-1)} {
+		_, err := td.HashStruct("T", map[string]any{"v": v})
+		require.NoError(t, err, v)
+	}
+	for _, v := range []any{json.Number("-129"), json.Number("128"), float64(1.5), true} {
+		_, err := td.HashStruct("T", map[string]any{"v": v})
+		require.Error(t, err, v)
+	}
+}
@@ -38,6 +38,7 @@ type Antiquary struct {
 	blobBackfilled        *atomic.Bool
 	cfg                   *clparams.BeaconChainConfig
 	states, blocks, blobs bool
+	compactBlobs          bool // compact the blob sidecars into segment files outside of tests
 	validatorsTable       *state_accessors.StaticValidatorTable
 	genesisState          *state.CachingBeaconState
 	// set to nil
@@ -167,11 +168,14 @@ func (a *Antiquary) Loop() error {
 	if a.states {
 		go a.loopStates(a.ctx)
 	}
+	if a.blobs && a.compactBlobs {
+		go a.loopBlobs(a.ctx)
+	}
 	if !IsTest {
 		return nil
 	}
 
-	if a.blobs {
+	if a.blobs && !a.compactBlobs {
 		go a.loopBlobs(a.ctx)
 	}
 
@@ -282,6 +286,12 @@ func (a *Antiquary) NotifyBlobBackfilled() {
 	a.blobBackfilled.Store(true)
 }
 
+// EnableBlobCompaction compacts the finalized blob sidecars into segment files,
+// for the nodes which archive all of them for ever. It must be called before Loop.
+func (a *Antiquary) EnableBlobCompaction() {
+	a.compactBlobs = true
+}
+
 func (a *Antiquary) loopBlobs(ctx context.Context) {
 	if a.cfg.DenebForkEpoch == math.MaxUint64 {
 		return
@@ -35,6 +35,13 @@ type CaplinConfig struct {
 	BlobBackfilling     bool
 	BlobPruningDisabled bool
 	Archive             bool
+	// BlobArchiveSlots keeps the blob sidecars of this many slots back from the current
+	// one, when longer than the pruning distance, math.MaxUint64 for ever. 0 disables
+	// the blob archive.
+	BlobArchiveSlots uint64
+	// BlobArchiveInboxes restricts the blob archive to the blob transactions sent to
+	// these addresses, the batch inboxes of rollups.
+	BlobArchiveInboxes []libcommon.Address
 }
 
 type NetworkType int
@@ -0,0 +1,173 @@
+package blob_storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	gokzg4844 "github.com/crate-crypto/go-kzg-4844"
+	"github.com/spf13/afero"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/crypto/kzg"
+
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/core/types"
+)
+
+// archiveFolder holds the sidecars kept past the pruning distance, with the
+// same layout as the blob store.
+const archiveFolder = "archive"
+
+// ArchivePolicy tells which blob sidecars are kept past the pruning distance,
+// for the rollup nodes which derive their chain from old L1 blocks.
+type ArchivePolicy struct {
+	// Slots is the number of slots back from the current one for which the sidecars
+	// are kept, when longer than the pruning distance, math.MaxUint64 to keep them
+	// for ever. The archive is disabled when 0.
+	Slots uint64
+	// Inboxes restricts the archive to the sidecars of the blob transactions sent to
+	// these addresses, i.e. the batch inboxes of the rollups. All sidecars are archived when empty.
+	Inboxes []libcommon.Address
+}
+
+func (p ArchivePolicy) Enabled() bool { return p.Slots > 0 }
+
+// ArchivesAll tells whether every sidecar is kept for ever.
+func (p ArchivePolicy) ArchivesAll() bool { return p.Slots == math.MaxUint64 && len(p.Inboxes) == 0 }
+
+// ArchiveSelector returns the indices of the sidecars of a block which are archived.
+type ArchiveSelector func(ctx context.Context, blockRoot libcommon.Hash) ([]uint64, error)
+
+// InboxSelector selects the sidecars of the blob transactions sent to inboxes.
+// readBlock returns the full block of a root, or nil when it's unknown.
+func InboxSelector(inboxes []libcommon.Address, readBlock func(ctx context.Context, blockRoot libcommon.Hash) (*cltypes.SignedBeaconBlock, error)) ArchiveSelector {
+	targets := make(map[libcommon.Address]struct{}, len(inboxes))
+	for _, inbox := range inboxes {
+		targets[inbox] = struct{}{}
+	}
+	return func(ctx context.Context, blockRoot libcommon.Hash) ([]uint64, error) {
+		block, err := readBlock(ctx, blockRoot)
+		if err != nil || block == nil {
+			return nil, err
+		}
+		return SidecarIndicesSentTo(block, targets)
+	}
+}
+
+// SidecarIndicesSentTo returns the indices of the sidecars of block whose blob
+// transaction is sent to one of targets.
+func SidecarIndicesSentTo(block *cltypes.SignedBeaconBlock, targets map[libcommon.Address]struct{}) ([]uint64, error) {
+	body := block.Block.Body
+	if body.ExecutionPayload == nil || body.ExecutionPayload.Transactions == nil || body.BlobKzgCommitments == nil || body.BlobKzgCommitments.Len() == 0 {
+		return nil, nil
+	}
+	hashes := map[libcommon.Hash]struct{}{}
+	var decodeErr error
+	body.ExecutionPayload.Transactions.ForEach(func(encoded []byte, idx, total int) bool {
+		if len(encoded) == 0 || encoded[0] != types.BlobTxType {
+			return true
+		}
+		txn, err := types.DecodeTransaction(encoded)
+		if err != nil {
+			decodeErr = fmt.Errorf("transaction %d: %w", idx, err)
+			return false
+		}
+		if to := txn.GetTo(); to != nil {
+			if _, ok := targets[*to]; ok {
+				for _, h := range txn.GetBlobHashes() {
+					hashes[h] = struct{}{}
+				}
+			}
+		}
+		return true
+	})
+	if decodeErr != nil || len(hashes) == 0 {
+		return nil, decodeErr
+	}
+	var indices []uint64
+	body.BlobKzgCommitments.Range(func(i int, c *cltypes.KZGCommitment, _ int) bool {
+		if _, ok := hashes[libcommon.Hash(kzg.KZGToVersionedHash(gokzg4844.KZGCommitment(*c)))]; ok {
+			indices = append(indices, uint64(i))
+		}
+		return true
+	})
+	return indices, nil
+}
+
+// archiveSidecars moves the selected sidecars of a folder of the store into the archive.
+func (bs *BlobStore) archiveSidecars(ctx context.Context, folder string) error {
+	entries, err := afero.ReadDir(bs.fs, folder)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	selected := map[libcommon.Hash]map[uint64]struct{}{}
+	for _, entry := range entries {
+		root, idx, ok := parseSidecarFileName(entry.Name())
+		if !ok {
+			continue
+		}
+		indices, seen := selected[root]
+		if !seen {
+			list, err := bs.archiveSelector(ctx, root)
+			if err != nil {
+				return fmt.Errorf("selecting the archived sidecars of %x: %w", root, err)
+			}
+			indices = make(map[uint64]struct{}, len(list))
+			for _, i := range list {
+				indices[i] = struct{}{}
+			}
+			selected[root] = indices
+		}
+		if _, ok := indices[idx]; !ok {
+			continue
+		}
+		if err := bs.fs.MkdirAll(path.Join(archiveFolder, folder), 0755); err != nil {
+			return err
+		}
+		if err := bs.fs.Rename(path.Join(folder, entry.Name()), path.Join(archiveFolder, folder, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSidecarFileName parses the `<blockRoot>_<index>` name of a sidecar file.
+func parseSidecarFileName(name string) (libcommon.Hash, uint64, bool) {
+	root, index, ok := strings.Cut(name, "_")
+	if !ok || len(root) != 2+2*length.Hash {
+		return libcommon.Hash{}, 0, false
+	}
+	idx, err := strconv.ParseUint(index, 10, 64)
+	if err != nil {
+		return libcommon.Hash{}, 0, false
+	}
+	return libcommon.HexToHash(root), idx, true
+}
+
+// prunableFolders returns the folders of the sidecars which are more than
+// slotsKept slots old, up to 1M slots back.
+func prunableFolders(currentSlot, slotsKept uint64) []string {
+	if slotsKept == math.MaxUint64 || currentSlot < slotsKept {
+		return nil
+	}
+	end := ((currentSlot - slotsKept) / subdivisionSlot) * subdivisionSlot
+	var start uint64
+	if end >= 1_000_000 {
+		start = end - 1_000_000
+	}
+	folders := make([]string, 0, (end-start)/subdivisionSlot)
+	for i := start; i < end; i += subdivisionSlot {
+		folders = append(folders, strconv.FormatUint(i/subdivisionSlot, 10))
+	}
+	return folders
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"path"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -32,6 +33,13 @@ type BlobStorage interface {
 	WriteStream(w io.Writer, slot uint64, blockRoot libcommon.Hash, idx uint64) error // Used for P2P networking
 	KzgCommitmentsCount(ctx context.Context, blockRoot libcommon.Hash) (uint32, error)
 	Prune() error
+	// SetArchivePolicy sets the sidecars kept past the pruning distance. selector is
+	// required when the policy only archives the sidecars sent to some inboxes.
+	SetArchivePolicy(policy ArchivePolicy, selector ArchiveSelector)
+	ArchivePolicy() ArchivePolicy
+	// SlotsKept returns the number of slots back from the current one for which
+	// sidecars are kept: the pruning distance, or the archive window if longer.
+	SlotsKept() uint64
 }
 
 type BlobStore struct {
@@ -40,6 +48,8 @@ type BlobStore struct {
 	beaconChainConfig *clparams.BeaconChainConfig
 	ethClock          eth_clock.EthereumClock
 	slotsKept         uint64
+	archive           ArchivePolicy
+	archiveSelector   ArchiveSelector
 }
 
 func NewBlobStore(db kv.RwDB, fs afero.Fs, slotsKept uint64, beaconChainConfig *clparams.BeaconChainConfig, ethClock eth_clock.EthereumClock) BlobStorage {
@@ -113,17 +123,22 @@ func (bs *BlobStore) ReadBlobSidecars(ctx context.Context, slot uint64, blockRoo
 	}
 	kzgCommitmentsLength := binary.LittleEndian.Uint32(val)
 
-	var blobSidecars []*cltypes.BlobSidecar
+	var (
+		blobSidecars []*cltypes.BlobSidecar
+		missing      int
+		fromArchive  bool // only some of the sidecars of a block are archived
+	)
 	for i := uint32(0); i < kzgCommitmentsLength; i++ {
-		_, filePath := blobSidecarFilePath(slot, uint64(i), blockRoot)
-		file, err := bs.fs.Open(filePath)
+		file, archived, err := bs.openSidecar(slot, uint64(i), blockRoot)
 		if err != nil {
 			if errors.Is(err, afero.ErrFileNotFound) {
-				return nil, false, nil
+				missing++
+				continue
 			}
 			return nil, false, err
 		}
 		defer file.Close()
+		fromArchive = fromArchive || archived
 
 		blobSidecar := &cltypes.BlobSidecar{}
 		if err := ssz_snappy.DecodeAndReadNoForkDigest(file, blobSidecar, clparams.DenebVersion); err != nil {
@@ -131,32 +146,71 @@ func (bs *BlobStore) ReadBlobSidecars(ctx context.Context, slot uint64, blockRoo
 		}
 		blobSidecars = append(blobSidecars, blobSidecar)
 	}
+	if missing > 0 && !fromArchive {
+		return nil, false, nil
+	}
 	return blobSidecars, true, nil
 }
 
-// Do a bit of pruning
+// openSidecar opens a sidecar file of the store, or of the archive once it's
+// pruned from the store, and tells whether it comes from the archive.
+func (bs *BlobStore) openSidecar(slot, index uint64, blockRoot libcommon.Hash) (afero.File, bool, error) {
+	_, filePath := blobSidecarFilePath(slot, index, blockRoot)
+	file, err := bs.fs.Open(filePath)
+	if err != nil && errors.Is(err, afero.ErrFileNotFound) && bs.archive.Enabled() {
+		file, err = bs.fs.Open(path.Join(archiveFolder, filePath))
+		return file, err == nil, err
+	}
+	return file, false, err
+}
+
+func (bs *BlobStore) SetArchivePolicy(policy ArchivePolicy, selector ArchiveSelector) {
+	bs.archive, bs.archiveSelector = policy, selector
+}
+
+func (bs *BlobStore) ArchivePolicy() ArchivePolicy { return bs.archive }
+
+func (bs *BlobStore) SlotsKept() uint64 {
+	if bs.archive.Enabled() {
+		return max(bs.slotsKept, bs.archive.Slots)
+	}
+	return bs.slotsKept
+}
+
+// Do a bit of pruning. The sidecars archived for all inboxes are kept in the
+// store for the archive window, the ones of some inboxes are moved to the archive
+// past the pruning distance, until the end of the window.
 func (bs *BlobStore) Prune() error {
-	if bs.slotsKept == math.MaxUint64 {
+	window := bs.SlotsKept()
+	slotsKept := bs.slotsKept
+	archiving := window > slotsKept && len(bs.archive.Inboxes) > 0 && bs.archiveSelector != nil
+	if len(bs.archive.Inboxes) == 0 {
+		slotsKept = window
+	}
+	if slotsKept == math.MaxUint64 {
 		return nil
 	}
-
+	ctx := context.Background()
 	currentSlot := bs.ethClock.GetCurrentSlot()
-	currentSlot -= bs.slotsKept
-	currentSlot = (currentSlot / subdivisionSlot) * subdivisionSlot
-	var startPrune uint64
-	if currentSlot >= 1_000_000 {
-		startPrune = currentSlot - 1_000_000
-	}
 	// delete all the folders that are older than slotsKept
-	for i := startPrune; i < currentSlot; i += subdivisionSlot {
-		bs.fs.RemoveAll(strconv.FormatUint(i, 10))
+	for _, folder := range prunableFolders(currentSlot, slotsKept) {
+		if archiving {
+			if err := bs.archiveSidecars(ctx, folder); err != nil {
+				return err
+			}
+		}
+		bs.fs.RemoveAll(folder)
+	}
+	if archiving {
+		for _, folder := range prunableFolders(currentSlot, window) {
+			bs.fs.RemoveAll(path.Join(archiveFolder, folder))
+		}
 	}
 	return nil
 }
 
 func (bs *BlobStore) WriteStream(w io.Writer, slot uint64, blockRoot libcommon.Hash, idx uint64) error {
-	_, filePath := blobSidecarFilePath(slot, idx, blockRoot)
-	file, err := bs.fs.Open(filePath)
+	file, _, err := bs.openSidecar(slot, idx, blockRoot)
 	if err != nil {
 		return err
 	}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"testing"
 
+	gokzg4844 "github.com/crate-crypto/go-kzg-4844"
 	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/crypto/kzg"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/memdb"
 	"github.com/erigontech/erigon/cl/clparams"
 	"github.com/erigontech/erigon/cl/cltypes"
 	"github.com/erigontech/erigon/cl/cltypes/solid"
+	"github.com/erigontech/erigon/cl/utils/eth_clock"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/holiman/uint256"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 func setupTestDB(t *testing.T) kv.RwDB {
@@ -50,3 +56,140 @@ func TestBlobDB(t *testing.T) {
 	require.Equal(t, s1.SignedBlockHeader, sidecars[0].SignedBlockHeader)
 	require.Equal(t, s2.SignedBlockHeader, sidecars[1].SignedBlockHeader)
 }
+
+func TestBlobArchive(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	clock := eth_clock.NewMockEthereumClock(ctrl)
+	currentSlot := uint64(50_000)
+	clock.EXPECT().GetCurrentSlot().DoAndReturn(func() uint64 { return currentSlot }).AnyTimes()
+
+	sidecar := func(index, slot uint64) *cltypes.BlobSidecar {
+		return cltypes.NewBlobSidecar(index, &cltypes.Blob{byte(index)}, libcommon.Bytes48{2}, libcommon.Bytes48{3}, &cltypes.SignedBeaconBlockHeader{Header: &cltypes.BeaconBlockHeader{Slot: slot}}, solid.NewHashVector(cltypes.CommitmentBranchSize))
+	}
+	inboxRoot, otherRoot, recentRoot := libcommon.Hash{1}, libcommon.Hash{2}, libcommon.Hash{3}
+
+	bs := NewBlobStore(db, afero.NewMemMapFs(), 12, &clparams.MainnetBeaconConfig, clock)
+	bs.SetArchivePolicy(ArchivePolicy{Slots: 45_000, Inboxes: []libcommon.Address{{1}}}, func(_ context.Context, blockRoot libcommon.Hash) ([]uint64, error) {
+		if blockRoot == inboxRoot {
+			return []uint64{1}, nil
+		}
+		return nil, nil
+	})
+	require.NoError(t, bs.WriteBlobSidecars(ctx, inboxRoot, []*cltypes.BlobSidecar{sidecar(0, 1), sidecar(1, 1)}))
+	require.NoError(t, bs.WriteBlobSidecars(ctx, otherRoot, []*cltypes.BlobSidecar{sidecar(0, 1)}))
+	require.NoError(t, bs.WriteBlobSidecars(ctx, recentRoot, []*cltypes.BlobSidecar{sidecar(0, 49_999)}))
+
+	require.NoError(t, bs.Prune())
+
+	// only the sidecar sent to the inbox is left
+	sidecars, found, err := bs.ReadBlobSidecars(ctx, 1, inboxRoot)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, sidecars, 1)
+	require.Equal(t, uint64(1), sidecars[0].Index)
+
+	_, found, err = bs.ReadBlobSidecars(ctx, 1, otherRoot)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	sidecars, found, err = bs.ReadBlobSidecars(ctx, 49_999, recentRoot)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, sidecars, 1)
+
+	// the archive window is over
+	currentSlot = 70_000
+	require.NoError(t, bs.Prune())
+	_, found, err = bs.ReadBlobSidecars(ctx, 1, inboxRoot)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+// The archive window counts back from the current slot, like the pruning
+// distance, which applies when longer.
+func TestBlobArchiveWindow(t *testing.T) {
+	ctx := context.Background()
+	sidecar := func(slot uint64) *cltypes.BlobSidecar {
+		return cltypes.NewBlobSidecar(0, &cltypes.Blob{1}, libcommon.Bytes48{2}, libcommon.Bytes48{3}, &cltypes.SignedBeaconBlockHeader{Header: &cltypes.BeaconBlockHeader{Slot: slot}}, solid.NewHashVector(cltypes.CommitmentBranchSize))
+	}
+	const slotsKept, currentSlot = 20_000, 100_000
+
+	for _, tt := range []struct {
+		name      string
+		window    uint64
+		slotsKept uint64
+		kept      []uint64 // slots of the sidecars left
+		pruned    []uint64
+	}{
+		{"shorter than the pruning distance", 5_000, slotsKept, []uint64{85_000}, []uint64{65_000, 45_000}},
+		{"longer than the pruning distance", 45_000, 45_000, []uint64{85_000, 65_000}, []uint64{45_000}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+			clock := eth_clock.NewMockEthereumClock(gomock.NewController(t))
+			clock.EXPECT().GetCurrentSlot().Return(uint64(currentSlot)).AnyTimes()
+			bs := NewBlobStore(db, afero.NewMemMapFs(), slotsKept, &clparams.MainnetBeaconConfig, clock)
+			bs.SetArchivePolicy(ArchivePolicy{Slots: tt.window}, nil)
+			require.Equal(t, tt.slotsKept, bs.SlotsKept())
+
+			for _, slot := range append(append([]uint64{}, tt.kept...), tt.pruned...) {
+				require.NoError(t, bs.WriteBlobSidecars(ctx, libcommon.Hash{byte(slot / 10_000)}, []*cltypes.BlobSidecar{sidecar(slot)}))
+			}
+			require.NoError(t, bs.Prune())
+			for _, slot := range tt.kept {
+				_, found, err := bs.ReadBlobSidecars(ctx, slot, libcommon.Hash{byte(slot / 10_000)})
+				require.NoError(t, err)
+				require.True(t, found, slot)
+			}
+			for _, slot := range tt.pruned {
+				_, found, err := bs.ReadBlobSidecars(ctx, slot, libcommon.Hash{byte(slot / 10_000)})
+				require.NoError(t, err)
+				require.False(t, found, slot)
+			}
+		})
+	}
+}
+
+func TestSidecarIndicesSentTo(t *testing.T) {
+	inbox, other := libcommon.Address{1}, libcommon.Address{2}
+	commitments := []cltypes.KZGCommitment{{1}, {2}, {3}}
+	versionedHash := func(c cltypes.KZGCommitment) libcommon.Hash {
+		return libcommon.Hash(kzg.KZGToVersionedHash(gokzg4844.KZGCommitment(c)))
+	}
+	blobTx := func(to libcommon.Address, commitments ...cltypes.KZGCommitment) types.Transaction {
+		txn := &types.BlobTx{DynamicFeeTransaction: types.DynamicFeeTransaction{
+			CommonTx: types.CommonTx{To: &to, Value: uint256.NewInt(0)},
+			ChainID:  uint256.NewInt(1),
+			Tip:      uint256.NewInt(1),
+			FeeCap:   uint256.NewInt(1),
+		}, MaxFeePerBlobGas: uint256.NewInt(1)}
+		for _, c := range commitments {
+			txn.BlobVersionedHashes = append(txn.BlobVersionedHashes, versionedHash(c))
+		}
+		return txn
+	}
+	txs, err := types.MarshalTransactionsBinary(types.Transactions{
+		blobTx(other, commitments[0]),
+		blobTx(inbox, commitments[1], commitments[2]),
+	})
+	require.NoError(t, err)
+
+	block := cltypes.NewSignedBeaconBlock(&clparams.MainnetBeaconConfig)
+	block.Block.Body.ExecutionPayload.Transactions = solid.NewTransactionsSSZFromTransactions(txs)
+	for i := range commitments {
+		block.Block.Body.BlobKzgCommitments.Append(&commitments[i])
+	}
+
+	indices, err := SidecarIndicesSentTo(block, map[libcommon.Address]struct{}{inbox: {}})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2}, indices)
+
+	indices, err = SidecarIndicesSentTo(block, map[libcommon.Address]struct{}{{3}: {}})
+	require.NoError(t, err)
+	require.Empty(t, indices)
+}
@@ -263,6 +263,10 @@ func downloadBlobHistoryWorker(cfg StageHistoryReconstructionCfg, ctx context.Co
 	prevLogSlot := currentSlot
 	prevTime := time.Now()
 	targetSlot := cfg.beaconCfg.DenebForkEpoch * cfg.beaconCfg.SlotsPerEpoch
+	// an archive window doesn't need the sidecars which are older than it
+	if window := cfg.blobStorage.SlotsKept(); cfg.blobStorage.ArchivePolicy().Enabled() && currentSlot > window {
+		targetSlot = max(targetSlot, currentSlot-window)
+	}
 
 	for currentSlot >= targetSlot {
 		if currentSlot <= cfg.sn.FrozenBlobs() {
@@ -51,6 +51,7 @@ import (
 	"github.com/Giulio2002/bls"
 	"github.com/erigontech/erigon-lib/log/v3"
 
+	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/mdbx"
@@ -115,6 +116,19 @@ func RunCaplinPhase1(ctx context.Context, engine execution_client.ExecutionEngin
 	csn := freezeblocks.NewCaplinSnapshots(ethconfig.BlocksFreezing{}, beaconConfig, dirs, logger)
 	rcsn := freezeblocks.NewBeaconSnapshotReader(csn, eth1Getter, beaconConfig)
 
+	blobArchive := blob_storage.ArchivePolicy{Slots: config.CaplinConfig.BlobArchiveSlots, Inboxes: config.CaplinConfig.BlobArchiveInboxes}
+	blobStorage.SetArchivePolicy(blobArchive, blob_storage.InboxSelector(blobArchive.Inboxes, func(ctx context.Context, blockRoot libcommon.Hash) (*cltypes.SignedBeaconBlock, error) {
+		tx, err := indexDB.BeginRo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		return rcsn.ReadBlockByRoot(ctx, tx, blockRoot)
+	}))
+	if blobArchive.Enabled() {
+		logger.Info("Blob sidecars archive", "slots", blobArchive.Slots, "inboxes", blobArchive.Inboxes)
+	}
+
 	pool := pool.NewOperationsPool(beaconConfig)
 	attestationProducer := attestation_producer.New(beaconConfig)
 
@@ -258,6 +272,9 @@ func RunCaplinPhase1(ctx context.Context, engine execution_client.ExecutionEngin
 		return err
 	}
 	antiq := antiquary.NewAntiquary(ctx, blobStorage, genesisState, vTables, beaconConfig, dirs, snDownloader, indexDB, csn, rcsn, logger, states, backfilling, blobBackfilling)
+	if blobArchive.ArchivesAll() {
+		antiq.EnableBlobCompaction()
+	}
 	// Create the antiquary
 	go func() {
 		if err := antiq.Loop(); err != nil {
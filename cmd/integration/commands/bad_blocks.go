@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/turbo/debug"
+	"github.com/erigontech/erigon/turbo/forensics"
+)
+
+var (
+	badBlockHash string
+	outputDir    string
+)
+
+var cmdExportBadBlock = &cobra.Command{
+	Use:   "export_bad_block",
+	Short: "Export the forensics bundle of a rejected block to a directory of t8n inputs, or list the bundles without --block.hash",
+	Example: "integration export_bad_block --datadir=<datadir> --block.hash=<hash> --output=<dir>\n" +
+		"evm t8n --input.alloc=<dir>/alloc.json --input.env=<dir>/env.json --input.txs=<dir>/txs.json --state.fork=<fork of forensics.json>",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := debug.SetupCobra(cmd, "integration")
+		ctx, _ := common.RootContext()
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return err
+		}
+		defer db.Close()
+
+		tx, err := db.BeginRo(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if badBlockHash == "" {
+			bundles, err := forensics.List(tx)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 8, 8, 1, ' ', 0)
+			defer w.Flush()
+			fmt.Fprintln(w, "number\thash\terror")
+			for _, b := range bundles {
+				fmt.Fprintf(w, "%d\t%x\t%s\n", b.BlockNumber, b.BlockHash, b.Error)
+			}
+			return nil
+		}
+
+		hash := common.HexToHash(badBlockHash)
+		bundle, err := forensics.Read(tx, hash)
+		if err != nil {
+			return err
+		}
+		if bundle == nil {
+			return fmt.Errorf("no forensics bundle for block %x", hash)
+		}
+		dir := outputDir
+		if dir == "" {
+			dir = fmt.Sprintf("bad_block_%d_%x", bundle.BlockNumber, hash)
+		}
+		if err := bundle.ExportT8n(dir); err != nil {
+			return err
+		}
+		logger.Info("Exported bad block forensics", "number", bundle.BlockNumber, "hash", hash, "dir", dir)
+		return nil
+	},
+}
+
+func init() {
+	withDataDir(cmdExportBadBlock)
+	cmdExportBadBlock.Flags().StringVar(&badBlockHash, "block.hash", "", "hash of the rejected block to export")
+	cmdExportBadBlock.Flags().StringVar(&outputDir, "output", "", "directory of the export, bad_block_<number>_<hash> by default")
+	rootCmd.AddCommand(cmdExportBadBlock)
+}
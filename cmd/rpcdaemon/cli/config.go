@@ -149,12 +149,17 @@ func RootCommand() (*cobra.Command, *httpcfg.HttpCfg) {
 	rootCmd.PersistentFlags().IntVar(&cfg.RpcFiltersConfig.RpcSubscriptionFiltersMaxTxs, "rpc.subscription.filters.maxtxs", rpchelper.DefaultFiltersConfig.RpcSubscriptionFiltersMaxTxs, "Maximum number of transactions to store per subscription.")
 	rootCmd.PersistentFlags().IntVar(&cfg.RpcFiltersConfig.RpcSubscriptionFiltersMaxAddresses, "rpc.subscription.filters.maxaddresses", rpchelper.DefaultFiltersConfig.RpcSubscriptionFiltersMaxAddresses, "Maximum number of addresses per subscription to filter logs by.")
 	rootCmd.PersistentFlags().IntVar(&cfg.RpcFiltersConfig.RpcSubscriptionFiltersMaxTopics, "rpc.subscription.filters.maxtopics", rpchelper.DefaultFiltersConfig.RpcSubscriptionFiltersMaxTopics, "Maximum number of topics per subscription to filter logs by.")
+	rootCmd.PersistentFlags().StringVar(&cfg.RpcFiltersConfig.FlashblocksURL, "flashblocks.url", rpchelper.DefaultFiltersConfig.FlashblocksURL, "Websocket URL of the sequencer flashblocks stream, served as the pending block (e.g. ws://sequencer:1111)")
+	rootCmd.PersistentFlags().StringVar(&cfg.RpcFiltersConfig.FlashblocksJWTSecret, utils.FlashblocksJWTSecretFlag.Name, rpchelper.DefaultFiltersConfig.FlashblocksJWTSecret, "Path to the JWT secret the flashblocks stream of --flashblocks.url is subscribed with, the one of --flashblocks.jwtsecret of the sequencer")
 	rootCmd.PersistentFlags().IntVar(&cfg.BatchLimit, utils.RpcBatchLimit.Name, utils.RpcBatchLimit.Value, utils.RpcBatchLimit.Usage)
 	rootCmd.PersistentFlags().IntVar(&cfg.ReturnDataLimit, utils.RpcReturnDataLimit.Name, utils.RpcReturnDataLimit.Value, utils.RpcReturnDataLimit.Usage)
+	rootCmd.PersistentFlags().Uint64Var(&cfg.StreamMaxResults, utils.RpcStreamMaxResultsFlag.Name, utils.RpcStreamMaxResultsFlag.Value, utils.RpcStreamMaxResultsFlag.Usage)
+	rootCmd.PersistentFlags().Uint64Var(&cfg.StreamMaxBlocks, utils.RpcStreamMaxBlocksFlag.Name, utils.RpcStreamMaxBlocksFlag.Value, utils.RpcStreamMaxBlocksFlag.Usage)
 
 	rootCmd.PersistentFlags().StringVar(&cfg.RollupSequencerHTTP, utils.RollupSequencerHTTPFlag.Name, "", "HTTP endpoint for the sequencer mempool")
 	rootCmd.PersistentFlags().StringVar(&cfg.RollupHistoricalRPC, utils.RollupHistoricalRPCFlag.Name, "", "RPC endpoint for historical data")
 	rootCmd.PersistentFlags().DurationVar(&cfg.RollupHistoricalRPCTimeout, utils.RollupHistoricalRPCTimeoutFlag.Name, rpccfg.DefaultHistoricalRPCTimeout, "Timeout for historical RPC requests")
+	rootCmd.PersistentFlags().StringVar(&cfg.RollupL1RPC, utils.RollupL1RPCFlag.Name, "", utils.RollupL1RPCFlag.Usage)
 
 	rootCmd.PersistentFlags().BoolVar(&cfg.AllowUnprotectedTxs, utils.AllowUnprotectedTxs.Name, utils.AllowUnprotectedTxs.Value, utils.AllowUnprotectedTxs.Usage)
 	rootCmd.PersistentFlags().IntVar(&cfg.MaxGetProofRewindBlockCount, utils.RpcMaxGetProofRewindBlockCount.Name, utils.RpcMaxGetProofRewindBlockCount.Value, utils.RpcMaxGetProofRewindBlockCount.Usage)
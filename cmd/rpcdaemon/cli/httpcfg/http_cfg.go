@@ -87,10 +87,14 @@ type HttpCfg struct {
 	AllowUnprotectedTxs         bool // Whether to allow non EIP-155 protected transactions  txs over RPC
 	MaxGetProofRewindBlockCount int  //Max GetProof rewind block count
 
+	StreamMaxResults uint64 // Maximum number of results of a response of the streaming methods (like eth_getLogsStream)
+	StreamMaxBlocks  uint64 // Maximum number of blocks scanned by a response of the streaming methods
+
 	// Optimism
 	RollupSequencerHTTP        string
 	RollupHistoricalRPC        string
 	RollupHistoricalRPCTimeout time.Duration
+	RollupL1RPC                string // L1 endpoint resolving the L1 transactions of the deposits
 
 	// Ots API
 	OtsMaxPageSize uint64
@@ -29,6 +29,7 @@ var (
 	discoveryDNS []string
 	nodiscover   bool // disable sentry's discovery mechanism
 	protocol     uint
+	snapServe    bool
 	allowedPorts []uint
 	netRestrict  string // CIDR to restrict peering to
 	maxPeers     int
@@ -49,6 +50,7 @@ func init() {
 	rootCmd.Flags().StringSliceVar(&discoveryDNS, utils.DNSDiscoveryFlag.Name, []string{}, utils.DNSDiscoveryFlag.Usage)
 	rootCmd.Flags().BoolVar(&nodiscover, utils.NoDiscoverFlag.Name, false, utils.NoDiscoverFlag.Usage)
 	rootCmd.Flags().UintVar(&protocol, utils.P2pProtocolVersionFlag.Name, utils.P2pProtocolVersionFlag.Value.Value()[0], utils.P2pProtocolVersionFlag.Usage)
+	rootCmd.Flags().BoolVar(&snapServe, utils.P2pProtocolSnapFlag.Name, false, utils.P2pProtocolSnapFlag.Usage)
 	rootCmd.Flags().UintSliceVar(&allowedPorts, utils.P2pProtocolAllowedPorts.Name, utils.P2pProtocolAllowedPorts.Value.Value(), utils.P2pProtocolAllowedPorts.Usage)
 	rootCmd.Flags().StringVar(&netRestrict, utils.NetrestrictFlag.Name, utils.NetrestrictFlag.Value, utils.NetrestrictFlag.Usage)
 	rootCmd.Flags().IntVar(&maxPeers, utils.MaxPeersFlag.Name, utils.MaxPeersFlag.Value, utils.MaxPeersFlag.Usage)
@@ -92,6 +94,7 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		p2pConfig.SnapServe = snapServe
 
 		logger := debug.SetupCobra(cmd, "sentry")
 		return sentry.Sentry(cmd.Context(), dirs, sentryAddr, discoveryDNS, p2pConfig, protocol, healthCheck, logger)
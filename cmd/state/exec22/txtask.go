@@ -51,6 +51,13 @@ type TxTask struct {
 	TraceTos           map[libcommon.Address]struct{}
 
 	UsedGas uint64
+
+	// VersionedReads and VersionedWrites are the read and write sets of the
+	// speculative execution of a transaction of a block being built
+	VersionedReads  *VersionedReads
+	VersionedWrites *VersionedWrites
+	Incarnation     int  // number of times the transaction was re-executed
+	Failed          bool // the execution reverted
 }
 
 // TxTaskQueue non-thread-safe priority-queue
@@ -0,0 +1,82 @@
+package exec22
+
+import (
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/core/types/accounts"
+)
+
+// StorageKey identifies a storage slot of an incarnation of an account.
+type StorageKey struct {
+	Address     libcommon.Address
+	Incarnation uint64
+	Key         libcommon.Hash
+}
+
+// VersionedReads is the read set of a speculative execution: the version of
+// every account and storage slot it read, 0 when read from the parent block.
+type VersionedReads struct {
+	Accounts map[libcommon.Address]int
+	Storage  map[StorageKey]int
+}
+
+func NewVersionedReads() *VersionedReads {
+	return &VersionedReads{Accounts: map[libcommon.Address]int{}, Storage: map[StorageKey]int{}}
+}
+
+// VersionedWrites is the write set of a speculative execution, as emitted by
+// the finalization of its IntraBlockState. It only records the changes of
+// balances, nonces and storage: Unsupported is set when the execution also
+// deleted or created an account, or deployed code.
+type VersionedWrites struct {
+	Accounts    map[libcommon.Address]*accounts.Account
+	Storage     map[StorageKey]uint256.Int
+	Unsupported bool
+
+	ignored map[libcommon.Address]uint256.Int
+}
+
+func NewVersionedWrites() *VersionedWrites {
+	return &VersionedWrites{Accounts: map[libcommon.Address]*accounts.Account{}, Storage: map[StorageKey]uint256.Int{}}
+}
+
+// Ignore sets the accounts whose writes are not recorded: the accounts which
+// only received a balance increase, which are committed as such.
+func (w *VersionedWrites) Ignore(balanceIncreases map[libcommon.Address]uint256.Int) {
+	w.ignored = balanceIncreases
+}
+
+func (w *VersionedWrites) isIgnored(address libcommon.Address) bool {
+	_, ok := w.ignored[address]
+	return ok
+}
+
+func (w *VersionedWrites) UpdateAccountData(address libcommon.Address, original, account *accounts.Account) error {
+	if !w.isIgnored(address) {
+		w.Accounts[address] = account.SelfCopy()
+	}
+	return nil
+}
+
+func (w *VersionedWrites) UpdateAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash, code []byte) error {
+	w.Unsupported = w.Unsupported || !w.isIgnored(address)
+	return nil
+}
+
+func (w *VersionedWrites) DeleteAccount(address libcommon.Address, original *accounts.Account) error {
+	w.Unsupported = w.Unsupported || !w.isIgnored(address)
+	return nil
+}
+
+func (w *VersionedWrites) WriteAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash, original, value *uint256.Int) error {
+	if !w.isIgnored(address) {
+		w.Storage[StorageKey{Address: address, Incarnation: incarnation, Key: *key}] = *value
+	}
+	return nil
+}
+
+func (w *VersionedWrites) CreateContract(address libcommon.Address) error {
+	w.Unsupported = w.Unsupported || !w.isIgnored(address)
+	return nil
+}
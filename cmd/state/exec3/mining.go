@@ -0,0 +1,198 @@
+package exec3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/cmd/state/exec22"
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+)
+
+// MaxMiningIncarnation bounds the re-executions of a transaction whose read set
+// was invalidated by the commits of the previous ones. The result of the last
+// one is validated again when committed, and executed serially if invalid.
+const MaxMiningIncarnation = 3
+
+var errMiningStateMoved = errors.New("state moved from the parent of the block being built")
+
+// MiningWorker speculatively executes transactions of a block being built on
+// top of a VersionedState, for the block builder to commit them in order.
+// Every worker reads the state of the parent block with its own RoTx.
+type MiningWorker struct {
+	ctx         context.Context
+	chainDb     kv.RoDB
+	chainTx     kv.Tx
+	chainConfig *chain.Config
+	engine      consensus.Engine
+	vmConfig    vm.Config
+	vs          *VersionedState
+	in          *exec22.QueueWithRetry
+	resultCh    *exec22.ResultsQueue
+	taskGasPool *core.GasPool
+}
+
+func NewMiningWorker(ctx context.Context, chainDb kv.RoDB, vs *VersionedState, in *exec22.QueueWithRetry, chainConfig *chain.Config, engine consensus.Engine, vmConfig vm.Config, results *exec22.ResultsQueue) *MiningWorker {
+	return &MiningWorker{
+		ctx:         ctx,
+		chainDb:     chainDb,
+		chainConfig: chainConfig,
+		engine:      engine,
+		vmConfig:    vmConfig,
+		vs:          vs,
+		in:          in,
+		resultCh:    results,
+		taskGasPool: new(core.GasPool),
+	}
+}
+
+// Run executes the tasks of the queue until the context is cancelled. It
+// fails when the state of the database is not the state of the parent of
+// header, e.g. when the chain moved since the block building started.
+func (rw *MiningWorker) Run(header *types.Header) (err error) {
+	if rw.chainTx, err = rw.chainDb.BeginRo(rw.ctx); err != nil {
+		return err
+	}
+	defer func() {
+		rw.chainTx.Rollback()
+		rw.chainTx = nil
+	}()
+	if err := rw.checkParent(header); err != nil {
+		return err
+	}
+
+	for txTask, ok := rw.in.Next(rw.ctx); ok; txTask, ok = rw.in.Next(rw.ctx) {
+		rw.RunTxTask(txTask)
+		// re-execute straight away what already conflicts with the committed transactions
+		if txTask.Incarnation < MaxMiningIncarnation && !rw.vs.Validate(txTask.VersionedReads) {
+			txTask.Incarnation++
+			rw.in.ReTry(txTask)
+			continue
+		}
+		if err := rw.resultCh.Add(rw.ctx, txTask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rw *MiningWorker) checkParent(header *types.Header) error {
+	number := header.Number.Uint64() - 1
+	progress, err := stages.GetStageProgress(rw.chainTx, stages.Execution)
+	if err != nil {
+		return err
+	}
+	hash, err := rawdb.ReadCanonicalHash(rw.chainTx, number)
+	if err != nil {
+		return err
+	}
+	if progress != number || hash != header.ParentHash {
+		return fmt.Errorf("%w: execution at %d, canonical %x, building on %d %x", errMiningStateMoved, progress, hash, number, header.ParentHash)
+	}
+	return nil
+}
+
+// RunTxTask executes the transaction of the task, as core.ApplyTransaction
+// would on the state of the block being built, without finalizing it.
+func (rw *MiningWorker) RunTxTask(txTask *exec22.TxTask) {
+	header := txTask.Header
+	reader := newVersionedReader(rw.vs, state.NewPlainStateReader(rw.chainTx))
+	ibs := state.New(reader)
+
+	txTask.Error = nil
+	txTask.UsedGas, txTask.Failed, txTask.Logs = 0, false, nil
+	txTask.BalanceIncreaseSet = nil
+	txTask.VersionedReads, txTask.VersionedWrites = reader.reads, nil
+
+	msg, err := txTask.Tx.AsMessage(*types.MakeSigner(rw.chainConfig, header.Number.Uint64(), header.Time), header.BaseFee, txTask.Rules)
+	if err != nil {
+		txTask.Error = err
+		return
+	}
+	msg.SetCheckNonce(!rw.vmConfig.StatelessExec)
+
+	txHash := txTask.Tx.Hash()
+	ibs.SetTxContext(txHash, libcommon.Hash{}, txTask.TxIndex)
+	rw.taskGasPool.Reset(txTask.Tx.GetGas())
+
+	getHeader := func(hash libcommon.Hash, number uint64) *types.Header {
+		return rawdb.ReadHeader(rw.chainTx, hash, number)
+	}
+	coinbase := txTask.Coinbase
+	blockContext := core.NewEVMBlockContext(header, core.GetHashFn(header, getHeader), rw.engine, &coinbase)
+	blockContext.L1CostFunc = opstack.NewL1CostFunc(rw.chainConfig, ibs)
+	vmConfig := rw.vmConfig
+	vmConfig.SkipAnalysis = txTask.SkipAnalysis
+	vmConfig.LazyCoinbase = true
+	evm := vm.NewEVM(blockContext, core.NewEVMTxContext(msg), ibs, rw.chainConfig, vmConfig)
+
+	result, err := core.ApplyMessage(evm, msg, rw.taskGasPool, true /* refunds */, false /* gasBailout */)
+	if err != nil {
+		txTask.Error = err
+		return
+	}
+	if err := ibs.Error(); err != nil {
+		txTask.Error = err
+		return
+	}
+
+	// The accounts which only received a balance increase are committed as such, as
+	// the coinbase and the fee vaults: the finalization loads them without recording
+	// the read, which would make every transaction conflict with the previous ones.
+	txTask.BalanceIncreaseSet = ibs.BalanceIncreaseSet()
+	txTask.VersionedWrites = exec22.NewVersionedWrites()
+	txTask.VersionedWrites.Ignore(txTask.BalanceIncreaseSet)
+	reader.record = false
+	if err := ibs.FinalizeTx(txTask.Rules, txTask.VersionedWrites); err != nil {
+		txTask.Error = err
+		return
+	}
+	txTask.UsedGas = result.UsedGas
+	txTask.Failed = result.Failed()
+	txTask.Logs = ibs.GetLogs(txHash)
+}
+
+// NewMiningWorkersPool starts workerCount workers executing the tasks of in on
+// top of vs, for the block with the given header. The results are reported to
+// rws until clear is called, or until a worker fails, which cancels workersCtx.
+// clear returns the error of the failed worker.
+func NewMiningWorkersPool(ctx context.Context, chainDb kv.RoDB, vs *VersionedState, in *exec22.QueueWithRetry, chainConfig *chain.Config, engine consensus.Engine, vmConfig vm.Config, header *types.Header, workerCount, taskCount int) (rws *exec22.ResultsQueue, workersCtx context.Context, clear func() error) {
+	// the results channel holds all the results, so that the workers never wait for the block builder
+	rws = exec22.NewResultsQueue(taskCount, workerCount)
+	ctx, cancel := context.WithCancel(ctx)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < workerCount; i++ {
+		w := NewMiningWorker(ctx, chainDb, vs, in, chainConfig, engine, vmConfig, rws)
+		g.Go(func() error {
+			return w.Run(header)
+		})
+	}
+	var clearDone bool
+	var clearErr error
+	clear = func() error {
+		if clearDone {
+			return clearErr
+		}
+		clearDone = true
+		cancel()
+		if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+			clearErr = err
+		}
+		rws.Close()
+		return clearErr
+	}
+	return rws, ctx, clear
+}
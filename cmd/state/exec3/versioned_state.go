@@ -0,0 +1,207 @@
+package exec3
+
+import (
+	"sync"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/cmd/state/exec22"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types/accounts"
+)
+
+// VersionedState is the state of a block being built, as a layer over the
+// state of its parent block. Every entry is tagged with the version of the
+// write which set it, so that the read set of a speculative execution can be
+// validated against the transactions committed since it was executed.
+type VersionedState struct {
+	lock     sync.RWMutex
+	version  int
+	accounts map[libcommon.Address]versionedAccount
+	storage  map[exec22.StorageKey]versionedStorage
+	codes    map[libcommon.Hash][]byte
+}
+
+type versionedAccount struct {
+	account     *accounts.Account // nil when deleted
+	incarnation uint64            // incarnation of the deleted account
+	reset       bool              // the storage of the parent block is no longer visible
+	version     int
+}
+
+type versionedStorage struct {
+	value   uint256.Int
+	version int
+}
+
+func NewVersionedState() *VersionedState {
+	return &VersionedState{
+		accounts: map[libcommon.Address]versionedAccount{},
+		storage:  map[exec22.StorageKey]versionedStorage{},
+		codes:    map[libcommon.Hash][]byte{},
+	}
+}
+
+// Writer returns a writer of the changes of the next transaction committed to
+// the block, which also passes them on to next.
+func (vs *VersionedState) Writer(next state.StateWriter) state.StateWriter {
+	vs.lock.Lock()
+	vs.version++
+	version := vs.version
+	vs.lock.Unlock()
+	return &versionedWriter{vs: vs, version: version, next: next}
+}
+
+// Reader returns a reader of the state of the block being built, which reads
+// the state of the parent block from parent.
+func (vs *VersionedState) Reader(parent state.StateReader) state.StateReader {
+	return &versionedReader{vs: vs, parent: parent}
+}
+
+// Validate reports whether the entries read by a speculative execution were
+// not written since.
+func (vs *VersionedState) Validate(reads *exec22.VersionedReads) bool {
+	vs.lock.RLock()
+	defer vs.lock.RUnlock()
+	for address, version := range reads.Accounts {
+		if vs.accounts[address].version != version {
+			return false
+		}
+	}
+	for key, version := range reads.Storage {
+		if vs.storage[key].version != version {
+			return false
+		}
+	}
+	return true
+}
+
+type versionedWriter struct {
+	vs      *VersionedState
+	version int
+	next    state.StateWriter
+}
+
+func (w *versionedWriter) UpdateAccountData(address libcommon.Address, original, account *accounts.Account) error {
+	w.vs.lock.Lock()
+	prev := w.vs.accounts[address]
+	w.vs.accounts[address] = versionedAccount{account: account.SelfCopy(), reset: prev.reset, version: w.version}
+	w.vs.lock.Unlock()
+	return w.next.UpdateAccountData(address, original, account)
+}
+
+func (w *versionedWriter) UpdateAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash, code []byte) error {
+	w.vs.lock.Lock()
+	w.vs.codes[codeHash] = libcommon.CopyBytes(code)
+	w.vs.lock.Unlock()
+	return w.next.UpdateAccountCode(address, incarnation, codeHash, code)
+}
+
+func (w *versionedWriter) DeleteAccount(address libcommon.Address, original *accounts.Account) error {
+	w.vs.lock.Lock()
+	w.vs.accounts[address] = versionedAccount{incarnation: original.Incarnation, reset: true, version: w.version}
+	w.vs.lock.Unlock()
+	return w.next.DeleteAccount(address, original)
+}
+
+func (w *versionedWriter) WriteAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash, original, value *uint256.Int) error {
+	w.vs.lock.Lock()
+	w.vs.storage[exec22.StorageKey{Address: address, Incarnation: incarnation, Key: *key}] = versionedStorage{value: *value, version: w.version}
+	w.vs.lock.Unlock()
+	return w.next.WriteAccountStorage(address, incarnation, key, original, value)
+}
+
+func (w *versionedWriter) CreateContract(address libcommon.Address) error {
+	w.vs.lock.Lock()
+	prev := w.vs.accounts[address]
+	prev.reset, prev.version = true, w.version
+	w.vs.accounts[address] = prev
+	w.vs.lock.Unlock()
+	return w.next.CreateContract(address)
+}
+
+// versionedReader reads the VersionedState, and the state of the parent block
+// for what it doesn't hold, recording the versions read.
+type versionedReader struct {
+	vs     *VersionedState
+	parent state.StateReader
+	reads  *exec22.VersionedReads
+	record bool
+}
+
+func newVersionedReader(vs *VersionedState, parent state.StateReader) *versionedReader {
+	return &versionedReader{vs: vs, parent: parent, reads: exec22.NewVersionedReads(), record: true}
+}
+
+func (r *versionedReader) account(address libcommon.Address) (versionedAccount, bool) {
+	r.vs.lock.RLock()
+	a, ok := r.vs.accounts[address]
+	r.vs.lock.RUnlock()
+	if r.record {
+		if _, read := r.reads.Accounts[address]; !read {
+			r.reads.Accounts[address] = a.version
+		}
+	}
+	return a, ok
+}
+
+func (r *versionedReader) ReadAccountData(address libcommon.Address) (*accounts.Account, error) {
+	if a, ok := r.account(address); ok {
+		if a.account == nil {
+			return nil, nil
+		}
+		return a.account.SelfCopy(), nil
+	}
+	return r.parent.ReadAccountData(address)
+}
+
+func (r *versionedReader) ReadAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash) ([]byte, error) {
+	k := exec22.StorageKey{Address: address, Incarnation: incarnation, Key: *key}
+	r.vs.lock.RLock()
+	s, ok := r.vs.storage[k]
+	reset := r.vs.accounts[address].reset
+	r.vs.lock.RUnlock()
+	if r.record {
+		if _, read := r.reads.Storage[k]; !read {
+			r.reads.Storage[k] = s.version
+		}
+	}
+	if ok {
+		return s.value.Bytes(), nil
+	}
+	if reset {
+		return nil, nil
+	}
+	return r.parent.ReadAccountStorage(address, incarnation, key)
+}
+
+func (r *versionedReader) code(codeHash libcommon.Hash) ([]byte, bool) {
+	r.vs.lock.RLock()
+	defer r.vs.lock.RUnlock()
+	code, ok := r.vs.codes[codeHash]
+	return code, ok
+}
+
+// The code is not recorded as read: it is determined by the code hash of the account.
+func (r *versionedReader) ReadAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash) ([]byte, error) {
+	if code, ok := r.code(codeHash); ok {
+		return code, nil
+	}
+	return r.parent.ReadAccountCode(address, incarnation, codeHash)
+}
+
+func (r *versionedReader) ReadAccountCodeSize(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash) (int, error) {
+	if code, ok := r.code(codeHash); ok {
+		return len(code), nil
+	}
+	return r.parent.ReadAccountCodeSize(address, incarnation, codeHash)
+}
+
+func (r *versionedReader) ReadAccountIncarnation(address libcommon.Address) (uint64, error) {
+	if a, ok := r.account(address); ok && a.account == nil && a.incarnation > 0 {
+		return a.incarnation, nil
+	}
+	return r.parent.ReadAccountIncarnation(address)
+}
@@ -61,6 +61,13 @@ var (
 	noTxGossip bool
 
 	commitEvery time.Duration
+
+	policyFile        string
+	policyReloadEvery time.Duration
+
+	interopRPC       string
+	interopMinSafety string
+	interopTimeout   time.Duration
 )
 
 func init() {
@@ -89,6 +96,11 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&optimism, "txpool.optimism", txpoolcfg.DefaultConfig.Optimism, "Enable Optimism Bedrock to make txpool account for L1 cost of transactions")
 	rootCmd.PersistentFlags().BoolVar(&noTxGossip, utils.TxPoolGossipDisableFlag.Name, utils.TxPoolGossipDisableFlag.Value, utils.TxPoolGossipDisableFlag.Usage)
 	rootCmd.Flags().StringSliceVar(&traceSenders, utils.TxPoolTraceSendersFlag.Name, []string{}, utils.TxPoolTraceSendersFlag.Usage)
+	rootCmd.PersistentFlags().StringVar(&policyFile, utils.TxPoolPolicyFileFlag.Name, utils.TxPoolPolicyFileFlag.Value, utils.TxPoolPolicyFileFlag.Usage)
+	rootCmd.PersistentFlags().DurationVar(&policyReloadEvery, utils.TxPoolPolicyReloadEveryFlag.Name, utils.TxPoolPolicyReloadEveryFlag.Value, utils.TxPoolPolicyReloadEveryFlag.Usage)
+	rootCmd.PersistentFlags().StringVar(&interopRPC, utils.RollupInteropRPCFlag.Name, "", utils.RollupInteropRPCFlag.Usage)
+	rootCmd.PersistentFlags().StringVar(&interopMinSafety, utils.RollupInteropMinSafetyFlag.Name, utils.RollupInteropMinSafetyFlag.Value, utils.RollupInteropMinSafetyFlag.Usage)
+	rootCmd.PersistentFlags().DurationVar(&interopTimeout, utils.RollupInteropTimeoutFlag.Name, utils.RollupInteropTimeoutFlag.Value, utils.RollupInteropTimeoutFlag.Usage)
 }
 
 var rootCmd = &cobra.Command{
@@ -156,6 +168,11 @@ func doTxpool(ctx context.Context, logger log.Logger) error {
 	cfg.PriceBump = priceBump
 	cfg.BlobPriceBump = blobPriceBump
 	cfg.NoGossip = noTxGossip
+	cfg.PolicyFile = policyFile
+	cfg.PolicyReloadEvery = policyReloadEvery
+	cfg.InteropRPC = interopRPC
+	cfg.InteropMinSafety = interopMinSafety
+	cfg.InteropTimeout = interopTimeout
 
 	cfg.Optimism = optimism
 
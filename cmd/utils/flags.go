@@ -20,6 +20,7 @@ package utils
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"math"
 	"math/big"
 	"path/filepath"
 	"runtime"
@@ -95,7 +96,15 @@ var (
 	}
 	DeveloperPeriodFlag = cli.IntFlag{
 		Name:  "dev.period",
-		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
+		Usage: "Block period to use in developer mode (0 = mine only if transaction pending, every 2 seconds with --dev.optimism)",
+	}
+	DeveloperOptimismFlag = cli.BoolFlag{
+		Name:  "dev.optimism",
+		Usage: "Run the developer chain as an OP Stack chain with every OP fork active, sequencing its blocks without op-node nor L1 (requires --chain=dev)",
+	}
+	DeveloperOptimismMintFlag = cli.StringFlag{
+		Name:  "dev.optimism.mint",
+		Usage: "Comma separated addresses credited 1000 ETH by deposits in the first block sequenced by --dev.optimism",
 	}
 	ChainFlag = cli.StringFlag{
 		Name:  "chain",
@@ -258,6 +267,21 @@ var (
 		Usage: "How often transactions should be committed to the storage",
 		Value: txpoolcfg.DefaultConfig.CommitEvery,
 	}
+	TxPoolPolicyFileFlag = cli.StringFlag{
+		Name:  "txpool.policy",
+		Usage: "JSON file with sender/recipient deny and allow lists, contract function selector rules and per-sender rate limits, enforced by the txpool and the block builder. The file is reloaded on change",
+		Value: "",
+	}
+	TxPoolPolicyReloadEveryFlag = cli.DurationFlag{
+		Name:  "txpool.policy.reload.every",
+		Usage: "How often the txpool policy file is checked for changes",
+		Value: txpoolcfg.DefaultConfig.PolicyReloadEvery,
+	}
+	TxPoolBundleSlotsFlag = cli.IntFlag{
+		Name:  "txpool.bundleslots",
+		Usage: "Maximum number of atomic bundles submitted with eth_sendBundle and kept until their target block range passes. Bundles are never gossiped. 0 disables eth_sendBundle",
+		Value: txpoolcfg.DefaultConfig.BundleSlots,
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -300,10 +324,20 @@ var (
 		Usage: "Time interval to recreate the block being mined",
 		Value: ethconfig.Defaults.Miner.Recommit,
 	}
+	MinerRebuildIntervalFlag = cli.DurationFlag{
+		Name:  "miner.rebuildinterval",
+		Usage: "Time interval to re-build the Proof-of-Stake payloads with the latest transactions until they are retrieved (0 = build once). Opt-in, as every re-build re-executes the block: a few hundred milliseconds, e.g. 250ms",
+		Value: ethconfig.Defaults.Miner.RebuildInterval,
+	}
 	MinerNoVerfiyFlag = cli.BoolFlag{
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerExecWorkersFlag = cli.IntFlag{
+		Name:  "miner.execworkers",
+		Usage: "Number of workers speculatively executing the transactions of the blocks being built, in parallel (serial execution if <= 1)",
+		Value: 0,
+	}
 	VMEnableDebugFlag = cli.BoolFlag{
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
@@ -430,6 +464,16 @@ var (
 		Usage: "Maximum number of bytes returned from eth_call or similar invocations",
 		Value: 100_000,
 	}
+	RpcStreamMaxResultsFlag = cli.Uint64Flag{
+		Name:  "rpc.stream.maxresults",
+		Usage: "Maximum number of results of a response of eth_getLogsStream or trace_filterStream, the rest is resumed with its cursor (0 = no limit)",
+		Value: 10_000,
+	}
+	RpcStreamMaxBlocksFlag = cli.Uint64Flag{
+		Name:  "rpc.stream.maxblocks",
+		Usage: "Maximum number of blocks scanned by a response of eth_getLogsStream or trace_filterStream, the rest is resumed with its cursor (0 = no limit)",
+		Value: 10_000,
+	}
 	HTTPTraceFlag = cli.BoolFlag{
 		Name:  "http.trace",
 		Usage: "Print all HTTP requests to logs with INFO level",
@@ -572,9 +616,13 @@ var (
 	}
 	P2pProtocolVersionFlag = cli.UintSliceFlag{
 		Name:  "p2p.protocol",
-		Usage: "Version of eth p2p protocol",
+		Usage: "Version of eth p2p protocol, eth/69 is opt-in: --p2p.protocol=68,67,69 serves it on the next allowed port",
 		Value: cli.NewUintSlice(nodecfg.DefaultConfig.P2P.ProtocolVersion...),
 	}
+	P2pProtocolSnapFlag = cli.BoolFlag{
+		Name:  "p2p.protocol.snap",
+		Usage: "Serve the snap/1 protocol alongside eth, so that other clients can snap-sync the state from this node. Only the state of the head is served, not the older pivots of geth. Ignored with historyV3",
+	}
 	P2pProtocolAllowedPorts = cli.UintSliceFlag{
 		Name:  "p2p.allowed-ports",
 		Usage: "Allowed ports to pick for different eth p2p protocol versions as follows <porta>,<portb>,..,<porti>",
@@ -696,11 +744,86 @@ var (
 		Usage: "Timeout for historical RPC requests.",
 		Value: "5s",
 	}
+	RollupInteropRPCFlag = cli.StringFlag{
+		Name:  "rollup.interoprpc",
+		Usage: "RPC endpoint of the op-supervisor validating the cross-chain executing messages of interop transactions, in the txpool and in the block builder",
+	}
+	RollupInteropMinSafetyFlag = cli.StringFlag{
+		Name:  "rollup.interopminsafety",
+		Usage: "Minimal safety level of the initiating messages executed by interop transactions (finalized, safe, local-safe, cross-unsafe, unsafe)",
+		Value: txpoolcfg.DefaultConfig.InteropMinSafety,
+	}
+	RollupInteropTimeoutFlag = cli.DurationFlag{
+		Name:  "rollup.interoptimeout",
+		Usage: "Timeout of the op-supervisor requests",
+		Value: txpoolcfg.DefaultConfig.InteropTimeout,
+	}
+	RollupDepositIndexFlag = cli.BoolFlag{
+		Name:  "rollup.depositindex",
+		Usage: "Build the index of the deposit transactions by source hash, served by boba_getDepositBySourceHash and boba_getDepositsByL1Tx",
+	}
+	RollupL1RPCFlag = cli.StringFlag{
+		Name:  "rollup.l1rpc",
+		Usage: "RPC endpoint of an L1 node, resolving the L1 transactions of boba_getDepositsByL1Tx",
+	}
+	ShadowRPCFlag = cli.StringFlag{
+		Name:  "shadow.rpc",
+		Usage: "RPC endpoint of a reference node of the chain (e.g. op-geth): every new block is re-executed and its hash, receipts and gas used are compared against it, disabled if empty",
+	}
+	ShadowDirFlag = cli.StringFlag{
+		Name:  "shadow.dir",
+		Usage: "Directory of the forensics of the blocks diverging from the reference of --shadow.rpc (default: <datadir>/shadow)",
+	}
+	FlashblocksAddrFlag = cli.StringFlag{
+		Name:  "flashblocks.addr",
+		Usage: "Listening address of the websocket stream of the flashblocks of the payloads built by the sequencer (e.g. 0.0.0.0:1111), disabled if empty. Without --flashblocks.jwtsecret, bind it to localhost or a private network",
+	}
+	FlashblocksIntervalFlag = cli.DurationFlag{
+		Name:  "flashblocks.interval",
+		Usage: "Interval between the flashblocks of a payload",
+		Value: 200 * time.Millisecond,
+	}
+	FlashblocksJWTSecretFlag = cli.StringFlag{
+		Name:  "flashblocks.jwtsecret",
+		Usage: "Path to the JWT secret the subscribers of the flashblocks stream authenticate with, on the sequencer (--flashblocks.addr) and on the RPC replicas (--flashblocks.url). The stream is public if empty",
+	}
+	ExternalBuildersFlag = cli.StringFlag{
+		Name:  "builder.urls",
+		Usage: "Comma separated Engine API endpoints of the external block builders the sequencer forwards its payload attributes to, disabled if empty",
+	}
+	ExternalBuilderJWTSecretFlag = cli.StringFlag{
+		Name:  "builder.jwtsecret",
+		Usage: "Path to the token that ensures safe connection to the Engine API of the external block builders",
+	}
+	ExternalBuilderTimeoutFlag = cli.DurationFlag{
+		Name:  "builder.timeout",
+		Usage: "Maximal delay of the external payloads at getPayload, before falling back to the local payload",
+		Value: 500 * time.Millisecond,
+	}
+	ExternalBuilderSelectionFlag = cli.StringFlag{
+		Name:  "builder.selection",
+		Usage: "Payload proposed at getPayload: the valid external payload of highest value (external), the valid payload of highest value, local or external (value), or the local payload, external ones being only validated (local)",
+		Value: "external",
+	}
 	RollupHaltOnIncompatibleProtocolVersionFlag = cli.StringFlag{
 		Name:  "rollup.halt",
 		Usage: "Opt-in option to halt on incompatible protocol version requirements of the given level (major/minor/patch/none), as signaled through the Engine API by the rollup node",
 	}
 
+	// Signer flags
+	SignerKeystoreFlag = cli.StringFlag{
+		Name:  "signer.keystore",
+		Usage: "Directory of the encrypted key files (web3 secret storage) of the accounts of eth_accounts, eth_sign*, eth_signTransaction and eth_sendTransaction on the authenticated endpoint, disabled if empty",
+	}
+	SignerPasswordFlag = cli.StringFlag{
+		Name:  "signer.password",
+		Usage: "Password file of the keystore, one password per line, tried on each key file",
+	}
+	SignerExternalFlag = cli.StringFlag{
+		Name:  "signer.external",
+		Usage: "Endpoint (URL or IPC path) of an external signer implementing the account API of clef, used instead of a keystore, disabled if empty",
+	}
+
 	// Metrics flags
 	MetricsEnabledFlag = cli.BoolFlag{
 		Name:  "metrics",
@@ -913,6 +1036,10 @@ var (
 		Usage: "Max allowed page size for search methods",
 		Value: 25,
 	}
+	OtsTokenIndicesFlag = cli.BoolFlag{
+		Name:  "ots.tokenindices",
+		Usage: "Build the indices of the ERC-20, ERC-721 and ERC-1155 transfers and holdings served by the ots2 API, from the receipts logs",
+	}
 
 	DiagnosticsURLFlag = cli.StringFlag{
 		Name:  "diagnostics.addr",
@@ -1036,6 +1163,19 @@ var (
 		Usage: "disable blob pruning in caplin",
 		Value: false,
 	}
+	CaplinBlobArchiveFlag = cli.BoolFlag{
+		Name:  "caplin.blobs.archive",
+		Usage: "keep the blob sidecars past the pruning distance, for ever unless --caplin.blobs.archive.slots is set",
+		Value: false,
+	}
+	CaplinBlobArchiveSlotsFlag = cli.Uint64Flag{
+		Name:  "caplin.blobs.archive.slots",
+		Usage: "number of slots back from the current one the blob sidecars are kept for, when more than the pruning distance (implies --caplin.blobs.archive)",
+	}
+	CaplinBlobArchiveInboxesFlag = cli.StringFlag{
+		Name:  "caplin.blobs.archive.inboxes",
+		Usage: "comma separated batch inbox addresses: only archive the blob sidecars of the transactions sent to them",
+	}
 	CaplinArchiveFlag = cli.BoolFlag{
 		Name:  "caplin.archive",
 		Usage: "enables archival node in caplin",
@@ -1221,6 +1361,8 @@ func NewP2PConfig(
 		enodeDBPath = filepath.Join(dirs.Nodes, "eth67")
 	case direct.ETH68:
 		enodeDBPath = filepath.Join(dirs.Nodes, "eth68")
+	case direct.ETH69:
+		enodeDBPath = filepath.Join(dirs.Nodes, "eth69")
 	default:
 		return nil, fmt.Errorf("unknown protocol: %v", protocol)
 	}
@@ -1394,6 +1536,10 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config, nodeName, datadir string, l
 		cfg.DiscoveryV5 = ctx.Bool(DiscoveryV5Flag.Name)
 	}
 
+	if ctx.IsSet(P2pProtocolSnapFlag.Name) {
+		cfg.SnapServe = ctx.Bool(P2pProtocolSnapFlag.Name)
+	}
+
 	if ctx.IsSet(MetricsEnabledFlag.Name) {
 		cfg.MetricsEnabled = ctx.Bool(MetricsEnabledFlag.Name)
 	}
@@ -1564,6 +1710,15 @@ func setTxPool(ctx *cli.Context, fullCfg *ethconfig.Config) {
 		fullCfg.TxPool.BlobPriceBump = ctx.Uint64(TxPoolBlobPriceBumpFlag.Name)
 	}
 	cfg.CommitEvery = common2.RandomizeDuration(ctx.Duration(TxPoolCommitEveryFlag.Name))
+	if ctx.IsSet(TxPoolPolicyFileFlag.Name) {
+		fullCfg.TxPool.PolicyFile = ctx.String(TxPoolPolicyFileFlag.Name)
+	}
+	if ctx.IsSet(TxPoolPolicyReloadEveryFlag.Name) {
+		fullCfg.TxPool.PolicyReloadEvery = ctx.Duration(TxPoolPolicyReloadEveryFlag.Name)
+	}
+	if ctx.IsSet(TxPoolBundleSlotsFlag.Name) {
+		fullCfg.TxPool.BundleSlots = ctx.Int(TxPoolBundleSlotsFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, datadir string, cfg *ethconfig.Config) {
@@ -1682,6 +1837,12 @@ func setMiner(ctx *cli.Context, cfg *params.MiningConfig) {
 	if ctx.IsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.IsSet(MinerRebuildIntervalFlag.Name) {
+		cfg.RebuildInterval = ctx.Duration(MinerRebuildIntervalFlag.Name)
+	}
+	if ctx.IsSet(MinerExecWorkersFlag.Name) {
+		cfg.ExecWorkers = ctx.Int(MinerExecWorkersFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1731,6 +1892,24 @@ func setCaplin(ctx *cli.Context, cfg *ethconfig.Config) {
 	cfg.CaplinConfig.BlobBackfilling = ctx.Bool(CaplinBlobBackfillingFlag.Name)
 	cfg.CaplinConfig.BlobPruningDisabled = ctx.Bool(CaplinDisableBlobPruningFlag.Name)
 	cfg.CaplinConfig.Archive = ctx.Bool(CaplinArchiveFlag.Name)
+	if ctx.Bool(CaplinBlobArchiveFlag.Name) || ctx.IsSet(CaplinBlobArchiveSlotsFlag.Name) {
+		cfg.CaplinConfig.BlobArchiveSlots = math.MaxUint64
+		if ctx.IsSet(CaplinBlobArchiveSlotsFlag.Name) {
+			cfg.CaplinConfig.BlobArchiveSlots = ctx.Uint64(CaplinBlobArchiveSlotsFlag.Name)
+		}
+		for _, inbox := range libcommon.CliString2Array(ctx.String(CaplinBlobArchiveInboxesFlag.Name)) {
+			if !libcommon.IsHexAddress(inbox) {
+				Fatalf("Invalid address in --%s: %s", CaplinBlobArchiveInboxesFlag.Name, inbox)
+			}
+			cfg.CaplinConfig.BlobArchiveInboxes = append(cfg.CaplinConfig.BlobArchiveInboxes, libcommon.HexToAddress(inbox))
+		}
+		// the archive needs the blocks to find the sidecars to download, and archiving
+		// all of them for ever is what blob backfilling does, with the segment files.
+		cfg.CaplinConfig.Backfilling = true
+		if cfg.CaplinConfig.BlobArchiveSlots == math.MaxUint64 && len(cfg.CaplinConfig.BlobArchiveInboxes) == 0 {
+			cfg.CaplinConfig.BlobBackfilling = true
+		}
+	}
 }
 
 func setSilkworm(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1902,6 +2081,41 @@ func SetEthConfig(ctx *cli.Context, nodeConfig *nodecfg.Config, cfg *ethconfig.C
 		cfg.RollupHistoricalRPC = ctx.String(RollupHistoricalRPCFlag.Name)
 	}
 	cfg.RollupHistoricalRPCTimeout = ctx.Duration(RollupHistoricalRPCTimeoutFlag.Name)
+	cfg.RollupDepositIndex = ctx.Bool(RollupDepositIndexFlag.Name)
+	if ctx.IsSet(RollupInteropRPCFlag.Name) {
+		cfg.TxPool.InteropRPC = ctx.String(RollupInteropRPCFlag.Name)
+	}
+	if ctx.IsSet(RollupInteropMinSafetyFlag.Name) {
+		cfg.TxPool.InteropMinSafety = ctx.String(RollupInteropMinSafetyFlag.Name)
+	}
+	if ctx.IsSet(RollupInteropTimeoutFlag.Name) {
+		cfg.TxPool.InteropTimeout = ctx.Duration(RollupInteropTimeoutFlag.Name)
+	}
+	cfg.FlashblocksAddr = ctx.String(FlashblocksAddrFlag.Name)
+	cfg.FlashblocksInterval = ctx.Duration(FlashblocksIntervalFlag.Name)
+	cfg.FlashblocksJWTSecret = ctx.String(FlashblocksJWTSecretFlag.Name)
+	if urls := ctx.String(ExternalBuildersFlag.Name); urls != "" {
+		cfg.ExternalBuilders = libcommon.CliString2Array(urls)
+	}
+	cfg.ExternalBuilderJWTSecret = ctx.String(ExternalBuilderJWTSecretFlag.Name)
+	cfg.ExternalBuilderTimeout = ctx.Duration(ExternalBuilderTimeoutFlag.Name)
+	cfg.ExternalBuilderSelection = ctx.String(ExternalBuilderSelectionFlag.Name)
+	cfg.SignerKeystore = ctx.String(SignerKeystoreFlag.Name)
+	cfg.SignerPasswordFile = ctx.String(SignerPasswordFlag.Name)
+	cfg.SignerExternal = ctx.String(SignerExternalFlag.Name)
+	cfg.OtsTokenIndices = ctx.Bool(OtsTokenIndicesFlag.Name)
+	cfg.ShadowRPC = ctx.String(ShadowRPCFlag.Name)
+	cfg.ShadowDir = ctx.String(ShadowDirFlag.Name)
+	if cfg.ShadowDir == "" {
+		cfg.ShadowDir = filepath.Join(cfg.Dirs.DataDir, "shadow")
+	}
+	if cfg.SignerKeystore != "" && cfg.SignerExternal != "" {
+		Fatalf("--%s and --%s are exclusive", SignerKeystoreFlag.Name, SignerExternalFlag.Name)
+	}
+
+	if ctx.Bool(DeveloperOptimismFlag.Name) && chain != networkname.DevChainName {
+		Fatalf("--%s requires --%s=%s", DeveloperOptimismFlag.Name, ChainFlag.Name, networkname.DevChainName)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch chain {
@@ -1927,12 +2141,24 @@ func SetEthConfig(ctx *cli.Context, nodeConfig *nodecfg.Config, cfg *ethconfig.C
 		logger.Info("Using developer account", "address", developer)
 
 		// Create a new developer genesis block or reuse existing one
-		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.Int(DeveloperPeriodFlag.Name)), developer)
-		logger.Info("Using custom developer period", "seconds", cfg.Genesis.Config.Clique.Period)
+		if ctx.Bool(DeveloperOptimismFlag.Name) {
+			cfg.Genesis = core.DeveloperOptimismGenesisBlock(developer)
+			cfg.DevOptimism = true
+			cfg.DevOptimismPeriod = time.Duration(ctx.Int(DeveloperPeriodFlag.Name)) * time.Second
+			for _, addr := range libcommon.CliString2Array(ctx.String(DeveloperOptimismMintFlag.Name)) {
+				if !libcommon.IsHexAddress(addr) {
+					Fatalf("Invalid address in --%s: %s", DeveloperOptimismMintFlag.Name, addr)
+				}
+				cfg.DevOptimismMint = append(cfg.DevOptimismMint, libcommon.HexToAddress(addr))
+			}
+			logger.Info("Using OP Stack developer chain", "period", cfg.DevOptimismPeriod, "mint", len(cfg.DevOptimismMint))
+		} else {
+			cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.Int(DeveloperPeriodFlag.Name)), developer)
+			logger.Info("Using custom developer period", "seconds", cfg.Genesis.Config.Clique.Period)
+		}
 		if !ctx.IsSet(MinerGasPriceFlag.Name) {
 			cfg.Miner.GasPrice = big.NewInt(1)
 		}
-		// TODO(jky) Review whether to add OpDevnetChainName
 	}
 
 	// Set Optimism Fjord hardfork time
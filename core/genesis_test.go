@@ -11,7 +11,10 @@ import (
 	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/memdb"
 	"github.com/erigontech/erigon-lib/kv/temporal/temporaltest"
+	"github.com/erigontech/erigon-lib/opstack"
+	types2 "github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/turbo/rpchelper"
 	"github.com/holiman/uint256"
@@ -20,8 +23,11 @@ import (
 
 	"github.com/erigontech/erigon-lib/log/v3"
 	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/consensus/misc"
 	"github.com/erigontech/erigon/core/state"
 	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/core/vm/evmtypes"
 	"github.com/erigontech/erigon/params"
 )
 
@@ -157,3 +163,50 @@ func TestDecodeBalance0(t *testing.T) {
 	require.NoError(t, err)
 	_ = genesisData
 }
+
+func TestDeveloperOptimismGenesisBlock(t *testing.T) {
+	genesis := core.DeveloperOptimismGenesisBlock(core.DevnetEtherbase)
+	db := memdb.NewTestDB(t)
+	_, block, err := core.CommitGenesisBlock(db, genesis, t.TempDir(), log.New())
+	require.NoError(t, err)
+	require.NoError(t, misc.ValidateHoloceneExtraData(block.Extra()))
+	tx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	ibs := state.New(state.NewPlainStateReader(tx))
+
+	info := &opstack.L1BlockInfo{
+		Number:         7,
+		Time:           84,
+		BaseFee:        uint256.NewInt(params.GWei),
+		BlobBaseFee:    uint256.NewInt(1),
+		Hash:           libcommon.Hash{1},
+		SequenceNumber: 2,
+		BatcherHash:    libcommon.Hash{2},
+	}
+	data := info.MarshalEcotone(1368, 810949)
+	evm := vm.NewEVM(core.NewEVMBlockContext(block.Header(), nil, nil, &libcommon.Address{}), evmtypes.TxContext{}, ibs, genesis.Config, vm.Config{})
+	// only the depositor sets the L1 attributes
+	_, _, err = evm.Call(vm.AccountRef(core.DevnetEtherbase), opstack.L1BlockAddr, data, 100_000, new(uint256.Int), false)
+	require.ErrorIs(t, err, vm.ErrExecutionReverted)
+	_, _, err = evm.Call(vm.AccountRef(opstack.L1InfoDepositorAddress), opstack.L1BlockAddr, data, 200_000, new(uint256.Int), false)
+	require.NoError(t, err)
+
+	slot := func(i int64) *uint256.Int {
+		key, value := libcommon.BigToHash(big.NewInt(i)), new(uint256.Int)
+		ibs.GetState(opstack.L1BlockAddr, &key, value)
+		return value
+	}
+	shl := func(x uint64, n uint) *uint256.Int { return new(uint256.Int).Lsh(uint256.NewInt(x), n) }
+	require.Equal(t, new(uint256.Int).Or(shl(84, 64), uint256.NewInt(7)), slot(0))
+	require.Equal(t, info.BaseFee, slot(1))
+	require.Equal(t, info.Hash, libcommon.Hash(slot(2).Bytes32()))
+	scalars := new(uint256.Int).Or(shl(1368, 96), shl(810949, 64))
+	require.Equal(t, scalars.Or(scalars, uint256.NewInt(2)), slot(3))
+	require.Equal(t, info.BatcherHash, libcommon.Hash(slot(4).Bytes32()))
+	require.Equal(t, info.BlobBaseFee, slot(7))
+
+	fee := opstack.NewL1CostFunc(genesis.Config, ibs)(types2.RollupCostData{Ones: 100}, block.Time())
+	require.NotNil(t, fee)
+	require.False(t, fee.IsZero())
+}
@@ -42,13 +42,16 @@ import (
 	"github.com/erigontech/erigon-lib/kv/kvcfg"
 	"github.com/erigontech/erigon-lib/kv/mdbx"
 	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+	"github.com/erigontech/erigon-lib/opstack"
 
 	"github.com/erigontech/erigon/common"
 	"github.com/erigontech/erigon/consensus/ethash"
 	"github.com/erigontech/erigon/consensus/merge"
+	"github.com/erigontech/erigon/consensus/misc"
 	"github.com/erigontech/erigon/core/rawdb"
 	"github.com/erigontech/erigon/core/state"
 	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
 	"github.com/erigontech/erigon/crypto"
 	"github.com/erigontech/erigon/params"
 	"github.com/erigontech/erigon/turbo/trie"
@@ -205,6 +208,9 @@ func WriteGenesisBlock(tx kv.RwTx, genesis *types.Genesis, overrides *ChainOverr
 	if err := newCfg.CheckConfigForkOrder(); err != nil {
 		return newCfg, nil, err
 	}
+	if err := vm.ValidatePrecompiles(newCfg); err != nil {
+		return newCfg, nil, err
+	}
 	storedCfg, storedErr := rawdb.ReadChainConfig(tx, storedHash)
 	if storedErr != nil && newCfg.Bor == nil {
 		return newCfg, nil, storedErr
@@ -331,6 +337,9 @@ func write(tx kv.RwTx, g *types.Genesis, tmpDir string, logger log.Logger) (*typ
 	if err := config.CheckConfigForkOrder(); err != nil {
 		return nil, nil, err
 	}
+	if err := vm.ValidatePrecompiles(config); err != nil {
+		return nil, nil, err
+	}
 
 	if err := rawdb.WriteBlock(tx, block); err != nil {
 		return nil, nil, err
@@ -565,6 +574,36 @@ func DeveloperGenesisBlock(period uint64, faucet libcommon.Address) *types.Genes
 	}
 }
 
+// devL1BlockCode is a minimal stand-in for the L1Block predeploy: it only
+// accepts the Ecotone L1 attributes deposit, and stores its values in the
+// storage layout of the real contract, for the L1 cost function to read them.
+var devL1BlockCode = common.FromHex("33" + "73deaddeaddeaddeaddeaddeaddeaddeaddead0001" + "14601e57600080fd5b" +
+	"60043560801c600355" + // sequenceNumber, blobBaseFeeScalar, baseFeeScalar
+	"60143560801c600055" + // number, timestamp
+	"6024356001" + "55" + // basefee
+	"6044356007" + "55" + // blobBaseFee
+	"6064356002" + "55" + // hash
+	"6084356004" + "55" + // batcherHash
+	"00")
+
+// DeveloperOptimismGenesisBlock returns the 'erigon --dev.optimism' genesis
+// block, with every OP Stack fork active.
+func DeveloperOptimismGenesisBlock(faucet libcommon.Address) *types.Genesis {
+	config := *params.AllOptimismProtocolChanges
+	alloc := readPrealloc("allocs/dev.json")
+	if _, ok := alloc[faucet]; !ok {
+		alloc[faucet] = types.GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(10_000), big.NewInt(params.Ether))}
+	}
+	alloc[opstack.L1BlockAddr] = types.GenesisAccount{Code: devL1BlockCode, Balance: new(big.Int)}
+	return &types.Genesis{
+		Config:     &config,
+		ExtraData:  misc.EncodeHoloceneExtraData(config.Optimism.EIP1559DenominatorCanyon, config.Optimism.EIP1559Elasticity),
+		GasLimit:   30_000_000,
+		Difficulty: new(big.Int),
+		Alloc:      alloc,
+	}
+}
+
 // ToBlock creates the genesis block and writes state of a genesis specification
 // to the given database (or discards it if nil).
 func GenesisToBlock(g *types.Genesis, tmpDir string, logger log.Logger) (*types.Block, *state.IntraBlockState, error) {
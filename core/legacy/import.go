@@ -0,0 +1,475 @@
+package legacy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/etl"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/bitmapdb"
+	"github.com/erigontech/erigon-lib/kv/dbutils"
+	"github.com/erigontech/erigon-lib/kv/kvcfg"
+	"github.com/erigontech/erigon-lib/kv/temporal/historyv2"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/services"
+)
+
+const importBatchSize = 1_000
+
+// Import reads a legacy export and writes its blocks, receipts, transaction
+// metadata and state history into the chain database. The export has to cover
+// the legacy chain [0, bedrock) in order: the state of the legacy heights is
+// kept as changesets, the last of them at the Bedrock block recording the
+// legacy value of every key the Bedrock migration replaced. Blocks which are
+// already present (e.g. in the snapshots) are verified and kept.
+//
+// Importing an export twice is harmless. The import can't resume: the blocks
+// are committed as they come, but the state history is only written once the
+// export reached Bedrock, so an interrupted import is run again from the
+// start of the export. The state history is only written with the erigon2
+// layout (--history.v3=false), the import fails with --history.v3.
+func Import(ctx context.Context, db kv.RwDB, blockReader services.FullBlockReader, chainConfig *chain.Config, r io.Reader, tmpdir string, logger log.Logger) error {
+	if !chainConfig.IsOptimism() || chainConfig.BedrockBlock == nil || chainConfig.BedrockBlock.Sign() == 0 {
+		return errors.New("the chain has no legacy blocks")
+	}
+	var historyV3 bool
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		historyV3, err = kvcfg.HistoryV3.Enabled(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+	if historyV3 {
+		return errors.New("the legacy state can't be imported with --history.v3")
+	}
+
+	imp := &importer{
+		blockReader:  blockReader,
+		chainConfig:  chainConfig,
+		bedrock:      chainConfig.BedrockBlock.Uint64(),
+		td:           new(big.Int),
+		accounts:     etl.NewCollector("legacy import", tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize), logger),
+		storage:      etl.NewCollector("legacy import", tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize), logger),
+		accountIndex: etl.NewCollector("legacy import", tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize), logger),
+		storageIndex: etl.NewCollector("legacy import", tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize), logger),
+		logger:       logger,
+	}
+	defer imp.close()
+
+	logEvery := time.NewTicker(30 * time.Second)
+	defer logEvery.Stop()
+
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stream := rlp.NewStream(r, 0)
+	for {
+		var b Block
+		if err := stream.Decode(&b); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("decode legacy block %d: %w", imp.next, err)
+		}
+		if err := imp.importBlock(ctx, tx, &b); err != nil {
+			return fmt.Errorf("legacy block %d: %w", imp.next, err)
+		}
+		imp.next++
+
+		if imp.next%importBatchSize == 0 {
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			if tx, err = db.BeginRw(ctx); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-logEvery.C:
+			logger.Info("[legacy import] Importing blocks", "block", imp.next-1, "bedrock", imp.bedrock)
+		default:
+		}
+	}
+
+	if imp.next != imp.bedrock {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		logger.Warn("[legacy import] The export doesn't reach Bedrock, the legacy state is not served", "imported", imp.next, "bedrock", imp.bedrock)
+		return nil
+	}
+	if err := imp.writeMigration(ctx, tx); err != nil {
+		return err
+	}
+	if err := writeStateImported(tx, imp.bedrock-1); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logger.Info("[legacy import] Done", "blocks", imp.next)
+	return nil
+}
+
+type importer struct {
+	blockReader services.FullBlockReader
+	chainConfig *chain.Config
+	bedrock     uint64
+
+	next       uint64 // number of the next expected block
+	parentHash libcommon.Hash
+	td         *big.Int
+
+	// the changes of every state key in block order: key + block number -> value
+	accounts *etl.Collector
+	storage  *etl.Collector
+	// history index entries: index key -> block number
+	accountIndex *etl.Collector
+	storageIndex *etl.Collector
+
+	logger log.Logger
+}
+
+func (imp *importer) close() {
+	imp.accounts.Close()
+	imp.storage.Close()
+	imp.accountIndex.Close()
+	imp.storageIndex.Close()
+}
+
+func (imp *importer) importBlock(ctx context.Context, tx kv.RwTx, b *Block) error {
+	if b.Header == nil || b.Header.Number == nil || b.Header.Number.Uint64() != imp.next {
+		return errors.New("unexpected block number, the export has to start at genesis and be in order")
+	}
+	if imp.next >= imp.bedrock {
+		return fmt.Errorf("not a legacy block, bedrock is %d", imp.bedrock)
+	}
+	if imp.next > 0 && b.Header.ParentHash != imp.parentHash {
+		return fmt.Errorf("parent hash mismatch: %x != %x", b.Header.ParentHash, imp.parentHash)
+	}
+	if len(b.Meta) != len(b.Txs) || len(b.Senders) != len(b.Txs) || len(b.Receipts) != len(b.Txs) {
+		return fmt.Errorf("%d transactions with %d metas, %d senders and %d receipts", len(b.Txs), len(b.Meta), len(b.Senders), len(b.Receipts))
+	}
+
+	txs := make(types.Transactions, len(b.Txs))
+	for i, enc := range b.Txs {
+		txn, err := types.DecodeTransaction(enc)
+		if err != nil {
+			return fmt.Errorf("decode transaction %d: %w", i, err)
+		}
+		txn.SetSender(b.Senders[i])
+		txs[i] = txn
+	}
+	if root := types.DeriveSha(txs); root != b.Header.TxHash {
+		return fmt.Errorf("transactions root mismatch: %x != %x", root, b.Header.TxHash)
+	}
+	receipts := make(types.Receipts, len(b.Receipts))
+	for i, r := range b.Receipts {
+		receipt, err := r.ToReceipt(txs[i], i)
+		if err != nil {
+			return fmt.Errorf("receipt %d: %w", i, err)
+		}
+		receipts[i] = receipt
+	}
+	if root := types.DeriveSha(receipts); root != b.Header.ReceiptHash {
+		return fmt.Errorf("receipts root mismatch: %x != %x", root, b.Header.ReceiptHash)
+	}
+
+	block := types.NewBlockFromStorage(b.Header.Hash(), b.Header, txs, nil, nil)
+	if err := imp.writeBlock(ctx, tx, block, b.Senders); err != nil {
+		return err
+	}
+	if err := rawdb.WriteReceipts(tx, imp.next, receipts); err != nil {
+		return err
+	}
+	for i, meta := range b.Meta {
+		r := b.Receipts[i]
+		meta.L1GasPrice, meta.L1GasUsed, meta.L1Fee, meta.FeeScalar = r.L1GasPrice, r.L1GasUsed, r.L1Fee, r.FeeScalar
+		if err := WriteTxMeta(tx, imp.next, i, meta); err != nil {
+			return err
+		}
+	}
+	if err := imp.writeChanges(tx, b); err != nil {
+		return err
+	}
+	imp.parentHash = block.Hash()
+	return nil
+}
+
+// writeBlock writes the block unless it is already known.
+func (imp *importer) writeBlock(ctx context.Context, tx kv.RwTx, block *types.Block, senders []libcommon.Address) error {
+	number, hash := block.NumberU64(), block.Hash()
+	imp.td.Add(imp.td, block.Difficulty())
+
+	canonical, err := imp.blockReader.CanonicalHash(ctx, tx, number)
+	if err != nil {
+		return err
+	}
+	if canonical == hash {
+		return nil
+	}
+	if canonical != (libcommon.Hash{}) {
+		return fmt.Errorf("block hash mismatch with the canonical chain: %x != %x", hash, canonical)
+	}
+	if err := rawdb.WriteHeader(tx, block.HeaderNoCopy()); err != nil {
+		return err
+	}
+	if err := rawdb.WriteCanonicalHash(tx, hash, number); err != nil {
+		return err
+	}
+	if err := rawdb.WriteTd(tx, hash, number, imp.td); err != nil {
+		return err
+	}
+	if err := rawdb.WriteBody(tx, hash, number, block.Body()); err != nil {
+		return err
+	}
+	if err := rawdb.WriteSenders(tx, hash, number, senders); err != nil {
+		return err
+	}
+	rawdb.WriteTxLookupEntries(tx, block)
+	return nil
+}
+
+// writeChanges writes the changesets of the block, the code it deployed and
+// collects the post values and history index entries.
+func (imp *importer) writeChanges(tx kv.RwTx, b *Block) error {
+	blockNum := hexutility.EncodeTs(imp.next)
+
+	accountChanges := historyv2.NewAccountChangeSet()
+	for _, change := range b.Accounts {
+		if err := accountChanges.Add(change.Address.Bytes(), encodeAccount(change.Prev)); err != nil {
+			return err
+		}
+		if len(change.Code) > 0 {
+			codeHash := crypto.Keccak256Hash(change.Code)
+			if change.Post == nil || change.Post.CodeHash != codeHash {
+				return fmt.Errorf("code of %x doesn't match its code hash", change.Address)
+			}
+			if err := tx.Put(kv.Code, codeHash[:], change.Code); err != nil {
+				return err
+			}
+		}
+		if err := imp.accounts.Collect(append(change.Address.Bytes(), blockNum...), encodeAccount(change.Post)); err != nil {
+			return err
+		}
+		if err := imp.accountIndex.Collect(change.Address.Bytes(), blockNum); err != nil {
+			return err
+		}
+	}
+	if err := historyv2.EncodeAccounts(imp.next, accountChanges, func(k, v []byte) error {
+		return tx.Put(kv.AccountChangeSet, k, v)
+	}); err != nil {
+		return err
+	}
+
+	storageChanges := historyv2.NewStorageChangeSet()
+	for _, change := range b.Storage {
+		key := dbutils.PlainGenerateCompositeStorageKey(change.Address.Bytes(), change.Incarnation, change.Key.Bytes())
+		if err := storageChanges.Add(key, normalizeStorage(change.Prev)); err != nil {
+			return err
+		}
+		if err := imp.storage.Collect(append(libcommon.Copy(key), blockNum...), normalizeStorage(change.Post)); err != nil {
+			return err
+		}
+		if err := imp.storageIndex.Collect(dbutils.CompositeKeyWithoutIncarnation(key), blockNum); err != nil {
+			return err
+		}
+	}
+	return historyv2.EncodeStorage(imp.next, storageChanges, func(k, v []byte) error {
+		return tx.Put(kv.StorageChangeSet, k, v)
+	})
+}
+
+func normalizeStorage(v []byte) []byte {
+	return new(uint256.Int).SetBytes(v).Bytes()
+}
+
+// writeMigration writes the changeset of the Bedrock block: every key holds
+// its final legacy value before Bedrock, which is empty for the keys the
+// migration created. Then it merges the history indices.
+func (imp *importer) writeMigration(ctx context.Context, tx kv.RwTx) error {
+	if header, err := imp.blockReader.HeaderByNumber(ctx, tx, imp.bedrock); err != nil {
+		return err
+	} else if header != nil && header.ParentHash != imp.parentHash {
+		return fmt.Errorf("the last legacy block %x is not the parent of the Bedrock block %x", imp.parentHash, header.ParentHash)
+	}
+
+	accountsC, err := tx.RwCursorDupSort(kv.AccountChangeSet)
+	if err != nil {
+		return err
+	}
+	defer accountsC.Close()
+	storageC, err := tx.RwCursorDupSort(kv.StorageChangeSet)
+	if err != nil {
+		return err
+	}
+	defer storageC.Close()
+	bedrockNum := hexutility.EncodeTs(imp.bedrock)
+
+	putAccount := func(key, value []byte) error {
+		if v, err := accountsC.SeekBothRange(bedrockNum, key); err != nil {
+			return err
+		} else if bytes.HasPrefix(v, key) {
+			return nil
+		}
+		if err := tx.Put(kv.AccountChangeSet, bedrockNum, append(libcommon.Copy(key), value...)); err != nil {
+			return err
+		}
+		return imp.accountIndex.Collect(key, bedrockNum)
+	}
+	putStorage := func(key, value []byte) error {
+		if _, err := historyv2.FindStorage(storageC, imp.bedrock, key); err == nil {
+			return nil
+		} else if !errors.Is(err, historyv2.ErrNotFound) {
+			return err
+		}
+		k := append(libcommon.Copy(bedrockNum), key[:length.Addr+length.Incarnation]...)
+		if err := tx.Put(kv.StorageChangeSet, k, append(libcommon.Copy(key[length.Addr+length.Incarnation:]), value...)); err != nil {
+			return err
+		}
+		return imp.storageIndex.Collect(dbutils.CompositeKeyWithoutIncarnation(key), bedrockNum)
+	}
+
+	// the final legacy values are the last collected value of each key
+	if err := loadLast(tx, imp.accounts, putAccount); err != nil {
+		return err
+	}
+	if err := loadLast(tx, imp.storage, putStorage); err != nil {
+		return err
+	}
+
+	// the keys which only exist since the migration, or were created later
+	plainC, err := tx.Cursor(kv.PlainState)
+	if err != nil {
+		return err
+	}
+	defer plainC.Close()
+	for k, _, err := plainC.First(); k != nil; k, _, err = plainC.Next() {
+		if err != nil {
+			return err
+		}
+		if len(k) == length.Addr {
+			err = putAccount(libcommon.Copy(k), []byte{})
+		} else {
+			err = putStorage(libcommon.Copy(k), nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := loadIndex(tx, kv.E2AccountsHistory, imp.accountIndex); err != nil {
+		return err
+	}
+	return loadIndex(tx, kv.E2StorageHistory, imp.storageIndex)
+}
+
+// loadLast calls put with the last value of every key of the collector, whose
+// keys are suffixed with the block number.
+func loadLast(tx kv.RwTx, collector *etl.Collector, put func(key, value []byte) error) error {
+	var lastKey, lastValue []byte
+	if err := collector.Load(tx, "", func(k, v []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+		key := k[:len(k)-length.BlockNum]
+		if lastKey != nil && !bytes.Equal(key, lastKey) {
+			if err := put(lastKey, lastValue); err != nil {
+				return err
+			}
+		}
+		lastKey, lastValue = libcommon.Copy(key), libcommon.Copy(v)
+		return nil
+	}, etl.TransformArgs{}); err != nil {
+		return err
+	}
+	if lastKey == nil {
+		return nil
+	}
+	return put(lastKey, lastValue)
+}
+
+// loadIndex merges the collected block numbers into the history index, the
+// legacy blocks precede the existing chunks hence the whole bitmap is rewritten.
+func loadIndex(tx kv.RwTx, bucket string, collector *etl.Collector) error {
+	var lastKey []byte
+	bm := roaring64.New()
+	if err := collector.Load(tx, "", func(k, v []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+		if lastKey != nil && !bytes.Equal(k, lastKey) {
+			if err := mergeIndex(tx, bucket, lastKey, bm); err != nil {
+				return err
+			}
+			bm.Clear()
+		}
+		lastKey = libcommon.Copy(k)
+		bm.Add(binary.BigEndian.Uint64(v))
+		return nil
+	}, etl.TransformArgs{}); err != nil {
+		return err
+	}
+	if lastKey == nil {
+		return nil
+	}
+	return mergeIndex(tx, bucket, lastKey, bm)
+}
+
+func mergeIndex(tx kv.RwTx, bucket string, key []byte, bm *roaring64.Bitmap) error {
+	existing, err := bitmapdb.Get64(tx, bucket, key, 0, math.MaxUint64)
+	if err != nil {
+		return err
+	}
+	bm.Or(existing)
+
+	c, err := tx.Cursor(bucket)
+	if err != nil {
+		return err
+	}
+	var chunks [][]byte
+	for k, _, err := c.Seek(key); k != nil; k, _, err = c.Next() {
+		if err != nil {
+			c.Close()
+			return err
+		}
+		if !bytes.HasPrefix(k, key) || len(k) != len(key)+8 {
+			break
+		}
+		chunks = append(chunks, libcommon.Copy(k))
+	}
+	c.Close()
+	for _, k := range chunks {
+		if err := tx.Delete(bucket, k); err != nil {
+			return err
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	return bitmapdb.WalkChunkWithKeys64(key, bm, bitmapdb.ChunkLimit, func(chunkKey []byte, chunk *roaring64.Bitmap) error {
+		buf.Reset()
+		if _, err := chunk.WriteTo(buf); err != nil {
+			return err
+		}
+		return tx.Put(bucket, chunkKey, libcommon.Copy(buf.Bytes()))
+	})
+}
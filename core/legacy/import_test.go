@@ -0,0 +1,175 @@
+package legacy_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/legacy"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/snapshotsync/freezeblocks"
+)
+
+var (
+	alice     = libcommon.HexToAddress("0xa1")
+	contract  = libcommon.HexToAddress("0xc1")
+	predeploy = libcommon.HexToAddress("0x4200000000000000000000000000000000000042")
+	slot      = libcommon.HexToHash("0x01")
+	code      = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+)
+
+func legacyBlock(t *testing.T, parent libcommon.Hash, number int64, txs [][]byte, records []*legacy.Receipt) *legacy.Block {
+	t.Helper()
+	b := &legacy.Block{Txs: txs, Receipts: records}
+	decoded := make(types.Transactions, len(txs))
+	receipts := make(types.Receipts, len(txs))
+	for i, enc := range txs {
+		txn, err := types.DecodeTransaction(enc)
+		require.NoError(t, err)
+		decoded[i] = txn
+		receipts[i], err = records[i].ToReceipt(txn, i)
+		require.NoError(t, err)
+		b.Meta = append(b.Meta, &legacy.TxMeta{QueueOrigin: legacy.QueueOriginL1, L1TxOrigin: &alice, L1BlockNumber: big.NewInt(100), L1Timestamp: 1000})
+		b.Senders = append(b.Senders, alice)
+	}
+	b.Header = &types.Header{
+		ParentHash:  parent,
+		Number:      big.NewInt(number),
+		Difficulty:  big.NewInt(2),
+		TxHash:      types.DeriveSha(decoded),
+		ReceiptHash: types.DeriveSha(receipts),
+		UncleHash:   types.EmptyUncleHash,
+	}
+	return b
+}
+
+func balance(v uint64) *legacy.Account {
+	return &legacy.Account{Balance: uint256.NewInt(v)}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	db := memdb.NewTestDB(t)
+	chainConfig := &chain.Config{ChainID: big.NewInt(288), BedrockBlock: big.NewInt(3), Optimism: &chain.OptimismConfig{}}
+	blockReader := freezeblocks.NewBlockReader(freezeblocks.NewRoSnapshots(ethconfig.BlocksFreezing{Enabled: false}, t.TempDir(), 0, log.New()), freezeblocks.NewBorRoSnapshots(ethconfig.BlocksFreezing{Enabled: false}, t.TempDir(), 0, log.New()))
+
+	// the state after the Bedrock migration
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		for addr, v := range map[libcommon.Address]uint64{alice: 5000, predeploy: 1} {
+			acc := accounts.NewAccount()
+			acc.Balance.SetUint64(v)
+			enc := make([]byte, acc.EncodingLengthForStorage())
+			acc.EncodeForStorage(enc)
+			if err := tx.Put(kv.PlainState, addr[:], enc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	genesis := legacyBlock(t, libcommon.Hash{}, 0, nil, nil)
+	genesis.Accounts = []*legacy.AccountChange{{Address: alice, Post: balance(100)}}
+
+	txn, err := rlp.EncodeToBytes(types.NewTransaction(0, contract, uint256.NewInt(10), 21000, uint256.NewInt(1), nil))
+	require.NoError(t, err)
+	block1 := legacyBlock(t, genesis.Header.Hash(), 1, [][]byte{txn}, []*legacy.Receipt{{
+		PostStateOrStatus: []byte{1},
+		CumulativeGasUsed: 21000,
+		GasUsed:           21000,
+		L1GasPrice:        big.NewInt(7),
+		L1GasUsed:         big.NewInt(1000),
+		L1Fee:             big.NewInt(7000),
+		FeeScalar:         "1.5",
+	}})
+	codeHash := crypto.Keccak256Hash(code)
+	block1.Accounts = []*legacy.AccountChange{
+		{Address: alice, Prev: balance(100), Post: balance(90)},
+		{Address: contract, Post: &legacy.Account{Balance: uint256.NewInt(10), CodeHash: codeHash, Incarnation: 1}, Code: code},
+	}
+	block1.Storage = []*legacy.StorageChange{{Address: contract, Incarnation: 1, Key: slot, Post: []byte{0x2a}}}
+
+	block2 := legacyBlock(t, block1.Header.Hash(), 2, nil, nil)
+	block2.Accounts = []*legacy.AccountChange{{Address: alice, Prev: balance(90), Post: balance(80)}}
+
+	var export bytes.Buffer
+	for _, b := range []*legacy.Block{genesis, block1, block2} {
+		require.NoError(t, rlp.Encode(&export, b))
+	}
+	require.NoError(t, legacy.Import(ctx, db, blockReader, chainConfig, &export, t.TempDir(), log.New()))
+
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	hash, err := rawdb.ReadCanonicalHash(tx, 1)
+	require.NoError(t, err)
+	require.Equal(t, block1.Header.Hash(), hash)
+	receipts := rawdb.ReadRawReceipts(tx, 1)
+	require.Len(t, receipts, 1)
+	require.NoError(t, legacy.SetReceiptsL1Fee(tx, 1, receipts))
+	require.Equal(t, "1.5", receipts[0].FeeScalar.String())
+	require.Equal(t, big.NewInt(7000), receipts[0].L1Fee)
+	meta, err := legacy.ReadTxMeta(tx, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, "l1", meta.QueueOriginString())
+	require.Equal(t, alice, *meta.L1TxOrigin)
+	rpcMeta, err := json.Marshal(meta.RPC())
+	require.NoError(t, err)
+	require.JSONEq(t, `{"queueOrigin":"l1","l1TxOrigin":"`+strings.ToLower(alice.Hex())+`","l1BlockNumber":"0x64","l1Timestamp":"0x3e8"}`, string(rpcMeta))
+
+	for _, tt := range []struct {
+		block               uint64 // state after this block
+		alice               uint64
+		contract, predeploy bool
+	}{
+		{0, 100, false, false},
+		{1, 90, true, false},
+		{2, 80, true, false},
+		{3, 5000, false, true},
+	} {
+		r := state.NewPlainState(tx, tt.block+1, nil)
+		acc, err := r.ReadAccountData(alice)
+		require.NoError(t, err)
+		require.Equal(t, tt.alice, acc.Balance.Uint64(), "block %d", tt.block)
+		acc, err = r.ReadAccountData(predeploy)
+		require.NoError(t, err)
+		require.Equal(t, tt.predeploy, acc != nil, "block %d", tt.block)
+		acc, err = r.ReadAccountData(contract)
+		require.NoError(t, err)
+		require.Equal(t, tt.contract, acc != nil, "block %d", tt.block)
+		if tt.contract {
+			require.Equal(t, codeHash, acc.CodeHash)
+			c, err := r.ReadAccountCode(contract, acc.Incarnation, acc.CodeHash)
+			require.NoError(t, err)
+			require.Equal(t, code, c)
+			v, err := r.ReadAccountStorage(contract, acc.Incarnation, &slot)
+			require.NoError(t, err)
+			require.Equal(t, []byte{0x2a}, v)
+		}
+	}
+
+	available, err := legacy.StateAvailable(tx, 2)
+	require.NoError(t, err)
+	require.True(t, available)
+	available, err = legacy.StateAvailable(tx, 3)
+	require.NoError(t, err)
+	require.False(t, available)
+}
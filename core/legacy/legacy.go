@@ -0,0 +1,275 @@
+// Package legacy imports the pre-Bedrock history of an OP Stack chain (blocks,
+// receipts and state) from an export of the legacy l2geth node, so that the
+// legacy heights are served without the historical RPC relay.
+package legacy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/rlp"
+)
+
+// Queue origins of the legacy transactions
+const (
+	QueueOriginSequencer = 0
+	QueueOriginL1        = 1
+)
+
+// Block is the export record of a legacy block: the block itself, the legacy
+// metadata and senders of its transactions, its receipts and the state it
+// changed. The export is a stream of RLP encoded records in block order.
+type Block struct {
+	Header   *types.Header
+	Txs      [][]byte // transactions in their legacy RLP encoding
+	Meta     []*TxMeta
+	Senders  []libcommon.Address
+	Receipts []*Receipt
+	Accounts []*AccountChange
+	Storage  []*StorageChange
+}
+
+// TxMeta is the L1 metadata l2geth kept next to each transaction. L1 to L2
+// messages (QueueOriginL1) are not signed, their sender is the L1TxOrigin.
+type TxMeta struct {
+	QueueOrigin   uint64
+	L1TxOrigin    *libcommon.Address `rlp:"nil"`
+	L1BlockNumber *big.Int           `rlp:"nil"`
+	L1Timestamp   uint64
+	QueueIndex    *uint64 `rlp:"nil"`
+
+	// The L1 fee of the legacy receipt, filled by the import: the receipts
+	// storage doesn't keep these fields.
+	L1GasPrice *big.Int `rlp:"optional"`
+	L1GasUsed  *big.Int `rlp:"optional"`
+	L1Fee      *big.Int `rlp:"optional"`
+	FeeScalar  string   `rlp:"optional"`
+}
+
+// QueueOriginString returns the name of the queue origin as reported by l2geth.
+func (m *TxMeta) QueueOriginString() string {
+	if m.QueueOrigin == QueueOriginL1 {
+		return "l1"
+	}
+	return "sequencer"
+}
+
+// RPCTxMeta holds the fields l2geth added to the RPC representation of a
+// transaction, embedded in the RPC transactions of the legacy heights.
+type RPCTxMeta struct {
+	QueueOrigin   *string            `json:"queueOrigin,omitempty"`
+	L1TxOrigin    *libcommon.Address `json:"l1TxOrigin,omitempty"`
+	L1BlockNumber *hexutil.Big       `json:"l1BlockNumber,omitempty"`
+	L1Timestamp   *hexutil.Uint64    `json:"l1Timestamp,omitempty"`
+	QueueIndex    *hexutil.Uint64    `json:"queueIndex,omitempty"`
+}
+
+// RPC returns the fields of the metadata in the RPC representation of the
+// transaction.
+func (m *TxMeta) RPC() *RPCTxMeta {
+	queueOrigin := m.QueueOriginString()
+	l1Timestamp := hexutil.Uint64(m.L1Timestamp)
+	meta := &RPCTxMeta{
+		QueueOrigin:   &queueOrigin,
+		L1TxOrigin:    m.L1TxOrigin,
+		L1BlockNumber: (*hexutil.Big)(m.L1BlockNumber),
+		L1Timestamp:   &l1Timestamp,
+	}
+	if m.QueueIndex != nil {
+		queueIndex := hexutil.Uint64(*m.QueueIndex)
+		meta.QueueIndex = &queueIndex
+	}
+	return meta
+}
+
+// Receipt is the export record of a legacy receipt, including the L1 fee
+// fields of the OVM.
+type Receipt struct {
+	PostStateOrStatus []byte
+	CumulativeGasUsed uint64
+	Logs              []*types.Log
+	GasUsed           uint64
+	ContractAddress   libcommon.Address
+	L1GasPrice        *big.Int `rlp:"nil"`
+	L1GasUsed         *big.Int `rlp:"nil"`
+	L1Fee             *big.Int `rlp:"nil"`
+	FeeScalar         string   // decimal, as reported by l2geth
+}
+
+// Account is the legacy state of an account. The balance is the native
+// balance as reported by eth_getBalance of the legacy node.
+type Account struct {
+	Nonce       uint64
+	Balance     *uint256.Int
+	CodeHash    libcommon.Hash
+	Incarnation uint64
+}
+
+// AccountChange is a change of an account in a block. A nil Prev or Post means
+// the account did not exist before or after the block. Code is set when the
+// block deployed the code of Post.
+type AccountChange struct {
+	Address libcommon.Address
+	Prev    *Account `rlp:"nil"`
+	Post    *Account `rlp:"nil"`
+	Code    []byte
+}
+
+// StorageChange is a change of a storage slot in a block.
+type StorageChange struct {
+	Address     libcommon.Address
+	Incarnation uint64
+	Key         libcommon.Hash
+	Prev        []byte
+	Post        []byte
+}
+
+// ToReceipt converts the export record into a receipt of the transaction.
+func (r *Receipt) ToReceipt(txn types.Transaction, index int) (*types.Receipt, error) {
+	receipt := &types.Receipt{
+		Type:              txn.Type(),
+		CumulativeGasUsed: r.CumulativeGasUsed,
+		Logs:              r.Logs,
+		TxHash:            txn.Hash(),
+		ContractAddress:   r.ContractAddress,
+		GasUsed:           r.GasUsed,
+		TransactionIndex:  uint(index),
+		L1GasPrice:        r.L1GasPrice,
+		L1GasUsed:         r.L1GasUsed,
+		L1Fee:             r.L1Fee,
+	}
+	switch len(r.PostStateOrStatus) {
+	case 0:
+		receipt.Status = types.ReceiptStatusFailed
+	case 1:
+		receipt.Status = uint64(r.PostStateOrStatus[0])
+	case len(libcommon.Hash{}):
+		receipt.PostState = r.PostStateOrStatus
+	default:
+		return nil, fmt.Errorf("invalid receipt status %x", r.PostStateOrStatus)
+	}
+	if r.FeeScalar != "" {
+		feeScalar, ok := new(big.Float).SetString(r.FeeScalar)
+		if !ok {
+			return nil, fmt.Errorf("invalid fee scalar %q", r.FeeScalar)
+		}
+		receipt.FeeScalar = feeScalar
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, nil
+}
+
+func (a *Account) toAccount() *accounts.Account {
+	acc := accounts.NewAccount()
+	acc.Nonce = a.Nonce
+	if a.Balance != nil {
+		acc.Balance = *a.Balance
+	}
+	acc.CodeHash = a.CodeHash
+	acc.Incarnation = a.Incarnation
+	return &acc
+}
+
+// encodeAccount returns the changeset value of the account, including the
+// code hash: the legacy code of the predeploys differs from the Bedrock one
+// which erigon restores from PlainContractCode otherwise.
+func encodeAccount(a *Account) []byte {
+	if a == nil {
+		return []byte{}
+	}
+	acc := a.toAccount()
+	enc := make([]byte, acc.EncodingLengthForStorage())
+	acc.EncodeForStorage(enc)
+	return enc
+}
+
+func txMetaKey(blockNum uint64, txIndex int) []byte {
+	k := make([]byte, 12)
+	binary.BigEndian.PutUint64(k, blockNum)
+	binary.BigEndian.PutUint32(k[8:], uint32(txIndex))
+	return k
+}
+
+// ReadTxMeta returns the legacy metadata of a transaction, nil if the block was
+// not imported from a legacy export.
+func ReadTxMeta(tx kv.Getter, blockNum uint64, txIndex int) (*TxMeta, error) {
+	v, err := tx.GetOne(kv.LegacyTxMeta, txMetaKey(blockNum, txIndex))
+	if err != nil {
+		return nil, err
+	}
+	if len(v) == 0 {
+		return nil, nil
+	}
+	meta := &TxMeta{}
+	if err := rlp.DecodeBytes(v, meta); err != nil {
+		return nil, fmt.Errorf("decode legacy tx meta %d/%d: %w", blockNum, txIndex, err)
+	}
+	return meta, nil
+}
+
+// WriteTxMeta stores the legacy metadata of a transaction.
+func WriteTxMeta(tx kv.Putter, blockNum uint64, txIndex int, meta *TxMeta) error {
+	v, err := rlp.EncodeToBytes(meta)
+	if err != nil {
+		return err
+	}
+	return tx.Put(kv.LegacyTxMeta, txMetaKey(blockNum, txIndex), v)
+}
+
+// SetReceiptsL1Fee restores the L1 fee fields of the receipts of an imported
+// legacy block.
+func SetReceiptsL1Fee(tx kv.Getter, blockNum uint64, receipts types.Receipts) error {
+	for i, r := range receipts {
+		meta, err := ReadTxMeta(tx, blockNum, i)
+		if err != nil {
+			return err
+		}
+		if meta == nil {
+			return nil
+		}
+		r.L1GasPrice, r.L1GasUsed, r.L1Fee = meta.L1GasPrice, meta.L1GasUsed, meta.L1Fee
+		if meta.FeeScalar != "" {
+			if r.FeeScalar, _ = new(big.Float).SetString(meta.FeeScalar); r.FeeScalar == nil {
+				return fmt.Errorf("invalid fee scalar %q", meta.FeeScalar)
+			}
+		}
+	}
+	return nil
+}
+
+var stateImportedKey = []byte("LegacyStateImported")
+
+// ReadStateImported returns the last legacy block whose state was imported.
+func ReadStateImported(tx kv.Getter) (uint64, bool, error) {
+	v, err := tx.GetOne(kv.DatabaseInfo, stateImportedKey)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(v) != 8 {
+		return 0, false, nil
+	}
+	return binary.BigEndian.Uint64(v), true, nil
+}
+
+func writeStateImported(tx kv.Putter, blockNum uint64) error {
+	return tx.Put(kv.DatabaseInfo, stateImportedKey, binary.BigEndian.AppendUint64(nil, blockNum))
+}
+
+// StateAvailable reports whether the legacy state at the given height was
+// imported and can be read from the state history.
+func StateAvailable(tx kv.Getter, blockNum uint64) (bool, error) {
+	imported, ok, err := ReadStateImported(tx)
+	if err != nil || !ok {
+		return false, err
+	}
+	return blockNum <= imported, nil
+}
@@ -121,6 +121,73 @@ func ResetBadBlockCache(tx kv.Tx, limit int) error {
 
 /* latest bad blocks end */
 
+// WriteBadBlockForensics stores the forensics bundle of a rejected block, and prunes the oldest bundles to keep
+// the latest limit of them.
+func WriteBadBlockForensics(tx kv.RwTx, hash common.Hash, number uint64, bundle []byte, limit int) error {
+	if err := tx.Put(kv.BadBlockForensics, dbutils.HeaderKey(number, hash), bundle); err != nil {
+		return fmt.Errorf("failed to store bad block forensics: %w", err)
+	}
+	c, err := tx.RwCursor(kv.BadBlockForensics)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	count, err := c.Count()
+	if err != nil {
+		return err
+	}
+	for k, _, err := c.First(); k != nil && count > uint64(limit); k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteCurrent(); err != nil {
+			return err
+		}
+		count--
+	}
+	return nil
+}
+
+// ReadBadBlockForensics retrieves the forensics bundle of a rejected block, nil if there is none. The number is
+// resolved from the header of the block, or from the keys of the bundles when the header was purged with the bad
+// chain or never stored (payloads rejected before their validation).
+func ReadBadBlockForensics(tx kv.Tx, hash common.Hash) ([]byte, error) {
+	number := ReadHeaderNumber(tx, hash)
+	if number == nil {
+		var err error
+		if number, err = ReadBadHeaderNumber(tx, hash); err != nil {
+			return nil, err
+		}
+	}
+	if number != nil {
+		v, err := tx.GetOne(kv.BadBlockForensics, dbutils.HeaderKey(*number, hash))
+		if err != nil || v != nil {
+			return common.CopyBytes(v), err
+		}
+	}
+	c, err := tx.Cursor(kv.BadBlockForensics)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	for k, v, err := c.First(); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return nil, err
+		}
+		if common.BytesToHash(k[8:]) == hash {
+			return common.CopyBytes(v), nil
+		}
+	}
+	return nil, nil
+}
+
+// ForEachBadBlockForensics iterates the stored forensics bundles by block number.
+func ForEachBadBlockForensics(tx kv.Tx, walker func(number uint64, hash common.Hash, bundle []byte) error) error {
+	return tx.ForEach(kv.BadBlockForensics, nil, func(k, v []byte) error {
+		return walker(binary.BigEndian.Uint64(k), common.BytesToHash(k[8:]), v)
+	})
+}
+
 func IsCanonicalHash(db kv.Getter, hash common.Hash, number uint64) (bool, error) {
 	canonicalHash, err := ReadCanonicalHash(db, number)
 	if err != nil {
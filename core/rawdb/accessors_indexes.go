@@ -17,6 +17,9 @@
 package rawdb
 
 import (
+	"encoding/binary"
+	"fmt"
+	"math"
 	"math/big"
 
 	libcommon "github.com/erigontech/erigon-lib/common"
@@ -63,3 +66,55 @@ func WriteTxLookupEntries(db kv.Putter, block *types.Block) {
 func DeleteTxLookupEntry(db kv.Deleter, hash libcommon.Hash) error {
 	return db.Delete(kv.TxLookup, hash.Bytes())
 }
+
+// DepositLookupEntry locates a deposit transaction of an OP Stack chain, on L2
+// and on L1.
+type DepositLookupEntry struct {
+	BlockNumber   uint64
+	TxIndex       uint64
+	L1BlockNumber uint64 // of the L1 origin of the block, 0 if unknown
+	L1BlockHash   libcommon.Hash
+	L1LogIndex    *uint64 // of the TransactionDeposited log of a user deposit, nil otherwise
+}
+
+const depositLookupEntryLength = 8 + 4 + 8 + 32 + 8
+
+// EncodeDepositLookupEntry encodes the value of the entry in kv.DepositLookup.
+func EncodeDepositLookupEntry(entry *DepositLookupEntry) []byte {
+	data := make([]byte, depositLookupEntryLength)
+	binary.BigEndian.PutUint64(data, entry.BlockNumber)
+	binary.BigEndian.PutUint32(data[8:], uint32(entry.TxIndex))
+	binary.BigEndian.PutUint64(data[12:], entry.L1BlockNumber)
+	copy(data[20:52], entry.L1BlockHash[:])
+	logIndex := uint64(math.MaxUint64)
+	if entry.L1LogIndex != nil {
+		logIndex = *entry.L1LogIndex
+	}
+	binary.BigEndian.PutUint64(data[52:], logIndex)
+	return data
+}
+
+// ReadDepositLookupEntry retrieves the location of the deposit transaction
+// of the given source hash, nil if not found.
+func ReadDepositLookupEntry(db kv.Getter, sourceHash libcommon.Hash) (*DepositLookupEntry, error) {
+	data, err := db.GetOne(kv.DepositLookup, sourceHash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data) != depositLookupEntryLength {
+		return nil, fmt.Errorf("invalid deposit lookup entry of %x: %d bytes", sourceHash, len(data))
+	}
+	entry := &DepositLookupEntry{
+		BlockNumber:   binary.BigEndian.Uint64(data),
+		TxIndex:       uint64(binary.BigEndian.Uint32(data[8:])),
+		L1BlockNumber: binary.BigEndian.Uint64(data[12:]),
+		L1BlockHash:   libcommon.BytesToHash(data[20:52]),
+	}
+	if logIndex := binary.BigEndian.Uint64(data[52:]); logIndex != math.MaxUint64 {
+		entry.L1LogIndex = &logIndex
+	}
+	return entry, nil
+}
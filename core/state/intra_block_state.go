@@ -89,6 +89,7 @@ type IntraBlockState struct {
 	journal        *journal
 	validRevisions []revision
 	nextRevisionID int
+	holdJournal    bool // the journal is kept across transactions, see HoldJournal
 	trace          bool
 	balanceInc     map[libcommon.Address]*BalanceIncrease // Map of balance increases (without first reading the account)
 }
@@ -724,11 +725,29 @@ func (sdb *IntraBlockState) FinalizeTx(chainRules *chain.Rules, stateWriter Stat
 		so.newlyCreated = false
 		sdb.stateObjectsDirty[addr] = struct{}{}
 	}
+	if sdb.holdJournal {
+		sdb.refund = 0
+		return nil
+	}
 	// Invalidate journal because reverting across transactions is not allowed.
 	sdb.clearJournalAndRefund()
 	return nil
 }
 
+// HoldJournal keeps the journal across the transactions finalized until
+// ReleaseJournal, so that a snapshot taken before them reverts them as a
+// whole. The block builder includes the bundles atomically this way: the
+// changes written by FinalizeTx meanwhile are not reverted.
+func (sdb *IntraBlockState) HoldJournal() {
+	sdb.holdJournal = true
+}
+
+// ReleaseJournal stops keeping the journal across transactions, and clears it.
+func (sdb *IntraBlockState) ReleaseJournal() {
+	sdb.holdJournal = false
+	sdb.clearJournalAndRefund()
+}
+
 // CommitBlock finalizes the state by removing the self destructed objects
 // and clears the journal as well as the refunds.
 func (sdb *IntraBlockState) CommitBlock(chainRules *chain.Rules, stateWriter StateWriter) error {
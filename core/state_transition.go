@@ -395,7 +395,12 @@ func (st *StateTransition) innerTransitionDb(refunds bool, gasBailout bool) (*ev
 	coinbase := st.evm.Context.Coinbase
 
 	senderInitBalance := st.state.GetBalance(st.msg.From()).Clone()
-	coinbaseInitBalance := st.state.GetBalance(coinbase).Clone()
+	// The parallel block building doesn't load the coinbase, so that the transactions which
+	// don't use it only add to its balance, see exec3.MiningWorker
+	var coinbaseInitBalance *uint256.Int
+	if !st.evm.Config().LazyCoinbase || st.evm.Context.PostApplyMessage != nil {
+		coinbaseInitBalance = st.state.GetBalance(coinbase).Clone()
+	}
 
 	// First check this message satisfies all consensus rules before
 	// applying the message. The rules include these clauses
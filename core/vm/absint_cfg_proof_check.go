@@ -207,7 +207,7 @@ func postCheck(sem *CfgAbsSem, code []byte, st0 *astate, pc0 int, pc1 int, isJum
 func CheckCfg(code []byte, proof *CfgProof) bool {
 	sem := NewCfgAbsSem()
 
-	if !proof.isValid() {
+	if hasEOFMagic(code) || !proof.isValid() {
 		return false
 	}
 
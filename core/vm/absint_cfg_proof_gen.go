@@ -640,6 +640,11 @@ func GenCfg(code []byte, anlyCounterLimit int, maxStackLen int, maxStackCount in
 	cfg.Program = program
 	cfg.PrevEdgeMap = make(map[int]map[int]bool)
 
+	// The control flow of EOF code has no dynamic jumps and is checked when it is deployed
+	if hasEOFMagic(code) {
+		return cfg, errors.New("eof code")
+	}
+
 	startPC := 0
 	codeLen := len(program.Code)
 	cfg.D = make(map[int]*astate)
@@ -41,3 +41,39 @@ func codeBitmap(code []byte) []uint64 {
 	}
 	return bits
 }
+
+// eofCodeBitmap collects the immediate data locations in a code section of an
+// EOF container. Unlike legacy code, instructions other than PUSHn carry
+// immediates, and the immediates of RJUMPV have a variable size.
+func eofCodeBitmap(code []byte) []uint64 {
+	bits := make([]uint64, (len(code)+63)/64)
+	for pc := 0; pc < len(code); {
+		size := eofImmediateSize(code, pc)
+		pc++
+		for end := min(pc+size, len(code)); pc < end; pc++ {
+			bits[pc/64] |= 1 << (pc & 63)
+		}
+	}
+	return bits
+}
+
+// eofImmediateSize returns the size of the immediate of the EOF instruction at
+// pc. The immediate may extend past the end of code.
+func eofImmediateSize(code []byte, pc int) int {
+	switch op := OpCode(code[pc]); op {
+	case RJUMP, RJUMPI, CALLF, JUMPF, DATALOADN:
+		return 2
+	case DUPN, SWAPN, EXCHANGE, EOFCREATE, RETURNCODE:
+		return 1
+	case RJUMPV:
+		if pc+1 >= len(code) {
+			return 1
+		}
+		return 1 + 2*(int(code[pc+1])+1)
+	default:
+		if op >= PUSH1 && op <= PUSH32 {
+			return int(op - PUSH1 + 1)
+		}
+		return 0
+	}
+}
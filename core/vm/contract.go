@@ -51,10 +51,14 @@ type Contract struct {
 	analysis      []uint64                    // Locally cached result of JUMPDEST analysis
 	skipAnalysis  bool
 
-	Code     []byte
-	CodeHash libcommon.Hash
-	CodeAddr *libcommon.Address
-	Input    []byte
+	Code      []byte
+	CodeHash  libcommon.Hash
+	CodeAddr  *libcommon.Address
+	Input     []byte
+	Container *Container // the EOF container of the code, nil for legacy code
+
+	section     uint64        // the code section executed in the EOF container
+	returnStack []returnFrame // the callers of the code section in the EOF container
 
 	Gas   uint64
 	value *uint256.Int
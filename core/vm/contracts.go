@@ -225,8 +225,21 @@ func init() {
 	}
 }
 
-// ActivePrecompiles returns the precompiles enabled with the current configuration.
+// ActivePrecompiles returns the precompiles enabled with the current configuration,
+// including the chain-specific ones.
 func ActivePrecompiles(rules *chain.Rules) []libcommon.Address {
+	addresses := forkPrecompiles(rules)
+	if len(rules.Precompiles) == 0 {
+		return addresses
+	}
+	addresses = append(make([]libcommon.Address, 0, len(addresses)+len(rules.Precompiles)), addresses...)
+	for _, p := range rules.Precompiles {
+		addresses = append(addresses, p.Address)
+	}
+	return addresses
+}
+
+func forkPrecompiles(rules *chain.Rules) []libcommon.Address {
 	switch {
 	case rules.IsOptimismGranite:
 		return PrecompiledAddressesGranite
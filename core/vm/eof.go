@@ -0,0 +1,354 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/erigontech/erigon/crypto"
+)
+
+// EVM Object Format (EIP-3540) as bundled by EIP-7692.
+
+const (
+	eofFormatByte = 0xef
+	eofMagicByte  = 0x00
+	eofVersion1   = 0x01
+
+	kindTypes     = 0x01
+	kindCode      = 0x02
+	kindContainer = 0x03
+	kindData      = 0xff
+	terminator    = 0x00
+
+	typeSectionItemSize = 4
+
+	maxCodeSections      = 1024
+	maxContainerSections = 256
+	maxInputItems        = 127
+	maxOutputItems       = 127
+	maxStackHeight       = 1023
+
+	// nonReturningFunction is the outputs value of code sections which never return to their caller.
+	nonReturningFunction = 0x80
+)
+
+var (
+	eofMagic     = []byte{eofFormatByte, eofMagicByte}
+	eofMagicHash = crypto.Keccak256Hash(eofMagic) // the code hash of EOF contracts as seen by legacy code
+)
+
+var (
+	ErrInvalidMagic           = errors.New("invalid magic")
+	ErrInvalidVersion         = errors.New("invalid version")
+	ErrMissingTypeHeader      = errors.New("missing type header")
+	ErrInvalidTypeSize        = errors.New("invalid type section size")
+	ErrMissingCodeHeader      = errors.New("missing code header")
+	ErrInvalidCodeHeader      = errors.New("invalid code header")
+	ErrInvalidContainerHeader = errors.New("invalid container header")
+	ErrMissingDataHeader      = errors.New("missing data header")
+	ErrMissingTerminator      = errors.New("missing header terminator")
+	ErrInvalidSectionSize     = errors.New("invalid section size")
+	ErrTooManyInputs          = errors.New("invalid type content, too many inputs")
+	ErrTooManyOutputs         = errors.New("invalid type content, too many outputs")
+	ErrInvalidSection0Type    = errors.New("invalid section 0 type, input and output should be zero and non-returning (0x80)")
+	ErrTooLargeMaxStackHeight = errors.New("invalid type content, max stack height exceeds limit")
+	ErrInvalidContainerSize   = errors.New("invalid container size")
+	ErrTruncatedData          = errors.New("truncated data section")
+)
+
+// hasEOFMagic reports whether code starts with the EOF magic, which no legacy
+// contract can since EIP-3541.
+func hasEOFMagic(code []byte) bool {
+	return len(code) >= len(eofMagic) && bytes.Equal(eofMagic, code[:len(eofMagic)])
+}
+
+// isEOFVersion1 reports whether code is an EOF container of version 1.
+func isEOFVersion1(code []byte) bool {
+	return hasEOFMagic(code) && len(code) > 2 && code[2] == eofVersion1
+}
+
+// functionMetadata is an item of the type section.
+type functionMetadata struct {
+	inputs           uint8
+	outputs          uint8
+	maxStackIncrease uint16
+}
+
+func (m *functionMetadata) returning() bool { return m.outputs != nonReturningFunction }
+
+// Container is an EOF container.
+type Container struct {
+	types         []*functionMetadata
+	codeSections  [][]byte
+	subContainers []*Container
+	rawContainers [][]byte // the subcontainers as they were encoded
+	data          []byte
+	dataSize      int // the declared size of the data section, which may exceed len(data) in deploy containers
+}
+
+// parseMode tells how much of the input a container may cover.
+type parseMode int
+
+const (
+	parseExact     parseMode = iota // the container is the whole input and its data section is complete
+	parseTruncated                  // the container is the whole input, but its data section may be truncated
+	parsePrefix                     // the container is followed by unrelated bytes, and its data section is complete
+)
+
+// MarshalBinary encodes the container.
+func (c *Container) MarshalBinary() []byte {
+	b := make([]byte, 0, 64)
+	b = append(b, eofFormatByte, eofMagicByte, eofVersion1)
+	b = append(b, kindTypes)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c.types)*typeSectionItemSize))
+	b = append(b, kindCode)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c.codeSections)))
+	for _, code := range c.codeSections {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(code)))
+	}
+	if len(c.rawContainers) > 0 {
+		b = append(b, kindContainer)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(c.rawContainers)))
+		for _, sub := range c.rawContainers {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(sub)))
+		}
+	}
+	b = append(b, kindData)
+	b = binary.BigEndian.AppendUint16(b, uint16(c.dataSize))
+	b = append(b, terminator)
+
+	for _, ty := range c.types {
+		b = append(b, ty.inputs, ty.outputs)
+		b = binary.BigEndian.AppendUint16(b, ty.maxStackIncrease)
+	}
+	for _, code := range c.codeSections {
+		b = append(b, code...)
+	}
+	for _, sub := range c.rawContainers {
+		b = append(b, sub...)
+	}
+	return append(b, c.data...)
+}
+
+// UnmarshalBinary decodes an EOF container which must span all of b.
+func (c *Container) UnmarshalBinary(b []byte) error {
+	_, err := c.unmarshal(b, parseExact)
+	return err
+}
+
+// parseInitcodePrefix decodes the EOF container at the start of the data of a
+// creation transaction (EIP-7698) and returns the calldata that follows it.
+func parseInitcodePrefix(b []byte) (*Container, []byte, error) {
+	c := new(Container)
+	size, err := c.unmarshal(b, parsePrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, b[size:], nil
+}
+
+// unmarshal decodes a container from b and returns the number of bytes it covers.
+func (c *Container) unmarshal(b []byte, mode parseMode) (int, error) {
+	if !hasEOFMagic(b) {
+		return 0, fmt.Errorf("%w: have %#x", ErrInvalidMagic, b[:min(2, len(b))])
+	}
+	if len(b) < 3 || b[2] != eofVersion1 {
+		return 0, ErrInvalidVersion
+	}
+	r := headerReader{b: b, pos: 3}
+
+	// Type section header.
+	if kind, ok := r.byte(); !ok || kind != kindTypes {
+		return 0, ErrMissingTypeHeader
+	}
+	typesSize, ok := r.uint16()
+	if !ok {
+		return 0, ErrMissingTypeHeader
+	}
+	if typesSize < typeSectionItemSize || typesSize%typeSectionItemSize != 0 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidTypeSize, typesSize)
+	}
+
+	// Code section header.
+	if kind, ok := r.byte(); !ok || kind != kindCode {
+		return 0, ErrMissingCodeHeader
+	}
+	numCode, ok := r.uint16()
+	if !ok || numCode == 0 || numCode > maxCodeSections {
+		return 0, ErrInvalidCodeHeader
+	}
+	if int(numCode) != int(typesSize)/typeSectionItemSize {
+		return 0, fmt.Errorf("%w: mismatch of code sections and types", ErrInvalidCodeHeader)
+	}
+	codeSizes := make([]int, numCode)
+	for i := range codeSizes {
+		size, ok := r.uint16()
+		if !ok {
+			return 0, ErrInvalidCodeHeader
+		}
+		if size == 0 {
+			return 0, fmt.Errorf("%w: code section %d is empty", ErrInvalidSectionSize, i)
+		}
+		codeSizes[i] = int(size)
+	}
+
+	// Optional container section header.
+	kind, ok := r.byte()
+	if !ok {
+		return 0, ErrMissingDataHeader
+	}
+	var containerSizes []int
+	if kind == kindContainer {
+		numContainers, ok := r.uint16()
+		if !ok || numContainers == 0 || numContainers > maxContainerSections {
+			return 0, ErrInvalidContainerHeader
+		}
+		containerSizes = make([]int, numContainers)
+		for i := range containerSizes {
+			size, ok := r.uint32()
+			if !ok {
+				return 0, ErrInvalidContainerHeader
+			}
+			if size == 0 {
+				return 0, fmt.Errorf("%w: container section %d is empty", ErrInvalidSectionSize, i)
+			}
+			containerSizes[i] = int(size)
+		}
+		if kind, ok = r.byte(); !ok {
+			return 0, ErrMissingDataHeader
+		}
+	}
+
+	// Data section header.
+	if kind != kindData {
+		return 0, ErrMissingDataHeader
+	}
+	dataSize, ok := r.uint16()
+	if !ok {
+		return 0, ErrMissingDataHeader
+	}
+	if t, ok := r.byte(); !ok || t != terminator {
+		return 0, ErrMissingTerminator
+	}
+
+	// Type section.
+	types := make([]*functionMetadata, numCode)
+	for i := range types {
+		inputs, _ := r.byte()
+		outputs, _ := r.byte()
+		maxStackIncrease, ok := r.uint16()
+		if !ok {
+			return 0, fmt.Errorf("%w: type section", ErrInvalidSectionSize)
+		}
+		if inputs > maxInputItems {
+			return 0, fmt.Errorf("%w: have %d, section %d", ErrTooManyInputs, inputs, i)
+		}
+		if outputs > maxOutputItems && outputs != nonReturningFunction {
+			return 0, fmt.Errorf("%w: have %d, section %d", ErrTooManyOutputs, outputs, i)
+		}
+		if int(inputs)+int(maxStackIncrease) > maxStackHeight {
+			return 0, fmt.Errorf("%w: have %d, section %d", ErrTooLargeMaxStackHeight, int(inputs)+int(maxStackIncrease), i)
+		}
+		types[i] = &functionMetadata{inputs: inputs, outputs: outputs, maxStackIncrease: maxStackIncrease}
+	}
+	if types[0].inputs != 0 || types[0].returning() {
+		return 0, ErrInvalidSection0Type
+	}
+
+	// Code sections.
+	codeSections := make([][]byte, numCode)
+	for i, size := range codeSizes {
+		code, ok := r.bytes(size)
+		if !ok {
+			return 0, fmt.Errorf("%w: code section %d", ErrInvalidSectionSize, i)
+		}
+		codeSections[i] = code
+	}
+
+	// Container sections.
+	var (
+		subContainers []*Container
+		rawContainers [][]byte
+	)
+	for i, size := range containerSizes {
+		raw, ok := r.bytes(size)
+		if !ok {
+			return 0, fmt.Errorf("%w: container section %d", ErrInvalidSectionSize, i)
+		}
+		sub := new(Container)
+		if _, err := sub.unmarshal(raw, parseTruncated); err != nil {
+			return 0, fmt.Errorf("%w: container section %d: %w", ErrInvalidContainerSize, i, err)
+		}
+		subContainers = append(subContainers, sub)
+		rawContainers = append(rawContainers, raw)
+	}
+
+	// Data section.
+	end := r.pos + int(dataSize)
+	switch mode {
+	case parsePrefix:
+		if end > len(b) {
+			return 0, ErrTruncatedData
+		}
+	case parseExact:
+		if end != len(b) {
+			if end > len(b) {
+				return 0, ErrTruncatedData
+			}
+			return 0, fmt.Errorf("%w: %d trailing bytes", ErrInvalidContainerSize, len(b)-end)
+		}
+	case parseTruncated:
+		if end < len(b) {
+			return 0, fmt.Errorf("%w: %d trailing bytes", ErrInvalidContainerSize, len(b)-end)
+		}
+		end = len(b)
+	}
+
+	c.types = types
+	c.codeSections = codeSections
+	c.subContainers = subContainers
+	c.rawContainers = rawContainers
+	c.data = b[r.pos:end]
+	c.dataSize = int(dataSize)
+	return end, nil
+}
+
+// headerReader reads big-endian integers from an EOF container.
+type headerReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *headerReader) byte() (byte, bool) {
+	if r.pos >= len(r.b) {
+		return 0, false
+	}
+	r.pos++
+	return r.b[r.pos-1], true
+}
+
+func (r *headerReader) uint16() (uint16, bool) {
+	if r.pos+2 > len(r.b) {
+		return 0, false
+	}
+	r.pos += 2
+	return binary.BigEndian.Uint16(r.b[r.pos-2:]), true
+}
+
+func (r *headerReader) uint32() (uint32, bool) {
+	if r.pos+4 > len(r.b) {
+		return 0, false
+	}
+	r.pos += 4
+	return binary.BigEndian.Uint32(r.b[r.pos-4:]), true
+}
+
+func (r *headerReader) bytes(n int) ([]byte, bool) {
+	if r.pos+n > len(r.b) {
+		return nil, false
+	}
+	r.pos += n
+	return r.b[r.pos-n : r.pos], true
+}
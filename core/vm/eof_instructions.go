@@ -0,0 +1,467 @@
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/vm/stack"
+	"github.com/erigontech/erigon/params"
+)
+
+const (
+	gasRjumpi   = 4
+	gasRjumpv   = 4
+	gasDataLoad = 4
+
+	// minRetainedGas is the gas which EXTCALL* leave to the caller at least (EIP-7069).
+	minRetainedGas = 5000
+	// minCalleeGas is the gas below which EXTCALL* don't call but fail.
+	minCalleeGas = 2300
+)
+
+var (
+	ErrInvalidEOFInitcode = errors.New("invalid eof initcode")
+	ErrInvalidAddress     = errors.New("address has non-zero upper bytes")
+	ErrInvalidAuxData     = errors.New("invalid aux data size")
+)
+
+// returnFrame is the caller of a code section entered with CALLF.
+type returnFrame struct {
+	section uint64
+	pc      uint64
+}
+
+// enableEOF turns an instruction set into the one of EOF code (EIP-7692).
+func enableEOF(jt *JumpTable) {
+	undefined := &operation{execute: opUndefined, undefined: true}
+	for _, op := range []OpCode{
+		CALL, CALLCODE, DELEGATECALL, STATICCALL, SELFDESTRUCT, JUMP, JUMPI, PC,
+		CREATE, CREATE2, CODESIZE, CODECOPY, EXTCODESIZE, EXTCODECOPY, EXTCODEHASH, GAS,
+	} {
+		jt[op] = undefined
+	}
+	jt[INVALID] = &operation{execute: opUndefined}
+	jt[RETURNDATACOPY] = &operation{
+		execute:     opReturnDataCopyEOF,
+		constantGas: GasFastestStep,
+		dynamicGas:  gasReturnDataCopy,
+		numPop:      3,
+		numPush:     0,
+		memorySize:  memoryReturnDataCopy,
+	}
+	jt[RJUMP] = &operation{
+		execute:     opRjump,
+		constantGas: GasQuickStep,
+		numPop:      0,
+		numPush:     0,
+	}
+	jt[RJUMPI] = &operation{
+		execute:     opRjumpi,
+		constantGas: gasRjumpi,
+		numPop:      1,
+		numPush:     0,
+	}
+	jt[RJUMPV] = &operation{
+		execute:     opRjumpv,
+		constantGas: gasRjumpv,
+		numPop:      1,
+		numPush:     0,
+	}
+	jt[CALLF] = &operation{
+		execute:     opCallf,
+		constantGas: GasFastStep,
+		numPop:      0,
+		numPush:     0,
+	}
+	jt[RETF] = &operation{
+		execute:     opRetf,
+		constantGas: GasFastestStep,
+		numPop:      0,
+		numPush:     0,
+	}
+	jt[JUMPF] = &operation{
+		execute:     opJumpf,
+		constantGas: GasFastStep,
+		numPop:      0,
+		numPush:     0,
+	}
+	jt[DUPN] = &operation{
+		execute:     opDupN,
+		constantGas: GasFastestStep,
+		numPop:      0,
+		numPush:     1,
+	}
+	jt[SWAPN] = &operation{
+		execute:     opSwapN,
+		constantGas: GasFastestStep,
+		numPop:      0,
+		numPush:     0,
+	}
+	jt[EXCHANGE] = &operation{
+		execute:     opExchange,
+		constantGas: GasFastestStep,
+		numPop:      0,
+		numPush:     0,
+	}
+	jt[DATALOAD] = &operation{
+		execute:     opDataLoad,
+		constantGas: gasDataLoad,
+		numPop:      1,
+		numPush:     1,
+	}
+	jt[DATALOADN] = &operation{
+		execute:     opDataLoadN,
+		constantGas: GasFastestStep,
+		numPop:      0,
+		numPush:     1,
+	}
+	jt[DATASIZE] = &operation{
+		execute:     opDataSize,
+		constantGas: GasQuickStep,
+		numPop:      0,
+		numPush:     1,
+	}
+	jt[DATACOPY] = &operation{
+		execute:     opDataCopy,
+		constantGas: GasFastestStep,
+		dynamicGas:  gasDataCopy,
+		numPop:      3,
+		numPush:     0,
+		memorySize:  memoryDataCopy,
+	}
+	jt[RETURNDATALOAD] = &operation{
+		execute:     opReturnDataLoad,
+		constantGas: GasFastestStep,
+		numPop:      1,
+		numPush:     1,
+	}
+	jt[EOFCREATE] = &operation{
+		execute:     opEOFCreate,
+		constantGas: params.Create2Gas,
+		dynamicGas:  gasCreate,
+		numPop:      4,
+		numPush:     1,
+		memorySize:  memoryEOFCreate,
+	}
+	jt[RETURNCODE] = &operation{
+		execute:    opReturnCode,
+		dynamicGas: pureMemoryGascost,
+		numPop:     2,
+		numPush:    0,
+		memorySize: memoryReturn,
+	}
+	jt[EXTCALL] = &operation{
+		execute:     opExtCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  makeGasExtCall(true),
+		numPop:      4,
+		numPush:     1,
+		memorySize:  memoryExtCall,
+	}
+	jt[EXTDELEGATECALL] = &operation{
+		execute:     opExtDelegateCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  makeGasExtCall(false),
+		numPop:      3,
+		numPush:     1,
+		memorySize:  memoryExtCall,
+	}
+	jt[EXTSTATICCALL] = &operation{
+		execute:     opExtStaticCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  makeGasExtCall(false),
+		numPop:      3,
+		numPush:     1,
+		memorySize:  memoryExtCall,
+	}
+}
+
+var gasDataCopy = memoryCopierGas(2)
+
+func memoryDataCopy(stack *stack.Stack) (uint64, bool) {
+	return calcMemSize64(stack.Back(0), stack.Back(2))
+}
+
+func memoryEOFCreate(stack *stack.Stack) (uint64, bool) {
+	return calcMemSize64(stack.Back(2), stack.Back(3))
+}
+
+func memoryExtCall(stack *stack.Stack) (uint64, bool) {
+	return calcMemSize64(stack.Back(1), stack.Back(2))
+}
+
+// enterSection makes the interpreter loop continue at the start of a code section.
+func enterSection(pc *uint64, scope *ScopeContext, section uint64) {
+	scope.Contract.section = section
+	scope.Contract.Code = scope.Contract.Container.codeSections[section]
+	*pc = math.MaxUint64 // pc will be increased to 0 by the interpreter loop
+}
+
+// checkSectionStack fails if calling or jumping to a code section could overflow the stack.
+func checkSectionStack(scope *ScopeContext, section uint64) error {
+	if sLen, increase := scope.Stack.Len(), int(scope.Contract.Container.types[section].maxStackIncrease); sLen+increase > int(params.StackLimit) {
+		return &ErrStackOverflow{stackLen: sLen, limit: int(params.StackLimit) - increase}
+	}
+	return nil
+}
+
+func opRjump(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	offset := int16(binary.BigEndian.Uint16(scope.Contract.Code[*pc+1:]))
+	// the offset is relative to the end of the immediate, and pc will be increased by the interpreter loop
+	*pc = uint64(int64(*pc) + 2 + int64(offset))
+	return nil, nil
+}
+
+func opRjumpi(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	cond := scope.Stack.Pop()
+	if cond.IsZero() {
+		*pc += 2
+		return nil, nil
+	}
+	return opRjump(pc, interpreter, scope)
+}
+
+func opRjumpv(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		code  = scope.Contract.Code
+		count = uint64(code[*pc+1]) + 1
+		idx   = scope.Stack.Pop()
+	)
+	if idx.LtUint64(count) {
+		offset := int16(binary.BigEndian.Uint16(code[*pc+2+2*idx.Uint64():]))
+		*pc = uint64(int64(*pc) + 1 + int64(2*count) + int64(offset))
+		return nil, nil
+	}
+	*pc += 1 + 2*count
+	return nil, nil
+}
+
+func opCallf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	section := uint64(binary.BigEndian.Uint16(scope.Contract.Code[*pc+1:]))
+	if len(scope.Contract.returnStack) >= int(params.CallCreateDepth) {
+		return nil, ErrReturnStackExceeded
+	}
+	if err := checkSectionStack(scope, section); err != nil {
+		return nil, err
+	}
+	scope.Contract.returnStack = append(scope.Contract.returnStack, returnFrame{section: scope.Contract.section, pc: *pc + 3})
+	enterSection(pc, scope, section)
+	return nil, nil
+}
+
+func opRetf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	frame := scope.Contract.returnStack[len(scope.Contract.returnStack)-1]
+	scope.Contract.returnStack = scope.Contract.returnStack[:len(scope.Contract.returnStack)-1]
+	scope.Contract.section = frame.section
+	scope.Contract.Code = scope.Contract.Container.codeSections[frame.section]
+	*pc = frame.pc - 1 // pc will be increased by the interpreter loop
+	return nil, nil
+}
+
+func opJumpf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	section := uint64(binary.BigEndian.Uint16(scope.Contract.Code[*pc+1:]))
+	if err := checkSectionStack(scope, section); err != nil {
+		return nil, err
+	}
+	enterSection(pc, scope, section)
+	return nil, nil
+}
+
+func opDupN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	n := int(scope.Contract.Code[*pc+1])
+	scope.Stack.Dup(n + 1)
+	*pc += 1
+	return nil, nil
+}
+
+func opSwapN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	n := int(scope.Contract.Code[*pc+1])
+	scope.Stack.Swap(n + 2)
+	*pc += 1
+	return nil, nil
+}
+
+func opExchange(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	imm := scope.Contract.Code[*pc+1]
+	n, m := int(imm>>4)+1, int(imm&0x0f)+1
+	a, b := scope.Stack.Back(n), scope.Stack.Back(n+m)
+	*a, *b = *b, *a
+	*pc += 1
+	return nil, nil
+}
+
+func opDataLoad(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	offset := scope.Stack.Peek()
+	offset.SetBytes(getDataBig(scope.Contract.Container.data, offset, 32))
+	return nil, nil
+}
+
+func opDataLoadN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	offset := uint64(binary.BigEndian.Uint16(scope.Contract.Code[*pc+1:]))
+	scope.Stack.Push(new(uint256.Int).SetBytes(getData(scope.Contract.Container.data, offset, 32)))
+	*pc += 2
+	return nil, nil
+}
+
+func opDataSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	scope.Stack.Push(new(uint256.Int).SetUint64(uint64(len(scope.Contract.Container.data))))
+	return nil, nil
+}
+
+func opDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		memOffset = scope.Stack.Pop()
+		offset    = scope.Stack.Pop()
+		size      = scope.Stack.Pop()
+	)
+	// These values are checked for overflow during gas cost calculation
+	scope.Memory.Set(memOffset.Uint64(), size.Uint64(), getDataBig(scope.Contract.Container.data, &offset, size.Uint64()))
+	return nil, nil
+}
+
+func opReturnDataLoad(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	offset := scope.Stack.Peek()
+	offset.SetBytes(getDataBig(interpreter.returnData, offset, 32))
+	return nil, nil
+}
+
+// opReturnDataCopyEOF is RETURNDATACOPY in EOF code, which pads the return
+// data with zeros instead of failing when reading past its end.
+func opReturnDataCopyEOF(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		memOffset  = scope.Stack.Pop()
+		dataOffset = scope.Stack.Pop()
+		length     = scope.Stack.Pop()
+	)
+	scope.Memory.Set(memOffset.Uint64(), length.Uint64(), getDataBig(interpreter.returnData, &dataOffset, length.Uint64()))
+	return nil, nil
+}
+
+func opEOFCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.readOnly {
+		return nil, ErrWriteProtection
+	}
+	var (
+		idx    = scope.Contract.Code[*pc+1]
+		value  = scope.Stack.Pop()
+		salt   = scope.Stack.Pop()
+		offset = scope.Stack.Pop()
+		size   = scope.Stack.Peek()
+		input  = scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
+		raw    = scope.Contract.Container.rawContainers[idx]
+	)
+	*pc += 1
+	// The new address is derived from the hash of the initcontainer
+	if !scope.Contract.UseGas(ToWordSize(uint64(len(raw))) * params.Keccak256WordGas) {
+		return nil, ErrOutOfGas
+	}
+	gas := scope.Contract.Gas
+	gas -= gas / 64
+	scope.Contract.UseGas(gas)
+
+	res, addr, returnGas, suberr := interpreter.evm.EOFCreate(scope.Contract, scope.Contract.Container.subContainers[idx], raw, input, gas, &value, &salt)
+	if suberr != nil {
+		size.Clear()
+	} else {
+		size.SetBytes(addr.Bytes())
+	}
+	scope.Contract.Gas += returnGas
+
+	if suberr == ErrExecutionReverted {
+		interpreter.returnData = res // set REVERT data to return data buffer
+		return res, nil
+	}
+	interpreter.returnData = nil // clear dirty return data buffer
+	return nil, nil
+}
+
+// opReturnCode ends initcode by returning the container to deploy: a
+// subcontainer with the aux data appended to its data section.
+func opReturnCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		idx          = scope.Contract.Code[*pc+1]
+		offset, size = scope.Stack.Pop(), scope.Stack.Pop()
+		aux          = scope.Memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
+	)
+	deploy := *scope.Contract.Container.subContainers[idx]
+	deploy.data = append(libcommon.CopyBytes(deploy.data), aux...)
+	if len(deploy.data) < deploy.dataSize || len(deploy.data) > math.MaxUint16 {
+		return nil, ErrInvalidAuxData
+	}
+	deploy.dataSize = len(deploy.data)
+	return deploy.MarshalBinary(), errStopToken
+}
+
+func opExtCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	return extCall(EXTCALL, interpreter, scope)
+}
+
+func opExtDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	return extCall(EXTDELEGATECALL, interpreter, scope)
+}
+
+func opExtStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	return extCall(EXTSTATICCALL, interpreter, scope)
+}
+
+// extCall executes EXTCALL, EXTDELEGATECALL and EXTSTATICCALL (EIP-7069),
+// which push 0 on success, 1 on revert or when the call couldn't be made, and
+// 2 on failure.
+func extCall(typ OpCode, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		evm                      = interpreter.evm
+		stack                    = scope.Stack
+		target, inOffset, inSize = stack.Pop(), stack.Pop(), stack.Pop()
+		value                    uint256.Int
+	)
+	if typ == EXTCALL {
+		value = stack.Pop()
+		if interpreter.readOnly && !value.IsZero() {
+			return nil, ErrWriteProtection
+		}
+	}
+	toAddr := libcommon.Address(target.Bytes20())
+	args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+
+	interpreter.returnData = nil
+	status := new(uint256.Int)
+	gas := scope.Contract.Gas - min(scope.Contract.Gas, max(scope.Contract.Gas/64, minRetainedGas))
+	if gas < minCalleeGas || interpreter.Depth() > int(params.CallCreateDepth) ||
+		(!value.IsZero() && !evm.Context.CanTransfer(evm.IntraBlockState(), scope.Contract.Address(), &value)) ||
+		(typ == EXTDELEGATECALL && !isEOFVersion1(evm.IntraBlockState().ResolveCode(toAddr))) {
+		stack.Push(status.SetOne())
+		return nil, nil
+	}
+	scope.Contract.UseGas(gas)
+
+	var (
+		ret       []byte
+		returnGas uint64
+		err       error
+	)
+	switch typ {
+	case EXTCALL:
+		ret, returnGas, err = evm.Call(scope.Contract, toAddr, args, gas, &value, false /* bailout */)
+	case EXTDELEGATECALL:
+		ret, returnGas, err = evm.DelegateCall(scope.Contract, toAddr, args, gas)
+	default:
+		ret, returnGas, err = evm.StaticCall(scope.Contract, toAddr, args, gas)
+	}
+	switch err {
+	case nil:
+	case ErrExecutionReverted, ErrDepth, ErrInsufficientBalance:
+		status.SetOne()
+	default:
+		status.SetUint64(2)
+	}
+	stack.Push(status)
+	scope.Contract.Gas += returnGas
+
+	interpreter.returnData = ret
+	return ret, nil
+}
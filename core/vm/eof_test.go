@@ -0,0 +1,307 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/vm/evmtypes"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+)
+
+// eofSection is a code section of a test container, with its inputs, outputs
+// and max stack increase.
+type eofSection struct {
+	code             []byte
+	inputs, outputs  uint8
+	maxStackIncrease uint16
+}
+
+func newTestContainer(sections []eofSection, subContainers [][]byte, data []byte, dataSize int) *Container {
+	c := &Container{data: data, dataSize: dataSize}
+	for _, s := range sections {
+		c.types = append(c.types, &functionMetadata{inputs: s.inputs, outputs: s.outputs, maxStackIncrease: s.maxStackIncrease})
+		c.codeSections = append(c.codeSections, s.code)
+	}
+	for _, raw := range subContainers {
+		sub := new(Container)
+		if _, err := sub.unmarshal(raw, parseTruncated); err != nil {
+			panic(err)
+		}
+		c.subContainers = append(c.subContainers, sub)
+		c.rawContainers = append(c.rawContainers, raw)
+	}
+	return c
+}
+
+// runtimeCode returns a deployable container with a single code section.
+func runtimeCode(code []byte, maxStackIncrease uint16) []byte {
+	return newTestContainer([]eofSection{{code: code, outputs: nonReturningFunction, maxStackIncrease: maxStackIncrease}}, nil, nil, 0).MarshalBinary()
+}
+
+func TestEOFMarshaling(t *testing.T) {
+	sub := runtimeCode([]byte{byte(STOP)}, 0)
+	c := newTestContainer([]eofSection{
+		{code: []byte{byte(CALLF), 0, 1, byte(PUSH0), byte(PUSH0), byte(RETURNCODE), 0}, outputs: nonReturningFunction, maxStackIncrease: 2},
+		{code: []byte{byte(RETF)}},
+	}, [][]byte{sub}, []byte{1, 2, 3}, 3)
+	b := c.MarshalBinary()
+
+	var decoded Container
+	require.NoError(t, decoded.UnmarshalBinary(b))
+	require.Equal(t, b, decoded.MarshalBinary())
+	require.Len(t, decoded.codeSections, 2)
+	require.Equal(t, []byte{1, 2, 3}, decoded.data)
+	require.Equal(t, sub, decoded.rawContainers[0])
+
+	// creation transactions append the calldata
+	prefix, calldata, err := parseInitcodePrefix(append(libcommon.CopyBytes(b), 0xca, 0xfe))
+	require.NoError(t, err)
+	require.Equal(t, b, prefix.MarshalBinary())
+	require.Equal(t, []byte{0xca, 0xfe}, calldata)
+
+	for name, tt := range map[string]struct {
+		code []byte
+		err  error
+	}{
+		"legacy":         {[]byte{byte(PUSH0), byte(STOP)}, ErrInvalidMagic},
+		"version":        {append([]byte{0xef, 0x00, 0x02}, b[3:]...), ErrInvalidVersion},
+		"trailing bytes": {append(libcommon.CopyBytes(b), 0x00), ErrInvalidContainerSize},
+		"truncated data": {b[:len(b)-1], ErrTruncatedData},
+		"no code":        {[]byte{0xef, 0x00, 0x01, kindTypes, 0x00, 0x04, kindData}, ErrMissingCodeHeader},
+		"section 0 type": {newTestContainer([]eofSection{{code: []byte{byte(STOP)}}}, nil, nil, 0).MarshalBinary(), ErrInvalidSection0Type},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, new(Container).UnmarshalBinary(tt.code), tt.err)
+		})
+	}
+}
+
+func TestEOFValidation(t *testing.T) {
+	returning := func(code []byte, outputs uint8, maxStackIncrease uint16) eofSection {
+		return eofSection{code: code, outputs: outputs, maxStackIncrease: maxStackIncrease}
+	}
+	main := func(code []byte, maxStackIncrease uint16) eofSection {
+		return returning(code, nonReturningFunction, maxStackIncrease)
+	}
+	deploy := runtimeCode([]byte{byte(STOP)}, 0)
+	for name, tt := range map[string]struct {
+		container  *Container
+		isInitcode bool
+		err        error
+	}{
+		"valid": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(PUSH0), byte(RETURN)}, 2)}, nil, nil, 0),
+		},
+		"undefined instruction": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(JUMP)}, 1)}, nil, nil, 0),
+			err:       ErrUndefinedInstruction,
+		},
+		"truncated immediate": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH2), 0x00}, 1)}, nil, nil, 0),
+			err:       ErrTruncatedImmediate,
+		},
+		"jump into immediate": {
+			container: newTestContainer([]eofSection{main([]byte{byte(RJUMP), 0x00, 0x01, byte(PUSH1), 0x00, byte(STOP)}, 1)}, nil, nil, 0),
+			err:       ErrInvalidJumpDest,
+		},
+		"unreachable code": {
+			container: newTestContainer([]eofSection{main([]byte{byte(STOP), byte(STOP)}, 0)}, nil, nil, 0),
+			err:       ErrUnreachableCode,
+		},
+		"no termination": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0)}, 1)}, nil, nil, 0),
+			err:       ErrInvalidCodeTermination,
+		},
+		"stack underflow": {
+			container: newTestContainer([]eofSection{main([]byte{byte(POP), byte(STOP)}, 0)}, nil, nil, 0),
+			err:       ErrEOFStackUnderflow,
+		},
+		"wrong max stack height": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(POP), byte(STOP)}, 0)}, nil, nil, 0),
+			err:       ErrInvalidMaxStackHeight,
+		},
+		"loop": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(RJUMPI), 0xff, 0xfc, byte(STOP)}, 1)}, nil, nil, 0),
+		},
+		"unbalanced loop": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(RJUMP), 0xff, 0xfc}, 1)}, nil, nil, 0),
+			err:       ErrInvalidBackwardJump,
+		},
+		"rjumpv": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(RJUMPV), 0x01, 0x00, 0x00, 0x00, 0x01, byte(STOP), byte(INVALID)}, 1)}, nil, nil, 0),
+		},
+		"callf": {
+			container: newTestContainer([]eofSection{
+				main([]byte{byte(CALLF), 0x00, 0x01, byte(POP), byte(STOP)}, 1),
+				returning([]byte{byte(PUSH0), byte(RETF)}, 1, 1),
+			}, nil, nil, 0),
+		},
+		"retf with wrong outputs": {
+			container: newTestContainer([]eofSection{
+				main([]byte{byte(CALLF), 0x00, 0x01, byte(POP), byte(STOP)}, 1),
+				returning([]byte{byte(RETF)}, 1, 0),
+			}, nil, nil, 0),
+			err: ErrInvalidOutputs,
+		},
+		"unreachable section": {
+			container: newTestContainer([]eofSection{main([]byte{byte(STOP)}, 0), returning([]byte{byte(RETF)}, 0, 0)}, nil, nil, 0),
+			err:       ErrUnreachableCodeSections,
+		},
+		"dataloadn out of bounds": {
+			container: newTestContainer([]eofSection{main([]byte{byte(DATALOADN), 0x00, 0x01, byte(POP), byte(STOP)}, 1)}, nil, make([]byte, 32), 32),
+			err:       ErrInvalidDataloadNArgument,
+		},
+		"initcode": {
+			container:  newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(PUSH0), byte(RETURNCODE), 0x00}, 2)}, [][]byte{deploy}, nil, 0),
+			isInitcode: true,
+		},
+		"returncode in runtime code": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(PUSH0), byte(RETURNCODE), 0x00}, 2)}, [][]byte{deploy}, nil, 0),
+			err:       ErrIncompatibleContainerKind,
+		},
+		"stop in initcode": {
+			container:  newTestContainer([]eofSection{main([]byte{byte(STOP)}, 0)}, nil, nil, 0),
+			isInitcode: true,
+			err:        ErrIncompatibleContainerKind,
+		},
+		"orphan subcontainer": {
+			container: newTestContainer([]eofSection{main([]byte{byte(STOP)}, 0)}, [][]byte{deploy}, nil, 0),
+			err:       ErrOrphanSubContainer,
+		},
+		"eofcreate of runtime code": {
+			container: newTestContainer([]eofSection{main([]byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EOFCREATE), 0x00, byte(STOP)}, 4)}, [][]byte{deploy}, nil, 0),
+			err:       ErrIncompatibleContainerKind,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var c Container
+			require.NoError(t, c.UnmarshalBinary(tt.container.MarshalBinary()))
+			err := c.ValidateCode(eofInstructionSet(&pragueInstructionSet, true), tt.isInitcode)
+			if tt.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEOFInstructionSet(t *testing.T) {
+	cancun := eofInstructionSet(&cancunInstructionSet, true)
+	require.Same(t, cancun, eofInstructionSet(&cancunInstructionSet, true))
+	require.NotSame(t, cancun, eofInstructionSet(&pragueInstructionSet, true))
+	// the instruction set of the fork of the rules is extended
+	require.Same(t, &cancunInstructionSet, instructionSetOf(&chain.Rules{IsLondon: true, IsShanghai: true, IsCancun: true, IsEOF: true}))
+
+	// the EOF instructions replace the legacy ones of the fork, which is left as is
+	require.False(t, cancun[RJUMP].undefined)
+	require.True(t, cancun[JUMP].undefined)
+	require.True(t, cancunInstructionSet[RJUMP].undefined)
+	require.False(t, cancunInstructionSet[JUMP].undefined)
+	require.Equal(t, cancunInstructionSet[ADD].constantGas, cancun[ADD].constantGas)
+	require.NotSame(t, cancunInstructionSet[ADD], cancun[ADD])
+
+	// an EOF instruction set with extra EIPs isn't cached
+	extra := copyJumpTable(&cancunInstructionSet)
+	require.NotSame(t, eofInstructionSet(extra, false), eofInstructionSet(extra, false))
+}
+
+func TestEOFExecution(t *testing.T) {
+	config := *params.AllProtocolChanges
+	config.PragueTime, config.EOFTime = big.NewInt(0), big.NewInt(0)
+
+	_, tx := memdb.NewTestTx(t)
+	ibs := state.New(state.NewPlainStateReader(tx))
+	blockCtx := evmtypes.BlockContext{
+		CanTransfer: func(evmtypes.IntraBlockState, libcommon.Address, *uint256.Int) bool { return true },
+		Transfer:    func(evmtypes.IntraBlockState, libcommon.Address, libcommon.Address, *uint256.Int, bool) {},
+	}
+	evm := NewEVM(blockCtx, evmtypes.TxContext{}, ibs, &config, Config{})
+	sender := AccountRef(libcommon.HexToAddress("0x5e"))
+	gas := uint64(1_000_000)
+	word := func(v uint64) []byte { return uint256.NewInt(v).PaddedBytes(32) }
+
+	// The runtime code returns its data from a function, and gets it from the
+	// aux data of the initcode.
+	runtime := newTestContainer([]eofSection{
+		{code: []byte{byte(CALLF), 0x00, 0x01, byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN)}, outputs: nonReturningFunction, maxStackIncrease: 2},
+		{code: []byte{byte(DATALOADN), 0x00, 0x00, byte(RETF)}, outputs: 1, maxStackIncrease: 1},
+	}, nil, nil, 32).MarshalBinary()
+	initcode := newTestContainer([]eofSection{
+		{code: []byte{byte(PUSH1), 42, byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURNCODE), 0x00}, outputs: nonReturningFunction, maxStackIncrease: 2},
+	}, [][]byte{runtime}, nil, 0).MarshalBinary()
+
+	deployed, addr, _, err := evm.Create(sender, initcode, gas, new(uint256.Int), false)
+	require.NoError(t, err)
+	require.True(t, hasEOFMagic(deployed))
+	require.Equal(t, deployed, ibs.GetCode(addr))
+	ret, _, err := evm.Call(sender, addr, nil, gas, new(uint256.Int), false)
+	require.NoError(t, err)
+	require.Equal(t, word(42), ret)
+
+	// Invalid EOF initcode fails the creation, and CREATE can't run EOF initcode
+	_, _, _, err = evm.Create(sender, initcode[:len(initcode)-1], gas, new(uint256.Int), false)
+	require.ErrorIs(t, err, ErrInvalidEOFInitcode)
+	legacyCreate := libcommon.HexToAddress("0xc0")
+	ibs.SetCode(legacyCreate, append(append([]byte{byte(PUSH1), byte(len(initcode)), byte(PUSH0), byte(PUSH0)}, byte(CALLDATACOPY)),
+		byte(PUSH1), byte(len(initcode)), byte(PUSH0), byte(PUSH0), byte(CREATE), byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN)))
+	ret, _, err = evm.Call(sender, legacyCreate, initcode, gas, new(uint256.Int), false)
+	require.NoError(t, err)
+	require.Equal(t, word(0), ret)
+
+	// Legacy code sees EOF contracts as the EOF magic
+	for op, want := range map[OpCode][]byte{
+		EXTCODESIZE: word(2),
+		EXTCODEHASH: crypto.Keccak256(eofMagic),
+	} {
+		legacy := libcommon.HexToAddress("0x1e")
+		ibs.SetCode(legacy, append(append([]byte{byte(PUSH20)}, addr.Bytes()...), byte(op), byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN)))
+		ret, _, err = evm.Call(sender, legacy, nil, gas, new(uint256.Int), false)
+		require.NoError(t, err)
+		require.Equal(t, want, ret, op.String())
+	}
+
+	// EXTCALL* push the status of the call
+	var (
+		succeeds = libcommon.HexToAddress("0xa0")
+		reverts  = libcommon.HexToAddress("0xa1")
+		fails    = libcommon.HexToAddress("0xa2")
+		caller   = libcommon.HexToAddress("0xa3")
+	)
+	ibs.SetCode(succeeds, []byte{byte(STOP)})
+	ibs.SetCode(reverts, []byte{byte(PUSH0), byte(PUSH0), byte(REVERT)})
+	ibs.SetCode(fails, []byte{byte(INVALID)})
+	for _, tt := range []struct {
+		op     OpCode
+		target libcommon.Address
+		status uint64
+	}{
+		{EXTCALL, succeeds, 0},
+		{EXTCALL, reverts, 1},
+		{EXTCALL, fails, 2},
+		{EXTSTATICCALL, succeeds, 0},
+		{EXTDELEGATECALL, succeeds, 1}, // legacy code can't be delegated to
+		{EXTDELEGATECALL, addr, 0},
+	} {
+		code := []byte{byte(PUSH0), byte(PUSH0)}
+		maxStackIncrease := uint16(3)
+		if tt.op == EXTCALL {
+			code = append([]byte{byte(PUSH0)}, code...)
+			maxStackIncrease++
+		}
+		code = append(append(append(code, byte(PUSH20)), tt.target.Bytes()...), byte(tt.op), byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN))
+		ibs.SetCode(caller, runtimeCode(code, maxStackIncrease))
+		ret, _, err = evm.Call(sender, caller, nil, gas, new(uint256.Int), false)
+		require.NoError(t, err)
+		require.Equal(t, word(tt.status), ret, "%v %x", tt.op, tt.target)
+	}
+}
@@ -0,0 +1,319 @@
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/chain"
+
+	"github.com/erigontech/erigon/params"
+)
+
+var (
+	ErrUndefinedInstruction      = errors.New("undefined instruction")
+	ErrTruncatedImmediate        = errors.New("truncated immediate")
+	ErrInvalidSectionArgument    = errors.New("invalid section argument")
+	ErrInvalidContainerArgument  = errors.New("invalid container argument")
+	ErrInvalidDataloadNArgument  = errors.New("invalid dataloadN argument")
+	ErrInvalidJumpDest           = errors.New("invalid jump destination")
+	ErrInvalidCallArgument       = errors.New("callf into non-returning section")
+	ErrInvalidRetf               = errors.New("retf in non-returning section")
+	ErrInvalidReturningSection   = errors.New("returning section without retf or returning jumpf")
+	ErrInvalidOutputs            = errors.New("invalid number of outputs")
+	ErrInvalidCodeTermination    = errors.New("invalid code termination")
+	ErrUnreachableCode           = errors.New("unreachable code")
+	ErrInvalidBackwardJump       = errors.New("invalid backward jump")
+	ErrEOFStackUnderflow         = errors.New("stack underflow")
+	ErrEOFStackOverflow          = errors.New("stack overflow")
+	ErrInvalidMaxStackHeight     = errors.New("invalid max stack height")
+	ErrUnreachableCodeSections   = errors.New("unreachable code sections")
+	ErrOrphanSubContainer        = errors.New("subcontainer not referenced")
+	ErrAmbiguousContainer        = errors.New("subcontainer referenced by both eofcreate and returncode")
+	ErrIncompatibleContainerKind = errors.New("instruction not allowed in this kind of container")
+)
+
+// containerKind tells whether a container is executed to create a contract,
+// which it finishes with RETURNCODE, or is the code of a deployed contract.
+type containerKind int
+
+const (
+	runtimeContainer containerKind = iota + 1
+	initcodeContainer
+)
+
+// ValidateCode validates the code of the container and of its subcontainers
+// against the EOF instruction set jt. isInitcode tells whether the container
+// is executed as initcode, as opposed to being deployed.
+func (c *Container) ValidateCode(jt *JumpTable, isInitcode bool) error {
+	kind := runtimeContainer
+	if isInitcode {
+		kind = initcodeContainer
+	}
+	return c.validate(jt, kind)
+}
+
+// ValidateEOFCode decodes code as an EOF container and validates it against
+// the EOF instruction set of the fork of the rules.
+func ValidateEOFCode(code []byte, isInitcode bool, rules *chain.Rules) error {
+	var c Container
+	if err := c.UnmarshalBinary(code); err != nil {
+		return err
+	}
+	return c.ValidateCode(eofInstructionSet(instructionSetOf(rules), true), isInitcode)
+}
+
+func (c *Container) validate(jt *JumpTable, kind containerKind) error {
+	if kind == initcodeContainer && len(c.data) < c.dataSize {
+		return ErrTruncatedData // only deploy containers get the rest of their data at RETURNCODE
+	}
+	var (
+		visited = make([]bool, len(c.codeSections))
+		queue   = []int{0}
+		refs    = make([]containerKind, len(c.subContainers))
+	)
+	visited[0] = true
+	for len(queue) > 0 {
+		section := queue[0]
+		queue = queue[1:]
+		called, err := c.validateSection(section, jt, kind, refs)
+		if err != nil {
+			return fmt.Errorf("section %d: %w", section, err)
+		}
+		for _, s := range called {
+			if !visited[s] {
+				visited[s] = true
+				queue = append(queue, s)
+			}
+		}
+	}
+	for i, v := range visited {
+		if !v {
+			return fmt.Errorf("%w: section %d", ErrUnreachableCodeSections, i)
+		}
+	}
+	for i, sub := range c.subContainers {
+		if refs[i] == 0 {
+			return fmt.Errorf("%w: container %d", ErrOrphanSubContainer, i)
+		}
+		if err := sub.validate(jt, refs[i]); err != nil {
+			return fmt.Errorf("container %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateSection validates a code section and returns the sections it calls
+// or jumps to. The kinds of the subcontainers it references are recorded in refs.
+func (c *Container) validateSection(section int, jt *JumpTable, kind containerKind, refs []containerKind) ([]int, error) {
+	var (
+		code      = c.codeSections[section]
+		ty        = c.types[section]
+		bitmap    = eofCodeBitmap(code)
+		called    []int
+		returning bool // whether RETF or a JUMPF to a returning section was seen
+	)
+	reference := func(idx int, ref containerKind) error {
+		if idx >= len(c.subContainers) {
+			return fmt.Errorf("%w: %d", ErrInvalidContainerArgument, idx)
+		}
+		if refs[idx] != 0 && refs[idx] != ref {
+			return fmt.Errorf("%w: %d", ErrAmbiguousContainer, idx)
+		}
+		refs[idx] = ref
+		return nil
+	}
+	for pc := 0; pc < len(code); {
+		op := OpCode(code[pc])
+		if jt[op].undefined {
+			return nil, fmt.Errorf("%w: %v at %d", ErrUndefinedInstruction, op, pc)
+		}
+		size := eofImmediateSize(code, pc)
+		if pc+size >= len(code) && size > 0 {
+			return nil, fmt.Errorf("%w: %v at %d", ErrTruncatedImmediate, op, pc)
+		}
+		switch op {
+		case RJUMP, RJUMPI, RJUMPV:
+			for _, dest := range rjumpTargets(code, pc) {
+				if dest < 0 || dest >= len(code) || !isCodeFromAnalysis(bitmap, uint64(dest)) {
+					return nil, fmt.Errorf("%w: %d at %d", ErrInvalidJumpDest, dest, pc)
+				}
+			}
+		case CALLF:
+			idx := int(binary.BigEndian.Uint16(code[pc+1:]))
+			if idx >= len(c.types) {
+				return nil, fmt.Errorf("%w: %d at %d", ErrInvalidSectionArgument, idx, pc)
+			}
+			if !c.types[idx].returning() {
+				return nil, fmt.Errorf("%w: %d at %d", ErrInvalidCallArgument, idx, pc)
+			}
+			called = append(called, idx)
+		case JUMPF:
+			idx := int(binary.BigEndian.Uint16(code[pc+1:]))
+			if idx >= len(c.types) {
+				return nil, fmt.Errorf("%w: %d at %d", ErrInvalidSectionArgument, idx, pc)
+			}
+			if target := c.types[idx]; target.returning() {
+				if !ty.returning() || ty.outputs < target.outputs {
+					return nil, fmt.Errorf("%w: jumpf to section %d at %d", ErrInvalidOutputs, idx, pc)
+				}
+				returning = true
+			}
+			called = append(called, idx)
+		case RETF:
+			if !ty.returning() {
+				return nil, fmt.Errorf("%w: at %d", ErrInvalidRetf, pc)
+			}
+			returning = true
+		case DATALOADN:
+			if offset := int(binary.BigEndian.Uint16(code[pc+1:])); offset+32 > c.dataSize {
+				return nil, fmt.Errorf("%w: %d at %d", ErrInvalidDataloadNArgument, offset, pc)
+			}
+		case EOFCREATE:
+			if err := reference(int(code[pc+1]), initcodeContainer); err != nil {
+				return nil, err
+			}
+		case RETURNCODE:
+			if kind == runtimeContainer {
+				return nil, fmt.Errorf("%w: %v at %d", ErrIncompatibleContainerKind, op, pc)
+			}
+			if err := reference(int(code[pc+1]), runtimeContainer); err != nil {
+				return nil, err
+			}
+		case STOP, RETURN:
+			if kind == initcodeContainer {
+				return nil, fmt.Errorf("%w: %v at %d", ErrIncompatibleContainerKind, op, pc)
+			}
+		}
+		pc += 1 + size
+	}
+	if ty.returning() && !returning {
+		return nil, ErrInvalidReturningSection
+	}
+	if err := c.validateStackHeights(section, jt); err != nil {
+		return nil, err
+	}
+	return called, nil
+}
+
+// rjumpTargets returns the destinations of the relative jump at pc. Its
+// immediates must not be truncated.
+func rjumpTargets(code []byte, pc int) []int {
+	if OpCode(code[pc]) == RJUMPV {
+		count := int(code[pc+1]) + 1
+		end := pc + 2 + 2*count
+		targets := make([]int, count)
+		for i := range targets {
+			targets[i] = end + int(int16(binary.BigEndian.Uint16(code[pc+2+2*i:])))
+		}
+		return targets
+	}
+	return []int{pc + 3 + int(int16(binary.BigEndian.Uint16(code[pc+1:])))}
+}
+
+// isTerminating reports whether an EOF instruction ends the execution of its
+// code section.
+func isTerminating(op OpCode) bool {
+	switch op {
+	case STOP, RETURN, REVERT, INVALID, RETF, JUMPF, RETURNCODE:
+		return true
+	}
+	return false
+}
+
+// validateStackHeights checks that the operand stack can neither underflow
+// nor overflow in a code section and that its maximal height is the declared
+// one (EIP-5450). Forward jumps can reach an instruction with a range of
+// stack heights, backward jumps must match the heights of their destination.
+func (c *Container) validateStackHeights(section int, jt *JumpTable) error {
+	type bounds struct{ min, max int }
+	var (
+		code    = c.codeSections[section]
+		ty      = c.types[section]
+		heights = make([]bounds, len(code))
+		highest = int(ty.inputs)
+	)
+	for i := range heights {
+		heights[i] = bounds{-1, -1}
+	}
+	heights[0] = bounds{int(ty.inputs), int(ty.inputs)}
+
+	for pc := 0; pc < len(code); {
+		op := OpCode(code[pc])
+		h := heights[pc]
+		if h.min < 0 {
+			return fmt.Errorf("%w: at %d", ErrUnreachableCode, pc)
+		}
+		next := pc + 1 + eofImmediateSize(code, pc)
+		required, delta := jt[op].numPop, jt[op].numPush-jt[op].numPop
+
+		switch op {
+		case CALLF, JUMPF:
+			target := c.types[binary.BigEndian.Uint16(code[pc+1:])]
+			if h.max+int(target.maxStackIncrease) > int(params.StackLimit) {
+				return fmt.Errorf("%w: %v at %d", ErrEOFStackOverflow, op, pc)
+			}
+			required = int(target.inputs)
+			if op == CALLF {
+				delta = int(target.outputs) - int(target.inputs)
+			} else if target.returning() {
+				want := int(ty.outputs) + int(target.inputs) - int(target.outputs)
+				if h.min != want || h.max != want {
+					return fmt.Errorf("%w: jumpf at %d with stack height %d-%d, want %d", ErrInvalidOutputs, pc, h.min, h.max, want)
+				}
+			}
+		case RETF:
+			if h.min != int(ty.outputs) || h.max != int(ty.outputs) {
+				return fmt.Errorf("%w: retf at %d with stack height %d-%d, want %d", ErrInvalidOutputs, pc, h.min, h.max, ty.outputs)
+			}
+		case DUPN:
+			required, delta = int(code[pc+1])+1, 1
+		case SWAPN:
+			required, delta = int(code[pc+1])+2, 0
+		case EXCHANGE:
+			n, m := int(code[pc+1]>>4)+1, int(code[pc+1]&0x0f)+1
+			required, delta = n+m+1, 0
+		}
+		if h.min < required {
+			return fmt.Errorf("%w: %v at %d requires %d items, have %d", ErrEOFStackUnderflow, op, pc, required, h.min)
+		}
+		out := bounds{h.min + delta, h.max + delta}
+		highest = max(highest, out.max)
+
+		visit := func(dest int) error {
+			if dest > pc {
+				if d := heights[dest]; d.min < 0 {
+					heights[dest] = out
+				} else {
+					heights[dest] = bounds{min(d.min, out.min), max(d.max, out.max)}
+				}
+			} else if heights[dest] != out {
+				return fmt.Errorf("%w: from %d to %d", ErrInvalidBackwardJump, pc, dest)
+			}
+			return nil
+		}
+		if op == RJUMP || op == RJUMPI || op == RJUMPV {
+			for _, dest := range rjumpTargets(code, pc) {
+				if err := visit(dest); err != nil {
+					return err
+				}
+			}
+		}
+		if op != RJUMP && !isTerminating(op) {
+			if next >= len(code) {
+				return fmt.Errorf("%w: %v at %d", ErrInvalidCodeTermination, op, pc)
+			}
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		pc = next
+	}
+	if highest > maxStackHeight {
+		return fmt.Errorf("%w: %d", ErrEOFStackOverflow, highest)
+	}
+	if declared := int(ty.inputs) + int(ty.maxStackIncrease); highest != declared {
+		return fmt.Errorf("%w: have %d, want %d", ErrInvalidMaxStackHeight, declared, highest)
+	}
+	return nil
+}
@@ -17,6 +17,7 @@
 package vm
 
 import (
+	"fmt"
 	"sync/atomic"
 
 	"github.com/holiman/uint256"
@@ -56,7 +57,10 @@ func (evm *EVM) precompile(addr libcommon.Address) (PrecompiledContract, bool) {
 	default:
 		precompiles = PrecompiledContractsHomestead
 	}
-	p, ok := precompiles[addr]
+	if p, ok := precompiles[addr]; ok {
+		return p, true
+	}
+	p, ok := evm.chainPrecompiles[addr]
 	return p, ok
 }
 
@@ -98,6 +102,8 @@ type EVM struct {
 	// available gas is calculated in gasCall* according to the 63/64 rule and later
 	// applied in opCall*.
 	callGasTemp uint64
+	// chainPrecompiles holds the chain-specific precompiles active with the chain rules
+	chainPrecompiles map[libcommon.Address]PrecompiledContract
 }
 
 // NewEVM returns a new EVM. The returned EVM is not thread safe and should
@@ -118,6 +124,7 @@ func NewEVM(blockCtx evmtypes.BlockContext, txCtx evmtypes.TxContext, state evmt
 		chainRules:      chainConfig.Rules(blockCtx.BlockNumber, blockCtx.Time),
 	}
 
+	evm.chainPrecompiles = chainPrecompiles(evm.chainRules)
 	evm.interpreter = NewEVMInterpreter(evm, vmConfig)
 
 	return evm
@@ -139,6 +146,7 @@ func (evm *EVM) ResetBetweenBlocks(blockCtx evmtypes.BlockContext, txCtx evmtype
 	evm.intraBlockState = ibs
 	evm.config = vmConfig
 	evm.chainRules = chainRules
+	evm.chainPrecompiles = chainPrecompiles(chainRules)
 
 	evm.interpreter = NewEVMInterpreter(evm, vmConfig)
 
@@ -275,11 +283,20 @@ func (evm *EVM) call(typ OpCode, caller ContractRef, addr libcommon.Address, inp
 			contract = NewContract(caller, addrCopy, value, gas, evm.config.SkipAnalysis)
 		}
 		contract.SetCallCode(&addrCopy, codeHash, code)
+		if evm.chainRules.IsEOF && hasEOFMagic(code) {
+			// EOF code is validated when it is deployed, so it only needs to be decoded
+			contract.Container = new(Container)
+			if contract.Container.UnmarshalBinary(code) != nil {
+				err = ErrInvalidCode
+			}
+		}
 		readOnly := false
 		if typ == STATICCALL {
 			readOnly = true
 		}
-		ret, err = run(evm, contract, input, readOnly)
+		if err == nil {
+			ret, err = run(evm, contract, input, readOnly)
+		}
 		gas = contract.Gas
 	}
 	// When an error was returned by the EVM or when setting the creation code
@@ -336,6 +353,9 @@ func (evm *EVM) StaticCall(caller ContractRef, addr libcommon.Address, input []b
 type codeAndHash struct {
 	code []byte
 	hash libcommon.Hash
+
+	container *Container // the EOF initcontainer
+	input     []byte     // the calldata of EOF initcode
 }
 
 func NewCodeAndHash(code []byte) *codeAndHash {
@@ -394,6 +414,12 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gasRemainin
 		}
 		evm.intraBlockState.SetNonce(caller.Address(), nonce+1)
 	}
+	if evm.chainRules.IsEOF && codeAndHash.container == nil && hasEOFMagic(codeAndHash.code) {
+		// Only creation transactions and EOFCREATE run EOF initcode (EIP-7698, EIP-7620)
+		if err = evm.prepareEOFInitcode(codeAndHash, typ, depth); err != nil {
+			return nil, libcommon.Address{}, 0, err
+		}
+	}
 	// We add this to the access list _before_ taking a snapshot. Even if the creation fails,
 	// the access-list change should not be rolled back
 	if evm.chainRules.IsBerlin {
@@ -417,12 +443,14 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gasRemainin
 	// The contract is a scoped environment for this execution context only.
 	contract := NewContract(caller, address, value, gasRemaining, evm.config.SkipAnalysis)
 	contract.SetCodeOptionalHash(&address, codeAndHash)
+	contract.Container = codeAndHash.container
 
 	if evm.config.NoRecursion && depth > 0 {
 		return nil, address, gasRemaining, nil
 	}
 
-	ret, err = run(evm, contract, nil, false)
+	// EOF initcode returns the container to deploy with RETURNCODE
+	ret, err = run(evm, contract, codeAndHash.input, false)
 
 	// EIP-170: Contract code size limit
 	if err == nil && evm.chainRules.IsSpuriousDragon && len(ret) > evm.maxCodeSize() {
@@ -434,7 +462,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gasRemainin
 	}
 
 	// Reject code starting with 0xEF if EIP-3541 is enabled.
-	if err == nil && evm.chainRules.IsLondon && len(ret) >= 1 && ret[0] == 0xEF {
+	if err == nil && evm.chainRules.IsLondon && len(ret) >= 1 && ret[0] == 0xEF && codeAndHash.container == nil {
 		err = ErrInvalidCode
 	}
 	// if the contract creation ran successfully and no errors were returned
@@ -491,6 +519,33 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gasRemaining uint64, en
 	return evm.create(caller, codeAndHash, gasRemaining, endowment, contractAddr, CREATE2, true /* incrementNonce */, bailout)
 }
 
+// EOFCreate creates a new contract from an initcontainer of the caller's EOF
+// container (EIP-7620). The address is derived like the one of Create2, from
+// the hash of the initcontainer.
+func (evm *EVM) EOFCreate(caller ContractRef, initContainer *Container, rawInitContainer []byte, input []byte, gasRemaining uint64, endowment *uint256.Int, salt *uint256.Int) (ret []byte, contractAddr libcommon.Address, leftOverGas uint64, err error) {
+	codeAndHash := &codeAndHash{code: rawInitContainer, container: initContainer, input: input}
+	contractAddr = crypto.CreateAddress2(caller.Address(), salt.Bytes32(), codeAndHash.Hash().Bytes())
+	return evm.create(caller, codeAndHash, gasRemaining, endowment, contractAddr, EOFCREATE, true /* incrementNonce */, false)
+}
+
+// prepareEOFInitcode decodes and validates the EOF initcontainer starting the
+// data of a creation transaction, which is followed by its calldata. CREATE
+// and CREATE2 can't run EOF initcode.
+func (evm *EVM) prepareEOFInitcode(codeAndHash *codeAndHash, typ OpCode, depth int) error {
+	if typ != CREATE || depth > 0 {
+		return ErrInvalidEOFInitcode
+	}
+	container, input, err := parseInitcodePrefix(codeAndHash.code)
+	if err == nil {
+		err = container.ValidateCode(evm.interpreter.(*EVMInterpreter).eofJt, true)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEOFInitcode, err)
+	}
+	codeAndHash.container, codeAndHash.input = container, input
+	return nil
+}
+
 // SysCreate is a special (system) contract creation methods for genesis constructors.
 // Unlike the normal Create & Create2, it doesn't increment caller's nonce.
 func (evm *EVM) SysCreate(caller ContractRef, code []byte, gas uint64, endowment *uint256.Int, contractAddr libcommon.Address) (ret []byte, leftOverGas uint64, err error) {
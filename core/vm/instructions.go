@@ -374,6 +374,13 @@ func opReturnDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeConte
 
 func opExtCodeSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
 	slot := scope.Stack.Peek()
+	if interpreter.evm.ChainRules().IsEOF {
+		// Legacy code sees EOF contracts as the bare magic
+		if code := interpreter.evm.IntraBlockState().ResolveCode(slot.Bytes20()); hasEOFMagic(code) {
+			slot.SetUint64(uint64(len(eofMagic)))
+			return nil, nil
+		}
+	}
 	slot.SetUint64(uint64(interpreter.evm.IntraBlockState().ResolveCodeSize(slot.Bytes20())))
 	return nil, nil
 }
@@ -411,7 +418,11 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 	addr := libcommon.Address(a.Bytes20())
 	len64 := length.Uint64()
 
-	codeCopy := getDataBig(interpreter.evm.IntraBlockState().ResolveCode(addr), &codeOffset, len64)
+	code := interpreter.evm.IntraBlockState().ResolveCode(addr)
+	if interpreter.evm.ChainRules().IsEOF && hasEOFMagic(code) {
+		code = eofMagic
+	}
+	codeCopy := getDataBig(code, &codeOffset, len64)
 	scope.Memory.Set(memOffset.Uint64(), len64, codeCopy)
 	return nil, nil
 }
@@ -453,12 +464,18 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 //	(7) Caller tries to get the code hash of a delegated account, the result should be
 //
 // equal the result of calling extcodehash on the account directly.
+//
+//	(8) Caller tries to get the code hash of an EOF contract, the result should be
+//
+// the hash of the EOF magic.
 func opExtCodeHash(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
 	slot := scope.Stack.Peek()
 	address := libcommon.Address(slot.Bytes20())
 
 	if interpreter.evm.IntraBlockState().Empty(address) {
 		slot.Clear()
+	} else if interpreter.evm.ChainRules().IsEOF && hasEOFMagic(interpreter.evm.IntraBlockState().ResolveCode(address)) {
+		slot.SetBytes(eofMagicHash.Bytes())
 	} else {
 		slot.SetBytes(interpreter.evm.IntraBlockState().ResolveCodeHash(address).Bytes())
 	}
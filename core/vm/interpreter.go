@@ -40,6 +40,7 @@ type Config struct {
 	ReadOnly      bool      // Do no perform any block finalisation
 	StatelessExec bool      // true is certain conditions (like state trie root hash matching) need to be relaxed for stateless EVM execution
 	RestoreState  bool      // Revert all changes made to the state (useful for constant system calls)
+	LazyCoinbase  bool      // Do not load the coinbase before the message, the parallel block building only adds to its balance
 
 	ExtraEips []int // Additional EIPS that are to be enabled
 }
@@ -92,6 +93,7 @@ type keccakState interface {
 type EVMInterpreter struct {
 	*VM
 	jt    *JumpTable // EVM instruction table
+	eofJt *JumpTable // EOF instruction table, derived from jt once EOF is enabled
 	depth int
 }
 
@@ -122,35 +124,7 @@ func copyJumpTable(jt *JumpTable) *JumpTable {
 
 // NewEVMInterpreter returns a new instance of the Interpreter.
 func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
-	var jt *JumpTable
-	switch {
-	case evm.ChainRules().IsPrague:
-		jt = &pragueInstructionSet
-	case evm.ChainRules().IsCancun:
-		jt = &cancunInstructionSet
-	case evm.ChainRules().IsNapoli:
-		jt = &napoliInstructionSet
-	case evm.ChainRules().IsShanghai:
-		jt = &shanghaiInstructionSet
-	case evm.ChainRules().IsLondon:
-		jt = &londonInstructionSet
-	case evm.ChainRules().IsBerlin:
-		jt = &berlinInstructionSet
-	case evm.ChainRules().IsIstanbul:
-		jt = &istanbulInstructionSet
-	case evm.ChainRules().IsConstantinople:
-		jt = &constantinopleInstructionSet
-	case evm.ChainRules().IsByzantium:
-		jt = &byzantiumInstructionSet
-	case evm.ChainRules().IsSpuriousDragon:
-		jt = &spuriousDragonInstructionSet
-	case evm.ChainRules().IsTangerineWhistle:
-		jt = &tangerineWhistleInstructionSet
-	case evm.ChainRules().IsHomestead:
-		jt = &homesteadInstructionSet
-	default:
-		jt = &frontierInstructionSet
-	}
+	jt := instructionSetOf(evm.ChainRules())
 	if len(cfg.ExtraEips) > 0 {
 		jt = copyJumpTable(jt)
 		for i, eip := range cfg.ExtraEips {
@@ -162,12 +136,18 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 		}
 	}
 
+	var eofJt *JumpTable
+	if evm.ChainRules().IsEOF {
+		eofJt = eofInstructionSet(jt, len(cfg.ExtraEips) == 0)
+	}
+
 	return &EVMInterpreter{
 		VM: &VM{
 			evm: evm,
 			cfg: cfg,
 		},
-		jt: jt,
+		jt:    jt,
+		eofJt: eofJt,
 	}
 }
 
@@ -190,6 +170,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	in.returnData = nil
 
 	var (
+		jt          = in.jt
 		op          OpCode // current opcode
 		mem         = pool.Get().(*Memory)
 		locStack    = stack.New()
@@ -214,6 +195,11 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	mem.Reset()
 
 	contract.Input = input
+	if contract.Container != nil {
+		// EOF code is executed by code section, starting with the first one
+		jt = in.eofJt
+		contract.Code = contract.Container.codeSections[0]
+	}
 
 	// Make sure the readOnly is only set if we aren't in readOnly yet.
 	// This makes also sure that the readOnly flag isn't removed for child calls.
@@ -258,7 +244,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		// Get the operation from the jump table and validate the stack to ensure there are
 		// enough stack items available to perform the operation.
 		op = contract.GetOp(_pc)
-		operation := in.jt[op]
+		operation := jt[op]
 		cost = operation.constantGas // For tracing
 		// Validate stack
 		if sLen := locStack.Len(); sLen < operation.numPop {
@@ -18,6 +18,9 @@ package vm
 
 import (
 	"fmt"
+	"sync"
+
+	"github.com/erigontech/erigon-lib/chain"
 
 	"github.com/erigontech/erigon/core/vm/stack"
 	"github.com/erigontech/erigon/params"
@@ -48,6 +51,9 @@ type operation struct {
 	opNum   int // only for push, swap, dup
 	// memorySize returns the memory size required for the operation
 	memorySize memorySizeFunc
+	// undefined tells that the opcode is not an instruction of the fork,
+	// which makes EOF code containing it invalid
+	undefined bool
 }
 
 var (
@@ -66,6 +72,10 @@ var (
 	pragueInstructionSet           = newPragueInstructionSet()
 )
 
+// eofInstructionSets caches the EOF instruction sets of the forks, by the
+// instruction set of the fork.
+var eofInstructionSets sync.Map
+
 // JumpTable contains the EVM opcodes supported at a given fork.
 type JumpTable [256]*operation
 
@@ -97,6 +107,61 @@ func newPragueInstructionSet() JumpTable {
 	return instructionSet
 }
 
+// instructionSetOf returns the instruction set of the fork of the rules.
+func instructionSetOf(rules *chain.Rules) *JumpTable {
+	switch {
+	case rules.IsPrague:
+		return &pragueInstructionSet
+	case rules.IsCancun:
+		return &cancunInstructionSet
+	case rules.IsNapoli:
+		return &napoliInstructionSet
+	case rules.IsShanghai:
+		return &shanghaiInstructionSet
+	case rules.IsLondon:
+		return &londonInstructionSet
+	case rules.IsBerlin:
+		return &berlinInstructionSet
+	case rules.IsIstanbul:
+		return &istanbulInstructionSet
+	case rules.IsConstantinople:
+		return &constantinopleInstructionSet
+	case rules.IsByzantium:
+		return &byzantiumInstructionSet
+	case rules.IsSpuriousDragon:
+		return &spuriousDragonInstructionSet
+	case rules.IsTangerineWhistle:
+		return &tangerineWhistleInstructionSet
+	case rules.IsHomestead:
+		return &homesteadInstructionSet
+	default:
+		return &frontierInstructionSet
+	}
+}
+
+// eofInstructionSet returns the EOF instruction set on top of the instruction
+// set of a fork, which is cached unless it is a copy with extra EIPs.
+func eofInstructionSet(jt *JumpTable, cache bool) *JumpTable {
+	if eof, ok := eofInstructionSets.Load(jt); ok {
+		return eof.(*JumpTable)
+	}
+	eof := newEOFInstructionSet(jt)
+	if cache {
+		eofInstructionSets.Store(jt, eof)
+	}
+	return eof
+}
+
+// newEOFInstructionSet returns the instructions of EOF code (EIP-7692) on top
+// of the instructions of a fork, which replace the legacy control flow, calls
+// and creation, and don't observe code or gas.
+func newEOFInstructionSet(jt *JumpTable) *JumpTable {
+	instructionSet := copyJumpTable(jt)
+	enableEOF(instructionSet)
+	validateAndFillMaxStack(instructionSet)
+	return instructionSet
+}
+
 // newCancunInstructionSet returns the frontier, homestead, byzantium,
 // constantinople, istanbul, petersburg, berlin, london, paris, shanghai,
 // and cancun instructions.
@@ -1212,7 +1277,7 @@ func newFrontierInstructionSet() JumpTable {
 	// Fill all unassigned slots with opUndefined.
 	for i, entry := range tbl {
 		if entry == nil {
-			tbl[i] = &operation{execute: opUndefined}
+			tbl[i] = &operation{execute: opUndefined, undefined: true}
 		}
 	}
 
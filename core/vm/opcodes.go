@@ -205,6 +205,37 @@ const (
 	LOG4
 )
 
+// 0xd0 range - EOF data ops.
+const (
+	DATALOAD OpCode = 0xd0 + iota
+	DATALOADN
+	DATASIZE
+	DATACOPY
+)
+
+// 0xe0 range - EOF control flow and stack ops.
+const (
+	RJUMP OpCode = 0xe0 + iota
+	RJUMPI
+	RJUMPV
+	CALLF
+	RETF
+	JUMPF
+	DUPN
+	SWAPN
+	EXCHANGE
+	EOFCREATE  OpCode = 0xec
+	RETURNCODE OpCode = 0xee
+)
+
+// 0xf0 range - EOF calls.
+const (
+	RETURNDATALOAD  OpCode = 0xf7
+	EXTCALL         OpCode = 0xf8
+	EXTDELEGATECALL OpCode = 0xf9
+	EXTSTATICCALL   OpCode = 0xfb
+)
+
 // 0xf0 range - closures.
 const (
 	CREATE OpCode = 0xf0 + iota
@@ -378,6 +409,31 @@ var opCodeToString = map[OpCode]string{
 	LOG3:   "LOG3",
 	LOG4:   "LOG4",
 
+	// 0xd0 range.
+	DATALOAD:  "DATALOAD",
+	DATALOADN: "DATALOADN",
+	DATASIZE:  "DATASIZE",
+	DATACOPY:  "DATACOPY",
+
+	// 0xe0 range.
+	RJUMP:      "RJUMP",
+	RJUMPI:     "RJUMPI",
+	RJUMPV:     "RJUMPV",
+	CALLF:      "CALLF",
+	RETF:       "RETF",
+	JUMPF:      "JUMPF",
+	DUPN:       "DUPN",
+	SWAPN:      "SWAPN",
+	EXCHANGE:   "EXCHANGE",
+	EOFCREATE:  "EOFCREATE",
+	RETURNCODE: "RETURNCODE",
+
+	// 0xf0 range - EOF calls.
+	RETURNDATALOAD:  "RETURNDATALOAD",
+	EXTCALL:         "EXTCALL",
+	EXTDELEGATECALL: "EXTDELEGATECALL",
+	EXTSTATICCALL:   "EXTSTATICCALL",
+
 	// 0xf0 range.
 	CREATE:       "CREATE",
 	CALL:         "CALL",
@@ -550,6 +606,27 @@ var stringToOp = map[string]OpCode{
 	"REVERT":         REVERT,
 	"INVALID":        INVALID,
 	"SELFDESTRUCT":   SELFDESTRUCT,
+
+	// EOF
+	"DATALOAD":        DATALOAD,
+	"DATALOADN":       DATALOADN,
+	"DATASIZE":        DATASIZE,
+	"DATACOPY":        DATACOPY,
+	"RJUMP":           RJUMP,
+	"RJUMPI":          RJUMPI,
+	"RJUMPV":          RJUMPV,
+	"CALLF":           CALLF,
+	"RETF":            RETF,
+	"JUMPF":           JUMPF,
+	"DUPN":            DUPN,
+	"SWAPN":           SWAPN,
+	"EXCHANGE":        EXCHANGE,
+	"EOFCREATE":       EOFCREATE,
+	"RETURNCODE":      RETURNCODE,
+	"RETURNDATALOAD":  RETURNDATALOAD,
+	"EXTCALL":         EXTCALL,
+	"EXTDELEGATECALL": EXTDELEGATECALL,
+	"EXTSTATICCALL":   EXTSTATICCALL,
 }
 
 // StringToOp finds the opcode whose name is stored in `str`.
@@ -349,3 +349,38 @@ func gasExtCodeCopyEIP7702(evm *EVM, contract *Contract, stack *stack.Stack, mem
 	}
 	return gas, nil
 }
+
+// makeGasExtCall returns the dynamic gas of EXTCALL, EXTDELEGATECALL and
+// EXTSTATICCALL: memory expansion, cold access and the value transfer. The
+// gas passed to the callee is determined when executing the call.
+func makeGasExtCall(transfersValue bool) gasFunc {
+	return func(evm *EVM, contract *Contract, stack *stack.Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		gas, err := memoryGasCost(mem, memorySize)
+		if err != nil {
+			return 0, err
+		}
+		target := stack.Back(0)
+		if target.BitLen() > 160 {
+			return 0, ErrInvalidAddress
+		}
+		addr := libcommon.Address(target.Bytes20())
+		var overflow bool
+		if evm.IntraBlockState().AddAddressToAccessList(addr) {
+			// The warm storage read cost is already charged as constantGas
+			if gas, overflow = math.SafeAdd(gas, params.ColdAccountAccessCostEIP2929-params.WarmStorageReadCostEIP2929); overflow {
+				return 0, ErrGasUintOverflow
+			}
+		}
+		if transfersValue && !stack.Back(3).IsZero() {
+			if gas, overflow = math.SafeAdd(gas, params.CallValueTransferGas); overflow {
+				return 0, ErrGasUintOverflow
+			}
+			if evm.IntraBlockState().Empty(addr) {
+				if gas, overflow = math.SafeAdd(gas, params.CallNewAccountGas); overflow {
+					return 0, ErrGasUintOverflow
+				}
+			}
+		}
+		return gas, nil
+	}
+}
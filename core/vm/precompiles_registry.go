@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+)
+
+// precompileRegistry holds the implementations which a chain config can
+// declare as chain-specific precompiles, by name.
+var (
+	precompileRegistryMu sync.RWMutex
+	precompileRegistry   = map[string]func() PrecompiledContract{
+		"ecrecover":  func() PrecompiledContract { return &ecrecover{} },
+		"sha256":     func() PrecompiledContract { return &sha256hash{} },
+		"ripemd160":  func() PrecompiledContract { return &ripemd160hash{} },
+		"identity":   func() PrecompiledContract { return &dataCopy{} },
+		"blake2f":    func() PrecompiledContract { return &blake2F{} },
+		"p256Verify": func() PrecompiledContract { return &p256Verify{} },
+	}
+)
+
+// RegisterPrecompile registers an implementation of chain-specific precompiles.
+// It is meant to be called from init functions, and panics if the name is
+// already registered.
+func RegisterPrecompile(name string, newContract func() PrecompiledContract) {
+	precompileRegistryMu.Lock()
+	defer precompileRegistryMu.Unlock()
+	if _, ok := precompileRegistry[name]; ok {
+		panic(fmt.Sprintf("precompile %q already registered", name))
+	}
+	precompileRegistry[name] = newContract
+}
+
+func registeredPrecompile(name string) (func() PrecompiledContract, bool) {
+	precompileRegistryMu.RLock()
+	defer precompileRegistryMu.RUnlock()
+	newContract, ok := precompileRegistry[name]
+	return newContract, ok
+}
+
+// ValidatePrecompiles checks that the chain-specific precompiles of the chain
+// config are registered and don't shadow other precompiles.
+func ValidatePrecompiles(config *chain.Config) error {
+	seen := map[libcommon.Address]struct{}{}
+	for _, p := range config.Precompiles {
+		if _, ok := registeredPrecompile(p.Name); !ok {
+			return fmt.Errorf("precompile %x: unknown implementation %q", p.Address, p.Name)
+		}
+		if _, ok := seen[p.Address]; ok {
+			return fmt.Errorf("precompile %x declared twice", p.Address)
+		}
+		seen[p.Address] = struct{}{}
+		for _, precompiles := range []map[libcommon.Address]PrecompiledContract{PrecompiledContractsPrague, PrecompiledContractsGranite} {
+			if _, ok := precompiles[p.Address]; ok {
+				return fmt.Errorf("precompile %x shadows a precompile of the protocol", p.Address)
+			}
+		}
+	}
+	return nil
+}
+
+// chainPrecompiles returns the chain-specific precompiles active with the
+// rules, or nil if there are none.
+func chainPrecompiles(rules *chain.Rules) map[libcommon.Address]PrecompiledContract {
+	if len(rules.Precompiles) == 0 {
+		return nil
+	}
+	precompiles := make(map[libcommon.Address]PrecompiledContract, len(rules.Precompiles))
+	for _, p := range rules.Precompiles {
+		newContract, ok := registeredPrecompile(p.Name)
+		if !ok {
+			continue // rejected by ValidatePrecompiles when the chain config is loaded
+		}
+		contract := newContract()
+		if p.Gas != nil {
+			contract = &configuredGasPrecompile{PrecompiledContract: contract, gas: *p.Gas}
+		}
+		precompiles[p.Address] = contract
+	}
+	return precompiles
+}
+
+// configuredGasPrecompile runs a precompile with the gas schedule of the chain config.
+type configuredGasPrecompile struct {
+	PrecompiledContract
+	gas chain.PrecompileGas
+}
+
+func (c *configuredGasPrecompile) RequiredGas(input []byte) uint64 {
+	words := ToWordSize(uint64(len(input)))
+	if c.gas.PerWord != 0 && words > (math.MaxUint64-c.gas.Base)/c.gas.PerWord {
+		return math.MaxUint64
+	}
+	return c.gas.Base + words*c.gas.PerWord
+}
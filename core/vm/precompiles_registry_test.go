@@ -0,0 +1,49 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/vm/evmtypes"
+	"github.com/erigontech/erigon/params"
+)
+
+func TestChainPrecompiles(t *testing.T) {
+	activation := uint64(10)
+	addr := libcommon.BytesToAddress([]byte{0x0b, 0x0b, 0xa0})
+	config := *params.TestChainConfig
+	config.Precompiles = []*chain.PrecompileConfig{{
+		Address:        addr,
+		Name:           "sha256",
+		ActivationTime: &activation,
+		Gas:            &chain.PrecompileGas{Base: 100, PerWord: 3},
+	}}
+	require.NoError(t, ValidatePrecompiles(&config))
+
+	before := NewEVM(evmtypes.BlockContext{Time: activation - 1}, evmtypes.TxContext{}, nil, &config, Config{})
+	_, ok := before.precompile(addr)
+	require.False(t, ok)
+	require.NotContains(t, ActivePrecompiles(before.ChainRules()), addr)
+
+	after := NewEVM(evmtypes.BlockContext{Time: activation}, evmtypes.TxContext{}, nil, &config, Config{})
+	p, ok := after.precompile(addr)
+	require.True(t, ok)
+	require.Contains(t, ActivePrecompiles(after.ChainRules()), addr)
+	require.Equal(t, uint64(100+2*3), p.RequiredGas(make([]byte, 33)))
+	out, err := p.Run([]byte("abc"))
+	require.NoError(t, err)
+	require.Equal(t, libcommon.HexToHash("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad").Bytes(), out)
+
+	// the precompiles of the fork are still there
+	_, ok = after.precompile(libcommon.BytesToAddress([]byte{1}))
+	require.True(t, ok)
+
+	config.Precompiles[0].Name = "unknown"
+	require.Error(t, ValidatePrecompiles(&config))
+	config.Precompiles[0].Name, config.Precompiles[0].Address = "sha256", libcommon.BytesToAddress([]byte{2})
+	require.Error(t, ValidatePrecompiles(&config))
+}
@@ -69,6 +69,9 @@ type Config struct {
 	PragueTime   *big.Int `json:"pragueTime,omitempty"`
 	OsakaTime    *big.Int `json:"osakaTime,omitempty"`
 
+	// EOFTime activates the EVM Object Format (EIP-7692) independently of the L1 fork schedule
+	EOFTime *big.Int `json:"eofTime,omitempty"`
+
 	BedrockBlock *big.Int `json:"bedrockBlock,omitempty"` // Bedrock switch block (nil = no fork, 0 = already on optimism bedrock)
 	RegolithTime *big.Int `json:"regolithTime,omitempty"` // Regolith switch time (nil = no fork, 0 = already on optimism regolith)
 	CanyonTime   *big.Int `json:"canyonTime,omitempty"`   // Canyon switch time (nil = no fork, 0 = already on optimism canyon)
@@ -102,6 +105,9 @@ type Config struct {
 	// Optimism config
 	Optimism *OptimismConfig `json:"optimism,omitempty"`
 
+	// Chain-specific precompiles, on top of the precompiles of the active fork
+	Precompiles []*PrecompileConfig `json:"precompiles,omitempty"`
+
 	Bor     BorConfig       `json:"-"`
 	BorJSON json.RawMessage `json:"bor,omitempty"`
 }
@@ -118,6 +124,38 @@ func (o *OptimismConfig) String() string {
 	return "optimism"
 }
 
+// PrecompileConfig declares a chain-specific precompile, run by one of the
+// implementations registered in core/vm.
+type PrecompileConfig struct {
+	Address        common.Address `json:"address"`
+	Name           string         `json:"name"`                     // name of the registered implementation
+	ActivationTime *uint64        `json:"activationTime,omitempty"` // nil = not active, 0 = active since genesis
+	Gas            *PrecompileGas `json:"gas,omitempty"`            // overrides the gas schedule of the implementation
+}
+
+// PrecompileGas is the gas schedule of a precompile: Base + PerWord for each
+// 32-byte word of the input.
+type PrecompileGas struct {
+	Base    uint64 `json:"base"`
+	PerWord uint64 `json:"perWord"`
+}
+
+// IsActive returns whether the precompile is active at the given time.
+func (p *PrecompileConfig) IsActive(time uint64) bool {
+	return p.ActivationTime != nil && *p.ActivationTime <= time
+}
+
+// ActivePrecompiles returns the chain-specific precompiles active at the given time.
+func (c *Config) ActivePrecompiles(time uint64) []*PrecompileConfig {
+	var active []*PrecompileConfig
+	for _, p := range c.Precompiles {
+		if p.IsActive(time) {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
 type BorConfig interface {
 	fmt.Stringer
 	IsAgra(num uint64) bool
@@ -130,7 +168,7 @@ type BorConfig interface {
 func (c *Config) String() string {
 	engine := c.getEngine()
 
-	return fmt.Sprintf("{ChainID: %v, Homestead: %v, DAO: %v, Tangerine Whistle: %v, Spurious Dragon: %v, Byzantium: %v, Constantinople: %v, Petersburg: %v, Istanbul: %v, Muir Glacier: %v, Berlin: %v, London: %v, Arrow Glacier: %v, Gray Glacier: %v, Terminal Total Difficulty: %v, Merge Netsplit: %v, Shanghai: %v, Cancun: %v, Prague: %v, Osaka: %v, BedrockBlock: %v, RegolithTime: %v, CanyonTime: %v, EcotoneTime: %v, FjordTime: %v, GraniteTime: %v, HoloceneTime: %v, Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v, Homestead: %v, DAO: %v, Tangerine Whistle: %v, Spurious Dragon: %v, Byzantium: %v, Constantinople: %v, Petersburg: %v, Istanbul: %v, Muir Glacier: %v, Berlin: %v, London: %v, Arrow Glacier: %v, Gray Glacier: %v, Terminal Total Difficulty: %v, Merge Netsplit: %v, Shanghai: %v, Cancun: %v, Prague: %v, Osaka: %v, EOF: %v, BedrockBlock: %v, RegolithTime: %v, CanyonTime: %v, EcotoneTime: %v, FjordTime: %v, GraniteTime: %v, HoloceneTime: %v, Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -151,6 +189,7 @@ func (c *Config) String() string {
 		c.CancunTime,
 		c.PragueTime,
 		c.OsakaTime,
+		c.EOFTime,
 		c.BedrockBlock,
 		c.RegolithTime,
 		c.CanyonTime,
@@ -279,6 +318,11 @@ func (c *Config) IsOsaka(time uint64) bool {
 	return isForked(c.OsakaTime, time)
 }
 
+// IsEOF returns whether time is either equal to the EOF activation time or greater.
+func (c *Config) IsEOF(time uint64) bool {
+	return isForked(c.EOFTime, time)
+}
+
 func (c *Config) IsBedrock(num uint64) bool {
 	return isForked(c.BedrockBlock, num)
 }
@@ -626,11 +670,12 @@ type Rules struct {
 	IsByzantium, IsConstantinople, IsPetersburg       bool
 	IsIstanbul, IsBerlin, IsLondon, IsShanghai        bool
 	IsCancun, IsNapoli                                bool
-	IsPrague, IsOsaka                                 bool
+	IsPrague, IsOsaka, IsEOF                          bool
 	IsAura                                            bool
 	IsOptimismBedrock, IsOptimismRegolith             bool
 	IsOptimismCanyon, IsOptimismFjord                 bool
 	IsOptimismGranite                                 bool
+	Precompiles                                       []*PrecompileConfig // chain-specific precompiles
 }
 
 // Rules ensures c's ChainID is not nil and returns a new Rules instance
@@ -656,12 +701,14 @@ func (c *Config) Rules(num uint64, time uint64) *Rules {
 		IsNapoli:           c.IsNapoli(num),
 		IsPrague:           c.IsPrague(time),
 		IsOsaka:            c.IsOsaka(time),
+		IsEOF:              c.IsEOF(time),
 		IsAura:             c.Aura != nil,
 		IsOptimismBedrock:  c.IsOptimismBedrock(num),
 		IsOptimismRegolith: c.IsOptimismRegolith(time),
 		IsOptimismCanyon:   c.IsOptimismCanyon(time),
 		IsOptimismFjord:    c.IsOptimismFjord(time),
 		IsOptimismGranite:  c.IsOptimismGranite(time),
+		Precompiles:        c.ActivePrecompiles(time),
 	}
 }
 
@@ -35,6 +35,7 @@ const (
 	ETH66 = 66
 	ETH67 = 67
 	ETH68 = 68
+	ETH69 = 69
 )
 
 var ProtoIds = map[uint]map[sentry.MessageId]struct{}{
@@ -98,6 +99,32 @@ var ProtoIds = map[uint]map[sentry.MessageId]struct{}{
 		sentry.MessageId_GET_POOLED_TRANSACTIONS_66:       struct{}{},
 		sentry.MessageId_POOLED_TRANSACTIONS_66:           struct{}{},
 	},
+	ETH69: {
+		sentry.MessageId_GET_BLOCK_HEADERS_66:             struct{}{},
+		sentry.MessageId_BLOCK_HEADERS_66:                 struct{}{},
+		sentry.MessageId_GET_BLOCK_BODIES_66:              struct{}{},
+		sentry.MessageId_BLOCK_BODIES_66:                  struct{}{},
+		sentry.MessageId_GET_RECEIPTS_69:                  struct{}{},
+		sentry.MessageId_RECEIPTS_69:                      struct{}{},
+		sentry.MessageId_TRANSACTIONS_66:                  struct{}{},
+		sentry.MessageId_NEW_POOLED_TRANSACTION_HASHES_68: struct{}{},
+		sentry.MessageId_GET_POOLED_TRANSACTIONS_66:       struct{}{},
+		sentry.MessageId_POOLED_TRANSACTIONS_66:           struct{}{},
+		sentry.MessageId_BLOCK_RANGE_UPDATE_69:            struct{}{},
+	},
+}
+
+// SnapProtoIds are the snap/1 message ids, the snap protocol runs alongside
+// any of the eth protocol versions.
+var SnapProtoIds = map[sentry.MessageId]struct{}{
+	sentry.MessageId_GET_ACCOUNT_RANGE_SNAP1:  struct{}{},
+	sentry.MessageId_ACCOUNT_RANGE_SNAP1:      struct{}{},
+	sentry.MessageId_GET_STORAGE_RANGES_SNAP1: struct{}{},
+	sentry.MessageId_STORAGE_RANGES_SNAP1:     struct{}{},
+	sentry.MessageId_GET_BYTE_CODES_SNAP1:     struct{}{},
+	sentry.MessageId_BYTE_CODES_SNAP1:         struct{}{},
+	sentry.MessageId_GET_TRIE_NODES_SNAP1:     struct{}{},
+	sentry.MessageId_TRIE_NODES_SNAP1:         struct{}{},
 }
 
 //go:generate mockgen -typed=true -destination=./sentry_client_mock.go -package=direct . SentryClient
@@ -159,6 +186,8 @@ func (c *SentryClientRemote) HandShake(ctx context.Context, in *emptypb.Empty, o
 		c.protocol = ETH67
 	case sentry.Protocol_ETH68:
 		c.protocol = ETH68
+	case sentry.Protocol_ETH69:
+		c.protocol = ETH69
 	default:
 		return nil, fmt.Errorf("unexpected protocol: %d", reply.Protocol)
 	}
@@ -387,6 +416,8 @@ func filterIds(in []sentry.MessageId, protocol uint) (filtered []sentry.MessageI
 	for _, id := range in {
 		if _, ok := ProtoIds[protocol][id]; ok {
 			filtered = append(filtered, id)
+		} else if _, ok := SnapProtoIds[id]; ok {
+			filtered = append(filtered, id)
 		}
 	}
 	return filtered
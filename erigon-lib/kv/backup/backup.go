@@ -23,13 +23,7 @@ import (
 )
 
 func OpenPair(from, to string, label kv.Label, targetPageSize datasize.ByteSize, logger log.Logger) (kv.RoDB, kv.RwDB) {
-	const ThreadsHardLimit = 9_000
-	src := mdbx2.NewMDBX(logger).Path(from).
-		Label(label).
-		RoTxsLimiter(semaphore.NewWeighted(ThreadsHardLimit)).
-		WithTableCfg(func(_ kv.TableCfg) kv.TableCfg { return kv.TablesCfgByLabel(label) }).
-		Flags(func(flags uint) uint { return flags | mdbx.Accede }).
-		MustOpen()
+	src := OpenSource(from, label, logger)
 	if targetPageSize <= 0 {
 		targetPageSize = datasize.ByteSize(src.PageSize())
 	}
@@ -48,13 +42,29 @@ func OpenPair(from, to string, label kv.Label, targetPageSize datasize.ByteSize,
 	return src, dst
 }
 
+// OpenSource opens the database to backup next to the process which owns it.
+func OpenSource(from string, label kv.Label, logger log.Logger) kv.RoDB {
+	const ThreadsHardLimit = 9_000
+	return mdbx2.NewMDBX(logger).Path(from).
+		Label(label).
+		RoTxsLimiter(semaphore.NewWeighted(ThreadsHardLimit)).
+		WithTableCfg(func(_ kv.TableCfg) kv.TableCfg { return kv.TablesCfgByLabel(label) }).
+		Flags(func(flags uint) uint { return flags | mdbx.Accede }).
+		MustOpen()
+}
+
 func Kv2kv(ctx context.Context, src kv.RoDB, dst kv.RwDB, tables []string, readAheadThreads int, logger log.Logger) error {
 	srcTx, err1 := src.BeginRo(ctx)
 	if err1 != nil {
 		return err1
 	}
 	defer srcTx.Rollback()
+	return Kv2kvTx(ctx, src, srcTx, dst, tables, readAheadThreads, logger)
+}
 
+// Kv2kvTx copies the tables of src to dst as they are seen by srcTx, which
+// lets the caller pin the point in time of the copy before it starts.
+func Kv2kvTx(ctx context.Context, src kv.RoDB, srcTx kv.Tx, dst kv.RwDB, tables []string, readAheadThreads int, logger log.Logger) error {
 	commitEvery := time.NewTicker(5 * time.Minute)
 	defer commitEvery.Stop()
 	logEvery := time.NewTicker(20 * time.Second)
@@ -0,0 +1,135 @@
+package backup
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/erigontech/erigon-lib/kv"
+)
+
+// offsets in the meta pages of mdbx.dat: the page header, then the MDBX_meta struct
+const (
+	mdbxNumMetas      = 3
+	mdbxPageTxnID     = 0
+	mdbxPagePgno      = 16
+	mdbxMetaTxnIDA    = 28
+	mdbxMetaGeoNext   = 56
+	mdbxMetaSign      = 188
+	mdbxMetaTxnIDB    = 196
+	mdbxSignSteady    = ^uint64(0)
+	dataFileAlignment = 64 * 1024 // rounds the copy up to the system page size
+)
+
+// ErrMetaRecycled is returned by OpenDataFile when the commits which followed
+// the beginning of the read transaction recycled its meta page.
+var ErrMetaRecycled = errors.New("meta page recycled")
+
+// DataFile is the mdbx.dat of a database as seen by a read transaction, which
+// can be streamed, e.g. into a tar archive, without a copy of the database on
+// disk. It is what `mdbx_copy` writes without compaction: the pages the read
+// transaction can reach are never overwritten while it is open, so they are
+// read straight from the file, and its meta page is the only one of the copy.
+type DataFile struct {
+	f        *os.File
+	meta     []byte
+	pageSize int64
+	size     int64
+}
+
+// OpenDataFile opens the mdbx.dat of the database of dir, which tx reads, right
+// after tx began: the next commits recycle the meta page of tx.
+// tx must stay open until the DataFile is written.
+func OpenDataFile(dir string, db kv.RoDB, tx kv.Tx) (*DataFile, error) {
+	f, err := os.Open(filepath.Join(dir, "mdbx.dat"))
+	if err != nil {
+		return nil, err
+	}
+	pageSize := int64(db.PageSize())
+	page := make([]byte, pageSize)
+	for i := int64(0); i < mdbxNumMetas; i++ {
+		if _, err := f.ReadAt(page, i*pageSize); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("read meta page %d of %s: %w", i, dir, err)
+		}
+		txnA, txnB := binary.LittleEndian.Uint64(page[mdbxMetaTxnIDA:]), binary.LittleEndian.Uint64(page[mdbxMetaTxnIDB:])
+		if txnA != tx.ViewID() || txnB != tx.ViewID() {
+			continue
+		}
+		next := int64(binary.LittleEndian.Uint32(page[mdbxMetaGeoNext:]))
+		size := (next*pageSize + dataFileAlignment - 1) / dataFileAlignment * dataFileAlignment
+		if stat, err := f.Stat(); err != nil {
+			f.Close()
+			return nil, err
+		} else if size > stat.Size() {
+			size = next * pageSize
+		}
+		return &DataFile{f: f, meta: page, pageSize: pageSize, size: size}, nil
+	}
+	f.Close()
+	return nil, fmt.Errorf("transaction %d of %s: %w", tx.ViewID(), dir, ErrMetaRecycled)
+}
+
+// BeginDataFile begins a read transaction of the database of dir and opens its
+// DataFile, with a new transaction while the meta page of the previous one was
+// recycled, up to attempts times. The transaction must stay open until the
+// DataFile is written.
+func BeginDataFile(ctx context.Context, dir string, db kv.RoDB, attempts int) (kv.Tx, *DataFile, error) {
+	for i := 1; ; i++ {
+		tx, err := db.BeginRo(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		dataFile, err := OpenDataFile(dir, db, tx)
+		if err == nil {
+			return tx, dataFile, nil
+		}
+		tx.Rollback()
+		if !errors.Is(err, ErrMetaRecycled) || i >= attempts {
+			return nil, nil, err
+		}
+	}
+}
+
+// Size returns the number of bytes WriteTo writes.
+func (d *DataFile) Size() int64 { return d.size }
+
+// WriteTo writes the mdbx.dat: the meta page of the read transaction, marked as
+// synced, with two empty meta pages next to it, then the pages of the database.
+func (d *DataFile) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for i := 0; i < mdbxNumMetas; i++ {
+		page := make([]byte, d.pageSize)
+		copy(page, d.meta)
+		if i < mdbxNumMetas-1 {
+			// an older meta is never preferred to the one of the highest transaction id
+			binary.LittleEndian.PutUint64(page[mdbxPageTxnID:], 0)
+			binary.LittleEndian.PutUint64(page[mdbxMetaTxnIDA:], 0)
+			binary.LittleEndian.PutUint64(page[mdbxMetaTxnIDB:], 0)
+			binary.LittleEndian.PutUint64(page[mdbxMetaSign:], 0)
+		} else {
+			binary.LittleEndian.PutUint64(page[mdbxMetaSign:], mdbxSignSteady)
+		}
+		binary.LittleEndian.PutUint32(page[mdbxPagePgno:], uint32(i))
+		n, err := w.Write(page)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	n, err := io.Copy(w, io.NewSectionReader(d.f, written, d.size-written))
+	written += n
+	if err != nil {
+		return written, err
+	}
+	if written != d.size {
+		return written, fmt.Errorf("short read of %s: %d of %d bytes", d.f.Name(), written, d.size)
+	}
+	return written, nil
+}
+
+func (d *DataFile) Close() error { return d.f.Close() }
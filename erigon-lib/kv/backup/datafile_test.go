@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/mdbx"
+	"github.com/erigontech/erigon-lib/log/v3"
+)
+
+func TestDataFile(t *testing.T) {
+	logger := log.New()
+	ctx := context.Background()
+	from, to := t.TempDir(), t.TempDir()
+	db := mdbx.NewMDBX(logger).Path(from).MapSize(64 * datasize.MB).MustOpen()
+	defer db.Close()
+
+	put := func(first, last uint64, value string) {
+		require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+			for i := first; i < last; i++ {
+				if err := tx.Put(kv.Headers, binary.BigEndian.AppendUint64(nil, i), []byte(value)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+	put(0, 1000, "before")
+
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	file, err := OpenDataFile(from, db, tx)
+	require.NoError(t, err)
+	defer file.Close()
+
+	// the writers move on while the copy is being made, recycling the meta page of tx
+	for i := 0; i < 5; i++ {
+		put(0, 2000, "after")
+	}
+
+	out, err := os.Create(filepath.Join(to, "mdbx.dat"))
+	require.NoError(t, err)
+	n, err := file.WriteTo(out)
+	require.NoError(t, err)
+	require.Equal(t, file.Size(), n)
+	require.NoError(t, out.Close())
+
+	copied := mdbx.NewMDBX(logger).Path(to).MapSize(64 * datasize.MB).MustOpen()
+	defer copied.Close()
+	require.NoError(t, copied.View(ctx, func(tx kv.Tx) error {
+		count := 0
+		err := tx.ForEach(kv.Headers, nil, func(k, v []byte) error {
+			require.Equal(t, "before", string(v))
+			count++
+			return nil
+		})
+		require.Equal(t, 1000, count)
+		return err
+	}))
+}
+
+// recyclingDB commits right after the first read transactions it begins, as
+// writers could before the DataFile of the transaction is opened.
+type recyclingDB struct {
+	kv.RwDB
+	recycle int
+	begun   int
+	commits uint64
+}
+
+func (db *recyclingDB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	tx, err := db.RwDB.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if db.begun++; db.begun <= db.recycle {
+		// every meta page is overwritten once per mdbxNumMetas commits, which must
+		// change the database not to be skipped
+		done := make(chan error)
+		go func() {
+			var err error
+			for i := 0; i < mdbxNumMetas && err == nil; i++ {
+				db.commits++
+				err = db.Update(ctx, func(tx kv.RwTx) error {
+					return tx.Put(kv.Headers, binary.BigEndian.AppendUint64(nil, db.commits), []byte("after"))
+				})
+			}
+			done <- err
+		}()
+		if err := <-done; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+func TestBeginDataFile(t *testing.T) {
+	logger := log.New()
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := mdbx.NewMDBX(logger).Path(dir).MapSize(64 * datasize.MB).MustOpen()
+	defer db.Close()
+
+	recycling := &recyclingDB{RwDB: db, recycle: 2}
+	tx, err := recycling.BeginRo(ctx)
+	require.NoError(t, err)
+	_, err = OpenDataFile(dir, recycling, tx)
+	tx.Rollback()
+	require.ErrorIs(t, err, ErrMetaRecycled)
+
+	// the meta page of the second transaction is recycled too, not the one of the third
+	recycling.begun = 0
+	_, _, err = BeginDataFile(ctx, dir, recycling, 2)
+	require.ErrorIs(t, err, ErrMetaRecycled)
+	recycling.begun = 0
+	tx, file, err := BeginDataFile(ctx, dir, recycling, 3)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	defer file.Close()
+	require.Equal(t, 3, recycling.begun)
+}
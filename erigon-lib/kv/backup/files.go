@@ -0,0 +1,150 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FilesStat tells what CopyFiles did with the files of a backup.
+type FilesStat struct {
+	Linked, Copied, Skipped, Removed int
+	Bytes                            int64 // bytes copied, hard links don't count
+}
+
+// CopyFiles backs up the immutable files of the directory tree from into to:
+// snapshot segments, their indices and blob sidecars. The files are never
+// modified once published - only deleted after a merge - so a hard link is a
+// consistent copy of them, and the files are copied only when from and to are
+// on different filesystems.
+//
+// Directories holding an mdbx database and unfinished `.tmp` files are left
+// out, the databases are copied by Kv2kv.
+//
+// The files of to which no longer are in from are removed, since their
+// content was merged into the files which replaced them. In incremental mode
+// the files which already are in to with the same size are skipped.
+func CopyFiles(from, to string, incremental bool) (FilesStat, error) {
+	var stat FilesStat
+	if _, err := os.Stat(from); errors.Is(err, fs.ErrNotExist) {
+		return stat, nil
+	}
+	seen := map[string]struct{}{}
+	err := WalkFiles(from, func(path, rel string, info fs.FileInfo) error {
+		seen[rel] = struct{}{}
+		target := filepath.Join(to, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0740); err != nil {
+			return err
+		}
+		if existing, err := os.Stat(target); err == nil {
+			if incremental && existing.Size() == info.Size() {
+				stat.Skipped++
+				return nil
+			}
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+		if err := os.Link(path, target); err == nil {
+			stat.Linked++
+			return nil
+		}
+		n, err := copyFile(path, target)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // merged away in the meantime, its content is in a newer file
+		}
+		if err != nil {
+			return err
+		}
+		stat.Copied++
+		stat.Bytes += n
+		return nil
+	})
+	if err != nil {
+		return stat, err
+	}
+	err = filepath.WalkDir(to, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if isDBDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(to, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := seen[rel]; ok {
+			return nil
+		}
+		stat.Removed++
+		return os.Remove(path)
+	})
+	return stat, err
+}
+
+// WalkFiles calls fn for the immutable files of the directory tree from, the
+// ones CopyFiles backs up, with their path relative to from.
+func WalkFiles(from string, fn func(path, rel string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if isDBDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // merged away in the meantime
+		}
+		if err != nil {
+			return err
+		}
+		return fn(path, rel, info)
+	})
+}
+
+// isDBDir tells whether dir holds an mdbx database.
+func isDBDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "mdbx.dat"))
+	return err == nil
+}
+
+func copyFile(from, to string) (int64, error) {
+	src, err := os.Open(from)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return n, fmt.Errorf("copy %s: %w", from, err)
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return n, err
+	}
+	return n, dst.Close()
+}
@@ -0,0 +1,45 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFiles(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(from, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0740))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0640))
+	}
+	write("v1-000000-000500-headers.seg", "headers")
+	write("v1-000500-001000-headers.seg", "headers2")
+	write("idx/v1-accounts.0-64.ef", "index")
+	write("v1-001000-001500-headers.seg.tmp", "unfinished")
+	write("db/mdbx.dat", "database")
+
+	stat, err := CopyFiles(from, to, false)
+	require.NoError(t, err)
+	require.Equal(t, 3, stat.Linked+stat.Copied)
+	content, err := os.ReadFile(filepath.Join(to, "idx", "v1-accounts.0-64.ef"))
+	require.NoError(t, err)
+	require.Equal(t, "index", string(content))
+	require.NoFileExists(t, filepath.Join(to, "v1-001000-001500-headers.seg.tmp"))
+	require.NoDirExists(t, filepath.Join(to, "db"))
+
+	// the two segments are merged, and a new one is published
+	require.NoError(t, os.Remove(filepath.Join(from, "v1-000000-000500-headers.seg")))
+	require.NoError(t, os.Remove(filepath.Join(from, "v1-000500-001000-headers.seg")))
+	write("v1-000000-001000-headers.seg", "merged headers")
+	write("v1-001000-001500-headers.seg", "headers3")
+
+	stat, err = CopyFiles(from, to, true)
+	require.NoError(t, err)
+	require.Equal(t, FilesStat{Linked: stat.Linked, Copied: stat.Copied, Bytes: stat.Bytes, Skipped: 1, Removed: 2}, stat)
+	require.Equal(t, 2, stat.Linked+stat.Copied)
+	require.NoFileExists(t, filepath.Join(to, "v1-000000-000500-headers.seg"))
+	require.FileExists(t, filepath.Join(to, "v1-000000-001000-headers.seg"))
+}
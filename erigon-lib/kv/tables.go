@@ -277,6 +277,9 @@ const (
 	Receipts = "Receipt"        // block_num_u64 -> canonical block receipts (non-canonical are not stored)
 	Log      = "TransactionLog" // block_num_u64 + txId -> logs of transaction
 
+	// LegacyTxMeta keeps the L1 metadata of the transactions of imported legacy (pre-Bedrock) blocks
+	LegacyTxMeta = "LegacyTxMeta" // block_num_u64 + tx_index_u32 -> rlp(tx meta)
+
 	// Stores bitmap indices - in which block numbers saw logs of given 'address' or 'topic'
 	// [addr or topic] + [2 bytes inverted shard number] -> bitmap(blockN)
 	// indices are sharded - because some bitmaps are >1Mb and when new incoming blocks process it
@@ -297,12 +300,38 @@ const (
 	CallFromIndex = "CallFromIndex"
 	CallToIndex   = "CallToIndex"
 
+	// Otterscan token transfer indices, built from the Transfer, TransferSingle and TransferBatch logs by the
+	// optional OtsTokenIndex stage. They are DupSort-ed tables of the transfers of a holder (sender or
+	// recipient), newest last:
+	// holder -> block_num_u64 + tx_index_u32 + log_index_u32 (in block) + token
+	OtsERC20Transfers   = "OtsERC20Transfers"
+	OtsERC721Transfers  = "OtsERC721Transfers"
+	OtsERC1155Transfers = "OtsERC1155Transfers"
+	// OtsTokenTransfers are the transfers of a token, of any standard, DupSort-ed:
+	// token -> block_num_u64 + tx_index_u32 + log_index_u32 (in block)
+	OtsTokenTransfers = "OtsTokenTransfers"
+	// OtsHoldings are the tokens a holder ever sent or received, DupSort-ed:
+	// holder -> token + standard_u8 + first_block_num_u64
+	OtsHoldings = "OtsHoldings"
+
 	// Cumulative indexes for estimation of stage execution
 	CumulativeGasIndex         = "CumulativeGasIndex"
 	CumulativeTransactionIndex = "CumulativeTransactionIndex"
 
 	TxLookup = "BlockTransactionLookup" // hash -> transaction/receipt lookup metadata
 
+	// DepositLookup locates the deposit transactions of OP Stack chains by source hash, built by the optional
+	// DepositLookup stage:
+	// source_hash -> block_num_u64 + tx_index_u32 + l1_block_num_u64 + l1_block_hash + l1_log_index_u64
+	// where the L1 block is the L1 origin of the block, and the L1 log index is the one of the TransactionDeposited
+	// log of the user deposits (max uint64 for the other deposits)
+	DepositLookup = "DepositLookup"
+
+	// BadBlockForensics keeps a reproduction bundle of the latest blocks rejected by the payload validation:
+	// block_num_u64 + block_hash -> json of the bundle (the block, its parent header, the prestate of the state it
+	// read, the receipts up to the failure, the error, the chain config and the fork flags)
+	BadBlockForensics = "BadBlockForensics"
+
 	ConfigTable = "Config" // config prefix for the db
 
 	// Progress of sync stages: stageName -> stageData
@@ -548,9 +577,11 @@ var ChaindataTables = []string{
 	ContractCode,
 	HeaderNumber,
 	BadHeaderNumber,
+	BadBlockForensics,
 	BlockBody,
 	Receipts,
 	TxLookup,
+	DepositLookup,
 	ConfigTable,
 	CurrentExecutionPayload,
 	DatabaseInfo,
@@ -574,9 +605,15 @@ var ChaindataTables = []string{
 	CallTraceSet,
 	CallFromIndex,
 	CallToIndex,
+	OtsERC20Transfers,
+	OtsERC721Transfers,
+	OtsERC1155Transfers,
+	OtsTokenTransfers,
+	OtsHoldings,
 	CumulativeGasIndex,
 	CumulativeTransactionIndex,
 	Log,
+	LegacyTxMeta,
 	Sequence,
 	EthTx,
 	NonCanonicalTxs,
@@ -735,6 +772,18 @@ var DiagnosticsTables = []string{
 	DiagSyncStages,
 }
 
+// ConsensusTables - the tables of the clique, aura and bor databases, next to the chaindata
+var ConsensusTables = []string{
+	DatabaseInfo,
+	Migrations,
+	CliqueSeparate,
+	CliqueSnapshot,
+	CliqueLastSnapshot,
+	Epoch,
+	PendingEpoch,
+	BorSeparate,
+}
+
 type CmpFunc func(k1, k2, v1, v2 []byte) int
 
 type TableCfg map[string]TableCfgItem
@@ -786,6 +835,12 @@ var ChaindataTablesCfg = TableCfg{
 	},
 	CallTraceSet: {Flags: DupSort},
 
+	OtsERC20Transfers:   {Flags: DupSort},
+	OtsERC721Transfers:  {Flags: DupSort},
+	OtsERC1155Transfers: {Flags: DupSort},
+	OtsTokenTransfers:   {Flags: DupSort},
+	OtsHoldings:         {Flags: DupSort},
+
 	TblAccountKeys:           {Flags: DupSort},
 	TblAccountHistoryKeys:    {Flags: DupSort},
 	TblAccountHistoryVals:    {Flags: DupSort},
@@ -838,6 +893,7 @@ var TxpoolTablesCfg = TableCfg{}
 var SentryTablesCfg = TableCfg{}
 var DownloaderTablesCfg = TableCfg{}
 var DiagnosticsTablesCfg = TableCfg{}
+var ConsensusTablesCfg = TableCfg{}
 var ReconTablesCfg = TableCfg{
 	PlainStateD:    {Flags: DupSort},
 	CodeD:          {Flags: DupSort},
@@ -848,6 +904,8 @@ func TablesCfgByLabel(label Label) TableCfg {
 	switch label {
 	case ChainDB:
 		return ChaindataTablesCfg
+	case ConsensusDB:
+		return ConsensusTablesCfg
 	case TxPoolDB:
 		return TxpoolTablesCfg
 	case SentryDB:
@@ -924,6 +982,13 @@ func reinit() {
 			DiagnosticsTablesCfg[name] = TableCfgItem{}
 		}
 	}
+
+	for _, name := range ConsensusTables {
+		_, ok := ConsensusTablesCfg[name]
+		if !ok {
+			ConsensusTablesCfg[name] = TableCfgItem{}
+		}
+	}
 }
 
 // Temporal
@@ -0,0 +1,201 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package interop validates the cross-chain executing messages of the OP Stack
+// interop set. A transaction executes a message of another chain through the
+// CrossL2Inbox predeploy, declaring the message in its access list. The
+// validity of the initiating message is attested by the op-supervisor, which
+// is queried by the txpool on ingress and by the block builder before
+// inclusion.
+package interop
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/holiman/uint256"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/types"
+)
+
+// CrossL2InboxAddr is the address of the CrossL2Inbox predeploy.
+var CrossL2InboxAddr = types.CrossL2InboxAddr
+
+// ExecutingMessageTopic is the topic of the ExecutingMessage event emitted by
+// the CrossL2Inbox for every executed message.
+var ExecutingMessageTopic = keccak([]byte("ExecutingMessage(bytes32,(address,uint256,uint256,uint256,uint256))"))
+
+// Prefixes of the CrossL2Inbox access list entries.
+const (
+	prefixLookup           = 1
+	prefixChainIDExtension = 2
+	prefixChecksum         = 3
+)
+
+// SafetyLevel is the safety of an initiating message as tracked by the supervisor.
+type SafetyLevel string
+
+const (
+	Finalized   SafetyLevel = "finalized"
+	CrossSafe   SafetyLevel = "safe"
+	LocalSafe   SafetyLevel = "local-safe"
+	CrossUnsafe SafetyLevel = "cross-unsafe"
+	LocalUnsafe SafetyLevel = "unsafe"
+	Invalid     SafetyLevel = "invalid"
+)
+
+func (lvl SafetyLevel) rank() int {
+	switch lvl {
+	case Finalized:
+		return 5
+	case CrossSafe:
+		return 4
+	case LocalSafe:
+		return 3
+	case CrossUnsafe:
+		return 2
+	case LocalUnsafe:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeastAsSafe reports whether lvl is at least as safe as min.
+func (lvl SafetyLevel) AtLeastAsSafe(min SafetyLevel) bool {
+	return lvl.rank() >= min.rank()
+}
+
+// ParseSafetyLevel parses the name of a safety level.
+func ParseSafetyLevel(s string) (SafetyLevel, error) {
+	lvl := SafetyLevel(s)
+	if lvl.rank() == 0 {
+		return "", fmt.Errorf("unknown safety level %q", s)
+	}
+	return lvl, nil
+}
+
+// Identifier locates an initiating message: a log of another chain of the
+// interop set.
+type Identifier struct {
+	Origin      common.Address
+	BlockNumber uint64
+	LogIndex    uint32
+	Timestamp   uint64
+	ChainID     uint256.Int
+}
+
+// Message is an executing message: the identifier of the initiating message
+// and the hash of its payload.
+type Message struct {
+	Identifier  Identifier
+	PayloadHash common.Hash
+}
+
+// ExecutingMessageFromLog decodes the executing message of an ExecutingMessage
+// event. It returns nil if the log is not such an event of the CrossL2Inbox.
+func ExecutingMessageFromLog(addr common.Address, topics []common.Hash, data []byte) (*Message, error) {
+	if addr != CrossL2InboxAddr || len(topics) == 0 || topics[0] != ExecutingMessageTopic {
+		return nil, nil
+	}
+	if len(topics) != 2 || len(data) != 5*32 {
+		return nil, fmt.Errorf("malformed ExecutingMessage event: %d topics, %d bytes of data", len(topics), len(data))
+	}
+	word := func(i int) []byte { return data[i*32 : (i+1)*32] }
+	for i := 1; i < 4; i++ {
+		if !isZero(word(i)[:24]) {
+			return nil, fmt.Errorf("malformed ExecutingMessage event: identifier field %d overflows", i)
+		}
+	}
+	if !isZero(word(0)[:12]) || !isZero(word(2)[24:28]) {
+		return nil, errors.New("malformed ExecutingMessage event: identifier field overflows")
+	}
+	msg := &Message{PayloadHash: topics[1]}
+	copy(msg.Identifier.Origin[:], word(0)[12:])
+	msg.Identifier.BlockNumber = binary.BigEndian.Uint64(word(1)[24:])
+	msg.Identifier.LogIndex = binary.BigEndian.Uint32(word(2)[28:])
+	msg.Identifier.Timestamp = binary.BigEndian.Uint64(word(3)[24:])
+	msg.Identifier.ChainID.SetBytes32(word(4))
+	return msg, nil
+}
+
+// Checksum returns the checksum which commits to the identifier and payload of
+// the message, as declared in the access list of the executing transaction.
+func (m *Message) Checksum() common.Hash {
+	logHash := keccak(m.Identifier.Origin[:], m.PayloadHash[:])
+	idPacked := make([]byte, 12, 32)
+	idPacked = binary.BigEndian.AppendUint64(idPacked, m.Identifier.BlockNumber)
+	idPacked = binary.BigEndian.AppendUint64(idPacked, m.Identifier.Timestamp)
+	idPacked = binary.BigEndian.AppendUint32(idPacked, m.Identifier.LogIndex)
+	idLogHash := keccak(logHash[:], idPacked)
+	chainID := m.Identifier.ChainID.Bytes32()
+	checksum := keccak(idLogHash[:], chainID[:])
+	checksum[0] = prefixChecksum
+	return checksum
+}
+
+// AccessListEntries returns the CrossL2Inbox access list entries declaring the
+// message: the lookup entry, the chain ID extension if the chain ID does not
+// fit in 64 bits, and the checksum.
+func (m *Message) AccessListEntries() []common.Hash {
+	var lookup common.Hash
+	lookup[0] = prefixLookup
+	binary.BigEndian.PutUint64(lookup[4:12], m.Identifier.ChainID[0])
+	binary.BigEndian.PutUint64(lookup[12:20], m.Identifier.BlockNumber)
+	binary.BigEndian.PutUint64(lookup[20:28], m.Identifier.Timestamp)
+	binary.BigEndian.PutUint32(lookup[28:32], m.Identifier.LogIndex)
+	entries := []common.Hash{lookup}
+	if !m.Identifier.ChainID.IsUint64() {
+		var ext common.Hash
+		ext[0] = prefixChainIDExtension
+		chainID := m.Identifier.ChainID.Bytes32()
+		copy(ext[8:], chainID[:24])
+		entries = append(entries, ext)
+	}
+	return append(entries, m.Checksum())
+}
+
+// Checksums returns the message checksums of CrossL2Inbox access list entries.
+func Checksums(entries []common.Hash) []common.Hash {
+	var checksums []common.Hash
+	for _, e := range entries {
+		if e[0] == prefixChecksum {
+			checksums = append(checksums, e)
+		}
+	}
+	return checksums
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func keccak(data ...[]byte) (h common.Hash) {
+	d := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		d.Write(b)
+	}
+	d.Sum(h[:0])
+	return h
+}
@@ -0,0 +1,116 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package interop
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+)
+
+func testMessage(chainID uint64) *Message {
+	return &Message{
+		Identifier: Identifier{
+			Origin:      common.HexToAddress("0x1234"),
+			BlockNumber: 100,
+			LogIndex:    3,
+			Timestamp:   1700000000,
+			ChainID:     *uint256.NewInt(chainID),
+		},
+		PayloadHash: common.HexToHash("0xabcd"),
+	}
+}
+
+func TestExecutingMessageFromLog(t *testing.T) {
+	msg := testMessage(288)
+	data := make([]byte, 5*32)
+	copy(data[12:32], msg.Identifier.Origin[:])
+	uint256.NewInt(msg.Identifier.BlockNumber).WriteToSlice(data[32:64])
+	uint256.NewInt(uint64(msg.Identifier.LogIndex)).WriteToSlice(data[64:96])
+	uint256.NewInt(msg.Identifier.Timestamp).WriteToSlice(data[96:128])
+	msg.Identifier.ChainID.WriteToSlice(data[128:160])
+	topics := []common.Hash{ExecutingMessageTopic, msg.PayloadHash}
+
+	decoded, err := ExecutingMessageFromLog(CrossL2InboxAddr, topics, data)
+	require.NoError(t, err)
+	require.Equal(t, msg, decoded)
+
+	decoded, err = ExecutingMessageFromLog(common.HexToAddress("0x01"), topics, data)
+	require.NoError(t, err)
+	require.Nil(t, decoded)
+
+	_, err = ExecutingMessageFromLog(CrossL2InboxAddr, topics, data[:128])
+	require.Error(t, err)
+}
+
+func TestAccessListEntries(t *testing.T) {
+	msg := testMessage(288)
+	entries := msg.AccessListEntries()
+	require.Len(t, entries, 2)
+	require.Equal(t, byte(prefixLookup), entries[0][0])
+	require.Equal(t, []common.Hash{msg.Checksum()}, Checksums(entries))
+
+	msg.Identifier.ChainID.Lsh(&msg.Identifier.ChainID, 128)
+	require.Len(t, msg.AccessListEntries(), 3)
+	require.NotEqual(t, entries[1], msg.Checksum())
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	stub := NewStub()
+	safe, unsafe, unknown := testMessage(1), testMessage(2), testMessage(3)
+	stub.Add(safe, CrossSafe)
+	stub.Add(unsafe, LocalUnsafe)
+
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	for name, supervisor := range map[string]Supervisor{"stub": stub, "rpc": NewClient(server.URL)} {
+		t.Run(name, func(t *testing.T) {
+			checker := NewChecker(supervisor, CrossUnsafe, time.Second)
+			require.NoError(t, checker.Check(ctx, safe.AccessListEntries(), 1))
+			require.NoError(t, checker.Check(ctx, nil, 1))
+			require.ErrorIs(t, checker.Check(ctx, unsafe.AccessListEntries(), 1), ErrInvalidMessage)
+			require.ErrorIs(t, checker.Check(ctx, append(safe.AccessListEntries(), unknown.AccessListEntries()...), 1), ErrInvalidMessage)
+		})
+	}
+
+	var checker *Checker
+	require.NoError(t, checker.Check(ctx, unknown.AccessListEntries(), 1))
+
+	server.Close()
+	err := NewChecker(NewClient(server.URL), CrossUnsafe, time.Second).Check(ctx, safe.AccessListEntries(), 1)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrInvalidMessage))
+}
+
+func TestParseSafetyLevel(t *testing.T) {
+	lvl, err := ParseSafetyLevel("cross-unsafe")
+	require.NoError(t, err)
+	require.Equal(t, CrossUnsafe, lvl)
+	require.True(t, Finalized.AtLeastAsSafe(lvl))
+	require.False(t, LocalUnsafe.AtLeastAsSafe(lvl))
+	_, err = ParseSafetyLevel("invalid")
+	require.Error(t, err)
+}
@@ -0,0 +1,100 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package interop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/erigontech/erigon-lib/common"
+)
+
+// Stub is an in-memory supervisor for tests and local devnets: it knows the
+// messages added to it, at the given safety. It can be used directly or served
+// over JSON-RPC as an http.Handler.
+type Stub struct {
+	mu       sync.Mutex
+	messages map[common.Hash]SafetyLevel // checksum -> safety
+}
+
+func NewStub() *Stub {
+	return &Stub{messages: map[common.Hash]SafetyLevel{}}
+}
+
+// Add makes the message known at the given safety.
+func (s *Stub) Add(msg *Message, safety SafetyLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[msg.Checksum()] = safety
+}
+
+func (s *Stub) CheckAccessList(_ context.Context, entries []common.Hash, minSafety SafetyLevel, _ ExecutingDescriptor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, checksum := range Checksums(entries) {
+		safety, ok := s.messages[checksum]
+		if !ok {
+			return fmt.Errorf("%w: unknown message %x", ErrInvalidMessage, checksum)
+		}
+		if !safety.AtLeastAsSafe(minSafety) {
+			return fmt.Errorf("%w: message %x is %s, expected %s", ErrInvalidMessage, checksum, safety, minSafety)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves supervisor_checkAccessList.
+func (s *Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     uint64            `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := rpcResponse{Version: "2.0", ID: req.ID, Result: json.RawMessage("null")}
+	if err := s.serve(r.Context(), req.Method, req.Params); err != nil {
+		res.Result, res.Error = nil, &rpcError{Code: -32000, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *Stub) serve(ctx context.Context, method string, params []json.RawMessage) error {
+	if method != "supervisor_checkAccessList" {
+		return fmt.Errorf("method %s not found", method)
+	}
+	if len(params) != 3 {
+		return fmt.Errorf("expected 3 params, got %d", len(params))
+	}
+	var (
+		entries    []common.Hash
+		minSafety  SafetyLevel
+		descriptor ExecutingDescriptor
+	)
+	for i, v := range []interface{}{&entries, &minSafety, &descriptor} {
+		if err := json.Unmarshal(params[i], v); err != nil {
+			return fmt.Errorf("invalid param %d: %w", i, err)
+		}
+	}
+	return s.CheckAccessList(ctx, entries, minSafety, descriptor)
+}
@@ -0,0 +1,137 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package interop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+)
+
+// ErrInvalidMessage is returned when the supervisor rejects an executing message.
+var ErrInvalidMessage = errors.New("invalid executing message")
+
+// ExecutingDescriptor describes the context in which the messages are executed.
+type ExecutingDescriptor struct {
+	Timestamp hexutil.Uint64 `json:"timestamp"` // timestamp of the executing block
+}
+
+// Supervisor checks executing messages against the op-supervisor.
+type Supervisor interface {
+	// CheckAccessList returns ErrInvalidMessage if any of the messages declared
+	// by the CrossL2Inbox access list entries is unknown to the supervisor or
+	// less safe than minSafety.
+	CheckAccessList(ctx context.Context, entries []common.Hash, minSafety SafetyLevel, descriptor ExecutingDescriptor) error
+}
+
+// Checker validates executing messages with a supervisor at a configured
+// safety level. A nil Checker accepts everything.
+type Checker struct {
+	supervisor Supervisor
+	minSafety  SafetyLevel
+	timeout    time.Duration
+}
+
+func NewChecker(supervisor Supervisor, minSafety SafetyLevel, timeout time.Duration) *Checker {
+	return &Checker{supervisor: supervisor, minSafety: minSafety, timeout: timeout}
+}
+
+// Check validates the messages declared by the CrossL2Inbox access list
+// entries for execution in a block with the given timestamp. Errors other than
+// ErrInvalidMessage mean that the supervisor could not be reached in time.
+func (c *Checker) Check(ctx context.Context, entries []common.Hash, timestamp uint64) error {
+	if c == nil || len(entries) == 0 {
+		return nil
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return c.supervisor.CheckAccessList(ctx, entries, c.minSafety, ExecutingDescriptor{Timestamp: hexutil.Uint64(timestamp)})
+}
+
+// Client is a JSON-RPC client of the op-supervisor.
+type Client struct {
+	url    string
+	client *http.Client
+	id     atomic.Uint64
+}
+
+func NewClient(url string) *Client {
+	return &Client{url: url, client: &http.Client{}}
+}
+
+type rpcRequest struct {
+	Version string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+func (c *Client) CheckAccessList(ctx context.Context, entries []common.Hash, minSafety SafetyLevel, descriptor ExecutingDescriptor) error {
+	body, err := json.Marshal(rpcRequest{
+		Version: "2.0",
+		ID:      c.id.Add(1),
+		Method:  "supervisor_checkAccessList",
+		Params:  []interface{}{entries, minSafety, descriptor},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("supervisor_checkAccessList: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("supervisor_checkAccessList: %s", resp.Status)
+	}
+	var res rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("supervisor_checkAccessList: %w", err)
+	}
+	if res.Error != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMessage, res.Error.Message)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package opstack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/holiman/uint256"
+	"golang.org/x/crypto/sha3"
+)
+
+// L1InfoDepositorAddress is the sender of the L1 attributes deposit which opens
+// every L2 block.
+var L1InfoDepositorAddress = libcommon.HexToAddress("0xDeaDDEaDDeAdDeAdDEAdDEaddeAddEAdDEAd0001")
+
+// Domains of the deposit source hashes.
+const (
+	UserDepositSourceDomain   = 0
+	L1InfoDepositSourceDomain = 1
+)
+
+// L1BlockInfo is the L1 origin of an L2 block, as set by the L1 attributes
+// deposit which opens the block.
+type L1BlockInfo struct {
+	Number         uint64
+	Time           uint64
+	BaseFee        *uint256.Int
+	BlobBaseFee    *uint256.Int // nil before Ecotone
+	Hash           libcommon.Hash
+	SequenceNumber uint64 // number of L2 blocks since the start of the epoch
+	BatcherHash    libcommon.Hash
+}
+
+// ParseL1BlockInfo decodes the calldata of the L1 attributes deposit, in the
+// Bedrock or the Ecotone format.
+func ParseL1BlockInfo(data []byte) (*L1BlockInfo, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("expected at least 4 L1 info bytes, got %d", len(data))
+	}
+	switch {
+	case bytes.Equal(data[:4], BedrockL1AttributesSelector):
+		if len(data) < LegacyL1InfoBytes {
+			return nil, fmt.Errorf("expected at least %d L1 info bytes, got %d", LegacyL1InfoBytes, len(data))
+		}
+		// setL1BlockValues(uint64 _number, uint64 _timestamp, uint256 _basefee, bytes32 _hash,
+		// uint64 _sequenceNumber, bytes32 _batcherHash, uint256 _l1FeeOverhead, uint256 _l1FeeScalar)
+		args := data[4:]
+		return &L1BlockInfo{
+			Number:         binary.BigEndian.Uint64(args[32*0+24 : 32*1]),
+			Time:           binary.BigEndian.Uint64(args[32*1+24 : 32*2]),
+			BaseFee:        new(uint256.Int).SetBytes(args[32*2 : 32*3]),
+			Hash:           libcommon.BytesToHash(args[32*3 : 32*4]),
+			SequenceNumber: binary.BigEndian.Uint64(args[32*4+24 : 32*5]),
+			BatcherHash:    libcommon.BytesToHash(args[32*5 : 32*6]),
+		}, nil
+	case bytes.Equal(data[:4], EcotoneL1AttributesSelector):
+		if len(data) != EcotoneL1InfoBytes {
+			return nil, fmt.Errorf("expected %d L1 info bytes, got %d", EcotoneL1InfoBytes, len(data))
+		}
+		// packed layout, see extractL1GasParamsPostEcotone
+		return &L1BlockInfo{
+			SequenceNumber: binary.BigEndian.Uint64(data[12:20]),
+			Time:           binary.BigEndian.Uint64(data[20:28]),
+			Number:         binary.BigEndian.Uint64(data[28:36]),
+			BaseFee:        new(uint256.Int).SetBytes(data[36:68]),
+			BlobBaseFee:    new(uint256.Int).SetBytes(data[68:100]),
+			Hash:           libcommon.BytesToHash(data[100:132]),
+			BatcherHash:    libcommon.BytesToHash(data[132:164]),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown L1 info selector %x", data[:4])
+	}
+}
+
+// MarshalEcotone encodes the L1 origin as the calldata of the Ecotone L1
+// attributes deposit, with the given L1 fee scalars.
+func (info *L1BlockInfo) MarshalEcotone(baseFeeScalar, blobBaseFeeScalar uint32) []byte {
+	data := make([]byte, EcotoneL1InfoBytes)
+	copy(data[:4], EcotoneL1AttributesSelector)
+	binary.BigEndian.PutUint32(data[4:8], baseFeeScalar)
+	binary.BigEndian.PutUint32(data[8:12], blobBaseFeeScalar)
+	binary.BigEndian.PutUint64(data[12:20], info.SequenceNumber)
+	binary.BigEndian.PutUint64(data[20:28], info.Time)
+	binary.BigEndian.PutUint64(data[28:36], info.Number)
+	if info.BaseFee != nil {
+		info.BaseFee.WriteToSlice(data[36:68])
+	}
+	if info.BlobBaseFee != nil {
+		info.BlobBaseFee.WriteToSlice(data[68:100])
+	}
+	copy(data[100:132], info.Hash[:])
+	copy(data[132:164], info.BatcherHash[:])
+	return data
+}
+
+// L1InfoDepositSource returns the source hash of the L1 attributes deposit of
+// the given L1 origin and sequence number.
+func L1InfoDepositSource(l1BlockHash libcommon.Hash, seqNumber uint64) libcommon.Hash {
+	var seq libcommon.Hash
+	binary.BigEndian.PutUint64(seq[24:], seqNumber)
+	return depositSource(L1InfoDepositSourceDomain, keccak256(l1BlockHash[:], seq[:]))
+}
+
+// UserDepositSource returns the source hash of the user deposit emitted by the
+// log of the given index in the given L1 block.
+func UserDepositSource(l1BlockHash libcommon.Hash, logIndex uint64) libcommon.Hash {
+	var index libcommon.Hash
+	binary.BigEndian.PutUint64(index[24:], logIndex)
+	return depositSource(UserDepositSourceDomain, keccak256(l1BlockHash[:], index[:]))
+}
+
+func depositSource(domain uint64, depositID libcommon.Hash) libcommon.Hash {
+	var domainInput [64]byte
+	binary.BigEndian.PutUint64(domainInput[24:32], domain)
+	copy(domainInput[32:], depositID[:])
+	return keccak256(domainInput[:])
+}
+
+func keccak256(data ...[]byte) (h libcommon.Hash) {
+	d := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		d.Write(b)
+	}
+	d.Sum(h[:0])
+	return h
+}
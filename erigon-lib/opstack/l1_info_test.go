@@ -0,0 +1,58 @@
+package opstack
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseL1BlockInfo(t *testing.T) {
+	info, err := ParseL1BlockInfo(getBedrockL1Attributes(basefee, overhead, scalar))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), info.Number)
+	require.Equal(t, uint64(1234), info.Time)
+	require.Equal(t, basefee, info.BaseFee)
+	require.Nil(t, info.BlobBaseFee)
+	require.Equal(t, common.BigToHash(big.NewInt(1234)), info.Hash)
+	require.Equal(t, uint64(1234), info.SequenceNumber)
+
+	info, err = ParseL1BlockInfo(getEcotoneL1Attributes(basefee, blobBasefee, basefeeScalar, blobBasefeeScalar))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), info.Number)
+	require.Equal(t, uint64(1234), info.Time)
+	require.Equal(t, uint64(1234), info.SequenceNumber)
+	require.Equal(t, basefee, info.BaseFee)
+	require.Equal(t, blobBasefee, info.BlobBaseFee)
+
+	_, err = ParseL1BlockInfo([]byte{0x01, 0x02, 0x03, 0x04})
+	require.Error(t, err)
+	_, err = ParseL1BlockInfo(EcotoneL1AttributesSelector)
+	require.Error(t, err)
+}
+
+func TestMarshalEcotone(t *testing.T) {
+	info := &L1BlockInfo{
+		Number:         100,
+		Time:           1200,
+		BaseFee:        basefee,
+		BlobBaseFee:    blobBasefee,
+		Hash:           common.HexToHash("0x01"),
+		SequenceNumber: 3,
+		BatcherHash:    common.HexToHash("0x02"),
+	}
+	data := info.MarshalEcotone(uint32(basefeeScalar.Uint64()), uint32(blobBasefeeScalar.Uint64()))
+	require.Equal(t, getEcotoneL1Attributes(basefee, blobBasefee, basefeeScalar, blobBasefeeScalar)[:12], data[:12])
+	parsed, err := ParseL1BlockInfo(data)
+	require.NoError(t, err)
+	require.Equal(t, info, parsed)
+}
+
+func TestDepositSource(t *testing.T) {
+	// keccak256(bytes32(domain) ++ keccak256(l1BlockHash ++ bytes32(index)))
+	l1Hash := common.HexToHash("0xa8c3ce4d39bef5a7f2aab1ea67d2d1a4fe06f00ef9c2aed1fc7e2acfaeb67a7c")
+	depositID := keccak256(l1Hash[:], common.BigToHash(big.NewInt(0x2f)).Bytes())
+	require.Equal(t, keccak256(common.BigToHash(big.NewInt(0)).Bytes(), depositID[:]), UserDepositSource(l1Hash, 0x2f))
+	require.Equal(t, keccak256(common.BigToHash(big.NewInt(1)).Bytes(), depositID[:]), L1InfoDepositSource(l1Hash, 0x2f))
+}
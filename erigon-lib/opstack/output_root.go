@@ -0,0 +1,34 @@
+package opstack
+
+import (
+	"golang.org/x/crypto/sha3"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+)
+
+// L2ToL1MessagePasserAddr is the address of the L2ToL1MessagePasser predeploy,
+// which records the withdrawals initiated on L2.
+var L2ToL1MessagePasserAddr = libcommon.HexToAddress("0x4200000000000000000000000000000000000016")
+
+// OutputVersionV0 is the version of the output roots proposed to L1.
+var OutputVersionV0 = libcommon.Hash{}
+
+// OutputRootV0 returns the output root of an L2 block, committing to its
+// state root, the storage root of the L2ToL1MessagePasser and its hash.
+func OutputRootV0(stateRoot, messagePasserStorageRoot, blockHash libcommon.Hash) libcommon.Hash {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(OutputVersionV0[:])
+	h.Write(stateRoot[:])
+	h.Write(messagePasserStorageRoot[:])
+	h.Write(blockHash[:])
+	return libcommon.BytesToHash(h.Sum(nil))
+}
+
+// WithdrawalStorageSlot returns the slot of the L2ToL1MessagePasser
+// sentMessages mapping, at slot 0, set to true by the withdrawal.
+func WithdrawalStorageSlot(withdrawalHash libcommon.Hash) libcommon.Hash {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(withdrawalHash[:])
+	h.Write(make([]byte, 32))
+	return libcommon.BytesToHash(h.Sum(nil))
+}
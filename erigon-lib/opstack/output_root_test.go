@@ -0,0 +1,22 @@
+package opstack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+)
+
+func TestOutputRootV0(t *testing.T) {
+	// keccak256(version 0 ++ state root ++ message passer storage root ++ block hash)
+	stateRoot := libcommon.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	storageRoot := libcommon.HexToHash("0x2222222222222222222222222222222222222222222222222222222222222222")
+	blockHash := libcommon.HexToHash("0x3333333333333333333333333333333333333333333333333333333333333333")
+	require.Equal(t, libcommon.HexToHash("0xd50bf2ff34ced71be0d2f0be7c2433c6b39d9c3b16c95daf1ed6f24b7578a3b2"), OutputRootV0(stateRoot, storageRoot, blockHash))
+}
+
+func TestWithdrawalStorageSlot(t *testing.T) {
+	// keccak256(withdrawal hash ++ slot 0 of sentMessages)
+	require.Equal(t, libcommon.HexToHash("0xada5013122d395ba3c54772283fb069b10426056ef8ca54750cb9bb552a59e7d"), WithdrawalStorageSlot(libcommon.HexToHash("0x01")))
+}
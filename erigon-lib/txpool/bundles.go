@@ -0,0 +1,219 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package txpool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/erigontech/erigon-lib/common"
+)
+
+var (
+	ErrBundlesDisabled   = errors.New("bundles are disabled")
+	ErrBundleEmpty       = errors.New("bundle without transactions")
+	ErrBundlePoolFull    = errors.New("bundle pool is full")
+	ErrBundleExpired     = errors.New("bundle target block range is in the past")
+	ErrBundleBlockRange  = errors.New("bundle max block number below its block number")
+	ErrBundleTimeRange   = errors.New("bundle max timestamp below its min timestamp")
+	ErrBundleMalformedTx = errors.New("bundle transaction hashes don't match its transactions")
+)
+
+// Bundle is a list of transactions which is included atomically by the block
+// builder, in order and right after the deposits, or not at all. Bundles are
+// kept apart from the regular pool and are never gossiped.
+type Bundle struct {
+	Hash              common.Hash   // keccak256 of the concatenated transaction hashes
+	Txs               [][]byte      // binary encoding of the transactions
+	TxHashes          []common.Hash // hashes of Txs
+	MinBlock          uint64        // first block the bundle can be included in
+	MaxBlock          uint64        // last block the bundle can be included in
+	MinTimestamp      uint64        // the bundle is included in blocks of this timestamp or later, 0 for any
+	MaxTimestamp      uint64        // the bundle is included in blocks of this timestamp or earlier, 0 for any
+	RevertingTxHashes []common.Hash // transactions of the bundle which are allowed to revert
+	ReplacementUuid   string        // the bundle replaces the previous one of the same UUID, which can be cancelled
+}
+
+// CanRevert reports whether the transaction of the given hash is allowed to revert.
+func (b *Bundle) CanRevert(txHash common.Hash) bool {
+	for _, h := range b.RevertingTxHashes {
+		if h == txHash {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bundle) expired(blockNum uint64, timestamp uint64) bool {
+	return b.MaxBlock < blockNum || (b.MaxTimestamp != 0 && b.MaxTimestamp < timestamp)
+}
+
+func (b *Bundle) eligible(blockNum uint64, timestamp uint64) bool {
+	return b.MinBlock <= blockNum && !b.expired(blockNum, timestamp) && b.MinTimestamp <= timestamp
+}
+
+// BundleHash returns the hash of a bundle of the given transaction hashes.
+func BundleHash(txHashes []common.Hash) common.Hash {
+	h := sha3.NewLegacyKeccak256()
+	for _, txHash := range txHashes {
+		h.Write(txHash[:])
+	}
+	var hash common.Hash
+	h.Sum(hash[:0])
+	return hash
+}
+
+// BundlePool keeps the bundles, in submission order, until they are past
+// their target block range or one of their transactions is included.
+type BundlePool struct {
+	lock    sync.Mutex
+	limit   int
+	bundles []*Bundle
+	head    uint64 // latest block number the bundles were requested for
+}
+
+// NewBundlePool returns a pool of at most limit bundles.
+func NewBundlePool(limit int) *BundlePool {
+	return &BundlePool{limit: limit}
+}
+
+// Add validates the bundle, computes its hash and adds it to the pool. It
+// replaces the bundle of the same replacement UUID, if any.
+func (p *BundlePool) Add(bundle *Bundle) (common.Hash, error) {
+	if p == nil {
+		return common.Hash{}, ErrBundlesDisabled
+	}
+	if len(bundle.Txs) == 0 {
+		return common.Hash{}, ErrBundleEmpty
+	}
+	if len(bundle.Txs) != len(bundle.TxHashes) {
+		return common.Hash{}, ErrBundleMalformedTx
+	}
+	if bundle.MaxBlock == 0 {
+		bundle.MaxBlock = bundle.MinBlock
+	}
+	if bundle.MaxBlock < bundle.MinBlock {
+		return common.Hash{}, ErrBundleBlockRange
+	}
+	if bundle.MaxTimestamp != 0 && bundle.MaxTimestamp < bundle.MinTimestamp {
+		return common.Hash{}, ErrBundleTimeRange
+	}
+	bundle.Hash = BundleHash(bundle.TxHashes)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if bundle.MaxBlock <= p.head {
+		return common.Hash{}, fmt.Errorf("%w: max block %d, head %d", ErrBundleExpired, bundle.MaxBlock, p.head)
+	}
+	if bundle.ReplacementUuid != "" {
+		p.remove(bundle.ReplacementUuid)
+	}
+	if len(p.bundles) >= p.limit {
+		return common.Hash{}, ErrBundlePoolFull
+	}
+	p.bundles = append(p.bundles, bundle)
+	return bundle.Hash, nil
+}
+
+// Cancel removes the bundle of the given replacement UUID, and reports whether there was one.
+func (p *BundlePool) Cancel(replacementUuid string) bool {
+	if p == nil || replacementUuid == "" {
+		return false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.remove(replacementUuid)
+}
+
+func (p *BundlePool) remove(replacementUuid string) bool {
+	for i, b := range p.bundles {
+		if b.ReplacementUuid == replacementUuid {
+			p.bundles = append(p.bundles[:i], p.bundles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Bundles returns the bundles which can be included in the block of the given
+// number and timestamp, in submission order. The bundles past their target
+// block range are dropped.
+func (p *BundlePool) Bundles(blockNum uint64, timestamp uint64) []*Bundle {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if blockNum > 0 && blockNum-1 > p.head {
+		p.head = blockNum - 1
+	}
+	var eligible []*Bundle
+	kept := p.bundles[:0]
+	for _, b := range p.bundles {
+		if b.expired(blockNum, timestamp) {
+			continue
+		}
+		kept = append(kept, b)
+		if b.eligible(blockNum, timestamp) {
+			eligible = append(eligible, b)
+		}
+	}
+	clear(p.bundles[len(kept):])
+	p.bundles = kept
+	return eligible
+}
+
+// RemoveIncluded removes the bundles of which a transaction was included in a
+// block, as the rest of them could only fail on their nonces, and returns the
+// number of bundles removed.
+func (p *BundlePool) RemoveIncluded(txHashes map[common.Hash]struct{}) int {
+	if p == nil || len(txHashes) == 0 {
+		return 0
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	kept := p.bundles[:0]
+	for _, b := range p.bundles {
+		included := false
+		for _, h := range b.TxHashes {
+			if _, ok := txHashes[h]; ok {
+				included = true
+				break
+			}
+		}
+		if !included {
+			kept = append(kept, b)
+		}
+	}
+	removed := len(p.bundles) - len(kept)
+	clear(p.bundles[len(kept):])
+	p.bundles = kept
+	return removed
+}
+
+// Len returns the number of bundles in the pool.
+func (p *BundlePool) Len() int {
+	if p == nil {
+		return 0
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.bundles)
+}
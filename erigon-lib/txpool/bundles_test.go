@@ -0,0 +1,103 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package txpool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+)
+
+func testBundle(tx byte, minBlock, maxBlock uint64, uuid string) *Bundle {
+	return &Bundle{
+		Txs:             [][]byte{{tx}},
+		TxHashes:        []common.Hash{{tx}},
+		MinBlock:        minBlock,
+		MaxBlock:        maxBlock,
+		ReplacementUuid: uuid,
+	}
+}
+
+func TestBundlePool(t *testing.T) {
+	var disabled *BundlePool
+	_, err := disabled.Add(testBundle(1, 1, 1, ""))
+	require.ErrorIs(t, err, ErrBundlesDisabled)
+	require.Empty(t, disabled.Bundles(1, 0))
+
+	p := NewBundlePool(3)
+	_, err = p.Add(&Bundle{MinBlock: 1})
+	require.ErrorIs(t, err, ErrBundleEmpty)
+	_, err = p.Add(testBundle(1, 2, 1, ""))
+	require.ErrorIs(t, err, ErrBundleBlockRange)
+	timed := testBundle(1, 1, 0, "")
+	timed.MinTimestamp, timed.MaxTimestamp = 10, 5
+	_, err = p.Add(timed)
+	require.ErrorIs(t, err, ErrBundleTimeRange)
+
+	a := testBundle(1, 1, 0, "")
+	hash, err := p.Add(a)
+	require.NoError(t, err)
+	require.Equal(t, BundleHash(a.TxHashes), hash)
+	require.Equal(t, uint64(1), a.MaxBlock)
+	b := testBundle(2, 1, 3, "b")
+	_, err = p.Add(b)
+	require.NoError(t, err)
+	c := testBundle(3, 2, 2, "")
+	_, err = p.Add(c)
+	require.NoError(t, err)
+	_, err = p.Add(testBundle(4, 1, 1, ""))
+	require.ErrorIs(t, err, ErrBundlePoolFull)
+
+	// the replacement takes the place of the bundle of the same uuid
+	replacement := testBundle(5, 1, 3, "b")
+	_, err = p.Add(replacement)
+	require.NoError(t, err)
+	require.Equal(t, []*Bundle{a, replacement}, p.Bundles(1, 0))
+	require.Equal(t, 3, p.Len())
+
+	// the bundles past their range are dropped
+	require.Equal(t, []*Bundle{c, replacement}, p.Bundles(2, 0))
+	require.Equal(t, 2, p.Len())
+	_, err = p.Add(testBundle(6, 1, 1, ""))
+	require.ErrorIs(t, err, ErrBundleExpired)
+
+	require.False(t, p.Cancel("a"))
+	require.True(t, p.Cancel("b"))
+	require.Equal(t, []*Bundle{c}, p.Bundles(2, 0))
+}
+
+func TestBundlePoolRemoveIncluded(t *testing.T) {
+	var disabled *BundlePool
+	require.Zero(t, disabled.RemoveIncluded(map[common.Hash]struct{}{{1}: {}}))
+
+	p := NewBundlePool(3)
+	a := testBundle(1, 1, 3, "")
+	b := &Bundle{Txs: [][]byte{{2}, {3}}, TxHashes: []common.Hash{{2}, {3}}, MinBlock: 1, MaxBlock: 3}
+	c := testBundle(4, 1, 3, "")
+	for _, bundle := range []*Bundle{a, b, c} {
+		_, err := p.Add(bundle)
+		require.NoError(t, err)
+	}
+
+	// a bundle is removed as soon as any of its transactions is included
+	require.Equal(t, 2, p.RemoveIncluded(map[common.Hash]struct{}{{3}: {}, {4}: {}, {5}: {}}))
+	require.Equal(t, []*Bundle{a}, p.Bundles(2, 0))
+	require.Zero(t, p.RemoveIncluded(nil))
+	require.Equal(t, 1, p.Len())
+}
@@ -59,8 +59,10 @@ import (
 	"github.com/erigontech/erigon-lib/kv/mdbx"
 	"github.com/erigontech/erigon-lib/metrics"
 	"github.com/erigontech/erigon-lib/opstack"
+	"github.com/erigontech/erigon-lib/opstack/interop"
 	"github.com/erigontech/erigon-lib/rlp"
 	"github.com/erigontech/erigon-lib/txpool/txpoolcfg"
+	"github.com/erigontech/erigon-lib/txpool/txpoolpolicy"
 	"github.com/erigontech/erigon-lib/types"
 	types2 "github.com/erigontech/erigon-lib/types"
 )
@@ -236,6 +238,9 @@ type TxPool struct {
 	isPostPrague            atomic.Bool
 	maxBlobsPerBlock        uint64
 	feeCalculator           FeeCalculator
+	policy                  *txpoolpolicy.Engine // address and contract filter policy, nil if not configured
+	interop                 *interop.Checker     // validation of interop executing messages, nil if not configured
+	bundles                 *BundlePool          // atomic bundles of eth_sendBundle, nil if not configured
 	logger                  log.Logger
 
 	l1Cost types.L1CostFn
@@ -269,6 +274,27 @@ func New(newTxs chan types.Announcements, coreDB kv.RoDB, cfg txpoolcfg.Config,
 		tracedSenders[common.BytesToAddress([]byte(sender))] = struct{}{}
 	}
 
+	var policy *txpoolpolicy.Engine
+	if cfg.PolicyFile != "" {
+		if policy, err = txpoolpolicy.New(cfg.PolicyFile, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	var interopChecker *interop.Checker
+	if cfg.InteropRPC != "" {
+		minSafety, err := interop.ParseSafetyLevel(cfg.InteropMinSafety)
+		if err != nil {
+			return nil, err
+		}
+		interopChecker = interop.NewChecker(interop.NewClient(cfg.InteropRPC), minSafety, cfg.InteropTimeout)
+	}
+
+	var bundles *BundlePool
+	if cfg.BundleSlots > 0 {
+		bundles = NewBundlePool(cfg.BundleSlots)
+	}
+
 	lock := &sync.Mutex{}
 	logger.Info("Starting TxPool", "Optimism", cfg.Optimism)
 
@@ -295,6 +321,9 @@ func New(newTxs chan types.Announcements, coreDB kv.RoDB, cfg txpoolcfg.Config,
 		minedBlobTxsByHash:      map[string]*metaTx{},
 		maxBlobsPerBlock:        maxBlobsPerBlock,
 		feeCalculator:           feeCalculator,
+		policy:                  policy,
+		interop:                 interopChecker,
+		bundles:                 bundles,
 		logger:                  logger,
 	}
 
@@ -540,7 +569,8 @@ func (p *TxPool) OnNewBlock(ctx context.Context, stateChanges *remote.StateChang
 		return err
 	}
 
-	_, unwindTxs, err = p.validateTxs(&unwindTxs, cacheView)
+	// the executing messages of the unwound transactions are checked again by the block builder
+	_, unwindTxs, err = p.validateTxs(&unwindTxs, nil, false /* fresh */, cacheView)
 
 	if err != nil {
 		return err
@@ -567,6 +597,14 @@ func (p *TxPool) OnNewBlock(ctx context.Context, stateChanges *remote.StateChang
 		return err
 	}
 
+	if p.bundles.Len() > 0 {
+		included := make(map[common.Hash]struct{}, len(minedTxs.Txs))
+		for _, txn := range minedTxs.Txs {
+			included[txn.IDHash] = struct{}{}
+		}
+		p.bundles.RemoveIncluded(included)
+	}
+
 	var announcements types.Announcements
 
 	announcements, err = p.addTxsOnNewBlock(block, cacheView, stateChanges, p.senders, unwindTxs, /* newTxs */
@@ -625,7 +663,8 @@ func (p *TxPool) processRemoteTxs(ctx context.Context) error {
 		return err
 	}
 
-	_, newTxs, err := p.validateTxs(p.unprocessedRemoteTxs, cacheView)
+	// the executing messages were checked by AddRemoteTxs
+	_, newTxs, err := p.validateTxs(p.unprocessedRemoteTxs, nil, true /* fresh */, cacheView)
 	if err != nil {
 		return err
 	}
@@ -891,7 +930,7 @@ func (p *TxPool) CountContent() (int, int, int) {
 	defer p.lock.Unlock()
 	return p.pending.Len(), p.baseFee.Len(), p.queued.Len()
 }
-func (p *TxPool) AddRemoteTxs(_ context.Context, newTxs types.TxSlots) {
+func (p *TxPool) AddRemoteTxs(ctx context.Context, newTxs types.TxSlots) {
 	if p.cfg.NoGossip {
 		// if no gossip, then
 		// disable adding remote transactions
@@ -900,9 +939,13 @@ func (p *TxPool) AddRemoteTxs(_ context.Context, newTxs types.TxSlots) {
 	}
 
 	defer addRemoteTxsTimer.ObserveDuration(time.Now())
+	interopReasons := p.checkInterop(ctx, &newTxs)
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	for i, txn := range newTxs.Txs {
+		if interopReasons != nil && interopReasons[i] != txpoolcfg.NotSet {
+			continue
+		}
 		hashS := string(txn.IDHash[:])
 		_, ok := p.unprocessedRemoteByHash[hashS]
 		if ok {
@@ -923,6 +966,60 @@ func toBlobs(_blobs [][]byte) []gokzg4844.Blob {
 	return blobs
 }
 
+// Policy returns the address and contract filter policy of the pool, nil if it is not configured.
+func (p *TxPool) Policy() *txpoolpolicy.Engine {
+	if p == nil {
+		return nil
+	}
+	return p.policy
+}
+
+// Interop returns the validation of interop executing messages, nil if it is not configured.
+func (p *TxPool) Interop() *interop.Checker {
+	if p == nil {
+		return nil
+	}
+	return p.interop
+}
+
+// Bundles returns the pool of atomic bundles, nil if bundles are not enabled.
+func (p *TxPool) Bundles() *BundlePool {
+	if p == nil {
+		return nil
+	}
+	return p.bundles
+}
+
+// checkInterop validates the executing messages of the transactions against the interop supervisor, nil if it is
+// not configured. It is called before taking the pool lock, so that the supervisor requests do not stall the pool.
+// The reasons are NotSet for the transactions without executing messages or with valid ones.
+func (p *TxPool) checkInterop(ctx context.Context, txs *types.TxSlots) []txpoolcfg.DiscardReason {
+	if p.interop == nil {
+		return nil
+	}
+	reasons := make([]txpoolcfg.DiscardReason, len(txs.Txs))
+	now := uint64(time.Now().Unix())
+	for i, txn := range txs.Txs {
+		if len(txn.InteropEntries) == 0 {
+			continue
+		}
+		err := p.interop.Check(ctx, txn.InteropEntries, now)
+		if err == nil {
+			continue
+		}
+		if txn.Traced {
+			p.logger.Info(fmt.Sprintf("TX TRACING: checkInterop rejected executing message idHash=%x err=%s", txn.IDHash, err))
+		}
+		if errors.Is(err, interop.ErrInvalidMessage) {
+			reasons[i] = txpoolcfg.InvalidInteropMsg
+			continue
+		}
+		p.logger.Warn("[txpool] interop supervisor unavailable", "err", err)
+		reasons[i] = txpoolcfg.InteropUnavailable
+	}
+	return reasons
+}
+
 func (p *TxPool) validateTx(txn *types.TxSlot, isLocal bool, stateCache kvcache.CacheView) txpoolcfg.DiscardReason {
 	// No unauthenticated deposits allowed in the transaction pool.
 	// This is for spam protection, not consensus,
@@ -933,6 +1030,18 @@ func (p *TxPool) validateTx(txn *types.TxSlot, isLocal bool, stateCache kvcache.
 	if p.cfg.Optimism && txn.Type == types.BlobTxType {
 		return txpoolcfg.TxTypeNotSupported
 	}
+	if p.policy != nil {
+		var to *common.Address
+		if !txn.Creation {
+			to = &txn.To
+		}
+		if reason := p.policy.Check(p.senders.senderID2Addr[txn.SenderID], to, txn.Selector[:min(txn.DataLen, len(txn.Selector))]); reason != txpoolcfg.Success {
+			if txn.Traced {
+				p.logger.Info(fmt.Sprintf("TX TRACING: validateTx rejected by policy idHash=%x reason=%s", txn.IDHash, reason))
+			}
+			return reason
+		}
+	}
 
 	isShanghai := p.isShanghai() || p.isAgra()
 	if isShanghai && txn.Creation && txn.DataLen > fixedgas.MaxInitCodeSize {
@@ -1186,7 +1295,9 @@ func (p *TxPool) ValidateSerializedTxn(serializedTxn []byte) error {
 	return nil
 }
 
-func (p *TxPool) validateTxs(txs *types.TxSlots, stateCache kvcache.CacheView) (reasons []txpoolcfg.DiscardReason, goodTxs types.TxSlots, err error) {
+// validateTxs validates the transactions, with the verdicts of checkInterop when they were checked (nil otherwise).
+// Only the fresh arrivals consume the rate limits of their senders, not the transactions re-injected by an unwind.
+func (p *TxPool) validateTxs(txs *types.TxSlots, interopReasons []txpoolcfg.DiscardReason, fresh bool, stateCache kvcache.CacheView) (reasons []txpoolcfg.DiscardReason, goodTxs types.TxSlots, err error) {
 	// reasons is pre-sized for direct indexing, with the default zero
 	// value DiscardReason of NotSet
 	reasons = make([]txpoolcfg.DiscardReason, len(txs.Txs))
@@ -1196,8 +1307,15 @@ func (p *TxPool) validateTxs(txs *types.TxSlots, stateCache kvcache.CacheView) (
 	}
 
 	goodCount := 0
+	now := time.Now()
 	for i, txn := range txs.Txs {
 		reason := p.validateTx(txn, txs.IsLocal[i], stateCache)
+		if reason == txpoolcfg.Success && interopReasons != nil && interopReasons[i] != txpoolcfg.NotSet {
+			reason = interopReasons[i]
+		}
+		if reason == txpoolcfg.Success && fresh && p.policy != nil {
+			reason = p.policy.Admit(p.senders.senderID2Addr[txn.SenderID], now)
+		}
 		if reason == txpoolcfg.Success {
 			goodCount++
 			// Success here means no DiscardReason yet, so leave it NotSet
@@ -1279,6 +1397,7 @@ func (p *TxPool) AddLocalTxs(ctx context.Context, newTransactions types.TxSlots,
 		return nil, err
 	}
 
+	interopReasons := p.checkInterop(ctx, &newTransactions)
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
@@ -1286,7 +1405,7 @@ func (p *TxPool) AddLocalTxs(ctx context.Context, newTransactions types.TxSlots,
 		return nil, err
 	}
 
-	reasons, newTxs, err := p.validateTxs(&newTransactions, cacheView)
+	reasons, newTxs, err := p.validateTxs(&newTransactions, interopReasons, true /* fresh */, cacheView)
 	if err != nil {
 		return nil, err
 	}
@@ -1886,6 +2005,8 @@ func MainLoop(ctx context.Context, db kv.RwDB, p *TxPool, newTxs chan types.Anno
 		return
 	}
 
+	go p.policy.Run(ctx, p.cfg.PolicyReloadEvery)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -2249,7 +2370,12 @@ func (p *TxPool) fromDB(ctx context.Context, tx kv.Tx, coreTx kv.Tx) error {
 
 		isLocalTx := p.isLocalLRU.Contains(string(k))
 
+		// the executing messages of the restored transactions are checked again by the block builder
 		if reason := p.validateTx(txn, isLocalTx, cacheView); reason != txpoolcfg.NotSet && reason != txpoolcfg.Success {
+			if reason == txpoolcfg.SenderDenied || reason == txpoolcfg.RecipientDenied || reason == txpoolcfg.SelectorDenied {
+				// denied by a policy reloaded since the transaction was pooled
+				continue
+			}
 			return nil // TODO: Clarify - if one of the txs has the wrong reason, no pooled txs!
 		}
 		txs.Resize(uint(i + 1))
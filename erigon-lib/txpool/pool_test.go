@@ -24,6 +24,9 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	gokzg4844 "github.com/crate-crypto/go-kzg-4844"
@@ -42,7 +45,10 @@ import (
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/opstack/interop"
+	"github.com/erigontech/erigon-lib/rlp"
 	"github.com/erigontech/erigon-lib/txpool/txpoolcfg"
+	"github.com/erigontech/erigon-lib/txpool/txpoolpolicy"
 	"github.com/erigontech/erigon-lib/types"
 )
 
@@ -792,6 +798,140 @@ func TestSetCodeTxValidationWithLargeAuthorizationValues(t *testing.T) {
 	assert.Equal(t, txpoolcfg.Success, result)
 }
 
+func TestInteropCheck(t *testing.T) {
+	stub := interop.NewStub()
+	known := &interop.Message{Identifier: interop.Identifier{BlockNumber: 1, ChainID: *uint256.NewInt(10)}}
+	unknown := &interop.Message{Identifier: interop.Identifier{BlockNumber: 2, ChainID: *uint256.NewInt(10)}}
+	stub.Add(known, interop.CrossUnsafe)
+	supervisor := httptest.NewServer(stub)
+	defer supervisor.Close()
+
+	ch := make(chan types.Announcements, 1)
+	_, coreDB := memdb.NewTestPoolDB(t), memdb.NewTestDB(t)
+	cfg := txpoolcfg.DefaultConfig
+	cfg.InteropRPC = supervisor.URL
+	cache := &kvcache.DummyCache{}
+	logger := log.New()
+	pool, err := New(ch, coreDB, cfg, cache, *u256.N1, nil, nil, nil, nil, fixedgas.DefaultMaxBlobsPerBlock, nil, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	tx, err := coreDB.BeginRw(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	sndr := sender{nonce: 0, balance: *uint256.NewInt(math.MaxUint64)}
+	sndrBytes := make([]byte, types.EncodeSenderLengthForStorage(sndr.nonce, sndr.balance))
+	types.EncodeSender(sndr.nonce, sndr.balance, sndrBytes)
+	require.NoError(t, tx.Put(kv.PlainState, make([]byte, 20), sndrBytes))
+	view, err := cache.View(ctx, tx)
+	require.NoError(t, err)
+
+	validate := func(entries []common.Hash) txpoolcfg.DiscardReason {
+		txn := &types.TxSlot{
+			FeeCap:         *uint256.NewInt(21000),
+			Gas:            500000,
+			Type:           types.DynamicFeeTxType,
+			InteropEntries: entries,
+		}
+		txns := types.TxSlots{Txs: []*types.TxSlot{txn}, Senders: make(types.Addresses, 20), IsLocal: []bool{false}}
+		require.NoError(t, pool.senders.registerNewSenders(&txns, logger))
+		reasons, _, err := pool.validateTxs(&txns, pool.checkInterop(ctx, &txns), true /* fresh */, view)
+		require.NoError(t, err)
+		if reasons[0] == txpoolcfg.NotSet {
+			return txpoolcfg.Success
+		}
+		return reasons[0]
+	}
+	require.Equal(t, txpoolcfg.Success, validate(nil))
+	require.Equal(t, txpoolcfg.Success, validate(known.AccessListEntries()))
+	require.Equal(t, txpoolcfg.InvalidInteropMsg, validate(unknown.AccessListEntries()))
+
+	supervisor.Close()
+	require.Equal(t, txpoolcfg.InteropUnavailable, validate(known.AccessListEntries()))
+}
+
+// transferRlp encodes an unsigned transfer of the chain 1, as stored in the pool db.
+func transferRlp(nonce uint64, to common.Address) []byte {
+	const gas, feeCap = 21000, 1_000_000
+	dataLen := rlp.U64Len(1) + rlp.U64Len(nonce) + rlp.U64Len(feeCap) + rlp.U64Len(feeCap) + rlp.U64Len(gas) +
+		rlp.StringLen(to[:]) + rlp.U64Len(0) + rlp.StringLen(nil) + rlp.ListPrefixLen(0) + 3*rlp.U64Len(0)
+	buf := make([]byte, 1+rlp.ListPrefixLen(dataLen)+dataLen)
+	buf[0] = types.DynamicFeeTxType
+	p := 1
+	p += rlp.EncodeListPrefix(dataLen, buf[p:])
+	for _, v := range []uint64{1, nonce, feeCap, feeCap, gas} {
+		p += rlp.EncodeU64(v, buf[p:])
+	}
+	p += rlp.EncodeString(to[:], buf[p:])
+	p += rlp.EncodeU64(0, buf[p:]) // value
+	p += rlp.EncodeString(nil, buf[p:])
+	p += rlp.EncodeListPrefix(0, buf[p:]) // access list
+	for i := 0; i < 3; i++ {
+		p += rlp.EncodeU64(0, buf[p:]) // v, r, s
+	}
+	return buf[:p]
+}
+
+func TestPolicyRestoreAndUnwind(t *testing.T) {
+	alice, bob := common.Address{0x01}, common.Address{0x02}
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rateLimits":[{"maxTxs":1,"window":"1m"}]}`), 0o644))
+
+	ch := make(chan types.Announcements, 10)
+	db, coreDB := memdb.NewTestPoolDB(t), memdb.NewTestDB(t)
+	cfg := txpoolcfg.DefaultConfig
+	cfg.PolicyFile = path
+	cache := &kvcache.DummyCache{}
+	logger := log.New()
+	pool, err := New(ch, coreDB, cfg, cache, *u256.N1, nil, nil, nil, nil, fixedgas.DefaultMaxBlobsPerBlock, nil, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	coreTx, err := coreDB.BeginRw(ctx)
+	require.NoError(t, err)
+	defer coreTx.Rollback()
+	sndrBytes := make([]byte, types.EncodeSenderLengthForStorage(0, *uint256.NewInt(common.Ether)))
+	types.EncodeSender(0, *uint256.NewInt(common.Ether), sndrBytes)
+	for _, addr := range []common.Address{alice, bob} {
+		require.NoError(t, coreTx.Put(kv.PlainState, addr[:], sndrBytes))
+	}
+	view, err := cache.View(ctx, coreTx)
+	require.NoError(t, err)
+
+	slots := func() types.TxSlots {
+		txs := types.TxSlots{}
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			txs.Append(&types.TxSlot{Nonce: nonce, Gas: 21000, FeeCap: *uint256.NewInt(1), IDHash: [32]byte{byte(nonce)}}, alice[:], true)
+		}
+		require.NoError(t, pool.senders.registerNewSenders(&txs, logger))
+		return txs
+	}
+	// the transactions re-injected by an unwind do not consume the rate limit of their sender
+	txs := slots()
+	reasons, _, err := pool.validateTxs(&txs, nil, false /* fresh */, view)
+	require.NoError(t, err)
+	require.Equal(t, []txpoolcfg.DiscardReason{txpoolcfg.NotSet, txpoolcfg.NotSet}, reasons)
+	txs = slots()
+	reasons, _, err = pool.validateTxs(&txs, nil, true /* fresh */, view)
+	require.NoError(t, err)
+	require.Equal(t, []txpoolcfg.DiscardReason{txpoolcfg.NotSet, txpoolcfg.SenderRateLimited}, reasons)
+
+	// a sender denied since its transaction was pooled only drops its own transaction on restore
+	tx, err := db.BeginRw(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	for i, sender := range []common.Address{alice, bob} {
+		require.NoError(t, tx.Put(kv.PoolTransaction, []byte{byte(i)}, append(sender.Bytes(), transferRlp(0, common.Address{0xff})...)))
+	}
+	require.NoError(t, pool.Policy().SetRules(txpoolpolicy.Rules{DenySenders: []common.Address{bob}}))
+	restored, err := New(ch, coreDB, cfg, cache, *u256.N1, nil, nil, nil, nil, fixedgas.DefaultMaxBlobsPerBlock, nil, logger)
+	require.NoError(t, err)
+	require.NoError(t, restored.fromDB(ctx, tx, coreTx))
+	require.Len(t, restored.byHash, 1)
+	for _, mt := range restored.byHash {
+		require.Equal(t, alice, restored.senders.senderID2Addr[mt.Tx.SenderID])
+	}
+}
+
 // Blob gas price bump + other requirements to replace existing txns in the pool
 func TestBlobTxReplacement(t *testing.T) {
 	assert, require := assert.New(t), require.New(t)
@@ -66,6 +66,15 @@ type Config struct {
 	OptimismFjordTime          *big.Int
 
 	NoGossip bool // this mode doesn't broadcast any txs, and if receive remote-txn - skip it
+
+	PolicyFile        string        // JSON file with the address and contract filter policy, hot-reloaded
+	PolicyReloadEvery time.Duration // how often the policy file is checked for changes
+
+	InteropRPC       string        // op-supervisor endpoint validating the executing messages of interop transactions
+	InteropMinSafety string        // minimal safety level of the initiating messages
+	InteropTimeout   time.Duration // timeout of the op-supervisor requests
+
+	BundleSlots int // maximum number of bundles submitted with eth_sendBundle, 0 disables bundles
 }
 
 var DefaultConfig = Config{
@@ -86,6 +95,11 @@ var DefaultConfig = Config{
 	BlobPriceBump:      100,
 
 	NoGossip: false,
+
+	PolicyReloadEvery: 10 * time.Second,
+
+	InteropMinSafety: "cross-unsafe",
+	InteropTimeout:   time.Second,
 }
 
 type DiscardReason uint8
@@ -125,6 +139,12 @@ const (
 	BlobPoolOverflow    DiscardReason = 31 // The total number of blobs (through blob txs) in the pool has reached its limit
 	NoAuthorizations    DiscardReason = 32 // EIP-7702 transactions with an empty authorization list are invalid
 	TxTypeNotSupported  DiscardReason = 33
+	SenderDenied        DiscardReason = 34 // Sender is rejected by the txpool policy
+	RecipientDenied     DiscardReason = 35 // Recipient is rejected by the txpool policy
+	SelectorDenied      DiscardReason = 36 // Called function of the recipient contract is rejected by the txpool policy
+	SenderRateLimited   DiscardReason = 37 // Sender exceeded its rate limit in the txpool policy
+	InvalidInteropMsg   DiscardReason = 38 // Executing message was rejected by the interop supervisor
+	InteropUnavailable  DiscardReason = 39 // Interop supervisor could not validate the executing messages
 )
 
 func (r DiscardReason) String() string {
@@ -191,6 +211,18 @@ func (r DiscardReason) String() string {
 		return "blobs limit in txpool is full"
 	case NoAuthorizations:
 		return "EIP-7702 transactions with an empty authorization list are invalid"
+	case SenderDenied:
+		return "sender is denied by txpool policy"
+	case RecipientDenied:
+		return "recipient is denied by txpool policy"
+	case SelectorDenied:
+		return "contract function is denied by txpool policy"
+	case SenderRateLimited:
+		return "sender is rate limited by txpool policy"
+	case InvalidInteropMsg:
+		return "executing message is invalid"
+	case InteropUnavailable:
+		return "interop supervisor is unavailable"
 	default:
 		panic(fmt.Sprintf("discard reason: %d", r))
 	}
@@ -0,0 +1,431 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package txpoolpolicy implements the operator-defined address and contract
+// filter policy which is enforced by the txpool on ingress and by the block
+// builder before inclusion.
+package txpoolpolicy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/txpool/txpoolcfg"
+)
+
+// SelectorLen is the length of a function selector at the start of calldata.
+const SelectorLen = 4
+
+// Rules is the JSON representation of a policy, as stored in the policy file
+// and exchanged over the admin RPC.
+type Rules struct {
+	// DenySenders rejects any transaction sent from one of these addresses.
+	DenySenders []common.Address `json:"denySenders,omitempty"`
+	// AllowSenders, when not empty, rejects any transaction whose sender is not listed.
+	AllowSenders []common.Address `json:"allowSenders,omitempty"`
+	// DenyRecipients rejects any transaction sent to one of these addresses.
+	DenyRecipients []common.Address `json:"denyRecipients,omitempty"`
+	// AllowRecipients, when not empty, rejects any call to an address which is not listed.
+	// Contract creations are not affected.
+	AllowRecipients []common.Address `json:"allowRecipients,omitempty"`
+	// Contracts holds per-contract function selector rules.
+	Contracts []ContractRule `json:"contracts,omitempty"`
+	// RateLimits holds per-sender rate limits. A limit without a sender applies
+	// to every sender which has no limit of its own.
+	RateLimits []RateLimit `json:"rateLimits,omitempty"`
+}
+
+// ContractRule restricts which functions of a contract may be called.
+type ContractRule struct {
+	Address common.Address `json:"address"`
+	// DenySelectors rejects calls starting with one of these selectors.
+	DenySelectors []hexutility.Bytes `json:"denySelectors,omitempty"`
+	// AllowSelectors, when not empty, rejects calls not starting with one of these selectors.
+	AllowSelectors []hexutility.Bytes `json:"allowSelectors,omitempty"`
+}
+
+// RateLimit allows at most MaxTxs transactions from a sender within Window (e.g. "1m").
+type RateLimit struct {
+	Sender *common.Address `json:"sender,omitempty"`
+	MaxTxs uint64          `json:"maxTxs"`
+	Window string          `json:"window"`
+}
+
+type selectorRule struct {
+	deny  map[[SelectorLen]byte]struct{}
+	allow map[[SelectorLen]byte]struct{}
+}
+
+type rateLimit struct {
+	maxTxs uint64
+	window time.Duration
+}
+
+type compiled struct {
+	denySenders     map[common.Address]struct{}
+	allowSenders    map[common.Address]struct{}
+	denyRecipients  map[common.Address]struct{}
+	allowRecipients map[common.Address]struct{}
+	contracts       map[common.Address]selectorRule
+	rateLimits      map[common.Address]rateLimit
+	defaultLimit    *rateLimit
+}
+
+func toSet(addrs []common.Address) map[common.Address]struct{} {
+	if len(addrs) == 0 {
+		return nil
+	}
+	set := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+func toSelectorSet(contract common.Address, selectors []hexutility.Bytes) (map[[SelectorLen]byte]struct{}, error) {
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+	set := make(map[[SelectorLen]byte]struct{}, len(selectors))
+	for _, s := range selectors {
+		if len(s) != SelectorLen {
+			return nil, fmt.Errorf("contract %x: selector %x must be %d bytes", contract, []byte(s), SelectorLen)
+		}
+		set[[SelectorLen]byte(s)] = struct{}{}
+	}
+	return set, nil
+}
+
+func (r *Rules) compile() (*compiled, error) {
+	c := &compiled{
+		denySenders:     toSet(r.DenySenders),
+		allowSenders:    toSet(r.AllowSenders),
+		denyRecipients:  toSet(r.DenyRecipients),
+		allowRecipients: toSet(r.AllowRecipients),
+		contracts:       make(map[common.Address]selectorRule, len(r.Contracts)),
+		rateLimits:      make(map[common.Address]rateLimit, len(r.RateLimits)),
+	}
+	for _, contract := range r.Contracts {
+		if _, ok := c.contracts[contract.Address]; ok {
+			return nil, fmt.Errorf("duplicate rule for contract %x", contract.Address)
+		}
+		deny, err := toSelectorSet(contract.Address, contract.DenySelectors)
+		if err != nil {
+			return nil, err
+		}
+		allow, err := toSelectorSet(contract.Address, contract.AllowSelectors)
+		if err != nil {
+			return nil, err
+		}
+		c.contracts[contract.Address] = selectorRule{deny: deny, allow: allow}
+	}
+	for _, limit := range r.RateLimits {
+		window, err := time.ParseDuration(limit.Window)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit window %q: %w", limit.Window, err)
+		}
+		if window <= 0 {
+			return nil, fmt.Errorf("rate limit window %q must be positive", limit.Window)
+		}
+		l := rateLimit{maxTxs: limit.MaxTxs, window: window}
+		if limit.Sender == nil {
+			if c.defaultLimit != nil {
+				return nil, errors.New("more than one default rate limit")
+			}
+			c.defaultLimit = &l
+			continue
+		}
+		if _, ok := c.rateLimits[*limit.Sender]; ok {
+			return nil, fmt.Errorf("duplicate rate limit for sender %x", *limit.Sender)
+		}
+		c.rateLimits[*limit.Sender] = l
+	}
+	return c, nil
+}
+
+func (c *compiled) check(sender common.Address, to *common.Address, data []byte) txpoolcfg.DiscardReason {
+	if _, ok := c.denySenders[sender]; ok {
+		return txpoolcfg.SenderDenied
+	}
+	if c.allowSenders != nil {
+		if _, ok := c.allowSenders[sender]; !ok {
+			return txpoolcfg.SenderDenied
+		}
+	}
+	if to == nil {
+		return txpoolcfg.Success
+	}
+	if _, ok := c.denyRecipients[*to]; ok {
+		return txpoolcfg.RecipientDenied
+	}
+	if c.allowRecipients != nil {
+		if _, ok := c.allowRecipients[*to]; !ok {
+			return txpoolcfg.RecipientDenied
+		}
+	}
+	rule, ok := c.contracts[*to]
+	if !ok {
+		return txpoolcfg.Success
+	}
+	// Calls without a full selector (plain transfers, fallback) can only be
+	// explicitly allowed by having no allow-list for the contract.
+	if len(data) < SelectorLen {
+		if rule.allow != nil {
+			return txpoolcfg.SelectorDenied
+		}
+		return txpoolcfg.Success
+	}
+	selector := [SelectorLen]byte(data[:SelectorLen])
+	if _, ok := rule.deny[selector]; ok {
+		return txpoolcfg.SelectorDenied
+	}
+	if rule.allow != nil {
+		if _, ok := rule.allow[selector]; !ok {
+			return txpoolcfg.SelectorDenied
+		}
+	}
+	return txpoolcfg.Success
+}
+
+// senderWindow is a fixed-window counter of transactions admitted for a sender.
+type senderWindow struct {
+	start time.Time
+	count uint64
+}
+
+// Engine holds the active policy. A nil *Engine accepts everything, so
+// callers do not need to check whether a policy is configured.
+type Engine struct {
+	path   string
+	logger log.Logger
+
+	lock     sync.RWMutex
+	rules    Rules
+	compiled *compiled
+	modTime  time.Time
+
+	rateLock sync.Mutex
+	windows  map[common.Address]*senderWindow
+}
+
+// New creates an engine and loads its rules from path. An empty path
+// creates an engine with no rules which can be populated via SetRules.
+func New(path string, logger log.Logger) (*Engine, error) {
+	e := &Engine{
+		path:     path,
+		logger:   logger,
+		compiled: &compiled{},
+		windows:  map[common.Address]*senderWindow{},
+	}
+	if path == "" {
+		return e, nil
+	}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Path returns the policy file the engine is reloaded from.
+func (e *Engine) Path() string {
+	if e == nil {
+		return ""
+	}
+	return e.path
+}
+
+// Rules returns the currently active rules.
+func (e *Engine) Rules() Rules {
+	if e == nil {
+		return Rules{}
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.rules
+}
+
+// SetRules validates and activates the given rules. If the engine is backed by
+// a file, the file is rewritten so that the rules survive a restart.
+func (e *Engine) SetRules(rules Rules) error {
+	if e == nil {
+		return errors.New("txpool policy is not enabled")
+	}
+	c, err := rules.compile()
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.path != "" {
+		data, err := json.MarshalIndent(rules, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(e.path, data, 0o644); err != nil {
+			return err
+		}
+		if st, err := os.Stat(e.path); err == nil {
+			e.modTime = st.ModTime()
+		}
+	}
+	e.activate(rules, c)
+	return nil
+}
+
+// Reload re-reads the policy file. The previous rules stay active if the file
+// can not be parsed.
+func (e *Engine) Reload() error {
+	if e == nil || e.path == "" {
+		return nil
+	}
+	st, err := os.Stat(e.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("parse txpool policy %s: %w", e.path, err)
+	}
+	c, err := rules.compile()
+	if err != nil {
+		return fmt.Errorf("txpool policy %s: %w", e.path, err)
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.modTime = st.ModTime()
+	e.activate(rules, c)
+	return nil
+}
+
+func (e *Engine) activate(rules Rules, c *compiled) {
+	e.rules = rules
+	e.compiled = c
+	e.rateLock.Lock()
+	e.windows = map[common.Address]*senderWindow{}
+	e.rateLock.Unlock()
+	e.logger.Info("[txpool] policy activated", "denySenders", len(rules.DenySenders), "allowSenders", len(rules.AllowSenders),
+		"denyRecipients", len(rules.DenyRecipients), "allowRecipients", len(rules.AllowRecipients),
+		"contracts", len(rules.Contracts), "rateLimits", len(rules.RateLimits))
+}
+
+// Run reloads the policy file whenever its modification time changes and
+// prunes stale rate limit windows, until ctx is done.
+func (e *Engine) Run(ctx context.Context, every time.Duration) {
+	if e == nil || every <= 0 {
+		return
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.pruneWindows(now)
+			if e.path == "" {
+				continue
+			}
+			st, err := os.Stat(e.path)
+			if err != nil {
+				e.logger.Warn("[txpool] policy file", "err", err)
+				continue
+			}
+			e.lock.RLock()
+			changed := !st.ModTime().Equal(e.modTime)
+			e.lock.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := e.Reload(); err != nil {
+				e.logger.Warn("[txpool] policy reload failed, keeping previous rules", "err", err)
+			}
+		}
+	}
+}
+
+// Check applies the sender, recipient and selector rules to a transaction.
+// to is nil for contract creations and data is the transaction calldata
+// (only the selector is inspected).
+func (e *Engine) Check(sender common.Address, to *common.Address, data []byte) txpoolcfg.DiscardReason {
+	if e == nil {
+		return txpoolcfg.Success
+	}
+	e.lock.RLock()
+	c := e.compiled
+	e.lock.RUnlock()
+	return c.check(sender, to, data)
+}
+
+// Admit accounts one transaction against the sender's rate limit and reports
+// SenderRateLimited if the limit for the current window is exhausted.
+func (e *Engine) Admit(sender common.Address, now time.Time) txpoolcfg.DiscardReason {
+	if e == nil {
+		return txpoolcfg.Success
+	}
+	e.lock.RLock()
+	c := e.compiled
+	e.lock.RUnlock()
+	limit, ok := c.rateLimits[sender]
+	if !ok {
+		if c.defaultLimit == nil {
+			return txpoolcfg.Success
+		}
+		limit = *c.defaultLimit
+	}
+
+	e.rateLock.Lock()
+	defer e.rateLock.Unlock()
+	w, ok := e.windows[sender]
+	if !ok || now.Sub(w.start) >= limit.window {
+		w = &senderWindow{start: now}
+		e.windows[sender] = w
+	}
+	if w.count >= limit.maxTxs {
+		return txpoolcfg.SenderRateLimited
+	}
+	w.count++
+	return txpoolcfg.Success
+}
+
+// pruneWindows drops rate limit windows which can no longer affect admission.
+func (e *Engine) pruneWindows(now time.Time) {
+	e.lock.RLock()
+	c := e.compiled
+	e.lock.RUnlock()
+	e.rateLock.Lock()
+	defer e.rateLock.Unlock()
+	for sender, w := range e.windows {
+		limit, ok := c.rateLimits[sender]
+		if !ok && c.defaultLimit != nil {
+			limit, ok = *c.defaultLimit, true
+		}
+		if !ok || now.Sub(w.start) >= limit.window {
+			delete(e.windows, sender)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+   Copyright 2024 The Erigon contributors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package txpoolpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/txpool/txpoolcfg"
+)
+
+var (
+	alice    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	bob      = common.HexToAddress("0x1000000000000000000000000000000000000002")
+	contract = common.HexToAddress("0x2000000000000000000000000000000000000001")
+	transfer = hexutility.Bytes{0xa9, 0x05, 0x9c, 0xbb}
+	approve  = hexutility.Bytes{0x09, 0x5e, 0xa7, 0xb3}
+)
+
+func TestNilEngine(t *testing.T) {
+	var e *Engine
+	require.Equal(t, txpoolcfg.Success, e.Check(alice, &bob, nil))
+	require.Equal(t, txpoolcfg.Success, e.Admit(alice, time.Now()))
+	require.Error(t, e.SetRules(Rules{}))
+}
+
+func TestCheck(t *testing.T) {
+	e, err := New("", log.New())
+	require.NoError(t, err)
+	require.NoError(t, e.SetRules(Rules{
+		DenySenders:    []common.Address{bob},
+		DenyRecipients: []common.Address{alice},
+		Contracts: []ContractRule{{
+			Address:       contract,
+			DenySelectors: []hexutility.Bytes{approve},
+		}},
+	}))
+
+	require.Equal(t, txpoolcfg.SenderDenied, e.Check(bob, &contract, nil))
+	require.Equal(t, txpoolcfg.RecipientDenied, e.Check(contract, &alice, nil))
+	require.Equal(t, txpoolcfg.SelectorDenied, e.Check(alice, &contract, append(approve, 0x01)))
+	require.Equal(t, txpoolcfg.Success, e.Check(alice, &contract, transfer))
+	require.Equal(t, txpoolcfg.Success, e.Check(alice, &contract, nil))
+	require.Equal(t, txpoolcfg.Success, e.Check(alice, nil, approve))
+
+	require.NoError(t, e.SetRules(Rules{
+		AllowSenders: []common.Address{alice},
+		Contracts: []ContractRule{{
+			Address:        contract,
+			AllowSelectors: []hexutility.Bytes{transfer},
+		}},
+	}))
+	require.Equal(t, txpoolcfg.SenderDenied, e.Check(bob, &contract, transfer))
+	require.Equal(t, txpoolcfg.Success, e.Check(alice, &contract, transfer))
+	require.Equal(t, txpoolcfg.SelectorDenied, e.Check(alice, &contract, approve))
+	require.Equal(t, txpoolcfg.SelectorDenied, e.Check(alice, &contract, nil))
+
+	require.Error(t, e.SetRules(Rules{Contracts: []ContractRule{{Address: contract, DenySelectors: []hexutility.Bytes{{0x01}}}}}))
+	// a rejected update keeps the previous rules
+	require.Equal(t, txpoolcfg.SenderDenied, e.Check(bob, &contract, transfer))
+}
+
+func TestAdmit(t *testing.T) {
+	e, err := New("", log.New())
+	require.NoError(t, err)
+	require.NoError(t, e.SetRules(Rules{
+		RateLimits: []RateLimit{
+			{MaxTxs: 1, Window: "1m"},
+			{Sender: &alice, MaxTxs: 2, Window: "1m"},
+		},
+	}))
+
+	now := time.Now()
+	require.Equal(t, txpoolcfg.Success, e.Admit(alice, now))
+	require.Equal(t, txpoolcfg.Success, e.Admit(alice, now))
+	require.Equal(t, txpoolcfg.SenderRateLimited, e.Admit(alice, now))
+	require.Equal(t, txpoolcfg.Success, e.Admit(bob, now))
+	require.Equal(t, txpoolcfg.SenderRateLimited, e.Admit(bob, now.Add(time.Second)))
+	require.Equal(t, txpoolcfg.Success, e.Admit(bob, now.Add(time.Minute)))
+
+	e.pruneWindows(now.Add(2 * time.Minute))
+	require.Empty(t, e.windows)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"denySenders":["0x1000000000000000000000000000000000000002"]}`), 0o644))
+
+	e, err := New(path, log.New())
+	require.NoError(t, err)
+	require.Equal(t, txpoolcfg.SenderDenied, e.Check(bob, nil, nil))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"denyRecipients":["0x1000000000000000000000000000000000000001"]}`), 0o644))
+	require.NoError(t, e.Reload())
+	require.Equal(t, txpoolcfg.Success, e.Check(bob, nil, nil))
+	require.Equal(t, txpoolcfg.RecipientDenied, e.Check(bob, &alice, nil))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rateLimits":[{"maxTxs":1,"window":"forever"}]}`), 0o644))
+	require.Error(t, e.Reload())
+	require.Equal(t, txpoolcfg.RecipientDenied, e.Check(bob, &alice, nil))
+
+	// SetRules persists the new rules to the policy file
+	require.NoError(t, e.SetRules(Rules{DenySenders: []common.Address{alice}}))
+	e2, err := New(path, log.New())
+	require.NoError(t, err)
+	require.Equal(t, txpoolcfg.SenderDenied, e2.Check(alice, nil, nil))
+}
@@ -93,14 +93,16 @@ type TxSlot struct {
 	Nonce          uint64      // Nonce of the transaction
 	DataLen        int         // Length of transaction's data (for calculation of intrinsic gas)
 	DataNonZeroLen int
-	AlAddrCount    int      // Number of addresses in the access list
-	AlStorCount    int      // Number of storage keys in the access list
-	Gas            uint64   // Gas limit of the transaction
-	IDHash         [32]byte // Transaction hash for the purposes of using it as a transaction Id
-	Traced         bool     // Whether transaction needs to be traced throughout transaction pool code and generate debug printing
-	Creation       bool     // Set to true if "To" field of the transaction is not set
-	Type           byte     // Transaction type
-	Size           uint32   // Size of the payload (without the RLP string envelope for typed transactions)
+	AlAddrCount    int            // Number of addresses in the access list
+	AlStorCount    int            // Number of storage keys in the access list
+	Gas            uint64         // Gas limit of the transaction
+	IDHash         [32]byte       // Transaction hash for the purposes of using it as a transaction Id
+	Traced         bool           // Whether transaction needs to be traced throughout transaction pool code and generate debug printing
+	Creation       bool           // Set to true if "To" field of the transaction is not set
+	To             common.Address // Recipient of the transaction, zero if Creation is set
+	Selector       [4]byte        // First (up to) 4 bytes of the transaction data, i.e. the called function
+	Type           byte           // Transaction type
+	Size           uint32         // Size of the payload (without the RLP string envelope for typed transactions)
 
 	// EIP-4844: Shard Blob Transactions
 	BlobFeeCap  uint256.Int // max_fee_per_blob_gas
@@ -114,8 +116,13 @@ type TxSlot struct {
 
 	// Optimism
 	RollupCostData RollupCostData
+	InteropEntries []common.Hash // Storage keys of the CrossL2Inbox access list entry, declaring the executed interop messages
 }
 
+// CrossL2InboxAddr is the address of the CrossL2Inbox predeploy of the OP Stack interop, whose access list entries
+// declare the executed interop messages (InteropEntries), validated by the opstack/interop package.
+var CrossL2InboxAddr = common.HexToAddress("0x4200000000000000000000000000000000000022")
+
 const (
 	LegacyTxType     byte = 0
 	AccessListTxType byte = 1    // EIP-2930
@@ -507,8 +514,10 @@ func (ctx *TxParseContext) parseTransactionBody(payload []byte, pos, p0 int, slo
 	if dataLen != 0 && dataLen != 20 {
 		return 0, fmt.Errorf("%w: unexpected length of to field: %d", ErrParseTxn, dataLen)
 	}
-	// Only note if To field is empty or not
 	slot.Creation = dataLen == 0
+	if !slot.Creation {
+		copy(slot.To[:], payload[dataPos:dataPos+dataLen])
+	}
 	p = dataPos + dataLen
 	// Next follows value
 	p, err = rlp.U256(payload, p, &slot.Value)
@@ -521,6 +530,7 @@ func (ctx *TxParseContext) parseTransactionBody(payload []byte, pos, p0 int, slo
 		return 0, fmt.Errorf("%w: data len: %s", ErrParseTxn, err) //nolint
 	}
 	slot.DataLen = dataLen
+	copy(slot.Selector[:], payload[dataPos:dataPos+dataLen])
 
 	// Zero and non-zero bytes are priced differently
 	slot.DataNonZeroLen = 0
@@ -564,6 +574,7 @@ func (ctx *TxParseContext) parseTransactionBody(payload []byte, pos, p0 int, slo
 				return 0, fmt.Errorf("%w: tuple addr len: %s", ErrParseTxn, err) //nolint
 			}
 			slot.AlAddrCount++
+			inbox := bytes.Equal(payload[addrPos:addrPos+20], CrossL2InboxAddr[:])
 			var storagePos, storageLen int
 			storagePos, storageLen, err = rlp.List(payload, addrPos+20)
 			if err != nil {
@@ -576,6 +587,9 @@ func (ctx *TxParseContext) parseTransactionBody(payload []byte, pos, p0 int, slo
 					return 0, fmt.Errorf("%w: tuple storage key len: %s", ErrParseTxn, err) //nolint
 				}
 				slot.AlStorCount++
+				if inbox {
+					slot.InteropEntries = append(slot.InteropEntries, common.BytesToHash(payload[sKeyPos:sKeyPos+32]))
+				}
 				sKeyPos += 32
 			}
 			if sKeyPos != storagePos+storageLen {
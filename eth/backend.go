@@ -75,6 +75,7 @@ import (
 	"github.com/erigontech/erigon-lib/txpool/txpoolcfg"
 	libtypes "github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon-lib/wrap"
+	"github.com/erigontech/erigon/accounts/signer"
 	"github.com/erigontech/erigon/cl/clparams"
 	"github.com/erigontech/erigon/cl/persistence/db_config"
 	"github.com/erigontech/erigon/cl/persistence/format/snapshot_format/getters"
@@ -122,8 +123,10 @@ import (
 	"github.com/erigontech/erigon/turbo/engineapi/engine_helpers"
 	"github.com/erigontech/erigon/turbo/execution/eth1"
 	"github.com/erigontech/erigon/turbo/execution/eth1/eth1_chain_reader.go"
+	"github.com/erigontech/erigon/turbo/flashblocks"
 	"github.com/erigontech/erigon/turbo/jsonrpc"
 	"github.com/erigontech/erigon/turbo/services"
+	"github.com/erigontech/erigon/turbo/shadow"
 	"github.com/erigontech/erigon/turbo/shards"
 	"github.com/erigontech/erigon/turbo/silkworm"
 	"github.com/erigontech/erigon/turbo/snapshotsync/freezeblocks"
@@ -166,6 +169,8 @@ type Ethereum struct {
 
 	seqRPCService        *rpc.Client
 	historicalRPCService *rpc.Client
+	accountSigner        signer.Signer
+	flashblocks          *flashblocks.Publisher
 
 	miningSealingQuit chan struct{}
 	pendingBlocks     chan *types.Block
@@ -186,6 +191,7 @@ type Ethereum struct {
 
 	notifications      *shards.Notifications
 	unsubscribeEthstat func()
+	unsubscribeShadow  func()
 
 	waitForStageLoopStop chan struct{}
 	waitForMiningStop    chan struct{}
@@ -198,6 +204,7 @@ type Ethereum struct {
 	txPoolGrpcServer        txpoolproto.TxpoolServer
 	notifyMiningAboutNewTxs chan struct{}
 	forkValidator           *engine_helpers.ForkValidator
+	inMemoryExecution       shadow.ValidateFunc
 	downloader              *downloader.Downloader
 
 	agg            *libstate.Aggregator
@@ -407,6 +414,11 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 	}
 
 	p2pConfig := stack.Config().P2P
+	if p2pConfig.SnapServe && config.HistoryV3 {
+		// historyV3 doesn't keep the hashed state and the intermediate hashes snap requests are answered from
+		logger.Warn("Not serving snap/1: unsupported with historyV3")
+		p2pConfig.SnapServe = false
+	}
 	var sentries []direct.SentryClient
 	if len(p2pConfig.SentryAddr) > 0 {
 		for _, addr := range p2pConfig.SentryAddr {
@@ -602,12 +614,14 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		return nil
 	}
 	backend.forkValidator = engine_helpers.NewForkValidator(ctx, currentBlockNumber, inMemoryExecution, tmpdir, backend.blockReader)
+	backend.inMemoryExecution = inMemoryExecution
 
 	statusDataProvider := sentry.NewStatusDataProvider(
 		chainKv,
 		chainConfig,
 		genesis,
 		backend.config.NetworkID,
+		backend.config.Prune,
 		logger,
 	)
 
@@ -669,6 +683,24 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		}
 		backend.historicalRPCService = client
 	}
+	signerCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	backend.accountSigner, err = signer.New(signerCtx, config.SignerKeystore, config.SignerPasswordFile, config.SignerExternal, logger)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if config.FlashblocksAddr != "" {
+		jwtSecret, err := flashblocks.ReadJWTSecret(config.FlashblocksJWTSecret)
+		if err != nil {
+			return nil, err
+		}
+		backend.flashblocks = flashblocks.NewPublisher(config.FlashblocksInterval, jwtSecret, logger)
+		go func() {
+			if err := backend.flashblocks.ListenAndServe(backend.sentryCtx, config.FlashblocksAddr); err != nil {
+				logger.Error("Flashblocks stream failed", "err", err)
+			}
+		}()
+	}
 	config.TxPool.NoGossip = config.DisableTxPoolGossip
 	var miningRPC txpoolproto.MiningServer
 	stateDiffClient := direct.NewStateDiffClientDirect(kvRPC)
@@ -716,7 +748,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		stagedsync.MiningStages(backend.sentryCtx,
 			stagedsync.StageMiningCreateBlockCfg(backend.chainDB, miner, *backend.chainConfig, backend.engine, backend.txPoolDB, nil, tmpdir, backend.blockReader),
 			stagedsync.StageBorHeimdallCfg(backend.chainDB, snapDb, miner, *backend.chainConfig, heimdallClient, backend.blockReader, nil, nil, nil, recents, signatures, false, nil),
-			stagedsync.StageMiningExecCfg(backend.chainDB, miner, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, nil, 0, backend.txPool, backend.txPoolDB, blockReader),
+			stagedsync.StageMiningExecCfg(backend.chainDB, miner, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, nil, 0, backend.txPool, backend.txPoolDB, backend.txPool.Policy(), backend.txPool.Interop(), backend.txPool.Bundles(), nil, blockReader),
 			stagedsync.StageHashStateCfg(backend.chainDB, dirs, config.HistoryV3),
 			stagedsync.StageTrieCfg(backend.chainDB, false, true, true, tmpdir, blockReader, nil, config.HistoryV3, backend.agg),
 			stagedsync.StageMiningFinishCfg(backend.chainDB, *backend.chainConfig, backend.engine, miner, backend.miningSealingQuit, backend.blockReader, latestBlockBuiltStore),
@@ -737,7 +769,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			stagedsync.MiningStages(backend.sentryCtx,
 				stagedsync.StageMiningCreateBlockCfg(backend.chainDB, miningStatePos, *backend.chainConfig, backend.engine, backend.txPoolDB, param, tmpdir, backend.blockReader),
 				stagedsync.StageBorHeimdallCfg(backend.chainDB, snapDb, miningStatePos, *backend.chainConfig, heimdallClient, backend.blockReader, nil, nil, nil, recents, signatures, false, nil),
-				stagedsync.StageMiningExecCfg(backend.chainDB, miningStatePos, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, interrupt, param.PayloadId, backend.txPool, backend.txPoolDB, blockReader),
+				stagedsync.StageMiningExecCfg(backend.chainDB, miningStatePos, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, interrupt, param.PayloadId, backend.txPool, backend.txPoolDB, backend.txPool.Policy(), backend.txPool.Interop(), backend.txPool.Bundles(), backend.flashblocks, blockReader),
 				stagedsync.StageHashStateCfg(backend.chainDB, dirs, config.HistoryV3),
 				stagedsync.StageTrieCfg(backend.chainDB, false, true, true, tmpdir, blockReader, nil, config.HistoryV3, backend.agg),
 				stagedsync.StageMiningFinishCfg(backend.chainDB, *backend.chainConfig, backend.engine, miningStatePos, backend.miningSealingQuit, backend.blockReader, latestBlockBuiltStore),
@@ -879,12 +911,29 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 	checkStateRoot := true
 	pipelineStages := stages2.NewPipelineStages(ctx, chainKv, config, p2pConfig, backend.sentriesClient, backend.notifications, backend.downloaderClient, blockReader, blockRetire, backend.agg, backend.silkworm, backend.forkValidator, logger, checkStateRoot)
 	backend.pipelineStagedSync = stagedsync.New(config.Sync, pipelineStages, stagedsync.PipelineUnwindOrder, stagedsync.PipelinePruneOrder, logger)
-	backend.eth1ExecutionServer = eth1.NewEthereumExecutionModule(blockReader, chainKv, backend.pipelineStagedSync, backend.forkValidator, chainConfig, assembleBlockPOS, hook, backend.notifications.Accumulator, backend.notifications.StateChangesConsumer, logger, backend.engine, config.HistoryV3, ctx)
+	rebuildInterval := config.Miner.RebuildInterval
+	if backend.flashblocks != nil {
+		// the payload is built once: its build keeps adding the transactions of the
+		// txpool and streams them as flashblocks until getPayload
+		rebuildInterval = 0
+	} else if config.DevOptimism && rebuildInterval == 0 {
+		rebuildInterval = engineapi.DevSequencerRebuildInterval
+	}
+	backend.eth1ExecutionServer = eth1.NewEthereumExecutionModule(blockReader, chainKv, backend.pipelineStagedSync, backend.forkValidator, chainConfig, assembleBlockPOS, rebuildInterval, hook, backend.notifications.Accumulator, backend.notifications.StateChangesConsumer, logger, backend.engine, config.HistoryV3, ctx)
 	executionRpc := direct.NewExecutionClientDirect(backend.eth1ExecutionServer)
+	var externalBuilders *engineapi.ExternalBuilders
+	if len(config.ExternalBuilders) > 0 {
+		if externalBuilders, err = engineapi.NewExternalBuilders(config.ExternalBuilders, config.ExternalBuilderJWTSecret, config.ExternalBuilderTimeout, config.ExternalBuilderSelection, logger); err != nil {
+			return nil, err
+		}
+	}
 	engineBackendRPC := engineapi.NewEngineServer(
 		logger,
 		chainConfig,
 		executionRpc,
+		backend.notifications.Events,
+		externalBuilders,
+		backend.eth1ExecutionServer.BadBlocks(),
 		backend.sentriesClient.Hd,
 		engine_block_downloader.NewEngineBlockDownloader(ctx,
 			logger, backend.sentriesClient.Hd, executionRpc,
@@ -940,7 +989,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 
 		go func() {
 			eth1Getter := getters.NewExecutionSnapshotReader(ctx, beaconCfg, blockReader, backend.chainDB)
-			if err := caplin1.RunCaplinPhase1(ctx, executionEngine, config, networkCfg, beaconCfg, ethClock, state, dirs, eth1Getter, backend.downloaderClient, config.CaplinConfig.Backfilling, config.CaplinConfig.BlobBackfilling, config.CaplinConfig.Archive, indiciesDB, blobStorage, creds); err != nil {
+			if err := caplin1.RunCaplinPhase1(ctx, executionEngine, config, networkCfg, beaconCfg, ethClock, state, dirs, eth1Getter, backend.downloaderClient, config.CaplinConfig.Backfilling, config.CaplinConfig.BlobBackfilling || config.CaplinConfig.BlobArchiveSlots > 0, config.CaplinConfig.Archive, indiciesDB, blobStorage, creds); err != nil {
 				logger.Error("could not start caplin", "err", err)
 			}
 			ctxCancel()
@@ -1022,12 +1071,12 @@ func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig
 	if config.Ethstats != "" {
 		var headCh chan [][]byte
 		headCh, s.unsubscribeEthstat = s.notifications.Events.AddHeaderSubscription()
-		if err := ethstats.New(stack, s.sentryServers, chainKv, s.blockReader, s.engine, config.Ethstats, s.networkID, ctx.Done(), headCh, txPoolRpcClient); err != nil {
+		if err := ethstats.New(stack, s.sentryServers, chainKv, s.blockReader, s.chainConfig, s.engine, config.Ethstats, s.networkID, ctx.Done(), headCh, txPoolRpcClient); err != nil {
 			return err
 		}
 	}
 
-	s.apiList = jsonrpc.APIList(chainKv, ethRpcClient, txPoolRpcClient, miningRpcClient, ff, stateCache, blockReader, s.agg, &httpRpcCfg, s.engine, s.seqRPCService, s.historicalRPCService, s.logger)
+	s.apiList = jsonrpc.APIList(chainKv, ethRpcClient, txPoolRpcClient, miningRpcClient, ff, stateCache, blockReader, s.agg, &httpRpcCfg, s.engine, s.seqRPCService, s.historicalRPCService, s.txPool.Policy(), s.txPool.Bundles(), s.logger)
 
 	if config.SilkwormRpcDaemon && httpRpcCfg.Enabled {
 		interface_log_settings := silkworm.RpcInterfaceLogSettings{
@@ -1061,7 +1110,7 @@ func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig
 	}
 
 	if chainConfig.Bor == nil {
-		go s.engineBackendRPC.Start(ctx, &httpRpcCfg, s.chainDB, s.blockReader, ff, stateCache, s.agg, s.engine, ethRpcClient, txPoolRpcClient, miningRpcClient, s.seqRPCService, s.historicalRPCService)
+		go s.engineBackendRPC.Start(ctx, &httpRpcCfg, s.chainDB, s.blockReader, ff, stateCache, s.agg, s.engine, ethRpcClient, txPoolRpcClient, miningRpcClient, s.seqRPCService, s.historicalRPCService, s.accountSigner)
 	}
 
 	// Register the backend on the node
@@ -1523,6 +1572,9 @@ func (s *Ethereum) Start() error {
 		nodeStages = s.pipelineStagedSync.StagesIdsList()
 		s.waitForStageLoopStop = nil // TODO: Ethereum.Stop should wait for execution_server shutdown
 		go s.eth1ExecutionServer.Start(s.sentryCtx)
+		if s.config.DevOptimism {
+			go engineapi.NewDevSequencer(s.engineBackendRPC, s.config.DevOptimismPeriod, s.config.DevOptimismMint, s.logger).Run(s.sentryCtx)
+		}
 	} else if s.config.PolygonSync {
 		s.waitForStageLoopStop = nil // Shutdown is handled by context
 		go func() {
@@ -1542,6 +1594,16 @@ func (s *Ethereum) Start() error {
 		go stages2.StageLoop(s.sentryCtx, s.chainDB, s.stagedSync, s.sentriesClient.Hd, s.waitForStageLoopStop, s.config.Sync.LoopThrottle, s.logger, s.blockReader, hook, s.config.ForcePartialCommit)
 	}
 
+	if s.config.ShadowRPC != "" {
+		reference, err := shadow.Dial(s.sentryCtx, s.config.ShadowRPC, s.logger)
+		if err != nil {
+			return fmt.Errorf("dialing the reference of the shadow execution: %w", err)
+		}
+		var headCh chan [][]byte
+		headCh, s.unsubscribeShadow = s.notifications.Events.AddHeaderSubscription()
+		go shadow.New(s.chainDB, s.blockReader, s.chainConfig, s.engine, s.inMemoryExecution, reference, s.config.ShadowDir, s.config.Dirs.Tmp, s.logger).Run(s.sentryCtx, headCh)
+	}
+
 	stages := diagnostics.InitStagesFromList(nodeStages)
 	diagnostics.Send(diagnostics.SyncStageList{StagesList: stages})
 
@@ -1571,6 +1633,9 @@ func (s *Ethereum) Stop() error {
 	if s.unsubscribeEthstat != nil {
 		s.unsubscribeEthstat()
 	}
+	if s.unsubscribeShadow != nil {
+		s.unsubscribeShadow()
+	}
 	if s.downloader != nil {
 		s.downloader.Close()
 	}
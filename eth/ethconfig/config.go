@@ -282,6 +282,29 @@ type Config struct {
 	RollupSequencerHTTP        string
 	RollupHistoricalRPC        string
 	RollupHistoricalRPCTimeout time.Duration
+	RollupDepositIndex         bool // build the deposits lookup index by source hash
+
+	FlashblocksAddr      string        // listening address of the flashblocks stream of the sequencer, disabled if empty
+	FlashblocksInterval  time.Duration // interval between the flashblocks of a payload
+	FlashblocksJWTSecret string        // path of the JWT secret of the subscribers of the flashblocks stream, public if empty
+
+	ExternalBuilders         []string      // Engine API endpoints of the external builders of the sequencer, disabled if empty
+	ExternalBuilderJWTSecret string        // path of the JWT secret of the Engine API of the external builders
+	ExternalBuilderTimeout   time.Duration // delay of the external payloads at getPayload before falling back to the local one
+	ExternalBuilderSelection string        // selection policy of the proposed payload: external, value or local
+
+	DevOptimism       bool             // sequence the blocks of the OP Stack dev chain without op-node
+	DevOptimismPeriod time.Duration    // block time of the OP Stack dev chain, the default if 0
+	DevOptimismMint   []common.Address // credited by deposits in the first block sequenced on the OP Stack dev chain
+
+	SignerKeystore     string // keystore directory of the accounts of the signer methods of the authenticated endpoint, disabled if empty
+	SignerPasswordFile string // passwords of the keystore, one per line
+	SignerExternal     string // endpoint of the external signer used instead of a keystore, disabled if empty
+
+	OtsTokenIndices bool // build the token transfers and holdings indices of the ots2 API
+
+	ShadowRPC string // reference RPC the new blocks are re-executed and compared against, disabled if empty
+	ShadowDir string // directory of the forensics of the blocks diverging from the reference
 
 	RollupHaltOnIncompatibleProtocolVersion string
 }
@@ -76,6 +76,9 @@ var DefaultTxPool2Config = func(fullCfg *Config) txpoolcfg.Config {
 	cfg.CommitEvery = 5 * time.Minute
 	cfg.TracedSenders = pool1Cfg.TracedSenders
 	cfg.CommitEvery = pool1Cfg.CommitEvery
+	cfg.PolicyFile = fullCfg.TxPool.PolicyFile
+	cfg.PolicyReloadEvery = fullCfg.TxPool.PolicyReloadEvery
+	cfg.BundleSlots = fullCfg.TxPool.BundleSlots
 
 	return cfg
 }
@@ -0,0 +1,256 @@
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/kvcfg"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/consensus/misc"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/services"
+)
+
+// OpBlocks verifies the OP Stack invariants of the post-Bedrock blocks in
+// [from, to): every block opens with a valid L1 attributes deposit, deposit
+// receipts carry DepositNonce/DepositReceiptVersion according to Regolith and
+// Canyon, the fee vault balances reconcile with the receipts and the Holocene
+// extraData is well-formed. to == 0 means up to the last block.
+func OpBlocks(ctx context.Context, db kv.RoDB, blockReader services.FullBlockReader, chainConfig *chain.Config, from, to uint64, failFast bool) error {
+	defer log.Info("[integrity] OpBlocks: done")
+	logEvery := time.NewTicker(10 * time.Second)
+	defer logEvery.Stop()
+
+	if !chainConfig.IsOptimism() {
+		return nil
+	}
+	// the Bedrock block itself is the genesis of the OP chain and carries no
+	// L1 attributes
+	var bedrock uint64
+	if chainConfig.BedrockBlock != nil {
+		bedrock = chainConfig.BedrockBlock.Uint64()
+	}
+	if from <= bedrock {
+		from = bedrock + 1
+	}
+
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	historyV3, err := kvcfg.HistoryV3.Enabled(tx)
+	if err != nil {
+		return err
+	}
+	if historyV3 {
+		// the plain state history is only kept by erigon2
+		log.Warn("[integrity] OpBlocks: fee vault balances are not checked with --history.v3")
+	}
+	if to == 0 {
+		// receipts are only available for the executed blocks
+		if to, err = stages.GetStageProgress(tx, stages.Execution); err != nil {
+			return err
+		}
+		to++
+	}
+
+	var parentTime uint64
+	if from > 0 {
+		parent, err := blockReader.HeaderByNumber(ctx, tx, from-1)
+		if err != nil {
+			return err
+		}
+		if parent != nil {
+			parentTime = parent.Time
+		}
+	}
+	for i := from; i < to; i++ {
+		hash, err := blockReader.CanonicalHash(ctx, tx, i)
+		if err != nil {
+			return err
+		}
+		block, senders, err := blockReader.BlockWithSenders(ctx, tx, hash, i)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			err := fmt.Errorf("block not found: %d", i)
+			if failFast {
+				return err
+			}
+			log.Error("[integrity] OpBlocks", "err", err)
+			continue
+		}
+		errs := []error{
+			opL1InfoDeposit(chainConfig, block, parentTime),
+			opHoloceneExtraData(chainConfig, block.Header()),
+		}
+		if receipts := rawdb.ReadReceipts(chainConfig, tx, block, senders); receipts != nil {
+			errs = append(errs, opDepositReceipts(chainConfig, block, receipts))
+			if !historyV3 {
+				errs = append(errs, opFeeVaults(tx, block, receipts))
+			}
+		}
+		for _, err := range errs {
+			if err == nil {
+				continue
+			}
+			err = fmt.Errorf("block %d: %w", i, err)
+			if failFast {
+				return err
+			}
+			log.Error("[integrity] OpBlocks", "err", err)
+		}
+		parentTime = block.Time()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-logEvery.C:
+			log.Info("[integrity] OpBlocks", "blockNum", fmt.Sprintf("%dK/%dK", i/1000, to/1000))
+		default:
+		}
+	}
+	return nil
+}
+
+// opL1InfoDeposit checks that the block opens with the L1 attributes deposit,
+// in the Bedrock format up to and including the Ecotone activation block.
+func opL1InfoDeposit(chainConfig *chain.Config, block *types.Block, parentTime uint64) error {
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		return errors.New("no L1 attributes deposit")
+	}
+	deposit, ok := txs[0].(*types.DepositTx)
+	if !ok {
+		return fmt.Errorf("first transaction is not a deposit, type %d", txs[0].Type())
+	}
+	if deposit.From != opstack.L1InfoDepositorAddress {
+		return fmt.Errorf("L1 attributes deposit from %x", deposit.From)
+	}
+	if deposit.To == nil || *deposit.To != opstack.L1BlockAddr {
+		return fmt.Errorf("L1 attributes deposit to %v", deposit.To)
+	}
+	if regolith := chainConfig.IsOptimismRegolith(block.Time()); deposit.IsSystemTransaction == regolith {
+		return fmt.Errorf("L1 attributes deposit isSystemTx=%t, regolith=%t", deposit.IsSystemTransaction, regolith)
+	}
+	selector := opstack.BedrockL1AttributesSelector
+	if chainConfig.IsEcotone(block.Time()) && chainConfig.IsEcotone(parentTime) {
+		selector = opstack.EcotoneL1AttributesSelector
+	}
+	if len(deposit.Data) < 4 || !bytes.Equal(deposit.Data[:4], selector) {
+		return fmt.Errorf("L1 attributes deposit selector %x, expected %x", deposit.Data[:min(4, len(deposit.Data))], selector)
+	}
+	return nil
+}
+
+// opDepositReceipts checks that deposit receipts carry the nonce from Regolith
+// and the receipt version from Canyon, and that other receipts carry neither.
+func opDepositReceipts(chainConfig *chain.Config, block *types.Block, receipts types.Receipts) error {
+	if len(receipts) != len(block.Transactions()) {
+		return fmt.Errorf("%d receipts for %d transactions", len(receipts), len(block.Transactions()))
+	}
+	regolith := chainConfig.IsOptimismRegolith(block.Time())
+	canyon := chainConfig.IsOptimismCanyon(block.Time())
+	for i, r := range receipts {
+		if r.Type != types.DepositTxType {
+			if r.DepositNonce != nil || r.DepositReceiptVersion != nil {
+				return fmt.Errorf("receipt %d: deposit fields on a non-deposit receipt", i)
+			}
+			continue
+		}
+		if (r.DepositNonce != nil) != regolith {
+			return fmt.Errorf("receipt %d: depositNonce=%v, regolith=%t", i, r.DepositNonce, regolith)
+		}
+		if canyon {
+			if r.DepositReceiptVersion == nil || *r.DepositReceiptVersion != types.CanyonDepositReceiptVersion {
+				return fmt.Errorf("receipt %d: depositReceiptVersion=%v, expected %d", i, r.DepositReceiptVersion, types.CanyonDepositReceiptVersion)
+			}
+		} else if r.DepositReceiptVersion != nil {
+			return fmt.Errorf("receipt %d: depositReceiptVersion=%d before canyon", i, *r.DepositReceiptVersion)
+		}
+	}
+	return nil
+}
+
+// opFeeVaults checks that the base fee and L1 fee vaults were credited with
+// exactly the fees of the block receipts. Blocks which call a vault directly
+// (e.g. a withdrawal) are skipped.
+func opFeeVaults(tx kv.Tx, block *types.Block, receipts types.Receipts) error {
+	baseFee, l1Fee := new(big.Int), new(big.Int)
+	for i, txn := range block.Transactions() {
+		if to := txn.GetTo(); to != nil && (*to == params.OptimismBaseFeeRecipient || *to == params.OptimismL1FeeRecipient) {
+			return nil
+		}
+		if txn.Type() == types.DepositTxType {
+			continue
+		}
+		if block.BaseFee() != nil {
+			baseFee.Add(baseFee, new(big.Int).Mul(block.BaseFee(), new(big.Int).SetUint64(receipts[i].GasUsed)))
+		}
+		if receipts[i].L1Fee != nil {
+			l1Fee.Add(l1Fee, receipts[i].L1Fee)
+		}
+	}
+
+	before := state.NewPlainState(tx, block.NumberU64(), nil)
+	after := state.NewPlainState(tx, block.NumberU64()+1, nil)
+	for _, vault := range []struct {
+		name     string
+		addr     libcommon.Address
+		expected *big.Int
+	}{
+		{"base fee", params.OptimismBaseFeeRecipient, baseFee},
+		{"L1 fee", params.OptimismL1FeeRecipient, l1Fee},
+	} {
+		balanceBefore, err := opBalance(before, vault.addr)
+		if err != nil {
+			return err
+		}
+		balanceAfter, err := opBalance(after, vault.addr)
+		if err != nil {
+			return err
+		}
+		if delta := new(big.Int).Sub(balanceAfter.ToBig(), balanceBefore.ToBig()); delta.Cmp(vault.expected) != 0 {
+			return fmt.Errorf("%s vault credited %d, receipts pay %d", vault.name, delta, vault.expected)
+		}
+	}
+	return nil
+}
+
+func opBalance(r state.StateReader, addr libcommon.Address) (*uint256.Int, error) {
+	acc, err := r.ReadAccountData(addr)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return new(uint256.Int), nil
+	}
+	return &acc.Balance, nil
+}
+
+// opHoloceneExtraData checks the encoding of the EIP-1559 parameters carried
+// in the extraData of Holocene headers.
+func opHoloceneExtraData(chainConfig *chain.Config, header *types.Header) error {
+	if !chainConfig.IsHolocene(header.Time) {
+		return nil
+	}
+	return misc.ValidateHoloceneExtraData(header.Extra)
+}
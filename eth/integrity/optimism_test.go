@@ -0,0 +1,153 @@
+package integrity
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/params"
+)
+
+// opTestConfig activates Regolith at 10, Canyon at 20, Ecotone at 30 and Holocene at 40.
+func opTestConfig() *chain.Config {
+	regolith, canyon, ecotone, holocene := uint64(10), uint64(20), uint64(30), uint64(40)
+	return &chain.Config{
+		Optimism:     &chain.OptimismConfig{},
+		RegolithTime: new(big.Int).SetUint64(regolith),
+		CanyonTime:   new(big.Int).SetUint64(canyon),
+		EcotoneTime:  new(big.Int).SetUint64(ecotone),
+		HoloceneTime: new(big.Int).SetUint64(holocene),
+	}
+}
+
+func opTestBlock(time uint64, extra []byte, txs ...types.Transaction) *types.Block {
+	header := &types.Header{Number: big.NewInt(1), Time: time, Extra: extra, BaseFee: big.NewInt(7)}
+	return types.NewBlock(header, txs, nil, nil, nil)
+}
+
+func opL1InfoTx(selector []byte, systemTx bool) *types.DepositTx {
+	return &types.DepositTx{
+		From:                opstack.L1InfoDepositorAddress,
+		To:                  &opstack.L1BlockAddr,
+		Value:               new(uint256.Int),
+		Data:                append(libcommon.Copy(selector), make([]byte, 32)...),
+		IsSystemTransaction: systemTx,
+	}
+}
+
+func TestOpL1InfoDeposit(t *testing.T) {
+	config := opTestConfig()
+	other := libcommon.HexToAddress("0x01")
+	fromOther := opL1InfoTx(opstack.EcotoneL1AttributesSelector, false)
+	fromOther.From = other
+	toOther := opL1InfoTx(opstack.EcotoneL1AttributesSelector, false)
+	toOther.To = &other
+
+	for _, tt := range []struct {
+		name       string
+		block      *types.Block
+		parentTime uint64
+		ok         bool
+	}{
+		{"bedrock", opTestBlock(5, nil, opL1InfoTx(opstack.BedrockL1AttributesSelector, true)), 4, true},
+		{"regolith", opTestBlock(15, nil, opL1InfoTx(opstack.BedrockL1AttributesSelector, false)), 14, true},
+		{"system tx after regolith", opTestBlock(15, nil, opL1InfoTx(opstack.BedrockL1AttributesSelector, true)), 14, false},
+		{"no system tx before regolith", opTestBlock(5, nil, opL1InfoTx(opstack.BedrockL1AttributesSelector, false)), 4, false},
+		// the Ecotone activation block still carries the Bedrock attributes
+		{"ecotone activation", opTestBlock(30, nil, opL1InfoTx(opstack.BedrockL1AttributesSelector, false)), 29, true},
+		{"ecotone activation with ecotone attributes", opTestBlock(30, nil, opL1InfoTx(opstack.EcotoneL1AttributesSelector, false)), 29, false},
+		{"ecotone", opTestBlock(32, nil, opL1InfoTx(opstack.EcotoneL1AttributesSelector, false)), 30, true},
+		{"ecotone with bedrock attributes", opTestBlock(32, nil, opL1InfoTx(opstack.BedrockL1AttributesSelector, false)), 30, false},
+		{"no transactions", opTestBlock(32, nil), 30, false},
+		{"not a deposit", opTestBlock(32, nil, types.NewTransaction(0, other, new(uint256.Int), 21000, new(uint256.Int), nil)), 30, false},
+		{"wrong sender", opTestBlock(32, nil, fromOther), 30, false},
+		{"wrong recipient", opTestBlock(32, nil, toOther), 30, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := opL1InfoDeposit(config, tt.block, tt.parentTime)
+			if tt.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestOpDepositReceipts(t *testing.T) {
+	config := opTestConfig()
+	nonce, version := uint64(3), types.CanyonDepositReceiptVersion
+	deposit := func(nonce, version *uint64) *types.Receipt {
+		return &types.Receipt{Type: types.DepositTxType, DepositNonce: nonce, DepositReceiptVersion: version}
+	}
+	block := func(time uint64) *types.Block {
+		return opTestBlock(time, nil, opL1InfoTx(opstack.BedrockL1AttributesSelector, false),
+			types.NewTransaction(0, libcommon.HexToAddress("0x01"), new(uint256.Int), 21000, new(uint256.Int), nil))
+	}
+
+	for _, tt := range []struct {
+		name     string
+		block    *types.Block
+		receipts types.Receipts
+		ok       bool
+	}{
+		{"bedrock", block(5), types.Receipts{deposit(nil, nil), {}}, true},
+		{"nonce before regolith", block(5), types.Receipts{deposit(&nonce, nil), {}}, false},
+		{"regolith", block(15), types.Receipts{deposit(&nonce, nil), {}}, true},
+		{"no nonce after regolith", block(15), types.Receipts{deposit(nil, nil), {}}, false},
+		{"version before canyon", block(15), types.Receipts{deposit(&nonce, &version), {}}, false},
+		{"canyon", block(25), types.Receipts{deposit(&nonce, &version), {}}, true},
+		{"no version after canyon", block(25), types.Receipts{deposit(&nonce, nil), {}}, false},
+		{"deposit fields on a non-deposit receipt", block(25), types.Receipts{deposit(&nonce, &version), {DepositNonce: &nonce}}, false},
+		{"missing receipt", block(25), types.Receipts{deposit(&nonce, &version)}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := opDepositReceipts(config, tt.block, tt.receipts)
+			if tt.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestOpFeeVaults(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	l1Info := opL1InfoTx(opstack.EcotoneL1AttributesSelector, false)
+	transfer := types.NewTransaction(0, libcommon.HexToAddress("0x01"), new(uint256.Int), 21000, uint256.NewInt(10), nil)
+	withdrawal := types.NewTransaction(1, params.OptimismBaseFeeRecipient, new(uint256.Int), 21000, uint256.NewInt(10), nil)
+
+	// deposits pay no fees
+	block := opTestBlock(32, nil, l1Info)
+	require.NoError(t, opFeeVaults(tx, block, types.Receipts{{Type: types.DepositTxType}}))
+
+	// the vaults were not credited with the fees of the transfer
+	block = opTestBlock(32, nil, l1Info, transfer)
+	err := opFeeVaults(tx, block, types.Receipts{{Type: types.DepositTxType}, {GasUsed: 21000, L1Fee: big.NewInt(5)}})
+	require.ErrorContains(t, err, "base fee vault credited 0, receipts pay 147000")
+
+	// the balances of the vaults do not reconcile when they are called directly
+	block = opTestBlock(32, nil, l1Info, transfer, withdrawal)
+	require.NoError(t, opFeeVaults(tx, block, types.Receipts{{Type: types.DepositTxType}, {GasUsed: 21000}, {GasUsed: 21000}}))
+}
+
+func TestOpHoloceneExtraData(t *testing.T) {
+	config := opTestConfig()
+	// version 0, denominator 250, elasticity 6
+	valid := []byte{0, 0, 0, 0, 250, 0, 0, 0, 6}
+
+	require.NoError(t, opHoloceneExtraData(config, &types.Header{Time: 35, Extra: []byte("before holocene")}))
+	require.NoError(t, opHoloceneExtraData(config, &types.Header{Time: 40, Extra: valid}))
+	require.Error(t, opHoloceneExtraData(config, &types.Header{Time: 40}))
+	require.Error(t, opHoloceneExtraData(config, &types.Header{Time: 40, Extra: append([]byte{1}, valid[1:]...)}))
+	require.Error(t, opHoloceneExtraData(config, &types.Header{Time: 40, Extra: []byte{0, 0, 0, 0, 0, 0, 0, 0, 6}}))
+}
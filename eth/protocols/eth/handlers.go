@@ -22,6 +22,7 @@ import (
 
 	"github.com/erigontech/erigon-lib/chain"
 	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/direct"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/log/v3"
 
@@ -158,7 +159,10 @@ func AnswerGetBlockBodiesQuery(db kv.Tx, query GetBlockBodiesPacket, blockReader
 	return bodies
 }
 
-func AnswerGetReceiptsQuery(chainConfig *chain.Config, br services.FullBlockReader, db kv.Tx, query GetReceiptsPacket) ([]rlp.RawValue, error) { //nolint:unparam
+// AnswerGetReceiptsQuery retrieves the receipts of the requested blocks, encoded
+// as expected by the given eth protocol version: since eth/69 the receipts are
+// sent without bloom, see Receipt69.
+func AnswerGetReceiptsQuery(chainConfig *chain.Config, br services.FullBlockReader, db kv.Tx, query GetReceiptsPacket, version uint) ([]rlp.RawValue, error) { //nolint:unparam
 	// Gather state data until the fetch or network limits is reached
 	var (
 		bytes    int
@@ -192,7 +196,11 @@ func AnswerGetReceiptsQuery(chainConfig *chain.Config, br services.FullBlockRead
 			}
 		}
 		// If known, encode and queue for response packet
-		if encoded, err := rlp.EncodeToBytes(results); err != nil {
+		var encodable interface{} = results
+		if version >= direct.ETH69 {
+			encodable = EncodeReceipts69(results)
+		}
+		if encoded, err := rlp.EncodeToBytes(encodable); err != nil {
 			return nil, fmt.Errorf("failed to encode receipt: %w", err)
 		} else {
 			receipts = append(receipts, encoded)
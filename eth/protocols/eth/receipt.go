@@ -0,0 +1,103 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"fmt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/types"
+)
+
+var (
+	receiptStatusFailedRLP     = []byte{}
+	receiptStatusSuccessfulRLP = []byte{0x01}
+)
+
+// Receipt69 is the eth/69 network encoding of a receipt. It carries the
+// consensus fields without the bloom, which the receiver recomputes from the
+// logs, and the transaction type as a plain field instead of the typed
+// envelope: [tx-type, post-state-or-status, cumulative-gas, logs].
+type Receipt69 struct {
+	Type              byte
+	PostStateOrStatus []byte
+	CumulativeGasUsed uint64
+	Logs              []*types.Log
+	// Deposit receipts keep the fields they carry in the consensus encoding,
+	// see types.Receipt.
+	DepositNonce          *uint64 `rlp:"optional"`
+	DepositReceiptVersion *uint64 `rlp:"optional"`
+}
+
+// NewReceipt69 converts a receipt into its eth/69 network encoding.
+func NewReceipt69(r *types.Receipt) *Receipt69 {
+	postStateOrStatus := r.PostState
+	if len(postStateOrStatus) == 0 {
+		postStateOrStatus = receiptStatusSuccessfulRLP
+		if r.Status == types.ReceiptStatusFailed {
+			postStateOrStatus = receiptStatusFailedRLP
+		}
+	}
+	r69 := &Receipt69{
+		Type:              r.Type,
+		PostStateOrStatus: postStateOrStatus,
+		CumulativeGasUsed: r.CumulativeGasUsed,
+		Logs:              r.Logs,
+	}
+	if r.Type == types.DepositTxType {
+		r69.DepositNonce = r.DepositNonce
+		r69.DepositReceiptVersion = r.DepositReceiptVersion
+	}
+	return r69
+}
+
+// ToReceipt restores the consensus fields of the receipt, including the bloom.
+func (r *Receipt69) ToReceipt() (*types.Receipt, error) {
+	receipt := &types.Receipt{
+		Type:              r.Type,
+		CumulativeGasUsed: r.CumulativeGasUsed,
+		Logs:              r.Logs,
+	}
+	switch {
+	case bytes.Equal(r.PostStateOrStatus, receiptStatusSuccessfulRLP):
+		receipt.Status = types.ReceiptStatusSuccessful
+	case bytes.Equal(r.PostStateOrStatus, receiptStatusFailedRLP):
+		receipt.Status = types.ReceiptStatusFailed
+	case len(r.PostStateOrStatus) == len(libcommon.Hash{}):
+		receipt.PostState = r.PostStateOrStatus
+	default:
+		return nil, fmt.Errorf("invalid receipt status %x", r.PostStateOrStatus)
+	}
+	if r.Type == types.DepositTxType {
+		receipt.DepositNonce = r.DepositNonce
+		receipt.DepositReceiptVersion = r.DepositReceiptVersion
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, nil
+}
+
+// EncodeReceipts69 converts the receipts of a block into their eth/69 network
+// encoding.
+func EncodeReceipts69(receipts types.Receipts) []*Receipt69 {
+	result := make([]*Receipt69, len(receipts))
+	for i, r := range receipts {
+		result[i] = NewReceipt69(r)
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/rlp"
+)
+
+func TestReceipt69RoundTrip(t *testing.T) {
+	nonce, version := uint64(7), types.CanyonDepositReceiptVersion
+	logs := []*types.Log{{
+		Address: libcommon.Address{0x11},
+		Topics:  []libcommon.Hash{{0x22}, {0x33}},
+		Data:    []byte{0x44, 0x55},
+	}}
+	receipts := types.Receipts{
+		{Type: types.LegacyTxType, PostState: libcommon.Hash{0x01}.Bytes(), CumulativeGasUsed: 21000, Logs: logs},
+		{Type: types.DynamicFeeTxType, Status: types.ReceiptStatusFailed, CumulativeGasUsed: 42000},
+		{Type: types.BlobTxType, Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 63000, Logs: logs},
+		{Type: types.DepositTxType, Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 84000, DepositNonce: &nonce, DepositReceiptVersion: &version},
+	}
+	for _, r := range receipts {
+		r.Bloom = types.CreateBloom(types.Receipts{r})
+	}
+
+	enc, err := rlp.EncodeToBytes(EncodeReceipts69(receipts))
+	require.NoError(t, err)
+	full, err := rlp.EncodeToBytes(receipts)
+	require.NoError(t, err)
+	require.Less(t, len(enc), len(full))
+
+	var decoded []*Receipt69
+	require.NoError(t, rlp.DecodeBytes(enc, &decoded))
+	require.Len(t, decoded, len(receipts))
+	restored := make(types.Receipts, len(decoded))
+	for i, r := range decoded {
+		restored[i], err = r.ToReceipt()
+		require.NoError(t, err)
+	}
+	// the consensus encoding, hence the receipts root, is preserved
+	require.Equal(t, types.DeriveSha(receipts), types.DeriveSha(restored))
+}
@@ -0,0 +1,446 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/dbutils"
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/services"
+	"github.com/erigontech/erigon/turbo/trie"
+)
+
+const (
+	// softResponseLimit is the target maximum size of replies to data retrievals.
+	softResponseLimit = 2 * 1024 * 1024
+
+	// maxCodeLookups is the maximum number of bytecodes to serve. This number is
+	// there to limit the number of disk lookups.
+	maxCodeLookups = 1024
+
+	// stateLookupSlack defines the ratio by how much a state response can exceed
+	// the requested limit in order to try and avoid breaking up contracts into
+	// multiple packages and proving them.
+	stateLookupSlack = 0.1
+
+	// maxTrieNodeLookups is the maximum number of state trie nodes to serve. This
+	// number is there to limit the number of disk lookups.
+	maxTrieNodeLookups = 1024
+)
+
+var errBadRequest = errors.New("bad request")
+
+var maxHash = libcommon.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+// slimAccount is the account body format of the snap protocol: the consensus
+// account with the empty storage root and the empty code hash left out.
+type slimAccount struct {
+	Nonce    uint64
+	Balance  *uint256.Int
+	Root     []byte
+	CodeHash []byte
+}
+
+func encodeSlimAccount(acc *accounts.Account, root libcommon.Hash) (rlp.RawValue, error) {
+	slim := slimAccount{Nonce: acc.Nonce, Balance: &acc.Balance}
+	if root != trie.EmptyRoot {
+		slim.Root = root[:]
+	}
+	if acc.CodeHash != trie.EmptyCodeHash {
+		slim.CodeHash = acc.CodeHash[:]
+	}
+	return rlp.EncodeToBytes(&slim)
+}
+
+func responseLimit(requested uint64) uint64 {
+	if requested > softResponseLimit {
+		return softResponseLimit
+	}
+	return requested
+}
+
+// ServedRoot returns the only state root snap requests can be answered for.
+// Erigon keeps a single copy of the hashed state, which corresponds to the
+// block reached by the IntermediateHashes stage: the requests for any other
+// root, including the roots of the recent blocks, get empty replies. Geth
+// pivots its snap sync at least 64 blocks behind the head, so it treats this
+// node as stateless for its pivot and syncs from other peers; the serving is
+// only useful to the clients requesting the state of the head, and to the
+// healing phase once the pivot moved close enough to the head.
+func ServedRoot(ctx context.Context, tx kv.Tx, blockReader services.HeaderReader) (libcommon.Hash, error) {
+	progress, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	header, err := blockReader.HeaderByNumber(ctx, tx, progress)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	if header == nil {
+		return libcommon.Hash{}, nil
+	}
+	return header.Root, nil
+}
+
+// storedStorageRoot returns the root of the storage trie of the account as
+// stored by the IntermediateHashes stage, which only keeps the roots of the
+// storage tries of some depth.
+func storedStorageRoot(tx kv.Getter, accHash []byte, incarnation uint64) (libcommon.Hash, bool, error) {
+	v, err := tx.GetOne(kv.TrieOfStorage, dbutils.GenerateStoragePrefix(accHash, incarnation))
+	if err != nil || len(v) == 0 {
+		return libcommon.Hash{}, false, err
+	}
+	_, _, _, _, root := trie.UnmarshalTrieNodeTyped(v)
+	return root, root != (libcommon.Hash{}), nil
+}
+
+// loadProofs walks the state trie collecting the nodes on the paths to the
+// given keys, and makes sure the hashed state still matches the served root.
+// The walk goes through the intermediate hashes of the untouched subtries, but
+// still costs a lot more than a range read: the requests calling it are
+// limited per peer by a ProofLimiter.
+func loadProofs(tx kv.Tx, root libcommon.Hash, keys [][]byte, hex bool, quit <-chan struct{}) (*trie.ProofRetainer, error) {
+	rl := trie.NewRetainList(0)
+	pr := trie.NewMultiProofRetainer(keys, hex, rl)
+	loader := trie.NewFlatDBTrieLoader("snap", rl, nil, nil, false)
+	loader.SetProofRetainer(pr)
+	computed, err := loader.CalcTrieRoot(tx, quit)
+	if err != nil {
+		return nil, err
+	}
+	if computed != root {
+		return nil, fmt.Errorf("state root mismatch: computed %x, expected %x", computed, root)
+	}
+	return pr, nil
+}
+
+// AnswerGetAccountRangeQuery returns a range of consecutive accounts starting
+// at the query origin, along with the proofs of the origin and of the last
+// returned account. The response is empty if the query root is not served.
+func AnswerGetAccountRangeQuery(tx kv.Tx, query *GetAccountRangePacket, root libcommon.Hash, quit <-chan struct{}) ([]*AccountData, [][]byte, error) {
+	if query.Root != root {
+		return nil, nil, nil
+	}
+	limit := responseLimit(query.Bytes)
+
+	c, err := tx.Cursor(kv.HashedAccounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+
+	var (
+		hashes []libcommon.Hash
+		accs   []accounts.Account
+		roots  = map[libcommon.Hash]libcommon.Hash{}
+		keys   = [][]byte{query.Origin[:]}
+		size   uint64
+	)
+	for k, v, err := c.Seek(query.Origin[:]); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return nil, nil, err
+		}
+		var acc accounts.Account
+		if err := acc.DecodeForStorage(v); err != nil {
+			return nil, nil, err
+		}
+		hash := libcommon.BytesToHash(k)
+		hashes = append(hashes, hash)
+		accs = append(accs, acc)
+		// Only the accounts without a stored storage root need the trie walk
+		// to go through them
+		if root, ok, err := storedStorageRoot(tx, k, acc.Incarnation); err != nil {
+			return nil, nil, err
+		} else if ok {
+			roots[hash] = root
+		} else {
+			keys = append(keys, hash[:])
+		}
+
+		// The storage root is not known until the trie is loaded, assume the
+		// worst case when accounting for the response size
+		body, err := encodeSlimAccount(&acc, libcommon.Hash{1})
+		if err != nil {
+			return nil, nil, err
+		}
+		size += uint64(length.Hash + len(body))
+		if bytes.Compare(k, query.Limit[:]) >= 0 || size > limit {
+			break
+		}
+	}
+
+	if len(hashes) > 0 {
+		keys = append(keys, hashes[len(hashes)-1][:])
+	}
+	pr, err := loadProofs(tx, root, keys, false, quit)
+	if err != nil {
+		return nil, nil, err
+	}
+	for hash, storageRoot := range pr.StorageRoots() {
+		roots[hash] = storageRoot
+	}
+
+	res := make([]*AccountData, len(hashes))
+	for i := range hashes {
+		storageRoot, ok := roots[hashes[i]]
+		if !ok {
+			return nil, nil, fmt.Errorf("missing storage root of account %x", hashes[i])
+		}
+		body, err := encodeSlimAccount(&accs[i], storageRoot)
+		if err != nil {
+			return nil, nil, err
+		}
+		res[i] = &AccountData{Hash: hashes[i], Body: body}
+	}
+	proofKeys := [][]byte{query.Origin[:]}
+	if len(hashes) > 0 {
+		proofKeys = append(proofKeys, hashes[len(hashes)-1][:])
+	}
+	return res, pr.ProofNodes(proofKeys, false), nil
+}
+
+// AnswerGetStorageRangesQuery returns the storage slots of the queried
+// accounts. Only the last returned range is proven, and only if it does not
+// start at the beginning of the storage trie or it was cut short by the
+// response limit. The response is empty if the query root is not served.
+func AnswerGetStorageRangesQuery(tx kv.Tx, query *GetStorageRangesPacket, root libcommon.Hash, quit <-chan struct{}) ([][]*StorageData, [][]byte, error) {
+	if query.Root != root {
+		return nil, nil, nil
+	}
+	limit := responseLimit(query.Bytes)
+	hardLimit := uint64(float64(limit) * (1 + stateLookupSlack))
+
+	c, err := tx.CursorDupSort(kv.HashedStorage)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+
+	var (
+		slots  [][]*StorageData
+		proofs [][]byte
+		size   uint64
+	)
+	for _, account := range query.Accounts {
+		// If we've exceeded the requested data limit, abort without opening
+		// a new storage range (that we'd need to prove due to exceeded size)
+		if size >= hardLimit {
+			break
+		}
+		// The first account might start from a different origin and end sooner
+		var origin libcommon.Hash
+		if len(query.Origin) > 0 {
+			origin, query.Origin = libcommon.BytesToHash(query.Origin), nil
+		}
+		var last = maxHash
+		if len(query.Limit) > 0 {
+			last, query.Limit = libcommon.BytesToHash(query.Limit), nil
+		}
+
+		enc, err := tx.GetOne(kv.HashedAccounts, account[:])
+		if err != nil {
+			return nil, nil, err
+		}
+		var acc accounts.Account
+		if err := acc.DecodeForStorage(enc); err != nil {
+			return nil, nil, err
+		}
+		prefix := dbutils.GenerateStoragePrefix(account[:], acc.Incarnation)
+
+		var (
+			storage  []*StorageData
+			lastSlot libcommon.Hash
+			abort    bool
+		)
+		if len(enc) > 0 {
+			for v, err := c.SeekBothRange(prefix, origin[:]); v != nil; _, v, err = c.NextDup() {
+				if err != nil {
+					return nil, nil, err
+				}
+				if size >= hardLimit {
+					abort = true
+					break
+				}
+				body, err := rlp.EncodeToBytes(v[length.Hash:])
+				if err != nil {
+					return nil, nil, err
+				}
+				lastSlot = libcommon.BytesToHash(v[:length.Hash])
+				storage = append(storage, &StorageData{Hash: lastSlot, Body: body})
+				size += uint64(length.Hash + len(body))
+				if bytes.Compare(lastSlot[:], last[:]) >= 0 {
+					break
+				}
+			}
+		}
+		if len(storage) > 0 {
+			slots = append(slots, storage)
+		}
+		// Generate the Merkle proofs for the first and last storage slot, but
+		// only if the response was capped. If the entire storage trie included
+		// in the response, no need for any proofs.
+		if origin != (libcommon.Hash{}) || (abort && len(storage) > 0) {
+			proofKeys := [][]byte{dbutils.GenerateCompositeStorageKey(account, acc.Incarnation, origin)}
+			if len(storage) > 0 {
+				proofKeys = append(proofKeys, dbutils.GenerateCompositeStorageKey(account, acc.Incarnation, lastSlot))
+			}
+			pr, err := loadProofs(tx, root, proofKeys, false, quit)
+			if err != nil {
+				return nil, nil, err
+			}
+			proofs = pr.ProofNodes(proofKeys, true)
+			break
+		}
+	}
+	return slots, proofs, nil
+}
+
+// AnswerGetByteCodesQuery returns the bytecodes of the queried code hashes,
+// stopping at the first unknown one.
+func AnswerGetByteCodesQuery(tx kv.Getter, query *GetByteCodesPacket) ([][]byte, error) {
+	limit := responseLimit(query.Bytes)
+	hashes := query.Hashes
+	if len(hashes) > maxCodeLookups {
+		hashes = hashes[:maxCodeLookups]
+	}
+
+	var (
+		codes [][]byte
+		size  uint64
+	)
+	for _, hash := range hashes {
+		if hash == trie.EmptyCodeHash {
+			// Peers should not request the empty code, but if they do, at
+			// least sent them back a correct response without db lookups
+			codes = append(codes, []byte{})
+			continue
+		}
+		code, err := tx.GetOne(kv.Code, hash[:])
+		if err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			break
+		}
+		codes = append(codes, libcommon.CopyBytes(code))
+		size += uint64(len(code))
+		if size > limit {
+			break
+		}
+	}
+	return codes, nil
+}
+
+// AnswerGetTrieNodesQuery returns the trie nodes at the queried paths,
+// stopping at the first missing one. The response is empty if the query root
+// is not served.
+func AnswerGetTrieNodesQuery(tx kv.Tx, query *GetTrieNodesPacket, root libcommon.Hash, quit <-chan struct{}) ([][]byte, error) {
+	if query.Root != root {
+		return nil, nil
+	}
+	limit := responseLimit(query.Bytes)
+
+	var hexKeys [][]byte
+	for _, pathset := range query.Paths {
+		if len(hexKeys) >= maxTrieNodeLookups {
+			break
+		}
+		switch len(pathset) {
+		case 0:
+			// Ensure we penalize invalid requests
+			return nil, errBadRequest
+		case 1:
+			// If we're only retrieving an account trie node, fetch it directly
+			hexKeys = append(hexKeys, compactToHex(pathset[0]))
+		default:
+			// Storage slots requested, resolve the account incarnation to
+			// locate its storage trie
+			if len(pathset[0]) != length.Hash {
+				return nil, errBadRequest
+			}
+			enc, err := tx.GetOne(kv.HashedAccounts, pathset[0])
+			if err != nil {
+				return nil, err
+			}
+			if len(enc) == 0 {
+				continue
+			}
+			var acc accounts.Account
+			if err := acc.DecodeForStorage(enc); err != nil {
+				return nil, err
+			}
+			prefix := keyToHex(dbutils.GenerateStoragePrefix(pathset[0], acc.Incarnation))
+			for _, path := range pathset[1:] {
+				hexKeys = append(hexKeys, append(libcommon.CopyBytes(prefix), compactToHex(path)...))
+			}
+		}
+	}
+	if len(hexKeys) == 0 {
+		return nil, nil
+	}
+
+	pr, err := loadProofs(tx, root, hexKeys, true, quit)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		nodes [][]byte
+		size  uint64
+	)
+	for _, hexKey := range hexKeys {
+		node := pr.Node(hexKey)
+		if node == nil {
+			break
+		}
+		nodes = append(nodes, node)
+		size += uint64(len(node))
+		if size > limit {
+			break
+		}
+	}
+	return nodes, nil
+}
+
+// compactToHex decodes a requested trie path, dropping the terminator flag
+// as nodes are addressed by their position in the trie.
+func compactToHex(compact []byte) []byte {
+	hex := trie.CompactToHex(compact)
+	if len(hex) > 0 && hex[len(hex)-1] == 16 {
+		hex = hex[:len(hex)-1]
+	}
+	return hex
+}
+
+func keyToHex(key []byte) []byte {
+	hex := make([]byte, 2*len(key))
+	for i, b := range key {
+		hex[i*2] = b / 16
+		hex[i*2+1] = b % 16
+	}
+	return hex
+}
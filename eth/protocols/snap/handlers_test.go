@@ -0,0 +1,298 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap_test
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/dbutils"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/eth/protocols/snap"
+	"github.com/erigontech/erigon/eth/stagedsync"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/trie"
+)
+
+var (
+	contractHash = crypto.Keccak256Hash([]byte("contract"))
+	contractCode = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+)
+
+type testState struct {
+	db          kv.RwDB
+	root        libcommon.Hash
+	accounts    []libcommon.Hash
+	stateTrie   *trie.Trie
+	storageTrie *trie.Trie
+	slots       []libcommon.Hash
+}
+
+// newTestState seeds the hashed state with plain accounts and a single
+// contract, and builds the same state in an in-memory trie for comparison.
+func newTestState(t *testing.T) *testState {
+	t.Helper()
+	s := &testState{
+		db:          memdb.NewTestDB(t),
+		stateTrie:   trie.NewTestRLPTrie(libcommon.Hash{}),
+		storageTrie: trie.NewTestRLPTrie(libcommon.Hash{}),
+	}
+	tx, err := s.db.BeginRw(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	for i := 0; i < 100; i++ {
+		slot := crypto.Keccak256Hash(binary.BigEndian.AppendUint64(nil, uint64(i)))
+		value := []byte{byte(i + 1)}
+		require.NoError(t, tx.Put(kv.HashedStorage, dbutils.GenerateCompositeStorageKey(contractHash, 1, slot), value))
+		enc, err := trie.EncodeAsValue(value)
+		require.NoError(t, err)
+		s.storageTrie.Update(slot[:], enc)
+		s.slots = append(s.slots, slot)
+	}
+	require.NoError(t, tx.Put(kv.Code, crypto.Keccak256(contractCode), contractCode))
+
+	put := func(hash libcommon.Hash, acc *accounts.Account) {
+		enc := make([]byte, acc.EncodingLengthForStorage())
+		acc.EncodeForStorage(enc)
+		require.NoError(t, tx.Put(kv.HashedAccounts, hash[:], enc))
+		forHashing := make([]byte, acc.EncodingLengthForHashing())
+		acc.EncodeForHashing(forHashing)
+		s.stateTrie.Update(hash[:], forHashing)
+		s.accounts = append(s.accounts, hash)
+	}
+	for i := 0; i < 200; i++ {
+		acc := accounts.NewAccount()
+		acc.Nonce = uint64(i)
+		acc.Balance.SetUint64(uint64(i) * 1000)
+		put(crypto.Keccak256Hash(binary.BigEndian.AppendUint64(nil, uint64(i))), &acc)
+	}
+	contract := accounts.NewAccount()
+	contract.Incarnation = 1
+	contract.CodeHash = crypto.Keccak256Hash(contractCode)
+	contract.Root = s.storageTrie.Hash()
+	put(contractHash, &contract)
+	sort.Slice(s.accounts, func(i, j int) bool { return s.accounts[i].Hex() < s.accounts[j].Hex() })
+	require.NoError(t, tx.Commit())
+
+	tx, err = s.db.BeginRw(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	cfg := stagedsync.StageTrieCfg(s.db, false, false, false, t.TempDir(), nil, nil, false, nil)
+	s.root, err = stagedsync.RegenerateIntermediateHashes("test", tx, cfg, libcommon.Hash{}, context.Background(), log.New())
+	require.NoError(t, err)
+	require.Equal(t, s.stateTrie.Hash(), s.root)
+	require.NoError(t, tx.Commit())
+	return s
+}
+
+func expectedProof(t *testing.T, tr *trie.Trie, keys ...libcommon.Hash) [][]byte {
+	t.Helper()
+	seen := map[string]struct{}{}
+	var nodes [][]byte
+	for _, key := range keys {
+		proof, err := tr.Prove(key[:], 0, false)
+		require.NoError(t, err)
+		for _, node := range proof {
+			if _, ok := seen[string(node)]; !ok {
+				seen[string(node)] = struct{}{}
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
+
+func TestAnswerGetAccountRangeQuery(t *testing.T) {
+	s := newTestState(t)
+	tx, err := s.db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	origin := s.accounts[10]
+	origin[31]-- // start in between two accounts
+	query := &snap.GetAccountRangePacket{Root: s.root, Origin: origin, Limit: s.accounts[60], Bytes: 500 * 1024}
+	accs, proof, err := snap.AnswerGetAccountRangeQuery(tx, query, s.root, nil)
+	require.NoError(t, err)
+	require.Len(t, accs, 51)
+	for i, acc := range accs {
+		require.Equal(t, s.accounts[10+i], acc.Hash)
+	}
+	require.ElementsMatch(t, expectedProof(t, s.stateTrie, origin, s.accounts[60]), proof)
+
+	// the contract account carries its storage root, as stored by the
+	// IntermediateHashes stage, and code hash
+	stored, err := tx.GetOne(kv.TrieOfStorage, dbutils.GenerateStoragePrefix(contractHash[:], 1))
+	require.NoError(t, err)
+	_, _, _, _, storedRoot := trie.UnmarshalTrieNodeTyped(stored)
+	require.Equal(t, s.storageTrie.Hash(), storedRoot)
+	query = &snap.GetAccountRangePacket{Root: s.root, Origin: contractHash, Limit: contractHash, Bytes: 500 * 1024}
+	accs, proof, err = snap.AnswerGetAccountRangeQuery(tx, query, s.root, nil)
+	require.NoError(t, err)
+	require.Len(t, accs, 1)
+	require.ElementsMatch(t, expectedProof(t, s.stateTrie, contractHash), proof)
+	var body struct {
+		Nonce    uint64
+		Balance  *uint256.Int
+		Root     []byte
+		CodeHash []byte
+	}
+	require.NoError(t, rlp.DecodeBytes(accs[0].Body, &body))
+	require.Equal(t, s.storageTrie.Hash().Bytes(), body.Root)
+	require.Equal(t, crypto.Keccak256(contractCode), body.CodeHash)
+
+	// the response is capped by the requested size
+	query = &snap.GetAccountRangePacket{Root: s.root, Limit: s.accounts[len(s.accounts)-1], Bytes: 1000}
+	accs, _, err = snap.AnswerGetAccountRangeQuery(tx, query, s.root, nil)
+	require.NoError(t, err)
+	require.Less(t, len(accs), 30)
+
+	// unknown roots are not served
+	query.Root = libcommon.Hash{1}
+	accs, proof, err = snap.AnswerGetAccountRangeQuery(tx, query, s.root, nil)
+	require.NoError(t, err)
+	require.Empty(t, accs)
+	require.Empty(t, proof)
+}
+
+func TestAnswerGetStorageRangesQuery(t *testing.T) {
+	s := newTestState(t)
+	tx, err := s.db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	sort.Slice(s.slots, func(i, j int) bool { return s.slots[i].Hex() < s.slots[j].Hex() })
+
+	// whole storage, no proof needed
+	query := &snap.GetStorageRangesPacket{Root: s.root, Accounts: []libcommon.Hash{s.accounts[0], contractHash}, Bytes: 500 * 1024}
+	slots, proof, err := snap.AnswerGetStorageRangesQuery(tx, query, s.root, nil)
+	require.NoError(t, err)
+	require.Len(t, slots, 1)
+	require.Len(t, slots[0], len(s.slots))
+	require.Empty(t, proof)
+
+	// capped range is proven
+	query = &snap.GetStorageRangesPacket{Root: s.root, Accounts: []libcommon.Hash{contractHash}, Origin: s.slots[5][:], Bytes: 1000}
+	slots, proof, err = snap.AnswerGetStorageRangesQuery(tx, query, s.root, nil)
+	require.NoError(t, err)
+	require.Len(t, slots, 1)
+	require.Equal(t, s.slots[5], slots[0][0].Hash)
+	last := slots[0][len(slots[0])-1].Hash
+	require.ElementsMatch(t, expectedProof(t, s.storageTrie, s.slots[5], last), proof)
+}
+
+func TestAnswerGetByteCodesQuery(t *testing.T) {
+	s := newTestState(t)
+	tx, err := s.db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	codes, err := snap.AnswerGetByteCodesQuery(tx, &snap.GetByteCodesPacket{
+		Hashes: []libcommon.Hash{crypto.Keccak256Hash(contractCode), trie.EmptyCodeHash, {1}},
+		Bytes:  1024,
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{contractCode, {}}, codes)
+}
+
+func TestAnswerGetTrieNodesQuery(t *testing.T) {
+	s := newTestState(t)
+	tx, err := s.db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	accountProof, err := s.stateTrie.Prove(s.accounts[0][:], 0, false)
+	require.NoError(t, err)
+	storageProof, err := s.storageTrie.Prove(s.slots[0][:], 0, false)
+	require.NoError(t, err)
+
+	missing := libcommon.Hash{0xaa}
+	nodes, err := snap.AnswerGetTrieNodesQuery(tx, &snap.GetTrieNodesPacket{
+		Root: s.root,
+		Paths: []snap.TrieNodePathSet{
+			{{0x00}},                       // account trie root
+			{contractHash[:], {0x00}},      // storage trie root
+			{missing[:], {0x00}},           // storage of a missing account
+			{{0x10 | s.accounts[0][0]>>4}}, // first level branch
+		},
+		Bytes: 500 * 1024,
+	}, s.root, nil)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{accountProof[0], storageProof[0], accountProof[1]}, nodes)
+}
+
+// The hashed state is kept for the head only: once it moved on, the requests
+// for the previous root, as a geth pivot behind the head, are answered empty.
+func TestAnswerStaleRoot(t *testing.T) {
+	s := newTestState(t)
+	stale := s.root
+
+	tx, err := s.db.BeginRw(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	acc := accounts.NewAccount()
+	acc.Balance.SetUint64(1)
+	enc := make([]byte, acc.EncodingLengthForStorage())
+	acc.EncodeForStorage(enc)
+	require.NoError(t, tx.Put(kv.HashedAccounts, s.accounts[0][:], enc))
+	cfg := stagedsync.StageTrieCfg(s.db, false, false, false, t.TempDir(), nil, nil, false, nil)
+	root, err := stagedsync.RegenerateIntermediateHashes("test", tx, cfg, libcommon.Hash{}, context.Background(), log.New())
+	require.NoError(t, err)
+	require.NotEqual(t, stale, root)
+
+	accs, proof, err := snap.AnswerGetAccountRangeQuery(tx, &snap.GetAccountRangePacket{Root: stale, Limit: s.accounts[len(s.accounts)-1], Bytes: 500 * 1024}, root, nil)
+	require.NoError(t, err)
+	require.Empty(t, accs)
+	require.Empty(t, proof)
+	slots, proof, err := snap.AnswerGetStorageRangesQuery(tx, &snap.GetStorageRangesPacket{Root: stale, Accounts: []libcommon.Hash{contractHash}, Bytes: 500 * 1024}, root, nil)
+	require.NoError(t, err)
+	require.Empty(t, slots)
+	require.Empty(t, proof)
+	nodes, err := snap.AnswerGetTrieNodesQuery(tx, &snap.GetTrieNodesPacket{Root: stale, Paths: []snap.TrieNodePathSet{{{0x00}}}, Bytes: 1024}, root, nil)
+	require.NoError(t, err)
+	require.Empty(t, nodes)
+
+	// the new root is served
+	accs, _, err = snap.AnswerGetAccountRangeQuery(tx, &snap.GetAccountRangePacket{Root: root, Limit: s.accounts[len(s.accounts)-1], Bytes: 500 * 1024}, root, nil)
+	require.NoError(t, err)
+	require.Len(t, accs, len(s.accounts))
+}
+
+func TestProofLimiter(t *testing.T) {
+	l := snap.NewProofLimiter()
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if l.Allow("a") {
+			allowed++
+		}
+	}
+	// the burst, and maybe a request refilled meanwhile
+	require.GreaterOrEqual(t, allowed, 16)
+	require.Less(t, allowed, 20)
+	require.True(t, l.Allow("b"))
+}
@@ -0,0 +1,41 @@
+package snap
+
+import (
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// proofRequestRate is the number of requests walking the state trie (the
+	// account ranges, the storage ranges, which may need a proof, and the trie
+	// nodes) a peer can make per second, with bursts of proofRequestBurst.
+	proofRequestRate  = 8
+	proofRequestBurst = 16
+
+	// maxLimitedPeers is the number of peers whose limits are remembered.
+	maxLimitedPeers = 1024
+)
+
+// ProofLimiter limits the requests walking the state trie per peer, so that
+// no peer can keep the node computing proofs. The requests over the limit get
+// an empty reply, which the peer handles as if the state was not available.
+type ProofLimiter struct {
+	limiters *lru.Cache[string, *rate.Limiter]
+}
+
+func NewProofLimiter() *ProofLimiter {
+	limiters, _ := lru.New[string, *rate.Limiter](maxLimitedPeers)
+	return &ProofLimiter{limiters: limiters}
+}
+
+// Allow reports whether the peer can make a request walking the state trie now.
+func (l *ProofLimiter) Allow(peer string) bool {
+	limiter, ok := l.limiters.Get(peer)
+	if !ok {
+		limiter = rate.NewLimiter(proofRequestRate, proofRequestBurst)
+		l.limiters.Add(peer, limiter)
+	}
+	return limiter.AllowN(time.Now(), 1)
+}
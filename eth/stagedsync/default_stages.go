@@ -24,8 +24,10 @@ func DefaultStages(ctx context.Context,
 	trieCfg TrieCfg,
 	history HistoryCfg,
 	logIndex LogIndexCfg,
+	otsTokenIndex OtsTokenIndexCfg,
 	callTraces CallTracesCfg,
 	txLookup TxLookupCfg,
+	depositLookup DepositLookupCfg,
 	finish FinishCfg,
 	test bool) []*Stage {
 	return []*Stage{
@@ -223,6 +225,20 @@ func DefaultStages(ctx context.Context,
 				return PruneLogIndex(p, tx, logIndex, ctx, logger)
 			},
 		},
+		{
+			ID:          stages.OtsTokenIndex,
+			Description: "Generate token transfers and holdings indices",
+			Disabled:    !otsTokenIndex.enabled || bodies.historyV3 || dbg.StagesOnlyBlocks,
+			Forward: func(firstCycle bool, badBlockUnwind bool, s *StageState, u Unwinder, txc wrap.TxContainer, logger log.Logger) error {
+				return SpawnOtsTokenIndex(s, txc.Tx, otsTokenIndex, ctx, logger)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, txc wrap.TxContainer, logger log.Logger) error {
+				return UnwindOtsTokenIndex(u, s, txc.Tx, otsTokenIndex, ctx, logger)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx, logger log.Logger) error {
+				return PruneOtsTokenIndex(p, tx, otsTokenIndex, ctx)
+			},
+		},
 		{
 			ID:          stages.TxLookup,
 			Description: "Generate tx lookup index",
@@ -237,6 +253,20 @@ func DefaultStages(ctx context.Context,
 				return PruneTxLookup(p, tx, txLookup, ctx, firstCycle, logger)
 			},
 		},
+		{
+			ID:          stages.DepositLookup,
+			Description: "Generate deposits lookup index",
+			Disabled:    !depositLookup.enabled || dbg.StagesOnlyBlocks,
+			Forward: func(firstCycle bool, badBlockUnwind bool, s *StageState, u Unwinder, txc wrap.TxContainer, logger log.Logger) error {
+				return SpawnDepositLookup(s, txc.Tx, depositLookup, ctx, logger)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, txc wrap.TxContainer, logger log.Logger) error {
+				return UnwindDepositLookup(u, s, txc.Tx, depositLookup, ctx, logger)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx, logger log.Logger) error {
+				return PruneDepositLookup(p, tx, depositLookup, ctx)
+			},
+		},
 		{
 			ID:          stages.Finish,
 			Description: "Final: update current block for the RPC API",
@@ -253,7 +283,7 @@ func DefaultStages(ctx context.Context,
 	}
 }
 
-func PipelineStages(ctx context.Context, snapshots SnapshotsCfg, blockHashCfg BlockHashesCfg, senders SendersCfg, exec ExecuteBlockCfg, hashState HashStateCfg, trieCfg TrieCfg, history HistoryCfg, logIndex LogIndexCfg, callTraces CallTracesCfg, txLookup TxLookupCfg, finish FinishCfg, test bool) []*Stage {
+func PipelineStages(ctx context.Context, snapshots SnapshotsCfg, blockHashCfg BlockHashesCfg, senders SendersCfg, exec ExecuteBlockCfg, hashState HashStateCfg, trieCfg TrieCfg, history HistoryCfg, logIndex LogIndexCfg, otsTokenIndex OtsTokenIndexCfg, callTraces CallTracesCfg, txLookup TxLookupCfg, depositLookup DepositLookupCfg, finish FinishCfg, test bool) []*Stage {
 	return []*Stage{
 		{
 			ID:          stages.Snapshots,
@@ -403,6 +433,20 @@ func PipelineStages(ctx context.Context, snapshots SnapshotsCfg, blockHashCfg Bl
 				return PruneLogIndex(p, tx, logIndex, ctx, logger)
 			},
 		},
+		{
+			ID:          stages.OtsTokenIndex,
+			Description: "Generate token transfers and holdings indices",
+			Disabled:    !otsTokenIndex.enabled || exec.historyV3,
+			Forward: func(firstCycle bool, badBlockUnwind bool, s *StageState, u Unwinder, txc wrap.TxContainer, logger log.Logger) error {
+				return SpawnOtsTokenIndex(s, txc.Tx, otsTokenIndex, ctx, logger)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, txc wrap.TxContainer, logger log.Logger) error {
+				return UnwindOtsTokenIndex(u, s, txc.Tx, otsTokenIndex, ctx, logger)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx, logger log.Logger) error {
+				return PruneOtsTokenIndex(p, tx, otsTokenIndex, ctx)
+			},
+		},
 		{
 			ID:          stages.TxLookup,
 			Description: "Generate tx lookup index",
@@ -416,6 +460,20 @@ func PipelineStages(ctx context.Context, snapshots SnapshotsCfg, blockHashCfg Bl
 				return PruneTxLookup(p, tx, txLookup, ctx, firstCycle, logger)
 			},
 		},
+		{
+			ID:          stages.DepositLookup,
+			Description: "Generate deposits lookup index",
+			Disabled:    !depositLookup.enabled,
+			Forward: func(firstCycle bool, badBlockUnwind bool, s *StageState, u Unwinder, txc wrap.TxContainer, logger log.Logger) error {
+				return SpawnDepositLookup(s, txc.Tx, depositLookup, ctx, logger)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, txc wrap.TxContainer, logger log.Logger) error {
+				return UnwindDepositLookup(u, s, txc.Tx, depositLookup, ctx, logger)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx, logger log.Logger) error {
+				return PruneDepositLookup(p, tx, depositLookup, ctx)
+			},
+		},
 		{
 			ID:          stages.Finish,
 			Description: "Final: update current block for the RPC API",
@@ -433,7 +491,7 @@ func PipelineStages(ctx context.Context, snapshots SnapshotsCfg, blockHashCfg Bl
 }
 
 // when uploading - potentially from zero we need to include headers and bodies stages otherwise we won't recover the POW portion of the chain
-func UploaderPipelineStages(ctx context.Context, snapshots SnapshotsCfg, headers HeadersCfg, blockHashCfg BlockHashesCfg, senders SendersCfg, bodies BodiesCfg, exec ExecuteBlockCfg, hashState HashStateCfg, trieCfg TrieCfg, history HistoryCfg, logIndex LogIndexCfg, callTraces CallTracesCfg, txLookup TxLookupCfg, finish FinishCfg, test bool) []*Stage {
+func UploaderPipelineStages(ctx context.Context, snapshots SnapshotsCfg, headers HeadersCfg, blockHashCfg BlockHashesCfg, senders SendersCfg, bodies BodiesCfg, exec ExecuteBlockCfg, hashState HashStateCfg, trieCfg TrieCfg, history HistoryCfg, logIndex LogIndexCfg, otsTokenIndex OtsTokenIndexCfg, callTraces CallTracesCfg, txLookup TxLookupCfg, depositLookup DepositLookupCfg, finish FinishCfg, test bool) []*Stage {
 	return []*Stage{
 		{
 			ID:          stages.Snapshots,
@@ -612,6 +670,20 @@ func UploaderPipelineStages(ctx context.Context, snapshots SnapshotsCfg, headers
 				return PruneLogIndex(p, tx, logIndex, ctx, logger)
 			},
 		},
+		{
+			ID:          stages.OtsTokenIndex,
+			Description: "Generate token transfers and holdings indices",
+			Disabled:    !otsTokenIndex.enabled || exec.historyV3,
+			Forward: func(firstCycle bool, badBlockUnwind bool, s *StageState, u Unwinder, txc wrap.TxContainer, logger log.Logger) error {
+				return SpawnOtsTokenIndex(s, txc.Tx, otsTokenIndex, ctx, logger)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, txc wrap.TxContainer, logger log.Logger) error {
+				return UnwindOtsTokenIndex(u, s, txc.Tx, otsTokenIndex, ctx, logger)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx, logger log.Logger) error {
+				return PruneOtsTokenIndex(p, tx, otsTokenIndex, ctx)
+			},
+		},
 		{
 			ID:          stages.TxLookup,
 			Description: "Generate tx lookup index",
@@ -625,6 +697,20 @@ func UploaderPipelineStages(ctx context.Context, snapshots SnapshotsCfg, headers
 				return PruneTxLookup(p, tx, txLookup, ctx, firstCycle, logger)
 			},
 		},
+		{
+			ID:          stages.DepositLookup,
+			Description: "Generate deposits lookup index",
+			Disabled:    !depositLookup.enabled,
+			Forward: func(firstCycle bool, badBlockUnwind bool, s *StageState, u Unwinder, txc wrap.TxContainer, logger log.Logger) error {
+				return SpawnDepositLookup(s, txc.Tx, depositLookup, ctx, logger)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, txc wrap.TxContainer, logger log.Logger) error {
+				return UnwindDepositLookup(u, s, txc.Tx, depositLookup, ctx, logger)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx, logger log.Logger) error {
+				return PruneDepositLookup(p, tx, depositLookup, ctx)
+			},
+		},
 		{
 			ID:          stages.Finish,
 			Description: "Final: update current block for the RPC API",
@@ -734,7 +820,9 @@ var DefaultForwardOrder = UnwindOrder{
 	stages.AccountHistoryIndex,
 	stages.StorageHistoryIndex,
 	stages.LogIndex,
+	stages.OtsTokenIndex,
 	stages.TxLookup,
+	stages.DepositLookup,
 	stages.Finish,
 }
 
@@ -748,7 +836,9 @@ type PruneOrder []stages.SyncStage
 var DefaultUnwindOrder = UnwindOrder{
 	stages.Finish,
 	stages.TxLookup,
+	stages.DepositLookup,
 	stages.LogIndex,
+	stages.OtsTokenIndex,
 	stages.StorageHistoryIndex,
 	stages.AccountHistoryIndex,
 	stages.CallTraces,
@@ -769,7 +859,9 @@ var DefaultUnwindOrder = UnwindOrder{
 var PipelineUnwindOrder = UnwindOrder{
 	stages.Finish,
 	stages.TxLookup,
+	stages.DepositLookup,
 	stages.LogIndex,
+	stages.OtsTokenIndex,
 	stages.StorageHistoryIndex,
 	stages.AccountHistoryIndex,
 	stages.CallTraces,
@@ -798,7 +890,9 @@ var StateUnwindOrder = UnwindOrder{
 var DefaultPruneOrder = PruneOrder{
 	stages.Finish,
 	stages.TxLookup,
+	stages.DepositLookup,
 	stages.LogIndex,
+	stages.OtsTokenIndex,
 	stages.StorageHistoryIndex,
 	stages.AccountHistoryIndex,
 	stages.CallTraces,
@@ -820,7 +914,9 @@ var DefaultPruneOrder = PruneOrder{
 var PipelinePruneOrder = PruneOrder{
 	stages.Finish,
 	stages.TxLookup,
+	stages.DepositLookup,
 	stages.LogIndex,
+	stages.OtsTokenIndex,
 	stages.StorageHistoryIndex,
 	stages.AccountHistoryIndex,
 	stages.CallTraces,
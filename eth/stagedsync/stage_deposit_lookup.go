@@ -0,0 +1,199 @@
+package stagedsync
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/etl"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/turbo/services"
+)
+
+// maxL1LogIndex bounds the search of the L1 log index of the user deposits.
+const maxL1LogIndex = 1 << 17
+
+type DepositLookupCfg struct {
+	db          kv.RwDB
+	enabled     bool
+	tmpdir      string
+	blockReader services.FullBlockReader
+}
+
+func StageDepositLookupCfg(db kv.RwDB, enabled bool, tmpdir string, blockReader services.FullBlockReader) DepositLookupCfg {
+	return DepositLookupCfg{
+		db:          db,
+		enabled:     enabled,
+		tmpdir:      tmpdir,
+		blockReader: blockReader,
+	}
+}
+
+func SpawnDepositLookup(s *StageState, tx kv.RwTx, cfg DepositLookupCfg, ctx context.Context, logger log.Logger) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	endBlock, err := s.ExecutionAt(tx)
+	if err != nil {
+		return err
+	}
+	if s.BlockNumber >= endBlock {
+		return nil
+	}
+
+	startBlock := s.BlockNumber
+	if startBlock > 0 {
+		startBlock++
+	}
+	// etl.Transform uses ExtractEndKey as exclusive bound, therefore endBlock + 1
+	if err = depositLookupTransform(s.LogPrefix(), tx, startBlock, endBlock+1, ctx, cfg, logger); err != nil {
+		return fmt.Errorf("depositLookupTransform: %w", err)
+	}
+	if err = s.Update(tx, endBlock); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DepositLookupEntries calls walker with the lookup entries of the deposits of a block. The L1 origin of the block
+// is the one of its L1 attributes deposit, and the L1 log indices of its user deposits are found by matching their
+// source hashes with the ones derived from it.
+func DepositLookupEntries(blockNum uint64, txs []types.Transaction, walker func(sourceHash libcommon.Hash, entry *rawdb.DepositLookupEntry) error) error {
+	var l1Info *opstack.L1BlockInfo
+	if len(txs) > 0 {
+		if deposit, ok := txs[0].(*types.DepositTx); ok && deposit.From == opstack.L1InfoDepositorAddress {
+			l1Info, _ = opstack.ParseL1BlockInfo(deposit.Data) // unknown L1 origin otherwise
+		}
+	}
+
+	var nextLogIndex uint64
+	for i, txn := range txs {
+		deposit, ok := txn.(*types.DepositTx)
+		if !ok {
+			continue
+		}
+		entry := &rawdb.DepositLookupEntry{BlockNumber: blockNum, TxIndex: uint64(i)}
+		if l1Info != nil {
+			entry.L1BlockNumber, entry.L1BlockHash = l1Info.Number, l1Info.Hash
+			// the user deposits open the first block of their epoch, in the order of their logs
+			if i > 0 && l1Info.SequenceNumber == 0 {
+				for logIndex := nextLogIndex; logIndex < maxL1LogIndex; logIndex++ {
+					if opstack.UserDepositSource(l1Info.Hash, logIndex) == deposit.SourceHash {
+						entry.L1LogIndex, nextLogIndex = &logIndex, logIndex+1
+						break
+					}
+				}
+			}
+		}
+		if err := walker(deposit.SourceHash, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// depositLookupTransform - [startKey, endKey)
+func depositLookupTransform(logPrefix string, tx kv.RwTx, blockFrom, blockTo uint64, ctx context.Context, cfg DepositLookupCfg, logger log.Logger) error {
+	return etl.Transform(logPrefix, tx, kv.HeaderCanonical, kv.DepositLookup, cfg.tmpdir, func(k, v []byte, next etl.ExtractNextFunc) error {
+		blocknum, blockHash := binary.BigEndian.Uint64(k), libcommon.CastToHash(v)
+		body, err := cfg.blockReader.BodyWithTransactions(ctx, tx, blockHash, blocknum)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			log.Warn(fmt.Sprintf("[%s] transform: empty block body %d, hash %x", logPrefix, blocknum, v))
+			return nil
+		}
+		return DepositLookupEntries(blocknum, body.Transactions, func(sourceHash libcommon.Hash, entry *rawdb.DepositLookupEntry) error {
+			return next(k, sourceHash.Bytes(), rawdb.EncodeDepositLookupEntry(entry))
+		})
+	}, etl.IdentityLoadFunc, etl.TransformArgs{
+		Quit:            ctx.Done(),
+		ExtractStartKey: hexutility.EncodeTs(blockFrom),
+		ExtractEndKey:   hexutility.EncodeTs(blockTo),
+		LogDetailsExtract: func(k, v []byte) (additionalLogArguments []interface{}) {
+			return []interface{}{"block", binary.BigEndian.Uint64(k)}
+		},
+	}, logger)
+}
+
+func UnwindDepositLookup(u *UnwindState, s *StageState, tx kv.RwTx, cfg DepositLookupCfg, ctx context.Context, logger log.Logger) (err error) {
+	if s.BlockNumber <= u.UnwindPoint {
+		return nil
+	}
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	// etl.Transform uses ExtractEndKey as exclusive bound, therefore s.BlockNumber + 1
+	if err := deleteDepositLookupRange(tx, s.LogPrefix(), u.UnwindPoint+1, s.BlockNumber+1, ctx, cfg, logger); err != nil {
+		return fmt.Errorf("unwind DepositLookup: %w", err)
+	}
+	if err := u.Done(tx); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteDepositLookupRange - [blockFrom, blockTo)
+func deleteDepositLookupRange(tx kv.RwTx, logPrefix string, blockFrom, blockTo uint64, ctx context.Context, cfg DepositLookupCfg, logger log.Logger) error {
+	return etl.Transform(logPrefix, tx, kv.HeaderCanonical, kv.DepositLookup, cfg.tmpdir, func(k, v []byte, next etl.ExtractNextFunc) error {
+		blocknum, blockHash := binary.BigEndian.Uint64(k), libcommon.CastToHash(v)
+		body, err := cfg.blockReader.BodyWithTransactions(ctx, tx, blockHash, blocknum)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			log.Debug("DepositLookup unwinding, empty block body", "height", blocknum)
+			return nil
+		}
+		for _, txn := range body.Transactions {
+			if deposit, ok := txn.(*types.DepositTx); ok {
+				if err := next(k, deposit.SourceHash.Bytes(), nil); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, etl.IdentityLoadFunc, etl.TransformArgs{
+		Quit:            ctx.Done(),
+		ExtractStartKey: hexutility.EncodeTs(blockFrom),
+		ExtractEndKey:   hexutility.EncodeTs(blockTo),
+		LogDetailsExtract: func(k, v []byte) (additionalLogArguments []interface{}) {
+			return []interface{}{"block", binary.BigEndian.Uint64(k)}
+		},
+	}, logger)
+}
+
+// PruneDepositLookup keeps the deposit lookup index whole, as the deposits are looked up long after they landed.
+func PruneDepositLookup(p *PruneState, tx kv.RwTx, cfg DepositLookupCfg, ctx context.Context) (err error) {
+	return nil
+}
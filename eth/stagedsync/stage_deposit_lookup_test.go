@@ -0,0 +1,61 @@
+package stagedsync
+
+import (
+	"testing"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/opstack"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+)
+
+func TestDepositLookupEntries(t *testing.T) {
+	l1Hash := libcommon.HexToHash("0x11")
+	l1InfoTx := func(seqNumber uint64) *types.DepositTx {
+		info := &opstack.L1BlockInfo{Number: 42, Hash: l1Hash, SequenceNumber: seqNumber, BaseFee: uint256.NewInt(1), BlobBaseFee: uint256.NewInt(1)}
+		return &types.DepositTx{
+			SourceHash: opstack.L1InfoDepositSource(l1Hash, seqNumber),
+			From:       opstack.L1InfoDepositorAddress,
+			Data:       info.MarshalEcotone(0, 0),
+		}
+	}
+	upgrade := libcommon.HexToHash("0x22")
+	txs := []types.Transaction{
+		l1InfoTx(0),
+		&types.DepositTx{SourceHash: opstack.UserDepositSource(l1Hash, 3)},
+		&types.DepositTx{SourceHash: opstack.UserDepositSource(l1Hash, 7)},
+		&types.DepositTx{SourceHash: upgrade},
+		types.NewTransaction(0, libcommon.Address{1}, uint256.NewInt(1), 21_000, uint256.NewInt(1), nil),
+	}
+
+	entries := map[libcommon.Hash]*rawdb.DepositLookupEntry{}
+	walk := func(sourceHash libcommon.Hash, entry *rawdb.DepositLookupEntry) error {
+		entries[sourceHash] = entry
+		return nil
+	}
+	require.NoError(t, DepositLookupEntries(5, txs, walk))
+	require.Len(t, entries, 4)
+	logIndex := func(i uint64) *uint64 { return &i }
+	for i, expected := range []*rawdb.DepositLookupEntry{
+		{BlockNumber: 5, TxIndex: 0, L1BlockNumber: 42, L1BlockHash: l1Hash},
+		{BlockNumber: 5, TxIndex: 1, L1BlockNumber: 42, L1BlockHash: l1Hash, L1LogIndex: logIndex(3)},
+		{BlockNumber: 5, TxIndex: 2, L1BlockNumber: 42, L1BlockHash: l1Hash, L1LogIndex: logIndex(7)},
+		{BlockNumber: 5, TxIndex: 3, L1BlockNumber: 42, L1BlockHash: l1Hash},
+	} {
+		sourceHash := txs[i].(*types.DepositTx).SourceHash
+		require.Equal(t, expected, entries[sourceHash])
+	}
+
+	// the later blocks of the epoch have no user deposits
+	clear(entries)
+	require.NoError(t, DepositLookupEntries(6, []types.Transaction{l1InfoTx(1), &types.DepositTx{SourceHash: opstack.UserDepositSource(l1Hash, 3)}}, walk))
+	require.Nil(t, entries[opstack.UserDepositSource(l1Hash, 3)].L1LogIndex)
+
+	// without L1 attributes deposit, the L1 origin is unknown
+	clear(entries)
+	require.NoError(t, DepositLookupEntries(7, []types.Transaction{&types.DepositTx{SourceHash: upgrade}}, walk))
+	require.Equal(t, &rawdb.DepositLookupEntry{BlockNumber: 7}, entries[upgrade])
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/eth/ethutils"
 	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/flashblocks"
 	"github.com/erigontech/erigon/turbo/services"
 )
 
@@ -37,6 +38,8 @@ type MiningBlock struct {
 
 	Deposits [][]byte
 	NoTxPool bool
+
+	Flashblocks *flashblocks.Block // streams the transactions as they are executed, nil if disabled
 }
 
 type MiningState struct {
@@ -17,6 +17,10 @@ import (
 	"github.com/erigontech/erigon-lib/common/metrics"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/membatch"
+	"github.com/erigontech/erigon-lib/opstack/interop"
+	"github.com/erigontech/erigon-lib/txpool"
+	"github.com/erigontech/erigon-lib/txpool/txpoolcfg"
+	"github.com/erigontech/erigon-lib/txpool/txpoolpolicy"
 	types2 "github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon/consensus"
 	"github.com/erigontech/erigon/consensus/misc"
@@ -28,6 +32,7 @@ import (
 	"github.com/erigontech/erigon/core/vm"
 	"github.com/erigontech/erigon/eth/stagedsync/stages"
 	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/flashblocks"
 	"github.com/erigontech/erigon/turbo/services"
 )
 
@@ -44,6 +49,10 @@ type MiningExecCfg struct {
 	payloadId   uint64
 	txPool      TxPoolForMining
 	txPoolDB    kv.RoDB
+	policy      *txpoolpolicy.Engine
+	interop     *interop.Checker
+	bundles     *txpool.BundlePool
+	flashblocks *flashblocks.Publisher
 }
 
 type TxPoolForMining interface {
@@ -55,8 +64,8 @@ func StageMiningExecCfg(
 	notifier ChainEventNotifier, chainConfig chain.Config,
 	engine consensus.Engine, vmConfig *vm.Config,
 	tmpdir string, interrupt *int32, payloadId uint64,
-	txPool TxPoolForMining, txPoolDB kv.RoDB,
-	blockReader services.FullBlockReader,
+	txPool TxPoolForMining, txPoolDB kv.RoDB, policy *txpoolpolicy.Engine, interopChecker *interop.Checker,
+	bundles *txpool.BundlePool, flashblocksPublisher *flashblocks.Publisher, blockReader services.FullBlockReader,
 ) MiningExecCfg {
 	return MiningExecCfg{
 		db:          db,
@@ -71,6 +80,10 @@ func StageMiningExecCfg(
 		payloadId:   payloadId,
 		txPool:      txPool,
 		txPoolDB:    txPoolDB,
+		policy:      policy,
+		interop:     interopChecker,
+		bundles:     bundles,
+		flashblocks: flashblocksPublisher,
 	}
 }
 
@@ -95,6 +108,8 @@ func SpawnMiningExecStage(s *StageState, tx kv.RwTx, cfg MiningExecCfg, quit <-c
 	// Optimism Canyon
 	misc.EnsureCreate2Deployer(&cfg.chainConfig, current.Header.Time, ibs)
 
+	current.Flashblocks = cfg.flashblocks.Begin(cfg.payloadId, current.Header)
+
 	// Create an empty block based on temporary copied state for
 	// sealing in advance without waiting block execution finished.
 	if !noempty {
@@ -124,7 +139,7 @@ func SpawnMiningExecStage(s *StageState, tx kv.RwTx, cfg MiningExecCfg, quit <-c
 			}
 			depTS := types.NewTransactionsFixedOrder(txs)
 
-			logs, _, err := addTransactionsToMiningBlock(logPrefix, current, cfg.chainConfig, cfg.vmConfig, getHeader, cfg.engine, depTS, cfg.miningState.MiningConfig.Etherbase, ibs, quit, cfg.interrupt, cfg.payloadId, logger)
+			logs, _, err := addTransactionsToMiningBlock(logPrefix, current, cfg.chainConfig, cfg.vmConfig, getHeader, cfg.engine, depTS, cfg.miningState.MiningConfig.Etherbase, ibs, quit, cfg.interrupt, cfg.payloadId, nil, nil, logger)
 			log.Debug("addTransactionsToMiningBlock (deposit) result", "err", err, "logs", logs)
 			if err != nil {
 				return err
@@ -132,7 +147,7 @@ func SpawnMiningExecStage(s *StageState, tx kv.RwTx, cfg MiningExecCfg, quit <-c
 		}
 
 		if txs != nil && !txs.Empty() {
-			logs, _, err := addTransactionsToMiningBlock(logPrefix, current, cfg.chainConfig, cfg.vmConfig, getHeader, cfg.engine, txs, cfg.miningState.MiningConfig.Etherbase, ibs, quit, cfg.interrupt, cfg.payloadId, logger)
+			logs, _, err := addTransactionsToMiningBlock(logPrefix, current, cfg.chainConfig, cfg.vmConfig, getHeader, cfg.engine, txs, cfg.miningState.MiningConfig.Etherbase, ibs, quit, cfg.interrupt, cfg.payloadId, nil, nil, logger)
 			log.Debug("addTransactionsToMiningBlock (txs) result", "err", err, "logs", logs)
 			if err != nil {
 				return err
@@ -151,34 +166,71 @@ func SpawnMiningExecStage(s *StageState, tx kv.RwTx, cfg MiningExecCfg, quit <-c
 				return err
 			}
 
-			for {
-				if current.NoTxPool {
-					// Only allow the Deposit transactions from op-node
-					log.Debug("Not adding transactions because NoTxPool is set")
-					break
-				}
-				txs, y, err := getNextTransactions(cfg, chainID, current.Header, 50, executionAt, stateReader, simulationTx, yielded, logger)
+			if !current.NoTxPool {
+				// the bundles are included atomically, right after the deposits
+				logs, err := addBundlesToMiningBlock(logPrefix, cfg, current, getHeader, stateReader, ibs, yielded, quit, logger)
 				if err != nil {
 					return err
 				}
+				NotifyPendingLogs(logPrefix, cfg.notifier, logs, logger)
+			}
 
-				if !txs.Empty() {
-					logs, stop, err := addTransactionsToMiningBlock(logPrefix, current, cfg.chainConfig, cfg.vmConfig, getHeader, cfg.engine, txs, cfg.miningState.MiningConfig.Etherbase, ibs, quit, cfg.interrupt, cfg.payloadId, logger)
+			execWorkers := cfg.miningState.MiningConfig.ExecWorkers
+			if cfg.interop != nil {
+				// a transaction whose executing messages fail the check is reverted after its execution
+				execWorkers = 0
+			}
+
+			// addTxPool adds the best transactions of the txpool until it runs dry, and
+			// reports whether the block is done: full, or retrieved by getPayload
+			addTxPool := func() (bool, error) {
+				for {
+					txs, y, err := getNextTransactions(cfg, chainID, current.Header, 50, executionAt, stateReader, simulationTx, yielded, logger)
+					if err != nil {
+						return false, err
+					}
+					if txs.Empty() {
+						return false, nil
+					}
+
+					parallel, err := startParallelMiningExec(context.Background(), cfg.db, execWorkers, &cfg.chainConfig, cfg.engine, cfg.vmConfig, current.Header, cfg.miningState.MiningConfig.Etherbase, txs, ibs, logger)
+					if err != nil {
+						return false, err
+					}
+					logs, stop, err := addTransactionsToMiningBlock(logPrefix, current, cfg.chainConfig, cfg.vmConfig, getHeader, cfg.engine, txs, cfg.miningState.MiningConfig.Etherbase, ibs, quit, cfg.interrupt, cfg.payloadId, cfg.interop, parallel, logger)
+					parallel.close()
 					log.Debug("addTransactionsToMiningBlock (regular)", "err", err, "logs", logs, "stop", stop)
 					if err != nil {
-						return err
+						return false, err
 					}
 					NotifyPendingLogs(logPrefix, cfg.notifier, logs, logger)
 					if stop {
-						break
+						return true, nil
+					}
+
+					// if we yielded less than the count we wanted, assume the txpool has run dry now and stop to save another loop
+					if y < 50 {
+						return false, nil
 					}
-				} else {
-					break
 				}
+			}
 
-				// if we yielded less than the count we wanted, assume the txpool has run dry now and stop to save another loop
-				if y < 50 {
-					break
+			if current.NoTxPool {
+				// Only allow the Deposit transactions from op-node
+				log.Debug("Not adding transactions because NoTxPool is set")
+			} else {
+				done, err := addTxPool()
+				if err != nil {
+					return err
+				}
+				// The payload streamed as flashblocks is built once: it keeps adding the
+				// transactions reaching the txpool on top of its state, and publishes
+				// them every flashblock interval, until getPayload or its timestamp
+				for !done && current.Flashblocks.Next(cfg.interrupt, time.Unix(int64(current.Header.Time), 0)) {
+					if done, err = addTxPool(); err != nil {
+						return err
+					}
+					current.Flashblocks.Flush()
 				}
 			}
 
@@ -186,6 +238,7 @@ func SpawnMiningExecStage(s *StageState, tx kv.RwTx, cfg MiningExecCfg, quit <-c
 		}
 	}
 
+	current.Flashblocks.Flush()
 	logger.Debug("SpawnMiningExecStage", "block", current.Header.Number, "txn", current.Txs.Len(), "payload", cfg.payloadId)
 	if current.Uncles == nil {
 		current.Uncles = []*types.Header{}
@@ -276,7 +329,7 @@ func getNextTransactions(
 	}
 
 	blockNum := executionAt + 1
-	txs, err := filterBadTransactions(txs, cfg.chainConfig, blockNum, header, stateReader, simulationTx, logger)
+	txs, err := filterBadTransactions(txs, cfg.chainConfig, blockNum, header, stateReader, simulationTx, cfg.policy, logger)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -284,7 +337,7 @@ func getNextTransactions(
 	return types.NewTransactionsFixedOrder(txs), count, nil
 }
 
-func filterBadTransactions(transactions []types.Transaction, config chain.Config, blockNumber uint64, header *types.Header, stateReader state.StateReader, simulationTx kv.StatelessRwTx, logger log.Logger) ([]types.Transaction, error) {
+func filterBadTransactions(transactions []types.Transaction, config chain.Config, blockNumber uint64, header *types.Header, stateReader state.StateReader, simulationTx kv.StatelessRwTx, policy *txpoolpolicy.Engine, logger log.Logger) ([]types.Transaction, error) {
 	initialCnt := len(transactions)
 	var filtered []types.Transaction
 	gasBailout := false
@@ -297,6 +350,7 @@ func filterBadTransactions(transactions []types.Transaction, config chain.Config
 	feeTooLowCnt := 0
 	balanceTooLowCnt := 0
 	overflowCnt := 0
+	policyCnt := 0
 	for len(transactions) > 0 && missedTxs != len(transactions) {
 		transaction := transactions[0]
 		sender, ok := transaction.GetSender()
@@ -322,6 +376,12 @@ func filterBadTransactions(transactions []types.Transaction, config chain.Config
 			transactions = transactions[1:]
 			continue
 		}
+		// The policy may have changed since the transaction was admitted to the pool
+		if reason := policy.Check(sender, transaction.GetTo(), transaction.GetData()); reason != txpoolcfg.Success {
+			transactions = transactions[1:]
+			policyCnt++
+			continue
+		}
 		// Check transaction nonce
 		if account.Nonce > transaction.GetNonce() {
 			transactions = transactions[1:]
@@ -418,13 +478,13 @@ func filterBadTransactions(transactions []types.Transaction, config chain.Config
 		filtered = append(filtered, transaction)
 		transactions = transactions[1:]
 	}
-	logger.Debug("Filtration", "initial", initialCnt, "no sender", noSenderCnt, "no account", noAccountCnt, "nonce too low", nonceTooLowCnt, "nonceTooHigh", missedTxs, "sender not EOA", notEOACnt, "fee too low", feeTooLowCnt, "overflow", overflowCnt, "balance too low", balanceTooLowCnt, "filtered", len(filtered))
+	logger.Debug("Filtration", "initial", initialCnt, "no sender", noSenderCnt, "no account", noAccountCnt, "nonce too low", nonceTooLowCnt, "nonceTooHigh", missedTxs, "sender not EOA", notEOACnt, "fee too low", feeTooLowCnt, "overflow", overflowCnt, "balance too low", balanceTooLowCnt, "policy", policyCnt, "filtered", len(filtered))
 	return filtered, nil
 }
 
 func addTransactionsToMiningBlock(logPrefix string, current *MiningBlock, chainConfig chain.Config, vmConfig *vm.Config, getHeader func(hash libcommon.Hash, number uint64) *types.Header,
 	engine consensus.Engine, txs types.TransactionsStream, coinbase libcommon.Address, ibs *state.IntraBlockState, quit <-chan struct{},
-	interrupt *int32, payloadId uint64, logger log.Logger) (types.Logs, bool, error) {
+	interrupt *int32, payloadId uint64, interopChecker *interop.Checker, parallel *parallelMiningExec, logger log.Logger) (types.Logs, bool, error) {
 	header := current.Header
 	tcount := 0
 	gasPool := new(core.GasPool).AddGas(header.GasLimit - header.GasUsed)
@@ -432,16 +492,31 @@ func addTransactionsToMiningBlock(logPrefix string, current *MiningBlock, chainC
 		gasPool.AddBlobGas(chainConfig.GetMaxBlobGasPerBlock() - *header.BlobGasUsed)
 	}
 	signer := types.MakeSigner(&chainConfig, header.Number.Uint64(), header.Time)
+	rules := chainConfig.Rules(header.Number.Uint64(), header.Time)
 
 	var coalescedLogs types.Logs
-	noop := state.NewNoopWriter()
 
 	var miningCommitTx = func(txn types.Transaction, coinbase libcommon.Address, vmConfig *vm.Config, chainConfig chain.Config, ibs *state.IntraBlockState, current *MiningBlock) ([]*types.Log, error) {
 		ibs.SetTxContext(txn.Hash(), libcommon.Hash{}, tcount)
 		gasSnap := gasPool.Gas()
 		blobGasSnap := gasPool.BlobGas()
+		gasUsedSnap := header.GasUsed
 		snap := ibs.Snapshot()
-		receipt, _, err := core.ApplyTransaction(&chainConfig, core.GetHashFn(header, getHeader), engine, &coinbase, gasPool, ibs, noop, header, txn, &header.GasUsed, header.BlobGasUsed, *vmConfig)
+		stateWriter := parallel.stateWriter(current.Flashblocks.StateWriter())
+		var receipt *types.Receipt
+		var err error
+		if parallel != nil {
+			receipt, err = parallel.commit(txn, gasPool, ibs, stateWriter, header, rules)
+		}
+		if receipt == nil && err == nil {
+			receipt, _, err = core.ApplyTransaction(&chainConfig, core.GetHashFn(header, getHeader), engine, &coinbase, gasPool, ibs, stateWriter, header, txn, &header.GasUsed, header.BlobGasUsed, *vmConfig)
+		}
+		if err == nil {
+			err = checkExecutingMessages(interopChecker, txn, receipt, header.Time)
+			if err != nil {
+				header.GasUsed = gasUsedSnap
+			}
+		}
 		if err != nil {
 			ibs.RevertToSnapshot(snap)
 			gasPool = new(core.GasPool).AddGas(gasSnap).AddBlobGas(blobGasSnap) // restore gasPool as well as ibs
@@ -450,6 +525,7 @@ func addTransactionsToMiningBlock(logPrefix string, current *MiningBlock, chainC
 
 		current.Txs = append(current.Txs, txn)
 		current.Receipts = append(current.Receipts, receipt)
+		current.Flashblocks.AddTransaction(txn, receipt)
 		return receipt.Logs, nil
 	}
 
@@ -527,6 +603,10 @@ LOOP:
 			// Reorg notification data race between the transaction pool and miner, skip account =
 			logger.Debug(fmt.Sprintf("[%s] Skipping transaction with high nonce", logPrefix), "hash", txn.Hash(), "sender", from, "nonce", txn.GetNonce())
 			txs.Pop()
+		} else if errors.Is(err, interop.ErrInvalidMessage) {
+			// The transaction executes an invalid cross-chain message, skip the account
+			logger.Debug(fmt.Sprintf("[%s] Skipping transaction with invalid executing message", logPrefix), "hash", txn.Hash(), "sender", from, "err", err)
+			txs.Pop()
 		} else if err == nil {
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			logger.Trace(fmt.Sprintf("[%s] Added transaction", logPrefix), "hash", txn.Hash(), "sender", from, "nonce", txn.GetNonce(), "payload", payloadId)
@@ -552,6 +632,38 @@ LOOP:
 
 }
 
+// checkExecutingMessages validates with the interop supervisor the cross-chain
+// messages executed by the transaction, declared in its access list or emitted
+// by the CrossL2Inbox. A transaction which can't be validated is not included.
+func checkExecutingMessages(interopChecker *interop.Checker, txn types.Transaction, receipt *types.Receipt, timestamp uint64) error {
+	if interopChecker == nil || txn.Type() == types.DepositTxType {
+		return nil
+	}
+	var entries []libcommon.Hash
+	for _, tuple := range txn.GetAccessList() {
+		if tuple.Address == interop.CrossL2InboxAddr {
+			entries = append(entries, tuple.StorageKeys...)
+		}
+	}
+	for _, l := range receipt.Logs {
+		msg, err := interop.ExecutingMessageFromLog(l.Address, l.Topics, l.Data)
+		if err != nil {
+			return fmt.Errorf("%w: %s", interop.ErrInvalidMessage, err)
+		}
+		if msg != nil {
+			entries = append(entries, msg.AccessListEntries()...)
+		}
+	}
+	if err := interopChecker.Check(context.Background(), entries, timestamp); err != nil {
+		if errors.Is(err, interop.ErrInvalidMessage) {
+			return err
+		}
+		// fail closed: the block must not include messages which were not validated
+		return fmt.Errorf("%w: %s", interop.ErrInvalidMessage, err)
+	}
+	return nil
+}
+
 func NotifyPendingLogs(logPrefix string, notifier ChainEventNotifier, logs types.Logs, logger log.Logger) {
 	if len(logs) == 0 {
 		return
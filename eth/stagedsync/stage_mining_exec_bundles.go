@@ -0,0 +1,164 @@
+package stagedsync
+
+import (
+	"errors"
+	"fmt"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/txpool"
+	"github.com/erigontech/erigon-lib/txpool/txpoolcfg"
+
+	"github.com/erigontech/erigon/cmd/state/exec3"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+)
+
+var (
+	errBundleTxType   = errors.New("transaction type not allowed in bundles")
+	errBundleTxHash   = errors.New("transaction hash mismatch")
+	errBundleReverted = errors.New("transaction reverted")
+)
+
+// addBundlesToMiningBlock includes the bundles targeting the block, in
+// submission order, right after its deposits. Every bundle is simulated on top
+// of the block first: it is included only if all its transactions are, and
+// none of them reverts unless it is allowed to. Otherwise it is dropped
+// entirely. The hashes of the included transactions are added to included, for
+// the txpool not to yield them again.
+func addBundlesToMiningBlock(logPrefix string, cfg MiningExecCfg, current *MiningBlock, getHeader func(hash libcommon.Hash, number uint64) *types.Header,
+	stateReader state.StateReader, ibs *state.IntraBlockState, included mapset.Set[[32]byte], quit <-chan struct{}, logger log.Logger) (types.Logs, error) {
+	header := current.Header
+	bundles := cfg.bundles.Bundles(header.Number.Uint64(), header.Time)
+	if len(bundles) == 0 {
+		return nil, nil
+	}
+	signer := types.MakeSigner(&cfg.chainConfig, header.Number.Uint64(), header.Time)
+	coinbase := cfg.miningState.MiningConfig.Etherbase
+
+	var logs types.Logs
+	for _, bundle := range bundles {
+		txs, err := decodeBundle(bundle, signer, cfg)
+		if err == nil {
+			err = simulateBundle(bundle, txs, cfg, current, getHeader, stateReader, ibs)
+		}
+		if err != nil {
+			logger.Debug(fmt.Sprintf("[%s] Dropping bundle", logPrefix), "hash", bundle.Hash, "err", err, "payload", cfg.payloadId)
+			continue
+		}
+
+		bundleLogs, added, err := addBundleToMiningBlock(logPrefix, cfg, current, getHeader, txs, coinbase, ibs, quit, logger)
+		if err != nil {
+			return nil, err
+		}
+		if !added {
+			// the execution diverged from the simulation on the same state
+			logger.Warn(fmt.Sprintf("[%s] Reverted partially included bundle", logPrefix), "hash", bundle.Hash, "txs", len(txs), "payload", cfg.payloadId)
+			continue
+		}
+		logger.Debug(fmt.Sprintf("[%s] Added bundle", logPrefix), "hash", bundle.Hash, "txs", len(txs), "payload", cfg.payloadId)
+		for _, txn := range txs {
+			included.Add(txn.Hash())
+		}
+		logs = append(logs, bundleLogs...)
+	}
+	return logs, nil
+}
+
+// addBundleToMiningBlock adds the transactions of a simulated bundle to the
+// block, all or none of them: the block and its state are reverted if one of
+// them is not included, and it reports whether they were added.
+func addBundleToMiningBlock(logPrefix string, cfg MiningExecCfg, current *MiningBlock, getHeader func(hash libcommon.Hash, number uint64) *types.Header,
+	txs []types.Transaction, coinbase libcommon.Address, ibs *state.IntraBlockState, quit <-chan struct{}, logger log.Logger) (types.Logs, bool, error) {
+	header := current.Header
+	count, gasUsed := len(current.Txs), header.GasUsed
+	var blobGasUsed *uint64
+	if header.BlobGasUsed != nil {
+		blobGasUsed = new(uint64)
+		*blobGasUsed = *header.BlobGasUsed
+	}
+
+	ibs.HoldJournal()
+	defer ibs.ReleaseJournal()
+	snap := ibs.Snapshot()
+	current.Flashblocks.Hold()
+	logs, _, err := addTransactionsToMiningBlock(logPrefix, current, cfg.chainConfig, cfg.vmConfig, getHeader, cfg.engine, types.NewTransactionsFixedOrder(append([]types.Transaction(nil), txs...)), coinbase, ibs, quit, nil, cfg.payloadId, nil, nil, logger)
+	if err != nil {
+		current.Flashblocks.Discard()
+		return nil, false, err
+	}
+	if len(current.Txs)-count != len(txs) {
+		ibs.RevertToSnapshot(snap)
+		current.Txs, current.Receipts = current.Txs[:count], current.Receipts[:count]
+		header.GasUsed, header.BlobGasUsed = gasUsed, blobGasUsed
+		current.Flashblocks.Discard()
+		return nil, false, nil
+	}
+	current.Flashblocks.Commit()
+	return logs, true, nil
+}
+
+// decodeBundle decodes the transactions of the bundle and recovers their
+// senders. Deposits and blob transactions are not allowed in bundles.
+func decodeBundle(bundle *txpool.Bundle, signer *types.Signer, cfg MiningExecCfg) ([]types.Transaction, error) {
+	txs := make([]types.Transaction, len(bundle.Txs))
+	for i, encoded := range bundle.Txs {
+		txn, err := types.UnmarshalTransactionFromBinary(encoded, false)
+		if err != nil {
+			return nil, err
+		}
+		switch txn.Type() {
+		case types.DepositTxType, types.BlobTxType:
+			return nil, fmt.Errorf("%w: %d", errBundleTxType, txn.Type())
+		}
+		if txn.Hash() != bundle.TxHashes[i] {
+			return nil, fmt.Errorf("%w: %x", errBundleTxHash, bundle.TxHashes[i])
+		}
+		sender, err := txn.Sender(*signer)
+		if err != nil {
+			return nil, err
+		}
+		// The policy may have changed since the bundle was submitted
+		if reason := cfg.policy.Check(sender, txn.GetTo(), txn.GetData()); reason != txpoolcfg.Success {
+			return nil, fmt.Errorf("transaction %x: %s", txn.Hash(), reason)
+		}
+		txs[i] = txn
+	}
+	return txs, nil
+}
+
+// simulateBundle executes the transactions of the bundle on top of the block
+// being built, on a copy of its state: the cross-transaction reverts are not
+// supported by the IntraBlockState of the block.
+func simulateBundle(bundle *txpool.Bundle, txs []types.Transaction, cfg MiningExecCfg, current *MiningBlock, getHeader func(hash libcommon.Hash, number uint64) *types.Header,
+	stateReader state.StateReader, ibs *state.IntraBlockState) error {
+	header := types.CopyHeader(current.Header)
+	rules := cfg.chainConfig.Rules(header.Number.Uint64(), header.Time)
+	vs := exec3.NewVersionedState()
+	if err := ibs.MakeWriteSet(rules, vs.Writer(state.NewNoopWriter())); err != nil {
+		return err
+	}
+	simulation := state.New(vs.Reader(stateReader))
+
+	vmConfig := *cfg.vmConfig
+	vmConfig.Debug, vmConfig.Tracer = false, nil
+	coinbase := cfg.miningState.MiningConfig.Etherbase
+	gasPool := new(core.GasPool).AddGas(header.GasLimit - header.GasUsed)
+	for i, txn := range txs {
+		simulation.SetTxContext(txn.Hash(), libcommon.Hash{}, len(current.Txs)+i)
+		receipt, _, err := core.ApplyTransaction(&cfg.chainConfig, core.GetHashFn(header, getHeader), cfg.engine, &coinbase, gasPool, simulation, state.NewNoopWriter(), header, txn, &header.GasUsed, header.BlobGasUsed, vmConfig)
+		if err != nil {
+			return fmt.Errorf("transaction %x: %w", txn.Hash(), err)
+		}
+		if receipt.Status == types.ReceiptStatusFailed && !bundle.CanRevert(txn.Hash()) {
+			return fmt.Errorf("%w: %x", errBundleReverted, txn.Hash())
+		}
+		if err := checkExecutingMessages(cfg.interop, txn, receipt, header.Time); err != nil {
+			return fmt.Errorf("transaction %x: %w", txn.Hash(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package stagedsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/txpool"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+)
+
+func TestAddBundlesToMiningBlock(t *testing.T) {
+	logger := log.New()
+	db := memdb.NewTestDB(t)
+	config := params.TestChainConfig
+	coinbase := libcommon.HexToAddress("0xc0ffee")
+	// always reverts
+	reverter := libcommon.HexToAddress("0xc1")
+
+	keys := make([]*ecdsa.PrivateKey, 5)
+	alloc := types.GenesisAlloc{
+		reverter: {Code: hexutility.MustDecodeHex("0x60006000fd"), Balance: new(big.Int)},
+	}
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = types.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	_, genesis, err := core.CommitGenesisBlock(db, &types.Genesis{Config: config, Alloc: alloc, GasLimit: 30_000_000}, t.TempDir(), logger)
+	require.NoError(t, err)
+
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), GasLimit: 30_000_000, Time: 1, Difficulty: big.NewInt(1), Coinbase: coinbase}
+	signer := types.MakeSigner(config, 1, 1)
+	transfer := func(key *ecdsa.PrivateKey, nonce uint64) types.Transaction {
+		return types.MustSignNewTx(key, *signer, types.NewTransaction(nonce, libcommon.Address{0xaa}, uint256.NewInt(1000), 21_000, uint256.NewInt(1), nil))
+	}
+	revert := func(key *ecdsa.PrivateKey, nonce uint64) types.Transaction {
+		return types.MustSignNewTx(key, *signer, types.NewTransaction(nonce, reverter, uint256.NewInt(0), 100_000, uint256.NewInt(1), nil))
+	}
+
+	pool := txpool.NewBundlePool(10)
+	send := func(minBlock uint64, reverting []libcommon.Hash, txs ...types.Transaction) {
+		bundle := &txpool.Bundle{MinBlock: minBlock, RevertingTxHashes: reverting}
+		for _, txn := range txs {
+			var buf bytes.Buffer
+			require.NoError(t, txn.MarshalBinary(&buf))
+			bundle.Txs = append(bundle.Txs, buf.Bytes())
+			bundle.TxHashes = append(bundle.TxHashes, txn.Hash())
+		}
+		_, err := pool.Add(bundle)
+		require.NoError(t, err)
+	}
+	included := []types.Transaction{transfer(keys[0], 0), transfer(keys[1], 0)}
+	send(1, nil, included...)
+	// the transfer is dropped along with the reverting transaction
+	send(1, nil, transfer(keys[2], 0), revert(keys[3], 0))
+	allowed := revert(keys[4], 0)
+	send(1, []libcommon.Hash{allowed.Hash()}, allowed)
+	included = append(included, allowed)
+	// conflicts with the first bundle
+	send(1, nil, transfer(keys[0], 0), transfer(keys[3], 0))
+	// targets the next block
+	send(2, nil, transfer(keys[3], 0))
+
+	tx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	cfg := MiningExecCfg{
+		chainConfig: *config,
+		vmConfig:    &vm.Config{},
+		miningState: MiningState{MiningConfig: &params.MiningConfig{Etherbase: coinbase}},
+		bundles:     pool,
+	}
+	current := &MiningBlock{Header: types.CopyHeader(header)}
+	stateReader := state.NewPlainStateReader(tx)
+	ibs := state.New(stateReader)
+	getHeader := func(hash libcommon.Hash, number uint64) *types.Header { return rawdb.ReadHeader(tx, hash, number) }
+	yielded := mapset.NewSet[[32]byte]()
+	_, err = addBundlesToMiningBlock("test", cfg, current, getHeader, stateReader, ibs, yielded, nil, logger)
+	require.NoError(t, err)
+
+	require.Len(t, current.Txs, len(included))
+	for i, txn := range included {
+		require.Equal(t, txn.Hash(), current.Txs[i].Hash())
+		require.True(t, yielded.Contains(txn.Hash()))
+	}
+	require.Equal(t, types.ReceiptStatusSuccessful, current.Receipts[0].Status)
+	require.Equal(t, types.ReceiptStatusFailed, current.Receipts[2].Status)
+	require.Equal(t, 2*params.TxGas+current.Receipts[2].GasUsed, current.Header.GasUsed)
+	// the bundles are kept until their target block range passes
+	require.Equal(t, 5, pool.Len())
+}
+
+func TestAddBundleToMiningBlockRevertsPartialInclusion(t *testing.T) {
+	logger := log.New()
+	db := memdb.NewTestDB(t)
+	config := params.TestChainConfig
+	coinbase := libcommon.HexToAddress("0xc0ffee")
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	alloc := types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}}
+	_, genesis, err := core.CommitGenesisBlock(db, &types.Genesis{Config: config, Alloc: alloc, GasLimit: 30_000_000}, t.TempDir(), logger)
+	require.NoError(t, err)
+
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), GasLimit: 30_000_000, Time: 1, Difficulty: big.NewInt(1), Coinbase: coinbase}
+	signer := types.MakeSigner(config, 1, 1)
+	transfer := func(nonce uint64) types.Transaction {
+		return types.MustSignNewTx(key, *signer, types.NewTransaction(nonce, libcommon.Address{0xaa}, uint256.NewInt(1000), 21_000, uint256.NewInt(1), nil))
+	}
+
+	tx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	cfg := MiningExecCfg{chainConfig: *config, vmConfig: &vm.Config{}}
+	current := &MiningBlock{Header: types.CopyHeader(header)}
+	ibs := state.New(state.NewPlainStateReader(tx))
+	getHeader := func(hash libcommon.Hash, number uint64) *types.Header { return rawdb.ReadHeader(tx, hash, number) }
+
+	// the second transaction skips a nonce, so only the first one is executed
+	_, added, err := addBundleToMiningBlock("test", cfg, current, getHeader, []types.Transaction{transfer(0), transfer(2)}, coinbase, ibs, nil, logger)
+	require.NoError(t, err)
+	require.False(t, added)
+	require.Empty(t, current.Txs)
+	require.Empty(t, current.Receipts)
+	require.Zero(t, current.Header.GasUsed)
+	require.Zero(t, ibs.GetNonce(sender))
+	require.Equal(t, uint256.NewInt(params.Ether), ibs.GetBalance(sender))
+	require.True(t, ibs.GetBalance(libcommon.Address{0xaa}).IsZero())
+
+	// while a bundle included in full is kept
+	_, added, err = addBundleToMiningBlock("test", cfg, current, getHeader, []types.Transaction{transfer(0), transfer(1)}, coinbase, ibs, nil, logger)
+	require.NoError(t, err)
+	require.True(t, added)
+	require.Len(t, current.Txs, 2)
+	require.Equal(t, uint64(2), ibs.GetNonce(sender))
+	require.Equal(t, 2*params.TxGas, current.Header.GasUsed)
+}
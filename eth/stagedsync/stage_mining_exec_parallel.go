@@ -0,0 +1,242 @@
+package stagedsync
+
+import (
+	"context"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/state/exec22"
+	"github.com/erigontech/erigon/cmd/state/exec3"
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+)
+
+// parallelMiningExec speculatively executes a batch of candidate transactions
+// on a pool of exec3 mining workers, Block-STM style, for
+// addTransactionsToMiningBlock to commit them in the order of the batch.
+//
+// The workers execute on top of a VersionedState holding the changes of the
+// block, updated by every commit. A speculative execution is committed from
+// its write set only if none of the accounts and storage slots it read were
+// written since, otherwise the transaction is executed serially as before:
+// the block is the same as with serial execution.
+type parallelMiningExec struct {
+	vs      *exec3.VersionedState
+	tasks   []*exec22.TxTask // in the order of the batch, nil for the transactions executed serially
+	next    int
+	in      *exec22.QueueWithRetry
+	rws     *exec22.ResultsQueue
+	ctx     context.Context
+	clear   func() error
+	results map[uint64]*exec22.TxTask
+	logger  log.Logger
+
+	committed, conflicts int
+}
+
+// startParallelMiningExec starts the speculative execution of txs, when it is
+// enabled and supported. The state of ibs is the one of the block before txs:
+// its journal must be clean, i.e. the previous transactions finalized.
+func startParallelMiningExec(ctx context.Context, db kv.RoDB, workers int, chainConfig *chain.Config, engine consensus.Engine, vmConfig *vm.Config,
+	header *types.Header, coinbase libcommon.Address, txs types.TransactionsStream, ibs *state.IntraBlockState, logger log.Logger) (*parallelMiningExec, error) {
+	fixed, ok := txs.(*types.TransactionsFixedOrder)
+	if !ok || workers <= 1 || db == nil || vmConfig.Debug || vmConfig.Tracer != nil {
+		return nil, nil
+	}
+
+	header = types.CopyHeader(header)
+	rules := chainConfig.Rules(header.Number.Uint64(), header.Time)
+	skipAnalysis := core.SkipAnalysis(chainConfig, header.Number.Uint64())
+	e := &parallelMiningExec{
+		vs:      exec3.NewVersionedState(),
+		tasks:   make([]*exec22.TxTask, len(fixed.Transactions)),
+		results: map[uint64]*exec22.TxTask{},
+		logger:  logger,
+	}
+	var count int
+	for i, txn := range fixed.Transactions {
+		if !parallelMiningSupported(txn) {
+			continue
+		}
+		e.tasks[i] = &exec22.TxTask{
+			TxNum:        uint64(i),
+			BlockNum:     header.Number.Uint64(),
+			Rules:        rules,
+			Header:       header,
+			Coinbase:     coinbase,
+			SkipAnalysis: skipAnalysis,
+			TxIndex:      i,
+			Tx:           txn,
+		}
+		count++
+	}
+	if count < 2 {
+		return nil, nil
+	}
+
+	// the changes of the block so far are the base of the versioned state
+	if err := ibs.MakeWriteSet(rules, e.vs.Writer(state.NewNoopWriter())); err != nil {
+		return nil, err
+	}
+
+	e.in = exec22.NewQueueWithRetry(count)
+	e.rws, e.ctx, e.clear = exec3.NewMiningWorkersPool(ctx, db, e.vs, e.in, chainConfig, engine, *vmConfig, header, min(workers, count), count)
+	for _, task := range e.tasks {
+		if task != nil {
+			e.in.Add(e.ctx, task)
+		}
+	}
+	return e, nil
+}
+
+// parallelMiningSupported reports whether the result of the speculative
+// execution of txn can be committed. The deposits, contract creations, blob
+// and free transactions are executed serially.
+func parallelMiningSupported(txn types.Transaction) bool {
+	switch txn.Type() {
+	case types.DepositTxType, types.BlobTxType:
+		return false
+	}
+	return txn.GetTo() != nil && !txn.GetFeeCap().IsZero()
+}
+
+// close stops the workers.
+func (e *parallelMiningExec) close() {
+	if e == nil {
+		return
+	}
+	if err := e.clear(); err != nil {
+		e.logger.Debug("Parallel execution of the block transactions stopped", "err", err)
+	}
+	e.logger.Debug("Parallel execution of the block transactions", "committed", e.committed, "conflicts", e.conflicts)
+}
+
+// stateWriter returns the writer of the changes of the next transaction
+// committed to the block.
+func (e *parallelMiningExec) stateWriter(next state.StateWriter) state.StateWriter {
+	if e == nil {
+		return next
+	}
+	return e.vs.Writer(next)
+}
+
+// result returns the speculative execution of txn, or nil if it must be
+// executed serially.
+func (e *parallelMiningExec) result(txn types.Transaction) *exec22.TxTask {
+	if e == nil {
+		return nil
+	}
+	var task *exec22.TxTask
+	for ; e.next < len(e.tasks); e.next++ {
+		if t := e.tasks[e.next]; t != nil && t.Tx == txn {
+			task = t
+			e.next++
+			break
+		}
+	}
+	if task == nil {
+		return nil
+	}
+	e.rws.DrainNonBlocking()
+	e.collect(task.TxNum)
+	// re-execute the following transactions which read what was committed since their execution
+	for txNum, res := range e.results {
+		if txNum > task.TxNum && res.Incarnation < exec3.MaxMiningIncarnation && !e.vs.Validate(res.VersionedReads) {
+			delete(e.results, txNum)
+			res.Incarnation++
+			e.in.ReTry(res)
+		}
+	}
+	for {
+		if res, ok := e.results[task.TxNum]; ok {
+			delete(e.results, task.TxNum)
+			return res
+		}
+		if err := e.rws.Drain(e.ctx); err != nil {
+			return nil
+		}
+		e.collect(task.TxNum)
+	}
+}
+
+// collect moves the results from the results queue, dropping the ones of the
+// transactions before txNum.
+func (e *parallelMiningExec) collect(txNum uint64) {
+	iter := e.rws.Iter()
+	defer iter.Close()
+	for e.rws.HasLocked() {
+		res := iter.PopNext()
+		if res.TxNum >= txNum {
+			e.results[res.TxNum] = res
+		}
+	}
+}
+
+// commit commits the speculative execution of txn to ibs, as
+// core.ApplyTransaction would have executed it. It returns a nil receipt when
+// txn must be executed serially.
+func (e *parallelMiningExec) commit(txn types.Transaction, gasPool *core.GasPool, ibs *state.IntraBlockState, stateWriter state.StateWriter, header *types.Header, rules *chain.Rules) (*types.Receipt, error) {
+	res := e.result(txn)
+	if res == nil || res.Error != nil || res.VersionedWrites.Unsupported {
+		return nil, nil
+	}
+	if !e.vs.Validate(res.VersionedReads) {
+		e.conflicts++
+		return nil, nil
+	}
+	for key, value := range res.VersionedWrites.Storage {
+		// a slot restored to its value is still written by the serial execution
+		var current uint256.Int
+		ibs.GetState(key.Address, &key.Key, &current)
+		if current == value {
+			return nil, nil
+		}
+	}
+
+	if err := gasPool.SubGas(txn.GetGas()); err != nil {
+		return nil, err
+	}
+	gasPool.AddGas(txn.GetGas() - res.UsedGas)
+
+	for address, account := range res.VersionedWrites.Accounts {
+		ibs.SetNonce(address, account.Nonce)
+		ibs.SetBalance(address, &account.Balance)
+	}
+	for key, value := range res.VersionedWrites.Storage {
+		ibs.SetState(key.Address, &key.Key, value)
+	}
+	for address, increase := range res.BalanceIncreaseSet {
+		ibs.AddBalance(address, &increase)
+	}
+	for _, l := range res.Logs {
+		l := *l
+		ibs.AddLog(&l)
+	}
+	if err := ibs.FinalizeTx(rules, stateWriter); err != nil {
+		return nil, err
+	}
+	header.GasUsed += res.UsedGas
+	e.committed++
+
+	receipt := &types.Receipt{Type: txn.Type(), CumulativeGasUsed: header.GasUsed}
+	if res.Failed {
+		receipt.Status = types.ReceiptStatusFailed
+	} else {
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+	receipt.TxHash = txn.Hash()
+	receipt.GasUsed = res.UsedGas
+	receipt.Logs = ibs.GetLogs(txn.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	receipt.BlockNumber = header.Number
+	receipt.TransactionIndex = uint(ibs.TxIndex())
+	return receipt, nil
+}
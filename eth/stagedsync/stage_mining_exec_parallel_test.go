@@ -0,0 +1,285 @@
+package stagedsync
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/params"
+)
+
+// mapStateWriter collects the final state changes of a block.
+type mapStateWriter map[string]string
+
+func (w mapStateWriter) UpdateAccountData(address libcommon.Address, original, account *accounts.Account) error {
+	w[fmt.Sprintf("account %x", address)] = fmt.Sprintf("nonce=%d balance=%d inc=%d code=%x", account.Nonce, &account.Balance, account.Incarnation, account.CodeHash)
+	return nil
+}
+
+func (w mapStateWriter) UpdateAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash, code []byte) error {
+	w[fmt.Sprintf("code %x", address)] = fmt.Sprintf("%x", codeHash)
+	return nil
+}
+
+func (w mapStateWriter) DeleteAccount(address libcommon.Address, original *accounts.Account) error {
+	w[fmt.Sprintf("account %x", address)] = "deleted"
+	return nil
+}
+
+func (w mapStateWriter) WriteAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash, original, value *uint256.Int) error {
+	w[fmt.Sprintf("storage %x %d %x", address, incarnation, *key)] = value.Hex()
+	return nil
+}
+
+func (w mapStateWriter) CreateContract(address libcommon.Address) error {
+	w[fmt.Sprintf("create %x", address)] = ""
+	return nil
+}
+
+// teeStateWriter writes the state changes to every writer.
+type teeStateWriter []state.StateWriter
+
+func (w teeStateWriter) UpdateAccountData(address libcommon.Address, original, account *accounts.Account) error {
+	for _, writer := range w {
+		if err := writer.UpdateAccountData(address, original, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w teeStateWriter) UpdateAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash, code []byte) error {
+	for _, writer := range w {
+		if err := writer.UpdateAccountCode(address, incarnation, codeHash, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w teeStateWriter) DeleteAccount(address libcommon.Address, original *accounts.Account) error {
+	for _, writer := range w {
+		if err := writer.DeleteAccount(address, original); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w teeStateWriter) WriteAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash, original, value *uint256.Int) error {
+	for _, writer := range w {
+		if err := writer.WriteAccountStorage(address, incarnation, key, original, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w teeStateWriter) CreateContract(address libcommon.Address) error {
+	for _, writer := range w {
+		if err := writer.CreateContract(address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestParallelMiningExec(t *testing.T) {
+	logger := log.New()
+	db := memdb.NewTestDB(t)
+	config := params.TestChainConfig
+	coinbase := libcommon.HexToAddress("0xc0ffee")
+	// increments slot 0: every call conflicts with the previous ones
+	counter := libcommon.HexToAddress("0xc1")
+	// sets the slot of the caller and emits a log
+	registry := libcommon.HexToAddress("0xc2")
+
+	keys := make([]*ecdsa.PrivateKey, 8)
+	alloc := types.GenesisAlloc{
+		counter:  {Code: hexutility.MustDecodeHex("0x60005460010160005500"), Balance: new(big.Int)},
+		registry: {Code: hexutility.MustDecodeHex("0x6001335560006000a000"), Balance: new(big.Int)},
+	}
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = types.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	_, genesis, err := core.CommitGenesisBlock(db, &types.Genesis{Config: config, Alloc: alloc, GasLimit: 30_000_000}, t.TempDir(), logger)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		return stages.SaveStageProgress(tx, stages.Execution, 0)
+	}))
+
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), GasLimit: 30_000_000, Time: 1, Difficulty: big.NewInt(1), Coinbase: coinbase}
+	signer := types.MakeSigner(config, 1, 1)
+	var txs []types.Transaction
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		for i, key := range keys {
+			var to libcommon.Address
+			switch nonce {
+			case 0, 3:
+				to = libcommon.BytesToAddress([]byte{0xaa, byte(nonce), byte(i)})
+			case 1:
+				to = registry
+			case 2:
+				to = counter
+			}
+			txs = append(txs, types.MustSignNewTx(key, *signer, types.NewTransaction(nonce, to, uint256.NewInt(1000), 100_000, uint256.NewInt(1), nil)))
+		}
+	}
+
+	serial := buildParallelMiningBlock(t, db, config, header, nil, txs, 0, logger)
+	require.Len(t, serial.Txs, len(txs))
+	for i := 0; i < 5; i++ {
+		serial.requireEqual(t, buildParallelMiningBlock(t, db, config, header, nil, txs, 4, logger))
+	}
+}
+
+// The parallel execution on an OP chain: the L1 attributes deposit sets the L1
+// fees of the transactions executed by the workers, the fee vaults and the
+// coinbase are credited by every transaction, and the deposits of the batch are
+// executed in between.
+func TestParallelMiningExecOptimism(t *testing.T) {
+	logger := log.New()
+	db := memdb.NewTestDB(t)
+	config := *params.TestChainConfig
+	config.Optimism = &chain.OptimismConfig{EIP1559Elasticity: 6, EIP1559Denominator: 50}
+	config.BedrockBlock = big.NewInt(0)
+	config.RegolithTime, config.CanyonTime, config.EcotoneTime, config.FjordTime = big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0)
+	coinbase := libcommon.HexToAddress("0xc0ffee")
+	counter := libcommon.HexToAddress("0xc1")
+	depositor := libcommon.HexToAddress("0xde")
+
+	keys := make([]*ecdsa.PrivateKey, 8)
+	alloc := types.GenesisAlloc{
+		counter: {Code: hexutility.MustDecodeHex("0x60005460010160005500"), Balance: new(big.Int)},
+		// stores the L1 base fee, the fee scalars and the L1 blob base fee of the calldata
+		opstack.L1BlockAddr: {Code: hexutility.MustDecodeHex("0x60003560015560203560035560403560075500"), Balance: new(big.Int)},
+	}
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = types.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	_, genesis, err := core.CommitGenesisBlock(db, &types.Genesis{Config: &config, Alloc: alloc, GasLimit: 30_000_000}, t.TempDir(), logger)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		return stages.SaveStageProgress(tx, stages.Execution, 0)
+	}))
+
+	l1Attributes := make([]byte, 3*32)
+	uint256.NewInt(params.GWei).WriteToSlice(l1Attributes[:32])
+	binary.BigEndian.PutUint32(l1Attributes[32+16:], 1368)
+	binary.BigEndian.PutUint32(l1Attributes[32+20:], 810949)
+	uint256.NewInt(1).WriteToSlice(l1Attributes[64:])
+	deposits := []types.Transaction{&types.DepositTx{
+		SourceHash: libcommon.Hash{1},
+		From:       opstack.L1InfoDepositorAddress,
+		To:         &opstack.L1BlockAddr,
+		Value:      new(uint256.Int),
+		Gas:        1_000_000,
+		Data:       l1Attributes,
+	}}
+
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), GasLimit: 30_000_000, Time: 1, Difficulty: big.NewInt(1), Coinbase: coinbase, BaseFee: big.NewInt(1)}
+	signer := types.MakeSigner(&config, 1, 1)
+	var txs []types.Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		for i, key := range keys {
+			to := libcommon.BytesToAddress([]byte{0xaa, byte(nonce), byte(i)})
+			if nonce == 1 {
+				to = counter
+			}
+			txs = append(txs, types.MustSignNewTx(key, *signer, types.NewTransaction(nonce, to, uint256.NewInt(1000), 100_000, uint256.NewInt(2), nil)))
+		}
+		// a deposit of the batch, calling the counter as the transactions around it
+		txs = append(txs, &types.DepositTx{
+			SourceHash: libcommon.Hash{2, byte(nonce)},
+			From:       depositor,
+			To:         &counter,
+			Mint:       uint256.NewInt(params.Ether),
+			Value:      uint256.NewInt(1000),
+			Gas:        100_000,
+		})
+	}
+
+	serial := buildParallelMiningBlock(t, db, &config, header, deposits, txs, 0, logger)
+	require.Len(t, serial.Txs, len(deposits)+len(txs))
+	for _, vault := range []libcommon.Address{coinbase, params.OptimismBaseFeeRecipient, params.OptimismL1FeeRecipient} {
+		require.Contains(t, serial.writes, fmt.Sprintf("account %x", vault))
+		require.NotContains(t, serial.writes[fmt.Sprintf("account %x", vault)], "balance=0 ")
+	}
+	for i := 0; i < 5; i++ {
+		serial.requireEqual(t, buildParallelMiningBlock(t, db, &config, header, deposits, txs, 4, logger))
+	}
+}
+
+// parallelMiningBlock is a block built by buildParallelMiningBlock.
+type parallelMiningBlock struct {
+	*MiningBlock
+	writes    mapStateWriter
+	stateRoot libcommon.Hash
+	committed int // transactions committed from the results of the workers
+}
+
+// buildParallelMiningBlock builds a block on top of the genesis, with the deposits
+// then txs, the latter executed by the given number of workers.
+func buildParallelMiningBlock(t *testing.T, db kv.RwDB, config *chain.Config, header *types.Header, deposits, txs []types.Transaction, workers int, logger log.Logger) *parallelMiningBlock {
+	tx, err := db.BeginRw(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	current := &MiningBlock{Header: types.CopyHeader(header)}
+	ibs := state.New(state.NewPlainStateReader(tx))
+	getHeader := func(hash libcommon.Hash, number uint64) *types.Header { return rawdb.ReadHeader(tx, hash, number) }
+	if len(deposits) > 0 {
+		_, _, err = addTransactionsToMiningBlock("test", current, *config, &vm.Config{}, getHeader, nil, types.NewTransactionsFixedOrder(append([]types.Transaction(nil), deposits...)), header.Coinbase, ibs, nil, nil, 0, nil, nil, logger)
+		require.NoError(t, err)
+	}
+
+	stream := types.NewTransactionsFixedOrder(append([]types.Transaction(nil), txs...))
+	parallel, err := startParallelMiningExec(context.Background(), db, workers, config, nil, &vm.Config{}, current.Header, header.Coinbase, stream, ibs, logger)
+	require.NoError(t, err)
+	_, _, err = addTransactionsToMiningBlock("test", current, *config, &vm.Config{}, getHeader, nil, stream, header.Coinbase, ibs, nil, nil, 0, nil, parallel, logger)
+	parallel.close()
+	require.NoError(t, err)
+
+	block := &parallelMiningBlock{MiningBlock: current, writes: mapStateWriter{}}
+	if parallel != nil {
+		block.committed = parallel.committed
+	}
+	require.NoError(t, ibs.CommitBlock(config.Rules(header.Number.Uint64(), header.Time), teeStateWriter{block.writes, state.NewPlainStateWriter(tx, nil, header.Number.Uint64())}))
+	block.stateRoot, err = core.CalcHashRootForTests(tx, current.Header, false)
+	require.NoError(t, err)
+	return block
+}
+
+// requireEqual checks that the block built by the workers is the serial one.
+func (serial *parallelMiningBlock) requireEqual(t *testing.T, block *parallelMiningBlock) {
+	require.Positive(t, block.committed)
+	require.Equal(t, serial.Header.GasUsed, block.Header.GasUsed)
+	require.Equal(t, serial.Txs, block.Txs)
+	require.Equal(t, serial.Receipts, block.Receipts)
+	require.Equal(t, serial.writes, block.writes)
+	require.Equal(t, serial.stateRoot, block.stateRoot)
+}
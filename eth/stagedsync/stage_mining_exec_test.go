@@ -0,0 +1,196 @@
+package stagedsync
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack/interop"
+	types2 "github.com/erigontech/erigon-lib/types"
+
+	"github.com/erigontech/erigon/consensus/ethash"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/flashblocks"
+)
+
+func TestCheckExecutingMessages(t *testing.T) {
+	stub := interop.NewStub()
+	known := &interop.Message{Identifier: interop.Identifier{BlockNumber: 1, ChainID: *uint256.NewInt(10)}}
+	unknown := &interop.Message{Identifier: interop.Identifier{BlockNumber: 2, ChainID: *uint256.NewInt(10)}}
+	stub.Add(known, interop.CrossUnsafe)
+	checker := interop.NewChecker(stub, interop.CrossUnsafe, time.Second)
+
+	executingLog := func(msg *interop.Message) *types.Log {
+		data := make([]byte, 5*32)
+		uint256.NewInt(msg.Identifier.BlockNumber).WriteToSlice(data[32:64])
+		msg.Identifier.ChainID.WriteToSlice(data[128:160])
+		return &types.Log{Address: interop.CrossL2InboxAddr, Topics: []libcommon.Hash{interop.ExecutingMessageTopic, msg.PayloadHash}, Data: data}
+	}
+	accessListTx := func(msg *interop.Message) types.Transaction {
+		return &types.DynamicFeeTransaction{AccessList: types2.AccessList{{Address: interop.CrossL2InboxAddr, StorageKeys: msg.AccessListEntries()}}}
+	}
+	plainTx := &types.DynamicFeeTransaction{}
+
+	for name, tt := range map[string]struct {
+		txn     types.Transaction
+		logs    []*types.Log
+		checker *interop.Checker
+		invalid bool
+	}{
+		"no messages":                {txn: plainTx, checker: checker},
+		"known message in list":      {txn: accessListTx(known), checker: checker},
+		"unknown message in list":    {txn: accessListTx(unknown), checker: checker, invalid: true},
+		"known message in logs":      {txn: plainTx, logs: []*types.Log{executingLog(known)}, checker: checker},
+		"unknown message in logs":    {txn: plainTx, logs: []*types.Log{executingLog(unknown)}, checker: checker, invalid: true},
+		"interop checks not enabled": {txn: accessListTx(unknown)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := checkExecutingMessages(tt.checker, tt.txn, &types.Receipt{Logs: tt.logs}, 1)
+			if tt.invalid {
+				require.ErrorIs(t, err, interop.ErrInvalidMessage)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// testTxPool yields the transactions added to it, once.
+type testTxPool struct {
+	mu  sync.Mutex
+	txs []types.Transaction
+}
+
+func (p *testTxPool) add(txn types.Transaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.txs = append(p.txs, txn)
+}
+
+func (p *testTxPool) YieldBest(n uint16, txs *types2.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte]) (bool, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	count := 0
+	for _, txn := range p.txs {
+		if count == int(n) || toSkip.Contains(txn.Hash()) {
+			continue
+		}
+		enc, err := types.MarshalTransactionsBinary(types.Transactions{txn})
+		if err != nil {
+			return false, 0, err
+		}
+		sender, _ := txn.GetSender()
+		txs.Txs = append(txs.Txs, enc[0])
+		txs.Senders = append(txs.Senders, sender[:]...)
+		txs.IsLocal = append(txs.IsLocal, false)
+		toSkip.Add(txn.Hash())
+		count++
+	}
+	return true, count, nil
+}
+
+// The payload streamed as flashblocks keeps adding the transactions reaching
+// the txpool after its first flashblock, until it is retrieved.
+func TestMiningExecFlashblocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := log.New()
+	db := memdb.NewTestDB(t)
+	config := params.TestChainConfig
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	_, genesis, err := core.CommitGenesisBlock(db, &types.Genesis{Config: config, Alloc: types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}}, GasLimit: 30_000_000}, t.TempDir(), logger)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		return stages.SaveStageProgress(tx, stages.Execution, 0)
+	}))
+
+	publisher := flashblocks.NewPublisher(50*time.Millisecond, nil, logger)
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+	received := make(chan *flashblocks.Flashblock, 16)
+	go flashblocks.Subscribe(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil, func(fb *flashblocks.Flashblock) { received <- fb }, logger)
+	// the subscription is made before the payload is built
+	time.Sleep(500 * time.Millisecond)
+
+	signer := types.MakeSigner(config, 1, 0)
+	transfer := func(nonce uint64) types.Transaction {
+		txn := types.MustSignNewTx(key, *signer, types.NewTransaction(nonce, libcommon.HexToAddress("0xaa"), uint256.NewInt(1), params.TxGas, uint256.NewInt(params.GWei), nil))
+		txn.SetSender(sender)
+		return txn
+	}
+	pool := &testTxPool{}
+	pool.add(transfer(0))
+
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), GasLimit: 30_000_000, Time: uint64(time.Now().Add(time.Minute).Unix()), Difficulty: big.NewInt(1), BaseFee: big.NewInt(1)}
+	miningState := NewMiningState(&params.MiningConfig{Etherbase: libcommon.HexToAddress("0xc0ffee")})
+	miningState.MiningBlock.Header = header
+	var interrupt int32
+	cfg := StageMiningExecCfg(db, miningState, nil, *config, ethash.NewFaker(), &vm.Config{}, t.TempDir(), &interrupt, 1, pool, memdb.NewTestPoolDB(t), nil, nil, nil, publisher, nil)
+
+	built := make(chan error, 1)
+	go func() {
+		// the write transaction belongs to the thread which began it
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		tx, err := db.BeginRw(ctx)
+		if err != nil {
+			built <- err
+			return
+		}
+		defer tx.Rollback()
+		built <- SpawnMiningExecStage(&StageState{ID: stages.MiningExecution}, tx, cfg, ctx.Done(), logger)
+	}()
+
+	next := func() *flashblocks.Flashblock {
+		select {
+		case fb := <-received:
+			return fb
+		case err := <-built:
+			t.Fatalf("payload built before getPayload: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no flashblock")
+		}
+		return nil
+	}
+	fb := next()
+	require.Equal(t, uint64(0), fb.Index)
+	require.Len(t, fb.Diff.Transactions, 1)
+
+	// a transaction reaching the txpool after the first flashblock
+	pool.add(transfer(1))
+	fb = next()
+	require.Equal(t, uint64(1), fb.Index)
+	require.Len(t, fb.Diff.Transactions, 1)
+	require.Equal(t, 2*params.TxGas, uint64(fb.Diff.GasUsed))
+
+	// getPayload
+	atomic.StoreInt32(&interrupt, 1)
+	select {
+	case err := <-built:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("payload not built after getPayload")
+	}
+	require.Len(t, miningState.MiningBlock.Txs, 2)
+	require.Equal(t, 2*params.TxGas, miningState.MiningBlock.Header.GasUsed)
+}
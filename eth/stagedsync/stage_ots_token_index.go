@@ -0,0 +1,382 @@
+package stagedsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/etl"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/ethdb/cbor"
+)
+
+// TokenStandard is the standard of a token, as told by its transfer events.
+type TokenStandard byte
+
+const (
+	ERC20 TokenStandard = iota + 1
+	ERC721
+	ERC1155
+)
+
+func (s TokenStandard) String() string {
+	switch s {
+	case ERC20:
+		return "ERC20"
+	case ERC721:
+		return "ERC721"
+	case ERC1155:
+		return "ERC1155"
+	default:
+		return fmt.Sprintf("TokenStandard(%d)", byte(s))
+	}
+}
+
+var (
+	// TransferEventTopic is the topic of Transfer(address indexed from, address indexed to, uint256 value) of
+	// ERC-20, and of Transfer(address indexed from, address indexed to, uint256 indexed tokenId) of ERC-721
+	TransferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	// TransferSingleEventTopic and TransferBatchEventTopic are the topics of the transfer events of ERC-1155
+	TransferSingleEventTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	TransferBatchEventTopic  = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+)
+
+// TokenTransferOf returns the standard, the sender and the recipient of a token transfer log, ok false if it is
+// not one. The ERC-20 and ERC-721 transfers share their event, and are told apart by the indexed token id of the
+// ERC-721 ones.
+func TokenTransferOf(l *types.Log) (standard TokenStandard, from, to libcommon.Address, ok bool) {
+	if len(l.Topics) == 0 {
+		return 0, from, to, false
+	}
+	var fromTopic, toTopic libcommon.Hash
+	switch l.Topics[0] {
+	case TransferEventTopic:
+		switch {
+		case len(l.Topics) == 3 && len(l.Data) == 32:
+			standard = ERC20
+		case len(l.Topics) == 4 && len(l.Data) == 0:
+			standard = ERC721
+		default:
+			return 0, from, to, false
+		}
+		fromTopic, toTopic = l.Topics[1], l.Topics[2]
+	case TransferSingleEventTopic, TransferBatchEventTopic:
+		if len(l.Topics) != 4 {
+			return 0, from, to, false
+		}
+		standard, fromTopic, toTopic = ERC1155, l.Topics[2], l.Topics[3]
+	default:
+		return 0, from, to, false
+	}
+	if !isAddressTopic(fromTopic) || !isAddressTopic(toTopic) {
+		return 0, from, to, false
+	}
+	return standard, libcommon.BytesToAddress(fromTopic[12:]), libcommon.BytesToAddress(toTopic[12:]), true
+}
+
+func isAddressTopic(topic libcommon.Hash) bool {
+	for _, b := range topic[:12] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// OtsTransfersTable returns the table of the transfers of the holders of the tokens of the given standard.
+func OtsTransfersTable(standard TokenStandard) string {
+	switch standard {
+	case ERC20:
+		return kv.OtsERC20Transfers
+	case ERC721:
+		return kv.OtsERC721Transfers
+	default:
+		return kv.OtsERC1155Transfers
+	}
+}
+
+// OtsLogPosition returns block_num_u64 + tx_index_u32 + log_index_u32, the position of a log in the token indices.
+func OtsLogPosition(blockNum uint64, txIndex, logIndex uint32) []byte {
+	pos := make([]byte, 16)
+	binary.BigEndian.PutUint64(pos, blockNum)
+	binary.BigEndian.PutUint32(pos[8:], txIndex)
+	binary.BigEndian.PutUint32(pos[12:], logIndex)
+	return pos
+}
+
+type OtsTokenIndexCfg struct {
+	db      kv.RwDB
+	enabled bool
+	tmpdir  string
+}
+
+func StageOtsTokenIndexCfg(db kv.RwDB, enabled bool, tmpDir string) OtsTokenIndexCfg {
+	return OtsTokenIndexCfg{
+		db:      db,
+		enabled: enabled,
+		tmpdir:  tmpDir,
+	}
+}
+
+func SpawnOtsTokenIndex(s *StageState, tx kv.RwTx, cfg OtsTokenIndexCfg, ctx context.Context, logger log.Logger) error {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		var err error
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	endBlock, err := s.ExecutionAt(tx)
+	if err != nil {
+		return fmt.Errorf("getting last executed block: %w", err)
+	}
+	if endBlock <= s.BlockNumber {
+		return nil
+	}
+	startBlock := s.BlockNumber
+	if startBlock > 0 {
+		startBlock++
+	}
+	if err = promoteOtsTokenIndex(s.LogPrefix(), tx, startBlock, endBlock, cfg, ctx, logger); err != nil {
+		return err
+	}
+	if err = s.Update(tx, endBlock); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTokenTransfers calls walker for the token transfer logs of the blocks from start to end, in order, with
+// their position, their token and their holders: the sender and the recipient, but the zero address.
+func walkTokenTransfers(logPrefix string, tx kv.Tx, start, end uint64, ctx context.Context, logger log.Logger,
+	walker func(blockNum uint64, pos []byte, token libcommon.Address, standard TokenStandard, holders []libcommon.Address) error,
+) error {
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+
+	logs, err := tx.Cursor(kv.Log)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	reader := bytes.NewReader(nil)
+	var currentBlock uint64
+	var logIndex uint32 // in block
+	holders := make([]libcommon.Address, 0, 2)
+	for k, v, err := logs.Seek(hexutility.EncodeTs(start)); k != nil; k, v, err = logs.Next() {
+		if err != nil {
+			return err
+		}
+		blockNum, txIndex := binary.BigEndian.Uint64(k), binary.BigEndian.Uint32(k[8:])
+		if blockNum > end {
+			break
+		}
+		if blockNum != currentBlock {
+			currentBlock, logIndex = blockNum, 0
+		}
+
+		select {
+		default:
+		case <-ctx.Done():
+			return libcommon.ErrStopped
+		case <-logEvery.C:
+			logger.Info(fmt.Sprintf("[%s] Progress", logPrefix), "number", blockNum)
+		}
+
+		var ll types.Logs
+		reader.Reset(v)
+		if err := cbor.Unmarshal(&ll, reader); err != nil {
+			return fmt.Errorf("receipt unmarshal failed: %w, block=%d", err, blockNum)
+		}
+		for _, l := range ll {
+			if standard, from, to, ok := TokenTransferOf(l); ok {
+				holders = holders[:0]
+				if from != (libcommon.Address{}) {
+					holders = append(holders, from)
+				}
+				if to != (libcommon.Address{}) && to != from {
+					holders = append(holders, to)
+				}
+				if err := walker(blockNum, OtsLogPosition(blockNum, txIndex, logIndex), l.Address, standard, holders); err != nil {
+					return err
+				}
+			}
+			logIndex++
+		}
+	}
+	return nil
+}
+
+func promoteOtsTokenIndex(logPrefix string, tx kv.RwTx, start, end uint64, cfg OtsTokenIndexCfg, ctx context.Context, logger log.Logger) error {
+	if end-start > 100 {
+		logger.Info(fmt.Sprintf("[%s] processing", logPrefix), "from", start, "to", end)
+	}
+
+	// the keys of the collectors are the keys and the values of the DupSort-ed tables
+	transfers := map[TokenStandard]*etl.Collector{}
+	for _, standard := range []TokenStandard{ERC20, ERC721, ERC1155} {
+		transfers[standard] = etl.NewCollector(logPrefix, cfg.tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize), logger)
+		defer transfers[standard].Close()
+	}
+	tokenTransfers := etl.NewCollector(logPrefix, cfg.tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize), logger)
+	defer tokenTransfers.Close()
+	// holder + token + standard -> first block
+	holdings := etl.NewCollector(logPrefix, cfg.tmpdir, etl.NewOldestEntryBuffer(etl.BufferOptimalSize), logger)
+	defer holdings.Close()
+
+	if err := walkTokenTransfers(logPrefix, tx, start, end, ctx, logger, func(blockNum uint64, pos []byte, token libcommon.Address, standard TokenStandard, holders []libcommon.Address) error {
+		if err := tokenTransfers.Collect(append(token.Bytes(), pos...), nil); err != nil {
+			return err
+		}
+		for _, holder := range holders {
+			key := append(append(holder.Bytes(), pos...), token.Bytes()...)
+			if err := transfers[standard].Collect(key, nil); err != nil {
+				return err
+			}
+			key = append(append(holder.Bytes(), token.Bytes()...), byte(standard))
+			if err := holdings.Collect(key, hexutility.EncodeTs(blockNum)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	splitLoad := func(k, _ []byte, _ etl.CurrentTableReader, next etl.LoadNextFunc) error {
+		return next(k, k[:length20], k[length20:])
+	}
+	args := etl.TransformArgs{Quit: ctx.Done()}
+	for standard, collector := range transfers {
+		if err := collector.Load(tx, OtsTransfersTable(standard), splitLoad, args); err != nil {
+			return err
+		}
+	}
+	if err := tokenTransfers.Load(tx, kv.OtsTokenTransfers, splitLoad, args); err != nil {
+		return err
+	}
+
+	// the holdings already known keep their first block
+	known, err := tx.CursorDupSort(kv.OtsHoldings)
+	if err != nil {
+		return err
+	}
+	defer known.Close()
+	return holdings.Load(tx, kv.OtsHoldings, func(k, v []byte, _ etl.CurrentTableReader, next etl.LoadNextFunc) error {
+		holder, tokenAndStandard := k[:length20], k[length20:]
+		existing, err := known.SeekBothRange(holder, tokenAndStandard)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(existing, tokenAndStandard) {
+			return nil
+		}
+		return next(k, holder, append(libcommon.Copy(tokenAndStandard), v...))
+	}, args)
+}
+
+const length20 = 20 // length of the addresses of the holders and the tokens
+
+func UnwindOtsTokenIndex(u *UnwindState, s *StageState, tx kv.RwTx, cfg OtsTokenIndexCfg, ctx context.Context, logger log.Logger) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if err := unwindOtsTokenIndex(s.LogPrefix(), tx, u.UnwindPoint, ctx, logger); err != nil {
+		return err
+	}
+	if err := u.Done(tx); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unwindOtsTokenIndex removes the transfers after the block to from the indices, and the holdings first seen
+// after it.
+func unwindOtsTokenIndex(logPrefix string, tx kv.RwTx, to uint64, ctx context.Context, logger log.Logger) error {
+	transfers := map[TokenStandard]kv.RwCursorDupSort{}
+	for _, standard := range []TokenStandard{ERC20, ERC721, ERC1155} {
+		c, err := tx.RwCursorDupSort(OtsTransfersTable(standard))
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		transfers[standard] = c
+	}
+	tokenTransfers, err := tx.RwCursorDupSort(kv.OtsTokenTransfers)
+	if err != nil {
+		return err
+	}
+	defer tokenTransfers.Close()
+
+	holdings := map[string]struct{}{} // holder + token + standard
+	if err := walkTokenTransfers(logPrefix, tx, to+1, math.MaxUint64, ctx, logger, func(blockNum uint64, pos []byte, token libcommon.Address, standard TokenStandard, holders []libcommon.Address) error {
+		if err := tokenTransfers.DeleteExact(token.Bytes(), pos); err != nil {
+			return err
+		}
+		for _, holder := range holders {
+			if err := transfers[standard].DeleteExact(holder.Bytes(), append(libcommon.Copy(pos), token.Bytes()...)); err != nil {
+				return err
+			}
+			holdings[string(append(append(holder.Bytes(), token.Bytes()...), byte(standard)))] = struct{}{}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	known, err := tx.RwCursorDupSort(kv.OtsHoldings)
+	if err != nil {
+		return err
+	}
+	defer known.Close()
+	for k := range holdings {
+		holder, tokenAndStandard := []byte(k[:length20]), []byte(k[length20:])
+		existing, err := known.SeekBothRange(holder, tokenAndStandard)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(existing, tokenAndStandard) && binary.BigEndian.Uint64(existing[len(tokenAndStandard):]) > to {
+			if err := known.DeleteExact(holder, existing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PruneOtsTokenIndex keeps the token indices whole, so that the transfer counts and the holdings stay exact when
+// the receipts they are built from are pruned. Only the transfers of the kept receipts can be listed though.
+func PruneOtsTokenIndex(p *PruneState, tx kv.RwTx, cfg OtsTokenIndexCfg, ctx context.Context) (err error) {
+	return nil
+}
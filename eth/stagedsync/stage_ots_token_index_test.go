@@ -0,0 +1,163 @@
+package stagedsync
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testERC20, testERC721, testERC1155 = libcommon.Address{0x20}, libcommon.Address{0x21}, libcommon.Address{0x11}
+	testHolderA, testHolderB           = libcommon.Address{0xa}, libcommon.Address{0xb}
+)
+
+func addressTopic(addr libcommon.Address) libcommon.Hash {
+	return libcommon.BytesToHash(addr.Bytes())
+}
+
+// genTokenTransfers writes the receipts of blocks with, in each: an unrelated log, an ERC-20 transfer from A to
+// B, and the mint of an ERC-721 token to A. Every other block also has an ERC-1155 transfer from A to itself.
+func genTokenTransfers(t *testing.T, tx kv.RwTx, blocks uint64) {
+	for i := uint64(1); i <= blocks; i++ {
+		receipts := types.Receipts{{
+			Logs: []*types.Log{
+				{Address: testERC20, Topics: []libcommon.Hash{{1}}},
+				{Address: testERC20, Topics: []libcommon.Hash{TransferEventTopic, addressTopic(testHolderA), addressTopic(testHolderB)}, Data: make([]byte, 32)},
+			},
+		}, {}, {
+			Logs: []*types.Log{
+				{Address: testERC721, Topics: []libcommon.Hash{TransferEventTopic, {}, addressTopic(testHolderA), libcommon.BigToHash(libcommon.Big1)}},
+			},
+		}}
+		if i%2 == 0 {
+			receipts[1].Logs = []*types.Log{
+				{Address: testERC1155, Topics: []libcommon.Hash{TransferSingleEventTopic, addressTopic(testHolderB), addressTopic(testHolderA), addressTopic(testHolderA)}, Data: make([]byte, 64)},
+			}
+		}
+		require.NoError(t, rawdb.AppendReceipts(tx, i, receipts))
+	}
+}
+
+func dumpTable(t *testing.T, tx kv.Tx, table string) []string {
+	var entries []string
+	require.NoError(t, tx.ForEach(table, nil, func(k, v []byte) error {
+		entries = append(entries, string(k)+string(v))
+		return nil
+	}))
+	return entries
+}
+
+func countDuplicates(t *testing.T, tx kv.Tx, table string, key libcommon.Address) uint64 {
+	c, err := tx.CursorDupSort(table)
+	require.NoError(t, err)
+	defer c.Close()
+	k, _, err := c.SeekExact(key.Bytes())
+	require.NoError(t, err)
+	if k == nil {
+		return 0
+	}
+	count, err := c.CountDuplicates()
+	require.NoError(t, err)
+	return count
+}
+
+func TestTokenTransferOf(t *testing.T) {
+	from, to := addressTopic(testHolderA), addressTopic(testHolderB)
+	for _, tt := range []struct {
+		name     string
+		log      *types.Log
+		standard TokenStandard
+	}{
+		{"erc20", &types.Log{Topics: []libcommon.Hash{TransferEventTopic, from, to}, Data: make([]byte, 32)}, ERC20},
+		{"erc721", &types.Log{Topics: []libcommon.Hash{TransferEventTopic, from, to, {1}}}, ERC721},
+		{"erc1155 single", &types.Log{Topics: []libcommon.Hash{TransferSingleEventTopic, {}, from, to}, Data: make([]byte, 64)}, ERC1155},
+		{"erc1155 batch", &types.Log{Topics: []libcommon.Hash{TransferBatchEventTopic, {}, from, to}, Data: make([]byte, 128)}, ERC1155},
+		{"erc20 without value", &types.Log{Topics: []libcommon.Hash{TransferEventTopic, from, to}}, 0},
+		{"not an address", &types.Log{Topics: []libcommon.Hash{TransferEventTopic, from, {1}}, Data: make([]byte, 32)}, 0},
+		{"other event", &types.Log{Topics: []libcommon.Hash{{1}, from, to}, Data: make([]byte, 32)}, 0},
+		{"anonymous", &types.Log{}, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			standard, gotFrom, gotTo, ok := TokenTransferOf(tt.log)
+			require.Equal(t, tt.standard != 0, ok)
+			if ok {
+				require.Equal(t, tt.standard, standard)
+				require.Equal(t, testHolderA, gotFrom)
+				require.Equal(t, testHolderB, gotTo)
+			}
+		})
+	}
+}
+
+func TestPromoteOtsTokenIndex(t *testing.T) {
+	logger := log.New()
+	require, ctx := require.New(t), context.Background()
+	_, tx := memdb.NewTestTx(t)
+	genTokenTransfers(t, tx, 100)
+
+	cfg := StageOtsTokenIndexCfg(nil, true, t.TempDir())
+	require.NoError(promoteOtsTokenIndex("logPrefix", tx, 0, 49, cfg, ctx, logger))
+	require.NoError(promoteOtsTokenIndex("logPrefix", tx, 50, 100, cfg, ctx, logger))
+
+	require.Equal(uint64(100), countDuplicates(t, tx, kv.OtsERC20Transfers, testHolderA))
+	require.Equal(uint64(100), countDuplicates(t, tx, kv.OtsERC20Transfers, testHolderB))
+	require.Equal(uint64(100), countDuplicates(t, tx, kv.OtsERC721Transfers, testHolderA))
+	require.Equal(uint64(0), countDuplicates(t, tx, kv.OtsERC721Transfers, libcommon.Address{}))
+	require.Equal(uint64(50), countDuplicates(t, tx, kv.OtsERC1155Transfers, testHolderA))
+	require.Equal(uint64(0), countDuplicates(t, tx, kv.OtsERC1155Transfers, testHolderB))
+	require.Equal(uint64(100), countDuplicates(t, tx, kv.OtsTokenTransfers, testERC20))
+	require.Equal(uint64(50), countDuplicates(t, tx, kv.OtsTokenTransfers, testERC1155))
+
+	// the positions count the logs of the block
+	c, err := tx.CursorDupSort(kv.OtsERC721Transfers)
+	require.NoError(err)
+	defer c.Close()
+	_, v, err := c.SeekExact(testHolderA.Bytes())
+	require.NoError(err)
+	require.Equal(append(OtsLogPosition(1, 2, 2), testERC721.Bytes()...), v)
+	v, err = c.LastDup()
+	require.NoError(err)
+	require.Equal(append(OtsLogPosition(100, 2, 3), testERC721.Bytes()...), v)
+
+	// the holdings keep their first block
+	holdings := dumpTable(t, tx, kv.OtsHoldings)
+	require.Len(holdings, 4)
+	holding := func(holder, token libcommon.Address, standard TokenStandard, firstBlock uint64) string {
+		v := append(append(holder.Bytes(), token.Bytes()...), byte(standard))
+		return string(binary.BigEndian.AppendUint64(v, firstBlock))
+	}
+	require.Contains(holdings, holding(testHolderA, testERC20, ERC20, 1))
+	require.Contains(holdings, holding(testHolderB, testERC20, ERC20, 1))
+	require.Contains(holdings, holding(testHolderA, testERC721, ERC721, 1))
+	require.Contains(holdings, holding(testHolderA, testERC1155, ERC1155, 2))
+}
+
+func TestUnwindOtsTokenIndex(t *testing.T) {
+	logger := log.New()
+	require, ctx := require.New(t), context.Background()
+	_, tx := memdb.NewTestTx(t)
+	genTokenTransfers(t, tx, 100)
+	cfg := StageOtsTokenIndexCfg(nil, true, t.TempDir())
+
+	for _, unwindPoint := range []uint64{70, 1} {
+		require.NoError(promoteOtsTokenIndex("logPrefix", tx, 0, 100, cfg, ctx, logger))
+		require.NoError(unwindOtsTokenIndex("logPrefix", tx, unwindPoint, ctx, logger))
+
+		_, expectedTx := memdb.NewTestTx(t)
+		genTokenTransfers(t, expectedTx, unwindPoint)
+		require.NoError(promoteOtsTokenIndex("logPrefix", expectedTx, 0, unwindPoint, cfg, ctx, logger))
+		for _, table := range []string{kv.OtsERC20Transfers, kv.OtsERC721Transfers, kv.OtsERC1155Transfers, kv.OtsTokenTransfers, kv.OtsHoldings} {
+			require.Equal(dumpTable(t, expectedTx, table), dumpTable(t, tx, table), table)
+		}
+	}
+}
@@ -76,8 +76,10 @@ func InitHarness(ctx context.Context, t *testing.T, cfg HarnessCfg) Harness {
 		stagedsync.TrieCfg{},
 		stagedsync.HistoryCfg{},
 		stagedsync.LogIndexCfg{},
+		stagedsync.OtsTokenIndexCfg{},
 		stagedsync.CallTracesCfg{},
 		stagedsync.TxLookupCfg{},
+		stagedsync.DepositLookupCfg{},
 		stagedsync.FinishCfg{},
 		true,
 	)
@@ -44,8 +44,10 @@ var (
 	AccountHistoryIndex SyncStage = "AccountHistoryIndex" // Generating history index for accounts
 	StorageHistoryIndex SyncStage = "StorageHistoryIndex" // Generating history index for storage
 	LogIndex            SyncStage = "LogIndex"            // Generating logs index (from receipts)
+	OtsTokenIndex       SyncStage = "OtsTokenIndex"       // Generating token transfers and holdings indices (from receipts), optional
 	CallTraces          SyncStage = "CallTraces"          // Generating call traces index
 	TxLookup            SyncStage = "TxLookup"            // Generating transactions lookup index
+	DepositLookup       SyncStage = "DepositLookup"       // Generating deposits lookup index by source hash, optional
 	Finish              SyncStage = "Finish"              // Nominal stage after all other stages
 
 	MiningCreateBlock SyncStage = "MiningCreateBlock"
@@ -74,8 +76,10 @@ var AllStages = []SyncStage{
 	AccountHistoryIndex,
 	StorageHistoryIndex,
 	LogIndex,
+	OtsTokenIndex,
 	CallTraces,
 	TxLookup,
+	DepositLookup,
 	Finish,
 }
 
@@ -31,7 +31,8 @@ import (
 // 3.1.0 - add Subscribe to logs
 // 3.2.0 - add EngineGetBlobsBundleV1
 // 3.3.0 - merge EngineGetBlobsBundleV1 into EngineGetPayload
-var EthBackendAPIVersion = &types2.VersionReply{Major: 3, Minor: 3, Patch: 0}
+// 3.4.0 - add SAFE_HEADER and FINALIZED_HEADER events to Subscribe
+var EthBackendAPIVersion = &types2.VersionReply{Major: 3, Minor: 4, Patch: 0}
 
 type EthBackendServer struct {
 	remote.UnimplementedETHBACKENDServer // must be embedded to have forward compatible implementations.
@@ -153,6 +154,8 @@ func (s *EthBackendServer) Subscribe(r *remote.SubscribeRequest, subscribeServer
 	defer clean()
 	newSnCh, newSnClean := s.events.AddNewSnapshotSubscription()
 	defer newSnClean()
+	forkchoiceCh, forkchoiceClean := s.events.AddForkchoiceSubscription()
+	defer forkchoiceClean()
 	s.logger.Info("new subscription to newHeaders established")
 	defer func() {
 		if err != nil {
@@ -183,6 +186,10 @@ func (s *EthBackendServer) Subscribe(r *remote.SubscribeRequest, subscribeServer
 			if err = subscribeServer.Send(&remote.SubscribeReply{Type: remote.Event_NEW_SNAPSHOT}); err != nil {
 				return err
 			}
+		case reply := <-forkchoiceCh:
+			if err = subscribeServer.Send(reply); err != nil {
+				return err
+			}
 		}
 	}
 }
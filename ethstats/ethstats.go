@@ -33,6 +33,7 @@ import (
 
 	"github.com/erigontech/erigon-lib/gointerfaces/txpool"
 
+	"github.com/erigontech/erigon-lib/chain"
 	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/log/v3"
@@ -56,10 +57,11 @@ const (
 // Service implements an Ethereum netstats reporting daemon that pushes local
 // chain statistics up to a monitoring server.
 type Service struct {
-	servers   []*sentry.GrpcServer // Peer-to-peer server to retrieve networking infos
-	chaindb   kv.RoDB
-	networkid uint64
-	engine    consensus.Engine // Consensus engine to retrieve variadic block fields
+	servers     []*sentry.GrpcServer // Peer-to-peer server to retrieve networking infos
+	chaindb     kv.RoDB
+	networkid   uint64
+	chainConfig *chain.Config
+	engine      consensus.Engine // Consensus engine to retrieve variadic block fields
 
 	node string // Name of the node to display on the monitoring page
 	pass string // Password to authorize access to the monitoring page
@@ -125,7 +127,7 @@ func (w *connWrapper) Close() error {
 }
 
 // New returns a monitoring service ready for stats reporting.
-func New(node *node.Node, servers []*sentry.GrpcServer, chainDB kv.RoDB, blockReader services.FullBlockReader,
+func New(node *node.Node, servers []*sentry.GrpcServer, chainDB kv.RoDB, blockReader services.FullBlockReader, chainConfig *chain.Config,
 	engine consensus.Engine, url string, networkid uint64, quitCh <-chan struct{}, headCh chan [][]byte, txPoolRpcClient txpool.TxpoolClient) error {
 	// Parse the netstats connection url
 	re := regexp.MustCompile("([^:@]*)(:([^@]*))?@(.+)")
@@ -143,6 +145,7 @@ func New(node *node.Node, servers []*sentry.GrpcServer, chainDB kv.RoDB, blockRe
 		pongCh:      make(chan struct{}),
 		histCh:      make(chan []uint64, 1),
 		networkid:   networkid,
+		chainConfig: chainConfig,
 		chaindb:     chainDB,
 		headCh:      headCh,
 		quitCh:      quitCh,
@@ -240,6 +243,11 @@ func (s *Service) loop() {
 					if err = s.reportBlock(conn); err != nil {
 						log.Warn("Block stats report failed", "err", err)
 					}
+					if err == nil && s.chainConfig.IsOptimism() {
+						if err = s.reportOptimism(conn); err != nil {
+							log.Warn("OP stats report failed", "err", err)
+						}
+					}
 
 				}
 			}
@@ -435,6 +443,11 @@ func (s *Service) report(conn *connWrapper) error {
 	if err := s.reportStats(conn); err != nil {
 		return err
 	}
+	if s.chainConfig.IsOptimism() {
+		if err := s.reportOptimism(conn); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -490,6 +503,7 @@ type blockStats struct {
 	TxHash     libcommon.Hash    `json:"transactionsRoot"`
 	Root       libcommon.Hash    `json:"stateRoot"`
 	Uncles     uncleStats        `json:"uncles"`
+	Optimism   *opBlockStats     `json:"optimism,omitempty"`
 }
 
 // txStats is the information to report about individual transactions.
@@ -556,6 +570,10 @@ func (s *Service) assembleBlockStats(block *types.Block, td *big.Int) *blockStat
 	for _, tx := range block.Transactions() {
 		txs = append(txs, txStats{tx.Hash()})
 	}
+	var optimism *opBlockStats
+	if s.chainConfig.IsOptimism() {
+		optimism = s.assembleOpBlockStats(block)
+	}
 
 	return &blockStats{
 		Number:     block.Header().Number,
@@ -571,6 +589,7 @@ func (s *Service) assembleBlockStats(block *types.Block, td *big.Int) *blockStat
 		TxHash:     block.Header().TxHash,
 		Root:       block.Header().Root,
 		Uncles:     block.Uncles(),
+		Optimism:   optimism,
 	}
 }
 
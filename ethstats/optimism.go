@@ -0,0 +1,125 @@
+package ethstats
+
+import (
+	"context"
+	"math/big"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+)
+
+// headStats identifies a block of the chain.
+type headStats struct {
+	Number uint64         `json:"number"`
+	Hash   libcommon.Hash `json:"hash"`
+}
+
+// opBlockStats is the OP Stack information to report about individual blocks,
+// taken from the L1 attributes deposit which opens the block.
+type opBlockStats struct {
+	L1Origin       headStats `json:"l1Origin"`
+	SequenceNumber uint64    `json:"sequenceNumber"`
+	SequencerDrift int64     `json:"sequencerDrift"` // seconds between the L1 origin and the block
+	L1BaseFee      *big.Int  `json:"l1BaseFee"`
+	L1BlobBaseFee  *big.Int  `json:"l1BlobBaseFee,omitempty"`
+	L1Fee          *big.Int  `json:"l1Fee"` // data availability fees paid by the block transactions
+	Deposits       int       `json:"deposits"`
+}
+
+// opHeadsStats is the OP Stack view of the chain heads.
+type opHeadsStats struct {
+	Unsafe    headStats     `json:"unsafe"`
+	Safe      headStats     `json:"safe"`
+	Finalized headStats     `json:"finalized"`
+	Head      *opBlockStats `json:"head,omitempty"`
+}
+
+// assembleOpBlockStats returns the OP Stack stats of block, or nil when it
+// carries no L1 attributes, e.g. the pre-Bedrock blocks.
+func (s *Service) assembleOpBlockStats(block *types.Block) *opBlockStats {
+	txs := block.Transactions()
+	if len(txs) == 0 || txs[0].Type() != types.DepositTxType || !s.chainConfig.IsOptimismBedrock(block.NumberU64()) {
+		return nil
+	}
+	info, err := opstack.ParseL1BlockInfo(txs[0].GetData())
+	if err != nil {
+		log.Debug("Invalid L1 attributes deposit", "number", block.NumberU64(), "err", err)
+		return nil
+	}
+	stats := &opBlockStats{
+		L1Origin:       headStats{Number: info.Number, Hash: info.Hash},
+		SequenceNumber: info.SequenceNumber,
+		SequencerDrift: int64(block.Time()) - int64(info.Time),
+		L1BaseFee:      info.BaseFee.ToBig(),
+		L1Fee:          new(big.Int),
+	}
+	if info.BlobBaseFee != nil {
+		stats.L1BlobBaseFee = info.BlobBaseFee.ToBig()
+	}
+	// the L1 fee is derived from the attributes as for the receipts, see Receipts.DeriveFields
+	gasParams, err := opstack.ExtractL1GasParams(s.chainConfig, block.Time(), txs[0].GetData())
+	if err != nil {
+		log.Debug("Invalid L1 gas params", "number", block.NumberU64(), "err", err)
+	}
+	for _, txn := range txs {
+		if txn.Type() == types.DepositTxType {
+			stats.Deposits++
+			continue
+		}
+		if err == nil {
+			if fee, _ := gasParams.CostFunc(txn.RollupCostData()); fee != nil {
+				stats.L1Fee.Add(stats.L1Fee, fee.ToBig())
+			}
+		}
+	}
+	return stats
+}
+
+// reportOptimism sends the unsafe, safe and finalized heads, as written by the
+// forkchoice updates of the execution module, and the L1 origin of the unsafe head.
+func (s *Service) reportOptimism(conn *connWrapper) error {
+	roTx, err := s.chaindb.BeginRo(context.Background())
+	if err != nil {
+		return err
+	}
+	defer roTx.Rollback()
+
+	heads := &opHeadsStats{
+		Safe:      readHeadStats(roTx, rawdb.ReadForkchoiceSafe(roTx)),
+		Finalized: readHeadStats(roTx, rawdb.ReadForkchoiceFinalized(roTx)),
+	}
+	block, err := s.blockReader.CurrentBlock(roTx)
+	if err != nil {
+		return err
+	}
+	if block != nil {
+		heads.Unsafe = headStats{Number: block.NumberU64(), Hash: block.Hash()}
+		heads.Head = s.assembleOpBlockStats(block)
+	}
+	log.Trace("Sending OP heads to ethstats", "unsafe", heads.Unsafe.Number, "safe", heads.Safe.Number, "finalized", heads.Finalized.Number)
+
+	stats := map[string]interface{}{
+		"id":       s.node,
+		"optimism": heads,
+	}
+	report := map[string][]interface{}{
+		"emit": {"optimism", stats},
+	}
+	return conn.WriteJSON(report)
+}
+
+func readHeadStats(tx kv.Getter, hash libcommon.Hash) headStats {
+	if hash == (libcommon.Hash{}) {
+		return headStats{}
+	}
+	number := rawdb.ReadHeaderNumber(tx, hash)
+	if number == nil {
+		return headStats{Hash: hash}
+	}
+	return headStats{Number: *number, Hash: hash}
+}
@@ -3,6 +3,7 @@ package sentry
 import (
 	"fmt"
 
+	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/gointerfaces"
 	proto_sentry "github.com/erigontech/erigon-lib/gointerfaces/sentry"
 	"github.com/erigontech/erigon/core/forkid"
@@ -21,35 +22,61 @@ func readAndValidatePeerStatusMessage(
 		return nil, p2p.NewPeerError(p2p.PeerErrorStatusReceive, p2p.DiscNetworkError, err, "readAndValidatePeerStatusMessage rw.ReadMsg error")
 	}
 
-	reply, err := tryDecodeStatusMessage(&msg)
+	var reply eth.StatusPacket
+	err = tryDecodeStatusMessage(&msg, &reply)
 	msg.Discard()
 	if err != nil {
 		return nil, p2p.NewPeerError(p2p.PeerErrorStatusDecode, p2p.DiscProtocolError, err, "readAndValidatePeerStatusMessage tryDecodeStatusMessage error")
 	}
 
-	err = checkPeerStatusCompatibility(reply, status, version, minVersion)
+	err = checkPeerStatusCompatibility(&reply, status, version, minVersion)
 	if err != nil {
 		return nil, p2p.NewPeerError(p2p.PeerErrorStatusIncompatible, p2p.DiscUselessPeer, err, "readAndValidatePeerStatusMessage checkPeerStatusCompatibility error")
 	}
 
-	return reply, nil
+	return &reply, nil
 }
 
-func tryDecodeStatusMessage(msg *p2p.Msg) (*eth.StatusPacket, error) {
+func readAndValidatePeerStatus69Message(
+	rw p2p.MsgReadWriter,
+	status *proto_sentry.StatusData,
+	version uint,
+	minVersion uint,
+) (*eth.StatusPacket69, *p2p.PeerError) {
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		return nil, p2p.NewPeerError(p2p.PeerErrorStatusReceive, p2p.DiscNetworkError, err, "readAndValidatePeerStatus69Message rw.ReadMsg error")
+	}
+
+	var reply eth.StatusPacket69
+	err = tryDecodeStatusMessage(&msg, &reply)
+	msg.Discard()
+	if err != nil {
+		return nil, p2p.NewPeerError(p2p.PeerErrorStatusDecode, p2p.DiscProtocolError, err, "readAndValidatePeerStatus69Message tryDecodeStatusMessage error")
+	}
+
+	err = checkPeerStatus69Compatibility(&reply, status, version, minVersion)
+	if err != nil {
+		return nil, p2p.NewPeerError(p2p.PeerErrorStatusIncompatible, p2p.DiscUselessPeer, err, "readAndValidatePeerStatus69Message checkPeerStatus69Compatibility error")
+	}
+
+	return &reply, nil
+}
+
+func tryDecodeStatusMessage(msg *p2p.Msg, reply interface{}) error {
 	if msg.Code != eth.StatusMsg {
-		return nil, fmt.Errorf("first msg has code %x (!= %x)", msg.Code, eth.StatusMsg)
+		return fmt.Errorf("first msg has code %x (!= %x)", msg.Code, eth.StatusMsg)
 	}
 
 	if msg.Size > eth.ProtocolMaxMsgSize {
-		return nil, fmt.Errorf("message is too large %d, limit %d", msg.Size, eth.ProtocolMaxMsgSize)
+		return fmt.Errorf("message is too large %d, limit %d", msg.Size, eth.ProtocolMaxMsgSize)
 	}
 
-	var reply eth.StatusPacket
-	if err := msg.Decode(&reply); err != nil {
-		return nil, fmt.Errorf("decode message %v: %w", msg, err)
+	if err := msg.Decode(reply); err != nil {
+		return fmt.Errorf("decode message %v: %w", msg, err)
 	}
 
-	return &reply, nil
+	return nil
 }
 
 func checkPeerStatusCompatibility(
@@ -57,24 +84,48 @@ func checkPeerStatusCompatibility(
 	status *proto_sentry.StatusData,
 	version uint,
 	minVersion uint,
+) error {
+	return checkStatusCompatibility(reply.ProtocolVersion, reply.NetworkID, reply.Genesis, reply.ForkID, status, version, minVersion)
+}
+
+func checkPeerStatus69Compatibility(
+	reply *eth.StatusPacket69,
+	status *proto_sentry.StatusData,
+	version uint,
+	minVersion uint,
+) error {
+	if reply.EarliestBlock > reply.LatestBlock {
+		return fmt.Errorf("invalid block range: earliest %d > latest %d", reply.EarliestBlock, reply.LatestBlock)
+	}
+	return checkStatusCompatibility(reply.ProtocolVersion, reply.NetworkID, reply.Genesis, reply.ForkID, status, version, minVersion)
+}
+
+func checkStatusCompatibility(
+	protocolVersion uint32,
+	peerNetworkID uint64,
+	peerGenesisHash libcommon.Hash,
+	peerForkID forkid.ID,
+	status *proto_sentry.StatusData,
+	version uint,
+	minVersion uint,
 ) error {
 	networkID := status.NetworkId
-	if reply.NetworkID != networkID {
-		return fmt.Errorf("network id does not match: theirs %d, ours %d", reply.NetworkID, networkID)
+	if peerNetworkID != networkID {
+		return fmt.Errorf("network id does not match: theirs %d, ours %d", peerNetworkID, networkID)
 	}
 
-	if uint(reply.ProtocolVersion) > version {
-		return fmt.Errorf("version is more than what this senty supports: theirs %d, max %d", reply.ProtocolVersion, version)
+	if uint(protocolVersion) > version {
+		return fmt.Errorf("version is more than what this senty supports: theirs %d, max %d", protocolVersion, version)
 	}
-	if uint(reply.ProtocolVersion) < minVersion {
-		return fmt.Errorf("version is less than allowed minimum: theirs %d, min %d", reply.ProtocolVersion, minVersion)
+	if uint(protocolVersion) < minVersion {
+		return fmt.Errorf("version is less than allowed minimum: theirs %d, min %d", protocolVersion, minVersion)
 	}
 
 	genesisHash := gointerfaces.ConvertH256ToHash(status.ForkData.Genesis)
-	if reply.Genesis != genesisHash {
-		return fmt.Errorf("genesis hash does not match: theirs %x, ours %x", reply.Genesis, genesisHash)
+	if peerGenesisHash != genesisHash {
+		return fmt.Errorf("genesis hash does not match: theirs %x, ours %x", peerGenesisHash, genesisHash)
 	}
 
 	forkFilter := forkid.NewFilterFromForks(status.ForkData.HeightForks, status.ForkData.TimeForks, genesisHash, status.MaxBlockHeight, status.MaxBlockTime)
-	return forkFilter(reply.ForkID)
+	return forkFilter(peerForkID)
 }
@@ -81,3 +81,45 @@ func TestCheckPeerStatusCompatibility(t *testing.T) {
 		assert.ErrorIs(t, err, forkid.ErrLocalIncompatibleOrStale)
 	})
 }
+
+func TestCheckPeerStatus69Compatibility(t *testing.T) {
+	var version uint = direct.ETH69
+	networkID := params.MainnetChainConfig.ChainID.Uint64()
+	heightForks, timeForks := forkid.GatherForks(params.MainnetChainConfig, 0 /* genesisTime */)
+	goodReply := eth.StatusPacket69{
+		ProtocolVersion: uint32(version),
+		NetworkID:       networkID,
+		Genesis:         params.MainnetGenesisHash,
+		ForkID:          forkid.NewIDFromForks(heightForks, timeForks, params.MainnetGenesisHash, 0, 0),
+		EarliestBlock:   0,
+		LatestBlock:     10,
+		LatestBlockHash: libcommon.Hash{1},
+	}
+	status := proto_sentry.StatusData{
+		NetworkId: networkID,
+		ForkData: &proto_sentry.Forks{
+			Genesis:     gointerfaces.ConvertHashToH256(params.MainnetGenesisHash),
+			HeightForks: heightForks,
+			TimeForks:   timeForks,
+		},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		err := checkPeerStatus69Compatibility(&goodReply, &status, version, version)
+		assert.Nil(t, err)
+	})
+	t.Run("invalid block range", func(t *testing.T) {
+		reply := goodReply
+		reply.EarliestBlock = reply.LatestBlock + 1
+		err := checkPeerStatus69Compatibility(&reply, &status, version, version)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "block range")
+	})
+	t.Run("genesis mismatch", func(t *testing.T) {
+		reply := goodReply
+		reply.Genesis = libcommon.Hash{}
+		err := checkPeerStatus69Compatibility(&reply, &status, version, version)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "genesis")
+	})
+}
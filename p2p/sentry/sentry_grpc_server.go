@@ -38,6 +38,7 @@ import (
 	"github.com/erigontech/erigon/common/debug"
 	"github.com/erigontech/erigon/core/forkid"
 	"github.com/erigontech/erigon/eth/protocols/eth"
+	"github.com/erigontech/erigon/eth/protocols/snap"
 	"github.com/erigontech/erigon/p2p"
 	"github.com/erigontech/erigon/p2p/dnsdisc"
 	"github.com/erigontech/erigon/p2p/enode"
@@ -50,6 +51,10 @@ const (
 	// complete before dropping the connection.= as malicious.
 	handshakeTimeout  = 5 * time.Second
 	maxPermitsPerPeer = 4 // How many outstanding requests per peer we may have
+
+	// blockRangeUpdateInterval is the number of blocks after which eth/69 peers
+	// are notified about the new range of blocks we serve.
+	blockRangeUpdateInterval = 32
 )
 
 // PeerInfo collects various extra bits of information about the peer,
@@ -288,23 +293,35 @@ func handShake(
 ) (*libcommon.Hash, *p2p.PeerError) {
 	// Send out own handshake in a new thread
 	errChan := make(chan *p2p.PeerError, 2)
-	resultChan := make(chan *eth.StatusPacket, 1)
+	resultChan := make(chan libcommon.Hash, 1)
 
 	ourTD := gointerfaces.ConvertH256ToUint256Int(status.TotalDifficulty)
 	// Convert proto status data into the one required by devp2p
 	genesisHash := gointerfaces.ConvertH256ToHash(status.ForkData.Genesis)
+	forkID := forkid.NewIDFromForks(status.ForkData.HeightForks, status.ForkData.TimeForks, genesisHash, status.MaxBlockHeight, status.MaxBlockTime)
 
 	go func() {
 		defer debug.LogPanic()
-		status := &eth.StatusPacket{
+		var ourStatus eth.Packet = &eth.StatusPacket{
 			ProtocolVersion: uint32(version),
 			NetworkID:       status.NetworkId,
 			TD:              ourTD.ToBig(),
 			Head:            gointerfaces.ConvertH256ToHash(status.BestHash),
 			Genesis:         genesisHash,
-			ForkID:          forkid.NewIDFromForks(status.ForkData.HeightForks, status.ForkData.TimeForks, genesisHash, status.MaxBlockHeight, status.MaxBlockTime),
+			ForkID:          forkID,
+		}
+		if version >= direct.ETH69 {
+			ourStatus = &eth.StatusPacket69{
+				ProtocolVersion: uint32(version),
+				NetworkID:       status.NetworkId,
+				Genesis:         genesisHash,
+				ForkID:          forkID,
+				EarliestBlock:   status.MinBlockHeight,
+				LatestBlock:     status.MaxBlockHeight,
+				LatestBlockHash: gointerfaces.ConvertH256ToHash(status.BestHash),
+			}
 		}
-		err := p2p.Send(rw, eth.StatusMsg, status)
+		err := p2p.Send(rw, eth.StatusMsg, ourStatus)
 
 		if err == nil {
 			errChan <- nil
@@ -315,10 +332,21 @@ func handShake(
 
 	go func() {
 		defer debug.LogPanic()
+		if version >= direct.ETH69 {
+			status, err := readAndValidatePeerStatus69Message(rw, status, version, minVersion)
+			if err == nil {
+				resultChan <- status.LatestBlockHash
+				errChan <- nil
+			} else {
+				errChan <- err
+			}
+			return
+		}
+
 		status, err := readAndValidatePeerStatusMessage(rw, status, version, minVersion)
 
 		if err == nil {
-			resultChan <- status
+			resultChan <- status.Head
 			errChan <- nil
 		} else {
 			errChan <- err
@@ -340,8 +368,8 @@ func handShake(
 		}
 	}
 
-	peerStatus := <-resultChan
-	return &peerStatus.Head, nil
+	peerBestHash := <-resultChan
+	return &peerBestHash, nil
 }
 
 func runPeer(
@@ -472,6 +500,10 @@ func runPeer(
 			send(eth.ToProto[protocol][msg.Code], peerID, b)
 			//log.Info(fmt.Sprintf("[%s] ReceiptsMsg", peerID))
 		case eth.NewBlockHashesMsg:
+			if protocol >= direct.ETH69 {
+				msg.Discard()
+				return p2p.NewPeerError(p2p.PeerErrorMessageObsolete, p2p.DiscSubprotocolError, nil, fmt.Sprintf("unexpected NewBlockHashesMsg from %s in eth/%d", peerID, protocol))
+			}
 			if !hasSubscribers(eth.ToProto[protocol][msg.Code]) {
 				continue
 			}
@@ -482,6 +514,10 @@ func runPeer(
 			//log.Debug("NewBlockHashesMsg from", "peerId", fmt.Sprintf("%x", peerID)[:20], "name", peerInfo.peer.Name())
 			send(eth.ToProto[protocol][msg.Code], peerID, b)
 		case eth.NewBlockMsg:
+			if protocol >= direct.ETH69 {
+				msg.Discard()
+				return p2p.NewPeerError(p2p.PeerErrorMessageObsolete, p2p.DiscSubprotocolError, nil, fmt.Sprintf("unexpected NewBlockMsg from %s in eth/%d", peerID, protocol))
+			}
 			if !hasSubscribers(eth.ToProto[protocol][msg.Code]) {
 				continue
 			}
@@ -531,6 +567,28 @@ func runPeer(
 				logger.Error(fmt.Sprintf("%s: reading msg into bytes: %v", peerID, err))
 			}
 			send(eth.ToProto[protocol][msg.Code], peerID, b)
+		case eth.BlockRangeUpdateMsg:
+			if protocol < direct.ETH69 {
+				msg.Discard()
+				return p2p.NewPeerError(p2p.PeerErrorInvalidMessageCode, p2p.DiscSubprotocolError, nil, fmt.Sprintf("unexpected BlockRangeUpdateMsg from %s in eth/%d", peerID, protocol))
+			}
+			b := make([]byte, msg.Size)
+			if _, err := io.ReadFull(msg.Payload, b); err != nil {
+				logger.Error(fmt.Sprintf("%s: reading msg into bytes: %v", peerID, err))
+			}
+			var update eth.BlockRangeUpdatePacket
+			if err := rlp.DecodeBytes(b, &update); err != nil {
+				msg.Discard()
+				return p2p.NewPeerError(p2p.PeerErrorInvalidMessage, p2p.DiscSubprotocolError, err, "sentry.runPeer: BlockRangeUpdateMsg decode error")
+			}
+			if err := update.Validate(); err != nil {
+				msg.Discard()
+				return p2p.NewPeerError(p2p.PeerErrorInvalidMessage, p2p.DiscSubprotocolError, err, "sentry.runPeer: invalid BlockRangeUpdateMsg")
+			}
+			peerInfo.SetIncreasedHeight(update.LatestBlock)
+			if hasSubscribers(eth.ToProto[protocol][msg.Code]) {
+				send(eth.ToProto[protocol][msg.Code], peerID, b)
+			}
 		case 11:
 			// Ignore
 			// TODO: Investigate why BSC peers for eth/67 send these messages
@@ -617,7 +675,7 @@ func NewGrpcServer(ctx context.Context, dialCandidates func() enode.Iterator, re
 		ss.Protocols = append(ss.Protocols, p2p.Protocol{
 			Name:           eth.ProtocolName,
 			Version:        protocol,
-			Length:         17,
+			Length:         eth.ProtocolLengths[protocol],
 			DialCandidates: disc,
 			Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) *p2p.PeerError {
 				peerID := peer.Pubkey()
@@ -678,6 +736,9 @@ func NewGrpcServer(ctx context.Context, dialCandidates func() enode.Iterator, re
 			//Attributes: []enr.Entry{eth.CurrentENREntry(chainConfig, genesisHash, headHeight)},
 		})
 	}
+	if cfg.SnapServe {
+		ss.Protocols = append(ss.Protocols, ss.snapProtocol(ctx, logger))
+	}
 
 	return ss
 }
@@ -713,10 +774,12 @@ type GrpcServer struct {
 	Protocols            []p2p.Protocol
 	discoveryDNS         []string
 	GoodPeers            sync.Map
-	TxSubscribed         uint32 // Set to non-zero if downloader is subscribed to transaction messages
+	snapPeers            sync.Map // peers running the snap protocol, see snapProtocol
+	TxSubscribed         uint32   // Set to non-zero if downloader is subscribed to transaction messages
 	p2pServer            *p2p.Server
 	p2pServerLock        sync.RWMutex
 	statusData           *proto_sentry.StatusData
+	blockRange           *eth.BlockRangeUpdatePacket // last block range announced to eth/69 peers
 	statusDataLock       sync.RWMutex
 	messageStreams       map[proto_sentry.MessageId]map[uint64]chan *proto_sentry.InboundMessage
 	messagesSubscriberID uint64
@@ -903,6 +966,9 @@ func (ss *GrpcServer) SendMessageByMinBlock(_ context.Context, inreq *proto_sent
 }
 
 func (ss *GrpcServer) SendMessageById(_ context.Context, inreq *proto_sentry.SendMessageByIdRequest) (*proto_sentry.SentPeers, error) {
+	if msgcode, ok := snap.FromProto[inreq.Data.Id]; ok {
+		return ss.sendSnapMessageById(inreq, msgcode)
+	}
 	reply := &proto_sentry.SentPeers{}
 	msgcode := eth.FromProto[ss.Protocols[0].Version][inreq.Data.Id]
 	if msgcode != eth.GetBlockHeadersMsg &&
@@ -994,6 +1060,8 @@ func (ss *GrpcServer) HandShake(context.Context, *emptypb.Empty) (*proto_sentry.
 		reply.Protocol = proto_sentry.Protocol_ETH67
 	case direct.ETH68:
 		reply.Protocol = proto_sentry.Protocol_ETH68
+	case direct.ETH69:
+		reply.Protocol = proto_sentry.Protocol_ETH69
 	}
 	return reply, nil
 }
@@ -1055,10 +1123,44 @@ func (ss *GrpcServer) SetStatus(ctx context.Context, statusData *proto_sentry.St
 	if ss.statusData == nil || statusData.MaxBlockHeight != 0 {
 		// Not overwrite statusData if the message contains zero MaxBlock (comes from standalone transaction pool)
 		ss.statusData = statusData
+		ss.announceBlockRange(statusData)
 	}
 	return reply, nil
 }
 
+// announceBlockRange notifies the eth/69 peers about the range of blocks we
+// serve, once the head moved by blockRangeUpdateInterval blocks since the last
+// announcement. Must be called with statusDataLock held.
+func (ss *GrpcServer) announceBlockRange(statusData *proto_sentry.StatusData) {
+	if len(ss.Protocols) == 0 || ss.Protocols[0].Version < direct.ETH69 {
+		return
+	}
+	update := &eth.BlockRangeUpdatePacket{
+		EarliestBlock:   statusData.MinBlockHeight,
+		LatestBlock:     statusData.MaxBlockHeight,
+		LatestBlockHash: gointerfaces.ConvertH256ToHash(statusData.BestHash),
+	}
+	if last := ss.blockRange; last != nil && update.LatestBlock >= last.LatestBlock && update.LatestBlock < last.LatestBlock+blockRangeUpdateInterval {
+		return
+	}
+	if err := update.Validate(); err != nil {
+		return
+	}
+	ss.blockRange = update
+
+	data, err := rlp.EncodeToBytes(update)
+	if err != nil {
+		ss.logger.Error("[sentry] announceBlockRange encode failed", "err", err)
+		return
+	}
+	ss.rangePeers(func(peerInfo *PeerInfo) bool {
+		if peerInfo.protocol >= direct.ETH69 {
+			ss.writePeer("[sentry] announceBlockRange", peerInfo, eth.BlockRangeUpdateMsg, data, 0)
+		}
+		return true
+	})
+}
+
 func (ss *GrpcServer) Peers(_ context.Context, _ *emptypb.Empty) (*proto_sentry.PeersReply, error) {
 	p2pServer := ss.getP2PServer()
 	if p2pServer == nil {
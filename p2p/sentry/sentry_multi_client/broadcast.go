@@ -10,6 +10,7 @@ import (
 	"github.com/erigontech/erigon-lib/log/v3"
 	"google.golang.org/grpc"
 
+	"github.com/erigontech/erigon-lib/direct"
 	proto_sentry "github.com/erigontech/erigon-lib/gointerfaces/sentry"
 
 	"github.com/erigontech/erigon/core/types"
@@ -38,7 +39,7 @@ func (cs *MultiClient) PropagateNewBlockHashes(ctx context.Context, announces []
 	}
 
 	for _, sentry := range cs.sentries {
-		if !sentry.Ready() {
+		if !sentry.Ready() || sentry.Protocol() >= direct.ETH69 { // eth/69 removed the block announcements
 			continue
 		}
 
@@ -75,7 +76,7 @@ func (cs *MultiClient) BroadcastNewBlock(ctx context.Context, header *types.Head
 	}
 
 	for _, sentry := range cs.sentries {
-		if !sentry.Ready() {
+		if !sentry.Ready() || sentry.Protocol() >= direct.ETH69 { // eth/69 removed the block announcements
 			continue
 		}
 
@@ -32,6 +32,7 @@ import (
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/eth/ethconfig"
 	"github.com/erigontech/erigon/eth/protocols/eth"
+	"github.com/erigontech/erigon/eth/protocols/snap"
 	"github.com/erigontech/erigon/p2p/sentry"
 	"github.com/erigontech/erigon/rlp"
 	"github.com/erigontech/erigon/turbo/services"
@@ -53,6 +54,7 @@ type (
 // RecvUploadMessage - sending bodies/receipts - may be heavy, it's ok to not process this messages enough fast, it's also ok to drop some of these messages if we can't process.
 // RecvUploadHeadersMessage - sending headers - dedicated stream because headers propagation speed important for network health
 // PeerEventsLoop - logging peer connect/disconnect events
+// RecvSnapMessage - serving snap/1 state requests
 func (cs *MultiClient) StartStreamLoops(ctx context.Context) {
 	sentries := cs.Sentries()
 	for i := range sentries {
@@ -61,6 +63,7 @@ func (cs *MultiClient) StartStreamLoops(ctx context.Context) {
 		go cs.RecvUploadMessageLoop(ctx, sentry, nil)
 		go cs.RecvUploadHeadersMessageLoop(ctx, sentry, nil)
 		go cs.PeerEventsLoop(ctx, sentry, nil)
+		go cs.RecvSnapMessageLoop(ctx, sentry, nil)
 	}
 }
 
@@ -72,6 +75,7 @@ func (cs *MultiClient) RecvUploadMessageLoop(
 	ids := []proto_sentry.MessageId{
 		eth.ToProto[direct.ETH66][eth.GetBlockBodiesMsg],
 		eth.ToProto[direct.ETH66][eth.GetReceiptsMsg],
+		eth.ToProto[direct.ETH69][eth.GetReceiptsMsg],
 	}
 	streamFactory := func(streamCtx context.Context, sentry direct.SentryClient) (SentryMessageStream, error) {
 		return sentry.Messages(streamCtx, &proto_sentry.MessagesRequest{Ids: ids}, grpc.WaitForReady(true))
@@ -276,8 +280,9 @@ type MultiClient struct {
 	// decouple sentry multi client from header and body downloading logic is done
 	disableBlockDownload bool
 
-	historyV3 bool
-	logger    log.Logger
+	historyV3   bool
+	snapLimiter *snap.ProofLimiter
+	logger      log.Logger
 }
 
 func NewMultiClient(
@@ -341,6 +346,7 @@ func NewMultiClient(
 		sendHeaderRequestsToMultiplePeers: chainConfig.TerminalTotalDifficultyPassed,
 		maxBlockBroadcastPeers:            maxBlockBroadcastPeers,
 		historyV3:                         kvcfg.HistoryV3.FromDB(db),
+		snapLimiter:                       snap.NewProofLimiter(),
 		disableBlockDownload:              disableBlockDownload,
 		logger:                            logger,
 	}
@@ -684,20 +690,28 @@ func (cs *MultiClient) getBlockBodies66(ctx context.Context, inreq *proto_sentry
 }
 
 func (cs *MultiClient) getReceipts66(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient) error {
+	return cs.getReceipts(ctx, inreq, sentry, direct.ETH66, proto_sentry.MessageId_RECEIPTS_66)
+}
+
+func (cs *MultiClient) getReceipts69(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient) error {
+	return cs.getReceipts(ctx, inreq, sentry, direct.ETH69, proto_sentry.MessageId_RECEIPTS_69)
+}
+
+func (cs *MultiClient) getReceipts(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient, version uint, responseId proto_sentry.MessageId) error {
 	if cs.historyV3 { // historyV3 doesn't store receipts in DB
 		return nil
 	}
 
 	var query eth.GetReceiptsPacket66
 	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
-		return fmt.Errorf("decoding getReceipts66: %w, data: %x", err, inreq.Data)
+		return fmt.Errorf("decoding %s: %w, data: %x", inreq.Id, err, inreq.Data)
 	}
 	tx, err := cs.db.BeginRo(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
-	receipts, err := eth.AnswerGetReceiptsQuery(cs.ChainConfig, cs.blockReader, tx, query.GetReceiptsPacket)
+	receipts, err := eth.AnswerGetReceiptsQuery(cs.ChainConfig, cs.blockReader, tx, query.GetReceiptsPacket, version)
 	if err != nil {
 		return err
 	}
@@ -712,7 +726,7 @@ func (cs *MultiClient) getReceipts66(ctx context.Context, inreq *proto_sentry.In
 	outreq := proto_sentry.SendMessageByIdRequest{
 		PeerId: inreq.PeerId,
 		Data: &proto_sentry.OutboundMessageData{
-			Id:   proto_sentry.MessageId_RECEIPTS_66,
+			Id:   responseId,
 			Data: b,
 		},
 	}
@@ -774,6 +788,22 @@ func (cs *MultiClient) handleInboundMessage(ctx context.Context, inreq *proto_se
 		return cs.receipts66(ctx, inreq, sentry)
 	case proto_sentry.MessageId_GET_RECEIPTS_66:
 		return cs.getReceipts66(ctx, inreq, sentry)
+
+	// ========= eth 69 ==========
+
+	case proto_sentry.MessageId_GET_RECEIPTS_69:
+		return cs.getReceipts69(ctx, inreq, sentry)
+
+	// ========= snap 1 ==========
+
+	case proto_sentry.MessageId_GET_ACCOUNT_RANGE_SNAP1:
+		return cs.getAccountRangeSnap1(ctx, inreq, sentry)
+	case proto_sentry.MessageId_GET_STORAGE_RANGES_SNAP1:
+		return cs.getStorageRangesSnap1(ctx, inreq, sentry)
+	case proto_sentry.MessageId_GET_BYTE_CODES_SNAP1:
+		return cs.getByteCodesSnap1(ctx, inreq, sentry)
+	case proto_sentry.MessageId_GET_TRIE_NODES_SNAP1:
+		return cs.getTrieNodesSnap1(ctx, inreq, sentry)
 	default:
 		return fmt.Errorf("not implemented for message Id: %s", inreq.Id)
 	}
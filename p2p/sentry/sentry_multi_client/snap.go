@@ -0,0 +1,160 @@
+package sentry_multi_client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"github.com/erigontech/erigon-lib/direct"
+	"github.com/erigontech/erigon-lib/gointerfaces"
+	proto_sentry "github.com/erigontech/erigon-lib/gointerfaces/sentry"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/eth/protocols/snap"
+	"github.com/erigontech/erigon/rlp"
+)
+
+// RecvSnapMessageLoop - serving snap/1 state requests, the sentry only forwards
+// them if it runs the snap protocol (see --p2p.protocol.snap)
+func (cs *MultiClient) RecvSnapMessageLoop(
+	ctx context.Context,
+	sentry direct.SentryClient,
+	wg *sync.WaitGroup,
+) {
+	ids := []proto_sentry.MessageId{
+		snap.ToProto[snap.GetAccountRangeMsg],
+		snap.ToProto[snap.GetStorageRangesMsg],
+		snap.ToProto[snap.GetByteCodesMsg],
+		snap.ToProto[snap.GetTrieNodesMsg],
+	}
+	streamFactory := func(streamCtx context.Context, sentry direct.SentryClient) (SentryMessageStream, error) {
+		return sentry.Messages(streamCtx, &proto_sentry.MessagesRequest{Ids: ids}, grpc.WaitForReady(true))
+	}
+
+	SentryReconnectAndPumpStreamLoop(ctx, sentry, cs.makeStatusData, "RecvSnapMessage", streamFactory, MakeInboundMessage, cs.HandleInboundMessage, wg, cs.logger)
+}
+
+func (cs *MultiClient) sendSnapResponse(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient, id proto_sentry.MessageId, packet interface{}) error {
+	b, err := rlp.EncodeToBytes(packet)
+	if err != nil {
+		return fmt.Errorf("encode %s response: %w", id, err)
+	}
+	outreq := proto_sentry.SendMessageByIdRequest{
+		PeerId: inreq.PeerId,
+		Data: &proto_sentry.OutboundMessageData{
+			Id:   id,
+			Data: b,
+		},
+	}
+	if _, err = sentry.SendMessageById(ctx, &outreq, &grpc.EmptyCallOption{}); err != nil {
+		if isPeerNotFoundErr(err) {
+			return nil
+		}
+		return fmt.Errorf("send %s response: %w", id, err)
+	}
+	return nil
+}
+
+// allowSnapProof reports whether the peer of the request can make another
+// request walking the state trie, which is otherwise answered empty.
+func (cs *MultiClient) allowSnapProof(inreq *proto_sentry.InboundMessage) bool {
+	peer := hex.EncodeToString(gointerfaces.ConvertH512ToBytes(inreq.PeerId))
+	if cs.snapLimiter.Allow(peer) {
+		return true
+	}
+	cs.logger.Debug("[snap] request rate exceeded", "peer", peer[:8])
+	return false
+}
+
+func (cs *MultiClient) getAccountRangeSnap1(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient) error {
+	if cs.historyV3 { // historyV3 doesn't keep the hashed state and the intermediate hashes
+		return nil
+	}
+	var query snap.GetAccountRangePacket
+	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
+		return fmt.Errorf("decoding getAccountRangeSnap1: %w, data: %x", err, inreq.Data)
+	}
+	response := snap.AccountRangePacket{ID: query.ID}
+	if !cs.allowSnapProof(inreq) {
+		return cs.sendSnapResponse(ctx, inreq, sentry, proto_sentry.MessageId_ACCOUNT_RANGE_SNAP1, &response)
+	}
+	if err := cs.db.View(ctx, func(tx kv.Tx) error {
+		root, err := snap.ServedRoot(ctx, tx, cs.blockReader)
+		if err != nil {
+			return err
+		}
+		response.Accounts, response.Proof, err = snap.AnswerGetAccountRangeQuery(tx, &query, root, ctx.Done())
+		return err
+	}); err != nil {
+		return fmt.Errorf("querying AccountRange: %w", err)
+	}
+	return cs.sendSnapResponse(ctx, inreq, sentry, proto_sentry.MessageId_ACCOUNT_RANGE_SNAP1, &response)
+}
+
+func (cs *MultiClient) getStorageRangesSnap1(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient) error {
+	if cs.historyV3 {
+		return nil
+	}
+	var query snap.GetStorageRangesPacket
+	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
+		return fmt.Errorf("decoding getStorageRangesSnap1: %w, data: %x", err, inreq.Data)
+	}
+	response := snap.StorageRangesPacket{ID: query.ID}
+	if !cs.allowSnapProof(inreq) {
+		return cs.sendSnapResponse(ctx, inreq, sentry, proto_sentry.MessageId_STORAGE_RANGES_SNAP1, &response)
+	}
+	if err := cs.db.View(ctx, func(tx kv.Tx) error {
+		root, err := snap.ServedRoot(ctx, tx, cs.blockReader)
+		if err != nil {
+			return err
+		}
+		response.Slots, response.Proof, err = snap.AnswerGetStorageRangesQuery(tx, &query, root, ctx.Done())
+		return err
+	}); err != nil {
+		return fmt.Errorf("querying StorageRanges: %w", err)
+	}
+	return cs.sendSnapResponse(ctx, inreq, sentry, proto_sentry.MessageId_STORAGE_RANGES_SNAP1, &response)
+}
+
+func (cs *MultiClient) getByteCodesSnap1(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient) error {
+	var query snap.GetByteCodesPacket
+	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
+		return fmt.Errorf("decoding getByteCodesSnap1: %w, data: %x", err, inreq.Data)
+	}
+	response := snap.ByteCodesPacket{ID: query.ID}
+	if err := cs.db.View(ctx, func(tx kv.Tx) (err error) {
+		response.Codes, err = snap.AnswerGetByteCodesQuery(tx, &query)
+		return err
+	}); err != nil {
+		return fmt.Errorf("querying ByteCodes: %w", err)
+	}
+	return cs.sendSnapResponse(ctx, inreq, sentry, proto_sentry.MessageId_BYTE_CODES_SNAP1, &response)
+}
+
+func (cs *MultiClient) getTrieNodesSnap1(ctx context.Context, inreq *proto_sentry.InboundMessage, sentry direct.SentryClient) error {
+	if cs.historyV3 {
+		return nil
+	}
+	var query snap.GetTrieNodesPacket
+	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
+		return fmt.Errorf("decoding getTrieNodesSnap1: %w, data: %x", err, inreq.Data)
+	}
+	response := snap.TrieNodesPacket{ID: query.ID}
+	if !cs.allowSnapProof(inreq) {
+		return cs.sendSnapResponse(ctx, inreq, sentry, proto_sentry.MessageId_TRIE_NODES_SNAP1, &response)
+	}
+	if err := cs.db.View(ctx, func(tx kv.Tx) error {
+		root, err := snap.ServedRoot(ctx, tx, cs.blockReader)
+		if err != nil {
+			return err
+		}
+		response.Nodes, err = snap.AnswerGetTrieNodesQuery(tx, &query, root, ctx.Done())
+		return err
+	}); err != nil {
+		return fmt.Errorf("querying TrieNodes: %w", err)
+	}
+	return cs.sendSnapResponse(ctx, inreq, sentry, proto_sentry.MessageId_TRIE_NODES_SNAP1, &response)
+}
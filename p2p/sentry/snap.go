@@ -0,0 +1,118 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	proto_sentry "github.com/erigontech/erigon-lib/gointerfaces/sentry"
+	proto_types "github.com/erigontech/erigon-lib/gointerfaces/types"
+
+	"github.com/erigontech/erigon/eth/protocols/snap"
+	"github.com/erigontech/erigon/p2p"
+)
+
+// snapProtocol is the snap/1 satellite protocol. Requests are forwarded to the
+// subscribers of the snap message ids, which answer through SendMessageById.
+func (ss *GrpcServer) snapProtocol(ctx context.Context, logger log.Logger) p2p.Protocol {
+	return p2p.Protocol{
+		Name:    snap.ProtocolName,
+		Version: snap.Version,
+		Length:  snap.ProtocolLength,
+		Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) *p2p.PeerError {
+			peerID := peer.Pubkey()
+			peerInfo := NewPeerInfo(peer, rw)
+			peerInfo.protocol = snap.Version
+			defer peerInfo.Close()
+
+			ss.snapPeers.Store(peerID, peerInfo)
+			defer ss.snapPeers.Delete(peerID)
+
+			return ss.runSnapPeer(ctx, peerID, rw, peerInfo, logger)
+		},
+		NodeInfo: func() interface{} {
+			return nil
+		},
+		PeerInfo: func(peerID [64]byte) interface{} {
+			return nil
+		},
+	}
+}
+
+func (ss *GrpcServer) runSnapPeer(ctx context.Context, peerID [64]byte, rw p2p.MsgReadWriter, peerInfo *PeerInfo, logger log.Logger) *p2p.PeerError {
+	cap := p2p.Cap{Name: snap.ProtocolName, Version: snap.Version}
+	for {
+		if err := libcommon.Stopped(ctx.Done()); err != nil {
+			return p2p.NewPeerError(p2p.PeerErrorDiscReason, p2p.DiscQuitting, ctx.Err(), "sentry.runSnapPeer: context stopped")
+		}
+		if err := peerInfo.RemoveReason(); err != nil {
+			return err
+		}
+
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return p2p.NewPeerError(p2p.PeerErrorMessageReceive, p2p.DiscNetworkError, err, "sentry.runSnapPeer: ReadMsg error")
+		}
+
+		if msg.Size > snap.ProtocolMaxMsgSize {
+			msg.Discard()
+			return p2p.NewPeerError(p2p.PeerErrorMessageSizeLimit, p2p.DiscSubprotocolError, nil, fmt.Sprintf("sentry.runSnapPeer: message is too large %d, limit %d", msg.Size, snap.ProtocolMaxMsgSize))
+		}
+
+		switch msg.Code {
+		case snap.GetAccountRangeMsg, snap.GetStorageRangesMsg, snap.GetByteCodesMsg, snap.GetTrieNodesMsg:
+			// snap is a satellite protocol, only peers which completed the eth
+			// handshake are served
+			if ss.getPeer(peerID) == nil || !ss.hasSubscribers(snap.ToProto[msg.Code]) {
+				break
+			}
+			b := make([]byte, msg.Size)
+			if _, err := io.ReadFull(msg.Payload, b); err != nil {
+				logger.Error(fmt.Sprintf("%s: reading msg into bytes: %v", peerID, err))
+			}
+			ss.send(snap.ToProto[msg.Code], peerID, b)
+		case snap.AccountRangeMsg, snap.StorageRangesMsg, snap.ByteCodesMsg, snap.TrieNodesMsg:
+			// we never request state from snap peers
+		default:
+			msg.Discard()
+			return p2p.NewPeerError(p2p.PeerErrorInvalidMessageCode, p2p.DiscSubprotocolError, nil, fmt.Sprintf("sentry.runSnapPeer: unknown message code %d", msg.Code))
+		}
+
+		trackPeerStatistics(peerInfo.peer.Info().ID, true, snap.ToProto[msg.Code].String(), cap.String(), int(msg.Size))
+		msg.Discard()
+	}
+}
+
+func (ss *GrpcServer) sendSnapMessageById(inreq *proto_sentry.SendMessageByIdRequest, msgcode uint64) (*proto_sentry.SentPeers, error) {
+	reply := &proto_sentry.SentPeers{}
+	if msgcode != snap.AccountRangeMsg &&
+		msgcode != snap.StorageRangesMsg &&
+		msgcode != snap.ByteCodesMsg &&
+		msgcode != snap.TrieNodesMsg {
+		return reply, fmt.Errorf("sendMessageById not implemented for message Id: %s", inreq.Data.Id)
+	}
+
+	peerID := ConvertH512ToPeerID(inreq.PeerId)
+	value, ok := ss.snapPeers.Load(peerID)
+	if !ok {
+		return reply, nil
+	}
+	peerInfo := value.(*PeerInfo)
+	data := inreq.Data.Data
+	peerInfo.Async(func() {
+		cap := p2p.Cap{Name: snap.ProtocolName, Version: snap.Version}
+		trackPeerStatistics(peerInfo.peer.Info().ID, false, inreq.Data.Id.String(), cap.String(), len(data))
+
+		err := peerInfo.rw.WriteMsg(p2p.Msg{Code: msgcode, Size: uint32(len(data)), Payload: bytes.NewReader(data)})
+		if err != nil {
+			peerInfo.Remove(p2p.NewPeerError(p2p.PeerErrorMessageSend, p2p.DiscNetworkError, err, fmt.Sprintf("[sentry] sendSnapMessageById msgcode=%d", msgcode)))
+			ss.snapPeers.Delete(peerID)
+		}
+	}, ss.logger)
+	reply.Peers = []*proto_types.H512{inreq.PeerId}
+	return reply, nil
+}
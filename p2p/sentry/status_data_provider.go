@@ -17,6 +17,7 @@ import (
 	"github.com/erigontech/erigon/core/forkid"
 	"github.com/erigontech/erigon/core/rawdb"
 	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/ethdb/prune"
 )
 
 var ErrNoHead = errors.New("ReadChainHead: ReadCurrentHeader error")
@@ -36,6 +37,7 @@ type StatusDataProvider struct {
 	genesisHead ChainHead
 	heightForks []uint64
 	timeForks   []uint64
+	pruneMode   prune.Mode
 
 	logger log.Logger
 }
@@ -45,6 +47,7 @@ func NewStatusDataProvider(
 	chainConfig *chain.Config,
 	genesis *types.Block,
 	networkId uint64,
+	pruneMode prune.Mode,
 	logger log.Logger,
 ) *StatusDataProvider {
 	s := &StatusDataProvider{
@@ -52,6 +55,7 @@ func NewStatusDataProvider(
 		networkId:   networkId,
 		genesisHash: genesis.Hash(),
 		genesisHead: makeGenesisChainHead(genesis),
+		pruneMode:   pruneMode,
 		logger:      logger,
 	}
 
@@ -93,6 +97,7 @@ func (s *StatusDataProvider) makeStatusData(head ChainHead) *proto_sentry.Status
 		BestHash:        gointerfaces.ConvertHashToH256(head.HeadHash),
 		MaxBlockHeight:  head.HeadHeight,
 		MaxBlockTime:    head.HeadTime,
+		MinBlockHeight:  s.earliestAvailableBlock(head.HeadHeight),
 		ForkData: &proto_sentry.Forks{
 			Genesis:     gointerfaces.ConvertHashToH256(s.genesisHash),
 			HeightForks: s.heightForks,
@@ -101,6 +106,16 @@ func (s *StatusDataProvider) makeStatusData(head ChainHead) *proto_sentry.Status
 	}
 }
 
+// earliestAvailableBlock is the first block whose bodies and receipts can be
+// served to peers, given the receipts prune settings (bodies are never pruned).
+func (s *StatusDataProvider) earliestAvailableBlock(head uint64) uint64 {
+	if s.pruneMode.Receipts == nil || !s.pruneMode.Receipts.Enabled() {
+		return 0
+	}
+	// receipts are kept for the blocks after the prune point, see the execution stage
+	return min(s.pruneMode.Receipts.PruneTo(head)+1, head)
+}
+
 func (s *StatusDataProvider) GetStatusData(ctx context.Context) (*proto_sentry.StatusData, error) {
 	chainHead, err := ReadChainHead(ctx, s.db)
 	if err != nil {
@@ -143,6 +143,42 @@ var (
 		Clique:                &chain.CliqueConfig{Period: 0, Epoch: 30000},
 	}
 
+	// AllOptimismProtocolChanges contains every protocol change introduced by
+	// Ethereum and by the OP Stack, all active at genesis. Used by --dev.optimism.
+	AllOptimismProtocolChanges = &chain.Config{
+		ChainID:                       big.NewInt(1337),
+		Consensus:                     chain.EtHashConsensus,
+		HomesteadBlock:                big.NewInt(0),
+		TangerineWhistleBlock:         big.NewInt(0),
+		SpuriousDragonBlock:           big.NewInt(0),
+		ByzantiumBlock:                big.NewInt(0),
+		ConstantinopleBlock:           big.NewInt(0),
+		PetersburgBlock:               big.NewInt(0),
+		IstanbulBlock:                 big.NewInt(0),
+		MuirGlacierBlock:              big.NewInt(0),
+		BerlinBlock:                   big.NewInt(0),
+		LondonBlock:                   big.NewInt(0),
+		ArrowGlacierBlock:             big.NewInt(0),
+		GrayGlacierBlock:              big.NewInt(0),
+		TerminalTotalDifficulty:       big.NewInt(0),
+		TerminalTotalDifficultyPassed: true,
+		ShanghaiTime:                  big.NewInt(0),
+		CancunTime:                    big.NewInt(0),
+		BedrockBlock:                  big.NewInt(0),
+		RegolithTime:                  big.NewInt(0),
+		CanyonTime:                    big.NewInt(0),
+		EcotoneTime:                   big.NewInt(0),
+		FjordTime:                     big.NewInt(0),
+		GraniteTime:                   big.NewInt(0),
+		HoloceneTime:                  big.NewInt(0),
+		Ethash:                        new(chain.EthashConfig),
+		Optimism: &chain.OptimismConfig{
+			EIP1559Elasticity:        6,
+			EIP1559Denominator:       50,
+			EIP1559DenominatorCanyon: 250,
+		},
+	}
+
 	MumbaiChainConfig = readChainSpec("chainspecs/mumbai.json")
 
 	AmoyChainConfig = readChainSpec("chainspecs/amoy.json")
@@ -21,4 +21,8 @@ type MiningConfig struct {
 	GasLimit   uint64            // Target gas limit for mined blocks.
 	GasPrice   *big.Int          // Minimum gas price for mining a transaction
 	Recommit   time.Duration     // The time interval for miner to re-create mining work.
+
+	RebuildInterval time.Duration // Time interval to re-build the PoS payloads until they are retrieved, built once if 0 (the default): each re-build re-executes the block and holds a db read transaction
+
+	ExecWorkers int // Number of workers executing the block transactions in parallel, serial execution if <= 1
 }
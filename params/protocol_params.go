@@ -23,6 +23,8 @@ import (
 )
 
 var (
+	// The priority fee portion of the transaction fee accumulates at this predeploy
+	OptimismSequencerFeeRecipient = common.HexToAddress("0x4200000000000000000000000000000000000011")
 	// The base fee portion of the transaction fee accumulates at this predeploy
 	OptimismBaseFeeRecipient = common.HexToAddress("0x4200000000000000000000000000000000000019")
 	// The L1 portion of the transaction fee accumulates at this predeploy
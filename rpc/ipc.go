@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"context"
 	"net"
 
 	"github.com/erigontech/erigon-lib/log/v3"
@@ -37,3 +38,15 @@ func (s *Server) ServeListener(l net.Listener) error {
 		go s.ServeCodec(NewCodec(conn), 0)
 	}
 }
+
+// DialIPC creates a client connected to the unix socket at the given path.
+func DialIPC(ctx context.Context, endpoint string, logger log.Logger) (*Client, error) {
+	return newClient(ctx, func(ctx context.Context) (ServerCodec, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "unix", endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return NewCodec(conn), nil
+	}, logger)
+}
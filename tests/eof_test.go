@@ -0,0 +1,23 @@
+package tests
+
+import (
+	"testing"
+)
+
+func TestEOF(t *testing.T) {
+	//t.Parallel()
+
+	et := new(testMatcher)
+
+	// the vectors of the repo run in every build, the EOFTests of the tests
+	// submodule when it is checked out
+	for _, dir := range []string{eofVectorsDir, eofTestDir} {
+		t.Run(dir, func(t *testing.T) {
+			et.walk(t, dir, func(t *testing.T, name string, test *EOFTest) {
+				if err := et.checkFailure(t, test.Run()); err != nil {
+					t.Error(err)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/core/vm"
+)
+
+// EOFTest is the JSON structure of an EOF container validation test
+// (EIP-7692), as found in EOFTests and the eof_tests fixtures.
+type EOFTest struct {
+	Vectors map[string]struct {
+		Code          hexutility.Bytes `json:"code"`
+		ContainerKind string           `json:"containerKind"`
+		Results       map[string]struct {
+			Exception string `json:"exception"`
+			Result    bool   `json:"result"`
+		} `json:"results"`
+	} `json:"vectors"`
+}
+
+// Run validates the containers of the test against the EOF instruction set
+// of every fork of the expected results, which must enable EOF.
+func (t *EOFTest) Run() error {
+	for name, vector := range t.Vectors {
+		for fork, result := range vector.Results {
+			config, ok := Forks[fork]
+			if !ok {
+				return UnsupportedForkError{fork}
+			}
+			rules := config.Rules(0, 0)
+			if !rules.IsEOF {
+				return fmt.Errorf("vector %s: fork %s does not enable EOF", name, fork)
+			}
+			err := vm.ValidateEOFCode(vector.Code, vector.ContainerKind == "INITCODE", rules)
+			if result.Result && err != nil {
+				return fmt.Errorf("vector %s (%s): unexpected error: %w", name, fork, err)
+			}
+			if !result.Result && err == nil {
+				return fmt.Errorf("vector %s (%s): expected error %s", name, fork, result.Exception)
+			}
+		}
+	}
+	return nil
+}
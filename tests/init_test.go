@@ -40,6 +40,8 @@ var (
 	transactionTestDir = filepath.Join(baseDir, "TransactionTests")
 	rlpTestDir         = filepath.Join(baseDir, "RLPTests")
 	difficultyTestDir  = filepath.Join(baseDir, "DifficultyTests")
+	eofTestDir         = filepath.Join(baseDir, "EOFTests")
+	eofVectorsDir      = filepath.Join(".", "eof")
 )
 
 func readJSON(reader io.Reader, value interface{}) error {
@@ -31,6 +31,7 @@ import (
 
 	"github.com/erigontech/erigon/accounts/abi"
 	"github.com/erigontech/erigon/common/math"
+	"github.com/erigontech/erigon/core/legacy"
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/core/vm/evmtypes"
 	"github.com/erigontech/erigon/eth/tracers/logger"
@@ -433,6 +434,8 @@ type RPCTransaction struct {
 	IsSystemTx *bool           `json:"isSystemTx,omitempty"`
 	// deposit-tx post-Canyon only
 	DepositReceiptVersion *hexutil.Uint64 `json:"depositReceiptVersion,omitempty"`
+	// legacy (pre-Bedrock) only
+	*legacy.RPCTxMeta
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
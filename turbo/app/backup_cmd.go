@@ -1,46 +1,72 @@
 package app
 
 import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-
-	"github.com/erigontech/erigon-lib/common"
-	"github.com/erigontech/erigon-lib/kv/backup"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/c2h5oh/datasize"
+	"github.com/klauspost/compress/zstd"
+	"github.com/urfave/cli/v2"
+
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/common/dir"
 	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/backup"
+	"github.com/erigontech/erigon-lib/log/v3"
+
 	"github.com/erigontech/erigon/cmd/utils"
 	"github.com/erigontech/erigon/cmd/utils/flags"
 	"github.com/erigontech/erigon/turbo/debug"
-	"github.com/urfave/cli/v2"
 )
 
 // nolint
 var backupCommand = cli.Command{
-	Name: "alpha_backup",
-	Description: `Alpha verison of command. Backup all databases without stopping of Erigon.
-While this command has Alpha prefix - we recommend to stop Erigon for backup. 
-Limitations: 
-- no support of datadir/snapshots folder. Recommendation: backup snapshots dir manually AFTER databases backup. Possible to implement in future.
-- no support of Consensus DB (copy it manually if you need). Possible to implement in future.
-- way to pipe output to compressor (lz4/zstd). Can compress target floder later or use zfs-with-enabled-compression.
-- jwt tocken: copy it manually - if need. 
-- no support of SentryDB (datadir/nodes folder). Because seems no much reason to backup it.
-
-Example: erigon backup --datadir=<your_datadir> --to.datadir=<backup_datadir>
-
-TODO:
-- support of Consensus DB (copy it manually if you need). Possible to implement in future.
-- support 2 use-cases: create new node (then remove jwt tocken, and nodes folder) and backup exising one (then backup jwt tocken, and nodes folder)
-- support of datadir/snapshots folder. Possible to implement in future. Can copy it manually or rsync or symlink/mount.
+	Name:    "backup",
+	Aliases: []string{"alpha_backup"},
+	Usage:   "Backup a datadir without stopping Erigon",
+	Description: `Consistent online backup of a datadir: the databases (chaindata, txpool, downloader,
+consensus and caplin), the snapshot files, the caplin blob sidecars and the jwt secret.
+
+The chaindata read transaction is opened before the snapshot files are collected, so the
+backup is a point-in-time copy: the files can be ahead of the database, which is fine,
+but never behind it. The snapshot files are immutable, so they are hard-linked when the
+target is on the same filesystem as the datadir and copied otherwise.
+
+The backup goes either to a directory, which can be used as a datadir, or to a tar archive
+compressed with zstd (--to.tar=- writes it to stdout). A tar backup streams the databases
+as they are, without a copy of them on disk, so --tables and --to.pagesize don't apply to it.
+
+--incremental only copies the snapshot files the previous backup doesn't have. For a
+directory backup it is the content of --to.datadir; for a tar backup it is the manifest
+written next to the previous archive (--incremental.manifest). The databases are always
+copied in full. The nodes folder (sentry) isn't backed up.
+
+Examples:
+  erigon backup --datadir=<your_datadir> --to.datadir=<backup_datadir> --incremental
+  erigon backup --datadir=<your_datadir> --to.tar=backup.tar.zst
+  erigon backup --datadir=<your_datadir> --to.tar=- --labels=chaindata,snapshots | ssh host 'cat > backup.tar.zst'
 `,
 	Action: doBackup,
 	Flags: joinFlags([]cli.Flag{
 		&utils.DataDirFlag,
 		&ToDatadirFlag,
+		&ToTarFlag,
+		&BackupCompressFlag,
+		&BackupIncrementalFlag,
+		&BackupIncrementalManifestFlag,
 		&BackupToPageSizeFlag,
 		&BackupLabelsFlag,
 		&BackupTablesFlag,
@@ -50,13 +76,29 @@ TODO:
 
 var (
 	ToDatadirFlag = flags.DirectoryFlag{
-		Name:     "to.datadir",
-		Usage:    "Target datadir",
-		Required: true,
+		Name:  "to.datadir",
+		Usage: "Target datadir",
+	}
+	ToTarFlag = cli.StringFlag{
+		Name:  "to.tar",
+		Usage: "Target tar archive, `-` for stdout",
+	}
+	BackupCompressFlag = cli.StringFlag{
+		Name:  "compress",
+		Usage: "Compression of the tar archive. One of: zstd,none",
+		Value: "zstd",
+	}
+	BackupIncrementalFlag = cli.BoolFlag{
+		Name:  "incremental",
+		Usage: "Only copy the snapshot files which are not in the previous backup",
+	}
+	BackupIncrementalManifestFlag = cli.StringFlag{
+		Name:  "incremental.manifest",
+		Usage: "Manifest of the previous tar backup, written next to it as <archive>.manifest",
 	}
 	BackupLabelsFlag = cli.StringFlag{
 		Name:  "labels",
-		Usage: "Name of component to backup. Example: chaindata,txpool,downloader",
+		Usage: "Name of component to backup. Example: chaindata,txpool,downloader,consensus,caplin,snapshots,jwt",
 	}
 	BackupTablesFlag = cli.StringFlag{
 		Name:  "tables",
@@ -68,36 +110,87 @@ var (
 	}
 	WarmupThreadsFlag = cli.Uint64Flag{
 		Name: "warmup.threads",
-		Usage: `Erigon's db works as blocking-io: means it stops when read from disk. 
-It means backup speed depends on 'disk latency' (not throughput). 
-Can spawn many threads which will read-ahead the data and bring it to OS's PageCache. 
+		Usage: `Erigon's db works as blocking-io: means it stops when read from disk.
+It means backup speed depends on 'disk latency' (not throughput).
+Can spawn many threads which will read-ahead the data and bring it to OS's PageCache.
 CloudDrives (and ssd) have bad-latency and good-parallel-throughput - then having >1k of warmup threads will help.`,
 		Value: uint64(backup.ReadAheadThreads),
 	}
 )
 
+// components which can be backed up, by --labels name
+const (
+	backupChaindata  = "chaindata"
+	backupTxPool     = "txpool"
+	backupDownloader = "downloader"
+	backupConsensus  = "consensus"
+	backupCaplin     = "caplin"
+	backupSnapshots  = "snapshots"
+	backupJwt        = "jwt"
+)
+
+var backupComponents = []string{backupChaindata, backupTxPool, backupDownloader, backupConsensus, backupCaplin, backupSnapshots, backupJwt}
+
+const (
+	jwtFileName        = "jwt.hex"
+	backupManifestName = "backup.manifest"
+)
+
+// backupDB is a database to backup, with the immutable files which must be
+// collected once its read transaction is open.
+type backupDB struct {
+	label     kv.Label
+	from, to  string
+	filesFrom string
+	filesTo   string
+}
+
 func doBackup(cliCtx *cli.Context) error {
 	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
 	if err != nil {
 		return err
 	}
 
-	defer logger.Info("backup done")
-
 	ctx := cliCtx.Context
 	dirs := datadir.New(cliCtx.String(utils.DataDirFlag.Name))
-	toDirs := datadir.New(cliCtx.String(ToDatadirFlag.Name))
+
+	toDatadir, toTar := cliCtx.String(ToDatadirFlag.Name), cliCtx.String(ToTarFlag.Name)
+	if (toDatadir == "") == (toTar == "") {
+		return fmt.Errorf("exactly one of --%s and --%s is required", ToDatadirFlag.Name, ToTarFlag.Name)
+	}
+	compress := cliCtx.String(BackupCompressFlag.Name)
+	if compress != "zstd" && compress != "none" {
+		return fmt.Errorf("unexpected --%s: %s", BackupCompressFlag.Name, compress)
+	}
+	incremental := cliCtx.Bool(BackupIncrementalFlag.Name)
+	var prevManifest map[string]int64
+	if toTar != "" && incremental {
+		if !cliCtx.IsSet(BackupIncrementalManifestFlag.Name) {
+			return fmt.Errorf("--%s of a tar backup requires --%s", BackupIncrementalFlag.Name, BackupIncrementalManifestFlag.Name)
+		}
+		if prevManifest, err = readBackupManifest(cliCtx.String(BackupIncrementalManifestFlag.Name)); err != nil {
+			return err
+		}
+	}
+
+	// a tar backup names its entries after the paths in the datadir
+	toDirs := dirs
+	if toTar == "" {
+		toDirs = datadir.New(toDatadir)
+	}
 
 	var targetPageSize datasize.ByteSize
 	if cliCtx.IsSet(BackupToPageSizeFlag.Name) {
 		targetPageSize = flags.DBPageSizeFlagUnmarshal(cliCtx, BackupToPageSizeFlag.Name, BackupToPageSizeFlag.Usage)
 	}
 
-	var lables = []kv.Label{kv.ChainDB, kv.TxPoolDB, kv.DownloaderDB}
-	if cliCtx.IsSet(BackupToPageSizeFlag.Name) {
-		lables = lables[:0]
-		for _, l := range common.CliString2Array(cliCtx.String(BackupLabelsFlag.Name)) {
-			lables = append(lables, kv.UnmarshalLabel(l))
+	components := backupComponents
+	if cliCtx.IsSet(BackupLabelsFlag.Name) {
+		components = common.CliString2Array(cliCtx.String(BackupLabelsFlag.Name))
+		for _, c := range components {
+			if !slices.Contains(backupComponents, c) {
+				return fmt.Errorf("unexpected --%s: %s", BackupLabelsFlag.Name, c)
+			}
 		}
 	}
 
@@ -105,45 +198,350 @@ func doBackup(cliCtx *cli.Context) error {
 	if cliCtx.IsSet(BackupTablesFlag.Name) {
 		tables = common.CliString2Array(cliCtx.String(BackupTablesFlag.Name))
 	}
+	if toTar != "" && (len(tables) > 0 || targetPageSize > 0) {
+		return fmt.Errorf("--%s and --%s don't apply to a tar backup, which streams the databases as they are", BackupTablesFlag.Name, BackupToPageSizeFlag.Name)
+	}
 
 	readAheadThreads := backup.ReadAheadThreads
 	if cliCtx.IsSet(WarmupThreadsFlag.Name) {
 		readAheadThreads = int(cliCtx.Uint64(WarmupThreadsFlag.Name))
 	}
 
-	//kv.SentryDB no much reason to backup
-	//TODO: add support of kv.ConsensusDB
-	for _, label := range lables {
-		var from, to string
-		switch label {
-		case kv.ChainDB:
-			from, to = dirs.Chaindata, toDirs.Chaindata
-		case kv.TxPoolDB:
-			from, to = dirs.TxPool, toDirs.TxPool
-		case kv.DownloaderDB:
-			from, to = filepath.Join(dirs.Snap, "db"), filepath.Join(toDirs.Snap, "db")
-		default:
-			panic(fmt.Sprintf("unexpected: %+v", label))
+	// the chaindata goes first: the snapshot files are collected under its read transaction
+	var dbs []backupDB
+	if slices.Contains(components, backupChaindata) {
+		db := backupDB{label: kv.ChainDB, from: dirs.Chaindata, to: toDirs.Chaindata}
+		if slices.Contains(components, backupSnapshots) {
+			db.filesFrom, db.filesTo = dirs.Snap, toDirs.Snap
 		}
+		dbs = append(dbs, db)
+	}
+	if slices.Contains(components, backupTxPool) {
+		dbs = append(dbs, backupDB{label: kv.TxPoolDB, from: dirs.TxPool, to: toDirs.TxPool})
+	}
+	if slices.Contains(components, backupDownloader) {
+		dbs = append(dbs, backupDB{label: kv.DownloaderDB, from: dirs.Downloader, to: toDirs.Downloader})
+	}
+	if slices.Contains(components, backupConsensus) {
+		for _, name := range []string{"clique", "aura", "bor"} {
+			dbs = append(dbs, backupDB{label: kv.ConsensusDB, from: filepath.Join(dirs.DataDir, name), to: filepath.Join(toDirs.DataDir, name)})
+		}
+	}
+	if slices.Contains(components, backupCaplin) {
+		dbs = append(dbs,
+			backupDB{label: kv.ChainDB, from: filepath.Join(dirs.CaplinIndexing, "beacon_indicies"), to: filepath.Join(toDirs.CaplinIndexing, "beacon_indicies")},
+			backupDB{label: kv.ChainDB, from: filepath.Join(dirs.CaplinBlobs, "chaindata"), to: filepath.Join(toDirs.CaplinBlobs, "chaindata"),
+				filesFrom: dirs.CaplinBlobs, filesTo: toDirs.CaplinBlobs})
+	}
+
+	if toTar != "" {
+		if err := backupToTar(ctx, dirs, dbs, slices.Contains(components, backupSnapshots) && !slices.Contains(components, backupChaindata),
+			slices.Contains(components, backupJwt), toTar, compress == "zstd", prevManifest, logger); err != nil {
+			return err
+		}
+		logger.Info("[backup] done")
+		return nil
+	}
 
-		if !dir.Exist(from) {
+	for _, db := range dbs {
+		if !dir.FileExist(filepath.Join(db.from, "mdbx.dat")) {
+			if db.filesFrom != "" {
+				if err := backupFiles(db.filesFrom, db.filesTo, incremental, logger); err != nil {
+					return err
+				}
+			}
 			continue
 		}
+		if err := backupDatabase(ctx, db, tables, targetPageSize, readAheadThreads, incremental, logger); err != nil {
+			return err
+		}
+	}
+	// without the chaindata there is no point in time to stick to
+	if slices.Contains(components, backupSnapshots) && !slices.Contains(components, backupChaindata) {
+		if err := backupFiles(dirs.Snap, toDirs.Snap, incremental, logger); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(components, backupJwt) {
+		if err := backupJwtSecret(dirs, toDirs); err != nil {
+			return err
+		}
+	}
+	logger.Info("[backup] done")
+	return nil
+}
+
+// backupDatabase copies db as seen by a single read transaction, after
+// collecting the immutable files which go with it.
+func backupDatabase(ctx context.Context, db backupDB, tables []string, targetPageSize datasize.ByteSize, readAheadThreads int, incremental bool, logger log.Logger) error {
+	if len(tables) == 0 { // if not partial backup - just drop target dir, to make backup more compact/fast (instead of clean tables)
+		if err := os.RemoveAll(db.to); err != nil {
+			return fmt.Errorf("rm: %w, %s", err, db.to)
+		}
+	}
+	if err := os.MkdirAll(db.to, 0740); err != nil { //owner: rw, group: r, others: -
+		return fmt.Errorf("mkdir: %w, %s", err, db.to)
+	}
+	logger.Info("[backup] start", "label", db.label, "path", db.from)
+	fromDB, toDB := backup.OpenPair(db.from, db.to, db.label, targetPageSize, logger)
+	defer fromDB.Close()
+	defer toDB.Close()
 
-		if len(tables) == 0 { // if not partial backup - just drop target dir, to make backup more compact/fast (instead of clean tables)
-			if err := os.RemoveAll(to); err != nil {
-				return fmt.Errorf("mkdir: %w, %s", err, to)
+	tx, err := fromDB.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if db.filesFrom != "" {
+		if err := backupFiles(db.filesFrom, db.filesTo, incremental, logger); err != nil {
+			return err
+		}
+	}
+	return backup.Kv2kvTx(ctx, fromDB, tx, toDB, tables, readAheadThreads, logger)
+}
+
+func backupFiles(from, to string, incremental bool, logger log.Logger) error {
+	stat, err := backup.CopyFiles(from, to, incremental)
+	if err != nil {
+		return fmt.Errorf("backup of %s: %w", from, err)
+	}
+	logger.Info("[backup] files", "path", from, "linked", stat.Linked, "copied", stat.Copied, "size", common.ByteCount(uint64(stat.Bytes)),
+		"skipped", stat.Skipped, "removed", stat.Removed)
+	return nil
+}
+
+func backupJwtSecret(dirs, toDirs datadir.Dirs) error {
+	secret, err := os.ReadFile(filepath.Join(dirs.DataDir, jwtFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(toDirs.DataDir, jwtFileName), secret, 0600)
+}
+
+// backupToTar streams the backup into a tar archive, without a copy of the
+// databases on disk: their mdbx.dat is read as seen by the read transaction of
+// the backup. The immutable files listed with the same size in prevManifest
+// are left out, and the manifest of the backup is written in the archive and
+// next to it.
+func backupToTar(ctx context.Context, dirs datadir.Dirs, dbs []backupDB, snapshots, jwt bool, target string, compress bool, prevManifest map[string]int64, logger log.Logger) (err error) {
+	var out io.WriteCloser = os.Stdout
+	if target != "-" {
+		if out, err = os.Create(target); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	w := bufio.NewWriterSize(out, 4*1024*1024)
+	var (
+		zw    *zstd.Encoder
+		tarTo io.Writer = w
+	)
+	if compress {
+		if zw, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(runtime.NumCPU())); err != nil {
+			return err
+		}
+		tarTo = zw
+	}
+	a := &backupArchive{tw: tar.NewWriter(tarTo), root: dirs.DataDir, dirs: map[string]bool{}, prevManifest: prevManifest}
+
+	logger.Info("[backup] writing archive", "path", target)
+	for _, db := range dbs {
+		if !dir.FileExist(filepath.Join(db.from, "mdbx.dat")) {
+			if db.filesFrom != "" {
+				if err := a.addFiles(db.filesFrom, logger); err != nil {
+					return err
+				}
 			}
+			continue
 		}
-		if err := os.MkdirAll(to, 0740); err != nil { //owner: rw, group: r, others: -
-			return fmt.Errorf("mkdir: %w, %s", err, to)
+		if err := a.addDatabase(ctx, db, logger); err != nil {
+			return err
 		}
-		logger.Info("[backup] start", "label", label)
-		fromDB, toDB := backup.OpenPair(from, to, label, targetPageSize, logger)
-		if err := backup.Kv2kv(ctx, fromDB, toDB, nil, readAheadThreads, logger); err != nil {
+	}
+	// without the chaindata there is no point in time to stick to
+	if snapshots {
+		if err := a.addFiles(dirs.Snap, logger); err != nil {
 			return err
 		}
 	}
+	if jwt {
+		if err := a.addFile(filepath.Join(dirs.DataDir, jwtFileName)); err != nil {
+			return err
+		}
+	}
+
+	manifest := a.manifest.String()
+	if err := a.tw.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0640, Size: int64(len(manifest)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(a.tw, manifest); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if target != "-" {
+		return os.WriteFile(target+".manifest", []byte(manifest), 0640)
+	}
+	return nil
+}
+
+// backupDataFileAttempts bounds the read transactions begun for the mdbx.dat of
+// a database whose writers keep recycling the meta page of the transaction.
+const backupDataFileAttempts = 10
+
+// backupArchive writes the entries of a tar backup, named after their path
+// relative to the datadir.
+type backupArchive struct {
+	tw           *tar.Writer
+	root         string
+	dirs         map[string]bool // directories with an entry already
+	prevManifest map[string]int64
+	manifest     strings.Builder // immutable files of the backup
+}
+
+// addDatabase writes the mdbx.dat of db as seen by a read transaction, which
+// stays open while the immutable files which go with it are collected.
+func (a *backupArchive) addDatabase(ctx context.Context, db backupDB, logger log.Logger) error {
+	logger.Info("[backup] start", "label", db.label, "path", db.from)
+	fromDB := backup.OpenSource(db.from, db.label, logger)
+	defer fromDB.Close()
+	tx, dataFile, err := backup.BeginDataFile(ctx, db.from, fromDB, backupDataFileAttempts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	defer dataFile.Close()
+	if db.filesFrom != "" {
+		if err := a.addFiles(db.filesFrom, logger); err != nil {
+			return err
+		}
+	}
+	rel, err := a.rel(filepath.Join(db.from, "mdbx.dat"))
+	if err != nil {
+		return err
+	}
+	if err := a.mkdir(filepath.Dir(rel)); err != nil {
+		return err
+	}
+	if err := a.tw.WriteHeader(&tar.Header{Name: filepath.ToSlash(rel), Mode: 0640, Size: dataFile.Size(), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	_, err = dataFile.WriteTo(a.tw)
+	return err
+}
 
+func (a *backupArchive) addFiles(from string, logger log.Logger) error {
+	if !dir.Exist(from) {
+		return nil
+	}
+	var files, skipped int
+	err := backup.WalkFiles(from, func(path, _ string, _ fs.FileInfo) error {
+		added, err := a.addImmutableFile(path)
+		if added {
+			files++
+		} else {
+			skipped++
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("backup of %s: %w", from, err)
+	}
+	logger.Info("[backup] files", "path", from, "archived", files, "skipped", skipped)
 	return nil
 }
+
+func (a *backupArchive) addFile(path string) error {
+	_, err := a.addImmutableFile(path)
+	return err
+}
+
+// addImmutableFile writes the file at path, unless it is gone or it is in the
+// previous backup, and adds it to the manifest.
+func (a *backupArchive) addImmutableFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil // merged away in the meantime, its content is in a newer file
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	rel, err := a.rel(path)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(&a.manifest, "%d %s\n", info.Size(), rel)
+	if size, ok := a.prevManifest[rel]; ok && size == info.Size() {
+		return false, nil // in the previous backup
+	}
+	if err := a.mkdir(filepath.Dir(rel)); err != nil {
+		return false, err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return false, err
+	}
+	hdr.Name = filepath.ToSlash(rel)
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return false, err
+	}
+	_, err = io.CopyN(a.tw, f, info.Size())
+	return err == nil, err
+}
+
+// mkdir writes the entries of rel and its parents which aren't in the archive yet.
+func (a *backupArchive) mkdir(rel string) error {
+	if rel == "." || a.dirs[rel] {
+		return nil
+	}
+	if err := a.mkdir(filepath.Dir(rel)); err != nil {
+		return err
+	}
+	a.dirs[rel] = true
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: filepath.ToSlash(rel) + "/", Mode: 0740, ModTime: time.Now()})
+}
+
+func (a *backupArchive) rel(path string) (string, error) {
+	return filepath.Rel(a.root, path)
+}
+
+// readBackupManifest reads the `<size> <path>` lines of the immutable files of a backup.
+func readBackupManifest(path string) (map[string]int64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := map[string]int64{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		size, rel, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed backup manifest line: %q", line)
+		}
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed backup manifest line: %q: %w", line, err)
+		}
+		manifest[rel] = n
+	}
+	return manifest, nil
+}
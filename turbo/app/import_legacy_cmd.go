@@ -0,0 +1,87 @@
+package app
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/cmd/hack/tool/fromdb"
+	"github.com/erigontech/erigon/cmd/utils"
+	"github.com/erigontech/erigon/core/legacy"
+	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/turbo/debug"
+)
+
+var importLegacyCommand = cli.Command{
+	Action:    MigrateFlags(importLegacy),
+	Name:      "import-legacy",
+	Usage:     "Import the pre-Bedrock history of an OP Stack chain from a legacy export",
+	ArgsUsage: "<filename>",
+	Flags: []cli.Flag{
+		&utils.DataDirFlag,
+	},
+	Description: `
+The import-legacy command imports the blocks, receipts, transaction metadata and
+state history of the legacy (pre-Bedrock) chain from an RLP export of the legacy
+node, optionally gzipped. The datadir must be initialized with the Bedrock genesis.
+Once imported, the legacy heights are served locally instead of being relayed to
+the historical RPC.
+
+Only --history.v3=false datadirs are supported. The import can't resume: the state
+history is written at the end, so an interrupted import has to be run again with the
+whole export, the blocks already imported are verified and kept.`,
+}
+
+func importLegacy(cliCtx *cli.Context) error {
+	if cliCtx.NArg() < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliCtx.Context
+	dirs := datadir.New(cliCtx.String(utils.DataDirFlag.Name))
+	chainDB := dbCfg(kv.ChainDB, dirs.Chaindata).MustOpen()
+	defer chainDB.Close()
+
+	chainConfig := fromdb.ChainConfig(chainDB)
+	if chainConfig == nil || !chainConfig.IsOptimism() {
+		return errors.New("import-legacy requires an initialized OP Stack datadir")
+	}
+
+	cfg := ethconfig.NewSnapCfg(true, false, true)
+	blockSnaps, borSnaps, caplinSnaps, blockRetire, agg, err := openSnaps(ctx, cfg, dirs, chainDB, logger)
+	if err != nil {
+		return err
+	}
+	defer blockSnaps.Close()
+	defer borSnaps.Close()
+	defer caplinSnaps.Close()
+	defer agg.Close()
+	blockReader, _ := blockRetire.IO()
+
+	fn := cliCtx.Args().First()
+	logger.Info("Importing legacy chain", "file", fn)
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	return legacy.Import(ctx, chainDB, blockReader, chainConfig, reader, dirs.Tmp, logger)
+}
@@ -63,9 +63,10 @@ func MakeApp(name string, action cli.ActionFunc, cliFlags []cli.Flag) *cli.App {
 	app.Commands = []*cli.Command{
 		&initCommand,
 		&importCommand,
+		&importLegacyCommand,
 		&snapshotCommand,
 		&supportCommand,
-		//&backupCommand,
+		&backupCommand,
 	}
 	return app
 }
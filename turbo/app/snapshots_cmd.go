@@ -162,6 +162,8 @@ var snapshotCommand = cli.Command{
 			Action: doIntegrity,
 			Flags: joinFlags([]cli.Flag{
 				&utils.DataDirFlag,
+				&SnapshotFromFlag,
+				&SnapshotToFlag,
 			}),
 		},
 		//{
@@ -223,6 +225,13 @@ func doIntegrity(cliCtx *cli.Context) error {
 		return err
 	}
 
+	if chainConfig := fromdb.ChainConfig(chainDB); chainConfig.IsOptimism() {
+		from, to := cliCtx.Uint64(SnapshotFromFlag.Name), cliCtx.Uint64(SnapshotToFlag.Name)
+		if err := integrity.OpBlocks(ctx, chainDB, blockReader, chainConfig, from, to, false); err != nil {
+			return err
+		}
+	}
+
 	//if err := blockReader.IntegrityTxnID(false); err != nil {
 	//	return err
 	//}
@@ -6,6 +6,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/holiman/uint256"
+
 	"github.com/erigontech/erigon-lib/log/v3"
 
 	"github.com/erigontech/erigon/core"
@@ -14,50 +16,144 @@ import (
 
 type BlockBuilderFunc func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error)
 
-// BlockBuilder wraps a goroutine that builds Proof-of-Stake payloads (PoS "mining")
+// BlockBuilder wraps a goroutine that builds Proof-of-Stake payloads (PoS "mining").
+// Until it is interrupted, it re-builds the payload every rebuildInterval with the
+// latest transactions of the txpool, keeping the one of highest value.
 type BlockBuilder struct {
-	interrupt int32
-	syncCond  *sync.Cond
-	result    *types.BlockWithReceipts
-	err       error
+	interrupt   int32
+	interrupted chan struct{}
+	once        sync.Once
+	syncCond    *sync.Cond
+	result      *types.BlockWithReceipts
+	value       *uint256.Int
+	err         error
+	stopped     bool // the result was retrieved, and is no longer replaced
+	done        bool
 }
 
-func NewBlockBuilder(build BlockBuilderFunc, param *core.BlockBuilderParameters) *BlockBuilder {
+// NewBlockBuilder starts building the payload of param. It is built once if
+// rebuildInterval is 0, or if param.NoTxPool is set: only the transactions
+// of param can be included then.
+func NewBlockBuilder(build BlockBuilderFunc, param *core.BlockBuilderParameters, rebuildInterval time.Duration) *BlockBuilder {
 	builder := new(BlockBuilder)
 	builder.syncCond = sync.NewCond(new(sync.Mutex))
+	builder.interrupted = make(chan struct{})
 
 	go func() {
-		t := time.Now()
-		result, err := build(param, &builder.interrupt)
-		if err != nil {
-			log.Warn("Failed to build a block", "err", err)
-		} else {
+		defer func() {
+			builder.syncCond.L.Lock()
+			defer builder.syncCond.L.Unlock()
+			builder.done = true
+			builder.syncCond.Broadcast()
+		}()
+
+		for iteration := 1; ; iteration++ {
+			t := time.Now()
+			result, err := build(param, &builder.interrupt)
+			buildTimer.ObserveDuration(t)
+			if err != nil {
+				log.Warn("Failed to build a block", "iteration", iteration, "err", err)
+				buildsFailed.Inc()
+				builder.syncCond.L.Lock()
+				if builder.result == nil {
+					builder.err = err
+					builder.syncCond.Broadcast()
+				}
+				builder.syncCond.L.Unlock()
+				// the following builds would fail the same way, e.g. when the chain moved
+				return
+			}
+
 			block := result.Block
 			reqLenStr := "nil"
 			if len(result.Requests) == 3 {
 				reqLenStr = fmt.Sprint("Deposit Requests", len(result.Requests[0].RequestData), "Withdrawal Requests", len(result.Requests[1].RequestData), "Consolidation Requests", len(result.Requests[2].RequestData))
 			}
-			log.Info("Built block", "hash", block.Hash(), "height", block.NumberU64(), "txs", len(block.Transactions()), "executionRequests", len(result.Requests), "Requests", reqLenStr, "gas used %", 100*float64(block.GasUsed())/float64(block.GasLimit()), "time", time.Since(t))
-		}
+			value := BlockValue(result)
+			improved := builder.keep(result, value)
+			if improved {
+				buildsImproved.Inc()
+			} else {
+				buildsKept.Inc()
+			}
+			logFn := log.Info
+			if iteration > 1 {
+				logFn = log.Debug
+			}
+			logFn("Built block", "hash", block.Hash(), "height", block.NumberU64(), "txs", len(block.Transactions()), "executionRequests", len(result.Requests), "Requests", reqLenStr, "gas used %", 100*float64(block.GasUsed())/float64(block.GasLimit()), "time", time.Since(t), "iteration", iteration, "value", value, "improved", improved)
 
-		builder.syncCond.L.Lock()
-		defer builder.syncCond.L.Unlock()
-		builder.result = result
-		builder.err = err
-		builder.syncCond.Broadcast()
+			if param.NoTxPool || rebuildInterval <= 0 || !builder.wait(t.Add(rebuildInterval), time.Unix(int64(param.Timestamp), 0)) {
+				return
+			}
+		}
 	}()
 
 	return builder
 }
 
+// keep keeps the result if it is better than the one of the previous builds,
+// and reports whether it did.
+func (b *BlockBuilder) keep(result *types.BlockWithReceipts, value *uint256.Int) bool {
+	b.syncCond.L.Lock()
+	defer b.syncCond.L.Unlock()
+	if b.result != nil && (b.stopped || !better(result, value, b.result, b.value)) {
+		return false
+	}
+	b.result, b.value = result, value
+	b.syncCond.Broadcast()
+	return true
+}
+
+// better reports whether the result of the value is better than the previous
+// one: of a higher value, or of the same value and more gas used, or of the
+// same gas used and more transactions. The tie-breaks keep the re-builds
+// including more transactions when their tips are zero, as on most L2s.
+func better(result *types.BlockWithReceipts, value *uint256.Int, prev *types.BlockWithReceipts, prevValue *uint256.Int) bool {
+	if c := value.Cmp(prevValue); c != 0 {
+		return c > 0
+	}
+	if gasUsed, prevGasUsed := result.Block.GasUsed(), prev.Block.GasUsed(); gasUsed != prevGasUsed {
+		return gasUsed > prevGasUsed
+	}
+	return len(result.Block.Transactions()) > len(prev.Block.Transactions())
+}
+
+// wait waits for the next build at next, and reports whether the payload
+// should be re-built: it isn't once interrupted, or past its timestamp.
+func (b *BlockBuilder) wait(next time.Time, timestamp time.Time) bool {
+	if !next.Before(timestamp) {
+		return false
+	}
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.interrupted:
+		return false
+	}
+}
+
+// Interrupt stops the building of the payload, without waiting for the build
+// in progress.
+func (b *BlockBuilder) Interrupt() {
+	b.once.Do(func() {
+		atomic.StoreInt32(&b.interrupt, 1)
+		close(b.interrupted)
+	})
+}
+
+// Stop stops the building of the payload and returns the one of highest value.
+// It waits for the first build only: a re-build in progress is dropped.
 func (b *BlockBuilder) Stop() (*types.BlockWithReceipts, error) {
-	atomic.StoreInt32(&b.interrupt, 1)
+	b.Interrupt()
 
 	b.syncCond.L.Lock()
 	defer b.syncCond.L.Unlock()
-	for b.result == nil && b.err == nil {
+	for b.result == nil && b.err == nil && !b.done {
 		b.syncCond.Wait()
 	}
+	b.stopped = true
 
 	return b.result, b.err
 }
@@ -71,3 +167,20 @@ func (b *BlockBuilder) Block() *types.Block {
 	}
 	return b.result.Block
 }
+
+// BlockValue returns the expected value to be received by the feeRecipient in wei
+func BlockValue(br *types.BlockWithReceipts) *uint256.Int {
+	baseFee := new(uint256.Int)
+	if header := br.Block.Header(); header.BaseFee != nil {
+		baseFee.SetFromBig(header.BaseFee)
+	}
+	blockValue := uint256.NewInt(0)
+	txs := br.Block.Transactions()
+	for i := range txs {
+		gas := new(uint256.Int).SetUint64(br.Receipts[i].GasUsed)
+		effectiveTip := txs[i].GetEffectiveGasTip(baseFee)
+		txValue := new(uint256.Int).Mul(gas, effectiveTip)
+		blockValue.Add(blockValue, txValue)
+	}
+	return blockValue
+}
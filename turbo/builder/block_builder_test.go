@@ -0,0 +1,138 @@
+package builder
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+)
+
+// blockOfValue returns a block of a single transaction paying gasPrice per gas.
+func blockOfValue(gasPrice uint64) *types.BlockWithReceipts {
+	return blockOfTxs(gasPrice, 1)
+}
+
+// blockOfTxs returns a block of n transactions paying gasPrice per gas.
+func blockOfTxs(gasPrice uint64, n int) *types.BlockWithReceipts {
+	var txs types.Transactions
+	var receipts types.Receipts
+	for i := 0; i < n; i++ {
+		txs = append(txs, types.NewTransaction(uint64(i), libcommon.Address{}, uint256.NewInt(0), 21_000, uint256.NewInt(gasPrice), nil))
+		receipts = append(receipts, &types.Receipt{GasUsed: 21_000})
+	}
+	header := &types.Header{Number: big.NewInt(1), GasLimit: 30_000_000, GasUsed: uint64(n) * 21_000}
+	return &types.BlockWithReceipts{
+		Block:    types.NewBlockFromStorage(libcommon.Hash{}, header, txs, nil, nil),
+		Receipts: receipts,
+	}
+}
+
+func TestBlockBuilderKeepsHighestValue(t *testing.T) {
+	t.Parallel()
+	gasPrices := []uint64{1, 3, 2}
+	var builds atomic.Int32
+	build := func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+		i := int(builds.Add(1)) - 1
+		if i >= len(gasPrices) {
+			return blockOfValue(0), nil
+		}
+		return blockOfValue(gasPrices[i]), nil
+	}
+	param := &core.BlockBuilderParameters{Timestamp: uint64(time.Now().Add(time.Minute).Unix())}
+	b := NewBlockBuilder(build, param, time.Millisecond)
+	require.Eventually(t, func() bool { return builds.Load() > int32(len(gasPrices)) }, 5*time.Second, time.Millisecond)
+
+	result, err := b.Stop()
+	require.NoError(t, err)
+	require.Equal(t, uint256.NewInt(3*21_000), BlockValue(result))
+}
+
+func TestBlockBuilderKeepsMoreTxsOfZeroTip(t *testing.T) {
+	t.Parallel()
+	counts := []int{1, 3, 2}
+	var builds atomic.Int32
+	build := func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+		i := int(builds.Add(1)) - 1
+		if i >= len(counts) {
+			return blockOfTxs(0, 0), nil
+		}
+		return blockOfTxs(0, counts[i]), nil
+	}
+	param := &core.BlockBuilderParameters{Timestamp: uint64(time.Now().Add(time.Minute).Unix())}
+	b := NewBlockBuilder(build, param, time.Millisecond)
+	require.Eventually(t, func() bool { return builds.Load() > int32(len(counts)) }, 5*time.Second, time.Millisecond)
+
+	result, err := b.Stop()
+	require.NoError(t, err)
+	require.True(t, BlockValue(result).IsZero())
+	require.Len(t, result.Block.Transactions(), 3)
+}
+
+func TestBlockBuilderBuildsOnce(t *testing.T) {
+	t.Parallel()
+	for name, tt := range map[string]struct {
+		noTxPool        bool
+		rebuildInterval time.Duration
+		timestamp       time.Time
+	}{
+		"no txpool":        {noTxPool: true, rebuildInterval: time.Millisecond, timestamp: time.Now().Add(time.Minute)},
+		"no rebuild":       {rebuildInterval: 0, timestamp: time.Now().Add(time.Minute)},
+		"past timestamp":   {rebuildInterval: time.Millisecond, timestamp: time.Now().Add(-time.Minute)},
+		"interval too big": {rebuildInterval: time.Hour, timestamp: time.Now().Add(time.Minute)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var builds atomic.Int32
+			build := func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+				builds.Add(1)
+				return blockOfValue(1), nil
+			}
+			param := &core.BlockBuilderParameters{NoTxPool: tt.noTxPool, Timestamp: uint64(tt.timestamp.Unix())}
+			b := NewBlockBuilder(build, param, tt.rebuildInterval)
+			require.Eventually(t, func() bool { return b.Block() != nil }, 5*time.Second, time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+			require.Equal(t, int32(1), builds.Load())
+
+			result, err := b.Stop()
+			require.NoError(t, err)
+			require.NotNil(t, result)
+		})
+	}
+}
+
+func TestBlockBuilderStop(t *testing.T) {
+	t.Parallel()
+	var builds atomic.Int32
+	failing := errors.New("chain moved")
+	build := func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+		if builds.Add(1) > 1 {
+			return nil, failing
+		}
+		return blockOfValue(1), nil
+	}
+	param := &core.BlockBuilderParameters{Timestamp: uint64(time.Now().Add(time.Minute).Unix())}
+	b := NewBlockBuilder(build, param, time.Millisecond)
+	require.Eventually(t, func() bool { return builds.Load() == 2 }, 5*time.Second, time.Millisecond)
+
+	// a failed re-build doesn't discard the previous payload
+	result, err := b.Stop()
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(2), builds.Load())
+
+	// while the error of the first build is returned
+	b = NewBlockBuilder(func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+		return nil, failing
+	}, param, time.Millisecond)
+	_, err = b.Stop()
+	require.ErrorIs(t, err, failing)
+}
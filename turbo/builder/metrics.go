@@ -0,0 +1,12 @@
+package builder
+
+import (
+	"github.com/erigontech/erigon-lib/metrics"
+)
+
+var (
+	buildTimer     = metrics.GetOrCreateSummary("block_builder_build_seconds")
+	buildsImproved = metrics.GetOrCreateCounter(`block_builder_builds_total{result="improved"}`)
+	buildsKept     = metrics.GetOrCreateCounter(`block_builder_builds_total{result="kept"}`)
+	buildsFailed   = metrics.GetOrCreateCounter(`block_builder_builds_total{result="failed"}`)
+)
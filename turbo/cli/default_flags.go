@@ -28,6 +28,9 @@ var DefaultFlags = []cli.Flag{
 	&utils.TxPoolLifetimeFlag,
 	&utils.TxPoolTraceSendersFlag,
 	&utils.TxPoolCommitEveryFlag,
+	&utils.TxPoolPolicyFileFlag,
+	&utils.TxPoolPolicyReloadEveryFlag,
+	&utils.TxPoolBundleSlotsFlag,
 	&PruneFlag,
 	&PruneHistoryFlag,
 	&PruneReceiptFlag,
@@ -78,6 +81,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.RpcGasCapFlag,
 	&utils.RpcBatchLimit,
 	&utils.RpcReturnDataLimit,
+	&utils.RpcStreamMaxResultsFlag,
+	&utils.RpcStreamMaxBlocksFlag,
 	&utils.AllowUnprotectedTxs,
 	&utils.RpcMaxGetProofRewindBlockCount,
 	&utils.RPCGlobalTxFeeCapFlag,
@@ -98,6 +103,7 @@ var DefaultFlags = []cli.Flag{
 	&RpcSubscriptionFiltersMaxTxsFlag,
 	&RpcSubscriptionFiltersMaxAddressesFlag,
 	&RpcSubscriptionFiltersMaxTopicsFlag,
+	&FlashblocksURLFlag,
 
 	&utils.SnapKeepBlocksFlag,
 	&utils.SnapStopFlag,
@@ -114,6 +120,7 @@ var DefaultFlags = []cli.Flag{
 	&utils.TorrentVerbosityFlag,
 	&utils.ListenPortFlag,
 	&utils.P2pProtocolVersionFlag,
+	&utils.P2pProtocolSnapFlag,
 	&utils.P2pProtocolAllowedPorts,
 	&utils.NATFlag,
 	&utils.NoDiscoverFlag,
@@ -128,6 +135,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.MaxPeersFlag,
 	&utils.ChainFlag,
 	&utils.DeveloperPeriodFlag,
+	&utils.DeveloperOptimismFlag,
+	&utils.DeveloperOptimismMintFlag,
 	&utils.VMEnableDebugFlag,
 	&utils.NetworkIdFlag,
 	&utils.FakePoWFlag,
@@ -148,8 +157,10 @@ var DefaultFlags = []cli.Flag{
 	&utils.MinerEtherbaseFlag,
 	&utils.MinerExtraDataFlag,
 	&utils.MinerNoVerfiyFlag,
+	&utils.MinerExecWorkersFlag,
 	&utils.MinerSigningKeyFileFlag,
 	&utils.MinerRecommitIntervalFlag,
+	&utils.MinerRebuildIntervalFlag,
 	&utils.SentryAddrFlag,
 	&utils.SentryLogPeerInfoFlag,
 	&utils.DownloaderAddrFlag,
@@ -177,7 +188,24 @@ var DefaultFlags = []cli.Flag{
 	&utils.RollupSequencerHTTPFlag,
 	&utils.RollupHistoricalRPCFlag,
 	&utils.RollupHistoricalRPCTimeoutFlag,
+	&utils.RollupInteropRPCFlag,
+	&utils.RollupInteropMinSafetyFlag,
+	&utils.RollupInteropTimeoutFlag,
+	&utils.RollupDepositIndexFlag,
+	&utils.RollupL1RPCFlag,
+	&utils.ShadowRPCFlag,
+	&utils.ShadowDirFlag,
+	&utils.FlashblocksAddrFlag,
+	&utils.FlashblocksIntervalFlag,
+	&utils.FlashblocksJWTSecretFlag,
+	&utils.ExternalBuildersFlag,
+	&utils.ExternalBuilderJWTSecretFlag,
+	&utils.ExternalBuilderTimeoutFlag,
+	&utils.ExternalBuilderSelectionFlag,
 	&utils.RollupHaltOnIncompatibleProtocolVersionFlag,
+	&utils.SignerKeystoreFlag,
+	&utils.SignerPasswordFlag,
+	&utils.SignerExternalFlag,
 
 	&utils.LightClientDiscoveryAddrFlag,
 	&utils.LightClientDiscoveryPortFlag,
@@ -186,6 +214,7 @@ var DefaultFlags = []cli.Flag{
 	&utils.SentinelPortFlag,
 
 	&utils.OtsSearchMaxCapFlag,
+	&utils.OtsTokenIndicesFlag,
 
 	&utils.SilkwormExecutionFlag,
 	&utils.SilkwormRpcDaemonFlag,
@@ -214,6 +243,9 @@ var DefaultFlags = []cli.Flag{
 	&utils.CaplinBlobBackfillingFlag,
 	&utils.CaplinDisableBlobPruningFlag,
 	&utils.CaplinArchiveFlag,
+	&utils.CaplinBlobArchiveFlag,
+	&utils.CaplinBlobArchiveSlotsFlag,
+	&utils.CaplinBlobArchiveInboxesFlag,
 
 	&utils.TrustedSetupFile,
 	&utils.RPCSlowFlag,
@@ -272,6 +272,11 @@ var (
 		Usage: "Maximum number of topics per subscription to filter logs by.",
 		Value: rpchelper.DefaultFiltersConfig.RpcSubscriptionFiltersMaxTopics,
 	}
+	FlashblocksURLFlag = cli.StringFlag{
+		Name:  "flashblocks.url",
+		Usage: "Websocket URL of the sequencer flashblocks stream, served as the pending block on RPC (e.g. ws://sequencer:1111)",
+		Value: "",
+	}
 
 	TxPoolCommitEvery = cli.DurationFlag{
 		Name:  "txpool.commit.every",
@@ -520,6 +525,8 @@ func setEmbeddedRpcDaemon(ctx *cli.Context, cfg *nodecfg.Config, logger log.Logg
 			RpcSubscriptionFiltersMaxTxs:       ctx.Int(RpcSubscriptionFiltersMaxTxsFlag.Name),
 			RpcSubscriptionFiltersMaxAddresses: ctx.Int(RpcSubscriptionFiltersMaxAddressesFlag.Name),
 			RpcSubscriptionFiltersMaxTopics:    ctx.Int(RpcSubscriptionFiltersMaxTopicsFlag.Name),
+			FlashblocksURL:                     ctx.String(FlashblocksURLFlag.Name),
+			FlashblocksJWTSecret:               ctx.String(utils.FlashblocksJWTSecretFlag.Name),
 		},
 		Gascap:                      ctx.Uint64(utils.RpcGasCapFlag.Name),
 		Feecap:                      ctx.Float64(utils.RPCGlobalTxFeeCapFlag.Name),
@@ -527,6 +534,8 @@ func setEmbeddedRpcDaemon(ctx *cli.Context, cfg *nodecfg.Config, logger log.Logg
 		TraceCompatibility:          ctx.Bool(utils.RpcTraceCompatFlag.Name),
 		BatchLimit:                  ctx.Int(utils.RpcBatchLimit.Name),
 		ReturnDataLimit:             ctx.Int(utils.RpcReturnDataLimit.Name),
+		StreamMaxResults:            ctx.Uint64(utils.RpcStreamMaxResultsFlag.Name),
+		StreamMaxBlocks:             ctx.Uint64(utils.RpcStreamMaxBlocksFlag.Name),
 		AllowUnprotectedTxs:         ctx.Bool(utils.AllowUnprotectedTxs.Name),
 		MaxGetProofRewindBlockCount: ctx.Int(utils.RpcMaxGetProofRewindBlockCount.Name),
 
@@ -537,6 +546,7 @@ func setEmbeddedRpcDaemon(ctx *cli.Context, cfg *nodecfg.Config, logger log.Logg
 		RollupSequencerHTTP:        ctx.String(utils.RollupSequencerHTTPFlag.Name),
 		RollupHistoricalRPC:        ctx.String(utils.RollupHistoricalRPCFlag.Name),
 		RollupHistoricalRPCTimeout: ctx.Duration(utils.RollupHistoricalRPCTimeoutFlag.Name),
+		RollupL1RPC:                ctx.String(utils.RollupL1RPCFlag.Name),
 
 		StateCache:          kvcache.DefaultCoherentConfig,
 		RPCSlowLogThreshold: ctx.Duration(utils.RPCSlowFlag.Name),
@@ -0,0 +1,214 @@
+package engineapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+)
+
+const (
+	// DevSequencerDefaultPeriod is the block time of --dev.optimism when
+	// --dev.period is not set.
+	DevSequencerDefaultPeriod = 2 * time.Second
+	// DevSequencerRebuildInterval re-builds the payloads of --dev.optimism, unless
+	// --miner.rebuildinterval is set, to include the transactions sent until the
+	// end of the block time.
+	DevSequencerRebuildInterval = 250 * time.Millisecond
+
+	// the synthetic L1 origin advances every devL1BlockTime seconds
+	devL1BlockTime = 12
+	// L1 fee scalars of the OP Stack chains after Ecotone
+	devBaseFeeScalar     = 1368
+	devBlobBaseFeeScalar = 810949
+	devL1InfoDepositGas  = 1_000_000
+)
+
+var (
+	devL1BaseFee     = uint256.NewInt(params.GWei)
+	devL1BlobBaseFee = uint256.NewInt(1)
+	// DevMintAmount is minted to every --dev.optimism.mint address.
+	DevMintAmount = new(uint256.Int).Mul(uint256.NewInt(1000), uint256.NewInt(params.Ether))
+)
+
+// DevSequencer replaces op-node on an OP Stack dev chain: it proposes a block
+// every period through the same engine API calls as op-node, each opened by
+// the L1 attributes deposit of a synthetic L1 origin. All its blocks are
+// immediately safe and finalized.
+type DevSequencer struct {
+	engine *EngineServer
+	period time.Duration
+	// mint are credited DevMintAmount by deposits in the first block
+	// sequenced after the start
+	mint   []libcommon.Address
+	logger log.Logger
+}
+
+func NewDevSequencer(engine *EngineServer, period time.Duration, mint []libcommon.Address, logger log.Logger) *DevSequencer {
+	if period <= 0 {
+		period = DevSequencerDefaultPeriod
+	}
+	return &DevSequencer{engine: engine, period: period, mint: mint, logger: logger}
+}
+
+// Run sequences blocks until the context is cancelled.
+func (d *DevSequencer) Run(ctx context.Context) {
+	d.logger.Info("[dev.optimism] Sequencing blocks", "period", d.period, "mint", len(d.mint))
+	for {
+		block, err := d.sequence(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			d.logger.Warn("[dev.optimism] Failed to sequence block", "err", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.period):
+			}
+			continue
+		}
+		d.mint = nil
+		d.logger.Info("[dev.optimism] Sequenced block", "number", uint64(block.BlockNumber), "hash", block.BlockHash, "txs", len(block.Transactions), "gasUsed", uint64(block.GasUsed))
+	}
+}
+
+// sequence builds the next block on top of the current head, and makes it the
+// new head.
+func (d *DevSequencer) sequence(ctx context.Context) (*engine_types.ExecutionPayload, error) {
+	head := d.engine.chainRW.CurrentHeader(ctx)
+	if head == nil {
+		return nil, errors.New("no current header")
+	}
+	headBlock := d.engine.chainRW.GetBlockByHash(ctx, head.Hash())
+	if headBlock == nil {
+		return nil, fmt.Errorf("head block %x not found", head.Hash())
+	}
+	timestamp := max(head.Time+uint64(d.period/time.Second), uint64(time.Now().Unix()))
+	attributes, err := devPayloadAttributes(d.engine.config, headBlock, timestamp, d.mint)
+	if err != nil {
+		return nil, err
+	}
+
+	forkchoice := &engine_types.ForkChoiceState{HeadHash: head.Hash(), SafeBlockHash: head.Hash(), FinalizedBlockHash: head.Hash()}
+	resp, err := d.engine.ForkchoiceUpdatedV3(ctx, forkchoice, attributes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.PayloadId == nil {
+		return nil, fmt.Errorf("no payload built: %s", resp.PayloadStatus.Status)
+	}
+
+	// the payload is re-built with the txpool transactions until its time, see
+	// DevSequencerRebuildInterval
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Until(time.Unix(int64(timestamp), 0))):
+	}
+
+	payload, err := d.engine.GetPayloadV3(ctx, *resp.PayloadId)
+	if err != nil {
+		return nil, err
+	}
+	block := payload.ExecutionPayload
+	status, err := d.engine.NewPayloadV3(ctx, block, []libcommon.Hash{}, attributes.ParentBeaconBlockRoot)
+	if err != nil {
+		return nil, err
+	}
+	if status.Status != engine_types.ValidStatus {
+		return nil, fmt.Errorf("new payload %x: %s %v", block.BlockHash, status.Status, status.ValidationError)
+	}
+	forkchoice = &engine_types.ForkChoiceState{HeadHash: block.BlockHash, SafeBlockHash: block.BlockHash, FinalizedBlockHash: block.BlockHash}
+	if resp, err = d.engine.ForkchoiceUpdatedV3(ctx, forkchoice, nil); err != nil {
+		return nil, err
+	}
+	if resp.PayloadStatus.Status != engine_types.ValidStatus {
+		return nil, fmt.Errorf("forkchoice updated %x: %s %v", block.BlockHash, resp.PayloadStatus.Status, resp.PayloadStatus.ValidationError)
+	}
+	return block, nil
+}
+
+// devPayloadAttributes returns the attributes of the block following head at
+// the given timestamp, as op-node would derive them from an L1 chain of a
+// block every devL1BlockTime seconds, with a deposit minting DevMintAmount to
+// each of mint.
+func devPayloadAttributes(config *chain.Config, head *types.Block, timestamp uint64, mint []libcommon.Address) (*engine_types.PayloadAttributes, error) {
+	l1Number := timestamp / devL1BlockTime
+	var l1Hash libcommon.Hash
+	binary.BigEndian.PutUint64(l1Hash[24:], l1Number)
+	info := &opstack.L1BlockInfo{
+		Number:      l1Number,
+		Time:        l1Number * devL1BlockTime,
+		BaseFee:     devL1BaseFee,
+		BlobBaseFee: devL1BlobBaseFee,
+		Hash:        crypto.Keccak256Hash(l1Hash[:]),
+	}
+	if txs := head.Transactions(); len(txs) > 0 && txs[0].Type() == types.DepositTxType {
+		parent, err := opstack.ParseL1BlockInfo(txs[0].GetData())
+		if err != nil {
+			return nil, fmt.Errorf("head L1 attributes: %w", err)
+		}
+		if parent.Number == info.Number {
+			info.SequenceNumber = parent.SequenceNumber + 1
+		}
+	}
+
+	deposits := []types.Transaction{&types.DepositTx{
+		SourceHash: opstack.L1InfoDepositSource(info.Hash, info.SequenceNumber),
+		From:       opstack.L1InfoDepositorAddress,
+		To:         &opstack.L1BlockAddr,
+		Value:      new(uint256.Int),
+		Gas:        devL1InfoDepositGas,
+		Data:       info.MarshalEcotone(devBaseFeeScalar, devBlobBaseFeeScalar),
+	}}
+	for i, addr := range mint {
+		to := addr
+		deposits = append(deposits, &types.DepositTx{
+			SourceHash: opstack.UserDepositSource(info.Hash, uint64(i)),
+			From:       addr,
+			To:         &to,
+			Mint:       DevMintAmount.Clone(),
+			Value:      new(uint256.Int),
+			Gas:        params.TxGas,
+		})
+	}
+
+	attributes := &engine_types.PayloadAttributes{
+		Timestamp:             hexutil.Uint64(timestamp),
+		PrevRandao:            info.Hash,
+		SuggestedFeeRecipient: params.OptimismSequencerFeeRecipient,
+		Withdrawals:           []*types.Withdrawal{},
+		ParentBeaconBlockRoot: &libcommon.Hash{},
+		Transactions:          make([]hexutility.Bytes, len(deposits)),
+		GasLimit:              (*hexutil.Uint64)(&head.Header().GasLimit),
+	}
+	for i, deposit := range deposits {
+		var buf bytes.Buffer
+		if err := deposit.MarshalBinary(&buf); err != nil {
+			return nil, err
+		}
+		attributes.Transactions[i] = buf.Bytes()
+	}
+	if config.IsHolocene(timestamp) {
+		// the chain config defaults
+		attributes.EIP1559Params = make(hexutility.Bytes, 8)
+	}
+	return attributes, nil
+}
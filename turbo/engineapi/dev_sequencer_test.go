@@ -0,0 +1,71 @@
+package engineapi
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/consensus/misc"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/params"
+)
+
+func TestDevPayloadAttributes(t *testing.T) {
+	config := params.AllOptimismProtocolChanges
+	genesis := types.NewBlockWithHeader(&types.Header{Number: new(big.Int), GasLimit: 30_000_000})
+	mint := []libcommon.Address{{1}, {2}}
+
+	decode := func(attributes [][]byte) ([]*types.DepositTx, *opstack.L1BlockInfo) {
+		deposits := make([]*types.DepositTx, len(attributes))
+		for i, encoded := range attributes {
+			txn, err := types.DecodeTransaction(encoded)
+			require.NoError(t, err)
+			deposits[i] = txn.(*types.DepositTx)
+		}
+		info, err := opstack.ParseL1BlockInfo(deposits[0].Data)
+		require.NoError(t, err)
+		return deposits, info
+	}
+
+	attributes, err := devPayloadAttributes(config, genesis, 2*devL1BlockTime+1, mint)
+	require.NoError(t, err)
+	require.Equal(t, uint64(30_000_000), uint64(*attributes.GasLimit))
+	require.Equal(t, params.OptimismSequencerFeeRecipient, attributes.SuggestedFeeRecipient)
+	require.NoError(t, misc.ValidateHolocene1559Params(attributes.EIP1559Params))
+	txs := make([][]byte, len(attributes.Transactions))
+	for i, txn := range attributes.Transactions {
+		txs[i] = txn
+	}
+	deposits, info := decode(txs)
+	require.Len(t, deposits, 3)
+	require.Equal(t, opstack.L1InfoDepositorAddress, deposits[0].From)
+	require.Equal(t, opstack.L1BlockAddr, *deposits[0].To)
+	require.Equal(t, uint64(2), info.Number)
+	require.Equal(t, uint64(2*devL1BlockTime), info.Time)
+	require.Zero(t, info.SequenceNumber)
+	require.Equal(t, opstack.L1InfoDepositSource(info.Hash, 0), deposits[0].SourceHash)
+	for i, addr := range mint {
+		require.Equal(t, addr, deposits[i+1].From)
+		require.Equal(t, DevMintAmount, deposits[i+1].Mint)
+		require.Equal(t, opstack.UserDepositSource(info.Hash, uint64(i)), deposits[i+1].SourceHash)
+	}
+
+	// the sequence number increases until the L1 origin changes
+	head := types.NewBlock(&types.Header{Number: big.NewInt(1), GasLimit: 30_000_000}, []types.Transaction{deposits[0]}, nil, nil, nil)
+	attributes, err = devPayloadAttributes(config, head, 2*devL1BlockTime+3, nil)
+	require.NoError(t, err)
+	require.Len(t, attributes.Transactions, 1)
+	_, next := decode([][]byte{attributes.Transactions[0]})
+	require.Equal(t, info.Hash, next.Hash)
+	require.Equal(t, uint64(1), next.SequenceNumber)
+
+	attributes, err = devPayloadAttributes(config, head, 3*devL1BlockTime, nil)
+	require.NoError(t, err)
+	_, next = decode([][]byte{attributes.Transactions[0]})
+	require.Equal(t, uint64(3), next.Number)
+	require.Zero(t, next.SequenceNumber)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 	libstate "github.com/erigontech/erigon-lib/state"
 
+	"github.com/erigontech/erigon/accounts/signer"
 	"github.com/erigontech/erigon/cmd/rpcdaemon/cli"
 	"github.com/erigontech/erigon/cmd/rpcdaemon/cli/httpcfg"
 	"github.com/erigontech/erigon/common"
@@ -36,14 +37,17 @@ import (
 	"github.com/erigontech/erigon/consensus/misc"
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/rlp"
 	"github.com/erigontech/erigon/rpc"
 	"github.com/erigontech/erigon/turbo/engineapi/engine_block_downloader"
 	"github.com/erigontech/erigon/turbo/engineapi/engine_helpers"
 	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
 	"github.com/erigontech/erigon/turbo/execution/eth1/eth1_chain_reader.go"
+	"github.com/erigontech/erigon/turbo/forensics"
 	"github.com/erigontech/erigon/turbo/jsonrpc"
 	"github.com/erigontech/erigon/turbo/rpchelper"
 	"github.com/erigontech/erigon/turbo/services"
+	"github.com/erigontech/erigon/turbo/shards"
 	"github.com/erigontech/erigon/turbo/stages/headerdownload"
 )
 
@@ -61,12 +65,24 @@ type EngineServer struct {
 	lock    sync.Mutex
 	logger  log.Logger
 
+	// events notifies the RPC subscribers of the new safe and finalized
+	// blocks of the forkchoice updates, the last of which are kept under lock.
+	events                  *shards.Events
+	safeHash, finalizedHash libcommon.Hash
+
+	// externalBuilders are the external builders of the payloads proposed by
+	// the sequencer, nil if none
+	externalBuilders *ExternalBuilders
+	// badBlocks records the forensics bundles of the payloads rejected before
+	// their validation, nil if none
+	badBlocks *forensics.Recorder
+
 	nodeCloser func() error
 }
 
 const fcuTimeout = 1000 // according to mathematics: 1000 millisecods = 1 second
 
-func NewEngineServer(logger log.Logger, config *chain.Config, executionService execution.ExecutionClient,
+func NewEngineServer(logger log.Logger, config *chain.Config, executionService execution.ExecutionClient, events *shards.Events, externalBuilders *ExternalBuilders, badBlocks *forensics.Recorder,
 	hd *headerdownload.HeaderDownload,
 	blockDownloader *engine_block_downloader.EngineBlockDownloader, test bool, proposing bool, ethConfig *ethconfig.Config, nodeCloser func() error) *EngineServer {
 	chainRW := eth1_chain_reader.NewChainReaderEth1(config, executionService, fcuTimeout)
@@ -80,6 +96,9 @@ func NewEngineServer(logger log.Logger, config *chain.Config, executionService e
 		proposing:        proposing,
 		hd:               hd,
 		nodeCloser:       nodeCloser,
+		events:           events,
+		externalBuilders: externalBuilders,
+		badBlocks:        badBlocks,
 	}
 }
 
@@ -96,6 +115,7 @@ func (e *EngineServer) Start(
 	txPool txpool.TxpoolClient,
 	mining txpool.MiningClient,
 	seqRPCService, historicalRPCService *rpc.Client,
+	accountSigner signer.Signer,
 ) {
 	base := jsonrpc.NewBaseApi(filters, stateCache, blockReader, agg, httpConfig.WithDatadir, httpConfig.EvmCallTimeout, engineReader, httpConfig.Dirs, seqRPCService, historicalRPCService)
 
@@ -115,6 +135,16 @@ func (e *EngineServer) Start(
 			Service:   EngineAPI(e),
 			Version:   "1.0",
 		}}
+	// the signer methods are only served on this authenticated endpoint, and
+	// override the deprecated ones of the eth namespace
+	if accountSigner != nil {
+		apiList = append(apiList, rpc.API{
+			Namespace: "eth",
+			Public:    true,
+			Service:   jsonrpc.SignerAPI(jsonrpc.NewSignerAPI(ethImpl, accountSigner)),
+			Version:   "1.0",
+		})
+	}
 
 	if err := cli.StartRpcServerWithJwtAuthentication(ctx, httpConfig, apiList, e.logger); err != nil {
 		e.logger.Error(err.Error())
@@ -232,6 +262,7 @@ func (s *EngineServer) newPayload(ctx context.Context, req *engine_types.Executi
 	blockHash := req.BlockHash
 	if header.Hash() != blockHash {
 		s.logger.Error("[NewPayload] invalid block hash", "stated", blockHash, "actual", header.Hash())
+		s.recordBadPayload(req, nil, fmt.Errorf("invalid block hash: stated %x, actual %x", blockHash, header.Hash()))
 		return &engine_types.PayloadStatus{
 			Status:          engine_types.InvalidStatus,
 			ValidationError: engine_types.NewStringifiedErrorFromString("invalid block hash"),
@@ -241,6 +272,7 @@ func (s *EngineServer) newPayload(ctx context.Context, req *engine_types.Executi
 	for _, txn := range req.Transactions {
 		if types.TypedTransactionMarshalledAsRlpString(txn) {
 			s.logger.Warn("[NewPayload] typed txn marshalled as RLP string", "txn", common.Bytes2Hex(txn))
+			s.recordBadPayload(req, nil, errors.New("typed txn marshalled as RLP string"))
 			return &engine_types.PayloadStatus{
 				Status:          engine_types.InvalidStatus,
 				ValidationError: engine_types.NewStringifiedErrorFromString("typed txn marshalled as RLP string"),
@@ -251,6 +283,7 @@ func (s *EngineServer) newPayload(ctx context.Context, req *engine_types.Executi
 	transactions, err := types.DecodeTransactions(txs)
 	if err != nil {
 		s.logger.Warn("[NewPayload] failed to decode transactions", "err", err)
+		s.recordBadPayload(req, nil, err)
 		return &engine_types.PayloadStatus{
 			Status:          engine_types.InvalidStatus,
 			ValidationError: engine_types.NewStringifiedError(err),
@@ -267,6 +300,7 @@ func (s *EngineServer) newPayload(ctx context.Context, req *engine_types.Executi
 			if !bad {
 				latestValidHash = req.ParentHash
 			}
+			s.recordBadPayload(req, types.NewBlockFromStorage(blockHash, &header, transactions, nil, withdrawals), err)
 			return &engine_types.PayloadStatus{
 				Status:          engine_types.InvalidStatus,
 				ValidationError: engine_types.NewStringifiedErrorFromString("blobs/blobgas exceeds max"),
@@ -274,6 +308,7 @@ func (s *EngineServer) newPayload(ctx context.Context, req *engine_types.Executi
 			}, nil
 		}
 		if errors.Is(err, ethutils.ErrMismatchBlobHashes) || errors.Is(err, ethutils.ErrInvalidVersiondHash) {
+			s.recordBadPayload(req, types.NewBlockFromStorage(blockHash, &header, transactions, nil, withdrawals), err)
 			return &engine_types.PayloadStatus{
 				Status:          engine_types.InvalidStatus,
 				ValidationError: engine_types.NewStringifiedErrorFromString(err.Error()),
@@ -298,6 +333,7 @@ func (s *EngineServer) newPayload(ctx context.Context, req *engine_types.Executi
 	payloadStatus, err := s.HandleNewPayload(ctx, "NewPayload", block, expectedBlobHashes)
 	if err != nil {
 		if errors.Is(err, consensus.ErrInvalidBlock) {
+			s.recordBadPayload(req, block, err)
 			return &engine_types.PayloadStatus{
 				Status:          engine_types.InvalidStatus,
 				ValidationError: engine_types.NewStringifiedError(err),
@@ -310,10 +346,26 @@ func (s *EngineServer) newPayload(ctx context.Context, req *engine_types.Executi
 	if payloadStatus.CriticalError != nil {
 		return nil, payloadStatus.CriticalError
 	}
+	if s.externalBuilders != nil && payloadStatus.Status == engine_types.ValidStatus {
+		s.externalBuilders.newPayload(req, expectedBlobHashes, parentBeaconBlockRoot, executionRequests, version)
+	}
 
 	return payloadStatus, nil
 }
 
+// recordBadPayload records the forensics bundle of a payload rejected before
+// its validation, of its block when the payload could be decoded into one.
+func (s *EngineServer) recordBadPayload(req *engine_types.ExecutionPayload, block *types.Block, err error) {
+	if s.badBlocks == nil {
+		return
+	}
+	if block != nil {
+		s.badBlocks.RecordBlock(block, err)
+		return
+	}
+	s.badBlocks.RecordPayload(uint64(req.BlockNumber), req.BlockHash, req.ParentHash, uint64(req.Timestamp), req, err)
+}
+
 // Check if we can quickly determine the status of a newPayload or forkchoiceUpdated.
 func (s *EngineServer) getQuickPayloadStatusIfPossible(ctx context.Context, blockHash libcommon.Hash, blockNumber uint64, parentHash libcommon.Hash, forkchoiceMessage *engine_types.ForkChoiceState, newPayload bool) (*engine_types.PayloadStatus, error) {
 	// Determine which prefix to use for logs
@@ -439,6 +491,15 @@ func (s *EngineServer) getQuickPayloadStatusIfPossible(ctx context.Context, bloc
 
 // EngineGetPayload retrieves previously assembled payload (Validators only)
 func (s *EngineServer) getPayload(ctx context.Context, payloadId uint64, version clparams.StateVersion) (*engine_types.GetPayloadResponse, error) {
+	response, err := s.getLocalPayload(ctx, payloadId, version)
+	if err != nil || s.externalBuilders == nil {
+		return response, err
+	}
+	return s.selectPayload(ctx, payloadId, response, version), nil
+}
+
+// getLocalPayload retrieves the payload assembled by the local block builder
+func (s *EngineServer) getLocalPayload(ctx context.Context, payloadId uint64, version clparams.StateVersion) (*engine_types.GetPayloadResponse, error) {
 	if !s.proposing {
 		return nil, fmt.Errorf("execution layer not running as a proposer. enable proposer by taking out the --proposer.disable flag on startup")
 	}
@@ -545,6 +606,12 @@ func (s *EngineServer) forkchoiceUpdated(ctx context.Context, forkchoiceState *e
 			return nil, status.CriticalError
 		}
 	}
+	if status.Status == engine_types.ValidStatus {
+		s.notifyForkchoice(ctx, forkchoiceState)
+		if s.externalBuilders != nil && payloadAttributes == nil {
+			s.externalBuilders.forkchoiceUpdated(forkchoiceState, nil, 0, version)
+		}
+	}
 
 	// No need for payload building
 	if payloadAttributes == nil || status.Status != engine_types.ValidStatus {
@@ -633,6 +700,9 @@ func (s *EngineServer) forkchoiceUpdated(ctx context.Context, forkchoiceState *e
 		s.logger.Warn("[ForkChoiceUpdated] Execution Service busy, could not fulfil Assemble Block request", "req.parentHash", req.ParentHash)
 		return &engine_types.ForkChoiceUpdatedResponse{PayloadStatus: &engine_types.PayloadStatus{Status: engine_types.SyncingStatus}, PayloadId: nil}, nil
 	}
+	if s.externalBuilders != nil {
+		s.externalBuilders.forkchoiceUpdated(forkchoiceState, payloadAttributes, resp.Id, version)
+	}
 
 	return &engine_types.ForkChoiceUpdatedResponse{
 		PayloadStatus: &engine_types.PayloadStatus{
@@ -1097,6 +1167,43 @@ func (e *EngineServer) HandlesForkChoice(
 	return payloadStatus, nil
 }
 
+// notifyForkchoice notifies the subscribers of the safe and finalized blocks
+// of a valid forkchoice update, when they changed. With Optimism the safe
+// block may also move backwards.
+func (e *EngineServer) notifyForkchoice(ctx context.Context, forkChoice *engine_types.ForkChoiceState) {
+	if e.events == nil {
+		return
+	}
+	if forkChoice.SafeBlockHash != e.safeHash {
+		if headerRlp := e.forkchoiceHeaderRlp(ctx, forkChoice.SafeBlockHash); headerRlp != nil {
+			e.events.OnNewSafeHeader(headerRlp)
+			e.safeHash = forkChoice.SafeBlockHash
+		}
+	}
+	if forkChoice.FinalizedBlockHash != e.finalizedHash {
+		if headerRlp := e.forkchoiceHeaderRlp(ctx, forkChoice.FinalizedBlockHash); headerRlp != nil {
+			e.events.OnNewFinalizedHeader(headerRlp)
+			e.finalizedHash = forkChoice.FinalizedBlockHash
+		}
+	}
+}
+
+func (e *EngineServer) forkchoiceHeaderRlp(ctx context.Context, hash libcommon.Hash) []byte {
+	if hash == (libcommon.Hash{}) {
+		return nil
+	}
+	header := e.chainRW.GetHeaderByHash(ctx, hash)
+	if header == nil {
+		return nil
+	}
+	headerRlp, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		e.logger.Warn("[ForkChoiceUpdated] could not encode header", "hash", hash, "err", err)
+		return nil
+	}
+	return headerRlp
+}
+
 func waitForStuff(waitCondnF func() (bool, error)) (bool, error) {
 	shouldWait, err := waitCondnF()
 	if err != nil || !shouldWait {
@@ -0,0 +1,341 @@
+package engineapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/metrics"
+
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/phase1/execution_client/rpc_helper"
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_helpers"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+)
+
+// Selection policies of the payload proposed at getPayload, among the local
+// one and the valid ones of the external builders.
+const (
+	BuilderSelectionExternal = "external" // the external payload of highest value, the local one as a fallback
+	BuilderSelectionValue    = "value"    // the payload of highest value, local or external
+	BuilderSelectionLocal    = "local"    // the local payload, the external ones are only validated
+)
+
+var (
+	externalPayloadTimer = metrics.GetOrCreateSummary("engine_external_builder_get_payload_seconds")
+
+	externalPayloadsFailed  = metrics.GetOrCreateCounter(`engine_external_builder_payloads_total{result="failed"}`)
+	externalPayloadsInvalid = metrics.GetOrCreateCounter(`engine_external_builder_payloads_total{result="invalid"}`)
+	externalPayloadsValid   = metrics.GetOrCreateCounter(`engine_external_builder_payloads_total{result="valid"}`)
+
+	payloadsSelectedLocal    = metrics.GetOrCreateCounter(`engine_payloads_selected_total{source="local"}`)
+	payloadsSelectedExternal = metrics.GetOrCreateCounter(`engine_payloads_selected_total{source="external"}`)
+)
+
+// ExternalBuilders forwards the Engine API calls of the sequencer to external
+// block builders, in the manner of rollup-boost, so that their payloads can be
+// proposed instead of the local one.
+type ExternalBuilders struct {
+	builders  []*rpc.Client
+	urls      []string
+	timeout   time.Duration
+	selection string
+	logger    log.Logger
+
+	lock     sync.Mutex
+	payloads map[uint64]*externalPayloadIds // by id of the local payload
+}
+
+// externalPayloadIds are the ids of the payloads of the same attributes
+// requested to the external builders.
+type externalPayloadIds struct {
+	ids                   []*hexutility.Bytes // by builder, nil until received
+	received              chan struct{}       // closed once all the builders replied
+	forcedTxs             int                 // number of transactions of the attributes
+	parentBeaconBlockRoot *libcommon.Hash
+}
+
+// NewExternalBuilders connects to the Engine API of the external builders, with
+// the JWT secret in the file at jwtSecretPath.
+func NewExternalBuilders(urls []string, jwtSecretPath string, timeout time.Duration, selection string, logger log.Logger) (*ExternalBuilders, error) {
+	switch selection {
+	case BuilderSelectionExternal, BuilderSelectionValue, BuilderSelectionLocal:
+	default:
+		return nil, fmt.Errorf("unknown builder selection %q", selection)
+	}
+	data, err := os.ReadFile(jwtSecretPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading the JWT secret of the external builders: %w", err)
+	}
+	jwtSecret := common.FromHex(strings.TrimSpace(string(data)))
+	if len(jwtSecret) != 32 {
+		return nil, errors.New("invalid JWT secret of the external builders")
+	}
+
+	b := &ExternalBuilders{
+		urls:      urls,
+		timeout:   timeout,
+		selection: selection,
+		logger:    logger,
+		payloads:  make(map[uint64]*externalPayloadIds),
+	}
+	for _, url := range urls {
+		client, err := rpc.DialHTTPWithClient(url, &http.Client{Transport: rpc_helper.NewJWTRoundTripper(jwtSecret)}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("external builder %s: %w", url, err)
+		}
+		b.builders = append(b.builders, client)
+	}
+	return b, nil
+}
+
+// engineMethod returns the name of the version of the Engine API method.
+func engineMethod(method string, version clparams.StateVersion) string {
+	switch {
+	case version >= clparams.ElectraVersion:
+		return fmt.Sprintf("engine_%sV4", method)
+	case version >= clparams.DenebVersion:
+		return fmt.Sprintf("engine_%sV3", method)
+	case version >= clparams.CapellaVersion:
+		return fmt.Sprintf("engine_%sV2", method)
+	default:
+		return fmt.Sprintf("engine_%sV1", method)
+	}
+}
+
+// forkchoiceUpdated forwards the forkchoice update in the background, and the
+// attributes of the local payload payloadId, if any. The attributes of the
+// payloads without transactions from the txpool are not forwarded: there is
+// nothing to order in them.
+func (b *ExternalBuilders) forkchoiceUpdated(forkchoiceState *engine_types.ForkChoiceState, payloadAttributes *engine_types.PayloadAttributes, payloadId uint64, version clparams.StateVersion) {
+	if payloadAttributes != nil && payloadAttributes.NoTxPool {
+		payloadAttributes = nil
+	}
+	var payload *externalPayloadIds
+	if payloadAttributes != nil {
+		payload = &externalPayloadIds{
+			ids:                   make([]*hexutility.Bytes, len(b.builders)),
+			received:              make(chan struct{}),
+			forcedTxs:             len(payloadAttributes.Transactions),
+			parentBeaconBlockRoot: payloadAttributes.ParentBeaconBlockRoot,
+		}
+		b.lock.Lock()
+		b.payloads[payloadId] = payload
+		// the payloads of the local builders which were evicted can't be retrieved anymore
+		ids := libcommon.SortedKeys(b.payloads)
+		for i := 0; i <= len(ids)-engine_helpers.MaxBuilders; i++ {
+			delete(b.payloads, ids[i])
+		}
+		b.lock.Unlock()
+	}
+
+	method := engineMethod("forkchoiceUpdated", version)
+	var wg sync.WaitGroup
+	for i, builder := range b.builders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), fcuTimeout*time.Millisecond)
+			defer cancel()
+			var resp engine_types.ForkChoiceUpdatedResponse
+			if err := builder.CallContext(ctx, &resp, method, forkchoiceState, payloadAttributes); err != nil {
+				b.logger.Debug("[ExternalBuilder] forkchoiceUpdated failed", "builder", b.urls[i], "err", err)
+				return
+			}
+			if payload != nil && resp.PayloadId != nil {
+				b.lock.Lock()
+				payload.ids[i] = resp.PayloadId
+				b.lock.Unlock()
+			}
+		}()
+	}
+	if payload != nil {
+		go func() {
+			wg.Wait()
+			close(payload.received)
+		}()
+	}
+}
+
+// newPayload forwards a new payload in the background, for the external builders
+// to build on it.
+func (b *ExternalBuilders) newPayload(req *engine_types.ExecutionPayload, expectedBlobHashes []libcommon.Hash, parentBeaconBlockRoot *libcommon.Hash, executionRequests []hexutility.Bytes, version clparams.StateVersion) {
+	args := []interface{}{req}
+	if version >= clparams.DenebVersion {
+		args = append(args, expectedBlobHashes, parentBeaconBlockRoot)
+	}
+	if version >= clparams.ElectraVersion {
+		args = append(args, executionRequests)
+	}
+	method := engineMethod("newPayload", version)
+	for i, builder := range b.builders {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), fcuTimeout*time.Millisecond)
+			defer cancel()
+			var status engine_types.PayloadStatus
+			if err := builder.CallContext(ctx, &status, method, args...); err != nil {
+				b.logger.Debug("[ExternalBuilder] newPayload failed", "builder", b.urls[i], "err", err)
+			}
+		}()
+	}
+}
+
+// getPayloads returns the payloads of the external builders for the local
+// payload payloadId, by decreasing value, within the timeout. The builders
+// which didn't reply in time are skipped.
+func (b *ExternalBuilders) getPayloads(ctx context.Context, payloadId uint64, version clparams.StateVersion) (*externalPayloadIds, []*engine_types.GetPayloadResponse) {
+	b.lock.Lock()
+	payload, ok := b.payloads[payloadId]
+	delete(b.payloads, payloadId)
+	b.lock.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	defer externalPayloadTimer.ObserveDuration(time.Now())
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	select {
+	case <-payload.received:
+	case <-ctx.Done():
+	}
+
+	method := engineMethod("getPayload", version)
+	responses := make([]*engine_types.GetPayloadResponse, len(b.builders))
+	var wg sync.WaitGroup
+	b.lock.Lock()
+	for i, builder := range b.builders {
+		id := payload.ids[i]
+		if id == nil {
+			externalPayloadsFailed.Inc()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var resp engine_types.GetPayloadResponse
+			var err error
+			if version < clparams.CapellaVersion {
+				// engine_getPayloadV1 returns the execution payload only
+				err = builder.CallContext(ctx, &resp.ExecutionPayload, method, id)
+			} else {
+				err = builder.CallContext(ctx, &resp, method, id)
+			}
+			if err != nil || resp.ExecutionPayload == nil {
+				b.logger.Debug("[ExternalBuilder] getPayload failed", "builder", b.urls[i], "err", err)
+				externalPayloadsFailed.Inc()
+				return
+			}
+			responses[i] = &resp
+		}()
+	}
+	b.lock.Unlock()
+	wg.Wait()
+
+	responses = slices.DeleteFunc(responses, func(resp *engine_types.GetPayloadResponse) bool { return resp == nil })
+	slices.SortStableFunc(responses, func(a, b *engine_types.GetPayloadResponse) int {
+		return payloadValue(b).Cmp(payloadValue(a))
+	})
+	return payload, responses
+}
+
+// payloadValue returns the value of the payload, as reported by its builder,
+// 0 if not reported.
+func payloadValue(resp *engine_types.GetPayloadResponse) *big.Int {
+	if resp.BlockValue == nil {
+		return new(big.Int)
+	}
+	return resp.BlockValue.ToInt()
+}
+
+// matchesLocalPayload reports whether the external payload builds on the same
+// attributes as the local one: the validation of the block doesn't check that
+// the transactions of the attributes, e.g. the deposits, open it.
+func matchesLocalPayload(external, local *engine_types.ExecutionPayload, forcedTxs int) bool {
+	if external.ParentHash != local.ParentHash ||
+		external.BlockNumber != local.BlockNumber ||
+		external.Timestamp != local.Timestamp ||
+		external.PrevRandao != local.PrevRandao ||
+		external.FeeRecipient != local.FeeRecipient ||
+		external.GasLimit != local.GasLimit ||
+		!slices.Equal(external.ExtraData, local.ExtraData) ||
+		types.DeriveSha(types.Withdrawals(external.Withdrawals)) != types.DeriveSha(types.Withdrawals(local.Withdrawals)) ||
+		len(external.Transactions) < forcedTxs ||
+		len(local.Transactions) < forcedTxs {
+		return false
+	}
+	for i := 0; i < forcedTxs; i++ {
+		if !slices.Equal(external.Transactions[i], local.Transactions[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectPayload returns the payload to propose among the local one and the
+// ones of the external builders, according to the selection policy. The
+// external payloads are validated through newPayload, the local payload being
+// the fallback of any failure.
+func (s *EngineServer) selectPayload(ctx context.Context, payloadId uint64, local *engine_types.GetPayloadResponse, version clparams.StateVersion) *engine_types.GetPayloadResponse {
+	b := s.externalBuilders
+	payload, responses := b.getPayloads(ctx, payloadId, version)
+	for _, resp := range responses {
+		if b.selection == BuilderSelectionValue && payloadValue(resp).Cmp(payloadValue(local)) <= 0 {
+			break
+		}
+		external := resp.ExecutionPayload
+		if !matchesLocalPayload(external, local.ExecutionPayload, payload.forcedTxs) {
+			s.logger.Warn("[ExternalBuilder] Payload of other attributes", "hash", external.BlockHash)
+			externalPayloadsInvalid.Inc()
+			continue
+		}
+		encodedTxs := make([][]byte, len(external.Transactions))
+		for i, txn := range external.Transactions {
+			encodedTxs[i] = txn
+		}
+		txs, err := types.DecodeTransactions(encodedTxs)
+		if err != nil {
+			s.logger.Warn("[ExternalBuilder] Invalid payload transactions", "hash", external.BlockHash, "err", err)
+			externalPayloadsInvalid.Inc()
+			continue
+		}
+		var blobHashes []libcommon.Hash
+		for _, txn := range txs {
+			blobHashes = append(blobHashes, txn.GetBlobHashes()...)
+		}
+		var executionRequests []hexutility.Bytes
+		if version >= clparams.ElectraVersion {
+			executionRequests = resp.ExecutionRequests
+		}
+		status, err := s.newPayload(ctx, external, blobHashes, payload.parentBeaconBlockRoot, executionRequests, version)
+		if err != nil || status.Status != engine_types.ValidStatus {
+			s.logger.Warn("[ExternalBuilder] Invalid payload", "hash", external.BlockHash, "status", status, "err", err)
+			externalPayloadsInvalid.Inc()
+			continue
+		}
+		externalPayloadsValid.Inc()
+		if b.selection == BuilderSelectionLocal {
+			break
+		}
+		s.logger.Info("[ExternalBuilder] Proposing external payload", "hash", external.BlockHash, "txs", len(external.Transactions), "value", resp.BlockValue)
+		payloadsSelectedExternal.Inc()
+		if resp.ParentBeaconBlockRoot == nil {
+			resp.ParentBeaconBlockRoot = local.ParentBeaconBlockRoot
+		}
+		return resp
+	}
+	payloadsSelectedLocal.Inc()
+	return local
+}
@@ -0,0 +1,118 @@
+package engineapi
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+)
+
+// testBuilder is an external builder building a payload of the given value
+// for the attributes it receives.
+type testBuilder struct {
+	value      int64
+	attributes chan *engine_types.PayloadAttributes
+}
+
+func (b *testBuilder) ForkchoiceUpdatedV3(ctx context.Context, forkChoiceState *engine_types.ForkChoiceState, payloadAttributes *engine_types.PayloadAttributes) (*engine_types.ForkChoiceUpdatedResponse, error) {
+	b.attributes <- payloadAttributes
+	resp := &engine_types.ForkChoiceUpdatedResponse{PayloadStatus: &engine_types.PayloadStatus{Status: engine_types.ValidStatus}}
+	if payloadAttributes != nil {
+		resp.PayloadId = engine_types.ConvertPayloadId(uint64(b.value))
+	}
+	return resp, nil
+}
+
+func (b *testBuilder) GetPayloadV3(ctx context.Context, payloadID hexutility.Bytes) (*engine_types.GetPayloadResponse, error) {
+	return &engine_types.GetPayloadResponse{
+		ExecutionPayload: &engine_types.ExecutionPayload{BlockNumber: hexutil.Uint64(b.value)},
+		BlockValue:       (*hexutil.Big)(big.NewInt(b.value)),
+	}, nil
+}
+
+func TestExternalBuildersGetPayloads(t *testing.T) {
+	logger := log.New()
+	var urls []string
+	var builders []*testBuilder
+	for _, value := range []int64{1, 3, 2} {
+		builder := &testBuilder{value: value, attributes: make(chan *engine_types.PayloadAttributes, 1)}
+		server := rpc.NewServer(50, false, false, true, logger, 100)
+		require.NoError(t, server.RegisterName("engine", builder))
+		httpServer := httptest.NewServer(server)
+		t.Cleanup(httpServer.Close)
+		t.Cleanup(server.Stop)
+		urls = append(urls, httpServer.URL)
+		builders = append(builders, builder)
+	}
+	jwtSecretPath := filepath.Join(t.TempDir(), "jwt.hex")
+	require.NoError(t, os.WriteFile(jwtSecretPath, []byte(hexutility.Encode(make([]byte, 32))), 0600))
+
+	_, err := NewExternalBuilders(urls, jwtSecretPath, time.Second, "best", logger)
+	require.Error(t, err)
+	b, err := NewExternalBuilders(urls, jwtSecretPath, time.Second, BuilderSelectionExternal, logger)
+	require.NoError(t, err)
+
+	forkchoiceState := &engine_types.ForkChoiceState{HeadHash: libcommon.HexToHash("0x01")}
+	attributes := &engine_types.PayloadAttributes{Timestamp: 1, Transactions: []hexutility.Bytes{{0x7e}}}
+	b.forkchoiceUpdated(forkchoiceState, attributes, 7, clparams.DenebVersion)
+	for _, builder := range builders {
+		require.Equal(t, attributes, <-builder.attributes)
+	}
+
+	payload, responses := b.getPayloads(context.Background(), 7, clparams.DenebVersion)
+	require.Equal(t, 1, payload.forcedTxs)
+	require.Len(t, responses, 3)
+	for i, value := range []int64{3, 2, 1} {
+		require.Equal(t, big.NewInt(value), payloadValue(responses[i]))
+	}
+	// the payloads are retrieved once
+	payload, responses = b.getPayloads(context.Background(), 7, clparams.DenebVersion)
+	require.Nil(t, payload)
+	require.Empty(t, responses)
+
+	// without transactions from the txpool, only the forkchoice is forwarded
+	b.forkchoiceUpdated(forkchoiceState, &engine_types.PayloadAttributes{Timestamp: 1, NoTxPool: true}, 8, clparams.DenebVersion)
+	for _, builder := range builders {
+		require.Nil(t, <-builder.attributes)
+	}
+	payload, _ = b.getPayloads(context.Background(), 8, clparams.DenebVersion)
+	require.Nil(t, payload)
+}
+
+func TestMatchesLocalPayload(t *testing.T) {
+	local := &engine_types.ExecutionPayload{
+		ParentHash:   libcommon.HexToHash("0x01"),
+		BlockNumber:  1,
+		Timestamp:    2,
+		GasLimit:     30_000_000,
+		Transactions: []hexutility.Bytes{{0x7e, 0x01}, {0x02}},
+	}
+	external := *local
+	external.Transactions = []hexutility.Bytes{{0x7e, 0x01}, {0x03}, {0x04}}
+	require.True(t, matchesLocalPayload(&external, local, 1))
+	// the deposits can't be replaced
+	require.False(t, matchesLocalPayload(&external, local, 2))
+	external.Transactions = nil
+	require.False(t, matchesLocalPayload(&external, local, 1))
+
+	external = *local
+	external.GasLimit++
+	require.False(t, matchesLocalPayload(&external, local, 0))
+	external = *local
+	external.ExtraData = []byte{0x01}
+	require.False(t, matchesLocalPayload(&external, local, 0))
+}
@@ -35,6 +35,7 @@ func (e *EthereumExecutionModule) evictOldBuilders() {
 
 	// remove old builders so that at most MaxBuilders - 1 remain
 	for i := 0; i <= len(e.builders)-engine_helpers.MaxBuilders; i++ {
+		e.builders[ids[i]].Interrupt()
 		delete(e.builders, ids[i])
 	}
 }
@@ -83,12 +84,16 @@ func (e *EthereumExecutionModule) AssembleBlock(ctx context.Context, req *execut
 
 	// Initiate payload building
 	e.evictOldBuilders()
+	// the payloads being built are superseded by the new one: stop re-building them
+	for _, b := range e.builders {
+		b.Interrupt()
+	}
 
 	e.nextPayloadId++
 	param.PayloadId = e.nextPayloadId
 	e.lastParameters = &param
 
-	e.builders[e.nextPayloadId] = builder.NewBlockBuilder(e.builderFunc, &param)
+	e.builders[e.nextPayloadId] = builder.NewBlockBuilder(e.builderFunc, &param, e.rebuildInterval)
 	e.logger.Info("[ForkChoiceUpdated] BlockBuilder added", "payload", e.nextPayloadId)
 
 	return &execution.AssembleBlockResponse{
@@ -97,19 +102,6 @@ func (e *EthereumExecutionModule) AssembleBlock(ctx context.Context, req *execut
 	}, nil
 }
 
-// The expected value to be received by the feeRecipient in wei
-func blockValue(br *types.BlockWithReceipts, baseFee *uint256.Int) *uint256.Int {
-	blockValue := uint256.NewInt(0)
-	txs := br.Block.Transactions()
-	for i := range txs {
-		gas := new(uint256.Int).SetUint64(br.Receipts[i].GasUsed)
-		effectiveTip := txs[i].GetEffectiveGasTip(baseFee)
-		txValue := new(uint256.Int).Mul(gas, effectiveTip)
-		blockValue.Add(blockValue, txValue)
-	}
-	return blockValue
-}
-
 func (e *EthereumExecutionModule) GetAssembledBlock(ctx context.Context, req *execution.GetAssembledBlockRequest) (*execution.GetAssembledBlockResponse, error) {
 	if !e.semaphore.TryAcquire(1) {
 		return &execution.GetAssembledBlockResponse{
@@ -118,14 +110,14 @@ func (e *EthereumExecutionModule) GetAssembledBlock(ctx context.Context, req *ex
 	}
 	defer e.semaphore.Release(1)
 	payloadId := req.Id
-	builder, ok := e.builders[payloadId]
+	blockBuilder, ok := e.builders[payloadId]
 	if !ok {
 		return &execution.GetAssembledBlockResponse{
 			Busy: false,
 		}, nil
 	}
 
-	blockWithReceipts, err := builder.Stop()
+	blockWithReceipts, err := blockBuilder.Stop()
 	if err != nil {
 		e.logger.Error("Failed to build PoS block", "err", err)
 		return nil, err
@@ -169,7 +161,7 @@ func (e *EthereumExecutionModule) GetAssembledBlock(ctx context.Context, req *ex
 		payload.ExcessBlobGas = header.ExcessBlobGas
 	}
 
-	blockValue := blockValue(blockWithReceipts, baseFee)
+	blockValue := builder.BlockValue(blockWithReceipts)
 
 	blobsBundle := &types2.BlobsBundleV1{}
 	for i, tx := range block.Transactions() {
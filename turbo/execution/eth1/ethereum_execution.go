@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"time"
 
 	"github.com/erigontech/erigon-lib/log/v3"
 	"golang.org/x/sync/semaphore"
@@ -26,6 +27,7 @@ import (
 	"github.com/erigontech/erigon/turbo/builder"
 	"github.com/erigontech/erigon/turbo/engineapi/engine_helpers"
 	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+	"github.com/erigontech/erigon/turbo/forensics"
 	"github.com/erigontech/erigon/turbo/services"
 	"github.com/erigontech/erigon/turbo/shards"
 	"github.com/erigontech/erigon/turbo/stages"
@@ -47,10 +49,14 @@ type EthereumExecutionModule struct {
 
 	logger log.Logger
 	// Block building
-	nextPayloadId  uint64
-	lastParameters *core.BlockBuilderParameters
-	builderFunc    builder.BlockBuilderFunc
-	builders       map[uint64]*builder.BlockBuilder
+	nextPayloadId   uint64
+	lastParameters  *core.BlockBuilderParameters
+	builderFunc     builder.BlockBuilderFunc
+	rebuildInterval time.Duration
+	builders        map[uint64]*builder.BlockBuilder
+
+	// badBlocks records the forensics bundles of the rejected blocks
+	badBlocks *forensics.Recorder
 
 	// Changes accumulator
 	hook                *stages.Hook
@@ -68,7 +74,7 @@ type EthereumExecutionModule struct {
 
 func NewEthereumExecutionModule(blockReader services.FullBlockReader, db kv.RwDB,
 	executionPipeline *stagedsync.Sync, forkValidator *engine_helpers.ForkValidator,
-	config *chain.Config, builderFunc builder.BlockBuilderFunc,
+	config *chain.Config, builderFunc builder.BlockBuilderFunc, rebuildInterval time.Duration,
 	hook *stages.Hook, accumulator *shards.Accumulator,
 	stateChangeConsumer shards.StateChangeConsumer,
 	logger log.Logger, engine consensus.Engine,
@@ -82,6 +88,7 @@ func NewEthereumExecutionModule(blockReader services.FullBlockReader, db kv.RwDB
 		forkValidator:       forkValidator,
 		builders:            make(map[uint64]*builder.BlockBuilder),
 		builderFunc:         builderFunc,
+		rebuildInterval:     rebuildInterval,
 		config:              config,
 		semaphore:           semaphore.NewWeighted(1),
 		hook:                hook,
@@ -89,9 +96,15 @@ func NewEthereumExecutionModule(blockReader services.FullBlockReader, db kv.RwDB
 		stateChangeConsumer: stateChangeConsumer,
 		engine:              engine,
 		bacgroundCtx:        ctx,
+		badBlocks:           forensics.NewRecorder(ctx, db, config, engine, blockReader, logger),
 	}
 }
 
+// BadBlocks returns the recorder of the forensics bundles of the rejected blocks.
+func (e *EthereumExecutionModule) BadBlocks() *forensics.Recorder {
+	return e.badBlocks
+}
+
 func (e *EthereumExecutionModule) getHeader(ctx context.Context, tx kv.Tx, blockHash libcommon.Hash, blockNumber uint64) (*types.Header, error) {
 	td, err := rawdb.ReadTd(tx, blockHash, blockNumber)
 	if err != nil {
@@ -206,6 +219,10 @@ func (e *EthereumExecutionModule) ValidateChain(ctx context.Context, req *execut
 		validationStatus = execution.ExecutionStatus_MissingSegment
 	}
 	isInvalidChain := status == engine_types.InvalidStatus || status == engine_types.InvalidBlockHashStatus || validationError != nil
+	if isInvalidChain {
+		// before the bad chain is purged
+		e.recordBadBlock(ctx, tx, lvh, header, validationError)
+	}
 	if isInvalidChain && (lvh != libcommon.Hash{}) && lvh != blockHash {
 		if err := e.purgeBadChain(ctx, tx, lvh, blockHash); err != nil {
 			return nil, err
@@ -224,6 +241,29 @@ func (e *EthereumExecutionModule) ValidateChain(ctx context.Context, req *execut
 	return validationReceipt, tx.Commit()
 }
 
+// recordBadBlock queues the forensics bundle of the first bad block of the chain of head, the child of the latest
+// valid hash. The bundle is built in the background, a failure is only logged, it does not fail the validation.
+func (e *EthereumExecutionModule) recordBadBlock(ctx context.Context, tx kv.RwTx, latestValidHash libcommon.Hash, head *types.Header, validationError error) {
+	header := head
+	for (latestValidHash != libcommon.Hash{}) && header.ParentHash != latestValidHash && header.Hash() != latestValidHash {
+		parent, err := e.blockReader.Header(ctx, tx, header.ParentHash, header.Number.Uint64()-1)
+		if err != nil || parent == nil {
+			break
+		}
+		header = parent
+	}
+	if header.Hash() == latestValidHash {
+		return
+	}
+	hash, number := header.Hash(), header.Number.Uint64()
+	body, err := e.blockReader.BodyWithTransactions(ctx, tx, hash, number)
+	if err != nil || body == nil {
+		e.logger.Warn("Could not record bad block forensics", "number", number, "hash", hash, "err", err)
+		return
+	}
+	e.badBlocks.RecordBlock(types.NewBlockFromStorage(hash, header, body.Transactions, body.Uncles, body.Withdrawals), validationError)
+}
+
 func (e *EthereumExecutionModule) purgeBadChain(ctx context.Context, tx kv.RwTx, latestValidHash, headHash libcommon.Hash) error {
 	tip := rawdb.ReadHeaderNumber(tx, headHash)
 
@@ -0,0 +1,100 @@
+// Package flashblocks streams the block being built by the sequencer as a
+// sequence of sub-block diffs ("flashblocks"), so that RPC replicas can serve
+// the pending state a few hundred milliseconds after the transactions are
+// executed, long before the block is sealed.
+//
+// The sequencer publishes the flashblocks of every payload over a websocket
+// stream. The first flashblock of a payload (index 0) carries the attributes
+// of the block, every flashblock carries the transactions, receipts and state
+// changes executed since the previous one, and the last one the state root and
+// hash of the sealed block. The payload is built once, from forkchoiceUpdated
+// to getPayload: the transactions reaching the txpool meanwhile are executed on
+// top of its state and published every interval.
+//
+// The stream is public unless the sequencer and the replicas share a JWT
+// secret, the one of the Engine API or another: the subscribers then sign
+// every connection with it, like the consensus client does its Engine API
+// requests.
+package flashblocks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/core/types"
+)
+
+// ReadJWTSecret reads the hex encoded JWT secret of the stream in the file at
+// path, nil if path is empty.
+func ReadJWTSecret(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the JWT secret of the flashblocks stream: %w", err)
+	}
+	jwtSecret := libcommon.FromHex(strings.TrimSpace(string(data)))
+	if len(jwtSecret) != 32 {
+		return nil, errors.New("invalid JWT secret of the flashblocks stream")
+	}
+	return jwtSecret, nil
+}
+
+// Flashblock is a diff of the payload being built.
+type Flashblock struct {
+	PayloadID hexutil.Uint64 `json:"payloadId"`
+	Index     uint64         `json:"index"`
+	Base      *Base          `json:"base,omitempty"` // set on the first flashblock of the payload
+	Diff      Diff           `json:"diff"`
+}
+
+// Base holds the attributes of the block being built.
+type Base struct {
+	ParentHash   libcommon.Hash    `json:"parentHash"`
+	FeeRecipient libcommon.Address `json:"feeRecipient"`
+	PrevRandao   libcommon.Hash    `json:"prevRandao"`
+	BlockNumber  hexutil.Uint64    `json:"blockNumber"`
+	GasLimit     hexutil.Uint64    `json:"gasLimit"`
+	Timestamp    hexutil.Uint64    `json:"timestamp"`
+	ExtraData    hexutility.Bytes  `json:"extraData"`
+	BaseFee      *hexutil.Big      `json:"baseFeePerGas"`
+}
+
+// Diff holds what was executed since the previous flashblock of the payload.
+type Diff struct {
+	Transactions []hexutility.Bytes `json:"transactions"` // binary encoded
+	Receipts     []*types.Receipt   `json:"receipts"`
+	Accounts     []*AccountDiff     `json:"accounts"`
+	Storage      []*StorageDiff     `json:"storage"`
+	GasUsed      hexutil.Uint64     `json:"gasUsed"`             // gas used by the block so far
+	StateRoot    *libcommon.Hash    `json:"stateRoot,omitempty"` // set once the block is sealed
+	BlockHash    *libcommon.Hash    `json:"blockHash,omitempty"` // set once the block is sealed
+}
+
+// AccountDiff is the state of an account after the flashblock. Destructed
+// means that the storage of the account was discarded (self-destruct or
+// contract creation) before the storage changes of the flashblock.
+type AccountDiff struct {
+	Address     libcommon.Address `json:"address"`
+	Deleted     bool              `json:"deleted,omitempty"`
+	Destructed  bool              `json:"destructed,omitempty"`
+	Nonce       hexutil.Uint64    `json:"nonce"`
+	Balance     *hexutil.Big      `json:"balance"`
+	CodeHash    libcommon.Hash    `json:"codeHash"`
+	Incarnation hexutil.Uint64    `json:"incarnation"`
+	Code        hexutility.Bytes  `json:"code,omitempty"` // set when the code was deployed in the flashblock
+}
+
+// StorageDiff is the value of a storage slot after the flashblock.
+type StorageDiff struct {
+	Address libcommon.Address `json:"address"`
+	Key     libcommon.Hash    `json:"key"`
+	Value   libcommon.Hash    `json:"value"`
+}
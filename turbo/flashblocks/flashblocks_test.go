@@ -0,0 +1,164 @@
+package flashblocks
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+)
+
+func TestFlashblocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := log.New()
+
+	publisher := NewPublisher(time.Hour, nil, logger)
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+
+	received := make(chan *Flashblock, 8)
+	go Subscribe(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil, func(fb *Flashblock) { received <- fb }, logger)
+	require.Eventually(t, func() bool {
+		publisher.mu.Lock()
+		defer publisher.mu.Unlock()
+		return len(publisher.subs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, tx := memdb.NewTestTx(t)
+	parent := state.NewPlainStateReader(tx)
+	ibs := state.New(parent)
+	rules := &chain.Rules{}
+
+	header := &types.Header{ParentHash: libcommon.HexToHash("0x01"), Number: big.NewInt(1), GasLimit: 30_000_000, Time: 2, BaseFee: big.NewInt(7)}
+	b := publisher.Begin(5, header)
+
+	sender, contract, reverted := libcommon.HexToAddress("0xaa"), libcommon.HexToAddress("0xbb"), libcommon.HexToAddress("0xcc")
+	key, code := libcommon.HexToHash("0x02"), []byte{0x60, 0x00}
+
+	// executed transaction
+	ibs.AddBalance(sender, uint256.NewInt(1000))
+	ibs.SetCode(contract, code)
+	ibs.SetState(contract, &key, *uint256.NewInt(42))
+	require.NoError(t, ibs.FinalizeTx(rules, b.StateWriter()))
+	txn := types.NewTransaction(0, contract, uint256.NewInt(1), 21000, uint256.NewInt(10), nil)
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, GasUsed: 21000, TxHash: txn.Hash(),
+		Logs: types.Logs{{Address: contract, Topics: []libcommon.Hash{key}, TxHash: txn.Hash()}}}
+	b.AddTransaction(txn, receipt)
+	b.Flush()
+
+	// transaction discarded after its execution
+	ibs.AddBalance(reverted, uint256.NewInt(1))
+	require.NoError(t, ibs.FinalizeTx(rules, b.StateWriter()))
+
+	sealedHeader := types.CopyHeader(header)
+	sealedHeader.Root, sealedHeader.GasUsed = libcommon.HexToHash("0x03"), 21000
+	sealed := types.NewBlockWithHeader(sealedHeader)
+	b.Finish(sealed)
+
+	next := func() *Flashblock {
+		select {
+		case fb := <-received:
+			return fb
+		case <-time.After(5 * time.Second):
+			t.Fatal("flashblock not received")
+			return nil
+		}
+	}
+	first, last := next(), next()
+	require.NotNil(t, first.Base)
+	require.Nil(t, last.Base)
+
+	pending, err := NewPending(first)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), pending.NumberU64())
+	require.Equal(t, header.ParentHash, pending.ParentHash())
+
+	got, gotReceipt, index, ok := pending.Transaction(txn.Hash())
+	require.True(t, ok)
+	require.Equal(t, txn.Hash(), got.Hash())
+	require.Equal(t, uint64(21000), gotReceipt.GasUsed)
+	require.Zero(t, index)
+	require.Len(t, pending.Logs(), 1)
+
+	reader := pending.StateReader(parent)
+	acc, err := reader.ReadAccountData(sender)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), acc.Balance.Uint64())
+	value, err := reader.ReadAccountStorage(contract, 1, &key)
+	require.NoError(t, err)
+	require.Equal(t, []byte{42}, value)
+	gotCode, err := reader.ReadAccountCode(contract, 1, acc.CodeHash)
+	require.NoError(t, err)
+	require.Nil(t, gotCode) // the sender has no code
+	contractAcc, err := reader.ReadAccountData(contract)
+	require.NoError(t, err)
+	gotCode, err = reader.ReadAccountCode(contract, 1, contractAcc.CodeHash)
+	require.NoError(t, err)
+	require.Equal(t, code, gotCode)
+	acc, err = reader.ReadAccountData(reverted)
+	require.NoError(t, err)
+	require.Nil(t, acc)
+
+	require.NoError(t, pending.Apply(last))
+	block := pending.Block()
+	require.Equal(t, sealed.Hash(), block.Hash())
+	require.Equal(t, sealedHeader.Root, block.Root())
+	require.Equal(t, 1, block.Transactions().Len())
+
+	last.Index++
+	require.Error(t, pending.Apply(last))
+}
+
+func TestFlashblocksJWT(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := log.New()
+
+	path := filepath.Join(t.TempDir(), "jwt.hex")
+	require.NoError(t, os.WriteFile(path, []byte("0x"+strings.Repeat("ab", 32)+"\n"), 0600))
+	jwtSecret, err := ReadJWTSecret(path)
+	require.NoError(t, err)
+	require.Len(t, jwtSecret, 32)
+
+	publisher := NewPublisher(time.Hour, jwtSecret, logger)
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// unauthenticated and wrongly signed subscribers are rejected before the upgrade
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Error(t, subscribe(ctx, url, []byte(strings.Repeat("x", 32)), func(*Flashblock) {}, logger))
+
+	go Subscribe(ctx, url, jwtSecret, func(*Flashblock) {}, logger)
+	require.Eventually(t, func() bool {
+		publisher.mu.Lock()
+		defer publisher.mu.Unlock()
+		return len(publisher.subs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	secret, err := ReadJWTSecret("")
+	require.NoError(t, err)
+	require.Nil(t, secret)
+	require.NoError(t, os.WriteFile(path, []byte("0xabcd"), 0600))
+	_, err = ReadJWTSecret(path)
+	require.Error(t, err)
+}
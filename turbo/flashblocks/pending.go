@@ -0,0 +1,265 @@
+package flashblocks
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+)
+
+// Pending is the block being built by the sequencer, as accumulated from its
+// flashblocks by an RPC replica.
+type Pending struct {
+	mu sync.RWMutex
+
+	payloadID uint64
+	next      uint64 // index of the next flashblock
+	header    *types.Header
+	blockHash *libcommon.Hash // set once the block is sealed
+	txs       types.Transactions
+	receipts  types.Receipts
+
+	accounts   map[libcommon.Address]*AccountDiff
+	storage    map[libcommon.Address]map[libcommon.Hash]libcommon.Hash
+	destructed map[libcommon.Address]struct{}
+	code       map[libcommon.Hash][]byte
+}
+
+// NewPending starts the pending block from the first flashblock of a payload.
+func NewPending(fb *Flashblock) (*Pending, error) {
+	if fb.Index != 0 || fb.Base == nil {
+		return nil, fmt.Errorf("flashblock %d of payload %x is not the first one", fb.Index, uint64(fb.PayloadID))
+	}
+	p := &Pending{
+		payloadID: uint64(fb.PayloadID),
+		header: &types.Header{
+			ParentHash: fb.Base.ParentHash,
+			Coinbase:   fb.Base.FeeRecipient,
+			MixDigest:  fb.Base.PrevRandao,
+			Number:     new(big.Int).SetUint64(uint64(fb.Base.BlockNumber)),
+			GasLimit:   uint64(fb.Base.GasLimit),
+			Time:       uint64(fb.Base.Timestamp),
+			Extra:      fb.Base.ExtraData,
+			Difficulty: new(big.Int),
+			UncleHash:  types.EmptyUncleHash,
+		},
+		accounts:   map[libcommon.Address]*AccountDiff{},
+		storage:    map[libcommon.Address]map[libcommon.Hash]libcommon.Hash{},
+		destructed: map[libcommon.Address]struct{}{},
+		code:       map[libcommon.Hash][]byte{},
+	}
+	if fb.Base.BaseFee != nil {
+		p.header.BaseFee = fb.Base.BaseFee.ToInt()
+	}
+	if err := p.Apply(fb); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Apply adds the next flashblock of the payload.
+func (p *Pending) Apply(fb *Flashblock) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if uint64(fb.PayloadID) != p.payloadID {
+		return fmt.Errorf("flashblock of payload %x, pending payload %x", uint64(fb.PayloadID), p.payloadID)
+	}
+	if fb.Index != p.next {
+		return fmt.Errorf("flashblock %d of payload %x, expected %d", fb.Index, p.payloadID, p.next)
+	}
+	if p.blockHash != nil {
+		return errors.New("flashblock after the sealed block")
+	}
+	if len(fb.Diff.Transactions) != len(fb.Diff.Receipts) {
+		return fmt.Errorf("%d transactions, %d receipts", len(fb.Diff.Transactions), len(fb.Diff.Receipts))
+	}
+	txs := make(types.Transactions, len(fb.Diff.Transactions))
+	for i, enc := range fb.Diff.Transactions {
+		txn, err := types.DecodeTransaction(enc)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %w", len(p.txs)+i, err)
+		}
+		txs[i] = txn
+	}
+
+	p.txs = append(p.txs, txs...)
+	p.receipts = append(p.receipts, fb.Diff.Receipts...)
+	for _, acc := range fb.Diff.Accounts {
+		if acc.Destructed {
+			p.destructed[acc.Address] = struct{}{}
+			delete(p.storage, acc.Address)
+		}
+		if len(acc.Code) > 0 {
+			p.code[acc.CodeHash] = acc.Code
+		}
+		p.accounts[acc.Address] = acc
+	}
+	for _, s := range fb.Diff.Storage {
+		slots, ok := p.storage[s.Address]
+		if !ok {
+			slots = map[libcommon.Hash]libcommon.Hash{}
+			p.storage[s.Address] = slots
+		}
+		slots[s.Key] = s.Value
+	}
+	p.header.GasUsed = uint64(fb.Diff.GasUsed)
+	if fb.Diff.StateRoot != nil {
+		p.header.Root = *fb.Diff.StateRoot
+	}
+	p.blockHash = fb.Diff.BlockHash
+	p.next++
+	return nil
+}
+
+// PayloadID returns the payload of the pending block.
+func (p *Pending) PayloadID() uint64 {
+	return p.payloadID
+}
+
+// ParentHash returns the parent of the pending block.
+func (p *Pending) ParentHash() libcommon.Hash {
+	return p.header.ParentHash
+}
+
+// NumberU64 returns the number of the pending block.
+func (p *Pending) NumberU64() uint64 {
+	return p.header.Number.Uint64()
+}
+
+// Header returns a copy of the header of the pending block. Its root is only
+// set once the block is sealed.
+func (p *Pending) Header() *types.Header {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return types.CopyHeader(p.header)
+}
+
+// Block returns the pending block with the transactions executed so far.
+func (p *Pending) Block() *types.Block {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	header, txs := types.CopyHeader(p.header), append(types.Transactions{}, p.txs...)
+	if p.blockHash != nil {
+		return types.NewBlockFromStorage(*p.blockHash, header, txs, nil, nil)
+	}
+	return types.NewBlockFromNetwork(header, &types.Body{Transactions: txs})
+}
+
+// Receipts returns the receipts of the transactions executed so far.
+func (p *Pending) Receipts() types.Receipts {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append(types.Receipts{}, p.receipts...)
+}
+
+// Transaction returns a transaction of the pending block, its receipt and index.
+func (p *Pending) Transaction(hash libcommon.Hash) (types.Transaction, *types.Receipt, int, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for i, txn := range p.txs {
+		if txn.Hash() == hash {
+			return txn, p.receipts[i], i, true
+		}
+	}
+	return nil, nil, 0, false
+}
+
+// Logs returns the logs of the pending block.
+func (p *Pending) Logs() types.Logs {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var logs types.Logs
+	for _, r := range p.receipts {
+		logs = append(logs, r.Logs...)
+	}
+	return logs
+}
+
+// StateReader returns a reader of the pending state on top of the state of
+// the parent block.
+func (p *Pending) StateReader(parent state.StateReader) state.StateReader {
+	return &pendingReader{p: p, parent: parent}
+}
+
+type pendingReader struct {
+	p      *Pending
+	parent state.StateReader
+}
+
+var _ state.StateReader = (*pendingReader)(nil)
+
+func (r *pendingReader) ReadAccountData(address libcommon.Address) (*accounts.Account, error) {
+	r.p.mu.RLock()
+	acc, ok := r.p.accounts[address]
+	r.p.mu.RUnlock()
+	if !ok {
+		return r.parent.ReadAccountData(address)
+	}
+	if acc.Deleted {
+		return nil, nil
+	}
+	a := accounts.NewAccount()
+	a.Nonce = uint64(acc.Nonce)
+	if acc.Balance != nil {
+		balance, overflow := uint256.FromBig(acc.Balance.ToInt())
+		if overflow {
+			return nil, fmt.Errorf("balance of %x overflows", address)
+		}
+		a.Balance = *balance
+	}
+	a.CodeHash = acc.CodeHash
+	a.Incarnation = uint64(acc.Incarnation)
+	return &a, nil
+}
+
+func (r *pendingReader) ReadAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash) ([]byte, error) {
+	r.p.mu.RLock()
+	v, ok := r.p.storage[address][*key]
+	_, destructed := r.p.destructed[address]
+	r.p.mu.RUnlock()
+	if ok {
+		return new(uint256.Int).SetBytes32(v[:]).Bytes(), nil
+	}
+	if destructed {
+		return nil, nil
+	}
+	return r.parent.ReadAccountStorage(address, incarnation, key)
+}
+
+func (r *pendingReader) ReadAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash) ([]byte, error) {
+	r.p.mu.RLock()
+	code, ok := r.p.code[codeHash]
+	r.p.mu.RUnlock()
+	if ok {
+		return code, nil
+	}
+	return r.parent.ReadAccountCode(address, incarnation, codeHash)
+}
+
+func (r *pendingReader) ReadAccountCodeSize(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash) (int, error) {
+	r.p.mu.RLock()
+	code, ok := r.p.code[codeHash]
+	r.p.mu.RUnlock()
+	if ok {
+		return len(code), nil
+	}
+	return r.parent.ReadAccountCodeSize(address, incarnation, codeHash)
+}
+
+func (r *pendingReader) ReadAccountIncarnation(address libcommon.Address) (uint64, error) {
+	r.p.mu.RLock()
+	acc, ok := r.p.accounts[address]
+	r.p.mu.RUnlock()
+	if ok && !acc.Deleted {
+		return uint64(acc.Incarnation), nil
+	}
+	return r.parent.ReadAccountIncarnation(address)
+}
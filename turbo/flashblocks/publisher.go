@@ -0,0 +1,461 @@
+package flashblocks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/rpc"
+)
+
+// subscriberBuffer is the number of flashblocks queued for a subscriber before
+// it is considered too slow and disconnected.
+const subscriberBuffer = 256
+
+// interruptPollInterval is the interval at which a Block waiting for its next
+// flashblock checks whether the payload was retrieved.
+const interruptPollInterval = 10 * time.Millisecond
+
+// Publisher streams the flashblocks of the payloads built by the sequencer to
+// the websocket subscribers.
+type Publisher struct {
+	interval  time.Duration
+	jwtSecret []byte // required from the subscribers, if not nil
+	upgrader  websocket.Upgrader
+	logger    log.Logger
+
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+// NewPublisher returns a publisher whose subscribers must authenticate with a
+// JWT signed with jwtSecret, if not nil. Without a secret, anyone who reaches
+// the stream can subscribe to it: it should listen on localhost or a private
+// network only.
+func NewPublisher(interval time.Duration, jwtSecret []byte, logger log.Logger) *Publisher {
+	return &Publisher{
+		interval:  interval,
+		jwtSecret: jwtSecret,
+		// the subscribers are nodes, not browsers: the origin is not checked, the JWT is
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		logger:   logger,
+		subs:     map[chan []byte]struct{}{},
+	}
+}
+
+// ListenAndServe serves the flashblocks stream on addr until ctx is done.
+func (p *Publisher) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: p, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	p.logger.Info("Flashblocks stream started", "addr", listener.Addr(), "interval", p.interval, "jwt", p.jwtSecret != nil)
+	if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP upgrades the request to a websocket and streams the flashblocks
+// to it, as JSON text messages.
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.jwtSecret != nil && !rpc.CheckJwtSecret(w, r, p.jwtSecret) {
+		p.logger.Debug("[flashblocks] unauthorized subscriber", "remote", r.RemoteAddr)
+		return
+	}
+	conn, err := p.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		p.logger.Debug("[flashblocks] upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := make(chan []byte, subscriberBuffer)
+	p.mu.Lock()
+	p.subs[ch] = struct{}{}
+	p.mu.Unlock()
+	defer p.unsubscribe(ch)
+
+	// the subscriber does not send anything, reading only detects the disconnection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				p.logger.Debug("[flashblocks] subscriber too slow, disconnecting", "remote", r.RemoteAddr)
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (p *Publisher) unsubscribe(ch chan []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.subs[ch]; ok {
+		delete(p.subs, ch)
+		close(ch)
+	}
+}
+
+func (p *Publisher) publish(fb *Flashblock) {
+	msg, err := json.Marshal(fb)
+	if err != nil {
+		p.logger.Warn("[flashblocks] encode", "payload", uint64(fb.PayloadID), "index", fb.Index, "err", err)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ch := range p.subs {
+		select {
+		case ch <- msg:
+		default:
+			delete(p.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Begin starts the flashblocks of a payload built on top of the header. It
+// returns nil if the publisher is nil.
+func (p *Publisher) Begin(payloadID uint64, header *types.Header) *Block {
+	if p == nil {
+		return nil
+	}
+	b := &Block{
+		publisher: p,
+		payloadID: payloadID,
+		base: &Base{
+			ParentHash:   header.ParentHash,
+			FeeRecipient: header.Coinbase,
+			PrevRandao:   header.MixDigest,
+			BlockNumber:  hexutil.Uint64(header.Number.Uint64()),
+			GasLimit:     hexutil.Uint64(header.GasLimit),
+			Timestamp:    hexutil.Uint64(header.Time),
+			ExtraData:    header.Extra,
+		},
+		lastFlush: time.Now(),
+	}
+	if header.BaseFee != nil {
+		b.base.BaseFee = (*hexutil.Big)(header.BaseFee)
+	}
+	b.writer.reset()
+	b.txWriter.reset()
+	return b
+}
+
+// Block accumulates the transactions executed in a payload and publishes
+// them as flashblocks. All methods are no-ops on a nil Block.
+type Block struct {
+	publisher *Publisher
+	payloadID uint64
+	base      *Base
+	index     uint64
+	gasUsed   hexutil.Uint64 // gas used by the block as of the last flashblock
+	lastFlush time.Time
+	lastNext  time.Time // return of the last Next
+
+	txs      types.Transactions
+	receipts types.Receipts
+	writer   diffWriter // state changes of the flashblock
+	txWriter diffWriter // state changes of the transaction being executed
+
+	held *heldTxs // transactions held until they are committed or discarded, nil if none
+}
+
+// heldTxs are the transactions executed while a Block is held.
+type heldTxs struct {
+	txs      types.Transactions
+	receipts types.Receipts
+	writer   diffWriter
+}
+
+// StateWriter returns the writer collecting the state changes of the next
+// transaction, to be passed to its finalization. The changes are added to the
+// flashblock by AddTransaction, and discarded if the transaction is not.
+func (b *Block) StateWriter() state.StateWriter {
+	if b == nil {
+		return state.NewNoopWriter()
+	}
+	b.txWriter.reset()
+	return &b.txWriter
+}
+
+// AddTransaction adds an executed transaction, and publishes the flashblock
+// if the interval since the previous one elapsed.
+func (b *Block) AddTransaction(txn types.Transaction, receipt *types.Receipt) {
+	if b == nil {
+		return
+	}
+	if receipt.Logs == nil {
+		// the logs are required by the json decoding of the receipt
+		r := *receipt
+		r.Logs = types.Logs{}
+		receipt = &r
+	}
+	if b.held != nil {
+		b.held.txs = append(b.held.txs, txn)
+		b.held.receipts = append(b.held.receipts, receipt)
+		b.held.writer.merge(&b.txWriter)
+		b.txWriter.reset()
+		return
+	}
+	b.txs = append(b.txs, txn)
+	b.receipts = append(b.receipts, receipt)
+	b.writer.merge(&b.txWriter)
+	b.txWriter.reset()
+	if time.Since(b.lastFlush) >= b.publisher.interval {
+		b.Flush()
+	}
+}
+
+// Next waits an interval after the previous flashblock, or after the previous
+// call if later, for the transactions to add to the next flashblock. It reports
+// whether the payload is still being built: it isn't once interrupted, by
+// getPayload, or at until, the timestamp of the block.
+func (b *Block) Next(interrupt *int32, until time.Time) bool {
+	if b == nil {
+		return false
+	}
+	next := b.lastFlush
+	if b.lastNext.After(next) {
+		next = b.lastNext
+	}
+	next = next.Add(b.publisher.interval)
+	if !next.Before(until) {
+		return false
+	}
+	interrupted := func() bool { return interrupt != nil && atomic.LoadInt32(interrupt) != 0 }
+	for wait := time.Until(next); wait > 0 && !interrupted(); wait = time.Until(next) {
+		// getPayload only sets the interrupt flag
+		time.Sleep(min(wait, interruptPollInterval))
+	}
+	b.lastNext = time.Now()
+	return !interrupted()
+}
+
+// Hold holds the transactions added until Commit or Discard, for a group of
+// transactions included atomically (a bundle) not to be published partially.
+func (b *Block) Hold() {
+	if b == nil {
+		return
+	}
+	b.held = &heldTxs{}
+	b.held.writer.reset()
+}
+
+// Commit adds the held transactions to the flashblock.
+func (b *Block) Commit() {
+	if b == nil || b.held == nil {
+		return
+	}
+	held := b.held
+	b.held = nil
+	b.txs = append(b.txs, held.txs...)
+	b.receipts = append(b.receipts, held.receipts...)
+	b.writer.merge(&held.writer)
+	if time.Since(b.lastFlush) >= b.publisher.interval {
+		b.Flush()
+	}
+}
+
+// Discard drops the held transactions.
+func (b *Block) Discard() {
+	if b == nil {
+		return
+	}
+	b.held = nil
+}
+
+// Flush publishes what was executed since the previous flashblock, if anything.
+func (b *Block) Flush() {
+	if b == nil || (len(b.txs) == 0 && b.writer.empty()) {
+		return
+	}
+	b.publish(nil)
+}
+
+// Finish publishes the last flashblock of the sealed block.
+func (b *Block) Finish(block *types.Block) {
+	if b == nil {
+		return
+	}
+	b.publish(block)
+}
+
+func (b *Block) publish(sealed *types.Block) {
+	fb := &Flashblock{PayloadID: hexutil.Uint64(b.payloadID), Index: b.index}
+	if b.index == 0 {
+		fb.Base = b.base
+	}
+	txs, err := types.MarshalTransactionsBinary(b.txs)
+	if err != nil {
+		b.publisher.logger.Warn("[flashblocks] encode transactions", "err", err)
+		return
+	}
+	for _, txn := range txs {
+		fb.Diff.Transactions = append(fb.Diff.Transactions, txn)
+	}
+	fb.Diff.Receipts = b.receipts
+	fb.Diff.Accounts, fb.Diff.Storage = b.writer.diff()
+	fb.Diff.GasUsed = b.gasUsed
+	if len(b.receipts) > 0 {
+		fb.Diff.GasUsed = hexutil.Uint64(b.receipts[len(b.receipts)-1].CumulativeGasUsed)
+	}
+	if sealed != nil {
+		root, hash := sealed.Root(), sealed.Hash()
+		fb.Diff.StateRoot, fb.Diff.BlockHash = &root, &hash
+		fb.Diff.GasUsed = hexutil.Uint64(sealed.GasUsed())
+	}
+	b.publisher.publish(fb)
+
+	b.gasUsed = fb.Diff.GasUsed
+	b.index++
+	b.lastFlush = time.Now()
+	b.txs, b.receipts = nil, nil
+	b.writer.reset()
+}
+
+// diffWriter collects the state changes of a flashblock.
+type diffWriter struct {
+	accounts map[libcommon.Address]*AccountDiff
+	storage  map[libcommon.Address]map[libcommon.Hash]*StorageDiff
+	order    []libcommon.Address
+}
+
+var _ state.StateWriter = (*diffWriter)(nil)
+
+func (w *diffWriter) reset() {
+	w.accounts = map[libcommon.Address]*AccountDiff{}
+	w.storage = map[libcommon.Address]map[libcommon.Hash]*StorageDiff{}
+	w.order = nil
+}
+
+func (w *diffWriter) empty() bool {
+	return len(w.order) == 0
+}
+
+func (w *diffWriter) account(address libcommon.Address) *AccountDiff {
+	acc, ok := w.accounts[address]
+	if !ok {
+		acc = &AccountDiff{Address: address}
+		w.accounts[address] = acc
+		w.order = append(w.order, address)
+	}
+	return acc
+}
+
+// merge applies the changes of a transaction on top of the changes of the
+// previous transactions of the flashblock.
+func (w *diffWriter) merge(tx *diffWriter) {
+	for _, addr := range tx.order {
+		src, dst := tx.accounts[addr], w.account(addr)
+		if src.Destructed {
+			delete(w.storage, addr)
+		}
+		code, codeHash, destructed := dst.Code, dst.CodeHash, dst.Destructed || src.Destructed
+		*dst = *src
+		dst.Destructed = destructed
+		if len(dst.Code) == 0 && !dst.Deleted && codeHash == dst.CodeHash {
+			dst.Code = code // deployed by a previous transaction of the flashblock
+		}
+		if len(tx.storage[addr]) == 0 {
+			continue
+		}
+		slots, ok := w.storage[addr]
+		if !ok {
+			slots = map[libcommon.Hash]*StorageDiff{}
+			w.storage[addr] = slots
+		}
+		for key, s := range tx.storage[addr] {
+			slots[key] = s
+		}
+	}
+}
+
+func (w *diffWriter) diff() ([]*AccountDiff, []*StorageDiff) {
+	var accs []*AccountDiff
+	var storage []*StorageDiff
+	for _, addr := range w.order {
+		accs = append(accs, w.accounts[addr])
+		for _, s := range w.storage[addr] {
+			storage = append(storage, s)
+		}
+	}
+	return accs, storage
+}
+
+func (w *diffWriter) UpdateAccountData(address libcommon.Address, original, account *accounts.Account) error {
+	acc := w.account(address)
+	acc.Deleted = false
+	acc.Nonce = hexutil.Uint64(account.Nonce)
+	acc.Balance = (*hexutil.Big)(account.Balance.ToBig())
+	acc.CodeHash = account.CodeHash
+	acc.Incarnation = hexutil.Uint64(account.Incarnation)
+	return nil
+}
+
+func (w *diffWriter) UpdateAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash, code []byte) error {
+	w.account(address).Code = libcommon.Copy(code)
+	return nil
+}
+
+func (w *diffWriter) DeleteAccount(address libcommon.Address, original *accounts.Account) error {
+	acc := w.account(address)
+	*acc = AccountDiff{Address: address, Deleted: true, Destructed: true}
+	delete(w.storage, address)
+	return nil
+}
+
+func (w *diffWriter) WriteAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash, original, value *uint256.Int) error {
+	w.account(address)
+	slots, ok := w.storage[address]
+	if !ok {
+		slots = map[libcommon.Hash]*StorageDiff{}
+		w.storage[address] = slots
+	}
+	slots[*key] = &StorageDiff{Address: address, Key: *key, Value: value.Bytes32()}
+	return nil
+}
+
+func (w *diffWriter) CreateContract(address libcommon.Address) error {
+	w.account(address).Destructed = true
+	delete(w.storage, address)
+	return nil
+}
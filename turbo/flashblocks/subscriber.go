@@ -0,0 +1,68 @@
+package flashblocks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/websocket"
+
+	"github.com/erigontech/erigon-lib/log/v3"
+)
+
+// reconnectDelay is the delay before reconnecting to the sequencer stream
+// after the connection was lost.
+const reconnectDelay = 3 * time.Second
+
+// Subscribe streams the flashblocks published at url to handle until ctx is
+// done, reconnecting when the connection is lost. Every connection is signed
+// with jwtSecret, if not nil.
+func Subscribe(ctx context.Context, url string, jwtSecret []byte, handle func(*Flashblock), logger log.Logger) {
+	for {
+		if err := subscribe(ctx, url, jwtSecret, handle, logger); err != nil && ctx.Err() == nil {
+			logger.Warn("[flashblocks] stream disconnected", "url", url, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func subscribe(ctx context.Context, url string, jwtSecret []byte, handle func(*Flashblock), logger log.Logger) error {
+	header := http.Header{}
+	if jwtSecret != nil {
+		// issued now, the publisher rejects the tokens issued more than a minute ago
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": time.Now().Unix()}).SignedString(jwtSecret)
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	logger.Info("[flashblocks] subscribed", "url", url)
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		fb := new(Flashblock)
+		if err := json.Unmarshal(msg, fb); err != nil {
+			logger.Debug("[flashblocks] decode", "err", err)
+			continue
+		}
+		handle(fb)
+	}
+}
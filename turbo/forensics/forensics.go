@@ -0,0 +1,261 @@
+// Package forensics keeps a reproduction bundle of the blocks rejected by the payload validation, so that a
+// consensus split between replicas can be replayed offline instead of guessed at.
+//
+// When a payload is found invalid, the block is re-executed on the state of its parent through a reader
+// recording the state it reads. The bundle holds the raw block, the parent header, that prestate witness, the
+// receipts of the transactions up to the failure, the validation error, and the chain config and fork flags of
+// the block. It is stored in the kv.BadBlockForensics table, and exported by `integration export_bad_block` to
+// a directory of inputs of the t8n tool (`evm t8n`).
+package forensics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/kvcfg"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/common/math"
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/consensus/misc"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/systemcontracts"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/eth/stagedsync"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/services"
+)
+
+// Limit is the number of bundles kept, the oldest ones are pruned.
+const Limit = 100
+
+// Bundle is the reproduction bundle of a rejected block.
+type Bundle struct {
+	BlockHash    libcommon.Hash   `json:"blockHash"`
+	BlockNumber  uint64           `json:"blockNumber"`
+	Block        hexutility.Bytes `json:"block,omitempty"`   // rlp
+	Payload      json.RawMessage  `json:"payload,omitempty"` // of a payload that could not be decoded into a block
+	ParentHeader *types.Header    `json:"parentHeader"`
+	Error        string           `json:"error"` // of the payload validation
+
+	// Prestate holds the accounts and storage read by the replay of the block on the state of its parent, unless
+	// that state is not available (PrestateError).
+	Prestate      types.GenesisAlloc                     `json:"prestate,omitempty"`
+	PrestateError string                                 `json:"prestateError,omitempty"`
+	BlockHashes   map[math.HexOrDecimal64]libcommon.Hash `json:"blockHashes,omitempty"` // read by BLOCKHASH
+	Receipts      types.Receipts                         `json:"receipts"`              // up to the failure
+	FailedTx      *int                                   `json:"failedTx,omitempty"`    // index of the failed transaction
+	ReplayError   string                                 `json:"replayError,omitempty"`
+
+	ChainConfig *chain.Config `json:"chainConfig"`
+	Forks       Forks         `json:"forks"`
+}
+
+// Forks are the fork flags of the block.
+type Forks struct {
+	*chain.Rules
+	IsOptimismEcotone  bool
+	IsOptimismHolocene bool
+}
+
+// forks returns the fork flags of the block of the number and time.
+func forks(cfg *chain.Config, number, time uint64) Forks {
+	return Forks{
+		Rules:              cfg.Rules(number, time),
+		IsOptimismEcotone:  cfg.IsOptimismEcotone(time),
+		IsOptimismHolocene: cfg.IsOptimism() && cfg.IsHolocene(time),
+	}
+}
+
+// DecodeBlock decodes the raw block.
+func (b *Bundle) DecodeBlock() (*types.Block, error) {
+	if len(b.Block) == 0 {
+		return nil, fmt.Errorf("payload of block %d could not be decoded into a block: %s", b.BlockNumber, b.Error)
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(b.Block, block); err != nil {
+		return nil, fmt.Errorf("decoding block %d: %w", b.BlockNumber, err)
+	}
+	return block, nil
+}
+
+// Build assembles the bundle of the block rejected with validationErr, replaying it on the state of its parent.
+// The prestate is only available when the parent is canonical, the failures of the replay are recorded in the
+// bundle.
+func Build(ctx context.Context, tx kv.Tx, cfg *chain.Config, engine consensus.Engine, blockReader services.FullBlockReader, block *types.Block, validationErr error, logger log.Logger) (*Bundle, error) {
+	header := block.Header()
+	number := block.NumberU64()
+	if number == 0 {
+		return nil, fmt.Errorf("genesis block has no parent")
+	}
+	parent, err := blockReader.Header(ctx, tx, block.ParentHash(), number-1)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x of block %d not found", block.ParentHash(), number)
+	}
+	raw, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{
+		BlockHash:    block.Hash(),
+		BlockNumber:  number,
+		Block:        raw,
+		ParentHeader: parent,
+		Receipts:     types.Receipts{},
+		ChainConfig:  cfg,
+		Forks:        forks(cfg, number, header.Time),
+	}
+	if validationErr != nil {
+		bundle.Error = validationErr.Error()
+	}
+
+	reader, err := parentStateReader(tx, cfg, parent)
+	if err != nil {
+		return nil, err
+	}
+	if reader == nil {
+		bundle.PrestateError = fmt.Sprintf("parent %x is not canonical, its state is not available", parent.Hash())
+		return bundle, nil
+	}
+	witness := newWitnessReader(reader)
+	if err := bundle.replay(ctx, tx, cfg, engine, blockReader, block, witness, logger); err != nil {
+		bundle.ReplayError = err.Error()
+	}
+	bundle.Prestate = witness.alloc()
+	return bundle, nil
+}
+
+// parentStateReader returns the reader of the state of the canonical parent, nil for a non-canonical parent.
+func parentStateReader(tx kv.Tx, cfg *chain.Config, parent *types.Header) (state.StateReader, error) {
+	number := parent.Number.Uint64()
+	canonical, err := rawdb.IsCanonicalHash(tx, parent.Hash(), number)
+	if err != nil || !canonical {
+		return nil, err
+	}
+	executed, err := stages.GetStageProgress(tx, stages.Execution)
+	if err != nil {
+		return nil, err
+	}
+	if executed == number {
+		return state.NewPlainStateReader(tx), nil
+	}
+	historyV3, err := kvcfg.HistoryV3.Enabled(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !historyV3 {
+		return state.NewPlainState(tx, number+1, systemcontracts.SystemContractCodeLookup[cfg.ChainName]), nil
+	}
+	maxTxNum, err := rawdbv3.TxNums.Max(tx, number)
+	if err != nil {
+		return nil, err
+	}
+	r := state.NewHistoryReaderV3()
+	r.SetTx(tx)
+	r.SetTxNum(maxTxNum + 1)
+	return r, nil
+}
+
+// replay executes the block on the reader, recording the receipts until a transaction fails.
+func (b *Bundle) replay(ctx context.Context, tx kv.Tx, cfg *chain.Config, engine consensus.Engine, blockReader services.FullBlockReader, block *types.Block, reader state.StateReader, logger log.Logger) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("replay panic: %v", r)
+		}
+	}()
+	header := block.Header()
+	getHeader := func(hash libcommon.Hash, number uint64) *types.Header {
+		h, _ := blockReader.Header(ctx, tx, hash, number)
+		return h
+	}
+	getHashFn := core.GetHashFn(header, getHeader)
+	blockHashFunc := func(n uint64) libcommon.Hash {
+		hash := getHashFn(n)
+		if b.BlockHashes == nil {
+			b.BlockHashes = make(map[math.HexOrDecimal64]libcommon.Hash)
+		}
+		b.BlockHashes[math.HexOrDecimal64(n)] = hash
+		return hash
+	}
+	chainReader := stagedsync.NewChainReaderImpl(cfg, tx, blockReader, logger)
+
+	ibs := state.New(reader)
+	if err := core.InitializeBlockExecution(engine, chainReader, header, cfg, ibs, logger); err != nil {
+		return err
+	}
+	misc.EnsureCreate2Deployer(cfg, header.Time, ibs)
+
+	gp := new(core.GasPool).AddGas(block.GasLimit()).AddBlobGas(cfg.GetMaxBlobGasPerBlock())
+	usedGas, usedBlobGas := new(uint64), new(uint64)
+	noop := state.NewNoopWriter()
+	for i, txn := range block.Transactions() {
+		ibs.SetTxContext(txn.Hash(), block.Hash(), i)
+		receipt, _, err := core.ApplyTransaction(cfg, blockHashFunc, engine, nil, gp, ibs, noop, header, txn, usedGas, usedBlobGas, vm.Config{})
+		if err != nil {
+			b.FailedTx = &i
+			return fmt.Errorf("could not apply tx %d [%x]: %w", i, txn.Hash(), err)
+		}
+		if receipt.Logs == nil {
+			receipt.Logs = types.Logs{} // required by the json of the receipt
+		}
+		b.Receipts = append(b.Receipts, receipt)
+	}
+	if _, _, _, _, err := core.FinalizeBlockExecution(engine, reader, header, block.Transactions(), block.Uncles(), noop, cfg, ibs, b.Receipts, block.Withdrawals(), chainReader, false, logger); err != nil {
+		return err
+	}
+	if *usedGas != header.GasUsed {
+		return fmt.Errorf("gas used by execution: %d, in header: %d", *usedGas, header.GasUsed)
+	}
+	return nil
+}
+
+// Write stores the bundle, keeping the latest Limit bundles.
+func Write(tx kv.RwTx, bundle *Bundle) error {
+	v, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+	return rawdb.WriteBadBlockForensics(tx, bundle.BlockHash, bundle.BlockNumber, v, Limit)
+}
+
+// Read retrieves the bundle of the rejected block, nil if there is none.
+func Read(tx kv.Tx, hash libcommon.Hash) (*Bundle, error) {
+	v, err := rawdb.ReadBadBlockForensics(tx, hash)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	bundle := new(Bundle)
+	if err := json.Unmarshal(v, bundle); err != nil {
+		return nil, fmt.Errorf("decoding forensics bundle of %x: %w", hash, err)
+	}
+	return bundle, nil
+}
+
+// List returns the stored bundles by block number.
+func List(tx kv.Tx) ([]*Bundle, error) {
+	var bundles []*Bundle
+	if err := rawdb.ForEachBadBlockForensics(tx, func(number uint64, hash libcommon.Hash, v []byte) error {
+		bundle := new(Bundle)
+		if err := json.Unmarshal(v, bundle); err != nil {
+			return fmt.Errorf("decoding forensics bundle of %x: %w", hash, err)
+		}
+		bundles = append(bundles, bundle)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
@@ -0,0 +1,205 @@
+package forensics_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/direct"
+	"github.com/erigontech/erigon-lib/gointerfaces/execution"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/execution/eth1/eth1_chain_reader.go"
+	"github.com/erigontech/erigon/turbo/forensics"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+var (
+	bankKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bankAddress = crypto.PubkeyToAddress(bankKey.PublicKey)
+	// the runtime code increments the slot 0
+	counterCode = hexutil.MustDecode("0x60005460010160005500")
+	counterInit = append(hexutil.MustDecode("0x600a600c600039600a6000f3"), counterCode...)
+)
+
+// counterChain deploys the counter in block 1 and increments it in the following blocks.
+func counterChain(t *testing.T, n int) (*mock.MockSentry, *core.ChainPack, libcommon.Address) {
+	gspec := &types.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  types.GenesisAlloc{bankAddress: {Balance: big.NewInt(1e18)}},
+	}
+	m := mock.MockWithGenesis(t, gspec, bankKey, false)
+	signer := types.LatestSignerForChainID(nil)
+	counter := crypto.CreateAddress(bankAddress, 0)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, n, func(i int, b *core.BlockGen) {
+		var txn types.Transaction
+		if i == 0 {
+			txn = types.NewContractCreation(b.TxNonce(bankAddress), new(uint256.Int), 1e6, new(uint256.Int), counterInit)
+		} else {
+			txn = types.NewTransaction(b.TxNonce(bankAddress), counter, new(uint256.Int), 1e6, new(uint256.Int), nil)
+		}
+		signed, err := types.SignTx(txn, *signer, bankKey)
+		require.NoError(t, err)
+		b.AddTx(signed)
+	})
+	require.NoError(t, err)
+	return m, chain, counter
+}
+
+// withGasUsed returns a copy of the block with a wrong gas used in its header.
+func withGasUsed(block *types.Block, gasUsed uint64) *types.Block {
+	header := types.CopyHeader(block.Header())
+	header.GasUsed = gasUsed
+	return types.NewBlockFromStorage(header.Hash(), header, block.Transactions(), block.Uncles(), block.Withdrawals())
+}
+
+func TestValidateChainRecordsBadBlock(t *testing.T) {
+	m, chain, counter := counterChain(t, 3)
+	require.NoError(t, m.InsertChain(chain.Slice(0, 2)))
+	bad := withGasUsed(chain.Blocks[2], chain.Blocks[2].GasUsed()+1)
+
+	ctx := context.Background()
+	wr := eth1_chain_reader.NewChainReaderEth1(m.ChainConfig, direct.NewExecutionClientDirect(m.Eth1ExecutionService), uint64(time.Hour))
+	require.NoError(t, wr.InsertBlocksAndWait(ctx, []*types.Block{bad}))
+	status, validationErr, _, err := wr.ValidateChain(ctx, bad.Hash(), bad.NumberU64())
+	require.NoError(t, err)
+	require.Equal(t, execution.ExecutionStatus_BadBlock, status)
+	require.NotNil(t, validationErr)
+	m.Eth1ExecutionService.BadBlocks().Wait()
+
+	tx, err := m.DB.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	bundle, err := forensics.Read(tx, bad.Hash())
+	require.NoError(t, err)
+	require.NotNil(t, bundle)
+	require.Equal(t, uint64(3), bundle.BlockNumber)
+	require.Equal(t, *validationErr, bundle.Error)
+	require.Equal(t, chain.Blocks[1].Hash(), bundle.ParentHeader.Hash())
+	require.Empty(t, bundle.PrestateError)
+	require.Nil(t, bundle.FailedTx)
+	require.Contains(t, bundle.ReplayError, "gas used")
+	require.Len(t, bundle.Receipts, 1)
+	require.Equal(t, m.ChainConfig.ChainID, bundle.ChainConfig.ChainID)
+	require.True(t, bundle.Forks.IsBerlin)
+
+	// the prestate is the state after block 2
+	require.Equal(t, uint64(2), bundle.Prestate[bankAddress].Nonce)
+	// the fields one by one, a zero balance does not survive the json round trip as new(big.Int)
+	account := bundle.Prestate[counter]
+	require.Zero(t, account.Balance.Sign())
+	require.Equal(t, uint64(1), account.Nonce)
+	require.Equal(t, counterCode, account.Code)
+	require.Equal(t, map[libcommon.Hash]libcommon.Hash{{}: libcommon.BigToHash(big.NewInt(1))}, account.Storage)
+
+	block, err := bundle.DecodeBlock()
+	require.NoError(t, err)
+	require.Equal(t, bad.Hash(), block.Hash())
+	bundles, err := forensics.List(tx)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+}
+
+func TestBuildFailedTx(t *testing.T) {
+	m, chain, _ := counterChain(t, 1)
+	require.NoError(t, m.InsertChain(chain))
+
+	// a transaction of an account without funds on top of the genesis
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	txn, err := types.SignTx(types.NewTransaction(0, bankAddress, uint256.NewInt(1), 21000, uint256.NewInt(1), nil), *types.LatestSignerForChainID(nil), key)
+	require.NoError(t, err)
+	header := types.CopyHeader(chain.Blocks[0].Header())
+	bad := types.NewBlock(header, types.Transactions{txn}, nil, nil, nil)
+
+	tx, err := m.DB.BeginRw(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	bundle, err := forensics.Build(context.Background(), tx, m.ChainConfig, m.Engine, m.BlockReader, bad, errors.New("invalid block"), m.Log)
+	require.NoError(t, err)
+	require.Equal(t, "invalid block", bundle.Error)
+	require.NotNil(t, bundle.FailedTx)
+	require.Equal(t, 0, *bundle.FailedTx)
+	require.Contains(t, bundle.ReplayError, "insufficient funds")
+	require.Empty(t, bundle.Receipts)
+	require.NotContains(t, bundle.Prestate, crypto.PubkeyToAddress(key.PublicKey))
+
+	dir := filepath.Join(t.TempDir(), "t8n")
+	require.NoError(t, bundle.ExportT8n(dir))
+	for _, name := range []string{"alloc.json", "env.json", "txs.json", "block.rlp", "parentHeader.json", "receipts.json", "chainConfig.json", "forensics.json"} {
+		require.FileExists(t, filepath.Join(dir, name))
+	}
+	var env map[string]interface{}
+	readJSON(t, filepath.Join(dir, "env.json"), &env)
+	require.Equal(t, "0x1", env["currentNumber"])
+	var txs []map[string]interface{}
+	readJSON(t, filepath.Join(dir, "txs.json"), &txs)
+	require.Len(t, txs, 1)
+	require.Equal(t, "0x5208", txs[0]["gas"])
+	var summary map[string]interface{}
+	readJSON(t, filepath.Join(dir, "forensics.json"), &summary)
+	require.EqualValues(t, 0, summary["failedTx"])
+	require.NotEmpty(t, summary["fork"])
+	raw, err := os.ReadFile(filepath.Join(dir, "block.rlp"))
+	require.NoError(t, err)
+	require.Equal(t, []byte(bundle.Block), raw)
+
+	// the oldest bundles are pruned
+	for i := uint64(1); i <= 3; i++ {
+		require.NoError(t, rawdb.WriteBadBlockForensics(tx, libcommon.Hash{byte(i)}, i, []byte("{}"), 2))
+	}
+	var numbers []uint64
+	require.NoError(t, rawdb.ForEachBadBlockForensics(tx, func(number uint64, _ libcommon.Hash, _ []byte) error {
+		numbers = append(numbers, number)
+		return nil
+	}))
+	require.Equal(t, []uint64{2, 3}, numbers)
+}
+
+func readJSON(t *testing.T, path string, v interface{}) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
+
+func TestRecordPayload(t *testing.T) {
+	m, chain, _ := counterChain(t, 1)
+	require.NoError(t, m.InsertChain(chain))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := forensics.NewRecorder(ctx, m.DB, m.ChainConfig, m.Engine, m.BlockReader, m.Log)
+	hash := libcommon.Hash{0x0b, 0xad}
+	payload := map[string]interface{}{"blockHash": hash, "transactions": []string{"0x01"}}
+	recorder.RecordPayload(2, hash, chain.Blocks[0].Hash(), chain.Blocks[0].Time()+1, payload, errors.New("rlp: bad transaction"))
+	recorder.Wait()
+
+	tx, err := m.DB.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	// without a header, the bundle is found by the keys of the table
+	bundle, err := forensics.Read(tx, hash)
+	require.NoError(t, err)
+	require.NotNil(t, bundle)
+	require.Equal(t, uint64(2), bundle.BlockNumber)
+	require.Equal(t, "rlp: bad transaction", bundle.Error)
+	require.Equal(t, chain.Blocks[0].Hash(), bundle.ParentHeader.Hash())
+	require.Empty(t, bundle.Block)
+	require.JSONEq(t, `{"blockHash":"0x0bad000000000000000000000000000000000000000000000000000000000000","transactions":["0x01"]}`, string(bundle.Payload))
+	_, err = bundle.DecodeBlock()
+	require.ErrorContains(t, err, "could not be decoded")
+}
@@ -0,0 +1,145 @@
+package forensics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/turbo/services"
+)
+
+// queueSize is the number of rejected blocks waiting for their bundle, the blocks rejected beyond it are dropped.
+const queueSize = 16
+
+// Recorder builds and stores the bundles of the rejected blocks in the background, so that the replay of a bad
+// block stays off the payload validation path. The bundles are built one at a time, in their own transactions,
+// on the state of the parents as committed.
+type Recorder struct {
+	db          kv.RwDB
+	config      *chain.Config
+	engine      consensus.Engine
+	blockReader services.FullBlockReader
+	logger      log.Logger
+
+	queue   chan job
+	pending sync.WaitGroup
+}
+
+// job builds the bundle of a rejected block.
+type job struct {
+	number uint64
+	hash   libcommon.Hash
+	build  func(ctx context.Context) (*Bundle, error)
+}
+
+// NewRecorder starts the recorder, until ctx is done.
+func NewRecorder(ctx context.Context, db kv.RwDB, config *chain.Config, engine consensus.Engine, blockReader services.FullBlockReader, logger log.Logger) *Recorder {
+	r := &Recorder{
+		db:          db,
+		config:      config,
+		engine:      engine,
+		blockReader: blockReader,
+		logger:      logger,
+		queue:       make(chan job, queueSize),
+	}
+	go r.loop(ctx)
+	return r
+}
+
+// RecordBlock queues the bundle of the block rejected with validationErr.
+func (r *Recorder) RecordBlock(block *types.Block, validationErr error) {
+	r.enqueue(block.NumberU64(), block.Hash(), func(ctx context.Context) (bundle *Bundle, err error) {
+		err = r.db.View(ctx, func(tx kv.Tx) error {
+			bundle, err = Build(ctx, tx, r.config, r.engine, r.blockReader, block, validationErr, r.logger)
+			return err
+		})
+		return bundle, err
+	})
+}
+
+// RecordPayload queues the bundle of a payload rejected before it could be decoded into a block. The bundle keeps
+// the payload as received (its json) in place of the block.
+func (r *Recorder) RecordPayload(number uint64, hash, parentHash libcommon.Hash, time uint64, payload interface{}, validationErr error) {
+	r.enqueue(number, hash, func(ctx context.Context) (*Bundle, error) {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		bundle := &Bundle{
+			BlockHash:   hash,
+			BlockNumber: number,
+			Payload:     raw,
+			Receipts:    types.Receipts{},
+			ChainConfig: r.config,
+			Forks:       forks(r.config, number, time),
+		}
+		if validationErr != nil {
+			bundle.Error = validationErr.Error()
+		}
+		if number > 0 {
+			if err := r.db.View(ctx, func(tx kv.Tx) error {
+				bundle.ParentHeader, err = r.blockReader.Header(ctx, tx, parentHash, number-1)
+				return err
+			}); err != nil {
+				return nil, err
+			}
+		}
+		return bundle, nil
+	})
+}
+
+// Wait waits for the queued bundles to be stored.
+func (r *Recorder) Wait() {
+	r.pending.Wait()
+}
+
+func (r *Recorder) enqueue(number uint64, hash libcommon.Hash, build func(ctx context.Context) (*Bundle, error)) {
+	r.pending.Add(1)
+	select {
+	case r.queue <- job{number: number, hash: hash, build: build}:
+	default:
+		r.pending.Done()
+		r.logger.Warn("Could not record bad block forensics", "number", number, "hash", hash, "err", fmt.Errorf("queue of %d blocks is full", queueSize))
+	}
+}
+
+func (r *Recorder) loop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case <-r.queue:
+					r.pending.Done()
+				default:
+					return
+				}
+			}
+		case j := <-r.queue:
+			r.record(ctx, j)
+			r.pending.Done()
+		}
+	}
+}
+
+func (r *Recorder) record(ctx context.Context, j job) {
+	bundle, err := j.build(ctx)
+	if err == nil {
+		err = r.db.Update(ctx, func(tx kv.RwTx) error {
+			return Write(tx, bundle)
+		})
+	}
+	if err != nil {
+		r.logger.Warn("Could not record bad block forensics", "number", j.number, "hash", j.hash, "err", err)
+		return
+	}
+	r.logger.Info("Recorded bad block forensics", "number", bundle.BlockNumber, "hash", bundle.BlockHash, "replayErr", bundle.ReplayError)
+}
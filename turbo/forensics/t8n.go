@@ -0,0 +1,146 @@
+package forensics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	common0 "github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/common/math"
+	"github.com/erigontech/erigon/core/types"
+)
+
+// t8nEnv is the env.json input of the t8n tool.
+type t8nEnv struct {
+	Coinbase         common0.UnprefixedAddress              `json:"currentCoinbase"`
+	Difficulty       *math.HexOrDecimal256                  `json:"currentDifficulty"`
+	Random           *math.HexOrDecimal256                  `json:"currentRandom,omitempty"`
+	ParentDifficulty *math.HexOrDecimal256                  `json:"parentDifficulty"`
+	GasLimit         math.HexOrDecimal64                    `json:"currentGasLimit"`
+	Number           math.HexOrDecimal64                    `json:"currentNumber"`
+	Timestamp        math.HexOrDecimal64                    `json:"currentTimestamp"`
+	ParentTimestamp  math.HexOrDecimal64                    `json:"parentTimestamp"`
+	BlockHashes      map[math.HexOrDecimal64]libcommon.Hash `json:"blockHashes,omitempty"`
+	BaseFee          *math.HexOrDecimal256                  `json:"currentBaseFee,omitempty"`
+	ParentUncleHash  libcommon.Hash                         `json:"parentUncleHash"`
+	Withdrawals      []*types.Withdrawal                    `json:"withdrawals,omitempty"`
+}
+
+// t8nSummary is the forensics.json of the exported directory, what the t8n inputs leave out.
+type t8nSummary struct {
+	BlockHash     libcommon.Hash `json:"blockHash"`
+	BlockNumber   uint64         `json:"blockNumber"`
+	Error         string         `json:"error"`
+	PrestateError string         `json:"prestateError,omitempty"`
+	FailedTx      *int           `json:"failedTx,omitempty"`
+	ReplayError   string         `json:"replayError,omitempty"`
+	Fork          string         `json:"fork"` // the --state.fork of the t8n tool
+	Forks         Forks          `json:"forks"`
+}
+
+// ExportT8n writes the bundle to dir as the alloc.json, env.json and txs.json inputs of the t8n tool, next to
+// the raw block (block.rlp), the parent header, the receipts up to the failure, the chain config and a summary of
+// the failure with the fork to run (forensics.json).
+func (b *Bundle) ExportT8n(dir string) error {
+	block, err := b.DecodeBlock()
+	if err != nil {
+		return err
+	}
+	header := block.Header()
+	env := &t8nEnv{
+		Coinbase:         common0.UnprefixedAddress(header.Coinbase),
+		Difficulty:       (*math.HexOrDecimal256)(header.Difficulty),
+		ParentDifficulty: (*math.HexOrDecimal256)(b.ParentHeader.Difficulty),
+		GasLimit:         math.HexOrDecimal64(header.GasLimit),
+		Number:           math.HexOrDecimal64(header.Number.Uint64()),
+		Timestamp:        math.HexOrDecimal64(header.Time),
+		ParentTimestamp:  math.HexOrDecimal64(b.ParentHeader.Time),
+		BlockHashes:      b.BlockHashes,
+		BaseFee:          (*math.HexOrDecimal256)(header.BaseFee),
+		ParentUncleHash:  b.ParentHeader.UncleHash,
+		Withdrawals:      block.Withdrawals(),
+	}
+	if header.Difficulty.Sign() == 0 {
+		env.Random = (*math.HexOrDecimal256)(new(big.Int).SetBytes(header.MixDigest.Bytes()))
+	}
+	txs := block.Transactions()
+	if txs == nil {
+		txs = types.Transactions{}
+	}
+	alloc := b.Prestate
+	if alloc == nil {
+		alloc = types.GenesisAlloc{}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, v := range map[string]interface{}{
+		"alloc.json":        alloc,
+		"env.json":          env,
+		"txs.json":          txs,
+		"parentHeader.json": b.ParentHeader,
+		"receipts.json":     b.Receipts,
+		"chainConfig.json":  b.ChainConfig,
+		"forensics.json": &t8nSummary{
+			BlockHash:     b.BlockHash,
+			BlockNumber:   b.BlockNumber,
+			Error:         b.Error,
+			PrestateError: b.PrestateError,
+			FailedTx:      b.FailedTx,
+			ReplayError:   b.ReplayError,
+			Fork:          b.Forks.t8nFork(header.Difficulty.Sign() == 0),
+			Forks:         b.Forks,
+		},
+	} {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "block.rlp"), b.Block, 0644)
+}
+
+// t8nFork returns the latest Ethereum fork of the flags known to the t8n tool, merged for a block of the proof of
+// stake. The OP Stack forks have no t8n equivalent, they are in forensics.json.
+func (f Forks) t8nFork(merged bool) string {
+	switch {
+	case f.Rules == nil:
+		return ""
+	case f.IsPrague:
+		return "Prague"
+	case f.IsCancun:
+		return "Cancun"
+	case f.IsShanghai:
+		return "Shanghai"
+	case f.IsLondon && merged:
+		return "Paris"
+	case f.IsLondon:
+		return "London"
+	case f.IsBerlin:
+		return "Berlin"
+	case f.IsIstanbul:
+		return "Istanbul"
+	case f.IsPetersburg:
+		return "ConstantinopleFix"
+	case f.IsConstantinople:
+		return "Constantinople"
+	case f.IsByzantium:
+		return "Byzantium"
+	case f.IsSpuriousDragon:
+		return "EIP158"
+	case f.IsTangerineWhistle:
+		return "EIP150"
+	case f.IsHomestead:
+		return "Homestead"
+	default:
+		return "Frontier"
+	}
+}
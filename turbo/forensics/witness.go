@@ -0,0 +1,106 @@
+package forensics
+
+import (
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+)
+
+// witnessReader records the state read through it. The intra block state reads every object once, before
+// modifying it, so the first read is the prestate of the block.
+type witnessReader struct {
+	state.StateReader
+	accounts map[libcommon.Address]*accounts.Account
+	storage  map[libcommon.Address]map[libcommon.Hash]libcommon.Hash
+	code     map[libcommon.Address][]byte
+}
+
+func newWitnessReader(r state.StateReader) *witnessReader {
+	return &witnessReader{
+		StateReader: r,
+		accounts:    make(map[libcommon.Address]*accounts.Account),
+		storage:     make(map[libcommon.Address]map[libcommon.Hash]libcommon.Hash),
+		code:        make(map[libcommon.Address][]byte),
+	}
+}
+
+func (r *witnessReader) ReadAccountData(address libcommon.Address) (*accounts.Account, error) {
+	a, err := r.StateReader.ReadAccountData(address)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := r.accounts[address]; !ok {
+		if a != nil {
+			r.accounts[address] = new(accounts.Account)
+			r.accounts[address].Copy(a)
+		} else {
+			r.accounts[address] = nil
+		}
+	}
+	return a, nil
+}
+
+func (r *witnessReader) ReadAccountStorage(address libcommon.Address, incarnation uint64, key *libcommon.Hash) ([]byte, error) {
+	v, err := r.StateReader.ReadAccountStorage(address, incarnation, key)
+	if err != nil {
+		return nil, err
+	}
+	storage, ok := r.storage[address]
+	if !ok {
+		storage = make(map[libcommon.Hash]libcommon.Hash)
+		r.storage[address] = storage
+	}
+	if _, ok := storage[*key]; !ok {
+		storage[*key] = libcommon.BytesToHash(v)
+	}
+	return v, nil
+}
+
+func (r *witnessReader) ReadAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash) ([]byte, error) {
+	code, err := r.StateReader.ReadAccountCode(address, incarnation, codeHash)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := r.code[address]; !ok {
+		r.code[address] = libcommon.CopyBytes(code)
+	}
+	return code, nil
+}
+
+func (r *witnessReader) ReadAccountCodeSize(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash) (int, error) {
+	// the size is all the EVM needs, the prestate needs the code
+	code, err := r.ReadAccountCode(address, incarnation, codeHash)
+	if err != nil {
+		return 0, err
+	}
+	return len(code), nil
+}
+
+// alloc returns the prestate of the accounts read, in the format of the t8n tool. The accounts that did not
+// exist are left out.
+func (r *witnessReader) alloc() types.GenesisAlloc {
+	alloc := make(types.GenesisAlloc, len(r.accounts))
+	for address, a := range r.accounts {
+		if a == nil {
+			continue
+		}
+		account := types.GenesisAccount{
+			Balance: a.Balance.ToBig(),
+			Nonce:   a.Nonce,
+			Code:    r.code[address],
+		}
+		for key, value := range r.storage[address] {
+			if value == (libcommon.Hash{}) {
+				continue
+			}
+			if account.Storage == nil {
+				account.Storage = make(map[libcommon.Hash]libcommon.Hash)
+			}
+			account.Storage[key] = value
+		}
+		alloc[address] = account
+	}
+	return alloc
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/erigontech/erigon-lib/gointerfaces/remote"
+	"github.com/erigontech/erigon-lib/txpool/txpoolpolicy"
 	"github.com/erigontech/erigon/p2p"
 
 	"github.com/erigontech/erigon/turbo/rpchelper"
@@ -22,17 +23,28 @@ type AdminAPI interface {
 
 	// AddPeer requests connecting to a remote node.
 	AddPeer(ctx context.Context, url string) (bool, error)
+
+	// TxPoolPolicy returns the active txpool address and contract filter policy.
+	TxPoolPolicy(ctx context.Context) (*txpoolpolicy.Rules, error)
+
+	// SetTxPoolPolicy replaces the txpool policy (and its file, if any).
+	SetTxPoolPolicy(ctx context.Context, rules txpoolpolicy.Rules) (bool, error)
+
+	// ReloadTxPoolPolicy re-reads the txpool policy file.
+	ReloadTxPoolPolicy(ctx context.Context) (bool, error)
 }
 
 // AdminAPIImpl data structure to store things needed for admin_* commands.
 type AdminAPIImpl struct {
-	ethBackend rpchelper.ApiBackend
+	ethBackend   rpchelper.ApiBackend
+	txPoolPolicy *txpoolpolicy.Engine
 }
 
 // NewAdminAPI returns AdminAPIImpl instance.
-func NewAdminAPI(eth rpchelper.ApiBackend) *AdminAPIImpl {
+func NewAdminAPI(eth rpchelper.ApiBackend, txPoolPolicy *txpoolpolicy.Engine) *AdminAPIImpl {
 	return &AdminAPIImpl{
-		ethBackend: eth,
+		ethBackend:   eth,
+		txPoolPolicy: txPoolPolicy,
 	}
 }
 
@@ -63,3 +75,33 @@ func (api *AdminAPIImpl) AddPeer(ctx context.Context, url string) (bool, error)
 	}
 	return result.Success, nil
 }
+
+var errTxPoolPolicyNotAvailable = errors.New("txpool policy is not available: it requires the embedded txpool and --txpool.policy")
+
+func (api *AdminAPIImpl) TxPoolPolicy(ctx context.Context) (*txpoolpolicy.Rules, error) {
+	if api.txPoolPolicy == nil {
+		return nil, errTxPoolPolicyNotAvailable
+	}
+	rules := api.txPoolPolicy.Rules()
+	return &rules, nil
+}
+
+func (api *AdminAPIImpl) SetTxPoolPolicy(ctx context.Context, rules txpoolpolicy.Rules) (bool, error) {
+	if api.txPoolPolicy == nil {
+		return false, errTxPoolPolicyNotAvailable
+	}
+	if err := api.txPoolPolicy.SetRules(rules); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (api *AdminAPIImpl) ReloadTxPoolPolicy(ctx context.Context) (bool, error) {
+	if api.txPoolPolicy == nil {
+		return false, errTxPoolPolicyNotAvailable
+	}
+	if err := api.txPoolPolicy.Reload(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,180 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/rpc"
+)
+
+// BobaAPI locates the deposits on L2, from the index built by the DepositLookup stage, enabled by
+// --rollup.depositindex.
+type BobaAPI interface {
+	GetDepositBySourceHash(ctx context.Context, sourceHash libcommon.Hash) (*DepositLocation, error)
+	GetDepositsByL1Tx(ctx context.Context, l1TxHash libcommon.Hash) ([]*DepositLocation, error)
+}
+
+// DepositLocation is the location of a deposit transaction on L2, and of its origin on L1.
+type DepositLocation struct {
+	SourceHash        libcommon.Hash  `json:"sourceHash"`
+	BlockHash         libcommon.Hash  `json:"blockHash"`
+	BlockNumber       hexutil.Uint64  `json:"blockNumber"`
+	TransactionHash   libcommon.Hash  `json:"transactionHash"`
+	TransactionIndex  hexutil.Uint64  `json:"transactionIndex"`
+	L1BlockHash       libcommon.Hash  `json:"l1BlockHash"`   // L1 origin of the L2 block
+	L1BlockNumber     hexutil.Uint64  `json:"l1BlockNumber"` // L1 origin of the L2 block
+	L1LogIndex        *hexutil.Uint64 `json:"l1LogIndex,omitempty"`
+	L1TransactionHash *libcommon.Hash `json:"l1TransactionHash,omitempty"`
+}
+
+// BobaAPIImpl is implementation of the BobaAPI interface, resolving the L1 transactions with the L1 endpoint of
+// --rollup.l1rpc.
+type BobaAPIImpl struct {
+	*BaseAPI
+	db     kv.RoDB
+	l1RPC  string
+	logger log.Logger
+
+	l1Lock sync.Mutex
+	l1     *rpc.Client // dialed on first use
+}
+
+// NewBobaAPI returns BobaAPIImpl instance
+func NewBobaAPI(base *BaseAPI, db kv.RoDB, l1RPC string, logger log.Logger) *BobaAPIImpl {
+	return &BobaAPIImpl{
+		BaseAPI: base,
+		db:      db,
+		l1RPC:   l1RPC,
+		logger:  logger,
+	}
+}
+
+var errNoDepositIndex = errors.New("deposit index is not built, see --rollup.depositindex")
+
+// GetDepositBySourceHash implements boba_getDepositBySourceHash. Returns the location of the deposit of the given
+// source hash, nil if it did not land on L2 (yet).
+func (api *BobaAPIImpl) GetDepositBySourceHash(ctx context.Context, sourceHash libcommon.Hash) (*DepositLocation, error) {
+	tx, err := api.beginIndexed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return api.depositLocation(ctx, tx, sourceHash)
+}
+
+// GetDepositsByL1Tx implements boba_getDepositsByL1Tx. Returns the locations of the deposits of the given L1
+// transaction, empty if none landed on L2 (yet), and nil if the L1 transaction is not found.
+func (api *BobaAPIImpl) GetDepositsByL1Tx(ctx context.Context, l1TxHash libcommon.Hash) ([]*DepositLocation, error) {
+	l1, err := api.l1Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var receipt *struct {
+		BlockHash libcommon.Hash `json:"blockHash"`
+		Logs      []struct {
+			LogIndex hexutil.Uint64 `json:"logIndex"`
+		} `json:"logs"`
+	}
+	if err := l1.CallContext(ctx, &receipt, "eth_getTransactionReceipt", l1TxHash); err != nil {
+		return nil, fmt.Errorf("L1 receipt: %w", err)
+	}
+	if receipt == nil {
+		return nil, nil
+	}
+
+	tx, err := api.beginIndexed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// the user deposits are derived from the TransactionDeposited logs, other logs are not found
+	locations := []*DepositLocation{}
+	for _, l := range receipt.Logs {
+		location, err := api.depositLocation(ctx, tx, opstack.UserDepositSource(receipt.BlockHash, uint64(l.LogIndex)))
+		if err != nil {
+			return nil, err
+		}
+		if location != nil {
+			location.L1TransactionHash = &l1TxHash
+			locations = append(locations, location)
+		}
+	}
+	return locations, nil
+}
+
+func (api *BobaAPIImpl) l1Client(ctx context.Context) (*rpc.Client, error) {
+	if api.l1RPC == "" {
+		return nil, errors.New("no L1 endpoint, see --rollup.l1rpc")
+	}
+	api.l1Lock.Lock()
+	defer api.l1Lock.Unlock()
+	if api.l1 == nil {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		client, err := rpc.DialContext(ctx, api.l1RPC, api.logger)
+		if err != nil {
+			return nil, fmt.Errorf("dial L1: %w", err)
+		}
+		api.l1 = client
+	}
+	return api.l1, nil
+}
+
+func (api *BobaAPIImpl) beginIndexed(ctx context.Context) (kv.Tx, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := stages.GetStageProgress(tx, stages.DepositLookup)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if progress == 0 {
+		tx.Rollback()
+		return nil, errNoDepositIndex
+	}
+	return tx, nil
+}
+
+func (api *BobaAPIImpl) depositLocation(ctx context.Context, tx kv.Tx, sourceHash libcommon.Hash) (*DepositLocation, error) {
+	entry, err := rawdb.ReadDepositLookupEntry(tx, sourceHash)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	blockHash, err := api._blockReader.CanonicalHash(ctx, tx, entry.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	txn, err := api._blockReader.TxnByIdxInBlock(ctx, tx, entry.BlockNumber, int(entry.TxIndex))
+	if err != nil {
+		return nil, err
+	}
+	if txn == nil {
+		return nil, fmt.Errorf("transaction %d of block %d not found", entry.TxIndex, entry.BlockNumber)
+	}
+	location := &DepositLocation{
+		SourceHash:       sourceHash,
+		BlockHash:        blockHash,
+		BlockNumber:      hexutil.Uint64(entry.BlockNumber),
+		TransactionHash:  txn.Hash(),
+		TransactionIndex: hexutil.Uint64(entry.TxIndex),
+		L1BlockHash:      entry.L1BlockHash,
+		L1BlockNumber:    hexutil.Uint64(entry.L1BlockNumber),
+	}
+	if entry.L1LogIndex != nil {
+		location.L1LogIndex = (*hexutil.Uint64)(entry.L1LogIndex)
+	}
+	return location, nil
+}
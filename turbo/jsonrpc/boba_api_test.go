@@ -0,0 +1,94 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/rpc"
+)
+
+// fakeL1 serves the receipt of a deposit transaction, with its TransactionDeposited log at index 2.
+type fakeL1 struct {
+	txHash, blockHash libcommon.Hash
+}
+
+func (l1 *fakeL1) GetTransactionReceipt(hash libcommon.Hash) (map[string]interface{}, error) {
+	if hash != l1.txHash {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"blockHash": l1.blockHash,
+		"logs":      []map[string]interface{}{{"logIndex": hexutil.Uint64(1)}, {"logIndex": hexutil.Uint64(2)}},
+	}, nil
+}
+
+func TestBobaGetDeposits(t *testing.T) {
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	ctx := context.Background()
+	l1 := &fakeL1{txHash: libcommon.HexToHash("0xaa"), blockHash: libcommon.HexToHash("0xbb")}
+	server := rpc.NewServer(50, false, false, true, log.New(), 100)
+	require.NoError(t, server.RegisterName("eth", l1))
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	api := NewBobaAPI(newBaseApiForTest(m), m.DB, httpServer.URL, log.New())
+
+	sourceHash := opstack.UserDepositSource(l1.blockHash, 2)
+	_, err := api.GetDepositBySourceHash(ctx, sourceHash)
+	require.ErrorIs(t, err, errNoDepositIndex)
+
+	// the deposit landed as the first transaction of block 1
+	logIndex := uint64(2)
+	require.NoError(t, m.DB.Update(ctx, func(tx kv.RwTx) error {
+		entry := &rawdb.DepositLookupEntry{BlockNumber: 1, L1BlockNumber: 42, L1BlockHash: l1.blockHash, L1LogIndex: &logIndex}
+		if err := tx.Put(kv.DepositLookup, sourceHash.Bytes(), rawdb.EncodeDepositLookupEntry(entry)); err != nil {
+			return err
+		}
+		return stages.SaveStageProgress(tx, stages.DepositLookup, 1)
+	}))
+	tx, err := m.DB.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	block, err := m.BlockReader.BlockByNumber(ctx, tx, 1)
+	require.NoError(t, err)
+	tx.Rollback()
+	expected := &DepositLocation{
+		SourceHash:       sourceHash,
+		BlockHash:        block.Hash(),
+		BlockNumber:      1,
+		TransactionHash:  block.Transactions()[0].Hash(),
+		TransactionIndex: 0,
+		L1BlockHash:      l1.blockHash,
+		L1BlockNumber:    42,
+		L1LogIndex:       (*hexutil.Uint64)(&logIndex),
+	}
+
+	location, err := api.GetDepositBySourceHash(ctx, sourceHash)
+	require.NoError(t, err)
+	require.Equal(t, expected, location)
+	location, err = api.GetDepositBySourceHash(ctx, libcommon.HexToHash("0x01"))
+	require.NoError(t, err)
+	require.Nil(t, location)
+
+	locations, err := api.GetDepositsByL1Tx(ctx, l1.txHash)
+	require.NoError(t, err)
+	expected.L1TransactionHash = &l1.txHash
+	require.Equal(t, []*DepositLocation{expected}, locations)
+	locations, err = api.GetDepositsByL1Tx(ctx, libcommon.HexToHash("0x01"))
+	require.NoError(t, err)
+	require.Nil(t, locations)
+
+	_, err = NewBobaAPI(newBaseApiForTest(m), m.DB, "", log.New()).GetDepositsByL1Tx(ctx, l1.txHash)
+	require.ErrorContains(t, err, "--rollup.l1rpc")
+}
@@ -2,6 +2,7 @@ package jsonrpc
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 	"testing"
 
@@ -258,3 +259,49 @@ func TestFilterAddressIntersection(t *testing.T) {
 		require.Empty(t, blockNumbersFromTraces(t, stream.Buffer()))
 	})
 }
+
+func TestFilterStream(t *testing.T) {
+	m := mock.Mock(t)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 10, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{1})
+	})
+	require.NoError(t, err, "generate chain")
+	require.NoError(t, m.InsertChain(chain), "inserting chain")
+
+	fromBlock, toBlock := uint64(1), uint64(10)
+	req := TraceFilterRequest{FromBlock: (*hexutil.Uint64)(&fromBlock), ToBlock: (*hexutil.Uint64)(&toBlock)}
+	// pages resumes the stream with the cursor until it is complete, and returns the block numbers of the rewards
+	pages := func(cfg *httpcfg.HttpCfg) [][]int {
+		api := NewTraceAPI(newBaseApiForTest(m), m.DB, cfg)
+		var cursor *StreamCursor
+		var pages [][]int
+		for {
+			stream := jsoniter.ConfigDefault.BorrowStream(nil)
+			require.NoError(t, api.FilterStream(context.Background(), req, cursor, new(bool), nil, stream))
+			var response struct {
+				Traces []struct {
+					BlockNumber int `json:"blockNumber"`
+				} `json:"traces"`
+				Cursor *StreamCursor `json:"cursor"`
+			}
+			require.NoError(t, json.Unmarshal(stream.Buffer(), &response))
+			jsoniter.ConfigDefault.ReturnStream(stream)
+			numbers := []int{}
+			for _, trace := range response.Traces {
+				numbers = append(numbers, trace.BlockNumber)
+			}
+			pages = append(pages, numbers)
+			if cursor = response.Cursor; cursor == nil {
+				return pages
+			}
+		}
+	}
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}, pages(&httpcfg.HttpCfg{}))
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}, {10}}, pages(&httpcfg.HttpCfg{StreamMaxResults: 3}))
+	assert.Equal(t, [][]int{{1, 2, 3, 4}, {5, 6, 7, 8}, {9, 10}}, pages(&httpcfg.HttpCfg{StreamMaxBlocks: 4}))
+
+	req.After = new(uint64)
+	stream := jsoniter.ConfigDefault.BorrowStream(nil)
+	defer jsoniter.ConfigDefault.ReturnStream(stream)
+	require.Error(t, NewTraceAPI(newBaseApiForTest(m), m.DB, &httpcfg.HttpCfg{}).FilterStream(context.Background(), req, nil, new(bool), nil, stream))
+}
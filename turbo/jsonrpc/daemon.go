@@ -8,6 +8,8 @@ import (
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 	libstate "github.com/erigontech/erigon-lib/state"
+	txpool2 "github.com/erigontech/erigon-lib/txpool"
+	"github.com/erigontech/erigon-lib/txpool/txpoolpolicy"
 	"github.com/erigontech/erigon/cmd/rpcdaemon/cli/httpcfg"
 	"github.com/erigontech/erigon/consensus"
 	"github.com/erigontech/erigon/consensus/clique"
@@ -21,10 +23,11 @@ import (
 func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, mining txpool.MiningClient,
 	filters *rpchelper.Filters, stateCache kvcache.Cache,
 	blockReader services.FullBlockReader, agg *libstate.Aggregator, cfg *httpcfg.HttpCfg, engine consensus.EngineReader,
-	seqRPCService, historicalRPCService *rpc.Client, logger log.Logger,
+	seqRPCService, historicalRPCService *rpc.Client, txPoolPolicy *txpoolpolicy.Engine, bundles *txpool2.BundlePool, logger log.Logger,
 ) (list []rpc.API) {
 	base := NewBaseApi(filters, stateCache, blockReader, agg, cfg.WithDatadir, cfg.EvmCallTimeout, engine, cfg.Dirs, seqRPCService, historicalRPCService)
 	ethImpl := NewEthAPI(base, db, eth, txPool, mining, cfg.Gascap, cfg.Feecap, cfg.ReturnDataLimit, cfg.AllowUnprotectedTxs, cfg.MaxGetProofRewindBlockCount, cfg.WebsocketSubscribeLogsChannelSize, logger)
+	ethImpl.StreamLimits = NewStreamLimits(cfg)
 	erigonImpl := NewErigonAPI(base, db, eth)
 	txpoolImpl := NewTxPoolAPI(base, db, txPool)
 	netImpl := NewNetAPIImpl(eth)
@@ -32,7 +35,7 @@ func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, m
 	traceImpl := NewTraceAPI(base, db, cfg)
 	web3Impl := NewWeb3APIImpl(eth)
 	dbImpl := NewDBAPIImpl() /* deprecated */
-	adminImpl := NewAdminAPI(eth)
+	adminImpl := NewAdminAPI(eth, txPoolPolicy)
 	parityImpl := NewParityAPIImpl(base, db)
 
 	var borImpl *BorImpl
@@ -52,8 +55,12 @@ func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, m
 	}
 
 	otsImpl := NewOtterscanAPI(base, db, cfg.OtsMaxPageSize)
+	ots2Impl := NewOtterscan2API(base, db, cfg.OtsMaxPageSize)
 	gqlImpl := NewGraphQLAPI(base, db)
 	overlayImpl := NewOverlayAPI(base, db, cfg.Gascap, cfg.OverlayGetLogsTimeout, cfg.OverlayReplayBlockTimeout, otsImpl)
+	optimismImpl := NewOptimismAPI(base, db, ethImpl)
+	bobaImpl := NewBobaAPI(base, db, cfg.RollupL1RPC, logger)
+	bundleImpl := NewBundleAPI(base, db, bundles, cfg.AllowUnprotectedTxs)
 
 	if cfg.GraphQLEnabled {
 		list = append(list, rpc.API{
@@ -73,6 +80,12 @@ func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, m
 				Service:   EthAPI(ethImpl),
 				Version:   "1.0",
 			})
+			list = append(list, rpc.API{
+				Namespace: "eth",
+				Public:    true,
+				Service:   BundleAPI(bundleImpl),
+				Version:   "1.0",
+			})
 		case "debug":
 			list = append(list, rpc.API{
 				Namespace: "debug",
@@ -152,6 +165,13 @@ func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, m
 				Service:   OtterscanAPI(otsImpl),
 				Version:   "1.0",
 			})
+		case "ots2":
+			list = append(list, rpc.API{
+				Namespace: "ots2",
+				Public:    true,
+				Service:   Otterscan2API(ots2Impl),
+				Version:   "1.0",
+			})
 		case "clique":
 			list = append(list, clique.NewCliqueAPI(db, engine, blockReader))
 		case "overlay":
@@ -161,6 +181,20 @@ func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, m
 				Service:   OverlayAPI(overlayImpl),
 				Version:   "1.0",
 			})
+		case "optimism":
+			list = append(list, rpc.API{
+				Namespace: "optimism",
+				Public:    true,
+				Service:   OptimismAPI(optimismImpl),
+				Version:   "1.0",
+			})
+		case "boba":
+			list = append(list, rpc.API{
+				Namespace: "boba",
+				Public:    true,
+				Service:   BobaAPI(bobaImpl),
+				Version:   "1.0",
+			})
 		}
 	}
 
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/holiman/uint256"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -57,6 +58,94 @@ func TestGetLogs(t *testing.T) {
 	}
 }
 
+func TestGetLogsToSafeBlock(t *testing.T) {
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	ethApi := NewEthAPI(newBaseApiForTest(m), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+	setSafe := func(number uint64) {
+		require.NoError(t, m.DB.Update(m.Ctx, func(tx kv.RwTx) error {
+			hash, err := rawdb.ReadCanonicalHash(tx, number)
+			if err != nil {
+				return err
+			}
+			rawdb.WriteForkchoiceSafe(tx, hash)
+			return nil
+		}))
+	}
+	toSafe := filters.FilterCriteria{ToBlock: big.NewInt(rpc.SafeBlockNumber.Int64())}
+
+	_, err := ethApi.GetLogs(m.Ctx, toSafe)
+	require.Error(t, err)
+
+	setSafe(10)
+	logs, err := ethApi.GetLogs(m.Ctx, toSafe)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, uint64(10), logs[0].BlockNumber)
+
+	// the safe block is resolved by every call
+	setSafe(9)
+	logs, err = ethApi.GetLogs(m.Ctx, toSafe)
+	require.NoError(t, err)
+	require.Empty(t, logs)
+
+	logs, err = ethApi.GetLogs(m.Ctx, filters.FilterCriteria{FromBlock: big.NewInt(1), ToBlock: big.NewInt(rpc.SafeBlockNumber.Int64())})
+	require.NoError(t, err)
+	require.Empty(t, logs)
+}
+
+func TestGetLogsStream(t *testing.T) {
+	// each transaction creates a contract, whose init code emits 2 logs
+	initCode := []byte{0x60, 0x00, 0x60, 0x00, 0xa0, 0x60, 0x00, 0x60, 0x00, 0xa0, 0x00} // LOG0(0, 0) LOG0(0, 0) STOP
+	signer := types.LatestSignerForChainID(nil)
+	m := mockWithGenerator(t, 4, func(i int, block *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, err := types.SignTx(types.NewContractCreation(block.TxNonce(testAddr), uint256.NewInt(0), 100_000, nil, initCode), *signer, testKey)
+			require.NoError(t, err)
+			block.AddTx(tx)
+		}
+	})
+	ethApi := NewEthAPI(newBaseApiForTest(m), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+	crit := filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())}
+	expected, err := ethApi.GetLogs(m.Ctx, crit)
+	require.NoError(t, err)
+	require.Len(t, expected, 16)
+
+	for _, limits := range []StreamLimits{{}, {MaxResults: 1}, {MaxBlocks: 3}, {MaxResults: 2, MaxBlocks: 5}} {
+		ethApi.StreamLimits = limits
+		var logs []*types.Log
+		var cursor *StreamCursor
+		responses := 0
+		for {
+			stream := jsoniter.ConfigDefault.BorrowStream(nil)
+			require.NoError(t, ethApi.GetLogsStream(m.Ctx, crit, cursor, stream))
+			var response struct {
+				Logs   []*types.Log  `json:"logs"`
+				Cursor *StreamCursor `json:"cursor"`
+			}
+			require.NoError(t, json.Unmarshal(stream.Buffer(), &response))
+			jsoniter.ConfigDefault.ReturnStream(stream)
+			logs = append(logs, response.Logs...)
+			responses++
+			if cursor = response.Cursor; cursor == nil {
+				break
+			}
+		}
+		require.Equal(t, len(expected), len(logs), "limits %+v", limits)
+		for i := range expected {
+			require.Equal(t, expected[i].BlockNumber, logs[i].BlockNumber)
+			require.Equal(t, expected[i].TxHash, logs[i].TxHash)
+			require.Equal(t, expected[i].Index, logs[i].Index)
+		}
+		if limits.MaxResults == 1 {
+			require.Equal(t, 8, responses) // the transactions are not split
+		}
+	}
+
+	stream := jsoniter.ConfigDefault.BorrowStream(nil)
+	defer jsoniter.ConfigDefault.ReturnStream(stream)
+	require.ErrorContains(t, ethApi.GetLogsStream(m.Ctx, filters.FilterCriteria{FromBlock: big.NewInt(1), ToBlock: big.NewInt(2)}, &StreamCursor{BlockNum: 5}, stream), "out of range")
+}
+
 func TestErigonGetLatestLogs(t *testing.T) {
 	assert := assert.New(t)
 	m, _, _ := rpcdaemontest.CreateTestSentry(t)
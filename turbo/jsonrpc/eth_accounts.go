@@ -37,7 +37,11 @@ func (api *APIImpl) GetBalance(ctx context.Context, address libcommon.Address, b
 	if err != nil {
 		return nil, fmt.Errorf("read chain config: %v", err)
 	}
-	if chainConfig.IsOptimismPreBedrock(blockNum) {
+	relay, err := api.relayPreBedrockState(tx, chainConfig, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if relay {
 		if api.historicalRPCService == nil {
 			return nil, rpc.ErrNoHistoricalFallback
 		}
@@ -94,7 +98,11 @@ func (api *APIImpl) GetTransactionCount(ctx context.Context, address libcommon.A
 	if err != nil {
 		return nil, fmt.Errorf("read chain config: %v", err)
 	}
-	if chainConfig.IsOptimismPreBedrock(blockNum) {
+	relay, err := api.relayPreBedrockState(tx, chainConfig, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if relay {
 		if api.historicalRPCService == nil {
 			return nil, rpc.ErrNoHistoricalFallback
 		}
@@ -134,7 +142,11 @@ func (api *APIImpl) GetCode(ctx context.Context, address libcommon.Address, bloc
 	if err != nil {
 		return nil, fmt.Errorf("read chain config: %v", err)
 	}
-	if chainConfig.IsOptimismPreBedrock(blockNum) {
+	relay, err := api.relayPreBedrockState(tx, chainConfig, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if relay {
 		if api.historicalRPCService == nil {
 			return nil, rpc.ErrNoHistoricalFallback
 		}
@@ -180,7 +192,11 @@ func (api *APIImpl) GetStorageAt(ctx context.Context, address libcommon.Address,
 	if err != nil {
 		return hexutility.Encode(common.LeftPadBytes(empty, 32)), fmt.Errorf("read chain config: %v", err)
 	}
-	if chainConfig.IsOptimismPreBedrock(blockNum) {
+	relay, err := api.relayPreBedrockState(tx, chainConfig, blockNum)
+	if err != nil {
+		return hexutility.Encode(common.LeftPadBytes(empty, 32)), err
+	}
+	if relay {
 		if api.historicalRPCService == nil {
 			return hexutility.Encode(common.LeftPadBytes(empty, 32)), rpc.ErrNoHistoricalFallback
 		}
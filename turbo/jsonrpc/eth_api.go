@@ -14,6 +14,7 @@ import (
 	"github.com/erigontech/erigon-lib/log/v3"
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/holiman/uint256"
+	jsoniter "github.com/json-iterator/go"
 
 	"github.com/erigontech/erigon-lib/chain"
 	"github.com/erigontech/erigon-lib/common"
@@ -29,6 +30,7 @@ import (
 	"github.com/erigontech/erigon/common/math"
 	"github.com/erigontech/erigon/consensus"
 	"github.com/erigontech/erigon/consensus/misc"
+	"github.com/erigontech/erigon/core/legacy"
 	"github.com/erigontech/erigon/core/rawdb"
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/core/types/accounts"
@@ -60,6 +62,7 @@ type EthAPI interface {
 	// Receipt related (see ./eth_receipts.go)
 	GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error)
 	GetLogs(ctx context.Context, crit ethFilters.FilterCriteria) (types.Logs, error)
+	GetLogsStream(ctx context.Context, crit ethFilters.FilterCriteria, cursor *StreamCursor, stream *jsoniter.Stream) error
 	GetBlockReceipts(ctx context.Context, numberOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error)
 
 	// Uncle related (see ./eth_uncles.go)
@@ -374,6 +377,7 @@ type APIImpl struct {
 	AllowUnprotectedTxs         bool
 	MaxGetProofRewindBlockCount int
 	SubscribeLogsChannelSize    int
+	StreamLimits                StreamLimits // limits of eth_getLogsStream, set by the daemon
 	logger                      log.Logger
 }
 
@@ -400,6 +404,20 @@ func NewEthAPI(base *BaseAPI, db kv.RoDB, eth rpchelper.ApiBackend, txPool txpoo
 	}
 }
 
+// relayPreBedrockState reports whether the state of the block is only served by
+// the historical RPC: the pre-Bedrock state is served locally once it was
+// imported from the legacy node (see `erigon import-legacy`).
+func (api *APIImpl) relayPreBedrockState(tx kv.Tx, chainConfig *chain.Config, blockNum uint64) (bool, error) {
+	if !chainConfig.IsOptimismPreBedrock(blockNum) {
+		return false, nil
+	}
+	if api.historyV3(tx) {
+		return true, nil
+	}
+	imported, err := legacy.StateAvailable(tx, blockNum)
+	return !imported, err
+}
+
 func (api *APIImpl) relayToHistoricalBackend(ctx context.Context, result interface{}, method string, args ...interface{}) error {
 	return api.historicalRPCService.CallContext(ctx, result, method, args...)
 }
@@ -436,6 +454,8 @@ type RPCTransaction struct {
 	IsSystemTx *bool        `json:"isSystemTx,omitempty"`
 	// deposit-tx post-Canyon only
 	DepositReceiptVersion *hexutil.Uint64 `json:"depositReceiptVersion,omitempty"`
+	// legacy (pre-Bedrock) only
+	*legacy.RPCTxMeta
 }
 
 // NewRPCTransaction returns a transaction that will serialize to the RPC
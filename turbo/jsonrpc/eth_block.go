@@ -18,6 +18,7 @@ import (
 	"github.com/erigontech/erigon/cl/clparams"
 	"github.com/erigontech/erigon/common/math"
 	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/legacy"
 	"github.com/erigontech/erigon/core/rawdb"
 	"github.com/erigontech/erigon/core/state"
 	"github.com/erigontech/erigon/core/types"
@@ -247,6 +248,9 @@ func (api *APIImpl) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber
 	}
 
 	response, err := ethapi.RPCMarshalBlockEx(b, true, fullTx, borTx, borTxHash, additionalFields, receipts)
+	if err == nil && fullTx && chainConfig.IsOptimismPreBedrock(b.NumberU64()) {
+		err = setLegacyTxMeta(tx, b.NumberU64(), response)
+	}
 	if err == nil && number == rpc.PendingBlockNumber && chainConfig.Optimism == nil { // don't remove info if optimism
 		// Pending blocks need to nil out a few fields
 		for _, field := range []string{"hash", "nonce", "miner"} {
@@ -313,6 +317,9 @@ func (api *APIImpl) GetBlockByHash(ctx context.Context, numberOrHash rpc.BlockNu
 	}
 
 	response, err := ethapi.RPCMarshalBlockEx(block, true, fullTx, borTx, borTxHash, additionalFields, receipts)
+	if err == nil && fullTx && chainConfig.IsOptimismPreBedrock(number) {
+		err = setLegacyTxMeta(tx, number, response)
+	}
 
 	if chainConfig.Bor != nil {
 		borConfig := chainConfig.Bor.(*borcfg.BorConfig)
@@ -483,3 +490,24 @@ func (api *APIImpl) blockByNumber(ctx context.Context, number rpc.BlockNumber, t
 
 	return api.blockByRPCNumber(ctx, number, tx)
 }
+
+// setLegacyTxMeta adds the L1 metadata of the transactions of an imported
+// legacy block to its RPC representation.
+func setLegacyTxMeta(tx kv.Tx, blockNum uint64, response map[string]interface{}) error {
+	txs, _ := response["transactions"].([]interface{})
+	for i, t := range txs {
+		rpcTx, ok := t.(*ethapi.RPCTransaction)
+		if !ok {
+			continue
+		}
+		meta, err := legacy.ReadTxMeta(tx, blockNum, i)
+		if err != nil {
+			return err
+		}
+		if meta == nil {
+			return nil
+		}
+		rpcTx.RPCTxMeta = meta.RPC()
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/txpool"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/turbo/rpchelper"
+)
+
+// BundleAPI submits atomic bundles of transactions to the block builder. A
+// bundle is included right after the deposits of a block of its target range,
+// with all its transactions, or not at all. Bundles are never gossiped.
+type BundleAPI interface {
+	SendBundle(ctx context.Context, args SendBundleArgs) (*SendBundleResult, error)
+	CancelBundle(ctx context.Context, args CancelBundleArgs) (bool, error)
+}
+
+// SendBundleArgs are the arguments of eth_sendBundle.
+type SendBundleArgs struct {
+	Txs               []hexutility.Bytes `json:"txs"`
+	BlockNumber       hexutil.Uint64     `json:"blockNumber"`
+	MaxBlockNumber    *hexutil.Uint64    `json:"maxBlockNumber,omitempty"`
+	MinTimestamp      *uint64            `json:"minTimestamp,omitempty"`
+	MaxTimestamp      *uint64            `json:"maxTimestamp,omitempty"`
+	RevertingTxHashes []libcommon.Hash   `json:"revertingTxHashes,omitempty"`
+	ReplacementUuid   string             `json:"replacementUuid,omitempty"`
+}
+
+type SendBundleResult struct {
+	BundleHash libcommon.Hash `json:"bundleHash"`
+}
+
+// CancelBundleArgs are the arguments of eth_cancelBundle.
+type CancelBundleArgs struct {
+	ReplacementUuid string `json:"replacementUuid"`
+}
+
+// BundleAPIImpl is implementation of the BundleAPI interface, on the bundle
+// pool of the embedded txpool. Without it, the bundles are forwarded to the
+// sequencer.
+type BundleAPIImpl struct {
+	*BaseAPI
+	db                  kv.RoDB
+	bundles             *txpool.BundlePool
+	allowUnprotectedTxs bool
+}
+
+// NewBundleAPI returns BundleAPIImpl instance
+func NewBundleAPI(base *BaseAPI, db kv.RoDB, bundles *txpool.BundlePool, allowUnprotectedTxs bool) *BundleAPIImpl {
+	return &BundleAPIImpl{
+		BaseAPI:             base,
+		db:                  db,
+		bundles:             bundles,
+		allowUnprotectedTxs: allowUnprotectedTxs,
+	}
+}
+
+var errBundlesNotAvailable = errors.New("bundles are not available: they require the embedded txpool and --txpool.bundleslots")
+
+// SendBundle implements eth_sendBundle. Submits a bundle for inclusion in the
+// blocks from blockNumber to maxBlockNumber (blockNumber only by default). It
+// replaces the previous bundle of the same replacementUuid.
+func (api *BundleAPIImpl) SendBundle(ctx context.Context, args SendBundleArgs) (*SendBundleResult, error) {
+	if api.bundles == nil {
+		if api.seqRPCService != nil {
+			var result SendBundleResult
+			if err := api.seqRPCService.CallContext(ctx, &result, "eth_sendBundle", args); err != nil {
+				return nil, err
+			}
+			return &result, nil
+		}
+		return nil, errBundlesNotAvailable
+	}
+	if len(args.Txs) == 0 {
+		return nil, txpool.ErrBundleEmpty
+	}
+	if args.BlockNumber == 0 {
+		return nil, errors.New("missing bundle block number")
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	cc, err := api.chainConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := rpchelper.GetLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &txpool.Bundle{
+		Txs:               make([][]byte, len(args.Txs)),
+		TxHashes:          make([]libcommon.Hash, len(args.Txs)),
+		MinBlock:          uint64(args.BlockNumber),
+		RevertingTxHashes: args.RevertingTxHashes,
+		ReplacementUuid:   args.ReplacementUuid,
+	}
+	if args.MaxBlockNumber != nil {
+		bundle.MaxBlock = uint64(*args.MaxBlockNumber)
+	}
+	if args.MinTimestamp != nil {
+		bundle.MinTimestamp = *args.MinTimestamp
+	}
+	if args.MaxTimestamp != nil {
+		bundle.MaxTimestamp = *args.MaxTimestamp
+	}
+	if max(bundle.MinBlock, bundle.MaxBlock) <= latest {
+		return nil, fmt.Errorf("%w: latest block %d", txpool.ErrBundleExpired, latest)
+	}
+
+	for i, encoded := range args.Txs {
+		txn, err := types.DecodeTransaction(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		switch txn.Type() {
+		case types.DepositTxType, types.BlobTxType:
+			return nil, fmt.Errorf("transaction %d: %w", i, types.ErrTxTypeNotSupported)
+		}
+		if !txn.Protected() && !api.allowUnprotectedTxs {
+			return nil, fmt.Errorf("transaction %d: only replay-protected (EIP-155) transactions allowed over RPC", i)
+		}
+		if txn.Protected() && cc.ChainID.Cmp(txn.GetChainID().ToBig()) != 0 {
+			return nil, fmt.Errorf("transaction %d: invalid chain id, expected: %d got: %d", i, cc.ChainID, txn.GetChainID())
+		}
+		bundle.Txs[i] = encoded
+		bundle.TxHashes[i] = txn.Hash()
+	}
+
+	hash, err := api.bundles.Add(bundle)
+	if err != nil {
+		return nil, err
+	}
+	return &SendBundleResult{BundleHash: hash}, nil
+}
+
+// CancelBundle implements eth_cancelBundle. Removes the bundle of the given
+// replacementUuid, and reports whether there was one.
+func (api *BundleAPIImpl) CancelBundle(ctx context.Context, args CancelBundleArgs) (bool, error) {
+	if api.bundles == nil {
+		if api.seqRPCService != nil {
+			var cancelled bool
+			if err := api.seqRPCService.CallContext(ctx, &cancelled, "eth_cancelBundle", args); err != nil {
+				return false, err
+			}
+			return cancelled, nil
+		}
+		return false, errBundlesNotAvailable
+	}
+	if args.ReplacementUuid == "" {
+		return false, errors.New("missing bundle replacement uuid")
+	}
+	return api.bundles.Cancel(args.ReplacementUuid), nil
+}
@@ -0,0 +1,57 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/txpool"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+func TestSendBundle(t *testing.T) {
+	m := mock.Mock(t)
+	txn, err := types.SignTx(types.NewTransaction(0, libcommon.Address{1}, uint256.NewInt(1), params.TxGas, uint256.NewInt(params.GWei), nil), *types.LatestSignerForChainID(m.ChainConfig.ChainID), m.Key)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, txn.MarshalBinary(&buf))
+	args := SendBundleArgs{Txs: []hexutility.Bytes{buf.Bytes()}, BlockNumber: 1, ReplacementUuid: "uuid"}
+
+	_, err = NewBundleAPI(newBaseApiForTest(m), m.DB, nil, false).SendBundle(context.Background(), args)
+	require.ErrorIs(t, err, errBundlesNotAvailable)
+
+	pool := txpool.NewBundlePool(1)
+	api := NewBundleAPI(newBaseApiForTest(m), m.DB, pool, false)
+	_, err = api.SendBundle(context.Background(), SendBundleArgs{Txs: args.Txs})
+	require.Error(t, err)
+	_, err = api.SendBundle(context.Background(), SendBundleArgs{Txs: []hexutility.Bytes{{0x7e}}, BlockNumber: 1})
+	require.Error(t, err)
+
+	result, err := api.SendBundle(context.Background(), args)
+	require.NoError(t, err)
+	require.Equal(t, txpool.BundleHash([]libcommon.Hash{txn.Hash()}), result.BundleHash)
+	bundles := pool.Bundles(1, 0)
+	require.Len(t, bundles, 1)
+	require.Equal(t, uint64(1), bundles[0].MaxBlock)
+
+	// replaced by uuid
+	maxBlock := hexutil.Uint64(3)
+	args.MaxBlockNumber = &maxBlock
+	_, err = api.SendBundle(context.Background(), args)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), pool.Bundles(1, 0)[0].MaxBlock)
+
+	cancelled, err := api.CancelBundle(context.Background(), CancelBundleArgs{ReplacementUuid: "uuid"})
+	require.NoError(t, err)
+	require.True(t, cancelled)
+	require.Zero(t, pool.Len())
+}
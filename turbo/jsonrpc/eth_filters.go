@@ -2,11 +2,15 @@ package jsonrpc
 
 import (
 	"context"
+	"math/big"
 	"strings"
 
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/log/v3"
 
 	"github.com/erigontech/erigon/common/debug"
+	"github.com/erigontech/erigon/core/rawdb"
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/eth/filters"
 	"github.com/erigontech/erigon/rpc"
@@ -42,10 +46,20 @@ func (api *APIImpl) NewBlockFilter(_ context.Context) (string, error) {
 }
 
 // NewFilter implements eth_newFilter. Creates an arbitrary filter object, based on filter options, to notify when the state changes (logs).
-func (api *APIImpl) NewFilter(_ context.Context, crit filters.FilterCriteria) (string, error) {
+// A filter up to the safe or finalized block delivers the logs of the blocks which become safe or finalized, instead
+// of the logs of the new blocks.
+func (api *APIImpl) NewFilter(ctx context.Context, crit filters.FilterCriteria) (string, error) {
 	if api.filters == nil {
 		return "", rpc.ErrNotificationsUnsupported
 	}
+	if crit.BlockHash == nil && crit.ToBlock != nil {
+		switch rpc.BlockNumber(crit.ToBlock.Int64()) {
+		case rpc.SafeBlockNumber:
+			return api.newForkchoiceFilter(ctx, crit, rawdb.ReadForkchoiceSafe, api.filters.SubscribeSafeHeads)
+		case rpc.FinalizedBlockNumber:
+			return api.newForkchoiceFilter(ctx, crit, rawdb.ReadForkchoiceFinalized, api.filters.SubscribeFinalizedHeads)
+		}
+	}
 	logs, id := api.filters.SubscribeLogs(256, crit)
 	go func() {
 		for lg := range logs {
@@ -55,6 +69,56 @@ func (api *APIImpl) NewFilter(_ context.Context, crit filters.FilterCriteria) (s
 	return "0x" + string(id), nil
 }
 
+// newForkchoiceFilter installs a logs filter which follows the safe or finalized block: each time it moves, the logs
+// of the blocks up to it are looked up and stored for the filter. Without fromBlock (or with a block tag), the filter
+// starts after the safe or finalized block as of its installation.
+func (api *APIImpl) newForkchoiceFilter(ctx context.Context, crit filters.FilterCriteria, readHead func(kv.Getter) common.Hash, subscribe func(int) (<-chan *types.Header, rpchelper.HeadsSubID)) (string, error) {
+	var next *uint64
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+		from := crit.FromBlock.Uint64()
+		next = &from
+	} else if err := api.db.View(ctx, func(tx kv.Tx) error {
+		if hash := readHead(tx); hash != (common.Hash{}) {
+			if number := rawdb.ReadHeaderNumber(tx, hash); number != nil {
+				from := *number + 1
+				next = &from
+			}
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	headers, id := subscribe(32)
+	logsId := rpchelper.LogsSubID(id)
+	go func() {
+		defer debug.LogPanic()
+		// the logs added after the filter was uninstalled
+		defer api.filters.ReadLogs(logsId)
+		for h := range headers {
+			number := h.Number.Uint64()
+			if next == nil {
+				next = &number
+			}
+			if number < *next {
+				continue
+			}
+			c := crit
+			c.FromBlock, c.ToBlock = new(big.Int).SetUint64(*next), new(big.Int).Set(h.Number)
+			logs, err := api.GetLogs(context.Background(), c)
+			if err != nil {
+				log.Warn("[rpc] could not get the logs of the filter", "id", id, "from", *next, "to", number, "err", err)
+				continue
+			}
+			for _, lg := range logs {
+				api.filters.AddLogs(logsId, lg)
+			}
+			*next = number + 1
+		}
+	}()
+	return "0x" + string(id), nil
+}
+
 // UninstallFilter new transaction filter
 func (api *APIImpl) UninstallFilter(_ context.Context, index string) (isDeleted bool, err error) {
 	if api.filters == nil {
@@ -71,6 +135,12 @@ func (api *APIImpl) UninstallFilter(_ context.Context, index string) (isDeleted
 	if ok := api.filters.UnsubscribeLogs(rpchelper.LogsSubID(cutIndex)); ok {
 		isDeleted = true
 	}
+	if ok := api.filters.UnsubscribeSafeHeads(rpchelper.HeadsSubID(cutIndex)); ok {
+		isDeleted = true
+	}
+	if ok := api.filters.UnsubscribeFinalizedHeads(rpchelper.HeadsSubID(cutIndex)); ok {
+		isDeleted = true
+	}
 	return
 }
 
@@ -161,6 +231,56 @@ func (api *APIImpl) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	return rpcSub, nil
 }
 
+// NewSafeHeads send a notification each time the safe block of the forkchoice changes.
+func (api *APIImpl) NewSafeHeads(ctx context.Context) (*rpc.Subscription, error) {
+	if api.filters == nil {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	return api.forkchoiceHeads(ctx, api.filters.SubscribeSafeHeads, api.filters.UnsubscribeSafeHeads)
+}
+
+// NewFinalizedHeads send a notification each time the finalized block of the forkchoice changes.
+func (api *APIImpl) NewFinalizedHeads(ctx context.Context) (*rpc.Subscription, error) {
+	if api.filters == nil {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	return api.forkchoiceHeads(ctx, api.filters.SubscribeFinalizedHeads, api.filters.UnsubscribeFinalizedHeads)
+}
+
+func (api *APIImpl) forkchoiceHeads(ctx context.Context, subscribe func(int) (<-chan *types.Header, rpchelper.HeadsSubID), unsubscribe func(rpchelper.HeadsSubID) bool) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer debug.LogPanic()
+		headers, id := subscribe(32)
+		defer unsubscribe(id)
+		for {
+			select {
+			case h, ok := <-headers:
+				if h != nil {
+					err := notifier.Notify(rpcSub.ID, h)
+					if err != nil {
+						log.Warn("[rpc] error while notifying subscription", "err", err)
+					}
+				}
+				if !ok {
+					log.Warn("[rpc] forkchoice heads channel was closed")
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewPendingTransactions send a notification each time when a transaction had added into mempool.
 func (api *APIImpl) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
 	if api.filters == nil {
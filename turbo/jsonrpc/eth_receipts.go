@@ -28,6 +28,7 @@ import (
 
 	"github.com/erigontech/erigon/consensus"
 	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/legacy"
 	"github.com/erigontech/erigon/core/rawdb"
 	"github.com/erigontech/erigon/core/state"
 	"github.com/erigontech/erigon/core/types"
@@ -53,6 +54,11 @@ func (api *BaseAPI) getReceipts(ctx context.Context, tx kv.Tx, block *types.Bloc
 	}
 
 	if receipts := rawdb.ReadReceipts(chainConfig, tx, block, senders); receipts != nil {
+		if chainConfig.IsOptimismPreBedrock(block.NumberU64()) {
+			if err := legacy.SetReceiptsL1Fee(tx, block.NumberU64(), receipts); err != nil {
+				return nil, err
+			}
+		}
 		api.receiptsCache.Add(block.Hash(), receipts)
 		return receipts, nil
 	}
@@ -97,7 +103,6 @@ func (api *BaseAPI) getReceipts(ctx context.Context, tx kv.Tx, block *types.Bloc
 
 // GetLogs implements eth_getLogs. Returns an array of logs matching a given filter object.
 func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (types.Logs, error) {
-	var begin, end uint64
 	logs := types.Logs{}
 
 	tx, beginErr := api.db.BeginRo(ctx)
@@ -106,13 +111,51 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (t
 	}
 	defer tx.Rollback()
 
+	begin, end, err := api.logsRange(ctx, tx, crit)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.historyV3(tx) {
+		return api.getLogsV3(ctx, tx.(kv.TemporalTx), begin, end, crit)
+	}
+	blockNumbers := bitmapdb.NewBitmap()
+	defer bitmapdb.ReturnToPool(blockNumbers)
+	if err := applyFilters(blockNumbers, tx, begin, end, crit); err != nil {
+		return logs, err
+	}
+	if blockNumbers.IsEmpty() {
+		return logs, nil
+	}
+	addrMap := make(map[common.Address]struct{}, len(crit.Addresses))
+	for _, v := range crit.Addresses {
+		addrMap[v] = struct{}{}
+	}
+	iter := blockNumbers.Iterator()
+	for iter.HasNext() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		blockLogs, err := api.blockLogs(ctx, tx, uint64(iter.Next()), addrMap, crit.Topics)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, blockLogs...)
+	}
+
+	return logs, nil
+}
+
+// logsRange returns the inclusive block range of the filter criteria.
+func (api *APIImpl) logsRange(ctx context.Context, tx kv.Tx, crit filters.FilterCriteria) (begin, end uint64, err error) {
 	if crit.BlockHash != nil {
 		block, err := api.blockByHashWithSenders(ctx, tx, *crit.BlockHash)
 		if err != nil {
-			return nil, err
+			return 0, 0, err
 		}
 		if block == nil {
-			return nil, fmt.Errorf("block not found: %x", *crit.BlockHash)
+			return 0, 0, fmt.Errorf("block not found: %x", *crit.BlockHash)
 		}
 
 		num := block.NumberU64()
@@ -122,7 +165,7 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (t
 		// Convert the RPC block numbers into internal representations
 		latest, _, _, err := rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(rpc.LatestExecutedBlockNumber), tx, nil)
 		if err != nil {
-			return nil, err
+			return 0, 0, err
 		}
 
 		begin = latest
@@ -134,7 +177,7 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (t
 				blockNum := rpc.BlockNumber(fromBlock)
 				begin, _, _, err = rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(blockNum), tx, api.filters)
 				if err != nil {
-					return nil, err
+					return 0, 0, err
 				}
 			}
 
@@ -148,113 +191,96 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (t
 				blockNum := rpc.BlockNumber(toBlock)
 				end, _, _, err = rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(blockNum), tx, api.filters)
 				if err != nil {
-					return nil, err
+					return 0, 0, err
+				}
+				// without fromBlock, the logs of the safe or finalized block as of this call
+				if crit.FromBlock == nil && (blockNum == rpc.SafeBlockNumber || blockNum == rpc.FinalizedBlockNumber) {
+					begin = end
 				}
 			}
 		}
 	}
 
 	if end < begin {
-		return nil, fmt.Errorf("end (%d) < begin (%d)", end, begin)
+		return 0, 0, fmt.Errorf("end (%d) < begin (%d)", end, begin)
 	}
 	if end > roaring.MaxUint32 {
 		latest, err := rpchelper.GetLatestBlockNumber(tx)
 		if err != nil {
-			return nil, err
+			return 0, 0, err
 		}
 		if begin > latest {
-			return nil, fmt.Errorf("begin (%d) > latest (%d)", begin, latest)
+			return 0, 0, fmt.Errorf("begin (%d) > latest (%d)", begin, latest)
 		}
 		end = latest
 	}
+	return begin, end, nil
+}
 
-	if api.historyV3(tx) {
-		return api.getLogsV3(ctx, tx.(kv.TemporalTx), begin, end, crit)
-	}
-	blockNumbers := bitmapdb.NewBitmap()
-	defer bitmapdb.ReturnToPool(blockNumbers)
-	if err := applyFilters(blockNumbers, tx, begin, end, crit); err != nil {
-		return logs, err
-	}
-	if blockNumbers.IsEmpty() {
-		return logs, nil
-	}
-	addrMap := make(map[common.Address]struct{}, len(crit.Addresses))
-	for _, v := range crit.Addresses {
-		addrMap[v] = struct{}{}
-	}
-	iter := blockNumbers.Iterator()
-	for iter.HasNext() {
-		if err := ctx.Err(); err != nil {
-			return nil, err
-		}
-
-		blockNumber := uint64(iter.Next())
-		var logIndex uint
-		var txIndex uint
-		var blockLogs []*types.Log
+// blockLogs returns the logs of the block matching the filter, from the receipts of kv.Log.
+func (api *APIImpl) blockLogs(ctx context.Context, tx kv.Tx, blockNumber uint64, addrMap map[common.Address]struct{}, topics [][]common.Hash) (types.Logs, error) {
+	var logIndex uint
+	var txIndex uint
+	var blockLogs []*types.Log
 
-		it, err := tx.Prefix(kv.Log, hexutility.EncodeTs(blockNumber))
+	it, err := tx.Prefix(kv.Log, hexutility.EncodeTs(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	for it.HasNext() {
+		k, v, err := it.Next()
 		if err != nil {
 			return nil, err
 		}
-		for it.HasNext() {
-			k, v, err := it.Next()
-			if err != nil {
-				return logs, err
-			}
 
-			var logs types.Logs
-			if err := cbor.Unmarshal(&logs, bytes.NewReader(v)); err != nil {
-				return logs, fmt.Errorf("receipt unmarshal failed:  %w", err)
-			}
-			for _, log := range logs {
-				log.Index = logIndex
-				logIndex++
-			}
-			filtered := logs.Filter(addrMap, crit.Topics, 0)
-			if len(filtered) == 0 {
-				continue
-			}
-			txIndex = uint(binary.BigEndian.Uint32(k[8:]))
-			for _, log := range filtered {
-				log.TxIndex = txIndex
-			}
-			blockLogs = append(blockLogs, filtered...)
+		var logs types.Logs
+		if err := cbor.Unmarshal(&logs, bytes.NewReader(v)); err != nil {
+			return nil, fmt.Errorf("receipt unmarshal failed:  %w", err)
 		}
-		if casted, ok := it.(kv.Closer); ok {
-			casted.Close()
+		for _, log := range logs {
+			log.Index = logIndex
+			logIndex++
 		}
-		if len(blockLogs) == 0 {
+		filtered := logs.Filter(addrMap, topics, 0)
+		if len(filtered) == 0 {
 			continue
 		}
-
-		blockHash, err := api._blockReader.CanonicalHash(ctx, tx, blockNumber)
-		if err != nil {
-			return nil, err
+		txIndex = uint(binary.BigEndian.Uint32(k[8:]))
+		for _, log := range filtered {
+			log.TxIndex = txIndex
 		}
+		blockLogs = append(blockLogs, filtered...)
+	}
+	if casted, ok := it.(kv.Closer); ok {
+		casted.Close()
+	}
+	if len(blockLogs) == 0 {
+		return nil, nil
+	}
 
-		body, err := api._blockReader.BodyWithTransactions(ctx, tx, blockHash, blockNumber)
-		if err != nil {
-			return nil, err
-		}
-		if body == nil {
-			return nil, fmt.Errorf("block not found %d", blockNumber)
-		}
-		for _, log := range blockLogs {
-			log.BlockNumber = blockNumber
-			log.BlockHash = blockHash
-			// bor transactions are at the end of the bodies transactions (added manually but not actually part of the block)
-			if log.TxIndex == uint(len(body.Transactions)) {
-				log.TxHash = bortypes.ComputeBorTxHash(blockNumber, blockHash)
-			} else {
-				log.TxHash = body.Transactions[log.TxIndex].Hash()
-			}
-		}
-		logs = append(logs, blockLogs...)
+	blockHash, err := api._blockReader.CanonicalHash(ctx, tx, blockNumber)
+	if err != nil {
+		return nil, err
 	}
 
-	return logs, nil
+	body, err := api._blockReader.BodyWithTransactions(ctx, tx, blockHash, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, fmt.Errorf("block not found %d", blockNumber)
+	}
+	for _, log := range blockLogs {
+		log.BlockNumber = blockNumber
+		log.BlockHash = blockHash
+		// bor transactions are at the end of the bodies transactions (added manually but not actually part of the block)
+		if log.TxIndex == uint(len(body.Transactions)) {
+			log.TxHash = bortypes.ComputeBorTxHash(blockNumber, blockHash)
+		} else {
+			log.TxHash = body.Transactions[log.TxIndex].Hash()
+		}
+	}
+	return blockLogs, nil
 }
 
 // The Topic list restricts matches to particular event topics. Each event has a list
@@ -386,7 +412,11 @@ func applyFiltersV3(tx kv.TemporalTx, begin, end uint64, crit filters.FilterCrit
 		return out, err
 	}
 	toTxNum++
+	return applyFiltersTxNumsV3(tx, fromTxNum, toTxNum, crit)
+}
 
+// applyFiltersTxNumsV3 - [fromTxNum, toTxNum)
+func applyFiltersTxNumsV3(tx kv.TemporalTx, fromTxNum, toTxNum uint64, crit filters.FilterCriteria) (out iter.U64, err error) {
 	topicsBitmap, err := getTopicsBitmapV3(tx, crit.Topics, fromTxNum, toTxNum)
 	if err != nil {
 		return out, err
@@ -641,6 +671,12 @@ func (api *APIImpl) GetTransactionReceipt(ctx context.Context, txnHash common.Ha
 	}
 
 	if !ok {
+		// not yet in a block, the sequencer may have preconfirmed it in a flashblock
+		if pending := api.filters.PendingFlashblocks(); pending != nil {
+			if txn, receipt, _, ok := pending.Transaction(txnHash); ok {
+				return ethutils.MarshalReceipt(receipt, txn, cc, pending.Header(), txnHash, true), nil
+			}
+		}
 		return nil, nil
 	}
 
@@ -794,9 +830,11 @@ func marshalReceipt(receipt *types.Receipt, txn types.Transaction, chainConfig *
 
 	if chainConfig.IsOptimism() {
 		if txn.Type() != types.DepositTxType {
-			fields["l1GasPrice"] = hexutil.Big(*receipt.L1GasPrice)
-			fields["l1GasUsed"] = hexutil.Big(*receipt.L1GasUsed)
-			fields["l1Fee"] = hexutil.Big(*receipt.L1Fee)
+			if receipt.L1Fee != nil { // absent on the legacy L1 to L2 messages
+				fields["l1GasPrice"] = (*hexutil.Big)(receipt.L1GasPrice)
+				fields["l1GasUsed"] = (*hexutil.Big)(receipt.L1GasUsed)
+				fields["l1Fee"] = hexutil.Big(*receipt.L1Fee)
+			}
 			if receipt.FeeScalar != nil { // removed in Ecotone
 				fields["l1FeeScalar"] = receipt.FeeScalar
 			}
@@ -0,0 +1,168 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/bitmapdb"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/filters"
+)
+
+// GetLogsStream implements eth_getLogsStream, the streaming variant of eth_getLogs. The logs are written to the
+// response as they are found, up to the limits of --rpc.stream.maxresults and --rpc.stream.maxblocks, and the
+// response ends with the cursor resuming the query, null once it is complete: {"logs":[...],"cursor":"0x..."}.
+func (api *APIImpl) GetLogsStream(ctx context.Context, crit filters.FilterCriteria, cursor *StreamCursor, stream *jsoniter.Stream) error {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	begin, end, err := api.logsRange(ctx, tx, crit)
+	if err != nil {
+		return err
+	}
+	from, to, err := api.StreamLimits.scanRange(begin, end, cursor)
+	if err != nil {
+		return err
+	}
+	addrMap := make(map[common.Address]struct{}, len(crit.Addresses))
+	for _, v := range crit.Addresses {
+		addrMap[v] = struct{}{}
+	}
+
+	results := newResultStream(stream, "logs", api.StreamLimits.MaxResults)
+	var next *StreamCursor
+	if api.historyV3(tx) {
+		next, err = api.streamLogsV3(ctx, tx.(kv.TemporalTx), from, to, crit, addrMap, cursor, results)
+	} else {
+		next, err = api.streamLogs(ctx, tx, from, to, crit, addrMap, cursor, results)
+	}
+	if err != nil {
+		return err
+	}
+	if next == nil && to < end {
+		next = &StreamCursor{BlockNum: to + 1}
+	}
+	return results.close(next)
+}
+
+// streamLogs writes the logs of [from, to] from the receipts of the blocks of the logs index, and returns the
+// cursor of the first transaction left out by the results limit.
+func (api *APIImpl) streamLogs(ctx context.Context, tx kv.Tx, from, to uint64, crit filters.FilterCriteria, addrMap map[common.Address]struct{}, cursor *StreamCursor, results *resultStream) (*StreamCursor, error) {
+	blockNumbers := bitmapdb.NewBitmap()
+	defer bitmapdb.ReturnToPool(blockNumbers)
+	if err := applyFilters(blockNumbers, tx, from, to, crit); err != nil {
+		return nil, err
+	}
+	it := blockNumbers.Iterator()
+	for it.HasNext() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		blockNum := uint64(it.Next())
+		logs, err := api.blockLogs(ctx, tx, blockNum, addrMap, crit.Topics)
+		if err != nil {
+			return nil, err
+		}
+		for i, l := range logs {
+			if cursor.skips(blockNum, int(l.TxIndex)) {
+				continue
+			}
+			if results.full() && (i == 0 || l.TxIndex != logs[i-1].TxIndex) {
+				return &StreamCursor{BlockNum: blockNum, TxIndex: uint32(l.TxIndex)}, nil
+			}
+			if err := results.write(l); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// streamLogsV3 writes the logs of [from, to], re-executing the transactions of the inverted indices as they are
+// iterated, and returns the cursor of the first transaction left out by the results limit.
+func (api *APIImpl) streamLogsV3(ctx context.Context, tx kv.TemporalTx, from, to uint64, crit filters.FilterCriteria, addrMap map[common.Address]struct{}, cursor *StreamCursor, results *resultStream) (*StreamCursor, error) {
+	var fromTxNum uint64
+	var err error
+	if from > 0 {
+		if fromTxNum, err = rawdbv3.TxNums.Min(tx, from); err != nil {
+			return nil, err
+		}
+	}
+	toTxNum, err := rawdbv3.TxNums.Max(tx, to)
+	if err != nil {
+		return nil, err
+	}
+	if cursor != nil {
+		// the transactions of the block follow its system transaction
+		fromTxNum = min(fromTxNum+1+uint64(cursor.TxIndex), toTxNum)
+	}
+	txNumbers, err := applyFiltersTxNumsV3(tx, fromTxNum, toTxNum+1, crit)
+	if err != nil {
+		return nil, err
+	}
+
+	chainConfig, err := api.chainConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	exec := txnExecutor(tx, chainConfig, api.engine(), api._blockReader, nil)
+
+	var blockHash common.Hash
+	var header *types.Header
+	it := MapTxNum2BlockNum(tx, txNumbers)
+	for it.HasNext() {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		txNum, blockNum, txIndex, isFinalTxn, blockNumChanged, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if blockNumChanged {
+			if header, err = api._blockReader.HeaderByNumber(ctx, tx, blockNum); err != nil {
+				return nil, err
+			}
+			if header == nil {
+				return nil, fmt.Errorf("header not found: %d", blockNum)
+			}
+			blockHash = header.Hash()
+			exec.changeBlock(header)
+		}
+		if isFinalTxn || txIndex < 0 {
+			continue
+		}
+		if results.full() {
+			return &StreamCursor{BlockNum: blockNum, TxIndex: uint32(txIndex)}, nil
+		}
+
+		txn, err := api._txnReader.TxnByIdxInBlock(ctx, tx, blockNum, txIndex)
+		if err != nil {
+			return nil, err
+		}
+		if txn == nil {
+			continue
+		}
+		rawLogs, _, err := exec.execTx(txNum, txIndex, txn)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range types.Logs(rawLogs).Filter(addrMap, crit.Topics, 0) {
+			l.BlockNumber = blockNum
+			l.BlockHash = blockHash
+			l.TxHash = txn.Hash()
+			if err := results.write(l); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
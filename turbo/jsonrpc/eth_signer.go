@@ -0,0 +1,280 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/accounts/signer"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/rpc"
+	ethapi2 "github.com/erigontech/erigon/turbo/adapter/ethapi"
+)
+
+// SignerAPI signs with the accounts of the node signer: a local keystore or an
+// external signer. It is only served on the authenticated endpoint, where it
+// replaces the deprecated eth_accounts, eth_sign, eth_signTransaction and
+// eth_sendTransaction of EthAPI.
+type SignerAPI interface {
+	Accounts(ctx context.Context) ([]libcommon.Address, error)
+	Sign(ctx context.Context, account libcommon.Address, data hexutility.Bytes) (hexutility.Bytes, error)
+	SignTypedData_v4(ctx context.Context, account libcommon.Address, data signer.TypedData) (hexutility.Bytes, error)
+	SignTransaction(ctx context.Context, args ethapi2.CallArgs) (*SignTransactionResult, error)
+	SendTransaction(ctx context.Context, args ethapi2.CallArgs) (libcommon.Hash, error)
+}
+
+// SignTransactionResult is the result of eth_signTransaction.
+type SignTransactionResult struct {
+	Raw hexutility.Bytes  `json:"raw"`
+	Tx  types.Transaction `json:"tx"`
+}
+
+// SignerAPIImpl is implementation of the SignerAPI interface, filling the
+// transactions with the nonce, gas and fees of the EthAPI.
+type SignerAPIImpl struct {
+	eth    *APIImpl
+	signer signer.Signer
+
+	// the nonces of an account are reserved under its lock, from filling its
+	// transactions until they are in the txpool
+	lock       sync.Mutex
+	nonceLocks map[libcommon.Address]*sync.Mutex
+}
+
+// NewSignerAPI returns SignerAPIImpl instance
+func NewSignerAPI(eth *APIImpl, signer signer.Signer) *SignerAPIImpl {
+	return &SignerAPIImpl{
+		eth:        eth,
+		signer:     signer,
+		nonceLocks: map[libcommon.Address]*sync.Mutex{},
+	}
+}
+
+// Accounts implements eth_accounts. Returns the accounts of the signer.
+func (api *SignerAPIImpl) Accounts(ctx context.Context) ([]libcommon.Address, error) {
+	return api.signer.Accounts(ctx)
+}
+
+// Sign implements eth_sign. Signs the EIP-191 hash of a personal message:
+// sign(keccak256("\x19Ethereum Signed Message:\n" + len(message) + message)).
+func (api *SignerAPIImpl) Sign(ctx context.Context, account libcommon.Address, data hexutility.Bytes) (hexutility.Bytes, error) {
+	return api.signer.SignText(ctx, account, data)
+}
+
+// SignTypedData_v4 implements eth_signTypedData_v4. Signs the EIP-712 hash of
+// typed structured data.
+func (api *SignerAPIImpl) SignTypedData_v4(ctx context.Context, account libcommon.Address, data signer.TypedData) (hexutility.Bytes, error) {
+	return api.signer.SignTypedData(ctx, account, &data)
+}
+
+// SignTransaction implements eth_signTransaction. Fills and signs a
+// transaction, without submitting it.
+func (api *SignerAPIImpl) SignTransaction(ctx context.Context, args ethapi2.CallArgs) (*SignTransactionResult, error) {
+	if args.Nonce == nil {
+		unlock, err := api.lockNonce(args.From)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	txn, err := api.signTransaction(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	var raw bytes.Buffer
+	if err := txn.MarshalBinary(&raw); err != nil {
+		return nil, err
+	}
+	return &SignTransactionResult{Raw: raw.Bytes(), Tx: txn}, nil
+}
+
+// SendTransaction implements eth_sendTransaction. Fills, signs and submits a
+// message call transaction, or a contract creation without a recipient.
+func (api *SignerAPIImpl) SendTransaction(ctx context.Context, args ethapi2.CallArgs) (libcommon.Hash, error) {
+	unlock, err := api.lockNonce(args.From)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	defer unlock()
+	txn, err := api.signTransaction(ctx, args)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	var raw bytes.Buffer
+	if err := txn.MarshalBinary(&raw); err != nil {
+		return libcommon.Hash{}, err
+	}
+	return api.eth.SendRawTransaction(ctx, raw.Bytes())
+}
+
+func (api *SignerAPIImpl) lockNonce(from *libcommon.Address) (func(), error) {
+	if from == nil {
+		return nil, errors.New("missing from")
+	}
+	api.lock.Lock()
+	nonceLock, ok := api.nonceLocks[*from]
+	if !ok {
+		nonceLock = &sync.Mutex{}
+		api.nonceLocks[*from] = nonceLock
+	}
+	api.lock.Unlock()
+	nonceLock.Lock()
+	return nonceLock.Unlock, nil
+}
+
+// signTransaction fills the missing nonce, gas and fees of the transaction,
+// as for the pending block, and signs it. With a gasPrice, the transaction is
+// a legacy one, or an access list one with an accessList. Otherwise it is a
+// dynamic fee one after London.
+func (api *SignerAPIImpl) signTransaction(ctx context.Context, args ethapi2.CallArgs) (types.Transaction, error) {
+	if args.From == nil {
+		return nil, errors.New("missing from")
+	}
+	from := *args.From
+	accounts, err := api.signer.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(accounts, from) {
+		return nil, fmt.Errorf("%w: %x", signer.ErrUnknownAccount, from)
+	}
+	if args.GasPrice != nil && (args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil) {
+		return nil, errors.New("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	}
+	if args.MaxFeePerBlobGas != nil {
+		return nil, fmt.Errorf("%w: blob transactions", types.ErrTxTypeNotSupported)
+	}
+	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
+		return nil, errors.New("both data and input specified, and not equal")
+	}
+
+	tx, err := api.eth.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	cc, err := api.eth.chainConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	chainID, overflow := uint256.FromBig(cc.ChainID)
+	if overflow {
+		return nil, fmt.Errorf("chain id %d overflows", cc.ChainID)
+	}
+	if args.ChainID != nil && args.ChainID.ToInt().Cmp(cc.ChainID) != 0 {
+		return nil, fmt.Errorf("invalid chain id, expected: %d got: %d", cc.ChainID, args.ChainID.ToInt())
+	}
+	head := rawdb.ReadCurrentHeader(tx)
+	if head == nil {
+		return nil, errors.New("no current header")
+	}
+	tx.Rollback()
+
+	var input []byte
+	if args.Input != nil {
+		input = *args.Input
+	} else if args.Data != nil {
+		input = *args.Data
+	}
+	value := new(uint256.Int)
+	if args.Value != nil {
+		if value, overflow = uint256.FromBig(args.Value.ToInt()); overflow {
+			return nil, errors.New("value overflows")
+		}
+	}
+	var nonce uint64
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	} else {
+		pending, err := api.eth.GetTransactionCount(ctx, from, rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber))
+		if err != nil {
+			return nil, err
+		}
+		nonce = uint64(*pending)
+	}
+
+	// the fees: a gas price, or a tip and a fee cap for a dynamic fee one
+	var tip, feeCap *uint256.Int
+	dynamic := args.GasPrice == nil && head.BaseFee != nil
+	if !dynamic {
+		if args.GasPrice != nil {
+			if feeCap, overflow = uint256.FromBig(args.GasPrice.ToInt()); overflow {
+				return nil, errors.New("gasPrice overflows")
+			}
+		} else {
+			suggested, err := api.eth.GasPrice(ctx)
+			if err != nil {
+				return nil, err
+			}
+			feeCap = uint256.MustFromBig(suggested.ToInt())
+		}
+		args.GasPrice = (*hexutil.Big)(feeCap.ToBig())
+	} else {
+		if args.MaxPriorityFeePerGas != nil {
+			if tip, overflow = uint256.FromBig(args.MaxPriorityFeePerGas.ToInt()); overflow {
+				return nil, errors.New("maxPriorityFeePerGas overflows")
+			}
+		} else {
+			suggested, err := api.eth.MaxPriorityFeePerGas(ctx)
+			if err != nil {
+				return nil, err
+			}
+			tip = uint256.MustFromBig(suggested.ToInt())
+		}
+		if args.MaxFeePerGas != nil {
+			if feeCap, overflow = uint256.FromBig(args.MaxFeePerGas.ToInt()); overflow {
+				return nil, errors.New("maxFeePerGas overflows")
+			}
+		} else {
+			// leave room for the base fee to double
+			feeCap = new(uint256.Int).Mul(uint256.MustFromBig(head.BaseFee), uint256.NewInt(2))
+			feeCap.Add(feeCap, tip)
+		}
+		if tip.Gt(feeCap) {
+			return nil, fmt.Errorf("maxPriorityFeePerGas (%v) higher than maxFeePerGas (%v)", tip, feeCap)
+		}
+		args.MaxFeePerGas, args.MaxPriorityFeePerGas = (*hexutil.Big)(feeCap.ToBig()), (*hexutil.Big)(tip.ToBig())
+	}
+
+	var gas uint64
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	} else {
+		// estimated with the filled nonce and fees
+		args.Nonce = (*hexutil.Uint64)(&nonce)
+		pending := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+		estimated, err := api.eth.EstimateGas(ctx, &args, &pending, nil)
+		if err != nil {
+			return nil, fmt.Errorf("estimate gas: %w", err)
+		}
+		gas = uint64(estimated)
+	}
+	if err := checkTxFee(feeCap.ToBig(), gas, api.eth.FeeCap); err != nil {
+		return nil, err
+	}
+
+	var txn types.Transaction
+	switch {
+	case dynamic:
+		dynamicTx := &types.DynamicFeeTransaction{CommonTx: types.CommonTx{Nonce: nonce, Gas: gas, To: args.To, Value: value, Data: input}, ChainID: chainID, Tip: tip, FeeCap: feeCap}
+		if args.AccessList != nil {
+			dynamicTx.AccessList = *args.AccessList
+		}
+		txn = dynamicTx
+	case args.AccessList != nil:
+		txn = &types.AccessListTx{LegacyTx: types.LegacyTx{CommonTx: types.CommonTx{Nonce: nonce, Gas: gas, To: args.To, Value: value, Data: input}, GasPrice: feeCap}, ChainID: chainID, AccessList: *args.AccessList}
+	default:
+		txn = &types.LegacyTx{CommonTx: types.CommonTx{Nonce: nonce, Gas: gas, To: args.To, Value: value, Data: input}, GasPrice: feeCap}
+	}
+	return api.signer.SignTx(ctx, from, txn, chainID)
+}
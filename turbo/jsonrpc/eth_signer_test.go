@@ -0,0 +1,99 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/gointerfaces/txpool"
+	"github.com/erigontech/erigon-lib/kv/kvcache"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/accounts/signer"
+	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/rpc/rpccfg"
+	"github.com/erigontech/erigon/turbo/adapter/ethapi"
+	"github.com/erigontech/erigon/turbo/jsonrpc"
+	"github.com/erigontech/erigon/turbo/rpchelper"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+// keySigner is a signer of a single key.
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s keySigner) Accounts(ctx context.Context) ([]common.Address, error) {
+	return []common.Address{crypto.PubkeyToAddress(s.key.PublicKey)}, nil
+}
+
+func (s keySigner) SignText(ctx context.Context, account common.Address, text []byte) ([]byte, error) {
+	return crypto.Sign(signer.TextHash(text), s.key)
+}
+
+func (s keySigner) SignTypedData(ctx context.Context, account common.Address, data *signer.TypedData) ([]byte, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash[:], s.key)
+}
+
+func (s keySigner) SignTx(ctx context.Context, account common.Address, txn types.Transaction, chainID *uint256.Int) (types.Transaction, error) {
+	return types.SignTx(txn, *types.LatestSignerForChainID(chainID.ToBig()), s.key)
+}
+
+func TestSignerSendTransaction(t *testing.T) {
+	mockSentry, require := mock.MockWithTxPool(t), require.New(t)
+	logger := log.New()
+
+	oneBlockStep(mockSentry, require, t)
+
+	ctx, conn := rpcdaemontest.CreateTestGrpcConn(t, mockSentry)
+	txPool := txpool.NewTxpoolClient(conn)
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, nil, txPool, txpool.NewMiningClient(conn), func() {}, mockSentry.Log)
+	base := jsonrpc.NewBaseApi(ff, kvcache.New(kvcache.DefaultCoherentConfig), mockSentry.BlockReader, mockSentry.HistoryV3Components(), false, rpccfg.DefaultEvmCallTimeout, mockSentry.Engine, mockSentry.Dirs, nil, nil)
+	eth := jsonrpc.NewEthAPI(base, mockSentry.DB, nil, txPool, nil, 5000000, 1e18, 100_000, false, 100_000, 128, logger)
+	api := jsonrpc.NewSignerAPI(eth, keySigner{key: mockSentry.Key})
+
+	accounts, err := api.Accounts(ctx)
+	require.NoError(err)
+	require.Equal([]common.Address{mockSentry.Address}, accounts)
+
+	to, value := common.Address{1}, (*hexutil.Big)(uint256.NewInt(1234).ToBig())
+	_, err = api.SendTransaction(ctx, ethapi.CallArgs{From: &to, To: &to, Value: value})
+	require.ErrorIs(err, signer.ErrUnknownAccount)
+
+	// the nonce, gas and fees are filled
+	signed, err := api.SignTransaction(ctx, ethapi.CallArgs{From: &mockSentry.Address, To: &to, Value: value})
+	require.NoError(err)
+	txn, err := types.DecodeTransaction(signed.Raw)
+	require.NoError(err)
+	require.Equal(signed.Tx.Hash(), txn.Hash())
+	require.Equal(uint64(0), txn.GetNonce())
+	require.Equal(uint64(21_000), txn.GetGas())
+	require.Equal(byte(types.LegacyTxType), txn.Type()) // before London
+	require.True(txn.Protected())
+
+	txsCh, id := ff.SubscribePendingTxs(2)
+	defer ff.UnsubscribePendingTxs(id)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		hash, err := api.SendTransaction(ctx, ethapi.CallArgs{From: &mockSentry.Address, To: &to, Value: value})
+		require.NoError(err)
+		select {
+		case got := <-txsCh:
+			require.Equal(hash, got[0].Hash())
+			require.Equal(nonce, got[0].GetNonce())
+		case <-time.After(20 * time.Second):
+			t.Fatal("timeout waiting for txn")
+		}
+	}
+}
@@ -3,22 +3,31 @@ package jsonrpc
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/direct"
 	"github.com/erigontech/erigon-lib/gointerfaces/sentry"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/kvcache"
 	"github.com/erigontech/erigon-lib/wrap"
 
 	"github.com/erigontech/erigon-lib/log/v3"
 
+	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcdaemontest"
 	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcservices"
 	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/filters"
 	"github.com/erigontech/erigon/eth/protocols/eth"
 	"github.com/erigontech/erigon/ethdb/privateapi"
 	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/rpc/rpccfg"
 	"github.com/erigontech/erigon/turbo/builder"
 	"github.com/erigontech/erigon/turbo/rpchelper"
 	"github.com/erigontech/erigon/turbo/stages"
@@ -68,3 +77,84 @@ func TestEthSubscribe(t *testing.T) {
 		require.Equal(i, header.Number.Uint64())
 	}
 }
+
+func TestEthSubscribeForkchoiceHeads(t *testing.T) {
+	m, require := mock.Mock(t), require.New(t)
+	ctx := context.Background()
+	logger := log.New()
+	backendServer := privateapi.NewEthBackendServer(ctx, nil, m.DB, m.Notifications.Events, m.BlockReader, logger, builder.NewLatestBlockBuiltStore())
+	backendClient := direct.NewEthBackendClientDirect(backendServer)
+	backend := rpcservices.NewRemoteBackend(backendClient, m.DB, m.BlockReader)
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, backend, nil, nil, func() {}, m.Log)
+
+	safeHeads, safeId := ff.SubscribeSafeHeads(16)
+	defer ff.UnsubscribeSafeHeads(safeId)
+	finalizedHeads, finalizedId := ff.SubscribeFinalizedHeads(16)
+	defer ff.UnsubscribeFinalizedHeads(finalizedId)
+
+	headerRlp, err := rlp.EncodeToBytes(m.Genesis.Header())
+	require.NoError(err)
+	// the events are dropped until the filters are subscribed to the backend
+	receive := func(notify func([]byte), heads <-chan *types.Header) *types.Header {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		timeout := time.After(10 * time.Second)
+		for {
+			notify(headerRlp)
+			select {
+			case header := <-heads:
+				return header
+			case <-ticker.C:
+			case <-timeout:
+				t.Fatal("timeout")
+			}
+		}
+	}
+	require.Equal(m.Genesis.Hash(), receive(m.Notifications.Events.OnNewSafeHeader, safeHeads).Hash())
+	require.Equal(m.Genesis.Hash(), receive(m.Notifications.Events.OnNewFinalizedHeader, finalizedHeads).Hash())
+}
+
+func TestNewFilterToSafeBlock(t *testing.T) {
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	require := require.New(t)
+	ctx := context.Background()
+	logger := log.New()
+	backendServer := privateapi.NewEthBackendServer(ctx, nil, m.DB, m.Notifications.Events, m.BlockReader, logger, builder.NewLatestBlockBuiltStore())
+	backendClient := direct.NewEthBackendClientDirect(backendServer)
+	backend := rpcservices.NewRemoteBackend(backendClient, m.DB, m.BlockReader)
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, backend, nil, nil, func() {}, m.Log)
+	stateCache := kvcache.New(kvcache.DefaultCoherentConfig)
+	api := NewEthAPI(NewBaseApi(ff, stateCache, m.BlockReader, m.HistoryV3Components(), false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil, nil), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, logger)
+
+	id, err := api.NewFilter(ctx, filters.FilterCriteria{ToBlock: big.NewInt(rpc.SafeBlockNumber.Int64())})
+	require.NoError(err)
+
+	var header *types.Header
+	require.NoError(m.DB.View(ctx, func(tx kv.Tx) error {
+		header, err = m.BlockReader.HeaderByNumber(ctx, tx, 10)
+		return err
+	}))
+	headerRlp, err := rlp.EncodeToBytes(header)
+	require.NoError(err)
+	// the logs of the new safe block are delivered, once the filters are subscribed to the backend
+	var changes []any
+	require.Eventually(func() bool {
+		m.Notifications.Events.OnNewSafeHeader(headerRlp)
+		changes, err = api.GetFilterChanges(ctx, id)
+		require.NoError(err)
+		return len(changes) > 0
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Len(changes, 1)
+	require.Equal(uint64(10), changes[0].(*types.Log).BlockNumber)
+
+	// and only once
+	m.Notifications.Events.OnNewSafeHeader(headerRlp)
+	time.Sleep(20 * time.Millisecond)
+	changes, err = api.GetFilterChanges(ctx, id)
+	require.NoError(err)
+	require.Empty(changes)
+
+	ok, err := api.UninstallFilter(ctx, id)
+	require.NoError(err)
+	require.True(ok)
+}
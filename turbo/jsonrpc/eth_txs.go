@@ -15,6 +15,7 @@ import (
 	"github.com/erigontech/erigon-lib/gointerfaces/types"
 	bortypes "github.com/erigontech/erigon/polygon/bor/types"
 
+	"github.com/erigontech/erigon/core/legacy"
 	"github.com/erigontech/erigon/core/rawdb"
 	types2 "github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/rpc"
@@ -84,7 +85,17 @@ func (api *APIImpl) GetTransactionByHash(ctx context.Context, txnHash common.Has
 			if len(receipts) <= int(txnIndex) {
 				return nil, fmt.Errorf("block has less receipts than expected: %d <= %d, block: %d", len(receipts), int(txnIndex), blockNum)
 			}
-			return NewRPCTransaction(txn, blockHash, blockNum, txnIndex, baseFee, receipts[txnIndex]), nil
+			rpcTx := NewRPCTransaction(txn, blockHash, blockNum, txnIndex, baseFee, receipts[txnIndex])
+			if chainConfig.IsOptimismPreBedrock(blockNum) {
+				meta, err := legacy.ReadTxMeta(tx, blockNum, int(txnIndex))
+				if err != nil {
+					return nil, err
+				}
+				if meta != nil {
+					rpcTx.RPCTxMeta = meta.RPC()
+				}
+			}
+			return rpcTx, nil
 		}
 
 		return NewRPCTransaction(txn, blockHash, blockNum, txnIndex, baseFee, nil), nil
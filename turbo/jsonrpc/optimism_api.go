@@ -0,0 +1,173 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/rpchelper"
+)
+
+// OptimismAPI provides the output roots of the L2 blocks and the proofs of
+// the withdrawals against them, as proposed and proven on L1.
+type OptimismAPI interface {
+	OutputAtBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*OutputResponse, error)
+	ProveWithdrawal(ctx context.Context, withdrawalHash libcommon.Hash, blockNrOrHash rpc.BlockNumberOrHash) (*WithdrawalProof, error)
+}
+
+// OptimismImpl is implementation of the OptimismAPI interface, built on the
+// proofs of eth_getProof.
+type OptimismImpl struct {
+	*BaseAPI
+	db  kv.RoDB
+	eth *APIImpl
+}
+
+// NewOptimismAPI returns OptimismImpl instance
+func NewOptimismAPI(base *BaseAPI, db kv.RoDB, eth *APIImpl) *OptimismImpl {
+	return &OptimismImpl{
+		BaseAPI: base,
+		db:      db,
+		eth:     eth,
+	}
+}
+
+// BlockID is the identifier of a block, as in the op-node API.
+type BlockID struct {
+	Hash   libcommon.Hash `json:"hash"`
+	Number uint64         `json:"number"`
+}
+
+// L2BlockRef is the reference of an L2 block to its L1 origin, as in the
+// op-node API.
+type L2BlockRef struct {
+	Hash           libcommon.Hash `json:"hash"`
+	Number         uint64         `json:"number"`
+	ParentHash     libcommon.Hash `json:"parentHash"`
+	Time           uint64         `json:"timestamp"`
+	L1Origin       BlockID        `json:"l1origin"`
+	SequenceNumber uint64         `json:"sequenceNumber"`
+}
+
+// OutputResponse is the result of optimism_outputAtBlock, the output root of
+// a block and its preimage.
+type OutputResponse struct {
+	Version               libcommon.Hash `json:"version"`
+	OutputRoot            libcommon.Hash `json:"outputRoot"`
+	BlockRef              L2BlockRef     `json:"blockRef"`
+	WithdrawalStorageRoot libcommon.Hash `json:"withdrawalStorageRoot"`
+	StateRoot             libcommon.Hash `json:"stateRoot"`
+}
+
+// WithdrawalProof is the result of optimism_proveWithdrawal: the proof of the
+// sentMessages slot of a withdrawal against the storage root of the output.
+type WithdrawalProof struct {
+	Output         *OutputResponse    `json:"output"`
+	WithdrawalHash libcommon.Hash     `json:"withdrawalHash"`
+	StorageSlot    libcommon.Hash     `json:"storageSlot"`
+	Included       bool               `json:"included"`
+	StorageProof   []hexutility.Bytes `json:"storageProof"`
+	AccountProof   []hexutility.Bytes `json:"accountProof"`
+}
+
+// OutputAtBlock implements optimism_outputAtBlock. Returns the version 0 output root of the block.
+func (api *OptimismImpl) OutputAtBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*OutputResponse, error) {
+	output, _, err := api.output(ctx, blockNrOrHash, nil)
+	return output, err
+}
+
+// ProveWithdrawal implements optimism_proveWithdrawal. Returns the proof of the inclusion of the
+// withdrawal in the L2ToL1MessagePasser at the block, for proveWithdrawalTransaction on L1.
+func (api *OptimismImpl) ProveWithdrawal(ctx context.Context, withdrawalHash libcommon.Hash, blockNrOrHash rpc.BlockNumberOrHash) (*WithdrawalProof, error) {
+	slot := opstack.WithdrawalStorageSlot(withdrawalHash)
+	output, proof, err := api.output(ctx, blockNrOrHash, []libcommon.Hash{slot})
+	if err != nil {
+		return nil, err
+	}
+	if len(proof.StorageProof) != 1 {
+		return nil, fmt.Errorf("expected 1 storage proof, got %d", len(proof.StorageProof))
+	}
+	storageProof := proof.StorageProof[0]
+	return &WithdrawalProof{
+		Output:         output,
+		WithdrawalHash: withdrawalHash,
+		StorageSlot:    slot,
+		Included:       storageProof.Value != nil && storageProof.Value.ToInt().Sign() != 0,
+		StorageProof:   storageProof.Proof,
+		AccountProof:   proof.AccountProof,
+	}, nil
+}
+
+// output returns the output of the block, and the proof of the given slots of
+// the L2ToL1MessagePasser.
+func (api *OptimismImpl) output(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, storageKeys []libcommon.Hash) (*OutputResponse, *accounts.AccProofResult, error) {
+	ref, stateRoot, err := api.blockRef(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the proof is of the block resolved above, even if the chain moves in between
+	proof, err := api.eth.GetProof(ctx, opstack.L2ToL1MessagePasserAddr, storageKeys, rpc.BlockNumberOrHashWithHash(ref.Hash, true))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &OutputResponse{
+		Version:               opstack.OutputVersionV0,
+		OutputRoot:            opstack.OutputRootV0(stateRoot, proof.StorageHash, ref.Hash),
+		BlockRef:              *ref,
+		WithdrawalStorageRoot: proof.StorageHash,
+		StateRoot:             stateRoot,
+	}, proof, nil
+}
+
+// blockRef returns the reference and the state root of the block.
+func (api *OptimismImpl) blockRef(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*L2BlockRef, libcommon.Hash, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, libcommon.Hash{}, err
+	}
+	defer tx.Rollback()
+
+	chainConfig, err := api.chainConfig(ctx, tx)
+	if err != nil {
+		return nil, libcommon.Hash{}, err
+	}
+	blockNum, hash, _, err := rpchelper.GetCanonicalBlockNumber(blockNrOrHash, tx, api.filters)
+	if err != nil {
+		return nil, libcommon.Hash{}, err
+	}
+	if chainConfig.IsOptimismPreBedrock(blockNum) {
+		return nil, libcommon.Hash{}, errors.New("no output root before Bedrock")
+	}
+	block, err := api.blockWithSenders(ctx, tx, hash, blockNum)
+	if err != nil {
+		return nil, libcommon.Hash{}, err
+	}
+	if block == nil {
+		return nil, libcommon.Hash{}, fmt.Errorf("block %d not found", blockNum)
+	}
+
+	ref := &L2BlockRef{
+		Hash:       block.Hash(),
+		Number:     block.NumberU64(),
+		ParentHash: block.ParentHash(),
+		Time:       block.Time(),
+	}
+	// the L1 origin is set by the L1 attributes deposit opening the block, except in the genesis block
+	if txs := block.Transactions(); len(txs) > 0 && txs[0].Type() == types.DepositTxType {
+		info, err := opstack.ParseL1BlockInfo(txs[0].GetData())
+		if err != nil {
+			return nil, libcommon.Hash{}, fmt.Errorf("block %d: %w", blockNum, err)
+		}
+		ref.L1Origin = BlockID{Hash: info.Hash, Number: info.Number}
+		ref.SequenceNumber = info.SequenceNumber
+	}
+	return ref, block.Root(), nil
+}
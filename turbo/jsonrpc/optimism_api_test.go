@@ -0,0 +1,82 @@
+package jsonrpc
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/opstack"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+	"github.com/erigontech/erigon/turbo/trie"
+)
+
+func TestOptimismOutputAtBlock(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	withdrawal := libcommon.HexToHash("0x01")
+	gspec := &types.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(params.Ether)},
+			opstack.L2ToL1MessagePasserAddr: {
+				Balance: new(big.Int),
+				Storage: map[libcommon.Hash]libcommon.Hash{opstack.WithdrawalStorageSlot(withdrawal): libcommon.HexToHash("0x01")},
+			},
+		},
+	}
+	m := mock.MockWithGenesis(t, gspec, key, false)
+	if m.HistoryV3 {
+		t.Skip("not supported by Erigon3")
+	}
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 2, func(i int, b *core.BlockGen) {})
+	require.NoError(t, err)
+	require.NoError(t, m.InsertChain(chain))
+
+	ethApi := NewEthAPI(newBaseApiForTest(m), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+	api := NewOptimismAPI(newBaseApiForTest(m), m.DB, ethApi)
+	header := chain.Headers[1]
+
+	output, err := api.OutputAtBlock(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	require.Equal(t, opstack.OutputVersionV0, output.Version)
+	require.Equal(t, header.Hash(), output.BlockRef.Hash)
+	require.Equal(t, uint64(2), output.BlockRef.Number)
+	require.Equal(t, header.Root, output.StateRoot)
+	// the storage trie of the L2ToL1MessagePasser, with the single leaf of the withdrawal
+	withdrawalStorageRoot := libcommon.HexToHash("0xcbbbf16ae54eaa34555ac83b610f28e615cc465d358c30aa210799f1f4ef4d4e")
+	require.Equal(t, withdrawalStorageRoot, output.WithdrawalStorageRoot)
+	require.Equal(t, crypto.Keccak256Hash(opstack.OutputVersionV0[:], header.Root[:], withdrawalStorageRoot[:], header.Hash().Bytes()), output.OutputRoot)
+
+	for _, tt := range []struct {
+		withdrawal libcommon.Hash
+		included   bool
+	}{
+		{withdrawal, true},
+		{libcommon.HexToHash("0x02"), false},
+	} {
+		proof, err := api.ProveWithdrawal(context.Background(), tt.withdrawal, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+		require.NoError(t, err)
+		require.Equal(t, output, proof.Output)
+		require.Equal(t, tt.included, proof.Included)
+		value := new(big.Int)
+		if tt.included {
+			value.SetUint64(1)
+		}
+		require.NoError(t, trie.VerifyStorageProof(output.WithdrawalStorageRoot, accounts.StorProofResult{
+			Key:   proof.StorageSlot,
+			Value: (*hexutil.Big)(value),
+			Proof: proof.StorageProof,
+		}))
+	}
+}
@@ -0,0 +1,395 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/stagedsync"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/ethdb/cbor"
+)
+
+// Otterscan2API serves the token transfers and holdings indices built by the OtsTokenIndex stage, enabled by
+// --ots.tokenindices. The transfer lists are paginated newest first, from page 0. The transfers are decoded from the
+// receipts: once these are pruned, their transfers are left out of the pages, which may hold fewer than pageSize, while
+// the counts still include them.
+type Otterscan2API interface {
+	GetERC20TransferList(ctx context.Context, addr common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error)
+	GetERC20TransferCount(ctx context.Context, addr common.Address) (uint64, error)
+	GetERC721TransferList(ctx context.Context, addr common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error)
+	GetERC721TransferCount(ctx context.Context, addr common.Address) (uint64, error)
+	GetERC1155TransferList(ctx context.Context, addr common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error)
+	GetERC1155TransferCount(ctx context.Context, addr common.Address) (uint64, error)
+	GetTokenTransferList(ctx context.Context, token common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error)
+	GetTokenTransferCount(ctx context.Context, token common.Address) (uint64, error)
+	GetAllHoldings(ctx context.Context, holder common.Address) ([]*TokenHolding, error)
+	GetAllERC20Holdings(ctx context.Context, holder common.Address) ([]*TokenHolding, error)
+	GetAllERC721Holdings(ctx context.Context, holder common.Address) ([]*TokenHolding, error)
+	GetAllERC1155Holdings(ctx context.Context, holder common.Address) ([]*TokenHolding, error)
+}
+
+// TokenTransfer is a transfer of ERC-20 tokens, of an ERC-721 token, or of ERC-1155 tokens, single or batched.
+type TokenTransfer struct {
+	BlockNumber      hexutil.Uint64  `json:"blockNumber"`
+	Timestamp        hexutil.Uint64  `json:"timestamp"`
+	TransactionHash  common.Hash     `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64  `json:"transactionIndex"`
+	LogIndex         hexutil.Uint64  `json:"logIndex"`
+	Token            common.Address  `json:"token"`
+	Standard         string          `json:"standard"`
+	Operator         *common.Address `json:"operator,omitempty"` // ERC-1155
+	From             common.Address  `json:"from"`
+	To               common.Address  `json:"to"`
+	Value            *hexutil.Big    `json:"value,omitempty"`   // ERC-20 and single ERC-1155
+	TokenID          *hexutil.Big    `json:"tokenId,omitempty"` // ERC-721 and single ERC-1155
+	TokenIDs         []*hexutil.Big  `json:"tokenIds,omitempty"`
+	Values           []*hexutil.Big  `json:"values,omitempty"`
+}
+
+// TokenTransferList is a page of transfers, newest first.
+type TokenTransferList struct {
+	Results  []*TokenTransfer `json:"results"`
+	LastPage bool             `json:"lastPage"`
+}
+
+// TokenHolding is a token a holder ever sent or received.
+type TokenHolding struct {
+	Token      common.Address `json:"token"`
+	Standard   string         `json:"standard"`
+	FirstBlock hexutil.Uint64 `json:"firstBlock"`
+}
+
+type Otterscan2APIImpl struct {
+	*BaseAPI
+	db          kv.RoDB
+	maxPageSize uint64
+}
+
+func NewOtterscan2API(base *BaseAPI, db kv.RoDB, maxPageSize uint64) *Otterscan2APIImpl {
+	return &Otterscan2APIImpl{
+		BaseAPI:     base,
+		db:          db,
+		maxPageSize: maxPageSize,
+	}
+}
+
+var (
+	errNoTokenIndices = errors.New("token indices are not built, see --ots.tokenindices")
+	errLogPruned      = errors.New("log not found, receipts pruned?")
+)
+
+func (api *Otterscan2APIImpl) GetERC20TransferList(ctx context.Context, addr common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error) {
+	return api.transferList(ctx, stagedsync.OtsTransfersTable(stagedsync.ERC20), addr, pageNumber, pageSize)
+}
+
+func (api *Otterscan2APIImpl) GetERC20TransferCount(ctx context.Context, addr common.Address) (uint64, error) {
+	return api.transferCount(ctx, stagedsync.OtsTransfersTable(stagedsync.ERC20), addr)
+}
+
+func (api *Otterscan2APIImpl) GetERC721TransferList(ctx context.Context, addr common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error) {
+	return api.transferList(ctx, stagedsync.OtsTransfersTable(stagedsync.ERC721), addr, pageNumber, pageSize)
+}
+
+func (api *Otterscan2APIImpl) GetERC721TransferCount(ctx context.Context, addr common.Address) (uint64, error) {
+	return api.transferCount(ctx, stagedsync.OtsTransfersTable(stagedsync.ERC721), addr)
+}
+
+func (api *Otterscan2APIImpl) GetERC1155TransferList(ctx context.Context, addr common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error) {
+	return api.transferList(ctx, stagedsync.OtsTransfersTable(stagedsync.ERC1155), addr, pageNumber, pageSize)
+}
+
+func (api *Otterscan2APIImpl) GetERC1155TransferCount(ctx context.Context, addr common.Address) (uint64, error) {
+	return api.transferCount(ctx, stagedsync.OtsTransfersTable(stagedsync.ERC1155), addr)
+}
+
+// GetTokenTransferList returns the transfers of a token, of any standard.
+func (api *Otterscan2APIImpl) GetTokenTransferList(ctx context.Context, token common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error) {
+	return api.transferList(ctx, kv.OtsTokenTransfers, token, pageNumber, pageSize)
+}
+
+func (api *Otterscan2APIImpl) GetTokenTransferCount(ctx context.Context, token common.Address) (uint64, error) {
+	return api.transferCount(ctx, kv.OtsTokenTransfers, token)
+}
+
+func (api *Otterscan2APIImpl) GetAllHoldings(ctx context.Context, holder common.Address) ([]*TokenHolding, error) {
+	return api.holdings(ctx, holder, 0)
+}
+
+func (api *Otterscan2APIImpl) GetAllERC20Holdings(ctx context.Context, holder common.Address) ([]*TokenHolding, error) {
+	return api.holdings(ctx, holder, stagedsync.ERC20)
+}
+
+func (api *Otterscan2APIImpl) GetAllERC721Holdings(ctx context.Context, holder common.Address) ([]*TokenHolding, error) {
+	return api.holdings(ctx, holder, stagedsync.ERC721)
+}
+
+func (api *Otterscan2APIImpl) GetAllERC1155Holdings(ctx context.Context, holder common.Address) ([]*TokenHolding, error) {
+	return api.holdings(ctx, holder, stagedsync.ERC1155)
+}
+
+func (api *Otterscan2APIImpl) beginIndexed(ctx context.Context) (kv.Tx, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := stages.GetStageProgress(tx, stages.OtsTokenIndex)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if progress == 0 {
+		tx.Rollback()
+		return nil, errNoTokenIndices
+	}
+	return tx, nil
+}
+
+func (api *Otterscan2APIImpl) transferCount(ctx context.Context, table string, addr common.Address) (uint64, error) {
+	tx, err := api.beginIndexed(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	c, err := tx.CursorDupSort(table)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	k, _, err := c.SeekExact(addr.Bytes())
+	if err != nil || k == nil {
+		return 0, err
+	}
+	return c.CountDuplicates()
+}
+
+// transferList returns the page of the transfers of the DupSort-ed table under addr, from the newest ones. The
+// values of the table start with the position of the transfers. The transfers whose logs were pruned are skipped.
+func (api *Otterscan2APIImpl) transferList(ctx context.Context, table string, addr common.Address, pageNumber, pageSize uint64) (*TokenTransferList, error) {
+	if pageSize > api.maxPageSize {
+		return nil, fmt.Errorf("max allowed page size: %v", api.maxPageSize)
+	}
+	tx, err := api.beginIndexed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	c, err := tx.CursorDupSort(table)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	result := &TokenTransferList{Results: make([]*TokenTransfer, 0, pageSize)}
+	k, _, err := c.SeekExact(addr.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		result.LastPage = true
+		return result, nil
+	}
+	v, err := c.LastDup()
+	if err != nil {
+		return nil, err
+	}
+	for skip := pageNumber * pageSize; skip > 0 && v != nil; skip-- {
+		if _, v, err = c.PrevDup(); err != nil {
+			return nil, err
+		}
+	}
+
+	logs := newBlockLogsReader(tx)
+	for ; v != nil && uint64(len(result.Results)) < pageSize; _, v, err = c.PrevDup() {
+		if err != nil {
+			return nil, err
+		}
+		transfer, err := api.tokenTransfer(ctx, tx, logs, v)
+		if errors.Is(err, errLogPruned) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Results = append(result.Results, transfer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.LastPage = v == nil
+	return result, nil
+}
+
+// tokenTransfer decodes the transfer log at pos, block_num_u64 + tx_index_u32 + log_index_u32.
+func (api *Otterscan2APIImpl) tokenTransfer(ctx context.Context, tx kv.Tx, logs *blockLogsReader, pos []byte) (*TokenTransfer, error) {
+	blockNum, txIndex, logIndex := binary.BigEndian.Uint64(pos), binary.BigEndian.Uint32(pos[8:]), binary.BigEndian.Uint32(pos[12:])
+	l, err := logs.log(blockNum, txIndex, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	standard, from, to, ok := stagedsync.TokenTransferOf(l)
+	if !ok {
+		return nil, fmt.Errorf("log %d of block %d is not a token transfer", logIndex, blockNum)
+	}
+	header, err := api._blockReader.HeaderByNumber(ctx, tx, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("header %d not found", blockNum)
+	}
+	txn, err := api._blockReader.TxnByIdxInBlock(ctx, tx, blockNum, int(txIndex))
+	if err != nil {
+		return nil, err
+	}
+	if txn == nil {
+		return nil, fmt.Errorf("transaction %d of block %d not found", txIndex, blockNum)
+	}
+
+	transfer := &TokenTransfer{
+		BlockNumber:      hexutil.Uint64(blockNum),
+		Timestamp:        hexutil.Uint64(header.Time),
+		TransactionHash:  txn.Hash(),
+		TransactionIndex: hexutil.Uint64(txIndex),
+		LogIndex:         hexutil.Uint64(logIndex),
+		Token:            l.Address,
+		Standard:         standard.String(),
+		From:             from,
+		To:               to,
+	}
+	switch {
+	case standard == stagedsync.ERC20:
+		transfer.Value = (*hexutil.Big)(new(big.Int).SetBytes(l.Data))
+	case standard == stagedsync.ERC721:
+		transfer.TokenID = (*hexutil.Big)(l.Topics[3].Big())
+	case l.Topics[0] == stagedsync.TransferSingleEventTopic:
+		if len(l.Data) != 64 {
+			return nil, fmt.Errorf("invalid TransferSingle data of log %d of block %d", logIndex, blockNum)
+		}
+		transfer.TokenID = (*hexutil.Big)(new(big.Int).SetBytes(l.Data[:32]))
+		transfer.Value = (*hexutil.Big)(new(big.Int).SetBytes(l.Data[32:]))
+	default:
+		if transfer.TokenIDs, err = abiUint256Array(l.Data, 0); err != nil {
+			return nil, fmt.Errorf("invalid TransferBatch ids of log %d of block %d: %w", logIndex, blockNum, err)
+		}
+		if transfer.Values, err = abiUint256Array(l.Data, 1); err != nil {
+			return nil, fmt.Errorf("invalid TransferBatch values of log %d of block %d: %w", logIndex, blockNum, err)
+		}
+	}
+	if standard == stagedsync.ERC1155 {
+		operator := common.BytesToAddress(l.Topics[1][12:])
+		transfer.Operator = &operator
+	}
+	return transfer, nil
+}
+
+// abiUint256Array decodes the uint256[] ABI encoded as the argument i of data.
+func abiUint256Array(data []byte, i int) ([]*hexutil.Big, error) {
+	head := 32 * i
+	if len(data) < head+32 {
+		return nil, errors.New("short data")
+	}
+	offset := new(big.Int).SetBytes(data[head : head+32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(data))-32 {
+		return nil, errors.New("offset out of data")
+	}
+	start := offset.Uint64()
+	length := new(big.Int).SetBytes(data[start : start+32])
+	if !length.IsUint64() || length.Uint64() > (uint64(len(data))-start-32)/32 {
+		return nil, errors.New("length out of data")
+	}
+	values := make([]*hexutil.Big, length.Uint64())
+	for j := range values {
+		from := start + 32 + 32*uint64(j)
+		values[j] = (*hexutil.Big)(new(big.Int).SetBytes(data[from : from+32]))
+	}
+	return values, nil
+}
+
+func (api *Otterscan2APIImpl) holdings(ctx context.Context, holder common.Address, standard stagedsync.TokenStandard) ([]*TokenHolding, error) {
+	tx, err := api.beginIndexed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	c, err := tx.CursorDupSort(kv.OtsHoldings)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	holdings := []*TokenHolding{}
+	k, v, err := c.SeekExact(holder.Bytes())
+	if err != nil || k == nil {
+		return holdings, err
+	}
+	for ; v != nil; _, v, err = c.NextDup() {
+		if err != nil {
+			return nil, err
+		}
+		// token + standard_u8 + first_block_num_u64
+		s := stagedsync.TokenStandard(v[length.Addr])
+		if standard != 0 && s != standard {
+			continue
+		}
+		holdings = append(holdings, &TokenHolding{
+			Token:      common.BytesToAddress(v[:length.Addr]),
+			Standard:   s.String(),
+			FirstBlock: hexutil.Uint64(binary.BigEndian.Uint64(v[length.Addr+1:])),
+		})
+	}
+	return holdings, err
+}
+
+// blockLogsReader reads the logs of the blocks by their index in block, caching the last block read.
+type blockLogsReader struct {
+	tx       kv.Tx
+	blockNum uint64
+	logs     []*types.Log
+	txIndex  []uint32 // of the logs
+}
+
+func newBlockLogsReader(tx kv.Tx) *blockLogsReader {
+	return &blockLogsReader{tx: tx}
+}
+
+func (r *blockLogsReader) log(blockNum uint64, txIndex, logIndex uint32) (*types.Log, error) {
+	if r.logs == nil || r.blockNum != blockNum {
+		r.blockNum, r.logs, r.txIndex = blockNum, []*types.Log{}, r.txIndex[:0]
+		it, err := r.tx.Prefix(kv.Log, hexutility.EncodeTs(blockNum))
+		if err != nil {
+			return nil, err
+		}
+		for it.HasNext() {
+			k, v, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			var logs types.Logs
+			if err := cbor.Unmarshal(&logs, bytes.NewReader(v)); err != nil {
+				return nil, fmt.Errorf("receipt unmarshal failed: %w", err)
+			}
+			for range logs {
+				r.txIndex = append(r.txIndex, binary.BigEndian.Uint32(k[8:]))
+			}
+			r.logs = append(r.logs, logs...)
+		}
+	}
+	if int(logIndex) >= len(r.logs) || r.txIndex[logIndex] != txIndex {
+		return nil, fmt.Errorf("log %d of transaction %d of block %d: %w", logIndex, txIndex, blockNum, errLogPruned)
+	}
+	return r.logs[logIndex], nil
+}
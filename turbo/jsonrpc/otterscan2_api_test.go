@@ -0,0 +1,71 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/ethdb/cbor"
+)
+
+func TestAbiUint256Array(t *testing.T) {
+	word := func(n int64) []byte { return common.BigToHash(big.NewInt(n)).Bytes() }
+	// TransferBatch data: ids [1, 2] and values [10, 20]
+	var data []byte
+	for _, n := range []int64{64, 160, 2, 1, 2, 2, 10, 20} {
+		data = append(data, word(n)...)
+	}
+
+	ids, err := abiUint256Array(data, 0)
+	require.NoError(t, err)
+	require.Equal(t, []*hexutil.Big{(*hexutil.Big)(big.NewInt(1)), (*hexutil.Big)(big.NewInt(2))}, ids)
+	values, err := abiUint256Array(data, 1)
+	require.NoError(t, err)
+	require.Equal(t, []*hexutil.Big{(*hexutil.Big)(big.NewInt(10)), (*hexutil.Big)(big.NewInt(20))}, values)
+
+	_, err = abiUint256Array(data, 8)
+	require.Error(t, err)
+	_, err = abiUint256Array(data[:len(data)-32], 1)
+	require.Error(t, err)
+	tooFar := append(word(1<<20), data[32:]...)
+	_, err = abiUint256Array(tooFar, 0)
+	require.Error(t, err)
+}
+
+func TestBlockLogsReader(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	putLogs := func(blockNum uint64, txIndex uint32, logs types.Logs) {
+		var buf bytes.Buffer
+		require.NoError(t, cbor.Marshal(&buf, logs))
+		key := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint64(nil, blockNum), txIndex)
+		require.NoError(t, tx.Put(kv.Log, key, buf.Bytes()))
+	}
+	a, b, c := common.HexToAddress("0xa"), common.HexToAddress("0xb"), common.HexToAddress("0xc")
+	putLogs(1, 0, types.Logs{{Address: a}})
+	putLogs(1, 2, types.Logs{{Address: b}, {Address: c}})
+
+	r := newBlockLogsReader(tx)
+	l, err := r.log(1, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, c, l.Address)
+	l, err = r.log(1, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, a, l.Address)
+
+	// the log index is in block, the transaction must match
+	_, err = r.log(1, 0, 1)
+	require.ErrorIs(t, err, errLogPruned)
+	_, err = r.log(1, 2, 3)
+	require.ErrorIs(t, err, errLogPruned)
+	// the receipts of block 2 were pruned
+	_, err = r.log(2, 0, 0)
+	require.ErrorIs(t, err, errLogPruned)
+}
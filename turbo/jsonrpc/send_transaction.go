@@ -88,6 +88,7 @@ func (api *APIImpl) SendRawTransaction(ctx context.Context, encodedTx hexutility
 }
 
 // SendTransaction implements eth_sendTransaction. Creates new message call transaction or a contract creation if the data field contains code.
+// It is only implemented by SignerAPI, with a signer on the authenticated endpoint.
 func (api *APIImpl) SendTransaction(_ context.Context, txObject interface{}) (common.Hash, error) {
 	return common.Hash{0}, fmt.Errorf(NotImplemented, "eth_sendTransaction")
 }
@@ -0,0 +1,128 @@
+package jsonrpc
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/cmd/rpcdaemon/cli/httpcfg"
+)
+
+// streamFlushSize is the amount of buffered output flushed to the connection: a slow reader blocks the flush,
+// which holds the iteration of the streaming method until it catches up.
+const streamFlushSize = 4096
+
+// StreamCursor resumes a streaming method (eth_getLogsStream, trace_filterStream) at the transaction TxIndex of
+// the block BlockNum, the first position not returned yet. The block rewards come after the last transaction, at
+// TxIndex the number of transactions of the block. It is encoded as an opaque hex token of 12 bytes.
+type StreamCursor struct {
+	BlockNum uint64
+	TxIndex  uint32
+}
+
+func (c StreamCursor) MarshalText() ([]byte, error) {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, c.BlockNum)
+	binary.BigEndian.PutUint32(b[8:], c.TxIndex)
+	return hexutility.Bytes(b).MarshalText()
+}
+
+func (c *StreamCursor) UnmarshalText(input []byte) error {
+	var b hexutility.Bytes
+	if err := b.UnmarshalText(input); err != nil {
+		return err
+	}
+	if len(b) != 12 {
+		return fmt.Errorf("invalid cursor: %d bytes, expected 12", len(b))
+	}
+	c.BlockNum, c.TxIndex = binary.BigEndian.Uint64(b), binary.BigEndian.Uint32(b[8:])
+	return nil
+}
+
+// skips reports whether the transaction txIndex of the block blockNum precedes the cursor.
+func (c *StreamCursor) skips(blockNum uint64, txIndex int) bool {
+	return c != nil && blockNum == c.BlockNum && txIndex < int(c.TxIndex)
+}
+
+// StreamLimits bound a response of the streaming methods, the client resumes the rest with its cursor. Zero is
+// no limit.
+type StreamLimits struct {
+	MaxResults uint64 // results per response, checked between transactions
+	MaxBlocks  uint64 // blocks scanned per response
+}
+
+func NewStreamLimits(cfg *httpcfg.HttpCfg) StreamLimits {
+	return StreamLimits{MaxResults: cfg.StreamMaxResults, MaxBlocks: cfg.StreamMaxBlocks}
+}
+
+// scanRange starts the scan of [begin, end] at the cursor, and returns the last block scanned by this response.
+func (l StreamLimits) scanRange(begin, end uint64, cursor *StreamCursor) (uint64, uint64, error) {
+	if cursor != nil {
+		if cursor.BlockNum < begin || cursor.BlockNum > end {
+			return 0, 0, fmt.Errorf("cursor block %d out of range [%d, %d]", cursor.BlockNum, begin, end)
+		}
+		begin = cursor.BlockNum
+	}
+	if l.MaxBlocks > 0 && end-begin >= l.MaxBlocks {
+		end = begin + l.MaxBlocks - 1
+	}
+	return begin, end, nil
+}
+
+// resultStream writes the response of a streaming method, {"<field>":[<results>...],"cursor":<cursor or null>},
+// flushing the results to the connection as they are produced.
+type resultStream struct {
+	stream *jsoniter.Stream
+	limit  uint64
+	n      uint64
+}
+
+func newResultStream(stream *jsoniter.Stream, field string, limit uint64) *resultStream {
+	stream.WriteObjectStart()
+	stream.WriteObjectField(field)
+	stream.WriteArrayStart()
+	return &resultStream{stream: stream, limit: limit}
+}
+
+func (s *resultStream) write(v interface{}) error {
+	b, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.n > 0 {
+		s.stream.WriteMore()
+	}
+	if _, err := s.stream.Write(b); err != nil {
+		return err
+	}
+	s.n++
+	if s.stream.Buffered() >= streamFlushSize {
+		return s.stream.Flush()
+	}
+	return nil
+}
+
+// full reports whether the response reached the results limit, the next transaction goes to the cursor.
+func (s *resultStream) full() bool {
+	return s.limit > 0 && s.n >= s.limit
+}
+
+func (s *resultStream) close(cursor *StreamCursor) error {
+	s.stream.WriteArrayEnd()
+	s.stream.WriteMore()
+	s.stream.WriteObjectField("cursor")
+	if cursor == nil {
+		s.stream.WriteNil()
+	} else {
+		b, err := cursor.MarshalText()
+		if err != nil {
+			return err
+		}
+		s.stream.WriteString(string(b))
+	}
+	s.stream.WriteObjectEnd()
+	return s.stream.Flush()
+}
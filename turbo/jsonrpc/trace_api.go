@@ -30,6 +30,7 @@ type TraceAPI interface {
 	Get(ctx context.Context, txHash libcommon.Hash, txIndicies []hexutil.Uint64, gasBailOut *bool, traceConfig *tracers.TraceConfig) (*ParityTrace, error)
 	Block(ctx context.Context, blockNr rpc.BlockNumber, gasBailOut *bool, traceConfig *tracers.TraceConfig) (ParityTraces, error)
 	Filter(ctx context.Context, req TraceFilterRequest, gasBailOut *bool, traceConfig *tracers.TraceConfig, stream *jsoniter.Stream) error
+	FilterStream(ctx context.Context, req TraceFilterRequest, cursor *StreamCursor, gasBailOut *bool, traceConfig *tracers.TraceConfig, stream *jsoniter.Stream) error
 }
 
 // TraceAPIImpl is implementation of the TraceAPI interface based on remote Db access
@@ -39,6 +40,7 @@ type TraceAPIImpl struct {
 	maxTraces     uint64
 	gasCap        uint64
 	compatibility bool // Bug for bug compatiblity with OpenEthereum
+	streamLimits  StreamLimits
 }
 
 // NewTraceAPI returns NewTraceAPI instance
@@ -49,5 +51,6 @@ func NewTraceAPI(base *BaseAPI, kv kv.RoDB, cfg *httpcfg.HttpCfg) *TraceAPIImpl
 		maxTraces:     cfg.MaxTraces,
 		gasCap:        cfg.Gascap,
 		compatibility: cfg.TraceCompatibility,
+		streamLimits:  NewStreamLimits(cfg),
 	}
 }
@@ -0,0 +1,216 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/bitmapdb"
+	"github.com/erigontech/erigon-lib/kv/iter"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/tracers"
+)
+
+// FilterStream implements trace_filterStream, the streaming variant of trace_filter. The traces are written to
+// the response as the blocks are traced, up to the limits of --rpc.stream.maxresults (or the count of the request,
+// if lower) and --rpc.stream.maxblocks, and the response ends with the cursor resuming the query, null once it is
+// complete: {"traces":[...],"cursor":"0x..."}. The cursor replaces the after offset of trace_filter.
+func (api *TraceAPIImpl) FilterStream(ctx context.Context, req TraceFilterRequest, cursor *StreamCursor, gasBailOut *bool, traceConfig *tracers.TraceConfig, stream *jsoniter.Stream) error {
+	if req.After != nil {
+		return errors.New("invalid parameters: after is not supported, resume with the cursor")
+	}
+	if gasBailOut == nil {
+		gasBailOut = new(bool) // false by default
+	}
+	dbtx, err := api.kv.BeginRo(ctx)
+	if err != nil {
+		return fmt.Errorf("traceFilterStream cannot open tx: %w", err)
+	}
+	defer dbtx.Rollback()
+
+	var fromBlock, toBlock uint64
+	if req.FromBlock != nil {
+		fromBlock = uint64(*req.FromBlock)
+	}
+	if req.ToBlock == nil {
+		headNumber := rawdb.ReadHeaderNumber(dbtx, rawdb.ReadHeadHeaderHash(dbtx))
+		toBlock = *headNumber
+	} else {
+		toBlock = uint64(*req.ToBlock)
+	}
+	if fromBlock > toBlock {
+		return fmt.Errorf("invalid parameters: fromBlock cannot be greater than toBlock")
+	}
+	from, to, err := api.streamLimits.scanRange(fromBlock, toBlock, cursor)
+	if err != nil {
+		return err
+	}
+	fromAddresses, toAddresses, blocks, err := api.traceFilterBlocks(dbtx, req, from, to)
+	if err != nil {
+		return err
+	}
+	chainConfig, err := api.chainConfig(ctx, dbtx)
+	if err != nil {
+		return err
+	}
+
+	limit := api.streamLimits.MaxResults
+	if req.Count != nil && *req.Count > 0 && (limit == 0 || *req.Count < limit) {
+		limit = *req.Count
+	}
+	results := newResultStream(stream, "traces", limit)
+	isIntersectionMode := req.Mode == TraceFilterModeIntersection
+	includeAll := len(fromAddresses) == 0 && len(toAddresses) == 0
+	var next *StreamCursor
+blocks:
+	for blocks.HasNext() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		blockNum, err := blocks.Next()
+		if err != nil {
+			return err
+		}
+		block, err := api.blockByNumberWithSenders(ctx, dbtx, blockNum)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			return fmt.Errorf("could not find block %d", blockNum)
+		}
+		blockHash := block.Hash()
+		signer := types.MakeSigner(chainConfig, blockNum, block.Time())
+		t, syscall, err := api.callManyTransactions(ctx, dbtx, block, []string{TraceTypeTrace}, -1 /* all tx indices */, *gasBailOut, signer, chainConfig, traceConfig)
+		if err != nil {
+			return err
+		}
+		for i, trace := range t {
+			if cursor.skips(blockNum, i) {
+				continue
+			}
+			if results.full() {
+				next = &StreamCursor{BlockNum: blockNum, TxIndex: uint32(i)}
+				break blocks
+			}
+			txPosition := uint64(i)
+			for _, pt := range trace.Trace {
+				if includeAll || filterTrace(pt, fromAddresses, toAddresses, isIntersectionMode) {
+					pt.BlockHash = &blockHash
+					pt.BlockNumber = &blockNum
+					pt.TransactionHash = trace.TransactionHash
+					pt.TransactionPosition = &txPosition
+					if err := results.write(pt); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		// the rewards come after the last transaction
+		if cursor.skips(blockNum, len(t)) {
+			continue
+		}
+		if results.full() {
+			next = &StreamCursor{BlockNum: blockNum, TxIndex: uint32(len(t))}
+			break
+		}
+		rewards, err := api.engine().CalculateRewards(chainConfig, block.Header(), block.Uncles(), syscall)
+		if err != nil {
+			return err
+		}
+		for _, r := range rewards {
+			if _, ok := toAddresses[r.Beneficiary]; ok || includeAll {
+				var tr ParityTrace
+				rewardAction := &RewardTraceAction{}
+				rewardAction.Author = r.Beneficiary
+				rewardAction.RewardType = rewardKindToString(r.Kind)
+				rewardAction.Value.ToInt().Set(r.Amount.ToBig())
+				tr.Action = rewardAction
+				tr.BlockHash = &blockHash
+				tr.BlockNumber = &blockNum
+				tr.Type = "reward" // nolint: goconst
+				tr.TraceAddress = []int{}
+				if err := results.write(tr); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if next == nil && to < toBlock {
+		next = &StreamCursor{BlockNum: to + 1}
+	}
+	return results.close(next)
+}
+
+// traceFilterBlocks returns the blocks of [from, to] with traces of the filter addresses, iterating the temporal
+// inverted indices lazily with history v3.
+func (api *TraceAPIImpl) traceFilterBlocks(dbtx kv.Tx, req TraceFilterRequest, from, to uint64) (fromAddresses, toAddresses map[common.Address]struct{}, blocks iter.U64, err error) {
+	if !api.historyV3(dbtx) {
+		fromAddresses, toAddresses, allBlocks, err := traceFilterBitmaps(dbtx, req, from, to+1)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return fromAddresses, toAddresses, bitmapdb.ToIter(allBlocks.Iterator()), nil
+	}
+
+	var fromTxNum uint64
+	if from > 0 {
+		if fromTxNum, err = rawdbv3.TxNums.Min(dbtx, from); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	toTxNum, err := rawdbv3.TxNums.Max(dbtx, to)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fromAddresses, toAddresses, allTxs, err := traceFilterBitmapsV3(dbtx.(kv.TemporalTx), req, fromTxNum, toTxNum+1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return fromAddresses, toAddresses, &txNumBlocksIter{it: MapTxNum2BlockNum(dbtx, allTxs)}, nil
+}
+
+// txNumBlocksIter iterates the distinct blocks of the ordered txNums.
+type txNumBlocksIter struct {
+	it       *MapTxNum2BlockNumIter
+	blockNum uint64
+	hasNext  bool
+	err      error
+	advanced bool
+}
+
+func (i *txNumBlocksIter) advance() {
+	if i.advanced {
+		return
+	}
+	i.advanced, i.hasNext = true, false
+	for i.it.HasNext() {
+		_, blockNum, _, _, blockNumChanged, err := i.it.Next()
+		if err != nil {
+			i.err, i.hasNext = err, true
+			return
+		}
+		if blockNumChanged {
+			i.blockNum, i.hasNext = blockNum, true
+			return
+		}
+	}
+}
+
+func (i *txNumBlocksIter) HasNext() bool {
+	i.advance()
+	return i.hasNext
+}
+
+func (i *txNumBlocksIter) Next() (uint64, error) {
+	i.advance()
+	i.advanced = false
+	return i.blockNum, i.err
+}
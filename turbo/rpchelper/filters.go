@@ -27,6 +27,7 @@ import (
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/eth/filters"
 	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/flashblocks"
 )
 
 // Filters holds the state for managing subscriptions to various Ethereum events.
@@ -35,15 +36,18 @@ import (
 type Filters struct {
 	mu sync.RWMutex
 
-	pendingBlock *types.Block
+	pendingBlock       *types.Block
+	pendingFlashblocks *flashblocks.Pending
 
-	headsSubs        *concurrent.SyncMap[HeadsSubID, Sub[*types.Header]]
-	pendingLogsSubs  *concurrent.SyncMap[PendingLogsSubID, Sub[types.Logs]]
-	pendingBlockSubs *concurrent.SyncMap[PendingBlockSubID, Sub[*types.Block]]
-	pendingTxsSubs   *concurrent.SyncMap[PendingTxsSubID, Sub[[]types.Transaction]]
-	logsSubs         *LogsFilterAggregator
-	logsRequestor    atomic.Value
-	onNewSnapshot    func()
+	headsSubs          *concurrent.SyncMap[HeadsSubID, Sub[*types.Header]]
+	safeHeadsSubs      *concurrent.SyncMap[HeadsSubID, Sub[*types.Header]]
+	finalizedHeadsSubs *concurrent.SyncMap[HeadsSubID, Sub[*types.Header]]
+	pendingLogsSubs    *concurrent.SyncMap[PendingLogsSubID, Sub[types.Logs]]
+	pendingBlockSubs   *concurrent.SyncMap[PendingBlockSubID, Sub[*types.Block]]
+	pendingTxsSubs     *concurrent.SyncMap[PendingTxsSubID, Sub[[]types.Transaction]]
+	logsSubs           *LogsFilterAggregator
+	logsRequestor      atomic.Value
+	onNewSnapshot      func()
 
 	logsStores         *concurrent.SyncMap[LogsSubID, []*types.Log]
 	pendingHeadsStores *concurrent.SyncMap[HeadsSubID, []*types.Header]
@@ -61,6 +65,8 @@ func New(ctx context.Context, config FiltersConfig, ethBackend ApiBackend, txPoo
 
 	ff := &Filters{
 		headsSubs:          concurrent.NewSyncMap[HeadsSubID, Sub[*types.Header]](),
+		safeHeadsSubs:      concurrent.NewSyncMap[HeadsSubID, Sub[*types.Header]](),
+		finalizedHeadsSubs: concurrent.NewSyncMap[HeadsSubID, Sub[*types.Header]](),
 		pendingTxsSubs:     concurrent.NewSyncMap[PendingTxsSubID, Sub[[]types.Transaction]](),
 		pendingLogsSubs:    concurrent.NewSyncMap[PendingLogsSubID, Sub[types.Logs]](),
 		pendingBlockSubs:   concurrent.NewSyncMap[PendingBlockSubID, Sub[*types.Block]](),
@@ -208,6 +214,14 @@ func New(ctx context.Context, config FiltersConfig, ethBackend ApiBackend, txPoo
 		}
 	}
 
+	if config.FlashblocksURL != "" {
+		if jwtSecret, err := flashblocks.ReadJWTSecret(config.FlashblocksJWTSecret); err != nil {
+			logger.Error("rpc filters: flashblocks stream disabled", "err", err)
+		} else {
+			go flashblocks.Subscribe(ctx, config.FlashblocksURL, jwtSecret, ff.HandleFlashblock, logger)
+		}
+	}
+
 	return ff
 }
 
@@ -218,6 +232,59 @@ func (ff *Filters) LastPendingBlock() *types.Block {
 	return ff.pendingBlock
 }
 
+// PendingFlashblocks returns the pending block accumulated from the sequencer
+// flashblocks, or nil if there is none.
+func (ff *Filters) PendingFlashblocks() *flashblocks.Pending {
+	if ff == nil {
+		return nil
+	}
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+	return ff.pendingFlashblocks
+}
+
+// HandleFlashblock handles a flashblock received from the sequencer stream.
+// It updates the pending block and notifies subscribers about it and its new logs.
+func (ff *Filters) HandleFlashblock(fb *flashblocks.Flashblock) {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	pending, logsFrom := ff.pendingFlashblocks, 0
+	if fb.Index == 0 {
+		p, err := flashblocks.NewPending(fb)
+		if err != nil {
+			ff.logger.Warn("rpc filters: unprocessable flashblock", "err", err)
+			return
+		}
+		pending = p
+	} else {
+		if pending == nil || pending.PayloadID() != uint64(fb.PayloadID) {
+			// joined the stream in the middle of a payload, wait for the next one
+			return
+		}
+		logsFrom = len(pending.Logs())
+		if err := pending.Apply(fb); err != nil {
+			ff.logger.Warn("rpc filters: unprocessable flashblock", "err", err)
+			ff.pendingFlashblocks = nil
+			return
+		}
+	}
+	ff.pendingFlashblocks = pending
+	b := pending.Block()
+	ff.pendingBlock = b
+
+	ff.pendingBlockSubs.Range(func(k PendingBlockSubID, v Sub[*types.Block]) error {
+		v.Send(b)
+		return nil
+	})
+	if logs := pending.Logs()[logsFrom:]; len(logs) > 0 {
+		ff.pendingLogsSubs.Range(func(k PendingLogsSubID, v Sub[types.Logs]) error {
+			v.Send(logs)
+			return nil
+		})
+	}
+}
+
 // subscribeToPendingTransactions subscribes to pending transactions using the given transaction pool client.
 // It listens for new transactions and processes them as they arrive.
 func (ff *Filters) subscribeToPendingTransactions(ctx context.Context, txPool txpool.TxpoolClient) error {
@@ -356,6 +423,44 @@ func (ff *Filters) UnsubscribeHeads(id HeadsSubID) bool {
 	return true
 }
 
+// SubscribeSafeHeads subscribes to the headers of the new safe blocks of the forkchoice
+// and returns a channel to receive the headers and a subscription ID to manage the subscription.
+func (ff *Filters) SubscribeSafeHeads(size int) (<-chan *types.Header, HeadsSubID) {
+	id := HeadsSubID(generateSubscriptionID())
+	sub := newChanSub[*types.Header](size)
+	ff.safeHeadsSubs.Put(id, sub)
+	return sub.ch, id
+}
+
+// UnsubscribeSafeHeads unsubscribes from the safe block headers using the given subscription ID.
+// It returns true if the unsubscription was successful, otherwise false.
+func (ff *Filters) UnsubscribeSafeHeads(id HeadsSubID) bool {
+	sub, ok := ff.safeHeadsSubs.Delete(id)
+	if ok {
+		sub.Close()
+	}
+	return ok
+}
+
+// SubscribeFinalizedHeads subscribes to the headers of the new finalized blocks of the forkchoice
+// and returns a channel to receive the headers and a subscription ID to manage the subscription.
+func (ff *Filters) SubscribeFinalizedHeads(size int) (<-chan *types.Header, HeadsSubID) {
+	id := HeadsSubID(generateSubscriptionID())
+	sub := newChanSub[*types.Header](size)
+	ff.finalizedHeadsSubs.Put(id, sub)
+	return sub.ch, id
+}
+
+// UnsubscribeFinalizedHeads unsubscribes from the finalized block headers using the given subscription ID.
+// It returns true if the unsubscription was successful, otherwise false.
+func (ff *Filters) UnsubscribeFinalizedHeads(id HeadsSubID) bool {
+	sub, ok := ff.finalizedHeadsSubs.Delete(id)
+	if ok {
+		sub.Close()
+	}
+	return ok
+}
+
 // SubscribePendingLogs subscribes to pending logs and returns a channel to receive the logs
 // and a subscription ID to manage the subscription. It uses the specified filter criteria.
 func (ff *Filters) SubscribePendingLogs(size int) (<-chan types.Logs, PendingLogsSubID) {
@@ -549,6 +654,10 @@ func (ff *Filters) onNewEvent(event *remote.SubscribeReply) error {
 	switch event.Type {
 	case remote.Event_HEADER:
 		return ff.onNewHeader(event)
+	case remote.Event_SAFE_HEADER:
+		return sendHeader(ff.safeHeadsSubs, event)
+	case remote.Event_FINALIZED_HEADER:
+		return sendHeader(ff.finalizedHeadsSubs, event)
 	case remote.Event_NEW_SNAPSHOT:
 		ff.onNewSnapshot()
 		return nil
@@ -597,6 +706,11 @@ func (ff *Filters) onPendingBlock(event *remote.SubscribeReply) error {
 
 // onNewHeader handles a new block header event from the remote and updates the internal state.
 func (ff *Filters) onNewHeader(event *remote.SubscribeReply) error {
+	return sendHeader(ff.headsSubs, event)
+}
+
+// sendHeader sends the header of a header event from the remote to the given subscriptions.
+func sendHeader(subs *concurrent.SyncMap[HeadsSubID, Sub[*types.Header]], event *remote.SubscribeReply) error {
 	payload := event.Data
 	var header types.Header
 	if len(payload) == 0 {
@@ -606,7 +720,7 @@ func (ff *Filters) onNewHeader(event *remote.SubscribeReply) error {
 	if err != nil {
 		return fmt.Errorf("unprocessable payload: %w", err)
 	}
-	return ff.headsSubs.Range(func(k HeadsSubID, v Sub[*types.Header]) error {
+	return subs.Range(func(k HeadsSubID, v Sub[*types.Header]) error {
 		v.Send(&header)
 		return nil
 	})
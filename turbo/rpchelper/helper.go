@@ -17,6 +17,7 @@ import (
 	borfinality "github.com/erigontech/erigon/polygon/bor/finality"
 	"github.com/erigontech/erigon/polygon/bor/finality/whitelist"
 	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/flashblocks"
 )
 
 // unable to decode supplied params, or an invalid number of parameters
@@ -110,6 +111,14 @@ func _GetBlockNumber(requireCanonical bool, blockNrOrHash rpc.BlockNumberOrHash,
 }
 
 func CreateStateReader(ctx context.Context, tx kv.Tx, blockNrOrHash rpc.BlockNumberOrHash, txnIndex int, filters *Filters, stateCache kvcache.Cache, historyV3 bool, chainName string) (state.StateReader, error) {
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		if pending := filters.PendingFlashblocks(); pending != nil {
+			reader, err := createFlashblocksStateReader(ctx, tx, pending, stateCache)
+			if reader != nil || err != nil {
+				return reader, err
+			}
+		}
+	}
 	blockNumber, _, latest, err := _GetBlockNumber(true, blockNrOrHash, tx, filters)
 	if err != nil {
 		return nil, err
@@ -128,6 +137,30 @@ func CreateStateReaderFromBlockNumber(ctx context.Context, tx kv.Tx, blockNumber
 	return CreateHistoryStateReader(tx, blockNumber+1, txnIndex, historyV3, chainName)
 }
 
+// createFlashblocksStateReader returns a reader of the state of the pending
+// flashblocks, or nil if they are not built on top of the executed state.
+func createFlashblocksStateReader(ctx context.Context, tx kv.Tx, pending *flashblocks.Pending, stateCache kvcache.Cache) (state.StateReader, error) {
+	plainStateBlockNumber, err := stages.GetStageProgress(tx, stages.Execution)
+	if err != nil {
+		return nil, fmt.Errorf("getting plain state block number: %w", err)
+	}
+	if pending.NumberU64() != plainStateBlockNumber+1 {
+		return nil, nil
+	}
+	hash, err := rawdb.ReadCanonicalHash(tx, plainStateBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if hash != pending.ParentHash() {
+		return nil, nil
+	}
+	cacheView, err := stateCache.View(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	return pending.StateReader(state.NewCachedReader2(cacheView, tx)), nil
+}
+
 func CreateHistoryStateReader(tx kv.Tx, blockNumber uint64, txnIndex int, historyV3 bool, chainName string) (state.StateReader, error) {
 	if !historyV3 {
 		r := state.NewPlainState(tx, blockNumber, systemcontracts.SystemContractCodeLookup[chainName])
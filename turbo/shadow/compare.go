@@ -0,0 +1,128 @@
+package shadow
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/turbo/forensics"
+)
+
+// refReceipt holds the compared fields of a receipt of the reference.
+type refReceipt struct {
+	Type              hexutil.Uint64     `json:"type"`
+	Status            hexutil.Uint64     `json:"status"`
+	CumulativeGasUsed hexutil.Uint64     `json:"cumulativeGasUsed"`
+	GasUsed           hexutil.Uint64     `json:"gasUsed"`
+	ContractAddress   *libcommon.Address `json:"contractAddress"`
+	Bloom             types.Bloom        `json:"logsBloom"`
+	Logs              []*refLog          `json:"logs"`
+	DepositNonce      *hexutil.Uint64    `json:"depositNonce"`
+	L1Fee             *hexutil.Big       `json:"l1Fee"`
+	L1GasUsed         *hexutil.Big       `json:"l1GasUsed"`
+}
+
+type refLog struct {
+	Address libcommon.Address `json:"address"`
+	Topics  []libcommon.Hash  `json:"topics"`
+	Data    hexutility.Bytes  `json:"data"`
+}
+
+// Divergence is a field of the block, or of the receipt of the transaction TxIndex, differing from the reference.
+type Divergence struct {
+	TxIndex   *int   `json:"txIndex,omitempty"`
+	Field     string `json:"field"`
+	Local     string `json:"local"`
+	Reference string `json:"reference"`
+}
+
+func (d Divergence) String() string {
+	if d.TxIndex != nil {
+		return fmt.Sprintf("tx %d %s: %s != %s", *d.TxIndex, d.Field, d.Local, d.Reference)
+	}
+	return fmt.Sprintf("%s: %s != %s", d.Field, d.Local, d.Reference)
+}
+
+// differ collects the divergences of a block.
+type differ []Divergence
+
+func (d *differ) check(txIndex *int, field string, local, reference interface{}) {
+	l, r := fmt.Sprint(local), fmt.Sprint(reference)
+	if l != r {
+		*d = append(*d, Divergence{TxIndex: txIndex, Field: field, Local: l, Reference: r})
+	}
+}
+
+// compare returns the divergences of the block, and of its receipts re-executed in the bundle, from the reference.
+func compare(block *types.Block, bundle *forensics.Bundle, ref *refBlock) []Divergence {
+	var d differ
+	d.check(nil, "hash", block.Hash(), ref.Hash)
+	if bundle.ReplayError != "" {
+		d.check(nil, "execution", bundle.ReplayError, "")
+	}
+	var gasUsed uint64
+	if n := len(bundle.Receipts); n > 0 {
+		gasUsed = bundle.Receipts[n-1].CumulativeGasUsed
+	}
+	d.check(nil, "gasUsed", gasUsed, uint64(ref.GasUsed))
+	if len(bundle.Receipts) == len(block.Transactions()) {
+		d.check(nil, "receiptsRoot", types.DeriveSha(bundle.Receipts), ref.ReceiptsRoot)
+	}
+	d.check(nil, "receipts", len(bundle.Receipts), len(ref.Receipts))
+
+	for i := 0; i < len(bundle.Receipts) && i < len(ref.Receipts); i++ {
+		local, reference, txIndex := bundle.Receipts[i], ref.Receipts[i], &i
+		d.check(txIndex, "type", uint64(local.Type), uint64(reference.Type))
+		d.check(txIndex, "status", local.Status, uint64(reference.Status))
+		d.check(txIndex, "cumulativeGasUsed", local.CumulativeGasUsed, uint64(reference.CumulativeGasUsed))
+		d.check(txIndex, "gasUsed", local.GasUsed, uint64(reference.GasUsed))
+		var contractAddress libcommon.Address
+		if reference.ContractAddress != nil {
+			contractAddress = *reference.ContractAddress
+		}
+		d.check(txIndex, "contractAddress", local.ContractAddress, contractAddress)
+		d.check(txIndex, "logsBloom", local.Bloom, reference.Bloom)
+		d.check(txIndex, "depositNonce", uint64Ptr(local.DepositNonce), uint64Ptr((*uint64)(reference.DepositNonce)))
+		d.check(txIndex, "l1Fee", bigPtr(local.L1Fee), bigPtr((*big.Int)(reference.L1Fee)))
+		d.check(txIndex, "l1GasUsed", bigPtr(local.L1GasUsed), bigPtr((*big.Int)(reference.L1GasUsed)))
+		d.check(txIndex, "logs", len(local.Logs), len(reference.Logs))
+		for j := 0; j < len(local.Logs) && j < len(reference.Logs); j++ {
+			l, r := local.Logs[j], reference.Logs[j]
+			if l.Address != r.Address || !equalTopics(l.Topics, r.Topics) || !bytes.Equal(l.Data, r.Data) {
+				d.check(txIndex, fmt.Sprintf("logs[%d]", j), fmt.Sprintf("%x %x %x", l.Address, l.Topics, l.Data), fmt.Sprintf("%x %x %x", r.Address, r.Topics, r.Data))
+			}
+		}
+	}
+	return d
+}
+
+func equalTopics(a, b []libcommon.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func uint64Ptr(v *uint64) string {
+	if v == nil {
+		return "none"
+	}
+	return fmt.Sprint(*v)
+}
+
+func bigPtr(v *big.Int) string {
+	if v == nil {
+		return "none"
+	}
+	return v.String()
+}
@@ -0,0 +1,302 @@
+// Package shadow runs a replica in shadow execution: every new block is re-executed on the state of its parent and
+// compared against a reference node of the same chain (e.g. op-geth), for a continuous differential validation of
+// the execution before the hardforks.
+//
+// The block hash, the receipts root, the gas used and the receipt of every transaction are compared with those served
+// by the reference RPC. The block of the reference is then executed on the state of its parent, in memory as the
+// engine API validates a payload, which checks the state root, the gas used and the receipts root of its header
+// against the ones computed by the replica. The divergences are logged and counted in the shadow_* metrics,
+// and the forensics bundle of the divergent block (see package forensics) is exported with them to a directory of
+// t8n inputs.
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/membatchwithdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/metrics"
+	"github.com/erigontech/erigon-lib/wrap"
+
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/forensics"
+	"github.com/erigontech/erigon/turbo/services"
+	"github.com/erigontech/erigon/turbo/shards"
+)
+
+// retryInterval is the interval of the retries of the blocks the reference does not have yet.
+const retryInterval = 2 * time.Second
+
+// maxReorgDepth is the number of compared blocks remembered, to compare again the blocks of a reorg.
+const maxReorgDepth = 128
+
+var (
+	blocksMatching  = metrics.GetOrCreateCounter(`shadow_blocks_total{result="match"}`)
+	blocksDivergent = metrics.GetOrCreateCounter(`shadow_blocks_total{result="divergent"}`)
+	blocksFailed    = metrics.GetOrCreateCounter(`shadow_blocks_total{result="failed"}`)
+	divergences     = metrics.GetOrCreateCounter("shadow_divergences_total")
+	lastBlock       = metrics.GetOrCreateGauge("shadow_block")
+)
+
+// errNotAvailable is returned for a block the reference does not have yet.
+var errNotAvailable = errors.New("block not available on the reference")
+
+// ValidateFunc executes a block in memory on top of txc, unwound to unwindPoint, as the fork validator of the engine
+// API does. It returns an error wrapping consensus.ErrInvalidBlock when the block is invalid.
+type ValidateFunc func(txc wrap.TxContainer, header *types.Header, body *types.RawBody, unwindPoint uint64, headersChain []*types.Header, bodiesChain []*types.RawBody, notifications *shards.Notifications) error
+
+// Reference is the RPC client of the reference node.
+type Reference interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// Shadow compares the new blocks of the replica against the reference node.
+type Shadow struct {
+	db          kv.RoDB
+	blockReader services.FullBlockReader
+	config      *chain.Config
+	engine      consensus.Engine
+	validate    ValidateFunc // of the blocks of the reference, not executed if nil
+	reference   Reference
+	dir         string // of the forensics of the divergent blocks
+	tmpDir      string
+	logger      log.Logger
+
+	next     uint64                    // next block to compare, 0 until the first head
+	compared map[uint64]libcommon.Hash // the last maxReorgDepth blocks compared
+}
+
+func New(db kv.RoDB, blockReader services.FullBlockReader, config *chain.Config, engine consensus.Engine, validate ValidateFunc, reference Reference, dir, tmpDir string, logger log.Logger) *Shadow {
+	return &Shadow{
+		db:          db,
+		blockReader: blockReader,
+		config:      config,
+		engine:      engine,
+		validate:    validate,
+		reference:   reference,
+		dir:         dir,
+		tmpDir:      tmpDir,
+		logger:      logger,
+		compared:    map[uint64]libcommon.Hash{},
+	}
+}
+
+// Dial creates the client of the reference RPC.
+func Dial(ctx context.Context, url string, logger log.Logger) (*rpc.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return rpc.DialContext(ctx, url, logger)
+}
+
+// Run compares the blocks executed since the first head of headCh, as the new heads come, until headCh is closed.
+func (s *Shadow) Run(ctx context.Context, headCh <-chan [][]byte) {
+	s.logger.Info("[shadow] Comparing the new blocks against the reference node", "dir", s.dir)
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-headCh:
+			if !ok {
+				return
+			}
+		case <-retry.C:
+		}
+		if err := s.catchUp(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Warn("[shadow] Comparison failed", "err", err)
+		}
+	}
+}
+
+// catchUp compares the blocks executed since the last one compared, up to the first block the reference does not
+// have yet. The blocks compared which are no longer canonical after a reorg are compared again.
+func (s *Shadow) catchUp(ctx context.Context) error {
+	var head uint64
+	if err := s.db.View(ctx, func(tx kv.Tx) (err error) {
+		if head, err = stages.GetStageProgress(tx, stages.Execution); err != nil {
+			return err
+		}
+		if s.next == 0 {
+			s.next = head
+			return nil
+		}
+		for ; s.next > 1; s.next-- {
+			hash, ok := s.compared[s.next-1]
+			if !ok {
+				break
+			}
+			canonical, err := s.blockReader.CanonicalHash(ctx, tx, s.next-1)
+			if err != nil {
+				return err
+			}
+			if canonical == hash {
+				break
+			}
+			delete(s.compared, s.next-1)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for ; s.next > 0 && s.next <= head; s.next++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hash, err := s.check(ctx, s.next)
+		if errors.Is(err, errNotAvailable) {
+			return nil
+		}
+		if err != nil {
+			blocksFailed.Inc()
+			s.logger.Warn("[shadow] Could not compare block", "number", s.next, "err", err)
+			continue
+		}
+		s.compared[s.next] = hash
+		if s.next > maxReorgDepth {
+			delete(s.compared, s.next-maxReorgDepth)
+		}
+	}
+	return nil
+}
+
+// Check re-executes the block and compares it against the reference, exporting the forensics of the block to the
+// directory when they diverge.
+func (s *Shadow) Check(ctx context.Context, number uint64) error {
+	_, err := s.check(ctx, number)
+	return err
+}
+
+// check compares the block of the number and returns its hash.
+func (s *Shadow) check(ctx context.Context, number uint64) (libcommon.Hash, error) {
+	ref, err := s.fetchReference(ctx, number)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	tx, err := s.db.BeginRo(ctx)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	defer tx.Rollback()
+	hash, err := s.blockReader.CanonicalHash(ctx, tx, number)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	block, senders, err := s.blockReader.BlockWithSenders(ctx, tx, hash, number)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	if block == nil {
+		return libcommon.Hash{}, fmt.Errorf("block %d not found", number)
+	}
+	bundle, err := forensics.Build(ctx, tx, s.config, s.engine, s.blockReader, block, nil, s.logger)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	if len(bundle.Receipts) == len(block.Transactions()) {
+		// the fields of the receipts served by the RPC, the L1 fees of the OP Stack
+		if err := bundle.Receipts.DeriveFields(s.config, hash, number, block.Time(), block.Transactions(), senders); err != nil {
+			return libcommon.Hash{}, err
+		}
+	}
+	diffs := compare(block, bundle, ref)
+	if err := s.executeReference(ctx, tx, number, block.ParentHash(), ref); errors.Is(err, consensus.ErrInvalidBlock) {
+		diffs = append(diffs, Divergence{Field: "referenceBlock", Local: err.Error(), Reference: "valid"})
+	} else if err != nil {
+		return libcommon.Hash{}, err
+	}
+	lastBlock.SetUint64(number)
+	if len(diffs) == 0 {
+		blocksMatching.Inc()
+		return hash, nil
+	}
+
+	blocksDivergent.Inc()
+	divergences.AddInt(len(diffs))
+	for _, d := range diffs {
+		s.logger.Warn("[shadow] Divergence from the reference", "number", number, "hash", block.Hash(), "divergence", d.String())
+	}
+	bundle.Error = fmt.Sprintf("%d divergences from the reference, the first one: %s", len(diffs), diffs[0])
+	dir := filepath.Join(s.dir, fmt.Sprintf("%d_%x", number, block.Hash()))
+	if err := bundle.ExportT8n(dir); err != nil {
+		return libcommon.Hash{}, err
+	}
+	data, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "divergence.json"), data, 0644); err != nil {
+		return libcommon.Hash{}, err
+	}
+	s.logger.Warn("[shadow] Exported the forensics of the divergent block", "number", number, "dir", dir)
+	return hash, nil
+}
+
+// executeReference executes the block of the reference on the state of its parent, in a memory batch over tx. The
+// execution fails with consensus.ErrInvalidBlock when the state root, the gas used or the receipts root of its header
+// differ from the ones computed. The first block is not executed: the validation only unwinds to a block after the
+// genesis. Neither is a block whose parent is not the canonical one of the replica, the branches diverged before it.
+func (s *Shadow) executeReference(ctx context.Context, tx kv.Tx, number uint64, parent libcommon.Hash, ref *refBlock) error {
+	if s.validate == nil || number < 2 {
+		return nil
+	}
+	var raw hexutility.Bytes
+	if err := s.reference.CallContext(ctx, &raw, "debug_getRawBlock", hexutil.Uint64(number)); err != nil {
+		return err
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(raw, block); err != nil {
+		return fmt.Errorf("raw block %d of the reference: %w", number, err)
+	}
+	if block.Hash() != ref.Hash {
+		return fmt.Errorf("raw block %d of the reference: hash %x, expected %x", number, block.Hash(), ref.Hash)
+	}
+	if block.ParentHash() != parent {
+		return nil
+	}
+	batch := membatchwithdb.NewMemoryBatch(tx, s.tmpDir, s.logger)
+	defer batch.Close()
+	notifications := &shards.Notifications{Events: shards.NewEvents(), Accumulator: shards.NewAccumulator()}
+	return s.validate(wrap.TxContainer{Tx: batch}, block.Header(), block.RawBody(), number-1, nil, nil, notifications)
+}
+
+// fetchReference retrieves the header and the receipts of the block from the reference.
+func (s *Shadow) fetchReference(ctx context.Context, number uint64) (*refBlock, error) {
+	var block *refBlock
+	if err := s.reference.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.Uint64(number), false); err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errNotAvailable
+	}
+	if err := s.reference.CallContext(ctx, &block.Receipts, "eth_getBlockReceipts", hexutil.Uint64(number)); err != nil {
+		return nil, err
+	}
+	if block.Receipts == nil {
+		return nil, errNotAvailable
+	}
+	return block, nil
+}
+
+// refBlock holds the compared fields of a block of the reference.
+type refBlock struct {
+	Hash         libcommon.Hash `json:"hash"`
+	ReceiptsRoot libcommon.Hash `json:"receiptsRoot"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	Receipts     []*refReceipt  `json:"-"`
+}
@@ -0,0 +1,225 @@
+package shadow_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/shadow"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+// fakeReference serves the blocks and receipts of a chain.
+type fakeReference struct {
+	blocks   map[uint64]map[string]interface{}
+	receipts map[uint64][]map[string]interface{}
+	raw      map[uint64]hexutility.Bytes
+}
+
+func (r *fakeReference) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	number := uint64(args[0].(hexutil.Uint64))
+	var v interface{}
+	switch method {
+	case "eth_getBlockByNumber":
+		if b, ok := r.blocks[number]; ok {
+			v = b
+		}
+	case "eth_getBlockReceipts":
+		if rs, ok := r.receipts[number]; ok {
+			v = rs
+		}
+	case "debug_getRawBlock":
+		if raw, ok := r.raw[number]; ok {
+			v = raw
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// newReference serves the blocks of the chain as the RPC of another node would.
+func newReference(t *testing.T, chain *core.ChainPack) *fakeReference {
+	r := &fakeReference{blocks: map[uint64]map[string]interface{}{}, receipts: map[uint64][]map[string]interface{}{}, raw: map[uint64]hexutility.Bytes{}}
+	for i, block := range chain.Blocks {
+		number := block.NumberU64()
+		raw, err := rlp.EncodeToBytes(block)
+		require.NoError(t, err)
+		r.raw[number] = raw
+		r.blocks[number] = map[string]interface{}{
+			"hash":         block.Hash(),
+			"receiptsRoot": block.ReceiptHash(),
+			"gasUsed":      hexutil.Uint64(block.GasUsed()),
+		}
+		r.receipts[number] = []map[string]interface{}{}
+		for _, receipt := range chain.Receipts[i] {
+			data, err := json.Marshal(receipt)
+			require.NoError(t, err)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &fields))
+			r.receipts[number] = append(r.receipts[number], fields)
+		}
+	}
+	return r
+}
+
+func TestShadowCheck(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	gspec := &types.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  types.GenesisAlloc{address: {Balance: big.NewInt(1e18)}},
+	}
+	m := mock.MockWithGenesis(t, gspec, key, false)
+	// the contract of the first block emits a log when called
+	code := hexutil.MustDecode("0x60016000a000")
+	init := append(hexutil.MustDecode("0x6006600c60003960066000f3"), code...)
+	contract := crypto.CreateAddress(address, 0)
+	signer := types.LatestSignerForChainID(nil)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 3, func(i int, b *core.BlockGen) {
+		var txn types.Transaction
+		if i == 0 {
+			txn = types.NewContractCreation(b.TxNonce(address), new(uint256.Int), 1e6, new(uint256.Int), init)
+		} else {
+			txn = types.NewTransaction(b.TxNonce(address), contract, new(uint256.Int), 1e6, new(uint256.Int), nil)
+		}
+		signed, err := types.SignTx(txn, *signer, key)
+		require.NoError(t, err)
+		b.AddTx(signed)
+	})
+	require.NoError(t, err)
+	require.NoError(t, m.InsertChain(chain))
+
+	ctx := context.Background()
+	reference := newReference(t, chain)
+	dir := t.TempDir()
+	s := shadow.New(m.DB, m.BlockReader, m.ChainConfig, m.Engine, m.InMemoryExecution, reference, dir, t.TempDir(), m.Log)
+	for number := uint64(1); number <= 3; number++ {
+		require.NoError(t, s.Check(ctx, number))
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// the reference does not have the block yet
+	require.Error(t, s.Check(ctx, 4))
+
+	// the reference diverges on the hash and the gas of the transaction of block 2
+	header := chain.Blocks[1].Header()
+	header.Extra = []byte("reference")
+	resealed := chain.Blocks[1].WithSeal(header)
+	reference.raw[2], err = rlp.EncodeToBytes(resealed)
+	require.NoError(t, err)
+	reference.blocks[2]["hash"] = resealed.Hash()
+	reference.receipts[2][0]["gasUsed"] = hexutil.Uint64(1)
+	reference.receipts[2][0]["logs"] = []interface{}{}
+	require.NoError(t, s.Check(ctx, 2))
+
+	exported := filepath.Join(dir, "2_"+chain.Blocks[1].Hash().Hex()[2:])
+	data, err := os.ReadFile(filepath.Join(exported, "divergence.json"))
+	require.NoError(t, err)
+	var diffs []shadow.Divergence
+	require.NoError(t, json.Unmarshal(data, &diffs))
+	var fields []string
+	for _, d := range diffs {
+		fields = append(fields, d.Field)
+	}
+	require.Equal(t, []string{"hash", "gasUsed", "logs"}, fields)
+	require.Nil(t, diffs[0].TxIndex)
+	require.Equal(t, 0, *diffs[1].TxIndex)
+	require.Equal(t, "1", diffs[2].Local)
+	require.Equal(t, "0", diffs[2].Reference)
+	for _, name := range []string{"alloc.json", "env.json", "txs.json", "forensics.json"} {
+		require.FileExists(t, filepath.Join(exported, name))
+	}
+	var alloc types.GenesisAlloc
+	data, err = os.ReadFile(filepath.Join(exported, "alloc.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &alloc))
+	require.Equal(t, code, alloc[contract].Code)
+
+	// the block 3 of the reference has another state root, which its execution doesn't compute
+	block := chain.Blocks[2]
+	header = block.Header()
+	header.Root = libcommon.HexToHash("0x02")
+	tampered := block.WithSeal(header)
+	raw, err := rlp.EncodeToBytes(tampered)
+	require.NoError(t, err)
+	reference.raw[3] = raw
+	reference.blocks[3]["hash"] = tampered.Hash()
+	require.NoError(t, s.Check(ctx, 3))
+
+	data, err = os.ReadFile(filepath.Join(dir, "3_"+block.Hash().Hex()[2:], "divergence.json"))
+	require.NoError(t, err)
+	diffs = nil
+	require.NoError(t, json.Unmarshal(data, &diffs))
+	require.Len(t, diffs, 2)
+	require.Equal(t, "hash", diffs[0].Field)
+	require.Equal(t, "referenceBlock", diffs[1].Field)
+	require.Contains(t, diffs[1].Local, "wrong trie root")
+	require.Equal(t, "valid", diffs[1].Reference)
+}
+
+func TestShadowRunReorg(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	gspec := &types.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  types.GenesisAlloc{address: {Balance: big.NewInt(1e18)}},
+	}
+	m := mock.MockWithGenesis(t, gspec, key, false)
+	generate := func(n int, coinbase libcommon.Address) *core.ChainPack {
+		chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, n, func(i int, b *core.BlockGen) {
+			b.SetCoinbase(coinbase)
+		})
+		require.NoError(t, err)
+		return chain
+	}
+	chain, fork := generate(3, libcommon.Address{1}), generate(4, libcommon.Address{2})
+	require.NoError(t, m.InsertChain(chain))
+
+	dir := t.TempDir()
+	s := shadow.New(m.DB, m.BlockReader, m.ChainConfig, m.Engine, m.InMemoryExecution, newReference(t, chain), dir, t.TempDir(), m.Log)
+	headCh := make(chan [][]byte)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(context.Background(), headCh)
+	}()
+	headCh <- nil
+	headCh <- nil // received once the first head was handled
+
+	// the replica reorgs to the fork the reference doesn't have: its block 3 is compared again
+	require.NoError(t, m.InsertChain(fork))
+	headCh <- nil
+	exported := filepath.Join(dir, "3_"+fork.Blocks[2].Hash().Hex()[2:])
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(exported, "divergence.json"))
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	close(headCh)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return once the heads channel was closed")
+	}
+}
@@ -26,6 +26,7 @@ type Events struct {
 	pendingBlockSubscriptions map[int]PendingBlockSubscription
 	pendingTxsSubscriptions   map[int]PendingTxsSubscription
 	logsSubscriptions         map[int]chan []*remote.SubscribeLogsReply
+	forkchoiceSubscriptions   map[int]chan *remote.SubscribeReply
 	hasLogSubscriptions       bool
 	lock                      sync.RWMutex
 }
@@ -38,6 +39,7 @@ func NewEvents() *Events {
 		pendingTxsSubscriptions:   map[int]PendingTxsSubscription{},
 		logsSubscriptions:         map[int]chan []*remote.SubscribeLogsReply{},
 		newSnapshotSubscription:   map[int]chan struct{}{},
+		forkchoiceSubscriptions:   map[int]chan *remote.SubscribeReply{},
 	}
 }
 
@@ -80,6 +82,21 @@ func (e *Events) AddLogsSubscription() (chan []*remote.SubscribeLogsReply, func(
 	}
 }
 
+// AddForkchoiceSubscription subscribes to the headers of the new safe and
+// finalized blocks, as SAFE_HEADER and FINALIZED_HEADER events.
+func (e *Events) AddForkchoiceSubscription() (chan *remote.SubscribeReply, func()) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	ch := make(chan *remote.SubscribeReply, 8)
+	e.id++
+	id := e.id
+	e.forkchoiceSubscriptions[id] = ch
+	return ch, func() {
+		delete(e.forkchoiceSubscriptions, id)
+		close(ch)
+	}
+}
+
 func (e *Events) EmptyLogSubsctiption(empty bool) {
 	e.lock.Lock()
 	defer e.lock.Unlock()
@@ -120,6 +137,22 @@ func (e *Events) OnNewHeader(newHeadersRlp [][]byte) {
 	}
 }
 
+func (e *Events) OnNewSafeHeader(headerRlp []byte) {
+	e.onForkchoice(&remote.SubscribeReply{Type: remote.Event_SAFE_HEADER, Data: headerRlp})
+}
+
+func (e *Events) OnNewFinalizedHeader(headerRlp []byte) {
+	e.onForkchoice(&remote.SubscribeReply{Type: remote.Event_FINALIZED_HEADER, Data: headerRlp})
+}
+
+func (e *Events) onForkchoice(reply *remote.SubscribeReply) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, ch := range e.forkchoiceSubscriptions {
+		common.PrioritizedSend(ch, reply)
+	}
+}
+
 func (e *Events) OnNewPendingLogs(logs types.Logs) {
 	e.lock.Lock()
 	defer e.lock.Unlock()
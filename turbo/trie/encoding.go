@@ -58,6 +58,12 @@ func hexToCompact(hex []byte) []byte {
 	return buf
 }
 
+// CompactToHex translates from COMPACT to HEX encoding. It is used to decode
+// the trie node paths requested by snap peers.
+func CompactToHex(compact []byte) []byte {
+	return compactToHex(compact)
+}
+
 func compactToHex(compact []byte) []byte {
 	if len(compact) == 0 {
 		return compact
@@ -55,6 +55,10 @@ type ProofRetainer struct {
 	storageKeys    []libcommon.Hash
 	storageHexKeys [][]byte
 	proofs         []*proofElement
+
+	// hexKeys is the sorted list of keys retained by a multi-key retainer
+	// (see NewMultiProofRetainer), nil for the single account retainer.
+	hexKeys [][]byte
 }
 
 // NewProofRetainer creates a new ProofRetainer instance for a given account and
@@ -93,6 +97,34 @@ func NewProofRetainer(addr libcommon.Address, a *accounts.Account, storageKeys [
 	}, nil
 }
 
+// NewMultiProofRetainer creates a ProofRetainer collecting the trie nodes on
+// the paths to an arbitrary set of keys. Keys are either in KEY encoding (32
+// byte account hashes or 72 byte storage keys, as stored in HashedStorage) or,
+// when hex is set, already in HEX encoding, which allows addressing inner
+// nodes of the trie. Unlike NewProofRetainer, the result is not an
+// EIP-1186 proof but the raw nodes, see ProofNodes, Node and StorageRoots.
+func NewMultiProofRetainer(keys [][]byte, hex bool, rl *RetainList) *ProofRetainer {
+	hexKeys := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		if hex {
+			rl.AddHex(k)
+			rl.markers = append(rl.markers, false)
+			hexKeys = append(hexKeys, k)
+			continue
+		}
+		hexKeys = append(hexKeys, rl.AddKey(k))
+	}
+	sort.Slice(hexKeys, func(i, j int) bool { return bytes.Compare(hexKeys[i], hexKeys[j]) < 0 })
+	return &ProofRetainer{rl: rl, hexKeys: hexKeys}
+}
+
+// retainsPrefix reports whether the prefix is on the path to one of the keys
+// of a multi-key retainer.
+func (pr *ProofRetainer) retainsPrefix(prefix []byte) bool {
+	i := sort.Search(len(pr.hexKeys), func(i int) bool { return bytes.Compare(pr.hexKeys[i], prefix) >= 0 })
+	return i < len(pr.hexKeys) && bytes.HasPrefix(pr.hexKeys[i], prefix)
+}
+
 // ProofElement requests a new proof element for a given prefix.  This proof
 // element is retained by the ProofRetainer, and will be utilized to compute the
 // proof after the trie computation has completed.  The prefix is the standard
@@ -102,6 +134,17 @@ func (pr *ProofRetainer) ProofElement(prefix []byte) *proofElement {
 		return nil
 	}
 
+	if pr.hexKeys != nil {
+		if !pr.retainsPrefix(prefix) {
+			return nil
+		}
+		pe := &proofElement{
+			hexKey: append([]byte{}, prefix...),
+		}
+		pr.proofs = append(pr.proofs, pe)
+		return pe
+	}
+
 	switch {
 	case bytes.HasPrefix(pr.accHexKey, prefix):
 		// This prefix is a node between the account and the root
@@ -191,6 +234,74 @@ func (pr *ProofRetainer) ProofResult() (*accounts.AccProofResult, error) {
 	return result, nil
 }
 
+// ProofNodes returns the distinct RLP encoded nodes collected by a multi-key
+// retainer on the paths to the given keys, which must be a subset of the
+// retained keys in KEY encoding. Only the nodes of the storage tries are
+// returned when storage is set, otherwise only the nodes of the account trie.
+// It may be invoked only after the Load function of the FlatDBTrieLoader has
+// successfully executed.
+func (pr *ProofRetainer) ProofNodes(keys [][]byte, storage bool) [][]byte {
+	hexKeys := make([][]byte, len(keys))
+	for i, k := range keys {
+		hexKeys[i] = make([]byte, 2*len(k))
+		for j, b := range k {
+			hexKeys[i][j*2] = b / 16
+			hexKeys[i][j*2+1] = b % 16
+		}
+	}
+	var nodes [][]byte
+	seen := map[string]struct{}{}
+	for _, pe := range pr.proofs {
+		if (len(pe.hexKey) > 2*length.Hash) != storage || pe.proof.Len() == 0 {
+			continue
+		}
+		onPath := false
+		for _, hexKey := range hexKeys {
+			if bytes.HasPrefix(hexKey, pe.hexKey) {
+				onPath = true
+				break
+			}
+		}
+		if !onPath {
+			continue
+		}
+		if _, ok := seen[string(pe.proof.Bytes())]; ok {
+			continue
+		}
+		seen[string(pe.proof.Bytes())] = struct{}{}
+		nodes = append(nodes, libcommon.CopyBytes(pe.proof.Bytes()))
+	}
+	return nodes
+}
+
+// Node returns the RLP encoded node located exactly at the given HEX encoded
+// path, or nil if there is no such node.
+func (pr *ProofRetainer) Node(hexKey []byte) []byte {
+	for _, pe := range pr.proofs {
+		if pe.proof.Len() > 0 && bytes.Equal(pe.hexKey, hexKey) {
+			return libcommon.CopyBytes(pe.proof.Bytes())
+		}
+	}
+	return nil
+}
+
+// StorageRoots returns the storage roots of the account leaves collected by
+// the retainer, keyed by account hash.
+func (pr *ProofRetainer) StorageRoots() map[libcommon.Hash]libcommon.Hash {
+	roots := map[libcommon.Hash]libcommon.Hash{}
+	for _, pe := range pr.proofs {
+		if len(pe.storageRootKey) != 2*length.Hash {
+			continue
+		}
+		var accHash libcommon.Hash
+		for i := range accHash {
+			accHash[i] = pe.storageRootKey[2*i]<<4 | pe.storageRootKey[2*i+1]
+		}
+		roots[accHash] = pe.storageRoot
+	}
+	return roots
+}
+
 // proofElement represent a node or leaf in the trie and its
 // corresponding RLP encoding.  We store the elements individually when
 // aggregating as multiple keys (in particular storage keys) may need to